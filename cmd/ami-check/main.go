@@ -10,7 +10,7 @@ import (
 	"syscall"
 
 	awsutil "github.com/Comcast/kuberhealthy/v2/pkg/aws"
-	kh "github.com/Comcast/kuberhealthy/v2/pkg/checks/external/checkClient"
+	kh "github.com/Comcast/kuberhealthy/v2/pkg/checkclient"
 	"github.com/aws/aws-sdk-go/aws/session"
 	log "github.com/sirupsen/logrus"
 	"k8s.io/client-go/kubernetes"
@@ -36,7 +36,7 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes"
 
-	checkclient "github.com/Comcast/kuberhealthy/v2/pkg/checks/external/checkClient"
+	checkclient "github.com/Comcast/kuberhealthy/v2/pkg/checkclient"
 	"github.com/Comcast/kuberhealthy/v2/pkg/kubeClient"
 )
 
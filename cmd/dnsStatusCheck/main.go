@@ -27,7 +27,7 @@ import (
 
 	"k8s.io/client-go/kubernetes"
 
-	checkclient "github.com/Comcast/kuberhealthy/v2/pkg/checks/external/checkClient"
+	checkclient "github.com/Comcast/kuberhealthy/v2/pkg/checkclient"
 	"github.com/Comcast/kuberhealthy/v2/pkg/kubeClient"
 )
 
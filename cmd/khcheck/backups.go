@@ -0,0 +1,179 @@
+// Copyright 2018 Comcast Cable Communications Management, LLC
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//     http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/integrii/flaggy"
+	log "github.com/sirupsen/logrus"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// the flags of the backups check
+var backupsSource = "velero"
+var backupsMaxAge = time.Hour * 25
+var backupsVeleroNamespace = "velero"
+var backupsSchedule string
+var backupsBucket string
+var backupsPrefix string
+var backupsRegion string
+var backupsEndpoint string
+var backupsMinSize int64 = 1
+
+// veleroBackupList is the part of a list of Velero Backup resources that the backups check reads
+type veleroBackupList struct {
+	Items []struct {
+		Metadata metav1.ObjectMeta `json:"metadata"`
+		Status   struct {
+			Phase               string       `json:"phase"`
+			CompletionTimestamp *metav1.Time `json:"completionTimestamp"`
+		} `json:"status"`
+	} `json:"items"`
+}
+
+// backupsSubcommand returns the subcommand of the backups check
+func backupsSubcommand() *flaggy.Subcommand {
+	s := flaggy.NewSubcommand("backups")
+	s.Description = "Expect the most recent backup to be newer than a maximum age"
+	s.String(&backupsSource, "", "source", "Where backups are found.  Either velero, for Velero Backup resources, or s3, for snapshots such as those of etcd in an S3 compatible bucket.")
+	s.Duration(&backupsMaxAge, "", "maxAge", "How old the most recent backup can be.")
+	s.String(&backupsVeleroNamespace, "", "veleroNamespace", "The namespace of the Velero Backup resources.")
+	s.String(&backupsSchedule, "", "schedule", "Only count Velero backups created by this schedule.")
+	s.String(&backupsBucket, "", "bucket", "The bucket snapshots are stored in.  Required by the s3 source.")
+	s.String(&backupsPrefix, "", "prefix", "Only count snapshots whose keys start with this prefix.")
+	s.String(&backupsRegion, "", "region", "The region of the bucket.")
+	s.String(&backupsEndpoint, "", "endpoint", "The endpoint of an S3 compatible object store, such as MinIO.  Defaults to AWS.")
+	s.Int64(&backupsMinSize, "", "minSize", "How many bytes a snapshot must have to count, so that empty or truncated snapshots are ignored.")
+	return s
+}
+
+// runBackupsCheck finds the most recent backup and fails if there is none or it is older than the maximum age.
+// The age of the most recent backup is measured.
+func runBackupsCheck(ctx context.Context) error {
+	var latest time.Time
+	var name string
+	var err error
+	switch backupsSource {
+	case "velero":
+		latest, name, err = latestVeleroBackup(ctx)
+	case "s3":
+		latest, name, err = latestS3Snapshot(ctx)
+	default:
+		return errors.New("--source must be velero or s3, not " + backupsSource)
+	}
+	if err != nil {
+		return err
+	}
+	if len(name) == 0 {
+		return errors.New("no completed backups were found")
+	}
+
+	age := time.Since(latest)
+	measure("backup_age_seconds", age.Seconds())
+	if age > backupsMaxAge {
+		return fmt.Errorf("the most recent backup %s completed %s ago at %s, which is more than the maximum age of %s",
+			name, age.Round(time.Second), latest.Format(time.RFC3339), backupsMaxAge)
+	}
+	log.Infoln("The most recent backup", name, "completed", age.Round(time.Second), "ago")
+	return nil
+}
+
+// latestVeleroBackup returns the completion time and name of the most recent Velero backup that completed.
+// Backups that failed or only partially completed are not counted.
+func latestVeleroBackup(ctx context.Context) (time.Time, string, error) {
+	client, err := newKubeClient()
+	if err != nil {
+		return time.Time{}, "", fmt.Errorf("error creating kubernetes client: %w", err)
+	}
+	req := client.Discovery().RESTClient().Get().AbsPath("/apis/velero.io/v1/namespaces", backupsVeleroNamespace, "backups")
+	if len(backupsSchedule) > 0 {
+		req = req.Param("labelSelector", "velero.io/schedule-name="+backupsSchedule)
+	}
+	body, err := req.Context(ctx).DoRaw()
+	if err != nil {
+		return time.Time{}, "", fmt.Errorf("error listing velero backups in %s: %w", backupsVeleroNamespace, err)
+	}
+	var backups veleroBackupList
+	err = json.Unmarshal(body, &backups)
+	if err != nil {
+		return time.Time{}, "", fmt.Errorf("error parsing velero backups: %w", err)
+	}
+	latest, name := newestVeleroBackup(backups)
+	return latest, name, nil
+}
+
+// newestVeleroBackup returns the completion time and name of the most recent completed backup in a list
+func newestVeleroBackup(backups veleroBackupList) (time.Time, string) {
+	var latest time.Time
+	var name string
+	for _, b := range backups.Items {
+		if b.Status.Phase != "Completed" || b.Status.CompletionTimestamp == nil {
+			continue
+		}
+		if b.Status.CompletionTimestamp.After(latest) {
+			latest = b.Status.CompletionTimestamp.Time
+			name = b.Metadata.Name
+		}
+	}
+	return latest, name
+}
+
+// latestS3Snapshot returns the last modified time and key of the most recent snapshot in the bucket that is at
+// least the minimum size.  Credentials are found the same way as the AWS CLI finds them.
+func latestS3Snapshot(ctx context.Context) (time.Time, string, error) {
+	if len(backupsBucket) == 0 {
+		return time.Time{}, "", errors.New("--bucket is required by the s3 source")
+	}
+	config := aws.NewConfig()
+	if len(backupsRegion) > 0 {
+		config = config.WithRegion(backupsRegion)
+	}
+	if len(backupsEndpoint) > 0 {
+		config = config.WithEndpoint(backupsEndpoint).WithS3ForcePathStyle(true)
+	}
+	sess, err := session.NewSession(config)
+	if err != nil {
+		return time.Time{}, "", fmt.Errorf("error creating aws session: %w", err)
+	}
+
+	var latest time.Time
+	var key string
+	input := &s3.ListObjectsV2Input{Bucket: aws.String(backupsBucket)}
+	if len(backupsPrefix) > 0 {
+		input.Prefix = aws.String(backupsPrefix)
+	}
+	err = s3.New(sess).ListObjectsV2PagesWithContext(ctx, input, func(page *s3.ListObjectsV2Output, last bool) bool {
+		for _, o := range page.Contents {
+			if o.LastModified == nil || aws.Int64Value(o.Size) < backupsMinSize || strings.HasSuffix(aws.StringValue(o.Key), "/") {
+				continue
+			}
+			if o.LastModified.After(latest) {
+				latest = *o.LastModified
+				key = aws.StringValue(o.Key)
+			}
+		}
+		return true
+	})
+	if err != nil {
+		return time.Time{}, "", fmt.Errorf("error listing snapshots in bucket %s: %w", backupsBucket, err)
+	}
+	return latest, key, nil
+}
@@ -0,0 +1,232 @@
+// Copyright 2018 Comcast Cable Communications Management, LLC
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//     http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/integrii/flaggy"
+	log "github.com/sirupsen/logrus"
+	apiv1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+
+	"github.com/Comcast/kuberhealthy/v2/pkg/kubeClient"
+)
+
+// the flags of the clock check
+var clockMaxSkew = time.Millisecond * 500
+var clockNTPServer = "pool.ntp.org"
+var clockImage = "busybox:1.31"
+var clockTolerateAll = true
+var clockSamples = 3
+
+// ntpEpochOffset is the number of seconds between the NTP epoch of 1900 and the unix epoch of 1970
+const ntpEpochOffset = 2208988800
+
+// clockReading is the time read from the clock of a node, and how far off the reading could be because of the time
+// taken to read it
+type clockReading struct {
+	Node        string
+	Skew        time.Duration // how far the clock of the node is ahead of the reference clock
+	Uncertainty time.Duration // half of the round trip of the reading
+}
+
+// clockSubcommand returns the subcommand of the clock check
+func clockSubcommand() *flaggy.Subcommand {
+	s := flaggy.NewSubcommand("clock")
+	s.Description = "Read the clock of every node and expect it to be close to an NTP reference"
+	s.Duration(&clockMaxSkew, "", "maxSkew", "How far the clock of a node can be from the reference clock.")
+	s.String(&clockNTPServer, "", "ntpServer", "The NTP server used as the reference clock.  Set to an empty string to use the clock of the checker pod.")
+	s.String(&clockImage, "", "image", "The image of the pods that read the clock of each node.  It must have a date command that supports %N.")
+	s.Bool(&clockTolerateAll, "", "tolerateAll", "Tolerate every taint so that the clock of every node is read.")
+	s.Int(&clockSamples, "", "samples", "How many times the clock of each node is read.  The reading with the shortest round trip is used.")
+	return s
+}
+
+// runClockCheck runs a pod on every node with a daemonset, reads the clock of each node from its pod, and fails
+// if any of them is further from the reference clock than the maximum skew.  Clock drift breaks TLS, leases and
+// leader election in ways that are hard to trace back to the clock.  The skew of each node is measured.
+func runClockCheck(ctx context.Context) error {
+	var offset time.Duration
+	if len(clockNTPServer) > 0 {
+		var err error
+		offset, err = ntpOffset(ctx, clockNTPServer)
+		if err != nil {
+			return err
+		}
+		log.Infoln("The clock of the checker pod is", offset, "behind", clockNTPServer)
+	}
+
+	config, err := kubeClient.Config(kubeConfigFile)
+	if err != nil {
+		return fmt.Errorf("error creating kubernetes client config: %w", err)
+	}
+	client, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		return fmt.Errorf("error creating kubernetes client: %w", err)
+	}
+
+	pods, err := createNodePods(ctx, client, "clock", clockImage, clockTolerateAll)
+	if err != nil {
+		return err
+	}
+
+	var readings []clockReading
+	for _, pod := range pods {
+		reading, err := readNodeClock(ctx, client, config, pod, offset)
+		if err != nil {
+			return fmt.Errorf("error reading the clock of node %s: %w", pod.Spec.NodeName, err)
+		}
+		readings = append(readings, reading)
+		measure(strings.Trim(measurementNamePattern.ReplaceAllString(reading.Node, "_"), "_")+"_skew_ms", float64(reading.Skew.Milliseconds()))
+	}
+	return clockSkewError(readings)
+}
+
+// readNodeClock reads the clock of the node a pod runs on by running date in the pod.  The reading is compared to
+// the reference clock at the middle of its round trip, and the reading with the shortest round trip is used.
+func readNodeClock(ctx context.Context, client kubernetes.Interface, config *rest.Config, pod apiv1.Pod, offset time.Duration) (clockReading, error) {
+	best := clockReading{Node: pod.Spec.NodeName, Uncertainty: time.Duration(math.MaxInt64)}
+	samples := clockSamples
+	if samples < 1 {
+		samples = 1
+	}
+	for i := 0; i < samples; i++ {
+		if ctx.Err() != nil {
+			return best, ctx.Err()
+		}
+		start := time.Now()
+		stdout, err := execInPod(client, config, pod, "date", "-u", "+%s.%N")
+		roundTrip := time.Since(start)
+		if err != nil {
+			return best, err
+		}
+		nodeTime, err := parseUnixTime(stdout)
+		if err != nil {
+			return best, err
+		}
+
+		uncertainty := roundTrip / 2
+		if uncertainty < best.Uncertainty {
+			reference := start.Add(uncertainty).Add(offset)
+			best.Skew = nodeTime.Sub(reference)
+			best.Uncertainty = uncertainty
+		}
+	}
+	log.Infoln("The clock of node", best.Node, "is", best.Skew, "ahead of the reference clock, give or take", best.Uncertainty)
+	return best, nil
+}
+
+// clockSkewError returns an error naming every node whose clock is certainly further from the reference clock than
+// the maximum skew, even after allowing for the time taken to read it
+func clockSkewError(readings []clockReading) error {
+	var skewed []string
+	for _, r := range readings {
+		skew := r.Skew
+		if skew < 0 {
+			skew = -skew
+		}
+		if skew-r.Uncertainty > clockMaxSkew {
+			skewed = append(skewed, fmt.Sprintf("%s is %s off", r.Node, r.Skew.Round(time.Millisecond)))
+		}
+	}
+	if len(skewed) > 0 {
+		sort.Strings(skewed)
+		return fmt.Errorf("the clocks of %d nodes are more than %s from the reference clock: %s", len(skewed), clockMaxSkew, strings.Join(skewed, ", "))
+	}
+	return nil
+}
+
+// parseUnixTime parses the seconds and nanoseconds since the unix epoch written by date +%s.%N
+func parseUnixTime(s string) (time.Time, error) {
+	parts := strings.SplitN(strings.TrimSpace(s), ".", 2)
+	seconds, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil || len(parts) != 2 || len(parts[1]) != 9 {
+		return time.Time{}, fmt.Errorf("date wrote %q instead of seconds and nanoseconds", strings.TrimSpace(s))
+	}
+	nanoseconds, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("date wrote %q instead of seconds and nanoseconds", strings.TrimSpace(s))
+	}
+	return time.Unix(seconds, nanoseconds), nil
+}
+
+// ntpOffset asks an NTP server for the time and returns how far the local clock is behind it
+func ntpOffset(ctx context.Context, server string) (time.Duration, error) {
+	address := server
+	if _, _, err := net.SplitHostPort(server); err != nil {
+		address = net.JoinHostPort(server, "123")
+	}
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "udp", address)
+	if err != nil {
+		return 0, fmt.Errorf("error connecting to ntp server %s: %w", server, err)
+	}
+	defer conn.Close()
+	err = conn.SetDeadline(time.Now().Add(time.Second * 5))
+	if err != nil {
+		return 0, fmt.Errorf("error setting deadline of ntp request: %w", err)
+	}
+
+	// a version 4 client request, with the transmit time echoed back by the server as the originate time
+	request := make([]byte, 48)
+	request[0] = 4<<3 | 3
+	sent := time.Now()
+	binary.BigEndian.PutUint64(request[40:], toNTPTime(sent))
+	_, err = conn.Write(request)
+	if err != nil {
+		return 0, fmt.Errorf("error sending ntp request to %s: %w", server, err)
+	}
+	response := make([]byte, 48)
+	n, err := conn.Read(response)
+	received := time.Now()
+	if err != nil {
+		return 0, fmt.Errorf("no reply from ntp server %s: %w", server, err)
+	}
+	if n < 48 || response[0]&7 != 4 {
+		return 0, errors.New("ntp server " + server + " sent an invalid reply")
+	}
+	if response[1] == 0 {
+		return 0, errors.New("ntp server " + server + " refused the request")
+	}
+	if binary.BigEndian.Uint64(response[24:]) != toNTPTime(sent) {
+		return 0, errors.New("ntp server " + server + " replied to a different request")
+	}
+
+	serverReceived := fromNTPTime(binary.BigEndian.Uint64(response[32:]))
+	serverSent := fromNTPTime(binary.BigEndian.Uint64(response[40:]))
+	return (serverReceived.Sub(sent) + serverSent.Sub(received)) / 2, nil
+}
+
+// toNTPTime returns a time as an NTP timestamp, which is seconds since 1900 as a 32.32 fixed point number
+func toNTPTime(t time.Time) uint64 {
+	seconds := uint64(t.Unix() + ntpEpochOffset)
+	fraction := uint64(t.Nanosecond()) << 32 / uint64(time.Second)
+	return seconds<<32 | fraction
+}
+
+// fromNTPTime returns the time of an NTP timestamp
+func fromNTPTime(ts uint64) time.Time {
+	seconds := int64(ts>>32) - ntpEpochOffset
+	nanoseconds := int64((ts & 0xffffffff) * uint64(time.Second) >> 32)
+	return time.Unix(seconds, nanoseconds)
+}
@@ -0,0 +1,227 @@
+// Copyright 2018 Comcast Cable Communications Management, LLC
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//     http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/integrii/flaggy"
+	log "github.com/sirupsen/logrus"
+	apiv1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	utilexec "k8s.io/client-go/util/exec"
+
+	"github.com/Comcast/kuberhealthy/v2/pkg/kubeClient"
+)
+
+// the flags of the cloudaccess check
+var cloudAccessTargets string
+var cloudAccessTimeout = time.Second * 5
+var cloudAccessImage = "busybox:1.31"
+var cloudAccessTolerateAll = true
+
+// cloudAccessParallelism is how many nodes are probed at once
+const cloudAccessParallelism = 10
+
+// instanceMetadataURL is the address of the instance metadata service of AWS, GCP, Azure and OpenStack, which the
+// imds target is short for
+const instanceMetadataURL = "http://169.254.169.254/"
+
+// cloudAccessTarget is a URL that pods must be able to reach, or must not be able to reach
+type cloudAccessTarget struct {
+	URL   string
+	Name  string // names the target in its measurements
+	Allow bool   // whether pods are expected to reach the target
+}
+
+// cloudAccessResult is whether the pod on a node reached a target
+type cloudAccessResult struct {
+	Node      string
+	Target    cloudAccessTarget
+	Reachable bool
+	Reason    string // why the target could not be reached
+}
+
+// cloudAccessSubcommand returns the subcommand of the cloudaccess check
+func cloudAccessSubcommand() *flaggy.Subcommand {
+	s := flaggy.NewSubcommand("cloudaccess")
+	s.Description = "Expect pods on every node to reach, or not reach, the instance metadata service and cloud APIs"
+	s.String(&cloudAccessTargets, "", "targets", "Comma separated targets such as imds=deny,https://sts.amazonaws.com=allow.  imds is the instance metadata service.")
+	s.Duration(&cloudAccessTimeout, "", "timeout", "How long reaching each target from each node can take.")
+	s.String(&cloudAccessImage, "", "image", "The image of the pods that probe the targets.  It must have a busybox wget command.")
+	s.Bool(&cloudAccessTolerateAll, "", "tolerateAll", "Tolerate every taint so that the targets are probed from every node.")
+	return s
+}
+
+// runCloudAccessCheck runs a pod on every node with a daemonset and requests every target from each pod.  It fails
+// if any node can reach a target that should be denied, such as the instance metadata service whose credentials
+// pods should not be able to take, or can not reach a target that should be allowed, such as a cloud API that
+// workloads depend on.  The number of nodes that reached each target is measured.
+func runCloudAccessCheck(ctx context.Context) error {
+	targets, err := parseCloudAccessTargets(cloudAccessTargets)
+	if err != nil {
+		return err
+	}
+	config, err := kubeClient.Config(kubeConfigFile)
+	if err != nil {
+		return fmt.Errorf("error creating kubernetes client config: %w", err)
+	}
+	client, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		return fmt.Errorf("error creating kubernetes client: %w", err)
+	}
+
+	pods, err := createNodePods(ctx, client, "cloudaccess", cloudAccessImage, cloudAccessTolerateAll)
+	if err != nil {
+		return err
+	}
+
+	var results []cloudAccessResult
+	var probeErrs []string
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	limit := make(chan struct{}, cloudAccessParallelism)
+	for _, pod := range pods {
+		for _, t := range targets {
+			wg.Add(1)
+			go func(pod apiv1.Pod, t cloudAccessTarget) {
+				defer wg.Done()
+				limit <- struct{}{}
+				defer func() { <-limit }()
+				result, err := probeCloudAccess(ctx, client, config, pod, t)
+				mu.Lock()
+				defer mu.Unlock()
+				if err != nil {
+					probeErrs = append(probeErrs, "error probing "+t.URL+" from node "+pod.Spec.NodeName+": "+err.Error())
+					return
+				}
+				results = append(results, result)
+			}(pod, t)
+		}
+	}
+	wg.Wait()
+
+	errs := append(probeErrs, cloudAccessErrors(targets, results)...)
+	if len(errs) > 0 {
+		sort.Strings(errs)
+		return errors.New(strings.Join(errs, ", "))
+	}
+	log.Infoln("Pods on all", len(pods), "nodes reached the allowed targets and none of the denied targets")
+	return nil
+}
+
+// parseCloudAccessTargets parses a comma separated list of URLs that are each followed by =allow or =deny
+func parseCloudAccessTargets(s string) ([]cloudAccessTarget, error) {
+	var targets []cloudAccessTarget
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if len(part) == 0 {
+			continue
+		}
+		i := strings.LastIndex(part, "=")
+		if i < 0 {
+			return nil, errors.New("target " + part + " must end with =allow or =deny")
+		}
+		t := cloudAccessTarget{URL: part[:i]}
+		switch part[i+1:] {
+		case "allow":
+			t.Allow = true
+		case "deny":
+		default:
+			return nil, errors.New("target " + part + " must end with =allow or =deny")
+		}
+		if t.URL == "imds" {
+			t.URL = instanceMetadataURL
+			t.Name = "imds"
+		}
+		u, err := url.Parse(t.URL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || len(u.Host) == 0 {
+			return nil, errors.New("target " + t.URL + " must be an http or https URL")
+		}
+		if len(t.Name) == 0 {
+			t.Name = strings.Trim(measurementNamePattern.ReplaceAllString(u.Host, "_"), "_")
+		}
+		targets = append(targets, t)
+	}
+	if len(targets) == 0 {
+		return nil, errors.New("--targets is required")
+	}
+	return targets, nil
+}
+
+// probeCloudAccess requests a target with wget in a pod.  A target is reachable when it responds at all, even
+// with an error status such as the 401 of an instance metadata service that requires a token.
+func probeCloudAccess(ctx context.Context, client kubernetes.Interface, config *rest.Config, pod apiv1.Pod, t cloudAccessTarget) (cloudAccessResult, error) {
+	result := cloudAccessResult{Node: pod.Spec.NodeName, Target: t}
+	if ctx.Err() != nil {
+		return result, ctx.Err()
+	}
+	seconds := int(cloudAccessTimeout.Seconds())
+	if seconds < 1 {
+		seconds = 1
+	}
+	_, err := execInPod(client, config, pod, "wget", "-q", "-T", strconv.Itoa(seconds), "-O", "/dev/null", t.URL)
+	result.Reachable, result.Reason, err = classifyWget(err)
+	return result, err
+}
+
+// classifyWget returns whether the wget run by a probe reached its target, or why it did not.  Errors that are
+// not wget exiting with a failure, such as failing to exec into the pod, are returned.
+func classifyWget(err error) (bool, string, error) {
+	if err == nil {
+		return true, "", nil
+	}
+	var exitErr utilexec.CodeExitError
+	if !errors.As(err, &exitErr) {
+		return false, "", err
+	}
+	message := err.Error()
+	if strings.Contains(message, "server returned error") {
+		return true, "", nil
+	}
+	if i := strings.LastIndex(message, "wget: "); i >= 0 {
+		message = message[i+len("wget: "):]
+	}
+	return false, message, nil
+}
+
+// cloudAccessErrors returns an error for each node that reached a denied target or did not reach an allowed one,
+// and measures how many nodes reached each target
+func cloudAccessErrors(targets []cloudAccessTarget, results []cloudAccessResult) []string {
+	reached := make(map[string]int)
+	var errs []string
+	for _, r := range results {
+		if r.Reachable {
+			reached[r.Target.URL]++
+		}
+		switch {
+		case r.Reachable && !r.Target.Allow:
+			errs = append(errs, "node "+r.Node+" can reach "+r.Target.URL+", which should be denied")
+		case !r.Reachable && r.Target.Allow:
+			errs = append(errs, "node "+r.Node+" can not reach "+r.Target.URL+": "+r.Reason)
+		}
+	}
+	for _, t := range targets {
+		measure(t.Name+"_reachable_nodes", float64(reached[t.URL]))
+	}
+	sort.Strings(errs)
+	return errs
+}
@@ -0,0 +1,171 @@
+// Copyright 2018 Comcast Cable Communications Management, LLC
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//     http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/integrii/flaggy"
+	log "github.com/sirupsen/logrus"
+	batchv1 "k8s.io/api/batch/v1"
+	batchv1beta1 "k8s.io/api/batch/v1beta1"
+	apiv1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// the flags of the cronjob check
+var cronJobRuns = 2
+var cronJobMaxDelay = time.Second * 30
+var cronJobImage = "busybox:1.31"
+
+// cronJobSubcommand returns the subcommand of the cronjob check
+func cronJobSubcommand() *flaggy.Subcommand {
+	s := flaggy.NewSubcommand("cronjob")
+	s.Description = "Create a cronjob that runs every minute and expect its jobs to be created on schedule and complete"
+	s.Int(&cronJobRuns, "", "runs", "How many scheduled jobs must complete.")
+	s.Duration(&cronJobMaxDelay, "", "maxDelay", "How long after its scheduled time each job can be created.")
+	s.String(&cronJobImage, "", "image", "The image of the jobs.  It must have a true command.")
+	return s
+}
+
+// runCronJobCheck creates a cronjob that runs every minute and waits for its jobs to be created on schedule and
+// complete.  A stalled cronjob controller creates no jobs and raises no errors, so the check is the only way it is
+// noticed.  The longest delay between the scheduled time of a job and its creation, and the longest time a job
+// took to complete, are measured.
+func runCronJobCheck(ctx context.Context) error {
+	if cronJobRuns < 1 {
+		return errors.New("--runs must be at least 1")
+	}
+	client, err := newKubeClient()
+	if err != nil {
+		return fmt.Errorf("error creating kubernetes client: %w", err)
+	}
+
+	name := resourceName("khcheck-cronjob")
+	labels := map[string]string{checkLabel: "cronjob", "app": name}
+	var backoffLimit int32
+	var historyLimit = int32(cronJobRuns)
+	cronJob := &batchv1beta1.CronJob{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Labels: labels},
+		Spec: batchv1beta1.CronJobSpec{
+			Schedule:                   "* * * * *",
+			ConcurrencyPolicy:          batchv1beta1.ForbidConcurrent,
+			SuccessfulJobsHistoryLimit: &historyLimit,
+			FailedJobsHistoryLimit:     &historyLimit,
+			JobTemplate: batchv1beta1.JobTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{Labels: labels},
+				Spec: batchv1.JobSpec{
+					BackoffLimit: &backoffLimit,
+					Template: apiv1.PodTemplateSpec{
+						ObjectMeta: metav1.ObjectMeta{Labels: labels},
+						Spec: apiv1.PodSpec{
+							Containers:    []apiv1.Container{{Name: "main", Image: cronJobImage, Command: []string{"true"}}},
+							RestartPolicy: apiv1.RestartPolicyNever,
+						},
+					},
+				},
+			},
+		},
+	}
+	cronJobs := client.BatchV1beta1().CronJobs(namespace)
+	_, err = cronJobs.Create(cronJob)
+	if err != nil {
+		return fmt.Errorf("error creating cronjob %s: %w", name, err)
+	}
+	log.Infoln("Created cronjob", name, "in", namespace)
+	afterReport(func() {
+		deleteAndWait("cronjob", name, func() error {
+			return cronJobs.Delete(name, foregroundDelete())
+		}, func() error {
+			_, err := cronJobs.Get(name, metav1.GetOptions{})
+			return err
+		})
+	})
+
+	var completed int
+	var errs []string
+	err = poll(ctx, func() (bool, error) {
+		jobs, err := client.BatchV1().Jobs(namespace).List(metav1.ListOptions{LabelSelector: "app=" + name})
+		if err != nil {
+			return false, err
+		}
+		completed, errs = cronJobProgress(name, jobs.Items)
+		return len(errs) > 0 || completed >= cronJobRuns, nil
+	})
+	if len(errs) > 0 {
+		return errors.New(strings.Join(errs, ", "))
+	}
+	if err != nil {
+		return fmt.Errorf("only %d of %d jobs of cronjob %s completed: %w", completed, cronJobRuns, name, err)
+	}
+	log.Infoln(completed, "jobs of cronjob", name, "were created on schedule and completed")
+	return nil
+}
+
+// cronJobProgress returns how many jobs of a cronjob completed, and an error for each job that failed or was
+// created later than the maximum delay after its scheduled time
+func cronJobProgress(cronJobName string, jobs []batchv1.Job) (int, []string) {
+	var completed int
+	var errs []string
+	var longestDelay, longestDuration time.Duration
+	for _, j := range jobs {
+		if scheduled, ok := cronJobScheduledTime(cronJobName, j.Name); ok {
+			delay := j.CreationTimestamp.Sub(scheduled)
+			if delay > longestDelay {
+				longestDelay = delay
+			}
+			if delay > cronJobMaxDelay {
+				errs = append(errs, fmt.Sprintf("job %s was created %s after its scheduled time", j.Name, delay.Round(time.Second)))
+			}
+		}
+		for _, c := range j.Status.Conditions {
+			if c.Status != apiv1.ConditionTrue {
+				continue
+			}
+			switch c.Type {
+			case batchv1.JobFailed:
+				errs = append(errs, "job "+j.Name+" failed: "+c.Reason+": "+c.Message)
+			case batchv1.JobComplete:
+				completed++
+				if j.Status.StartTime != nil && j.Status.CompletionTime != nil {
+					duration := j.Status.CompletionTime.Sub(j.Status.StartTime.Time)
+					if duration > longestDuration {
+						longestDuration = duration
+					}
+				}
+			}
+		}
+	}
+	measure("job_delay_ms", float64(longestDelay.Milliseconds()))
+	measure("job_duration_ms", float64(longestDuration.Milliseconds()))
+	sort.Strings(errs)
+	return completed, errs
+}
+
+// cronJobScheduledTime returns the time a job of a cronjob was scheduled for.  The cronjob controller names each
+// job after its cronjob and the minutes since the unix epoch of its scheduled time.
+func cronJobScheduledTime(cronJobName string, jobName string) (time.Time, bool) {
+	if !strings.HasPrefix(jobName, cronJobName+"-") {
+		return time.Time{}, false
+	}
+	minutes, err := strconv.ParseInt(strings.TrimPrefix(jobName, cronJobName+"-"), 10, 64)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return time.Unix(minutes*60, 0), true
+}
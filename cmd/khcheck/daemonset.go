@@ -0,0 +1,93 @@
+// Copyright 2018 Comcast Cable Communications Management, LLC
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//     http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/integrii/flaggy"
+	log "github.com/sirupsen/logrus"
+	appsv1 "k8s.io/api/apps/v1"
+	apiv1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// the flags of the daemonset check
+var daemonSetImage = "gcr.io/google-containers/pause:3.1"
+var daemonSetTolerateAll = true
+
+// daemonSetSubcommand returns the subcommand of the daemonset check
+func daemonSetSubcommand() *flaggy.Subcommand {
+	s := flaggy.NewSubcommand("daemonset")
+	s.Description = "Create a daemonset and expect a ready pod on every node it is scheduled to"
+	s.String(&daemonSetImage, "", "image", "The image of the daemonset's pods.")
+	s.Bool(&daemonSetTolerateAll, "", "tolerateAll", "Tolerate every taint so that a pod is scheduled to every node.")
+	return s
+}
+
+// runDaemonSetCheck creates a daemonset, waits for a ready pod on every node it is scheduled to, and deletes it
+func runDaemonSetCheck(ctx context.Context) error {
+	client, err := newKubeClient()
+	if err != nil {
+		return fmt.Errorf("error creating kubernetes client: %w", err)
+	}
+
+	name := resourceName("khcheck-daemonset")
+	labels := map[string]string{checkLabel: "daemonset", "app": name}
+	var tolerations []apiv1.Toleration
+	if daemonSetTolerateAll {
+		tolerations = []apiv1.Toleration{{Operator: apiv1.TolerationOpExists}}
+	}
+	daemonSet := &appsv1.DaemonSet{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Labels: labels},
+		Spec: appsv1.DaemonSetSpec{
+			Selector: &metav1.LabelSelector{MatchLabels: labels},
+			Template: apiv1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{Labels: labels},
+				Spec: apiv1.PodSpec{
+					Containers:  []apiv1.Container{{Name: "main", Image: daemonSetImage}},
+					Tolerations: tolerations,
+				},
+			},
+		},
+	}
+	daemonSets := client.AppsV1().DaemonSets(namespace)
+	_, err = daemonSets.Create(daemonSet)
+	if err != nil {
+		return fmt.Errorf("error creating daemonset %s: %w", name, err)
+	}
+	log.Infoln("Created daemonset", name, "in", namespace)
+	afterReport(func() {
+		deleteAndWait("daemonset", name, func() error {
+			return daemonSets.Delete(name, foregroundDelete())
+		}, func() error {
+			_, err := daemonSets.Get(name, metav1.GetOptions{})
+			return err
+		})
+	})
+
+	var status appsv1.DaemonSetStatus
+	err = poll(ctx, func() (bool, error) {
+		d, err := daemonSets.Get(name, metav1.GetOptions{})
+		if err != nil {
+			return false, err
+		}
+		status = d.Status
+		return status.DesiredNumberScheduled > 0 && status.NumberReady == status.DesiredNumberScheduled, nil
+	})
+	if err != nil {
+		return fmt.Errorf("daemonset %s had %d of %d pods ready: %w", name, status.NumberReady, status.DesiredNumberScheduled, err)
+	}
+	log.Infoln("Pods of daemonset", name, "are ready on all", status.NumberReady, "nodes")
+	return nil
+}
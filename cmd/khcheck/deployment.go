@@ -0,0 +1,137 @@
+// Copyright 2018 Comcast Cable Communications Management, LLC
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//     http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/integrii/flaggy"
+	log "github.com/sirupsen/logrus"
+	appsv1 "k8s.io/api/apps/v1"
+	apiv1 "k8s.io/api/core/v1"
+	k8sErrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+// pollInterval is how often the checks that create resources look at them while waiting
+const pollInterval = time.Second * 2
+
+// checkLabel is set on every resource created by the library so that leftovers can be found
+const checkLabel = "kuberhealthy-khcheck"
+
+// the flags of the deployment check
+var deploymentImage = "nginxinc/nginx-unprivileged:1.17.8"
+var deploymentReplicas = 2
+
+// deploymentSubcommand returns the subcommand of the deployment check
+func deploymentSubcommand() *flaggy.Subcommand {
+	s := flaggy.NewSubcommand("deployment")
+	s.Description = "Create a deployment and expect all of its replicas to become available"
+	s.String(&deploymentImage, "", "image", "The image of the deployment's pods.")
+	s.Int(&deploymentReplicas, "", "replicas", "The number of replicas of the deployment.")
+	return s
+}
+
+// runDeploymentCheck creates a deployment, waits for all of its replicas to become available, and deletes it
+func runDeploymentCheck(ctx context.Context) error {
+	client, err := newKubeClient()
+	if err != nil {
+		return fmt.Errorf("error creating kubernetes client: %w", err)
+	}
+
+	name := resourceName("khcheck-deployment")
+	labels := map[string]string{checkLabel: "deployment", "app": name}
+	replicas := int32(deploymentReplicas)
+	deployment := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Labels: labels},
+		Spec: appsv1.DeploymentSpec{
+			Replicas: &replicas,
+			Selector: &metav1.LabelSelector{MatchLabels: labels},
+			Template: apiv1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{Labels: labels},
+				Spec: apiv1.PodSpec{
+					Containers: []apiv1.Container{{Name: "main", Image: deploymentImage}},
+				},
+			},
+		},
+	}
+	deployments := client.AppsV1().Deployments(namespace)
+	_, err = deployments.Create(deployment)
+	if err != nil {
+		return fmt.Errorf("error creating deployment %s: %w", name, err)
+	}
+	log.Infoln("Created deployment", name, "in", namespace)
+	afterReport(func() {
+		deleteAndWait("deployment", name, func() error {
+			return deployments.Delete(name, foregroundDelete())
+		}, func() error {
+			_, err := deployments.Get(name, metav1.GetOptions{})
+			return err
+		})
+	})
+
+	err = poll(ctx, func() (bool, error) {
+		d, err := deployments.Get(name, metav1.GetOptions{})
+		if err != nil {
+			return false, err
+		}
+		return d.Status.AvailableReplicas == replicas, nil
+	})
+	if err != nil {
+		return fmt.Errorf("deployment %s did not have %d available replicas: %w", name, replicas, err)
+	}
+	log.Infoln("All", replicas, "replicas of deployment", name, "are available")
+	return nil
+}
+
+// poll calls condition until it returns true or an error, or the context ends
+func poll(ctx context.Context, condition func() (bool, error)) error {
+	err := wait.PollImmediateUntil(pollInterval, condition, ctx.Done())
+	if err == wait.ErrWaitTimeout {
+		return ctx.Err()
+	}
+	return err
+}
+
+// foregroundDelete returns delete options that also delete the dependents of a resource before it is gone
+func foregroundDelete() *metav1.DeleteOptions {
+	propagation := metav1.DeletePropagationForeground
+	return &metav1.DeleteOptions{PropagationPolicy: &propagation}
+}
+
+// deleteAndWait deletes a resource created by a check and waits for it to be gone, so that the next run does not
+// start while the resources of this one are still terminating.  Failures are logged, since the result of the
+// check is already decided.
+func deleteAndWait(kind string, name string, remove func() error, get func() error) {
+	err := remove()
+	if err != nil && !k8sErrors.IsNotFound(err) {
+		log.Errorln("Failed to delete", kind, name+":", err)
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	err = poll(ctx, func() (bool, error) {
+		err := get()
+		if k8sErrors.IsNotFound(err) {
+			return true, nil
+		}
+		return false, err
+	})
+	if err != nil {
+		log.Errorln("Failed to wait for", kind, name, "to be deleted:", err)
+		return
+	}
+	log.Infoln("Deleted", kind, name)
+}
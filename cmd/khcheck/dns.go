@@ -0,0 +1,71 @@
+// Copyright 2018 Comcast Cable Communications Management, LLC
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//     http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/integrii/flaggy"
+	log "github.com/sirupsen/logrus"
+)
+
+// the flags of the dns check
+var dnsHosts = "kubernetes.default.svc.cluster.local"
+var dnsServer string
+
+// dnsSubcommand returns the subcommand of the dns check
+func dnsSubcommand() *flaggy.Subcommand {
+	s := flaggy.NewSubcommand("dns")
+	s.Description = "Resolve host names and expect at least one address for each"
+	s.String(&dnsHosts, "", "hosts", "Comma separated host names that are resolved.")
+	s.String(&dnsServer, "", "server", "The host:port of a DNS server to query instead of the one in /etc/resolv.conf.")
+	return s
+}
+
+// runDNSCheck resolves every host name and fails if any of them has no addresses
+func runDNSCheck(ctx context.Context) error {
+	resolver := net.DefaultResolver
+	if len(dnsServer) > 0 {
+		resolver = &net.Resolver{
+			PreferGo: true,
+			Dial: func(ctx context.Context, network string, address string) (net.Conn, error) {
+				var d net.Dialer
+				return d.DialContext(ctx, network, dnsServer)
+			},
+		}
+	}
+
+	var errs []string
+	for _, host := range strings.Split(dnsHosts, ",") {
+		host = strings.TrimSpace(host)
+		if len(host) == 0 {
+			continue
+		}
+		addresses, err := resolver.LookupHost(ctx, host)
+		if err != nil {
+			errs = append(errs, err.Error())
+			continue
+		}
+		if len(addresses) == 0 {
+			errs = append(errs, "no addresses found for "+host)
+			continue
+		}
+		log.Infoln("Resolved", host, "to", addresses)
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("failed to resolve hosts: %s", strings.Join(errs, ", "))
+	}
+	return nil
+}
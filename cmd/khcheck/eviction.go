@@ -0,0 +1,195 @@
+// Copyright 2018 Comcast Cable Communications Management, LLC
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//     http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/integrii/flaggy"
+	log "github.com/sirupsen/logrus"
+	appsv1 "k8s.io/api/apps/v1"
+	apiv1 "k8s.io/api/core/v1"
+	policyv1beta1 "k8s.io/api/policy/v1beta1"
+	k8sErrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/client-go/kubernetes"
+)
+
+// the flags of the eviction check
+var evictionImage = "gcr.io/google-containers/pause:3.1"
+var evictionReplicas = 2
+
+// evictionSubcommand returns the subcommand of the eviction check
+func evictionSubcommand() *flaggy.Subcommand {
+	s := flaggy.NewSubcommand("eviction")
+	s.Description = "Create a deployment protected by a disruption budget and expect the eviction API to honor the budget"
+	s.String(&evictionImage, "", "image", "The image of the deployment's pods.")
+	s.Int(&evictionReplicas, "", "replicas", "The number of replicas of the deployment, of which the disruption budget allows one to be evicted.")
+	return s
+}
+
+// runEvictionCheck creates a deployment and a pod disruption budget that allows one of its pods to be disrupted.
+// It evicts one pod, which must be allowed, then another, which the budget must refuse, and waits for the
+// deployment to replace the evicted pod.  Node drains and cluster upgrades rely on this path, so a budget that is
+// not enforced or an eviction API that refuses everything is found before a drain needs it.  How long the allowed
+// eviction took and how long the deployment took to recover are measured.
+func runEvictionCheck(ctx context.Context) error {
+	if evictionReplicas < 2 {
+		return errors.New("--replicas must be at least 2")
+	}
+	client, err := newKubeClient()
+	if err != nil {
+		return fmt.Errorf("error creating kubernetes client: %w", err)
+	}
+
+	name := resourceName("khcheck-eviction")
+	labels := map[string]string{checkLabel: "eviction", "app": name}
+	replicas := int32(evictionReplicas)
+	deployment := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Labels: labels},
+		Spec: appsv1.DeploymentSpec{
+			Replicas: &replicas,
+			Selector: &metav1.LabelSelector{MatchLabels: labels},
+			Template: apiv1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{Labels: labels},
+				Spec: apiv1.PodSpec{
+					Containers: []apiv1.Container{{Name: "main", Image: evictionImage}},
+				},
+			},
+		},
+	}
+	deployments := client.AppsV1().Deployments(namespace)
+	_, err = deployments.Create(deployment)
+	if err != nil {
+		return fmt.Errorf("error creating deployment %s: %w", name, err)
+	}
+	afterReport(func() {
+		deleteAndWait("deployment", name, func() error {
+			return deployments.Delete(name, foregroundDelete())
+		}, func() error {
+			_, err := deployments.Get(name, metav1.GetOptions{})
+			return err
+		})
+	})
+
+	minAvailable := intstr.FromInt(evictionReplicas - 1)
+	pdb := &policyv1beta1.PodDisruptionBudget{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Labels: labels},
+		Spec: policyv1beta1.PodDisruptionBudgetSpec{
+			MinAvailable: &minAvailable,
+			Selector:     &metav1.LabelSelector{MatchLabels: labels},
+		},
+	}
+	pdbs := client.PolicyV1beta1().PodDisruptionBudgets(namespace)
+	_, err = pdbs.Create(pdb)
+	if err != nil {
+		return fmt.Errorf("error creating pod disruption budget %s: %w", name, err)
+	}
+	log.Infoln("Created deployment and pod disruption budget", name, "in", namespace)
+	afterReport(func() {
+		deleteAndWait("pod disruption budget", name, func() error {
+			return pdbs.Delete(name, &metav1.DeleteOptions{})
+		}, func() error {
+			_, err := pdbs.Get(name, metav1.GetOptions{})
+			return err
+		})
+	})
+
+	// waits for every replica to be available and for the budget to allow exactly one disruption
+	waitForBudget := func() error {
+		return poll(ctx, func() (bool, error) {
+			d, err := deployments.Get(name, metav1.GetOptions{})
+			if err != nil {
+				return false, err
+			}
+			p, err := pdbs.Get(name, metav1.GetOptions{})
+			if err != nil {
+				return false, err
+			}
+			return d.Status.AvailableReplicas == replicas && pdbAllowsOneDisruption(p, replicas), nil
+		})
+	}
+	err = waitForBudget()
+	if err != nil {
+		return fmt.Errorf("pod disruption budget %s did not allow one disruption of %d available replicas: %w", name, replicas, err)
+	}
+
+	pods, err := client.CoreV1().Pods(namespace).List(metav1.ListOptions{LabelSelector: "app=" + name})
+	if err != nil {
+		return fmt.Errorf("error listing the pods of deployment %s: %w", name, err)
+	}
+	var running []apiv1.Pod
+	for _, p := range pods.Items {
+		if p.Status.Phase == apiv1.PodRunning && p.DeletionTimestamp == nil {
+			running = append(running, p)
+		}
+	}
+	if len(running) < 2 {
+		return fmt.Errorf("deployment %s had %d running pods to evict", name, len(running))
+	}
+
+	started := time.Now()
+	err = evictionOutcome(running[0].Name, true, evictPod(client, running[0]))
+	if err != nil {
+		return err
+	}
+	measure("eviction_ms", float64(time.Since(started).Milliseconds()))
+	log.Infoln("Evicted pod", running[0].Name, "within the disruption budget")
+
+	err = evictionOutcome(running[1].Name, false, evictPod(client, running[1]))
+	if err != nil {
+		return err
+	}
+	log.Infoln("The disruption budget refused the eviction of pod", running[1].Name)
+
+	err = waitForBudget()
+	if err != nil {
+		return fmt.Errorf("deployment %s did not recover from the eviction of pod %s: %w", name, running[0].Name, err)
+	}
+	measure("recovery_ms", float64(time.Since(started).Milliseconds()))
+	log.Infoln("Deployment", name, "replaced the evicted pod after", time.Since(started).Round(time.Second))
+	return nil
+}
+
+// evictPod requests the eviction of a pod through the eviction API
+func evictPod(client kubernetes.Interface, pod apiv1.Pod) error {
+	return client.CoreV1().Pods(pod.Namespace).Evict(&policyv1beta1.Eviction{
+		ObjectMeta: metav1.ObjectMeta{Name: pod.Name, Namespace: pod.Namespace},
+	})
+}
+
+// pdbAllowsOneDisruption returns true once the disruption budget controller has observed the latest budget, counts
+// every replica as healthy, and allows exactly one of them to be disrupted
+func pdbAllowsOneDisruption(pdb *policyv1beta1.PodDisruptionBudget, replicas int32) bool {
+	return pdb.Status.ObservedGeneration >= pdb.Generation &&
+		pdb.Status.CurrentHealthy == replicas &&
+		pdb.Status.PodDisruptionsAllowed == 1
+}
+
+// evictionOutcome returns an error if the eviction of a pod was not allowed when it should have been, or was not
+// refused by the disruption budget when it should have been.  The eviction API refuses evictions that would break
+// a budget with a 429 Too Many Requests.
+func evictionOutcome(podName string, allowed bool, err error) error {
+	switch {
+	case allowed && err != nil:
+		return fmt.Errorf("error evicting pod %s within its disruption budget: %w", podName, err)
+	case !allowed && err == nil:
+		return errors.New("pod " + podName + " was evicted although its disruption budget allowed no more disruptions")
+	case !allowed && !k8sErrors.IsTooManyRequests(err):
+		return fmt.Errorf("expected the eviction of pod %s to be refused by its disruption budget but got: %w", podName, err)
+	}
+	return nil
+}
@@ -0,0 +1,288 @@
+// Copyright 2018 Comcast Cable Communications Management, LLC
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//     http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/integrii/flaggy"
+	log "github.com/sirupsen/logrus"
+	apiv1 "k8s.io/api/core/v1"
+	k8sErrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// the flags of the gitops check
+var gitOpsKindsList = "applications,kustomizations,helmreleases"
+var gitOpsNamespaces string
+var gitOpsThreshold = time.Minute * 15
+var gitOpsIgnoreSuspended = true
+var gitOpsStateConfigMap = "khcheck-gitops"
+
+// gitOpsKinds are the API group of each kind of GitOps resource the gitops check reads
+var gitOpsKinds = map[string]string{
+	"applications":   "argoproj.io",
+	"kustomizations": "kustomize.toolkit.fluxcd.io",
+	"helmreleases":   "helm.toolkit.fluxcd.io",
+}
+
+// gitOpsProblem is a GitOps resource that is out of sync or unhealthy
+type gitOpsProblem struct {
+	Kind      string
+	Namespace string
+	Name      string
+	Problem   string    // what is wrong with the resource
+	Since     time.Time // when the resource last changed state, when the resource records it
+}
+
+// key returns the key the time a problem was first seen is remembered by
+func (p gitOpsProblem) key() string {
+	return p.Kind + "." + p.Namespace + "." + p.Name
+}
+
+// argoApplicationList is the part of a list of Argo CD Applications that the gitops check reads
+type argoApplicationList struct {
+	Items []struct {
+		Metadata metav1.ObjectMeta `json:"metadata"`
+		Status   struct {
+			Sync struct {
+				Status string `json:"status"`
+			} `json:"sync"`
+			Health struct {
+				Status  string `json:"status"`
+				Message string `json:"message"`
+			} `json:"health"`
+		} `json:"status"`
+	} `json:"items"`
+}
+
+// fluxResourceList is the part of a list of Flux Kustomizations or HelmReleases that the gitops check reads
+type fluxResourceList struct {
+	Items []struct {
+		Metadata metav1.ObjectMeta `json:"metadata"`
+		Spec     struct {
+			Suspend bool `json:"suspend"`
+		} `json:"spec"`
+		Status struct {
+			Conditions []struct {
+				Type               string      `json:"type"`
+				Status             string      `json:"status"`
+				Reason             string      `json:"reason"`
+				Message            string      `json:"message"`
+				LastTransitionTime metav1.Time `json:"lastTransitionTime"`
+			} `json:"conditions"`
+		} `json:"status"`
+	} `json:"items"`
+}
+
+// gitOpsSubcommand returns the subcommand of the gitops check
+func gitOpsSubcommand() *flaggy.Subcommand {
+	s := flaggy.NewSubcommand("gitops")
+	s.Description = "Expect Argo CD Applications and Flux Kustomizations and HelmReleases to be synced and healthy"
+	s.String(&gitOpsKindsList, "", "kinds", "Comma separated kinds of GitOps resources that are checked.  Kinds that are not installed are skipped.")
+	s.String(&gitOpsNamespaces, "", "namespaces", "Comma separated namespaces whose GitOps resources are checked.  Defaults to every namespace.")
+	s.Duration(&gitOpsThreshold, "", "threshold", "How long a resource can be out of sync or unhealthy before the check fails.")
+	s.Bool(&gitOpsIgnoreSuspended, "", "ignoreSuspended", "Skip resources whose reconciliation is suspended.")
+	s.String(&gitOpsStateConfigMap, "", "stateConfigMap", "The config map in the check namespace that remembers when resources were first seen out of sync, for resources that do not record it.")
+	return s
+}
+
+// runGitOpsCheck finds the GitOps resources that are out of sync or unhealthy, and fails if any of them has been
+// for longer than the threshold.  Resources that do not record when they changed state are remembered in the state
+// config map from the run that first saw them out of sync.  The number of unhealthy resources, and how long the
+// longest has been unhealthy, are measured.
+func runGitOpsCheck(ctx context.Context) error {
+	client, err := newKubeClient()
+	if err != nil {
+		return fmt.Errorf("error creating kubernetes client: %w", err)
+	}
+	groups, err := client.Discovery().ServerGroups()
+	if err != nil {
+		return fmt.Errorf("error discovering api groups: %w", err)
+	}
+	preferred := make(map[string]string)
+	for _, g := range groups.Groups {
+		preferred[g.Name] = g.PreferredVersion.GroupVersion
+	}
+
+	var problems []gitOpsProblem
+	var checked int
+	for _, kind := range strings.Split(gitOpsKindsList, ",") {
+		kind = strings.TrimSpace(kind)
+		group, ok := gitOpsKinds[kind]
+		if !ok {
+			return errors.New("unknown kind " + kind + " in --kinds")
+		}
+		groupVersion, ok := preferred[group]
+		if !ok {
+			log.Infoln("Skipping", kind, "because", group, "is not installed")
+			continue
+		}
+		checked++
+		found, err := listGitOpsProblems(ctx, client, kind, groupVersion)
+		if err != nil {
+			return err
+		}
+		problems = append(problems, found...)
+	}
+	if checked == 0 {
+		return errors.New("none of the GitOps kinds " + gitOpsKindsList + " are installed")
+	}
+
+	configMaps := client.CoreV1().ConfigMaps(namespace)
+	state, err := configMaps.Get(gitOpsStateConfigMap, metav1.GetOptions{})
+	if k8sErrors.IsNotFound(err) {
+		state = &apiv1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: gitOpsStateConfigMap, Labels: map[string]string{checkLabel: "gitops"}}}
+		state, err = configMaps.Create(state)
+	}
+	if err != nil {
+		return fmt.Errorf("error getting config map %s: %w", gitOpsStateConfigMap, err)
+	}
+	errs, firstSeen := overdueGitOpsProblems(problems, state.Data, time.Now())
+	state.Data = firstSeen
+	_, err = configMaps.Update(state)
+	if err != nil {
+		return fmt.Errorf("error updating config map %s: %w", gitOpsStateConfigMap, err)
+	}
+
+	if len(errs) > 0 {
+		return errors.New(strings.Join(errs, ", "))
+	}
+	log.Infoln("All GitOps resources are synced and healthy, or have not been for longer than", gitOpsThreshold)
+	return nil
+}
+
+// listGitOpsProblems lists the resources of a kind in the checked namespaces and returns those that are out of
+// sync or unhealthy
+func listGitOpsProblems(ctx context.Context, client kubernetes.Interface, kind string, groupVersion string) ([]gitOpsProblem, error) {
+	namespaces := []string{""}
+	if len(strings.TrimSpace(gitOpsNamespaces)) > 0 {
+		namespaces = strings.Split(gitOpsNamespaces, ",")
+	}
+
+	var problems []gitOpsProblem
+	for _, ns := range namespaces {
+		path := "/apis/" + groupVersion + "/" + kind
+		if ns = strings.TrimSpace(ns); len(ns) > 0 {
+			path = "/apis/" + groupVersion + "/namespaces/" + ns + "/" + kind
+		}
+		body, err := client.Discovery().RESTClient().Get().AbsPath(path).Context(ctx).DoRaw()
+		if err != nil {
+			return nil, fmt.Errorf("error listing %s: %w", path, err)
+		}
+		if kind == "applications" {
+			var list argoApplicationList
+			err = json.Unmarshal(body, &list)
+			problems = append(problems, argoApplicationProblems(list)...)
+		} else {
+			var list fluxResourceList
+			err = json.Unmarshal(body, &list)
+			problems = append(problems, fluxResourceProblems(kind, list)...)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error parsing %s: %w", path, err)
+		}
+	}
+	return problems, nil
+}
+
+// argoApplicationProblems returns the applications that are not synced or not healthy.  Applications do not record
+// when their sync or health status changed.
+func argoApplicationProblems(list argoApplicationList) []gitOpsProblem {
+	var problems []gitOpsProblem
+	for _, a := range list.Items {
+		var issues []string
+		if a.Status.Sync.Status != "Synced" {
+			issues = append(issues, "is "+a.Status.Sync.Status)
+		}
+		health := a.Status.Health.Status
+		if health != "Healthy" && !(health == "Suspended" && gitOpsIgnoreSuspended) {
+			issue := "is " + health
+			if len(a.Status.Health.Message) > 0 {
+				issue += ": " + a.Status.Health.Message
+			}
+			issues = append(issues, issue)
+		}
+		if len(issues) > 0 {
+			problems = append(problems, gitOpsProblem{
+				Kind:      "applications",
+				Namespace: a.Metadata.Namespace,
+				Name:      a.Metadata.Name,
+				Problem:   strings.Join(issues, " and "),
+			})
+		}
+	}
+	return problems
+}
+
+// fluxResourceProblems returns the Kustomizations or HelmReleases whose Ready condition is not true, since the time
+// the condition last changed
+func fluxResourceProblems(kind string, list fluxResourceList) []gitOpsProblem {
+	var problems []gitOpsProblem
+	for _, r := range list.Items {
+		if r.Spec.Suspend && gitOpsIgnoreSuspended {
+			continue
+		}
+		problem := gitOpsProblem{Kind: kind, Namespace: r.Metadata.Namespace, Name: r.Metadata.Name, Problem: "has no Ready condition"}
+		for _, c := range r.Status.Conditions {
+			if c.Type != "Ready" {
+				continue
+			}
+			problem.Problem = "is not ready: " + c.Reason + ": " + c.Message
+			problem.Since = c.LastTransitionTime.Time
+			if c.Status == "True" {
+				problem.Problem = ""
+			}
+		}
+		if len(problem.Problem) > 0 {
+			problems = append(problems, problem)
+		}
+	}
+	return problems
+}
+
+// overdueGitOpsProblems returns an error for each problem that has lasted longer than the threshold, and when each
+// current problem was first seen.  Problems that do not record when they started are timed from when a run first
+// saw them, which is looked up in firstSeen.
+func overdueGitOpsProblems(problems []gitOpsProblem, firstSeen map[string]string, now time.Time) ([]string, map[string]string) {
+	seen := make(map[string]string)
+	var errs []string
+	var longest time.Duration
+	for _, p := range problems {
+		since := p.Since
+		if since.IsZero() {
+			since = now
+			if previous, err := time.Parse(time.RFC3339, firstSeen[p.key()]); err == nil {
+				since = previous
+			}
+			seen[p.key()] = since.Format(time.RFC3339)
+		}
+		lasted := now.Sub(since)
+		if lasted > longest {
+			longest = lasted
+		}
+		if lasted > gitOpsThreshold {
+			errs = append(errs, fmt.Sprintf("%s %s/%s %s for %s", p.Kind, p.Namespace, p.Name, p.Problem, lasted.Round(time.Second)))
+		}
+	}
+	measure("unhealthy_resources", float64(len(problems)))
+	measure("longest_unhealthy_seconds", longest.Seconds())
+	sort.Strings(errs)
+	return errs, seen
+}
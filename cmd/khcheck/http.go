@@ -0,0 +1,69 @@
+// Copyright 2018 Comcast Cable Communications Management, LLC
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//     http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/integrii/flaggy"
+	log "github.com/sirupsen/logrus"
+)
+
+// the flags of the http check
+var httpURL string
+var httpMethod = http.MethodGet
+var httpExpectedStatus = http.StatusOK
+var httpRequestTimeout = time.Second * 10
+
+// httpSubcommand returns the subcommand of the http check
+func httpSubcommand() *flaggy.Subcommand {
+	s := flaggy.NewSubcommand("http")
+	s.Description = "Make a request to a URL and expect a status code"
+	s.String(&httpURL, "", "url", "The http or https URL that is requested.")
+	s.String(&httpMethod, "", "method", "The method of the request.")
+	s.Int(&httpExpectedStatus, "", "expectedStatus", "The status code the response must have.")
+	s.Duration(&httpRequestTimeout, "", "requestTimeout", "How long the request can take.")
+	return s
+}
+
+// runHTTPCheck makes a request to the URL and fails unless the response has the expected status code
+func runHTTPCheck(ctx context.Context) error {
+	if !strings.HasPrefix(httpURL, "http://") && !strings.HasPrefix(httpURL, "https://") {
+		return errors.New("--url must be an http or https URL")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, httpRequestTimeout)
+	defer cancel()
+	req, err := http.NewRequest(httpMethod, httpURL, nil)
+	if err != nil {
+		return fmt.Errorf("error creating %s request to %s: %w", httpMethod, httpURL, err)
+	}
+	resp, err := http.DefaultClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return fmt.Errorf("error making %s request to %s: %w", httpMethod, httpURL, err)
+	}
+	defer resp.Body.Close()
+	io.Copy(ioutil.Discard, resp.Body)
+
+	if resp.StatusCode != httpExpectedStatus {
+		return fmt.Errorf("%s request to %s returned %d instead of %d", httpMethod, httpURL, resp.StatusCode, httpExpectedStatus)
+	}
+	log.Infoln("Got a", resp.StatusCode, "from a", httpMethod, "to", httpURL)
+	return nil
+}
@@ -0,0 +1,272 @@
+// Copyright 2018 Comcast Cable Communications Management, LLC
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//     http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/integrii/flaggy"
+	log "github.com/sirupsen/logrus"
+	appsv1 "k8s.io/api/apps/v1"
+	apiv1 "k8s.io/api/core/v1"
+	networkingv1beta1 "k8s.io/api/networking/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+// the flags of the ingress check
+var ingressHost string
+var ingressClass string
+var ingressAddress string
+var ingressTLS bool
+var ingressTLSSecret string
+var ingressCAFile string
+var ingressImage = "hashicorp/http-echo:0.2.3"
+var ingressRequestTimeout = time.Second * 10
+
+// ingressBackendPort is the port the backend of the ingress check listens on
+const ingressBackendPort = 5678
+
+// maxIngressBody is the most of each response body that is read when looking for the token of the backend
+const maxIngressBody = 64 * 1024
+
+// ingressSubcommand returns the subcommand of the ingress check
+func ingressSubcommand() *flaggy.Subcommand {
+	s := flaggy.NewSubcommand("ingress")
+	s.Description = "Create an ingress and backend and expect requests through the ingress controller to reach the backend"
+	s.String(&ingressHost, "", "host", "The host of the ingress rule, which requests are sent to.  Required.")
+	s.String(&ingressClass, "", "class", "The ingress class of the ingress, for clusters with more than one ingress controller.")
+	s.String(&ingressAddress, "", "address", "The address requests are sent to instead of the address the host resolves to, such as the service of the ingress controller.")
+	s.Bool(&ingressTLS, "", "tls", "Send requests over HTTPS and verify the certificate the ingress controller presents for the host.")
+	s.String(&ingressTLSSecret, "", "tlsSecret", "The secret in the check namespace holding the certificate of the host.  Defaults to the certificate the ingress controller serves by default.")
+	s.String(&ingressCAFile, "", "caFile", "A file holding the CA certificates that the certificate of the host is verified with.  Defaults to the system roots.")
+	s.String(&ingressImage, "", "image", "The image of the backend.  It must respond to every path with the text given by its -text argument.")
+	s.Duration(&ingressRequestTimeout, "", "requestTimeout", "How long each request through the ingress controller can take.")
+	return s
+}
+
+// runIngressCheck creates a backend, a service and an ingress that routes a path unique to the run to the service,
+// and requests the path through the ingress controller until the backend answers.  It fails if the backend is never
+// reached, such as when the controller does not pick up the ingress or terminates TLS with the wrong certificate.
+// The time taken for the ingress to route to the backend, and the latency of the request that reached it, are
+// measured.
+func runIngressCheck(ctx context.Context) error {
+	if len(ingressHost) == 0 {
+		return errors.New("--host is required")
+	}
+	client, err := newKubeClient()
+	if err != nil {
+		return fmt.Errorf("error creating kubernetes client: %w", err)
+	}
+
+	name := resourceName("khcheck-ingress")
+	labels := map[string]string{checkLabel: "ingress", "app": name}
+	replicas := int32(1)
+	deployment := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Labels: labels},
+		Spec: appsv1.DeploymentSpec{
+			Replicas: &replicas,
+			Selector: &metav1.LabelSelector{MatchLabels: labels},
+			Template: apiv1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{Labels: labels},
+				Spec: apiv1.PodSpec{
+					Containers: []apiv1.Container{{
+						Name:  "main",
+						Image: ingressImage,
+						Args:  []string{"-text=" + name, fmt.Sprintf("-listen=:%d", ingressBackendPort)},
+						Ports: []apiv1.ContainerPort{{ContainerPort: ingressBackendPort}},
+					}},
+				},
+			},
+		},
+	}
+	deployments := client.AppsV1().Deployments(namespace)
+	_, err = deployments.Create(deployment)
+	if err != nil {
+		return fmt.Errorf("error creating deployment %s: %w", name, err)
+	}
+	afterReport(func() {
+		deleteAndWait("deployment", name, func() error {
+			return deployments.Delete(name, foregroundDelete())
+		}, func() error {
+			_, err := deployments.Get(name, metav1.GetOptions{})
+			return err
+		})
+	})
+
+	service := &apiv1.Service{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Labels: labels},
+		Spec: apiv1.ServiceSpec{
+			Selector: labels,
+			Ports:    []apiv1.ServicePort{{Port: 80, TargetPort: intstr.FromInt(ingressBackendPort)}},
+		},
+	}
+	services := client.CoreV1().Services(namespace)
+	_, err = services.Create(service)
+	if err != nil {
+		return fmt.Errorf("error creating service %s: %w", name, err)
+	}
+	afterReport(func() {
+		deleteAndWait("service", name, func() error {
+			return services.Delete(name, &metav1.DeleteOptions{})
+		}, func() error {
+			_, err := services.Get(name, metav1.GetOptions{})
+			return err
+		})
+	})
+
+	ingress := &networkingv1beta1.Ingress{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Labels: labels},
+		Spec: networkingv1beta1.IngressSpec{
+			Rules: []networkingv1beta1.IngressRule{{
+				Host: ingressHost,
+				IngressRuleValue: networkingv1beta1.IngressRuleValue{HTTP: &networkingv1beta1.HTTPIngressRuleValue{
+					Paths: []networkingv1beta1.HTTPIngressPath{{
+						Path:    "/" + name,
+						Backend: networkingv1beta1.IngressBackend{ServiceName: name, ServicePort: intstr.FromInt(80)},
+					}},
+				}},
+			}},
+		},
+	}
+	if len(ingressClass) > 0 {
+		ingress.Annotations = map[string]string{"kubernetes.io/ingress.class": ingressClass}
+	}
+	if len(ingressTLSSecret) > 0 {
+		ingress.Spec.TLS = []networkingv1beta1.IngressTLS{{Hosts: []string{ingressHost}, SecretName: ingressTLSSecret}}
+	}
+	ingresses := client.NetworkingV1beta1().Ingresses(namespace)
+	_, err = ingresses.Create(ingress)
+	if err != nil {
+		return fmt.Errorf("error creating ingress %s: %w", name, err)
+	}
+	created := time.Now()
+	log.Infoln("Created deployment, service and ingress", name, "in", namespace)
+	afterReport(func() {
+		deleteAndWait("ingress", name, func() error {
+			return ingresses.Delete(name, &metav1.DeleteOptions{})
+		}, func() error {
+			_, err := ingresses.Get(name, metav1.GetOptions{})
+			return err
+		})
+	})
+
+	err = poll(ctx, func() (bool, error) {
+		d, err := deployments.Get(name, metav1.GetOptions{})
+		if err != nil {
+			return false, err
+		}
+		return d.Status.AvailableReplicas == replicas, nil
+	})
+	if err != nil {
+		return fmt.Errorf("backend deployment %s did not become available: %w", name, err)
+	}
+
+	httpClient, err := ingressHTTPClient()
+	if err != nil {
+		return err
+	}
+	err = requestThroughIngress(ctx, httpClient, "/"+name, name)
+	if err != nil {
+		return err
+	}
+	measure("ingress_ready_ms", float64(time.Since(created).Milliseconds()))
+	log.Infoln("Ingress", name, "routed", ingressHost+"/"+name, "to its backend after", time.Since(created).Round(time.Second))
+	return nil
+}
+
+// ingressHTTPClient returns the client that sends requests through the ingress controller.  Requests are sent to
+// the ingress address when one is set, with the host of the ingress still used for its Host header and for TLS.
+// Connections are not reused so that every request sees the configuration the controller has loaded.
+func ingressHTTPClient() (*http.Client, error) {
+	tlsConfig := &tls.Config{ServerName: ingressHost}
+	if len(ingressCAFile) > 0 {
+		pem, err := ioutil.ReadFile(ingressCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("error reading CA certificates from %s: %w", ingressCAFile, err)
+		}
+		tlsConfig.RootCAs = x509.NewCertPool()
+		if !tlsConfig.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, errors.New(ingressCAFile + " holds no PEM encoded certificates")
+		}
+	}
+	transport := &http.Transport{TLSClientConfig: tlsConfig, DisableKeepAlives: true}
+	if len(ingressAddress) > 0 {
+		address := ingressAddress
+		if _, _, err := net.SplitHostPort(address); err != nil {
+			port := "80"
+			if ingressTLS {
+				port = "443"
+			}
+			address = net.JoinHostPort(address, port)
+		}
+		var d net.Dialer
+		transport.DialContext = func(ctx context.Context, network string, _ string) (net.Conn, error) {
+			return d.DialContext(ctx, network, address)
+		}
+	}
+	return &http.Client{Transport: transport, Timeout: ingressRequestTimeout}, nil
+}
+
+// requestThroughIngress requests a path of the ingress host until the response is from the backend, which is
+// known by the token it responds with.  The controller can take a while to pick up a new ingress, so failed
+// requests are retried until the context ends and the last failure is returned.
+func requestThroughIngress(ctx context.Context, client *http.Client, path string, token string) error {
+	scheme := "http"
+	if ingressTLS {
+		scheme = "https"
+	}
+	url := scheme + "://" + ingressHost + path
+
+	var lastErr error
+	err := poll(ctx, func() (bool, error) {
+		req, err := http.NewRequest(http.MethodGet, url, nil)
+		if err != nil {
+			return false, fmt.Errorf("error creating request to %s: %w", url, err)
+		}
+		start := time.Now()
+		resp, err := client.Do(req.WithContext(ctx))
+		if err != nil {
+			lastErr = err
+			return false, nil
+		}
+		defer resp.Body.Close()
+		body, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxIngressBody))
+		if err != nil {
+			lastErr = fmt.Errorf("error reading response from %s: %w", url, err)
+			return false, nil
+		}
+		if resp.StatusCode != http.StatusOK || !bytes.Contains(body, []byte(token)) {
+			lastErr = fmt.Errorf("%s returned %d without the response of the backend", url, resp.StatusCode)
+			return false, nil
+		}
+		measure("ingress_request_ms", float64(time.Since(start).Milliseconds()))
+		return true, nil
+	})
+	if err != nil && lastErr != nil {
+		return fmt.Errorf("requests to %s did not reach the backend: %w", url, lastErr)
+	}
+	if err != nil {
+		return fmt.Errorf("requests to %s did not reach the backend: %w", url, err)
+	}
+	return nil
+}
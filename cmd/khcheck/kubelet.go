@@ -0,0 +1,197 @@
+// Copyright 2018 Comcast Cable Communications Management, LLC
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//     http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/integrii/flaggy"
+	log "github.com/sirupsen/logrus"
+	apiv1 "k8s.io/api/core/v1"
+	k8sErrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// the flags of the kubelet check
+var kubeletImage = "gcr.io/google-containers/pause:3.1"
+var kubeletNodeSelector string
+var kubeletPodDeadline = time.Minute
+var kubeletMaxHeartbeatAge = time.Minute
+
+// nodeLeaseNamespace is the namespace of the leases that kubelets renew as their heartbeat
+const nodeLeaseNamespace = "kube-node-lease"
+
+// kubeletSubcommand returns the subcommand of the kubelet check
+func kubeletSubcommand() *flaggy.Subcommand {
+	s := flaggy.NewSubcommand("kubelet")
+	s.Description = "Start a pod on every node within a deadline and expect every kubelet to have a recent heartbeat"
+	s.String(&kubeletImage, "", "image", "The image of the pod started on each node.")
+	s.String(&kubeletNodeSelector, "", "nodeSelector", "A label selector of the nodes that are checked.  Defaults to every node.")
+	s.Duration(&kubeletPodDeadline, "", "podDeadline", "How long the kubelet of each node can take to start its pod.")
+	s.Duration(&kubeletMaxHeartbeatAge, "", "maxHeartbeatAge", "How old the heartbeat of each kubelet can be.")
+	return s
+}
+
+// runKubeletCheck checks the heartbeat of the kubelet of every node, then starts a pod on every schedulable node
+// and fails if any kubelet is stale or does not start its pod within the deadline.  The pods are bound to their
+// nodes directly rather than through the scheduler, so that only the kubelet and its container runtime are timed.
+// The heartbeat age of each node and the time each pod took to start are measured.
+func runKubeletCheck(ctx context.Context) error {
+	client, err := newKubeClient()
+	if err != nil {
+		return fmt.Errorf("error creating kubernetes client: %w", err)
+	}
+	nodes, err := client.CoreV1().Nodes().List(metav1.ListOptions{LabelSelector: kubeletNodeSelector})
+	if err != nil {
+		return fmt.Errorf("error listing nodes: %w", err)
+	}
+	if len(nodes.Items) == 0 {
+		return errors.New("no nodes matched the node selector " + kubeletNodeSelector)
+	}
+
+	errs := staleKubelets(client, nodes.Items, time.Now())
+	var schedulable []string
+	for _, n := range nodes.Items {
+		if !n.Spec.Unschedulable {
+			schedulable = append(schedulable, n.Name)
+		}
+	}
+	errs = append(errs, slowKubelets(ctx, client, schedulable)...)
+
+	if len(errs) > 0 {
+		sort.Strings(errs)
+		return errors.New(strings.Join(errs, ", "))
+	}
+	log.Infoln("The kubelets of all", len(nodes.Items), "nodes are healthy")
+	return nil
+}
+
+// staleKubelets returns an error for each node whose kubelet has not sent a heartbeat within the maximum heartbeat
+// age.  The heartbeat is the renewal of the node's lease, or the heartbeat of its Ready condition on clusters
+// without node leases.
+func staleKubelets(client kubernetes.Interface, nodes []apiv1.Node, now time.Time) []string {
+	var errs []string
+	for _, n := range nodes {
+		var heartbeat time.Time
+		lease, err := client.CoordinationV1beta1().Leases(nodeLeaseNamespace).Get(n.Name, metav1.GetOptions{})
+		switch {
+		case err == nil && lease.Spec.RenewTime != nil:
+			heartbeat = lease.Spec.RenewTime.Time
+		case err != nil && !k8sErrors.IsNotFound(err):
+			errs = append(errs, "error getting the lease of node "+n.Name+": "+err.Error())
+			continue
+		default:
+			for _, c := range n.Status.Conditions {
+				if c.Type == apiv1.NodeReady {
+					heartbeat = c.LastHeartbeatTime.Time
+				}
+			}
+		}
+		if heartbeat.IsZero() {
+			errs = append(errs, "node "+n.Name+" has no kubelet heartbeat")
+			continue
+		}
+
+		age := now.Sub(heartbeat)
+		measure(strings.Trim(measurementNamePattern.ReplaceAllString(n.Name, "_"), "_")+"_heartbeat_age_seconds", age.Seconds())
+		if age > kubeletMaxHeartbeatAge {
+			errs = append(errs, fmt.Sprintf("the kubelet of node %s last sent a heartbeat %s ago", n.Name, age.Round(time.Second)))
+		}
+	}
+	return errs
+}
+
+// slowKubelets starts a pod on each node and returns an error for each node that does not start its pod within
+// the pod deadline.  The pods are deleted once the result is reported.
+func slowKubelets(ctx context.Context, client kubernetes.Interface, nodes []string) []string {
+	pods := client.CoreV1().Pods(namespace)
+	run := resourceName("khcheck-kubelet")
+	created := make(map[string]time.Time)
+	var errs []string
+	for _, node := range nodes {
+		pod := &apiv1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				GenerateName: "khcheck-kubelet-",
+				Labels:       map[string]string{checkLabel: "kubelet", "app": run},
+			},
+			Spec: apiv1.PodSpec{
+				NodeName:      node,
+				Containers:    []apiv1.Container{{Name: "main", Image: kubeletImage}},
+				Tolerations:   []apiv1.Toleration{{Operator: apiv1.TolerationOpExists}},
+				RestartPolicy: apiv1.RestartPolicyNever,
+			},
+		}
+		p, err := pods.Create(pod)
+		if err != nil {
+			errs = append(errs, "error creating a pod on node "+node+": "+err.Error())
+			continue
+		}
+		created[node] = time.Now()
+		name := p.Name
+		afterReport(func() {
+			deleteAndWait("pod", name, func() error {
+				return pods.Delete(name, &metav1.DeleteOptions{})
+			}, func() error {
+				_, err := pods.Get(name, metav1.GetOptions{})
+				return err
+			})
+		})
+	}
+	if len(created) == 0 {
+		return errs
+	}
+
+	deadlineCtx, cancel := context.WithTimeout(ctx, kubeletPodDeadline)
+	defer cancel()
+	started := make(map[string]bool)
+	failed := make(map[string]string)
+	err := poll(deadlineCtx, func() (bool, error) {
+		list, err := pods.List(metav1.ListOptions{LabelSelector: "app=" + run})
+		if err != nil {
+			return false, err
+		}
+		for _, p := range list.Items {
+			node := p.Spec.NodeName
+			if started[node] || len(failed[node]) > 0 {
+				continue
+			}
+			switch p.Status.Phase {
+			case apiv1.PodRunning:
+				started[node] = true
+				elapsed := time.Since(created[node])
+				measure(strings.Trim(measurementNamePattern.ReplaceAllString(node, "_"), "_")+"_pod_start_ms", float64(elapsed.Milliseconds()))
+				log.Infoln("The kubelet of node", node, "started a pod in", elapsed.Round(time.Millisecond))
+			case apiv1.PodFailed:
+				failed[node] = p.Status.Reason + " " + p.Status.Message
+			}
+		}
+		return len(started)+len(failed) == len(created), nil
+	})
+	if err != nil && err != context.DeadlineExceeded {
+		errs = append(errs, "error waiting for pods to start: "+err.Error())
+	}
+	for node := range created {
+		switch {
+		case len(failed[node]) > 0:
+			errs = append(errs, "the pod on node "+node+" failed: "+strings.TrimSpace(failed[node]))
+		case !started[node]:
+			errs = append(errs, fmt.Sprintf("the kubelet of node %s did not start a pod within %s", node, kubeletPodDeadline))
+		}
+	}
+	return errs
+}
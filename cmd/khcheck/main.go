@@ -0,0 +1,169 @@
+// Copyright 2018 Comcast Cable Communications Management, LLC
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//     http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// khcheck is a library of common Kuberhealthy checks shipped as the subcommands of one binary, so that khchecks
+// can all reference the same image and pick their check with args.
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/integrii/flaggy"
+	log "github.com/sirupsen/logrus"
+	"k8s.io/client-go/kubernetes"
+
+	// required for oidc kubectl testing
+	_ "k8s.io/client-go/plugin/pkg/client/auth/oidc"
+
+	"github.com/Comcast/kuberhealthy/v2/pkg/checkclient"
+	"github.com/Comcast/kuberhealthy/v2/pkg/health"
+	"github.com/Comcast/kuberhealthy/v2/pkg/kubeClient"
+)
+
+// KHPodNamespace is the environment variable Kuberhealthy sets to the namespace of the checker pod
+const KHPodNamespace = "KH_POD_NAMESPACE"
+
+// defaultTimeout bounds a check that is run without a Kuberhealthy deadline, such as from a workstation
+const defaultTimeout = time.Minute * 5
+
+// deadlineMargin is how long before the Kuberhealthy deadline a check gives up so that it can still report
+const deadlineMargin = time.Second * 10
+
+// the global flags
+var kubeConfigFile = filepath.Join(os.Getenv("HOME"), ".kube", "config")
+var namespace = os.Getenv(KHPodNamespace)
+
+// check is a check of the library.  A nil error is reported as a success, and any other error as a failure.  Either
+// is reported along with the measurements the check recorded.
+type check func(ctx context.Context) error
+
+// cleanups are run in reverse order once the result of a check has been reported, so that deleting the resources
+// a check created does not hold up its report
+var cleanups []func()
+
+// afterReport runs a cleanup once the result of the check has been reported
+func afterReport(cleanup func()) {
+	cleanups = append(cleanups, cleanup)
+}
+
+// measurements are reported with the result of a check, such as the latency of the requests it made
+var measurements health.Measurements
+
+// measure records a measurement that is reported with the result of the check
+func measure(name string, value float64) {
+	if measurements == nil {
+		measurements = make(health.Measurements)
+	}
+	measurements[name] = value
+}
+
+// subcommand is the subcommand that runs a check
+type subcommand struct {
+	*flaggy.Subcommand
+	run check
+}
+
+func main() {
+	flaggy.SetName("khcheck")
+	flaggy.SetDescription("Common Kuberhealthy checks.  Run one by naming it as a subcommand.")
+	flaggy.String(&kubeConfigFile, "", "kubeconfig", "The kubeconfig used when not running in a pod.")
+	flaggy.String(&namespace, "n", "namespace", "The namespace that checks create their resources in.  Defaults to the namespace of the checker pod.")
+
+	checks := []subcommand{
+		{httpSubcommand(), runHTTPCheck},
+		{dnsSubcommand(), runDNSCheck},
+		{deploymentSubcommand(), runDeploymentCheck},
+		{daemonSetSubcommand(), runDaemonSetCheck},
+		{storageSubcommand(), runStorageCheck},
+		{transactionSubcommand(), runTransactionCheck},
+		{portsSubcommand(), runPortsCheck},
+		{webhooksSubcommand(), runWebhooksCheck},
+		{backupsSubcommand(), runBackupsCheck},
+		{clockSubcommand(), runClockCheck},
+		{kubeletSubcommand(), runKubeletCheck},
+		{ingressSubcommand(), runIngressCheck},
+		{tokensSubcommand(), runTokensCheck},
+		{metricsSubcommand(), runMetricsCheck},
+		{cronJobSubcommand(), runCronJobCheck},
+		{gitOpsSubcommand(), runGitOpsCheck},
+		{cloudAccessSubcommand(), runCloudAccessCheck},
+		{evictionSubcommand(), runEvictionCheck},
+		{quotaSubcommand(), runQuotaCheck},
+		{policySubcommand(), runPolicyCheck},
+	}
+	for _, c := range checks {
+		flaggy.AttachSubcommand(c.Subcommand, 1)
+	}
+	flaggy.Parse()
+
+	if len(namespace) == 0 {
+		namespace = "kuberhealthy"
+	}
+
+	for _, c := range checks {
+		if c.Used {
+			os.Exit(runCheck(c.Name, c.run))
+		}
+	}
+	flaggy.ShowHelpAndExit("A check is required")
+}
+
+// runCheck runs a check before its Kuberhealthy deadline, reports its result, and returns the exit code
+func runCheck(name string, run check) int {
+	timeout := defaultTimeout
+	deadline, err := checkclient.GetDeadline()
+	if err == nil {
+		timeout = time.Until(deadline) - deadlineMargin
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	log.Infoln("Running the", name, "check with a timeout of", timeout)
+	var errorMessages []string
+	err = run(ctx)
+	if err != nil {
+		log.Errorln("The", name, "check failed:", err)
+		errorMessages = []string{err.Error()}
+	} else {
+		log.Infoln("The", name, "check succeeded")
+	}
+	switch {
+	case len(measurements) > 0:
+		err = checkclient.ReportMeasurements(measurements, errorMessages)
+	case len(errorMessages) > 0:
+		err = checkclient.ReportFailure(errorMessages)
+	default:
+		err = checkclient.ReportSuccess()
+	}
+	for i := len(cleanups) - 1; i >= 0; i-- {
+		cleanups[i]()
+	}
+	if err != nil {
+		log.Errorln("Failed to report to Kuberhealthy:", err)
+		return 1
+	}
+	return 0
+}
+
+// newKubeClient creates a client for the checks that create resources
+func newKubeClient() (*kubernetes.Clientset, error) {
+	return kubeClient.Create(kubeConfigFile)
+}
+
+// resourceName returns a name for a resource created by a check run that does not collide with the resources
+// of other runs
+func resourceName(prefix string) string {
+	return prefix + "-" + strconv.FormatInt(time.Now().UnixNano(), 36)
+}
@@ -0,0 +1,822 @@
+package main
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	admissionv1beta1 "k8s.io/api/admissionregistration/v1beta1"
+	autoscalingv2beta1 "k8s.io/api/autoscaling/v2beta1"
+	batchv1 "k8s.io/api/batch/v1"
+	coordinationv1beta1 "k8s.io/api/coordination/v1beta1"
+	v1 "k8s.io/api/core/v1"
+	policyv1beta1 "k8s.io/api/policy/v1beta1"
+	k8sErrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	utilexec "k8s.io/client-go/util/exec"
+)
+
+// TestHTTPCheck validates that the http check fails unless the response has the expected status code
+func TestHTTPCheck(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/healthz" {
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	httpURL = server.URL + "/healthz"
+	err := runHTTPCheck(context.Background())
+	if err != nil {
+		t.Fatal("Expected the check to succeed but got", err)
+	}
+
+	httpURL = server.URL + "/missing"
+	err = runHTTPCheck(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "returned 404 instead of 200") {
+		t.Fatal("Expected the check to fail on a 404 but got", err)
+	}
+
+	httpURL = "ftp://example.com"
+	err = runHTTPCheck(context.Background())
+	if err == nil {
+		t.Fatal("Expected the check to reject a URL that is not http")
+	}
+}
+
+// TestDNSCheck validates that the dns check fails when any host name can not be resolved
+func TestDNSCheck(t *testing.T) {
+	dnsHosts = "localhost"
+	err := runDNSCheck(context.Background())
+	if err != nil {
+		t.Fatal("Expected localhost to resolve but got", err)
+	}
+
+	dnsHosts = "localhost, kuberhealthy.invalid"
+	err = runDNSCheck(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "kuberhealthy.invalid") {
+		t.Fatal("Expected the check to fail on a host that does not resolve but got", err)
+	}
+}
+
+// TestTransactionCheck validates that transactions pass cookies and captured variables between steps and fail on
+// the first step whose response is not what it expects
+func TestTransactionCheck(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/login":
+			if r.Method != http.MethodPost {
+				w.WriteHeader(http.StatusMethodNotAllowed)
+				return
+			}
+			http.SetCookie(w, &http.Cookie{Name: "session", Value: "abc"})
+			w.Write([]byte(`{"token": "secret", "user": {"id": 7}}`))
+		case "/items":
+			cookie, err := r.Cookie("session")
+			if err != nil || cookie.Value != "abc" || r.Header.Get("Authorization") != "Bearer secret" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			w.Write([]byte(`{"items": [{"owner": "7"}]}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	transactionSteps = `
+steps:
+- name: login
+  method: POST
+  url: ` + server.URL + `/login
+  capture:
+    token:
+      jsonPath: token
+    userID:
+      jsonPath: user.id
+- name: items
+  url: ` + server.URL + `/items
+  headers:
+    Authorization: Bearer ${token}
+  expectJSON:
+    items.0.owner: ${userID}
+`
+	measurements = nil
+	err := runTransactionCheck(context.Background())
+	if err != nil {
+		t.Fatal("Expected the transaction to succeed but got", err)
+	}
+	if _, ok := measurements["items_ms"]; !ok {
+		t.Fatal("Expected the latency of each step to be measured but got", measurements)
+	}
+
+	transactionSteps = `
+steps:
+- name: items
+  url: ` + server.URL + `/items
+  headers:
+    Authorization: Bearer ${token}
+`
+	err = runTransactionCheck(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "undefined variables token") {
+		t.Fatal("Expected the transaction to fail on an undefined variable but got", err)
+	}
+
+	transactionSteps = `
+steps:
+- name: items
+  url: ` + server.URL + `/items
+`
+	err = runTransactionCheck(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "step items failed") {
+		t.Fatal("Expected the transaction to fail on an unauthorized step but got", err)
+	}
+}
+
+// TestPortsCheck validates that open TCP ports and replying UDP ports are reached and that closed ports fail
+func TestPortsCheck(t *testing.T) {
+	tcp, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tcp.Close()
+	go func() {
+		for {
+			conn, err := tcp.Accept()
+			if err != nil {
+				return
+			}
+			conn.Close()
+		}
+	}()
+
+	udp, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer udp.Close()
+	go func() {
+		buf := make([]byte, 1500)
+		for {
+			n, addr, err := udp.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			udp.WriteTo(buf[:n], addr)
+		}
+	}()
+
+	measurements = nil
+	portsTargets = tcp.Addr().String() + ",udp://" + udp.LocalAddr().String() + "?payload=ping&name=echo"
+	err = runPortsCheck(context.Background())
+	if err != nil {
+		t.Fatal("Expected every port to be reached but got", err)
+	}
+	if _, ok := measurements["echo_reply_ms"]; !ok {
+		t.Fatal("Expected the reply latency of the named udp target to be measured but got", measurements)
+	}
+	tcpName := "tcp_127_0_0_1_" + strconv.Itoa(tcp.Addr().(*net.TCPAddr).Port) + "_connect_ms"
+	if _, ok := measurements[tcpName]; !ok {
+		t.Fatal("Expected the connect latency of the tcp target to be measured as", tcpName, "but got", measurements)
+	}
+
+	closed := tcp.Addr().String()
+	tcp.Close()
+	portsTargets = "tcp://" + closed + "?timeout=1s"
+	err = runPortsCheck(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "failed to reach ports") {
+		t.Fatal("Expected the check to fail on a closed port but got", err)
+	}
+
+	portsTargets = "http://example.com:80"
+	err = runPortsCheck(context.Background())
+	if err == nil {
+		t.Fatal("Expected the check to reject a target that is not tcp or udp")
+	}
+}
+
+// TestWebhooksCheck validates that webhooks must have ready endpoints and serve trusted certificates that are not
+// about to expire, and that API services must be available
+func TestWebhooksCheck(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+	caBundle := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	url := server.URL
+
+	webhooksMinCertValidity = time.Hour
+	errs := checkWebhook(fake.NewSimpleClientset(), "trusted", admissionv1beta1.WebhookClientConfig{URL: &url, CABundle: caBundle})
+	if len(errs) > 0 {
+		t.Fatal("Expected a webhook with a trusted certificate to pass but got", errs)
+	}
+
+	webhooksMinCertValidity = time.Hour * 24 * 365 * 200
+	errs = checkWebhook(fake.NewSimpleClientset(), "expiring", admissionv1beta1.WebhookClientConfig{URL: &url, CABundle: caBundle})
+	if len(errs) != 1 || !strings.Contains(errs[0], "expires at") {
+		t.Fatal("Expected a webhook with a certificate that expires too soon to fail but got", errs)
+	}
+	webhooksMinCertValidity = time.Hour
+
+	errs = checkWebhook(fake.NewSimpleClientset(), "untrusted", admissionv1beta1.WebhookClientConfig{URL: &url})
+	if len(errs) != 1 || !strings.Contains(errs[0], "error verifying certificate") {
+		t.Fatal("Expected a webhook with an untrusted certificate to fail but got", errs)
+	}
+
+	service := &admissionv1beta1.ServiceReference{Namespace: "webhooks", Name: "policy"}
+	endpoints := &v1.Endpoints{ObjectMeta: metav1.ObjectMeta{Namespace: "webhooks", Name: "policy"}}
+	errs = checkWebhook(fake.NewSimpleClientset(endpoints), "policy", admissionv1beta1.WebhookClientConfig{Service: service})
+	if len(errs) != 1 || !strings.Contains(errs[0], "has no ready endpoints") {
+		t.Fatal("Expected a webhook without ready endpoints to fail but got", errs)
+	}
+
+	fail := admissionv1beta1.Fail
+	ignore := admissionv1beta1.Ignore
+	if !shouldCheckWebhook("policy", &fail) || shouldCheckWebhook("policy", &ignore) || shouldCheckWebhook("policy", nil) {
+		t.Fatal("Expected only webhooks that fail closed to be checked by default")
+	}
+	webhooksNames = "other, policy"
+	if !shouldCheckWebhook("policy", &fail) || shouldCheckWebhook("another", &fail) {
+		t.Fatal("Expected only the named webhooks to be checked")
+	}
+	webhooksNames = ""
+
+	var list apiServiceList
+	err := json.Unmarshal([]byte(`{"items": [
+		{"metadata": {"name": "v1."}, "spec": {}},
+		{"metadata": {"name": "v1beta1.metrics.k8s.io"}, "spec": {"service": {"namespace": "kube-system", "name": "metrics-server"}, "insecureSkipTLSVerify": true},
+			"status": {"conditions": [{"type": "Available", "status": "False", "reason": "FailedDiscoveryCheck", "message": "no response"}]}},
+		{"metadata": {"name": "v1.custom.example.com"}, "spec": {"service": {"namespace": "custom", "name": "api"}, "insecureSkipTLSVerify": true},
+			"status": {"conditions": [{"type": "Available", "status": "True"}]}}
+	]}`), &list)
+	if err != nil {
+		t.Fatal("Expected API services to parse but got", err)
+	}
+	if errs = checkAPIService(list.Items[0]); len(errs) > 0 {
+		t.Fatal("Expected an API service served by the API server to be skipped but got", errs)
+	}
+	errs = checkAPIService(list.Items[1])
+	if len(errs) != 1 || !strings.Contains(errs[0], "FailedDiscoveryCheck: no response") {
+		t.Fatal("Expected an unavailable API service to fail with its reason but got", errs)
+	}
+	if errs = checkAPIService(list.Items[2]); len(errs) > 0 {
+		t.Fatal("Expected an available API service to pass but got", errs)
+	}
+}
+
+// TestBackupsCheck validates that the most recent completed backup must be newer than the maximum age
+func TestBackupsCheck(t *testing.T) {
+	recent := time.Now().Add(-time.Hour).UTC().Format(time.RFC3339)
+	old := time.Now().Add(-time.Hour * 48).UTC().Format(time.RFC3339)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("prefix") != "etcd/" {
+			w.Write([]byte(`<ListBucketResult><Name>backups</Name><IsTruncated>false</IsTruncated></ListBucketResult>`))
+			return
+		}
+		w.Write([]byte(`<ListBucketResult><Name>backups</Name><IsTruncated>false</IsTruncated>
+			<Contents><Key>etcd/old.db</Key><LastModified>` + old + `</LastModified><Size>100</Size></Contents>
+			<Contents><Key>etcd/empty.db</Key><LastModified>` + recent + `</LastModified><Size>0</Size></Contents>
+		</ListBucketResult>`))
+	}))
+	defer server.Close()
+	os.Setenv("AWS_ACCESS_KEY_ID", "test")
+	os.Setenv("AWS_SECRET_ACCESS_KEY", "test")
+
+	backupsSource = "s3"
+	backupsBucket = "backups"
+	backupsRegion = "us-east-1"
+	backupsEndpoint = server.URL
+	backupsPrefix = "etcd/"
+	backupsMaxAge = time.Hour * 25
+	measurements = nil
+	err := runBackupsCheck(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "etcd/old.db") {
+		t.Fatal("Expected the check to fail on a stale snapshot, ignoring the empty one, but got", err)
+	}
+	if age := measurements["backup_age_seconds"]; age < 47*60*60 {
+		t.Fatal("Expected the age of the most recent snapshot to be measured but got", measurements)
+	}
+
+	backupsMaxAge = time.Hour * 72
+	err = runBackupsCheck(context.Background())
+	if err != nil {
+		t.Fatal("Expected the check to pass on a snapshot newer than the maximum age but got", err)
+	}
+
+	backupsPrefix = "missing/"
+	err = runBackupsCheck(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "no completed backups") {
+		t.Fatal("Expected the check to fail without any snapshots but got", err)
+	}
+
+	var backups veleroBackupList
+	err = json.Unmarshal([]byte(`{"items": [
+		{"metadata": {"name": "daily-1"}, "status": {"phase": "Completed", "completionTimestamp": "`+old+`"}},
+		{"metadata": {"name": "daily-2"}, "status": {"phase": "PartiallyFailed", "completionTimestamp": "`+recent+`"}},
+		{"metadata": {"name": "daily-3"}, "status": {"phase": "InProgress"}}
+	]}`), &backups)
+	if err != nil {
+		t.Fatal("Expected velero backups to parse but got", err)
+	}
+	if _, name := newestVeleroBackup(backups); name != "daily-1" {
+		t.Fatal("Expected only completed velero backups to count but got", name)
+	}
+}
+
+// TestClockCheck validates that the offset from an NTP server is measured and that only nodes certainly further
+// from the reference clock than the maximum skew fail the check
+func TestClockCheck(t *testing.T) {
+	server, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal("Expected to listen on a udp port but got", err)
+	}
+	defer server.Close()
+	go func() {
+		buf := make([]byte, 48)
+		for {
+			_, addr, err := server.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			now := toNTPTime(time.Now().Add(time.Second * 10))
+			reply := make([]byte, 48)
+			reply[0] = 4<<3 | 4
+			reply[1] = 2
+			copy(reply[24:32], buf[40:48])
+			binary.BigEndian.PutUint64(reply[32:], now)
+			binary.BigEndian.PutUint64(reply[40:], now)
+			server.WriteTo(reply, addr)
+		}
+	}()
+
+	offset, err := ntpOffset(context.Background(), server.LocalAddr().String())
+	if err != nil {
+		t.Fatal("Expected the ntp server to reply but got", err)
+	}
+	if offset < time.Second*9 || offset > time.Second*11 {
+		t.Fatal("Expected the local clock to be 10s behind the ntp server but got", offset)
+	}
+
+	now := time.Now()
+	if ts := fromNTPTime(toNTPTime(now)); ts.Sub(now) > time.Microsecond || now.Sub(ts) > time.Microsecond {
+		t.Fatal("Expected ntp timestamps to round trip but got", ts, "for", now)
+	}
+
+	nodeTime, err := parseUnixTime("1600000000.123456789\n")
+	if err != nil || !nodeTime.Equal(time.Unix(1600000000, 123456789)) {
+		t.Fatal("Expected the output of date to be parsed but got", nodeTime, err)
+	}
+	if _, err := parseUnixTime("1600000000.%N"); err == nil {
+		t.Fatal("Expected output of date without nanoseconds to be rejected")
+	}
+
+	clockMaxSkew = time.Millisecond * 500
+	err = clockSkewError([]clockReading{
+		{Node: "in-sync", Skew: time.Millisecond * 20, Uncertainty: time.Millisecond * 5},
+		{Node: "uncertain", Skew: time.Millisecond * 600, Uncertainty: time.Millisecond * 200},
+		{Node: "behind", Skew: -time.Second * 3, Uncertainty: time.Millisecond * 5},
+	})
+	if err == nil || !strings.Contains(err.Error(), "behind is -3s off") || strings.Contains(err.Error(), "uncertain") {
+		t.Fatal("Expected only the node that is certainly skewed to fail the check but got", err)
+	}
+}
+
+// TestKubeletCheck validates that kubelets with stale heartbeats, or that do not start a pod in time, are reported
+func TestKubeletCheck(t *testing.T) {
+	now := time.Now()
+	renewed := metav1.NewMicroTime(now.Add(-time.Second * 10))
+	stale := metav1.NewMicroTime(now.Add(-time.Minute * 5))
+	nodes := []v1.Node{
+		{ObjectMeta: metav1.ObjectMeta{Name: "leased"}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "stale-lease"}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "no-lease"}, Status: v1.NodeStatus{Conditions: []v1.NodeCondition{
+			{Type: v1.NodeReady, LastHeartbeatTime: metav1.NewTime(now.Add(-time.Second * 30))},
+		}}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "never"}},
+	}
+	client := fake.NewSimpleClientset(
+		&coordinationv1beta1.Lease{ObjectMeta: metav1.ObjectMeta{Namespace: nodeLeaseNamespace, Name: "leased"}, Spec: coordinationv1beta1.LeaseSpec{RenewTime: &renewed}},
+		&coordinationv1beta1.Lease{ObjectMeta: metav1.ObjectMeta{Namespace: nodeLeaseNamespace, Name: "stale-lease"}, Spec: coordinationv1beta1.LeaseSpec{RenewTime: &stale}},
+	)
+
+	kubeletMaxHeartbeatAge = time.Minute
+	measurements = nil
+	errs := staleKubelets(client, nodes, now)
+	if len(errs) != 2 || !strings.Contains(errs[0], "stale-lease last sent a heartbeat 5m0s ago") || !strings.Contains(errs[1], "never has no kubelet heartbeat") {
+		t.Fatal("Expected the nodes with a stale or missing heartbeat to be reported but got", errs)
+	}
+	if age := measurements["no_lease_heartbeat_age_seconds"]; age != 30 {
+		t.Fatal("Expected the heartbeat of a node without a lease to come from its Ready condition but got", measurements)
+	}
+
+	kubeletPodDeadline = time.Millisecond * 100
+	cleanups = nil
+	errs = slowKubelets(context.Background(), client, []string{"leased"})
+	if len(errs) != 1 || !strings.Contains(errs[0], "the kubelet of node leased did not start a pod within 100ms") {
+		t.Fatal("Expected a node whose pod does not start to be reported but got", errs)
+	}
+	if len(cleanups) != 1 {
+		t.Fatal("Expected the pod to be deleted after the report but got", len(cleanups), "cleanups")
+	}
+	cleanups = nil
+}
+
+// TestIngressCheck validates that requests are sent to the ingress address with the ingress host, and only pass
+// once the backend answers over a trusted certificate
+func TestIngressCheck(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Host != "example.com" || r.URL.Path != "/khcheck-ingress-test" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write([]byte("khcheck-ingress-test\n"))
+	}))
+	defer server.Close()
+	caFile, err := ioutil.TempFile("", "khcheck-ca")
+	if err != nil {
+		t.Fatal("Expected to create a CA file but got", err)
+	}
+	defer os.Remove(caFile.Name())
+	pem.Encode(caFile, &pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	caFile.Close()
+
+	ingressHost = "example.com"
+	ingressAddress = server.Listener.Addr().String()
+	ingressTLS = true
+	ingressCAFile = caFile.Name()
+	client, err := ingressHTTPClient()
+	if err != nil {
+		t.Fatal("Expected an ingress client but got", err)
+	}
+	measurements = nil
+	err = requestThroughIngress(context.Background(), client, "/khcheck-ingress-test", "khcheck-ingress-test")
+	if err != nil {
+		t.Fatal("Expected the request to reach the backend but got", err)
+	}
+	if _, ok := measurements["ingress_request_ms"]; !ok {
+		t.Fatal("Expected the latency of the request to be measured but got", measurements)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	err = requestThroughIngress(ctx, client, "/unrouted", "khcheck-ingress-test")
+	if err == nil || !strings.Contains(err.Error(), "returned 404") {
+		t.Fatal("Expected a path that is not routed to the backend to fail but got", err)
+	}
+
+	ingressCAFile = ""
+	client, err = ingressHTTPClient()
+	if err != nil {
+		t.Fatal("Expected an ingress client but got", err)
+	}
+	ctx, cancel = context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	err = requestThroughIngress(ctx, client, "/khcheck-ingress-test", "khcheck-ingress-test")
+	if err == nil || !strings.Contains(err.Error(), "certificate") {
+		t.Fatal("Expected an untrusted certificate to fail but got", err)
+	}
+	ingressTLS = false
+	ingressAddress = ""
+}
+
+// TestTokensCheck validates that service account tokens are only accepted when they are signed by a key of the
+// cluster and have the expected claims
+func TestTokensCheck(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal("Expected an RSA key but got", err)
+	}
+	keys := jsonWebKeySet{Keys: []jsonWebKey{{
+		KeyType: "RSA",
+		KeyID:   "current",
+		N:       base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+		E:       base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+	}}}
+	sign := func(kid string, claims string) string {
+		signed := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"RS256","kid":"`+kid+`"}`)) + "." +
+			base64.RawURLEncoding.EncodeToString([]byte(claims))
+		digest := sha256.Sum256([]byte(signed))
+		signature, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+		if err != nil {
+			t.Fatal("Expected to sign a token but got", err)
+		}
+		return signed + "." + base64.RawURLEncoding.EncodeToString(signature)
+	}
+
+	now := time.Now()
+	exp := strconv.FormatInt(now.Add(time.Hour).Unix(), 10)
+	subject := "system:serviceaccount:kuberhealthy:khcheck-sa"
+	valid := sign("current", `{"iss":"https://kubernetes.default.svc","sub":"`+subject+`","aud":["khcheck"],"exp":`+exp+`}`)
+	err = verifyServiceAccountToken(valid, keys, "https://kubernetes.default.svc", "khcheck", subject, now)
+	if err != nil {
+		t.Fatal("Expected a valid token to verify but got", err)
+	}
+
+	cases := map[string]string{
+		"is signed with key \"rotated\"": sign("rotated", `{"iss":"https://kubernetes.default.svc","sub":"`+subject+`","aud":"khcheck","exp":`+exp+`}`),
+		"has issuer https://old":         sign("current", `{"iss":"https://old","sub":"`+subject+`","aud":"khcheck","exp":`+exp+`}`),
+		"has audiences [other]":          sign("current", `{"iss":"https://kubernetes.default.svc","sub":"`+subject+`","aud":"other","exp":`+exp+`}`),
+		"token expired":                  sign("current", `{"iss":"https://kubernetes.default.svc","sub":"`+subject+`","aud":"khcheck","exp":1}`),
+		"does not verify":                valid[:len(valid)-4] + "AAAA",
+	}
+	for want, token := range cases {
+		err = verifyServiceAccountToken(token, keys, "https://kubernetes.default.svc", "khcheck", subject, now)
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Fatal("Expected a token that", want, "to fail but got", err)
+		}
+	}
+
+	err = reviewToken(fake.NewSimpleClientset(), valid, subject)
+	if err == nil || !strings.Contains(err.Error(), "not authenticated") {
+		t.Fatal("Expected a token that TokenReview does not authenticate to fail but got", err)
+	}
+}
+
+// TestMetricsCheck validates that ready nodes and pods without fresh metrics are reported, and that a test
+// autoscaler only passes once it has read its metrics
+func TestMetricsCheck(t *testing.T) {
+	now := time.Now().Truncate(time.Second)
+	ready := v1.NodeStatus{Conditions: []v1.NodeCondition{{Type: v1.NodeReady, Status: v1.ConditionTrue}}}
+	nodes := []v1.Node{
+		{ObjectMeta: metav1.ObjectMeta{Name: "fresh"}, Status: ready},
+		{ObjectMeta: metav1.ObjectMeta{Name: "stale"}, Status: ready},
+		{ObjectMeta: metav1.ObjectMeta{Name: "missing"}, Status: ready},
+		{ObjectMeta: metav1.ObjectMeta{Name: "not-ready"}},
+	}
+	var metrics resourceMetricsList
+	err := json.Unmarshal([]byte(`{"items": [
+		{"metadata": {"name": "fresh"}, "timestamp": "`+now.Add(-time.Second*30).UTC().Format(time.RFC3339)+`"},
+		{"metadata": {"name": "stale"}, "timestamp": "`+now.Add(-time.Minute*10).UTC().Format(time.RFC3339)+`"}
+	]}`), &metrics)
+	if err != nil {
+		t.Fatal("Expected metrics to parse but got", err)
+	}
+
+	metricsMaxAge = time.Minute * 2
+	measurements = nil
+	errs := staleNodeMetrics(nodes, metrics, now)
+	if len(errs) != 2 || errs[0] != "node missing has no metrics" || errs[1] != "the metrics of node stale are 10m0s old" {
+		t.Fatal("Expected the ready nodes with missing or stale metrics to be reported but got", errs)
+	}
+	if age := measurements["node_metrics_age_seconds"]; age != 600 {
+		t.Fatal("Expected the age of the oldest node metrics to be measured but got", measurements)
+	}
+	if errs = stalePodMetrics(resourceMetricsList{}, now); len(errs) != 1 {
+		t.Fatal("Expected a namespace without pod metrics to be reported but got", errs)
+	}
+
+	hpa := &autoscalingv2beta1.HorizontalPodAutoscaler{}
+	if computed, reason := hpaComputed(hpa); computed || reason != "it has no ScalingActive condition" {
+		t.Fatal("Expected a new autoscaler to not be computed but got", reason)
+	}
+	hpa.Status.Conditions = []autoscalingv2beta1.HorizontalPodAutoscalerCondition{{
+		Type: autoscalingv2beta1.ScalingActive, Status: v1.ConditionFalse, Reason: "FailedGetResourceMetric", Message: "unable to fetch metrics",
+	}}
+	if computed, reason := hpaComputed(hpa); computed || reason != "FailedGetResourceMetric: unable to fetch metrics" {
+		t.Fatal("Expected an autoscaler without metrics to report why but got", reason)
+	}
+	hpa.Status.Conditions[0].Status = v1.ConditionTrue
+	hpa.Status.CurrentMetrics = []autoscalingv2beta1.MetricStatus{{Type: autoscalingv2beta1.ResourceMetricSourceType}}
+	if computed, _ := hpaComputed(hpa); !computed {
+		t.Fatal("Expected an autoscaler that read its metrics to be computed")
+	}
+}
+
+// TestCronJobCheck validates that completed jobs are counted and that failed or late jobs are reported
+func TestCronJobCheck(t *testing.T) {
+	scheduled := time.Date(2020, 1, 1, 12, 0, 0, 0, time.UTC)
+	job := func(minute int, created time.Duration, condition batchv1.JobConditionType) batchv1.Job {
+		at := scheduled.Add(time.Minute * time.Duration(minute))
+		start := metav1.NewTime(at.Add(created))
+		done := metav1.NewTime(at.Add(created + time.Second*5))
+		return batchv1.Job{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:              "khcheck-cronjob-abc-" + strconv.FormatInt(at.Unix()/60, 10),
+				CreationTimestamp: metav1.NewTime(at.Add(created)),
+			},
+			Status: batchv1.JobStatus{
+				StartTime:      &start,
+				CompletionTime: &done,
+				Conditions:     []batchv1.JobCondition{{Type: condition, Status: v1.ConditionTrue, Reason: "BackoffLimitExceeded"}},
+			},
+		}
+	}
+
+	cronJobMaxDelay = time.Second * 30
+	measurements = nil
+	completed, errs := cronJobProgress("khcheck-cronjob-abc", []batchv1.Job{
+		job(0, time.Second*2, batchv1.JobComplete),
+		job(1, time.Second*4, batchv1.JobComplete),
+	})
+	if completed != 2 || len(errs) > 0 {
+		t.Fatal("Expected two jobs created on schedule to complete but got", completed, errs)
+	}
+	if measurements["job_delay_ms"] != 4000 || measurements["job_duration_ms"] != 5000 {
+		t.Fatal("Expected the longest delay and duration of the jobs to be measured but got", measurements)
+	}
+
+	completed, errs = cronJobProgress("khcheck-cronjob-abc", []batchv1.Job{
+		job(0, time.Minute*2, batchv1.JobComplete),
+		job(1, time.Second, batchv1.JobFailed),
+	})
+	if completed != 1 || len(errs) != 2 || !strings.Contains(errs[0], "created 2m0s after its scheduled time") || !strings.Contains(errs[1], "failed: BackoffLimitExceeded") {
+		t.Fatal("Expected the failed and late jobs to be reported but got", completed, errs)
+	}
+}
+
+// TestGitOpsCheck validates that out of sync and unhealthy resources only fail the check once they have been for
+// longer than the threshold
+func TestGitOpsCheck(t *testing.T) {
+	now := time.Now().Truncate(time.Second)
+	var applications argoApplicationList
+	err := json.Unmarshal([]byte(`{"items": [
+		{"metadata": {"namespace": "argocd", "name": "synced"}, "status": {"sync": {"status": "Synced"}, "health": {"status": "Healthy"}}},
+		{"metadata": {"namespace": "argocd", "name": "drifted"}, "status": {"sync": {"status": "OutOfSync"}, "health": {"status": "Healthy"}}},
+		{"metadata": {"namespace": "argocd", "name": "broken"}, "status": {"sync": {"status": "Synced"}, "health": {"status": "Degraded", "message": "crash looping"}}},
+		{"metadata": {"namespace": "argocd", "name": "paused"}, "status": {"sync": {"status": "Synced"}, "health": {"status": "Suspended"}}}
+	]}`), &applications)
+	if err != nil {
+		t.Fatal("Expected applications to parse but got", err)
+	}
+	var kustomizations fluxResourceList
+	err = json.Unmarshal([]byte(`{"items": [
+		{"metadata": {"namespace": "flux-system", "name": "ready"}, "status": {"conditions": [{"type": "Ready", "status": "True"}]}},
+		{"metadata": {"namespace": "flux-system", "name": "failing"}, "status": {"conditions": [{"type": "Ready", "status": "False", "reason": "BuildFailed", "message": "missing file",
+			"lastTransitionTime": "`+now.Add(-time.Hour).UTC().Format(time.RFC3339)+`"}]}},
+		{"metadata": {"namespace": "flux-system", "name": "suspended"}, "spec": {"suspend": true}, "status": {"conditions": [{"type": "Ready", "status": "False"}]}}
+	]}`), &kustomizations)
+	if err != nil {
+		t.Fatal("Expected kustomizations to parse but got", err)
+	}
+
+	gitOpsIgnoreSuspended = true
+	problems := append(argoApplicationProblems(applications), fluxResourceProblems("kustomizations", kustomizations)...)
+	if len(problems) != 3 {
+		t.Fatal("Expected the drifted, broken and failing resources to be problems but got", problems)
+	}
+
+	gitOpsThreshold = time.Minute * 15
+	firstSeen := map[string]string{
+		"applications.argocd.drifted": now.Add(-time.Minute * 20).Format(time.RFC3339),
+		"applications.argocd.gone":    now.Add(-time.Hour).Format(time.RFC3339),
+	}
+	errs, seen := overdueGitOpsProblems(problems, firstSeen, now)
+	if len(errs) != 2 || errs[0] != "applications argocd/drifted is OutOfSync for 20m0s" || errs[1] != "kustomizations flux-system/failing is not ready: BuildFailed: missing file for 1h0m0s" {
+		t.Fatal("Expected the resources unhealthy for longer than the threshold to fail the check but got", errs)
+	}
+	if len(seen) != 2 || seen["applications.argocd.broken"] != now.Format(time.RFC3339) {
+		t.Fatal("Expected only the current application problems to be remembered but got", seen)
+	}
+}
+
+// TestCloudAccessCheck validates that targets are parsed, that wget failures are told apart from unreachable
+// targets, and that nodes reaching denied targets or not reaching allowed ones are reported
+func TestCloudAccessCheck(t *testing.T) {
+	targets, err := parseCloudAccessTargets("imds=deny, https://sts.amazonaws.com=allow")
+	if err != nil {
+		t.Fatal("Expected the targets to parse but got", err)
+	}
+	if len(targets) != 2 || targets[0].URL != instanceMetadataURL || targets[0].Allow || targets[1].Name != "sts_amazonaws_com" || !targets[1].Allow {
+		t.Fatal("Expected the metadata service to be denied and sts to be allowed but got", targets)
+	}
+	for _, bad := range []string{"", "imds", "imds=maybe", "sts.amazonaws.com=allow"} {
+		if _, err := parseCloudAccessTargets(bad); err == nil {
+			t.Fatal("Expected targets", bad, "to be rejected")
+		}
+	}
+
+	exitErr := utilexec.CodeExitError{Err: errors.New("command terminated with exit code 1"), Code: 1}
+	reachable, _, err := classifyWget(fmt.Errorf("error running wget in pod p: %w: wget: server returned error: HTTP/1.1 401 Unauthorized", exitErr))
+	if !reachable || err != nil {
+		t.Fatal("Expected an HTTP error status to be reachable but got", reachable, err)
+	}
+	reachable, reason, err := classifyWget(fmt.Errorf("error running wget in pod p: %w: wget: download timed out", exitErr))
+	if reachable || reason != "download timed out" || err != nil {
+		t.Fatal("Expected a timeout to be unreachable but got", reachable, reason, err)
+	}
+	_, _, err = classifyWget(errors.New("error creating exec into pod p"))
+	if err == nil {
+		t.Fatal("Expected a failure to exec to be returned")
+	}
+
+	results := []cloudAccessResult{
+		{Node: "a", Target: targets[0]},
+		{Node: "a", Target: targets[1], Reachable: true},
+		{Node: "b", Target: targets[0], Reachable: true},
+		{Node: "b", Target: targets[1], Reason: "download timed out"},
+	}
+	errs := cloudAccessErrors(targets, results)
+	if len(errs) != 2 || errs[0] != "node b can not reach https://sts.amazonaws.com: download timed out" || errs[1] != "node b can reach "+instanceMetadataURL+", which should be denied" {
+		t.Fatal("Expected node b to be reported for both targets but got", errs)
+	}
+}
+
+// TestEvictionCheck validates that the disruption budget is only ready once it allows one disruption of every
+// replica, and that evictions are only accepted when they are allowed or refused by the budget as expected
+func TestEvictionCheck(t *testing.T) {
+	pdb := &policyv1beta1.PodDisruptionBudget{ObjectMeta: metav1.ObjectMeta{Generation: 2}}
+	pdb.Status = policyv1beta1.PodDisruptionBudgetStatus{ObservedGeneration: 1, CurrentHealthy: 2, PodDisruptionsAllowed: 1}
+	if pdbAllowsOneDisruption(pdb, 2) {
+		t.Fatal("Expected a budget whose latest generation was not observed to not be ready")
+	}
+	pdb.Status.ObservedGeneration = 2
+	if !pdbAllowsOneDisruption(pdb, 2) {
+		t.Fatal("Expected a budget that allows one disruption of every replica to be ready")
+	}
+	pdb.Status.CurrentHealthy = 1
+	if pdbAllowsOneDisruption(pdb, 2) {
+		t.Fatal("Expected a budget missing a healthy replica to not be ready")
+	}
+
+	refused := k8sErrors.NewTooManyRequests("Cannot evict pod as it would violate the pod's disruption budget.", 0)
+	if err := evictionOutcome("a", true, nil); err != nil {
+		t.Fatal("Expected an allowed eviction to pass but got", err)
+	}
+	if err := evictionOutcome("a", true, refused); err == nil {
+		t.Fatal("Expected a refused eviction that should have been allowed to fail")
+	}
+	if err := evictionOutcome("b", false, refused); err != nil {
+		t.Fatal("Expected an eviction refused by the budget to pass but got", err)
+	}
+	if err := evictionOutcome("b", false, nil); err == nil {
+		t.Fatal("Expected an eviction that broke the budget to fail")
+	}
+	if err := evictionOutcome("b", false, k8sErrors.NewForbidden(v1.Resource("pods/eviction"), "b", errors.New("denied"))); err == nil {
+		t.Fatal("Expected an eviction refused for another reason to fail")
+	}
+}
+
+// TestQuotaCheck validates that only creations forbidden for the expected reason pass, and that pods are checked
+// for the defaults of the limit range
+func TestQuotaCheck(t *testing.T) {
+	overQuota := k8sErrors.NewForbidden(v1.Resource("pods"), "over-quota", errors.New("exceeded quota: q, requested: pods=1, used: pods=1, limited: pods=1"))
+	if err := rejectionOutcome("a pod over the resource quota", overQuota, "exceeded quota"); err != nil {
+		t.Fatal("Expected a pod forbidden by the quota to pass but got", err)
+	}
+	if err := rejectionOutcome("a pod over the resource quota", nil, "exceeded quota"); err == nil {
+		t.Fatal("Expected an admitted pod to fail")
+	}
+	if err := rejectionOutcome("a pod with a CPU limit over the limit range maximum", overQuota, "maximum cpu usage"); err == nil {
+		t.Fatal("Expected a pod forbidden for another reason to fail")
+	}
+
+	pod := quotaPod("within-quota")
+	if err := limitRangeDefaultsApplied(pod); err == nil {
+		t.Fatal("Expected a pod without resources to fail")
+	}
+	defaults := v1.ResourceList{v1.ResourceCPU: resource.MustParse("0.05"), v1.ResourceMemory: resource.MustParse("32Mi")}
+	pod.Spec.Containers[0].Resources = v1.ResourceRequirements{Requests: defaults, Limits: defaults}
+	if err := limitRangeDefaultsApplied(pod); err != nil {
+		t.Fatal("Expected a pod with the default resources to pass but got", err)
+	}
+}
+
+// TestPolicyCheck validates that resources are loaded with a generated name when they have none, and that only
+// webhook denials with the expected message pass
+func TestPolicyCheck(t *testing.T) {
+	resource, err := loadPolicyResource("apiVersion: v1\nkind: Pod\nmetadata:\n  labels:\n    app: unowned\n", "", "violating")
+	if err != nil {
+		t.Fatal("Expected the resource to load but got", err)
+	}
+	if resource.GetKind() != "Pod" || resource.GetGenerateName() != "khcheck-policy-" {
+		t.Fatal("Expected a pod with a generated name but got", resource)
+	}
+	if _, err := loadPolicyResource("", "", "compliant"); err == nil {
+		t.Fatal("Expected a missing resource to fail")
+	}
+	if _, err := loadPolicyResource("metadata:\n  name: x\n", "", "compliant"); err == nil {
+		t.Fatal("Expected a resource without a kind to fail")
+	}
+
+	denied := errors.New(`admission webhook "validation.gatekeeper.sh" denied the request: [required-owner] you must provide labels: {"owner"}`)
+	if err := policyDenialOutcome(denied, "required-owner"); err != nil {
+		t.Fatal("Expected a denial by the policy to pass but got", err)
+	}
+	if err := policyDenialOutcome(denied, "other-policy"); err == nil {
+		t.Fatal("Expected a denial by another policy to fail")
+	}
+	if err := policyDenialOutcome(errors.New(`Internal error occurred: failed calling webhook "validation.gatekeeper.sh": context deadline exceeded`), ""); err == nil {
+		t.Fatal("Expected a failed webhook call to fail")
+	}
+	if err := policyDenialOutcome(nil, ""); err == nil {
+		t.Fatal("Expected an admitted violating resource to fail")
+	}
+}
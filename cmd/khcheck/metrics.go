@@ -0,0 +1,285 @@
+// Copyright 2018 Comcast Cable Communications Management, LLC
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//     http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/integrii/flaggy"
+	log "github.com/sirupsen/logrus"
+	appsv1 "k8s.io/api/apps/v1"
+	autoscalingv2beta1 "k8s.io/api/autoscaling/v2beta1"
+	apiv1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// the flags of the metrics check
+var metricsMaxAge = time.Minute * 2
+var metricsPodNamespace = "kube-system"
+var metricsHPA bool
+var metricsHPATimeout = time.Minute * 3
+var metricsImage = "gcr.io/google-containers/pause:3.1"
+
+// metricsAPIPath is the path of the resource metrics API served by metrics-server
+const metricsAPIPath = "/apis/metrics.k8s.io/v1beta1"
+
+// resourceMetricsList is the part of a list of node or pod metrics that the metrics check reads
+type resourceMetricsList struct {
+	Items []struct {
+		Metadata  metav1.ObjectMeta `json:"metadata"`
+		Timestamp metav1.Time       `json:"timestamp"`
+	} `json:"items"`
+}
+
+// metricsSubcommand returns the subcommand of the metrics check
+func metricsSubcommand() *flaggy.Subcommand {
+	s := flaggy.NewSubcommand("metrics")
+	s.Description = "Expect the metrics API to serve fresh node and pod metrics, and optionally a test HPA to compute its replicas"
+	s.Duration(&metricsMaxAge, "", "maxAge", "How old the metrics of each node and pod can be.")
+	s.String(&metricsPodNamespace, "", "podNamespace", "The namespace whose pod metrics are checked.  It must have running pods.")
+	s.Bool(&metricsHPA, "", "hpa", "Also create a deployment with a horizontal pod autoscaler and expect the autoscaler to compute its replicas from the metrics API.")
+	s.Duration(&metricsHPATimeout, "", "hpaTimeout", "How long the test autoscaler can take to compute its replicas.")
+	s.String(&metricsImage, "", "image", "The image of the deployment scaled by the test autoscaler.")
+	return s
+}
+
+// runMetricsCheck fails if the metrics API does not have fresh metrics for every ready node and for the pods of the
+// pod namespace, and, when enabled, if a test autoscaler can not compute its replicas.  A dead metrics pipeline
+// silently stops autoscaling, so it is checked end to end.  The age of the oldest metrics is measured.
+func runMetricsCheck(ctx context.Context) error {
+	client, err := newKubeClient()
+	if err != nil {
+		return fmt.Errorf("error creating kubernetes client: %w", err)
+	}
+	now := time.Now()
+
+	nodes, err := client.CoreV1().Nodes().List(metav1.ListOptions{})
+	if err != nil {
+		return fmt.Errorf("error listing nodes: %w", err)
+	}
+	nodeMetrics, err := listResourceMetrics(ctx, client, metricsAPIPath+"/nodes")
+	if err != nil {
+		return err
+	}
+	errs := staleNodeMetrics(nodes.Items, nodeMetrics, now)
+
+	podMetrics, err := listResourceMetrics(ctx, client, metricsAPIPath+"/namespaces/"+metricsPodNamespace+"/pods")
+	if err != nil {
+		return err
+	}
+	errs = append(errs, stalePodMetrics(podMetrics, now)...)
+
+	if metricsHPA && len(errs) == 0 {
+		err = checkTestHPA(ctx, client)
+		if err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+
+	if len(errs) > 0 {
+		return errors.New(strings.Join(errs, ", "))
+	}
+	log.Infoln("The metrics API has fresh metrics for all", len(nodeMetrics.Items), "nodes")
+	return nil
+}
+
+// listResourceMetrics lists node or pod metrics from the metrics API
+func listResourceMetrics(ctx context.Context, client kubernetes.Interface, path string) (resourceMetricsList, error) {
+	var list resourceMetricsList
+	body, err := client.Discovery().RESTClient().Get().AbsPath(path).Context(ctx).DoRaw()
+	if err != nil {
+		return list, fmt.Errorf("error getting %s from the metrics API: %w", path, err)
+	}
+	err = json.Unmarshal(body, &list)
+	if err != nil {
+		return list, fmt.Errorf("error parsing %s from the metrics API: %w", path, err)
+	}
+	return list, nil
+}
+
+// staleNodeMetrics returns an error for each ready node that has no metrics or whose metrics are older than the
+// maximum age
+func staleNodeMetrics(nodes []apiv1.Node, metrics resourceMetricsList, now time.Time) []string {
+	timestamps := make(map[string]time.Time)
+	for _, m := range metrics.Items {
+		timestamps[m.Metadata.Name] = m.Timestamp.Time
+	}
+
+	var errs []string
+	var oldest time.Duration
+	for _, n := range nodes {
+		if !nodeReady(n) {
+			continue
+		}
+		timestamp, ok := timestamps[n.Name]
+		if !ok {
+			errs = append(errs, "node "+n.Name+" has no metrics")
+			continue
+		}
+		age := now.Sub(timestamp)
+		if age > oldest {
+			oldest = age
+		}
+		if age > metricsMaxAge {
+			errs = append(errs, fmt.Sprintf("the metrics of node %s are %s old", n.Name, age.Round(time.Second)))
+		}
+	}
+	measure("node_metrics_age_seconds", oldest.Seconds())
+	sort.Strings(errs)
+	return errs
+}
+
+// stalePodMetrics returns an error if there are no pod metrics, and for each pod whose metrics are older than the
+// maximum age
+func stalePodMetrics(metrics resourceMetricsList, now time.Time) []string {
+	if len(metrics.Items) == 0 {
+		return []string{"there are no metrics for the pods in " + metricsPodNamespace}
+	}
+	var errs []string
+	var oldest time.Duration
+	for _, m := range metrics.Items {
+		age := now.Sub(m.Timestamp.Time)
+		if age > oldest {
+			oldest = age
+		}
+		if age > metricsMaxAge {
+			errs = append(errs, fmt.Sprintf("the metrics of pod %s/%s are %s old", metricsPodNamespace, m.Metadata.Name, age.Round(time.Second)))
+		}
+	}
+	measure("pod_metrics_age_seconds", oldest.Seconds())
+	sort.Strings(errs)
+	return errs
+}
+
+// nodeReady returns true if a node has a Ready condition that is true
+func nodeReady(n apiv1.Node) bool {
+	for _, c := range n.Status.Conditions {
+		if c.Type == apiv1.NodeReady {
+			return c.Status == apiv1.ConditionTrue
+		}
+	}
+	return false
+}
+
+// checkTestHPA creates a deployment scaled by a CPU utilization autoscaler and waits for the autoscaler to compute
+// its replicas from the metrics API.  The deployment and autoscaler are deleted once the result is reported.
+func checkTestHPA(ctx context.Context, client kubernetes.Interface) error {
+	name := resourceName("khcheck-metrics")
+	labels := map[string]string{checkLabel: "metrics", "app": name}
+	replicas := int32(1)
+	deployment := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Labels: labels},
+		Spec: appsv1.DeploymentSpec{
+			Replicas: &replicas,
+			Selector: &metav1.LabelSelector{MatchLabels: labels},
+			Template: apiv1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{Labels: labels},
+				Spec: apiv1.PodSpec{
+					Containers: []apiv1.Container{{
+						Name:  "main",
+						Image: metricsImage,
+						Resources: apiv1.ResourceRequirements{
+							Requests: apiv1.ResourceList{apiv1.ResourceCPU: resource.MustParse("10m")},
+						},
+					}},
+				},
+			},
+		},
+	}
+	deployments := client.AppsV1().Deployments(namespace)
+	_, err := deployments.Create(deployment)
+	if err != nil {
+		return fmt.Errorf("error creating deployment %s: %w", name, err)
+	}
+	afterReport(func() {
+		deleteAndWait("deployment", name, func() error {
+			return deployments.Delete(name, foregroundDelete())
+		}, func() error {
+			_, err := deployments.Get(name, metav1.GetOptions{})
+			return err
+		})
+	})
+
+	utilization := int32(80)
+	hpa := &autoscalingv2beta1.HorizontalPodAutoscaler{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Labels: labels},
+		Spec: autoscalingv2beta1.HorizontalPodAutoscalerSpec{
+			ScaleTargetRef: autoscalingv2beta1.CrossVersionObjectReference{APIVersion: "apps/v1", Kind: "Deployment", Name: name},
+			MinReplicas:    &replicas,
+			MaxReplicas:    2,
+			Metrics: []autoscalingv2beta1.MetricSpec{{
+				Type:     autoscalingv2beta1.ResourceMetricSourceType,
+				Resource: &autoscalingv2beta1.ResourceMetricSource{Name: apiv1.ResourceCPU, TargetAverageUtilization: &utilization},
+			}},
+		},
+	}
+	hpas := client.AutoscalingV2beta1().HorizontalPodAutoscalers(namespace)
+	_, err = hpas.Create(hpa)
+	if err != nil {
+		return fmt.Errorf("error creating horizontal pod autoscaler %s: %w", name, err)
+	}
+	created := time.Now()
+	log.Infoln("Created deployment and horizontal pod autoscaler", name, "in", namespace)
+	afterReport(func() {
+		deleteAndWait("horizontal pod autoscaler", name, func() error {
+			return hpas.Delete(name, &metav1.DeleteOptions{})
+		}, func() error {
+			_, err := hpas.Get(name, metav1.GetOptions{})
+			return err
+		})
+	})
+
+	hpaCtx, cancel := context.WithTimeout(ctx, metricsHPATimeout)
+	defer cancel()
+	var reason string
+	err = poll(hpaCtx, func() (bool, error) {
+		h, err := hpas.Get(name, metav1.GetOptions{})
+		if err != nil {
+			return false, err
+		}
+		var computed bool
+		computed, reason = hpaComputed(h)
+		return computed, nil
+	})
+	if err != nil {
+		return fmt.Errorf("horizontal pod autoscaler %s did not compute its replicas within %s: %s", name, metricsHPATimeout, reason)
+	}
+	measure("hpa_ready_ms", float64(time.Since(created).Milliseconds()))
+	log.Infoln("Horizontal pod autoscaler", name, "computed its replicas after", time.Since(created).Round(time.Second))
+	return nil
+}
+
+// hpaComputed returns true once an autoscaler has read its metrics and is able to scale.  Otherwise it returns why
+// it is not.
+func hpaComputed(hpa *autoscalingv2beta1.HorizontalPodAutoscaler) (bool, string) {
+	for _, c := range hpa.Status.Conditions {
+		if c.Type != autoscalingv2beta1.ScalingActive {
+			continue
+		}
+		if c.Status != apiv1.ConditionTrue {
+			return false, c.Reason + ": " + c.Message
+		}
+		if len(hpa.Status.CurrentMetrics) == 0 {
+			return false, "it has no current metrics"
+		}
+		return true, ""
+	}
+	return false, "it has no ScalingActive condition"
+}
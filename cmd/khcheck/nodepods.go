@@ -0,0 +1,117 @@
+// Copyright 2018 Comcast Cable Communications Management, LLC
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//     http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+	appsv1 "k8s.io/api/apps/v1"
+	apiv1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/remotecommand"
+)
+
+// createNodePods creates a daemonset that runs a pod of the image on every node, for checks that fan out across
+// nodes, and returns its pods once they are all running.  The pods sleep so that checks can exec into them.  The
+// daemonset is deleted once the result is reported.
+func createNodePods(ctx context.Context, client kubernetes.Interface, check string, image string, tolerateAll bool) ([]apiv1.Pod, error) {
+	name := resourceName("khcheck-" + check)
+	labels := map[string]string{checkLabel: check, "app": name}
+	var tolerations []apiv1.Toleration
+	if tolerateAll {
+		tolerations = []apiv1.Toleration{{Operator: apiv1.TolerationOpExists}}
+	}
+	var gracePeriod int64
+	daemonSet := &appsv1.DaemonSet{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Labels: labels},
+		Spec: appsv1.DaemonSetSpec{
+			Selector: &metav1.LabelSelector{MatchLabels: labels},
+			Template: apiv1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{Labels: labels},
+				Spec: apiv1.PodSpec{
+					Containers:                    []apiv1.Container{{Name: "main", Image: image, Command: []string{"sleep", "3600"}}},
+					Tolerations:                   tolerations,
+					TerminationGracePeriodSeconds: &gracePeriod,
+				},
+			},
+		},
+	}
+	daemonSets := client.AppsV1().DaemonSets(namespace)
+	_, err := daemonSets.Create(daemonSet)
+	if err != nil {
+		return nil, fmt.Errorf("error creating daemonset %s: %w", name, err)
+	}
+	log.Infoln("Created daemonset", name, "in", namespace)
+	afterReport(func() {
+		deleteAndWait("daemonset", name, func() error {
+			return daemonSets.Delete(name, foregroundDelete())
+		}, func() error {
+			_, err := daemonSets.Get(name, metav1.GetOptions{})
+			return err
+		})
+	})
+
+	var pods []apiv1.Pod
+	var desired int32
+	err = poll(ctx, func() (bool, error) {
+		d, err := daemonSets.Get(name, metav1.GetOptions{})
+		if err != nil {
+			return false, err
+		}
+		desired = d.Status.DesiredNumberScheduled
+		list, err := client.CoreV1().Pods(namespace).List(metav1.ListOptions{LabelSelector: "app=" + name})
+		if err != nil {
+			return false, err
+		}
+		pods = pods[:0]
+		for _, p := range list.Items {
+			if p.Status.Phase == apiv1.PodRunning && len(p.Spec.NodeName) > 0 {
+				pods = append(pods, p)
+			}
+		}
+		return desired > 0 && int32(len(pods)) == desired, nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("daemonset %s had %d of %d pods running: %w", name, len(pods), desired, err)
+	}
+	return pods, nil
+}
+
+// execInPod runs a command in the main container of a pod and returns what it wrote to stdout.  The error of a
+// command that fails includes what it wrote to stderr.
+func execInPod(client kubernetes.Interface, config *rest.Config, pod apiv1.Pod, command ...string) (string, error) {
+	req := client.CoreV1().RESTClient().Post().Resource("pods").Namespace(pod.Namespace).Name(pod.Name).
+		SubResource("exec").VersionedParams(&apiv1.PodExecOptions{
+		Container: "main",
+		Command:   command,
+		Stdout:    true,
+		Stderr:    true,
+	}, scheme.ParameterCodec)
+	exec, err := remotecommand.NewSPDYExecutor(config, "POST", req.URL())
+	if err != nil {
+		return "", fmt.Errorf("error creating exec into pod %s: %w", pod.Name, err)
+	}
+
+	var stdout, stderr bytes.Buffer
+	err = exec.Stream(remotecommand.StreamOptions{Stdout: &stdout, Stderr: &stderr})
+	if err != nil {
+		return stdout.String(), fmt.Errorf("error running %s in pod %s: %w: %s", command[0], pod.Name, err, strings.TrimSpace(stderr.String()))
+	}
+	return stdout.String(), nil
+}
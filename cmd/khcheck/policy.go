@@ -0,0 +1,180 @@
+// Copyright 2018 Comcast Cable Communications Management, LLC
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//     http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"time"
+
+	"github.com/ghodss/yaml"
+	"github.com/integrii/flaggy"
+	log "github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/restmapper"
+
+	"github.com/Comcast/kuberhealthy/v2/pkg/kubeClient"
+)
+
+// the flags of the policy check
+var policyViolating string
+var policyViolatingFile string
+var policyCompliant string
+var policyCompliantFile string
+var policyExpectMessage string
+var policyMaxLatency = time.Second * 3
+
+// policySubcommand returns the subcommand of the policy check
+func policySubcommand() *flaggy.Subcommand {
+	s := flaggy.NewSubcommand("policy")
+	s.Description = "Dry run a resource that violates a policy and one that complies, and expect the policy engine to deny and admit them in time"
+	s.String(&policyViolating, "", "violating", "The YAML or JSON resource that a policy of Gatekeeper or Kyverno denies.")
+	s.String(&policyViolatingFile, "", "violatingFile", "A file holding the violating resource, such as one mounted from a ConfigMap.")
+	s.String(&policyCompliant, "", "compliant", "The YAML or JSON resource that the policies admit.")
+	s.String(&policyCompliantFile, "", "compliantFile", "A file holding the compliant resource, such as one mounted from a ConfigMap.")
+	s.String(&policyExpectMessage, "", "expectMessage", "Text that the denial of the violating resource must contain, such as the name of the policy.")
+	s.Duration(&policyMaxLatency, "", "maxLatency", "How long the API server can take to deny or admit each resource.")
+	return s
+}
+
+// runPolicyCheck creates the violating and the compliant resource as dry runs, so that nothing is persisted, and
+// expects the admission webhook of the policy engine to deny the first and admit the second within the maximum
+// latency.  A dead engine whose webhooks fail open admits the violating resource, and one whose webhooks fail closed
+// or are wedged denies or delays the compliant one.  How long each request took is measured.
+func runPolicyCheck(ctx context.Context) error {
+	violating, err := loadPolicyResource(policyViolating, policyViolatingFile, "violating")
+	if err != nil {
+		return err
+	}
+	compliant, err := loadPolicyResource(policyCompliant, policyCompliantFile, "compliant")
+	if err != nil {
+		return err
+	}
+
+	config, err := kubeClient.Config(kubeConfigFile)
+	if err != nil {
+		return fmt.Errorf("error creating kubernetes client config: %w", err)
+	}
+	dynamicClient, err := dynamic.NewForConfig(config)
+	if err != nil {
+		return fmt.Errorf("error creating dynamic kubernetes client: %w", err)
+	}
+	discoveryClient, err := discovery.NewDiscoveryClientForConfig(config)
+	if err != nil {
+		return fmt.Errorf("error creating discovery client: %w", err)
+	}
+	groupResources, err := restmapper.GetAPIGroupResources(discoveryClient)
+	if err != nil {
+		return fmt.Errorf("error discovering api resources: %w", err)
+	}
+	mapper := restmapper.NewDiscoveryRESTMapper(groupResources)
+
+	var errs []string
+	latency, err := dryRunCreate(dynamicClient, mapper, violating)
+	measure("violating_ms", float64(latency.Milliseconds()))
+	err = policyDenialOutcome(err, policyExpectMessage)
+	if err != nil {
+		errs = append(errs, err.Error())
+	}
+	if latency > policyMaxLatency {
+		errs = append(errs, fmt.Sprintf("the violating resource took %s to be denied", latency.Round(time.Millisecond)))
+	}
+
+	latency, err = dryRunCreate(dynamicClient, mapper, compliant)
+	measure("compliant_ms", float64(latency.Milliseconds()))
+	if err != nil {
+		errs = append(errs, "the compliant resource was not admitted: "+err.Error())
+	}
+	if latency > policyMaxLatency {
+		errs = append(errs, fmt.Sprintf("the compliant resource took %s to be admitted", latency.Round(time.Millisecond)))
+	}
+
+	if len(errs) > 0 {
+		return errors.New(strings.Join(errs, ", "))
+	}
+	log.Infoln("The policy engine denied the violating resource and admitted the compliant resource")
+	return nil
+}
+
+// loadPolicyResource reads a resource from its flag or the file it was given in.  Resources without a name are
+// given a generated one, so that dry runs do not conflict with existing resources.
+func loadPolicyResource(inline string, file string, which string) (*unstructured.Unstructured, error) {
+	manifest := []byte(inline)
+	if len(file) > 0 {
+		var err error
+		manifest, err = ioutil.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("error reading the %s resource from %s: %w", which, file, err)
+		}
+	}
+	if len(bytes.TrimSpace(manifest)) == 0 {
+		return nil, fmt.Errorf("--%s or --%sFile is required", which, which)
+	}
+
+	var object map[string]interface{}
+	err := yaml.Unmarshal(manifest, &object)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing the %s resource: %w", which, err)
+	}
+	resource := &unstructured.Unstructured{Object: object}
+	if len(resource.GetAPIVersion()) == 0 || len(resource.GetKind()) == 0 {
+		return nil, fmt.Errorf("the %s resource must have an apiVersion and kind", which)
+	}
+	if len(resource.GetName()) == 0 && len(resource.GetGenerateName()) == 0 {
+		resource.SetGenerateName("khcheck-policy-")
+	}
+	return resource, nil
+}
+
+// dryRunCreate creates a resource as a dry run, in the check namespace when it is namespaced and does not name a
+// namespace, and returns how long the API server took to answer
+func dryRunCreate(client dynamic.Interface, mapper meta.RESTMapper, resource *unstructured.Unstructured) (time.Duration, error) {
+	gvk := resource.GroupVersionKind()
+	mapping, err := mapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+	if err != nil {
+		return 0, fmt.Errorf("error finding the api resource of %s: %w", gvk, err)
+	}
+	resources := client.Resource(mapping.Resource)
+	options := metav1.CreateOptions{DryRun: []string{metav1.DryRunAll}}
+
+	started := time.Now()
+	if mapping.Scope.Name() == meta.RESTScopeNameNamespace {
+		ns := resource.GetNamespace()
+		if len(ns) == 0 {
+			ns = namespace
+		}
+		_, err = resources.Namespace(ns).Create(resource, options)
+	} else {
+		_, err = resources.Create(resource, options)
+	}
+	return time.Since(started), err
+}
+
+// policyDenialOutcome returns an error unless the creation of the violating resource was denied by an admission
+// webhook with a message that contains the expected message
+func policyDenialOutcome(err error, expectMessage string) error {
+	if err == nil {
+		return errors.New("the violating resource was admitted")
+	}
+	if !strings.Contains(err.Error(), "denied the request") || !strings.Contains(err.Error(), expectMessage) {
+		return fmt.Errorf("expected the violating resource to be denied by the policy engine but got: %w", err)
+	}
+	return nil
+}
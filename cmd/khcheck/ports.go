@@ -0,0 +1,182 @@
+// Copyright 2018 Comcast Cable Communications Management, LLC
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//     http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/integrii/flaggy"
+	log "github.com/sirupsen/logrus"
+)
+
+// the flags of the ports check
+var portsTargets string
+var portsTimeout = time.Second * 5
+
+// measurementNamePattern matches the characters of a target that are replaced to name its measurements
+var measurementNamePattern = regexp.MustCompile(`[^A-Za-z0-9]+`)
+
+// portTarget is a host and port that is probed over TCP or UDP
+type portTarget struct {
+	Name        string        // names the target in its measurements
+	Network     string        // tcp or udp
+	Host        string        // the host name or IP address of the target
+	Port        string        // the port of the target
+	Timeout     time.Duration // how long resolving and probing the target can take
+	Payload     string        // the datagram sent to UDP targets
+	ExpectReply bool          // fails UDP probes that get no reply in time
+}
+
+// portsSubcommand returns the subcommand of the ports check
+func portsSubcommand() *flaggy.Subcommand {
+	s := flaggy.NewSubcommand("ports")
+	s.Description = "Connect to TCP ports and probe UDP ports"
+	s.String(&portsTargets, "", "targets", "Comma separated targets such as tcp://postgres.db:5432 or udp://10.0.0.10:53?payload=ping.  Targets without a scheme use TCP.")
+	s.Duration(&portsTimeout, "", "timeout", "How long resolving and probing each target can take, unless the target sets its own timeout.")
+	return s
+}
+
+// runPortsCheck probes every target and fails if any of them can not be reached.  The time taken to resolve
+// and reach each target is measured.
+func runPortsCheck(ctx context.Context) error {
+	targets, err := parsePortTargets(portsTargets)
+	if err != nil {
+		return err
+	}
+
+	var errs []string
+	for _, t := range targets {
+		err = probePort(ctx, t)
+		if err != nil {
+			errs = append(errs, err.Error())
+			continue
+		}
+		log.Infoln("Reached", t.Network, "port", t.Port, "of", t.Host)
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("failed to reach ports: %s", strings.Join(errs, ", "))
+	}
+	return nil
+}
+
+// parsePortTargets parses a comma separated list of targets.  Targets are URLs whose scheme is the network, and
+// can set name, timeout, payload, and expectReply query parameters.
+func parsePortTargets(s string) ([]portTarget, error) {
+	var targets []portTarget
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if len(part) == 0 {
+			continue
+		}
+		if !strings.Contains(part, "://") {
+			part = "tcp://" + part
+		}
+		u, err := url.Parse(part)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing target %s: %w", part, err)
+		}
+		if u.Scheme != "tcp" && u.Scheme != "udp" {
+			return nil, errors.New("target " + part + " must use tcp or udp")
+		}
+		if len(u.Hostname()) == 0 || len(u.Port()) == 0 {
+			return nil, errors.New("target " + part + " must have a host and port")
+		}
+
+		query := u.Query()
+		t := portTarget{
+			Name:        query.Get("name"),
+			Network:     u.Scheme,
+			Host:        u.Hostname(),
+			Port:        u.Port(),
+			Timeout:     portsTimeout,
+			Payload:     query.Get("payload"),
+			ExpectReply: true,
+		}
+		if len(t.Name) == 0 {
+			t.Name = strings.Trim(measurementNamePattern.ReplaceAllString(t.Network+"_"+t.Host+"_"+t.Port, "_"), "_")
+		}
+		if timeout := query.Get("timeout"); len(timeout) > 0 {
+			t.Timeout, err = time.ParseDuration(timeout)
+			if err != nil {
+				return nil, fmt.Errorf("error parsing timeout of target %s: %w", part, err)
+			}
+		}
+		if expectReply := query.Get("expectReply"); len(expectReply) > 0 {
+			t.ExpectReply, err = strconv.ParseBool(expectReply)
+			if err != nil {
+				return nil, fmt.Errorf("error parsing expectReply of target %s: %w", part, err)
+			}
+		}
+		targets = append(targets, t)
+	}
+	if len(targets) == 0 {
+		return nil, errors.New("--targets is required")
+	}
+	return targets, nil
+}
+
+// probePort resolves the host of a target and probes its port.  TCP ports are reached when a connection is
+// accepted.  UDP ports are sent the payload of the target and are reached when they reply, or when they do
+// not refuse the datagram if no reply is expected.  The time to resolve the host, and to connect or get a
+// reply, is measured.
+func probePort(ctx context.Context, t portTarget) error {
+	ctx, cancel := context.WithTimeout(ctx, t.Timeout)
+	defer cancel()
+	address := net.JoinHostPort(t.Host, t.Port)
+
+	start := time.Now()
+	addresses, err := net.DefaultResolver.LookupHost(ctx, t.Host)
+	if err != nil {
+		return fmt.Errorf("error resolving %s: %w", t.Host, err)
+	}
+	measure(t.Name+"_dns_ms", float64(time.Since(start).Milliseconds()))
+
+	start = time.Now()
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, t.Network, net.JoinHostPort(addresses[0], t.Port))
+	if err != nil {
+		return fmt.Errorf("error connecting to %s %s: %w", t.Network, address, err)
+	}
+	defer conn.Close()
+	if t.Network == "tcp" {
+		measure(t.Name+"_connect_ms", float64(time.Since(start).Milliseconds()))
+		return nil
+	}
+
+	deadline, _ := ctx.Deadline()
+	err = conn.SetDeadline(deadline)
+	if err != nil {
+		return fmt.Errorf("error setting deadline of probe to %s: %w", address, err)
+	}
+	_, err = conn.Write([]byte(t.Payload))
+	if err != nil {
+		return fmt.Errorf("error sending probe to udp %s: %w", address, err)
+	}
+	_, err = conn.Read(make([]byte, 1500))
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() && !t.ExpectReply {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("no reply to probe of udp %s: %w", address, err)
+	}
+	measure(t.Name+"_reply_ms", float64(time.Since(start).Milliseconds()))
+	return nil
+}
@@ -0,0 +1,176 @@
+// Copyright 2018 Comcast Cable Communications Management, LLC
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//     http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/integrii/flaggy"
+	log "github.com/sirupsen/logrus"
+	apiv1 "k8s.io/api/core/v1"
+	k8sErrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// the flags of the quota check
+var quotaImage = "gcr.io/google-containers/pause:3.1"
+
+// the limits that the quota check sets in its sandbox namespace
+var quotaMaxCPU = resource.MustParse("200m")
+var quotaDefaultCPU = resource.MustParse("50m")
+var quotaDefaultMemory = resource.MustParse("32Mi")
+
+// quotaSubcommand returns the subcommand of the quota check
+func quotaSubcommand() *flaggy.Subcommand {
+	s := flaggy.NewSubcommand("quota")
+	s.Description = "Create a sandbox namespace with a resource quota and limit range and expect pods that exceed them to be rejected"
+	s.String(&quotaImage, "", "image", "The image of the pods created in the sandbox namespace.")
+	return s
+}
+
+// runQuotaCheck creates a sandbox namespace with a resource quota that allows one pod and a limit range that caps
+// the CPU limit of containers and sets their default requests and limits.  It expects a pod over the CPU cap to be
+// rejected, a pod without resources to be admitted with the defaults, and a second pod to be rejected by the quota.
+// Quotas and limit ranges are enforced by admission plugins that can be disabled without any other sign, so this
+// proves the policies are active.  How long the quota controller took to compute the quota is measured.
+func runQuotaCheck(ctx context.Context) error {
+	client, err := newKubeClient()
+	if err != nil {
+		return fmt.Errorf("error creating kubernetes client: %w", err)
+	}
+
+	name := resourceName("khcheck-quota")
+	labels := map[string]string{checkLabel: "quota"}
+	namespaces := client.CoreV1().Namespaces()
+	_, err = namespaces.Create(&apiv1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: name, Labels: labels}})
+	if err != nil {
+		return fmt.Errorf("error creating namespace %s: %w", name, err)
+	}
+	log.Infoln("Created sandbox namespace", name)
+	afterReport(func() {
+		deleteAndWait("namespace", name, func() error {
+			return namespaces.Delete(name, &metav1.DeleteOptions{})
+		}, func() error {
+			_, err := namespaces.Get(name, metav1.GetOptions{})
+			return err
+		})
+	})
+
+	limitRange := &apiv1.LimitRange{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Labels: labels},
+		Spec: apiv1.LimitRangeSpec{
+			Limits: []apiv1.LimitRangeItem{{
+				Type:           apiv1.LimitTypeContainer,
+				Max:            apiv1.ResourceList{apiv1.ResourceCPU: quotaMaxCPU},
+				Default:        apiv1.ResourceList{apiv1.ResourceCPU: quotaDefaultCPU, apiv1.ResourceMemory: quotaDefaultMemory},
+				DefaultRequest: apiv1.ResourceList{apiv1.ResourceCPU: quotaDefaultCPU, apiv1.ResourceMemory: quotaDefaultMemory},
+			}},
+		},
+	}
+	_, err = client.CoreV1().LimitRanges(name).Create(limitRange)
+	if err != nil {
+		return fmt.Errorf("error creating limit range %s: %w", name, err)
+	}
+
+	quota := &apiv1.ResourceQuota{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Labels: labels},
+		Spec: apiv1.ResourceQuotaSpec{
+			Hard: apiv1.ResourceList{apiv1.ResourcePods: resource.MustParse("1")},
+		},
+	}
+	quotas := client.CoreV1().ResourceQuotas(name)
+	_, err = quotas.Create(quota)
+	if err != nil {
+		return fmt.Errorf("error creating resource quota %s: %w", name, err)
+	}
+	created := time.Now()
+
+	// quota admission refuses every pod until the quota controller has computed the quota
+	err = poll(ctx, func() (bool, error) {
+		q, err := quotas.Get(name, metav1.GetOptions{})
+		if err != nil {
+			return false, err
+		}
+		_, ok := q.Status.Hard[apiv1.ResourcePods]
+		return ok, nil
+	})
+	if err != nil {
+		return fmt.Errorf("the quota controller did not compute resource quota %s: %w", name, err)
+	}
+	measure("quota_ready_ms", float64(time.Since(created).Milliseconds()))
+
+	pods := client.CoreV1().Pods(name)
+	over := quotaPod("over-limit")
+	over.Spec.Containers[0].Resources.Limits = apiv1.ResourceList{apiv1.ResourceCPU: resource.MustParse("1")}
+	_, err = pods.Create(over)
+	err = rejectionOutcome("a pod with a CPU limit over the limit range maximum", err, "maximum cpu usage")
+	if err != nil {
+		return err
+	}
+
+	admitted, err := pods.Create(quotaPod("within-quota"))
+	if err != nil {
+		return fmt.Errorf("error creating a pod within the resource quota and limit range: %w", err)
+	}
+	err = limitRangeDefaultsApplied(admitted)
+	if err != nil {
+		return err
+	}
+
+	_, err = pods.Create(quotaPod("over-quota"))
+	err = rejectionOutcome("a pod over the resource quota", err, "exceeded quota")
+	if err != nil {
+		return err
+	}
+	log.Infoln("The resource quota and limit range of namespace", name, "were enforced")
+	return nil
+}
+
+// quotaPod returns a pod without resources for the quota check to create in its sandbox namespace
+func quotaPod(name string) *apiv1.Pod {
+	return &apiv1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Labels: map[string]string{checkLabel: "quota"}},
+		Spec: apiv1.PodSpec{
+			Containers: []apiv1.Container{{Name: "main", Image: quotaImage}},
+		},
+	}
+}
+
+// rejectionOutcome returns an error unless creating a resource was forbidden with a message that contains reason
+func rejectionOutcome(what string, err error, reason string) error {
+	if err == nil {
+		return errors.New(what + " was admitted")
+	}
+	if !k8sErrors.IsForbidden(err) || !strings.Contains(err.Error(), reason) {
+		return fmt.Errorf("expected %s to be forbidden with %q but got: %w", what, reason, err)
+	}
+	return nil
+}
+
+// limitRangeDefaultsApplied returns an error unless the limit range set the default requests and limits of a pod
+// that was created without them
+func limitRangeDefaultsApplied(pod *apiv1.Pod) error {
+	resources := pod.Spec.Containers[0].Resources
+	for _, list := range []apiv1.ResourceList{resources.Requests, resources.Limits} {
+		cpu, memory := list[apiv1.ResourceCPU], list[apiv1.ResourceMemory]
+		if cpu.Cmp(quotaDefaultCPU) != 0 || memory.Cmp(quotaDefaultMemory) != 0 {
+			return fmt.Errorf("expected the limit range to default the resources of pod %s to %s CPU and %s memory but got requests %v and limits %v",
+				pod.Name, quotaDefaultCPU.String(), quotaDefaultMemory.String(), resources.Requests, resources.Limits)
+		}
+	}
+	return nil
+}
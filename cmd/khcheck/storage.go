@@ -0,0 +1,131 @@
+// Copyright 2018 Comcast Cable Communications Management, LLC
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//     http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/integrii/flaggy"
+	log "github.com/sirupsen/logrus"
+	apiv1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// the flags of the storage check
+var storageClass string
+var storageSize = "1Mi"
+var storageImage = "busybox:1.31"
+
+// storageSubcommand returns the subcommand of the storage check
+func storageSubcommand() *flaggy.Subcommand {
+	s := flaggy.NewSubcommand("storage")
+	s.Description = "Create a persistent volume claim and expect a pod to write to it and read back what it wrote"
+	s.String(&storageClass, "", "storageClass", "The storage class of the claim.  Defaults to the default storage class of the cluster.")
+	s.String(&storageSize, "", "size", "The size of the claim.")
+	s.String(&storageImage, "", "image", "The image of the pod that writes to the claim.  It must have a shell.")
+	return s
+}
+
+// runStorageCheck creates a persistent volume claim and a pod that writes a file to it and reads it back, waits
+// for the pod to succeed, and deletes both
+func runStorageCheck(ctx context.Context) error {
+	size, err := resource.ParseQuantity(storageSize)
+	if err != nil {
+		return fmt.Errorf("error parsing --size: %w", err)
+	}
+	client, err := newKubeClient()
+	if err != nil {
+		return fmt.Errorf("error creating kubernetes client: %w", err)
+	}
+
+	name := resourceName("khcheck-storage")
+	labels := map[string]string{checkLabel: "storage"}
+	claim := &apiv1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Labels: labels},
+		Spec: apiv1.PersistentVolumeClaimSpec{
+			AccessModes: []apiv1.PersistentVolumeAccessMode{apiv1.ReadWriteOnce},
+			Resources: apiv1.ResourceRequirements{
+				Requests: apiv1.ResourceList{apiv1.ResourceStorage: size},
+			},
+		},
+	}
+	if len(storageClass) > 0 {
+		claim.Spec.StorageClassName = &storageClass
+	}
+	claims := client.CoreV1().PersistentVolumeClaims(namespace)
+	_, err = claims.Create(claim)
+	if err != nil {
+		return fmt.Errorf("error creating persistent volume claim %s: %w", name, err)
+	}
+	log.Infoln("Created persistent volume claim", name, "in", namespace)
+	afterReport(func() {
+		deleteAndWait("persistent volume claim", name, func() error {
+			return claims.Delete(name, &metav1.DeleteOptions{})
+		}, func() error {
+			_, err := claims.Get(name, metav1.GetOptions{})
+			return err
+		})
+	})
+
+	// the pod writes the run's name to the volume and only succeeds if it reads the same name back
+	pod := &apiv1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Labels: labels},
+		Spec: apiv1.PodSpec{
+			RestartPolicy: apiv1.RestartPolicyNever,
+			Containers: []apiv1.Container{{
+				Name:         "main",
+				Image:        storageImage,
+				Command:      []string{"sh", "-c", `echo "$0" > /data/khcheck && sync && test "$(cat /data/khcheck)" = "$0"`, name},
+				VolumeMounts: []apiv1.VolumeMount{{Name: "data", MountPath: "/data"}},
+			}},
+			Volumes: []apiv1.Volume{{
+				Name: "data",
+				VolumeSource: apiv1.VolumeSource{
+					PersistentVolumeClaim: &apiv1.PersistentVolumeClaimVolumeSource{ClaimName: name},
+				},
+			}},
+		},
+	}
+	pods := client.CoreV1().Pods(namespace)
+	_, err = pods.Create(pod)
+	if err != nil {
+		return fmt.Errorf("error creating pod %s: %w", name, err)
+	}
+	afterReport(func() {
+		deleteAndWait("pod", name, func() error {
+			return pods.Delete(name, &metav1.DeleteOptions{})
+		}, func() error {
+			_, err := pods.Get(name, metav1.GetOptions{})
+			return err
+		})
+	})
+
+	var phase apiv1.PodPhase
+	err = poll(ctx, func() (bool, error) {
+		p, err := pods.Get(name, metav1.GetOptions{})
+		if err != nil {
+			return false, err
+		}
+		phase = p.Status.Phase
+		return phase == apiv1.PodSucceeded || phase == apiv1.PodFailed, nil
+	})
+	if err != nil {
+		return fmt.Errorf("pod %s writing to persistent volume claim %s did not finish and was %s: %w", name, name, phase, err)
+	}
+	if phase == apiv1.PodFailed {
+		return fmt.Errorf("pod %s failed to write to and read from persistent volume claim %s", name, name)
+	}
+	log.Infoln("Pod", name, "wrote to and read from persistent volume claim", name)
+	return nil
+}
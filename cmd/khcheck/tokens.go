@@ -0,0 +1,330 @@
+// Copyright 2018 Comcast Cable Communications Management, LLC
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//     http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/integrii/flaggy"
+	log "github.com/sirupsen/logrus"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// the flags of the tokens check
+var tokensServiceAccount = "khcheck-sa"
+var tokensAudience = "khcheck"
+var tokensIssuer string
+var tokensJWKSFromIssuer bool
+var tokensTokenFile string
+var tokensExpiration = time.Minute * 10
+
+// tokenLeeway is how far the clocks of the API server and the checker pod can differ when the times in a token
+// are checked
+const tokenLeeway = time.Minute
+
+// maxJWKSBody is the most of a key set that is read from the jwks_uri of the issuer
+const maxJWKSBody = 1024 * 1024
+
+// openIDConfiguration is the part of the OpenID discovery document of the cluster that the tokens check reads
+type openIDConfiguration struct {
+	Issuer  string `json:"issuer"`
+	JWKSURI string `json:"jwks_uri"`
+}
+
+// jsonWebKeySet is the set of public keys that service account tokens are signed with
+type jsonWebKeySet struct {
+	Keys []jsonWebKey `json:"keys"`
+}
+
+// jsonWebKey is an RSA or elliptic curve public key of a key set
+type jsonWebKey struct {
+	KeyType string `json:"kty"`
+	KeyID   string `json:"kid"`
+	N       string `json:"n"`
+	E       string `json:"e"`
+	Curve   string `json:"crv"`
+	X       string `json:"x"`
+	Y       string `json:"y"`
+}
+
+// tokenClaims are the claims of a service account token that the tokens check validates
+type tokenClaims struct {
+	Issuer    string          `json:"iss"`
+	Subject   string          `json:"sub"`
+	Audience  json.RawMessage `json:"aud"`
+	Expiry    int64           `json:"exp"`
+	NotBefore int64           `json:"nbf"`
+}
+
+// tokensSubcommand returns the subcommand of the tokens check
+func tokensSubcommand() *flaggy.Subcommand {
+	s := flaggy.NewSubcommand("tokens")
+	s.Description = "Request a service account token and expect it to verify against the cluster issuer and be accepted by TokenReview"
+	s.String(&tokensServiceAccount, "", "serviceAccount", "The service account in the check namespace that a token is requested for.")
+	s.String(&tokensAudience, "", "audience", "The audience of the requested token.")
+	s.String(&tokensIssuer, "", "issuer", "The issuer tokens must have.  Defaults to the issuer the cluster advertises.")
+	s.Bool(&tokensJWKSFromIssuer, "", "jwksFromIssuer", "Fetch the signing keys from the jwks_uri the cluster advertises, as an external OIDC consumer would, rather than from the API server.")
+	s.String(&tokensTokenFile, "", "tokenFile", "A projected service account token mounted into the checker pod that is also validated.  It must be issued for the audience.")
+	s.Duration(&tokensExpiration, "", "expiration", "How long the requested token is valid for.  The API server requires at least 10m.")
+	return s
+}
+
+// runTokensCheck requests a service account token, verifies its signature and claims with the issuer and signing
+// keys the cluster advertises through OpenID discovery, and has the API server review it.  Broken token signing,
+// such as a signing key that was rotated on some API servers but not others during a control plane upgrade,
+// fails one of these steps.  The time taken to issue and review the token is measured.
+func runTokensCheck(ctx context.Context) error {
+	client, err := newKubeClient()
+	if err != nil {
+		return fmt.Errorf("error creating kubernetes client: %w", err)
+	}
+
+	expiration := int64(tokensExpiration.Seconds())
+	start := time.Now()
+	request, err := client.CoreV1().ServiceAccounts(namespace).CreateToken(tokensServiceAccount, &authenticationv1.TokenRequest{
+		Spec: authenticationv1.TokenRequestSpec{Audiences: []string{tokensAudience}, ExpirationSeconds: &expiration},
+	})
+	if err != nil {
+		return fmt.Errorf("error requesting a token for service account %s/%s: %w", namespace, tokensServiceAccount, err)
+	}
+	measure("token_request_ms", float64(time.Since(start).Milliseconds()))
+
+	body, err := client.Discovery().RESTClient().Get().AbsPath("/.well-known/openid-configuration").Context(ctx).DoRaw()
+	if err != nil {
+		return fmt.Errorf("error getting the OpenID configuration of the cluster: %w", err)
+	}
+	var config openIDConfiguration
+	err = json.Unmarshal(body, &config)
+	if err != nil {
+		return fmt.Errorf("error parsing the OpenID configuration of the cluster: %w", err)
+	}
+	issuer := tokensIssuer
+	if len(issuer) == 0 {
+		issuer = config.Issuer
+	}
+	if config.Issuer != issuer {
+		return fmt.Errorf("the cluster advertises issuer %s instead of %s", config.Issuer, issuer)
+	}
+
+	keys, err := fetchJWKS(ctx, client, config.JWKSURI)
+	if err != nil {
+		return err
+	}
+
+	subject := "system:serviceaccount:" + namespace + ":" + tokensServiceAccount
+	var errs []string
+	err = verifyServiceAccountToken(request.Status.Token, keys, issuer, tokensAudience, subject, time.Now())
+	if err == nil {
+		err = reviewToken(client, request.Status.Token, subject)
+	}
+	if err != nil {
+		errs = append(errs, "requested token: "+err.Error())
+	}
+
+	if len(tokensTokenFile) > 0 {
+		token, err := ioutil.ReadFile(tokensTokenFile)
+		if err != nil {
+			return fmt.Errorf("error reading token from %s: %w", tokensTokenFile, err)
+		}
+		err = verifyServiceAccountToken(string(bytes.TrimSpace(token)), keys, issuer, tokensAudience, "", time.Now())
+		if err == nil {
+			err = reviewToken(client, string(bytes.TrimSpace(token)), "")
+		}
+		if err != nil {
+			errs = append(errs, "projected token: "+err.Error())
+		}
+	}
+
+	if len(errs) > 0 {
+		return errors.New(strings.Join(errs, ", "))
+	}
+	log.Infoln("Service account tokens issued by", issuer, "are valid")
+	return nil
+}
+
+// fetchJWKS returns the keys that service account tokens are signed with, from the API server or from the
+// jwks_uri the cluster advertises
+func fetchJWKS(ctx context.Context, client kubernetes.Interface, jwksURI string) (jsonWebKeySet, error) {
+	var keys jsonWebKeySet
+	var body []byte
+	var err error
+	if tokensJWKSFromIssuer {
+		body, err = fetchURL(ctx, jwksURI)
+	} else {
+		body, err = client.Discovery().RESTClient().Get().AbsPath("/openid/v1/jwks").Context(ctx).DoRaw()
+	}
+	if err != nil {
+		return keys, fmt.Errorf("error getting the signing keys of the cluster: %w", err)
+	}
+	err = json.Unmarshal(body, &keys)
+	if err != nil {
+		return keys, fmt.Errorf("error parsing the signing keys of the cluster: %w", err)
+	}
+	if len(keys.Keys) == 0 {
+		return keys, errors.New("the cluster advertises no signing keys")
+	}
+	return keys, nil
+}
+
+// fetchURL returns the body of a URL that must respond with 200
+func fetchURL(ctx context.Context, url string) ([]byte, error) {
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	client := &http.Client{Timeout: time.Second * 10}
+	resp, err := client.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s returned %d", url, resp.StatusCode)
+	}
+	return ioutil.ReadAll(io.LimitReader(resp.Body, maxJWKSBody))
+}
+
+// reviewToken has the API server review a token and returns an error unless it is authenticated for the audience,
+// as the subject when one is given
+func reviewToken(client kubernetes.Interface, token string, subject string) error {
+	start := time.Now()
+	review, err := client.AuthenticationV1().TokenReviews().Create(&authenticationv1.TokenReview{
+		Spec: authenticationv1.TokenReviewSpec{Token: token, Audiences: []string{tokensAudience}},
+	})
+	if err != nil {
+		return fmt.Errorf("error reviewing token: %w", err)
+	}
+	measure("token_review_ms", float64(time.Since(start).Milliseconds()))
+	if !review.Status.Authenticated {
+		return errors.New("token was not authenticated by TokenReview: " + review.Status.Error)
+	}
+	if len(subject) > 0 && review.Status.User.Username != subject {
+		return fmt.Errorf("TokenReview authenticated the token as %s instead of %s", review.Status.User.Username, subject)
+	}
+	return nil
+}
+
+// verifyServiceAccountToken returns an error unless a token is signed by one of the keys and has the issuer and
+// audience, is valid at the given time, and belongs to the subject when one is given
+func verifyServiceAccountToken(token string, keys jsonWebKeySet, issuer string, audience string, subject string, now time.Time) error {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return errors.New("token is not a JWT")
+	}
+	var header struct {
+		Algorithm string `json:"alg"`
+		KeyID     string `json:"kid"`
+	}
+	err := decodeJWTPart(parts[0], &header)
+	if err != nil {
+		return fmt.Errorf("error parsing token header: %w", err)
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return fmt.Errorf("error decoding token signature: %w", err)
+	}
+
+	var key *jsonWebKey
+	for i, k := range keys.Keys {
+		if k.KeyID == header.KeyID || (len(header.KeyID) == 0 && len(keys.Keys) == 1) {
+			key = &keys.Keys[i]
+		}
+	}
+	if key == nil {
+		return fmt.Errorf("token is signed with key %q, which is not one of the %d signing keys of the cluster", header.KeyID, len(keys.Keys))
+	}
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	switch header.Algorithm {
+	case "RS256":
+		if key.KeyType != "RSA" {
+			return errors.New("token is signed with RS256 but key " + key.KeyID + " is not an RSA key")
+		}
+		n, errN := base64.RawURLEncoding.DecodeString(key.N)
+		e, errE := base64.RawURLEncoding.DecodeString(key.E)
+		if errN != nil || errE != nil {
+			return errors.New("signing key " + key.KeyID + " is not a valid RSA key")
+		}
+		public := &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+		if rsa.VerifyPKCS1v15(public, crypto.SHA256, digest[:], signature) != nil {
+			return errors.New("token signature does not verify with signing key " + key.KeyID)
+		}
+	case "ES256":
+		if key.KeyType != "EC" || key.Curve != "P-256" {
+			return errors.New("token is signed with ES256 but key " + key.KeyID + " is not a P-256 key")
+		}
+		x, errX := base64.RawURLEncoding.DecodeString(key.X)
+		y, errY := base64.RawURLEncoding.DecodeString(key.Y)
+		if errX != nil || errY != nil || len(signature) != 64 {
+			return errors.New("token signature or signing key " + key.KeyID + " is not a valid P-256 signature or key")
+		}
+		public := &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		if !ecdsa.Verify(public, digest[:], new(big.Int).SetBytes(signature[:32]), new(big.Int).SetBytes(signature[32:])) {
+			return errors.New("token signature does not verify with signing key " + key.KeyID)
+		}
+	default:
+		return errors.New("token is signed with unsupported algorithm " + header.Algorithm)
+	}
+
+	var claims tokenClaims
+	err = decodeJWTPart(parts[1], &claims)
+	if err != nil {
+		return fmt.Errorf("error parsing token claims: %w", err)
+	}
+	if claims.Issuer != issuer {
+		return fmt.Errorf("token has issuer %s instead of %s", claims.Issuer, issuer)
+	}
+	if len(subject) > 0 && claims.Subject != subject {
+		return fmt.Errorf("token has subject %s instead of %s", claims.Subject, subject)
+	}
+	audiences := []string{}
+	if json.Unmarshal(claims.Audience, &audiences) != nil {
+		var single string
+		json.Unmarshal(claims.Audience, &single)
+		audiences = []string{single}
+	}
+	if !containsTrimmed(audiences, audience) {
+		return fmt.Errorf("token has audiences %v instead of %s", audiences, audience)
+	}
+	if now.Add(-tokenLeeway).After(time.Unix(claims.Expiry, 0)) {
+		return fmt.Errorf("token expired at %s", time.Unix(claims.Expiry, 0).Format(time.RFC3339))
+	}
+	if claims.NotBefore > 0 && now.Add(tokenLeeway).Before(time.Unix(claims.NotBefore, 0)) {
+		return fmt.Errorf("token is not valid until %s", time.Unix(claims.NotBefore, 0).Format(time.RFC3339))
+	}
+	return nil
+}
+
+// decodeJWTPart decodes the base64 encoded JSON of the header or claims of a JWT
+func decodeJWTPart(part string, v interface{}) error {
+	b, err := base64.RawURLEncoding.DecodeString(part)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, v)
+}
@@ -0,0 +1,334 @@
+// Copyright 2018 Comcast Cable Communications Management, LLC
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//     http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/cookiejar"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ghodss/yaml"
+	"github.com/integrii/flaggy"
+	log "github.com/sirupsen/logrus"
+)
+
+// the flags of the transaction check
+var transactionSteps string
+var transactionStepsFile string
+var transactionRequestTimeout = time.Second * 10
+
+// maxTransactionBody is the most of each response body that is read for assertions and captures
+const maxTransactionBody = 1024 * 1024
+
+// variablePattern matches the ${name} references to variables in the request and expected response of a step
+var variablePattern = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+// Transaction is a sequence of HTTP requests made in order, such as logging in, fetching a page, and posting a
+// form.  Cookies are kept between steps, and values captured from the response of one step can be used by the
+// steps after it.
+type Transaction struct {
+	Steps []TransactionStep `json:"steps"`
+}
+
+// TransactionStep is one request of a transaction and what its response must look like
+type TransactionStep struct {
+	Name               string             `json:"name"`               // names the step in errors and in its latency measurement
+	Method             string             `json:"method"`             // the method of the request.  Defaults to GET.
+	URL                string             `json:"url"`                // the http or https URL that is requested
+	Headers            map[string]string  `json:"headers"`            // headers sent with the request
+	Body               string             `json:"body"`               // the body sent with the request
+	ExpectStatus       int                `json:"expectStatus"`       // the status code the response must have.  Defaults to 200.
+	ExpectBodyContains string             `json:"expectBodyContains"` // text the body of the response must contain
+	ExpectJSON         map[string]string  `json:"expectJSON"`         // the values that paths into the JSON body of the response must have
+	Capture            map[string]Capture `json:"capture"`            // values of the response saved as variables for later steps
+}
+
+// Capture saves a value of a response as a variable.  Exactly one of its fields is set.
+type Capture struct {
+	JSONPath string `json:"jsonPath"` // a dot separated path into the JSON body, such as data.items.0.id
+	Header   string `json:"header"`   // the name of a response header
+	Regex    string `json:"regex"`    // a regular expression matched against the body.  Its first group is captured.
+}
+
+// transactionSubcommand returns the subcommand of the transaction check
+func transactionSubcommand() *flaggy.Subcommand {
+	s := flaggy.NewSubcommand("transaction")
+	s.Description = "Make a sequence of HTTP requests, passing values captured from each response to the next"
+	s.String(&transactionSteps, "", "steps", "The YAML or JSON transaction to run.")
+	s.String(&transactionStepsFile, "", "stepsFile", "A file holding the YAML or JSON transaction to run, such as one mounted from a ConfigMap.")
+	s.Duration(&transactionRequestTimeout, "", "requestTimeout", "How long each request can take.")
+	return s
+}
+
+// runTransactionCheck runs every step of the transaction in order and fails on the first step whose response is
+// not what the step expects.  The latency of every step that completes is measured.
+func runTransactionCheck(ctx context.Context) error {
+	t, err := loadTransaction()
+	if err != nil {
+		return err
+	}
+
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		return fmt.Errorf("error creating cookie jar: %w", err)
+	}
+	client := &http.Client{Jar: jar}
+	variables := make(map[string]string)
+
+	start := time.Now()
+	for i, step := range t.Steps {
+		if len(step.Name) == 0 {
+			step.Name = "step" + strconv.Itoa(i+1)
+		}
+		stepStart := time.Now()
+		err = runTransactionStep(ctx, client, step, variables)
+		if err != nil {
+			return fmt.Errorf("step %s failed: %w", step.Name, err)
+		}
+		measure(step.Name+"_ms", float64(time.Since(stepStart).Milliseconds()))
+		log.Infoln("Transaction step", step.Name, "succeeded")
+	}
+	measure("transaction_ms", float64(time.Since(start).Milliseconds()))
+	return nil
+}
+
+// loadTransaction reads the transaction from the steps flag or the file it was given in
+func loadTransaction() (Transaction, error) {
+	var t Transaction
+	steps := []byte(transactionSteps)
+	if len(transactionStepsFile) > 0 {
+		var err error
+		steps, err = ioutil.ReadFile(transactionStepsFile)
+		if err != nil {
+			return t, fmt.Errorf("error reading transaction from %s: %w", transactionStepsFile, err)
+		}
+	}
+	if len(bytes.TrimSpace(steps)) == 0 {
+		return t, errors.New("--steps or --stepsFile is required")
+	}
+
+	err := yaml.Unmarshal(steps, &t)
+	if err != nil {
+		return t, fmt.Errorf("error parsing transaction: %w", err)
+	}
+	if len(t.Steps) == 0 {
+		return t, errors.New("the transaction has no steps")
+	}
+	for i, step := range t.Steps {
+		if len(step.URL) == 0 {
+			return t, fmt.Errorf("step %d must have a url", i+1)
+		}
+		for name, c := range step.Capture {
+			if countSet(c.JSONPath, c.Header, c.Regex) != 1 {
+				return t, fmt.Errorf("capture %s of step %d must set exactly one of jsonPath, header or regex", name, i+1)
+			}
+		}
+	}
+	return t, nil
+}
+
+// runTransactionStep makes the request of a step, checks its response, and captures variables from it
+func runTransactionStep(ctx context.Context, client *http.Client, step TransactionStep, variables map[string]string) error {
+	method := step.Method
+	if len(method) == 0 {
+		method = http.MethodGet
+	}
+	url, err := expandVariables(step.URL, variables)
+	if err != nil {
+		return err
+	}
+	if !strings.HasPrefix(url, "http://") && !strings.HasPrefix(url, "https://") {
+		return errors.New(url + " is not an http or https URL")
+	}
+	body, err := expandVariables(step.Body, variables)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, transactionRequestTimeout)
+	defer cancel()
+	req, err := http.NewRequest(method, url, strings.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("error creating %s request to %s: %w", method, url, err)
+	}
+	for k, v := range step.Headers {
+		v, err = expandVariables(v, variables)
+		if err != nil {
+			return err
+		}
+		req.Header.Set(k, v)
+	}
+	resp, err := client.Do(req.WithContext(ctx))
+	if err != nil {
+		return fmt.Errorf("error making %s request to %s: %w", method, url, err)
+	}
+	defer resp.Body.Close()
+	respBody, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxTransactionBody))
+	if err != nil {
+		return fmt.Errorf("error reading response of %s request to %s: %w", method, url, err)
+	}
+
+	expectStatus := step.ExpectStatus
+	if expectStatus == 0 {
+		expectStatus = http.StatusOK
+	}
+	if resp.StatusCode != expectStatus {
+		return fmt.Errorf("%s request to %s returned %d instead of %d", method, url, resp.StatusCode, expectStatus)
+	}
+	contains, err := expandVariables(step.ExpectBodyContains, variables)
+	if err != nil {
+		return err
+	}
+	if len(contains) > 0 && !bytes.Contains(respBody, []byte(contains)) {
+		return fmt.Errorf("response of %s request to %s does not contain %q", method, url, contains)
+	}
+
+	// the JSON body is only parsed when a step looks into it
+	var doc interface{}
+	needsJSON := len(step.ExpectJSON) > 0
+	for _, c := range step.Capture {
+		needsJSON = needsJSON || len(c.JSONPath) > 0
+	}
+	if needsJSON {
+		decoder := json.NewDecoder(bytes.NewReader(respBody))
+		decoder.UseNumber()
+		err = decoder.Decode(&doc)
+		if err != nil {
+			return fmt.Errorf("response of %s request to %s is not JSON: %w", method, url, err)
+		}
+	}
+	for path, want := range step.ExpectJSON {
+		want, err := expandVariables(want, variables)
+		if err != nil {
+			return err
+		}
+		got, err := jsonPathValue(doc, path)
+		if err != nil {
+			return err
+		}
+		if got != want {
+			return fmt.Errorf("%s of the response is %q instead of %q", path, got, want)
+		}
+	}
+
+	for name, c := range step.Capture {
+		var value string
+		switch {
+		case len(c.JSONPath) > 0:
+			value, err = jsonPathValue(doc, c.JSONPath)
+			if err != nil {
+				return err
+			}
+		case len(c.Header) > 0:
+			value = resp.Header.Get(c.Header)
+			if len(value) == 0 {
+				return fmt.Errorf("response has no %s header to capture as %s", c.Header, name)
+			}
+		default:
+			re, err := regexp.Compile(c.Regex)
+			if err != nil {
+				return fmt.Errorf("error parsing regex of capture %s: %w", name, err)
+			}
+			match := re.FindSubmatch(respBody)
+			if match == nil {
+				return fmt.Errorf("response does not match the regex of capture %s", name)
+			}
+			value = string(match[0])
+			if len(match) > 1 {
+				value = string(match[1])
+			}
+		}
+		variables[name] = value
+	}
+	return nil
+}
+
+// expandVariables replaces the ${name} references in s with captured variables, or with environment variables
+// such as those injected from secrets when no variable of that name was captured
+func expandVariables(s string, variables map[string]string) (string, error) {
+	var missing []string
+	expanded := variablePattern.ReplaceAllStringFunc(s, func(ref string) string {
+		name := variablePattern.FindStringSubmatch(ref)[1]
+		if v, ok := variables[name]; ok {
+			return v
+		}
+		if v, ok := os.LookupEnv(name); ok {
+			return v
+		}
+		missing = append(missing, name)
+		return ref
+	})
+	if len(missing) > 0 {
+		return "", errors.New("undefined variables " + strings.Join(missing, ", "))
+	}
+	return expanded, nil
+}
+
+// jsonPathValue returns the value at a dot separated path into a JSON document as a string.  Numeric path
+// elements index into arrays.  Objects and arrays are returned as JSON.
+func jsonPathValue(doc interface{}, path string) (string, error) {
+	v := doc
+	for _, key := range strings.Split(path, ".") {
+		switch node := v.(type) {
+		case map[string]interface{}:
+			child, ok := node[key]
+			if !ok {
+				return "", fmt.Errorf("response has no %s", path)
+			}
+			v = child
+		case []interface{}:
+			i, err := strconv.Atoi(key)
+			if err != nil || i < 0 || i >= len(node) {
+				return "", fmt.Errorf("response has no %s", path)
+			}
+			v = node[i]
+		default:
+			return "", fmt.Errorf("response has no %s", path)
+		}
+	}
+
+	switch value := v.(type) {
+	case string:
+		return value, nil
+	case json.Number:
+		return value.String(), nil
+	case nil:
+		return "null", nil
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return "", fmt.Errorf("error formatting %s of the response: %w", path, err)
+	}
+	return string(b), nil
+}
+
+// countSet returns how many of the strings are not blank
+func countSet(values ...string) int {
+	var n int
+	for _, v := range values {
+		if len(v) > 0 {
+			n++
+		}
+	}
+	return n
+}
@@ -0,0 +1,275 @@
+// Copyright 2018 Comcast Cable Communications Management, LLC
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//     http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/integrii/flaggy"
+	log "github.com/sirupsen/logrus"
+	admissionv1beta1 "k8s.io/api/admissionregistration/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// the flags of the webhooks check
+var webhooksNames string
+var webhooksAllFailurePolicies bool
+var webhooksSkipAPIServices bool
+var webhooksMinCertValidity = time.Hour * 24 * 7
+var webhooksDialTimeout = time.Second * 5
+
+// apiServicesPath is the path that aggregated API services are listed from
+const apiServicesPath = "/apis/apiregistration.k8s.io/v1/apiservices"
+
+// apiServiceList is the part of a list of APIService resources that the webhooks check reads
+type apiServiceList struct {
+	Items []apiService `json:"items"`
+}
+
+// apiService is the part of an APIService resource that the webhooks check reads
+type apiService struct {
+	Metadata metav1.ObjectMeta `json:"metadata"`
+	Spec     struct {
+		Service *struct {
+			Namespace string `json:"namespace"`
+			Name      string `json:"name"`
+			Port      *int32 `json:"port"`
+		} `json:"service"`
+		CABundle              []byte `json:"caBundle"`
+		InsecureSkipTLSVerify bool   `json:"insecureSkipTLSVerify"`
+	} `json:"spec"`
+	Status struct {
+		Conditions []struct {
+			Type    string `json:"type"`
+			Status  string `json:"status"`
+			Reason  string `json:"reason"`
+			Message string `json:"message"`
+		} `json:"conditions"`
+	} `json:"status"`
+}
+
+// webhooksSubcommand returns the subcommand of the webhooks check
+func webhooksSubcommand() *flaggy.Subcommand {
+	s := flaggy.NewSubcommand("webhooks")
+	s.Description = "Expect admission webhooks and aggregated API services to be available with valid certificates"
+	s.String(&webhooksNames, "", "webhooks", "Comma separated names of the webhooks that are checked.  Defaults to every webhook.")
+	s.Bool(&webhooksAllFailurePolicies, "", "allFailurePolicies", "Set to true to also check webhooks that are ignored when they fail, which do not block requests.")
+	s.Bool(&webhooksSkipAPIServices, "", "skipAPIServices", "Set to true to skip checking aggregated API services.")
+	s.Duration(&webhooksMinCertValidity, "", "minCertValidity", "How long the certificates of webhooks and API services must stay valid for.")
+	s.Duration(&webhooksDialTimeout, "", "dialTimeout", "How long connecting to each webhook or API service can take.")
+	return s
+}
+
+// runWebhooksCheck checks every admission webhook that blocks requests when it fails, and every aggregated API
+// service.  A broken webhook blocks the requests it intercepts across the whole cluster, which often shows up as
+// unrelated deploys failing.
+func runWebhooksCheck(ctx context.Context) error {
+	client, err := newKubeClient()
+	if err != nil {
+		return fmt.Errorf("error creating kubernetes client: %w", err)
+	}
+
+	var errs []string
+	validating, err := client.AdmissionregistrationV1beta1().ValidatingWebhookConfigurations().List(metav1.ListOptions{})
+	if err != nil {
+		return fmt.Errorf("error listing validating webhook configurations: %w", err)
+	}
+	for _, config := range validating.Items {
+		for _, w := range config.Webhooks {
+			if shouldCheckWebhook(w.Name, w.FailurePolicy) {
+				errs = append(errs, checkWebhook(client, w.Name, w.ClientConfig)...)
+			}
+		}
+	}
+	mutating, err := client.AdmissionregistrationV1beta1().MutatingWebhookConfigurations().List(metav1.ListOptions{})
+	if err != nil {
+		return fmt.Errorf("error listing mutating webhook configurations: %w", err)
+	}
+	for _, config := range mutating.Items {
+		for _, w := range config.Webhooks {
+			if shouldCheckWebhook(w.Name, w.FailurePolicy) {
+				errs = append(errs, checkWebhook(client, w.Name, w.ClientConfig)...)
+			}
+		}
+	}
+
+	if !webhooksSkipAPIServices {
+		body, err := client.Discovery().RESTClient().Get().AbsPath(apiServicesPath).Context(ctx).DoRaw()
+		if err != nil {
+			return fmt.Errorf("error listing API services: %w", err)
+		}
+		var apiServices apiServiceList
+		err = json.Unmarshal(body, &apiServices)
+		if err != nil {
+			return fmt.Errorf("error parsing API services: %w", err)
+		}
+		for _, s := range apiServices.Items {
+			errs = append(errs, checkAPIService(s)...)
+		}
+	}
+
+	if len(errs) > 0 {
+		return errors.New(strings.Join(errs, ", "))
+	}
+	return nil
+}
+
+// shouldCheckWebhook returns true for webhooks that were chosen by name and block requests when they fail.
+// Webhooks without a failure policy are ignored when they fail.
+func shouldCheckWebhook(name string, policy *admissionv1beta1.FailurePolicyType) bool {
+	if len(strings.TrimSpace(webhooksNames)) > 0 && !containsTrimmed(strings.Split(webhooksNames, ","), name) {
+		return false
+	}
+	return webhooksAllFailurePolicies || (policy != nil && *policy == admissionv1beta1.Fail)
+}
+
+// checkWebhook returns an error for each way the service or URL of a webhook is not available or presents a
+// certificate that can not be trusted with the webhook's CA bundle
+func checkWebhook(client kubernetes.Interface, name string, config admissionv1beta1.WebhookClientConfig) []string {
+	if config.Service != nil {
+		ref := config.Service.Namespace + "/" + config.Service.Name
+		err := checkServiceEndpoints(client, config.Service.Namespace, config.Service.Name)
+		if err != nil {
+			return []string{"webhook " + name + " service " + ref + " " + err.Error()}
+		}
+		serverName := config.Service.Name + "." + config.Service.Namespace + ".svc"
+		err = verifyServingCertificate(net.JoinHostPort(serverName, "443"), serverName, config.CABundle)
+		if err != nil {
+			return []string{"webhook " + name + ": " + err.Error()}
+		}
+		log.Infoln("Webhook", name, "service", ref, "is available")
+		return nil
+	}
+
+	if config.URL == nil {
+		return []string{"webhook " + name + " has no service or url"}
+	}
+	u, err := url.Parse(*config.URL)
+	if err != nil {
+		return []string{"webhook " + name + " has an invalid url: " + err.Error()}
+	}
+	port := u.Port()
+	if len(port) == 0 {
+		port = "443"
+	}
+	err = verifyServingCertificate(net.JoinHostPort(u.Hostname(), port), u.Hostname(), config.CABundle)
+	if err != nil {
+		return []string{"webhook " + name + ": " + err.Error()}
+	}
+	log.Infoln("Webhook", name, "url", *config.URL, "is available")
+	return nil
+}
+
+// checkAPIService returns an error for each way an aggregated API service is not available or presents a
+// certificate that can not be trusted with its CA bundle.  API services served by the API server itself have
+// no service and are skipped.
+func checkAPIService(s apiService) []string {
+	name := s.Metadata.Name
+	if s.Spec.Service == nil {
+		return nil
+	}
+
+	available := false
+	var errs []string
+	for _, c := range s.Status.Conditions {
+		if c.Type != "Available" {
+			continue
+		}
+		available = c.Status == "True"
+		if !available {
+			errs = append(errs, "API service "+name+" is not available: "+c.Reason+": "+c.Message)
+		}
+	}
+	if !available && len(errs) == 0 {
+		errs = append(errs, "API service "+name+" has no Available condition")
+	}
+
+	if !s.Spec.InsecureSkipTLSVerify && len(s.Spec.CABundle) > 0 {
+		port := "443"
+		if s.Spec.Service.Port != nil {
+			port = strconv.Itoa(int(*s.Spec.Service.Port))
+		}
+		serverName := s.Spec.Service.Name + "." + s.Spec.Service.Namespace + ".svc"
+		err := verifyServingCertificate(net.JoinHostPort(serverName, port), serverName, s.Spec.CABundle)
+		if err != nil {
+			errs = append(errs, "API service "+name+": "+err.Error())
+		}
+	}
+	if len(errs) == 0 {
+		log.Infoln("API service", name, "is available")
+	}
+	return errs
+}
+
+// checkServiceEndpoints returns an error unless a service has at least one ready endpoint
+func checkServiceEndpoints(client kubernetes.Interface, namespace string, name string) error {
+	endpoints, err := client.CoreV1().Endpoints(namespace).Get(name, metav1.GetOptions{})
+	if err != nil {
+		return fmt.Errorf("has no endpoints: %w", err)
+	}
+	for _, subset := range endpoints.Subsets {
+		if len(subset.Addresses) > 0 {
+			return nil
+		}
+	}
+	return errors.New("has no ready endpoints")
+}
+
+// verifyServingCertificate connects to an address and returns an error unless the certificate it presents for
+// serverName is trusted by the CA bundle and stays valid for the minimum certificate validity.  The system roots
+// are trusted when the CA bundle is empty.
+func verifyServingCertificate(address string, serverName string, caBundle []byte) error {
+	config := &tls.Config{ServerName: serverName}
+	if len(caBundle) > 0 {
+		config.RootCAs = x509.NewCertPool()
+		if !config.RootCAs.AppendCertsFromPEM(caBundle) {
+			return errors.New("caBundle holds no PEM encoded certificates")
+		}
+	}
+
+	conn, err := tls.DialWithDialer(&net.Dialer{Timeout: webhooksDialTimeout}, "tcp", address, config)
+	if err != nil {
+		return fmt.Errorf("error verifying certificate of %s: %w", address, err)
+	}
+	defer conn.Close()
+
+	// the chain expires when its first certificate does, which may be the CA rather than the serving certificate
+	for _, chain := range conn.ConnectionState().VerifiedChains {
+		for _, cert := range chain {
+			if time.Until(cert.NotAfter) < webhooksMinCertValidity {
+				return fmt.Errorf("certificate %s presented by %s expires at %s", cert.Subject.CommonName, address, cert.NotAfter.Format(time.RFC3339))
+			}
+		}
+	}
+	return nil
+}
+
+// containsTrimmed returns true if the list holds the value once whitespace is trimmed from its entries
+func containsTrimmed(list []string, value string) bool {
+	for _, v := range list {
+		if strings.TrimSpace(v) == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,276 @@
+// Copyright 2018 Comcast Cable Communications Management, LLC
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//     http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/ghodss/yaml"
+
+	"github.com/Comcast/kuberhealthy/v2/pkg/khcheckcrd"
+)
+
+// khcheckImage is the image of the common checks library that imported probes run with
+const khcheckImage = "quay.io/comcast/khcheck:1.0.0"
+
+// maxCheckNameLength keeps the names of imported checks short enough to be used in the names of their pods
+const maxCheckNameLength = 63
+
+// the flags of the import-blackbox subcommand
+var blackboxConfigFile string
+var blackboxTargetsFile string
+var blackboxNamespace string
+
+// checkNamePattern matches the runs of characters that can not be used in check names
+var checkNamePattern = regexp.MustCompile(`[^a-z0-9]+`)
+
+// blackboxConfig is the configuration of a blackbox exporter, which defines the modules that probe targets
+type blackboxConfig struct {
+	Modules map[string]blackboxModule `json:"modules"`
+}
+
+// blackboxModule is a blackbox exporter module.  The settings of each prober are kept as they were read so that
+// the settings that have no equivalent in the imported check can be reported.
+type blackboxModule struct {
+	Prober  string                 `json:"prober"`
+	Timeout string                 `json:"timeout,omitempty"`
+	HTTP    map[string]interface{} `json:"http,omitempty"`
+	TCP     map[string]interface{} `json:"tcp,omitempty"`
+	DNS     map[string]interface{} `json:"dns,omitempty"`
+}
+
+// prometheusConfig is the part of a Prometheus configuration that lists the targets probed by blackbox exporters
+type prometheusConfig struct {
+	Global struct {
+		ScrapeInterval string `json:"scrape_interval,omitempty"`
+	} `json:"global"`
+	ScrapeConfigs []scrapeConfig `json:"scrape_configs"`
+}
+
+// scrapeConfig is a Prometheus scrape job.  Jobs that scrape the /probe path of a blackbox exporter name their
+// module in their params and list their targets in their static configs.
+type scrapeConfig struct {
+	JobName        string              `json:"job_name"`
+	ScrapeInterval string              `json:"scrape_interval,omitempty"`
+	MetricsPath    string              `json:"metrics_path,omitempty"`
+	Params         map[string][]string `json:"params,omitempty"`
+	StaticConfigs  []struct {
+		Targets []string `json:"targets"`
+	} `json:"static_configs,omitempty"`
+}
+
+// importBlackboxChecks prints a khcheck for every target probed in the Prometheus configuration with a module of
+// the blackbox exporter configuration
+func importBlackboxChecks() error {
+	if len(blackboxConfigFile) == 0 || len(blackboxTargetsFile) == 0 {
+		return errors.New("both the blackbox exporter configuration and the Prometheus configuration with its targets must be given")
+	}
+	var config blackboxConfig
+	err := readYAMLFile(blackboxConfigFile, &config)
+	if err != nil {
+		return err
+	}
+	var prometheus prometheusConfig
+	err = readYAMLFile(blackboxTargetsFile, &prometheus)
+	if err != nil {
+		return err
+	}
+
+	checks, warnings, err := importBlackbox(config, prometheus, blackboxNamespace)
+	for _, w := range warnings {
+		fmt.Fprintln(os.Stderr, "Warning:", w)
+	}
+	if err != nil {
+		return err
+	}
+	return printManifests(checks)
+}
+
+// readYAMLFile reads a YAML or JSON file into v
+func readYAMLFile(path string, v interface{}) error {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("error reading %s: %w", path, err)
+	}
+	err = yaml.Unmarshal(b, v)
+	if err != nil {
+		return fmt.Errorf("error parsing %s: %w", path, err)
+	}
+	return nil
+}
+
+// importBlackbox creates a khcheck for every target of the blackbox exporter jobs of a Prometheus configuration.
+// HTTP, TCP, and DNS probes become http, ports, and dns checks of the common checks library, which run at the
+// scrape interval of their job.  Probes and module settings that can not be imported are returned as warnings.
+func importBlackbox(config blackboxConfig, prometheus prometheusConfig, namespace string) ([]*khcheckcrd.KuberhealthyCheck, []string, error) {
+	var checks []*khcheckcrd.KuberhealthyCheck
+	var warnings []string
+	names := make(map[string]bool)
+
+	warnedModules := make(map[string]bool)
+	for _, job := range prometheus.ScrapeConfigs {
+		if job.MetricsPath != "/probe" || len(job.Params["module"]) == 0 {
+			continue
+		}
+		moduleName := job.Params["module"][0]
+		module, ok := config.Modules[moduleName]
+		if !ok {
+			warnings = append(warnings, "job "+job.JobName+" uses module "+moduleName+", which is not in the blackbox exporter configuration")
+			continue
+		}
+		if len(job.StaticConfigs) == 0 {
+			warnings = append(warnings, "job "+job.JobName+" has no static targets and was skipped")
+			continue
+		}
+		if !warnedModules[moduleName] {
+			warnedModules[moduleName] = true
+			for _, option := range unsupportedModuleOptions(module) {
+				warnings = append(warnings, "module "+moduleName+" sets "+option+", which the imported checks do not support")
+			}
+		}
+
+		interval := job.ScrapeInterval
+		if len(interval) == 0 {
+			interval = prometheus.Global.ScrapeInterval
+		}
+		for _, static := range job.StaticConfigs {
+			for _, target := range static.Targets {
+				args, err := blackboxArgs(module, target)
+				if err != nil {
+					warnings = append(warnings, "target "+target+" of job "+job.JobName+" was skipped: "+err.Error())
+					continue
+				}
+				def := checkDefinition{
+					Name:      uniqueCheckName(moduleName, target, names),
+					Namespace: namespace,
+					Image:     khcheckImage,
+					Args:      args,
+					Interval:  interval,
+				}
+				check, err := buildCheck(def)
+				if err != nil {
+					return nil, warnings, fmt.Errorf("error importing target %s of job %s: %w", target, job.JobName, err)
+				}
+				checks = append(checks, check)
+			}
+		}
+	}
+
+	if len(checks) == 0 {
+		return nil, warnings, errors.New("no blackbox exporter targets were found in the Prometheus configuration")
+	}
+	return checks, warnings, nil
+}
+
+// blackboxArgs returns the arguments of the common checks library that probe a target the way a blackbox
+// exporter module does
+func blackboxArgs(module blackboxModule, target string) ([]string, error) {
+	switch module.Prober {
+	case "http":
+		url := target
+		if !strings.HasPrefix(url, "http://") && !strings.HasPrefix(url, "https://") {
+			url = "http://" + url
+		}
+		args := []string{"http", "--url", url}
+		if method, ok := module.HTTP["method"].(string); ok && len(method) > 0 {
+			args = append(args, "--method", strings.ToUpper(method))
+		}
+		if codes, ok := module.HTTP["valid_status_codes"].([]interface{}); ok && len(codes) > 0 {
+			if code, ok := codes[0].(float64); ok {
+				args = append(args, "--expectedStatus", strconv.Itoa(int(code)))
+			}
+		}
+		if len(module.Timeout) > 0 {
+			args = append(args, "--requestTimeout", module.Timeout)
+		}
+		return args, nil
+	case "tcp":
+		args := []string{"ports", "--targets", "tcp://" + target}
+		if len(module.Timeout) > 0 {
+			args = append(args, "--timeout", module.Timeout)
+		}
+		return args, nil
+	case "dns":
+		queryName, _ := module.DNS["query_name"].(string)
+		if len(queryName) == 0 {
+			return nil, errors.New("the dns module has no query_name")
+		}
+		server := target
+		if _, _, err := net.SplitHostPort(server); err != nil {
+			server = net.JoinHostPort(server, "53")
+		}
+		return []string{"dns", "--hosts", queryName, "--server", server}, nil
+	}
+	return nil, errors.New("the " + module.Prober + " prober has no equivalent check")
+}
+
+// unsupportedModuleOptions returns the settings of a module that the imported checks do not carry over
+func unsupportedModuleOptions(module blackboxModule) []string {
+	var supported []string
+	var options map[string]interface{}
+	switch module.Prober {
+	case "http":
+		options = module.HTTP
+		supported = []string{"method", "preferred_ip_protocol"}
+		if codes, ok := module.HTTP["valid_status_codes"].([]interface{}); ok && len(codes) <= 1 {
+			supported = append(supported, "valid_status_codes")
+		}
+	case "tcp":
+		options = module.TCP
+		supported = []string{"preferred_ip_protocol"}
+	case "dns":
+		options = module.DNS
+		supported = []string{"query_name", "preferred_ip_protocol"}
+	}
+
+	var unsupported []string
+	for option := range options {
+		found := false
+		for _, s := range supported {
+			if option == s {
+				found = true
+				break
+			}
+		}
+		if !found {
+			unsupported = append(unsupported, module.Prober+"."+option)
+		}
+	}
+	sort.Strings(unsupported)
+	return unsupported
+}
+
+// uniqueCheckName turns a module and target into a valid check name that is not in names yet, and adds it to names
+func uniqueCheckName(module string, target string, names map[string]bool) string {
+	target = strings.ToLower(target)
+	if i := strings.Index(target, "://"); i >= 0 {
+		target = target[i+3:]
+	}
+	base := strings.Trim(checkNamePattern.ReplaceAllString(strings.ToLower(module)+"-"+target, "-"), "-")
+	if len(base) > maxCheckNameLength-4 {
+		base = strings.Trim(base[:maxCheckNameLength-4], "-")
+	}
+	name := base
+	for i := 2; names[name]; i++ {
+		name = base + "-" + strconv.Itoa(i)
+	}
+	names[name] = true
+	return name
+}
@@ -0,0 +1,284 @@
+// Copyright 2018 Comcast Cable Communications Management, LLC
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//     http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ghodss/yaml"
+	apiv1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+
+	"github.com/Comcast/kuberhealthy/v2/pkg/khcheckcrd"
+)
+
+// the defaults filled in for settings a check definition leaves out
+const defaultCheckNamespace = "kuberhealthy"
+const defaultCheckInterval = time.Minute * 5
+const maxDefaultCheckTimeout = time.Minute * 5
+
+// the flags of the generate subcommand, which override the definition file
+var definitionFile string
+var definitionFlags checkDefinition
+var definitionEnv string
+
+// checkDefinition is the short definition of a check that generate turns into a full khcheck
+type checkDefinition struct {
+	Name      string            `json:"name"`
+	Namespace string            `json:"namespace,omitempty"` // defaults to kuberhealthy
+	Image     string            `json:"image"`
+	Command   []string          `json:"command,omitempty"`
+	Args      []string          `json:"args,omitempty"`
+	Env       map[string]string `json:"env,omitempty"`
+	Interval  string            `json:"interval,omitempty"` // how often the check runs.  Defaults to 5m.
+	Timeout   string            `json:"timeout,omitempty"`  // how long a run can take.  Defaults to the interval, up to 5m.
+	Severity  string            `json:"severity,omitempty"`
+}
+
+// generateCheck prints the khcheck of the check definition in the definition file, with the generate flags
+// applied over it, as YAML or JSON
+func generateCheck() error {
+	var def checkDefinition
+	if len(definitionFile) > 0 {
+		b, err := ioutil.ReadFile(definitionFile)
+		if err != nil {
+			return fmt.Errorf("error reading check definition %s: %w", definitionFile, err)
+		}
+		err = yaml.Unmarshal(b, &def)
+		if err != nil {
+			return fmt.Errorf("error parsing check definition %s: %w", definitionFile, err)
+		}
+	}
+	def = mergeDefinition(def, definitionFlags)
+	if len(definitionEnv) > 0 {
+		env, err := parseEnv(definitionEnv)
+		if err != nil {
+			return err
+		}
+		if def.Env == nil {
+			def.Env = make(map[string]string)
+		}
+		for k, v := range env {
+			def.Env[k] = v
+		}
+	}
+
+	check, err := buildCheck(def)
+	if err != nil {
+		return err
+	}
+	return printManifests([]*khcheckcrd.KuberhealthyCheck{check})
+}
+
+// printManifests prints khchecks as YAML documents, or as JSON with the json output format
+func printManifests(checks []*khcheckcrd.KuberhealthyCheck) error {
+	var manifests []interface{}
+	for _, check := range checks {
+		manifest, err := checkManifest(check)
+		if err != nil {
+			return err
+		}
+		manifests = append(manifests, manifest)
+	}
+
+	if output == "json" {
+		if len(manifests) == 1 {
+			return printJSON(manifests[0])
+		}
+		return printJSON(map[string]interface{}{"apiVersion": "v1", "kind": "List", "items": manifests})
+	}
+	for i, manifest := range manifests {
+		b, err := yaml.Marshal(manifest)
+		if err != nil {
+			return fmt.Errorf("error rendering khcheck as YAML: %w", err)
+		}
+		if i > 0 {
+			b = append([]byte("---\n"), b...)
+		}
+		_, err = os.Stdout.Write(b)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// mergeDefinition returns the definition with every setting of the overrides that is set applied over it
+func mergeDefinition(def checkDefinition, overrides checkDefinition) checkDefinition {
+	set := func(value *string, override string) {
+		if len(override) > 0 {
+			*value = override
+		}
+	}
+	set(&def.Name, overrides.Name)
+	set(&def.Namespace, overrides.Namespace)
+	set(&def.Image, overrides.Image)
+	set(&def.Interval, overrides.Interval)
+	set(&def.Timeout, overrides.Timeout)
+	set(&def.Severity, overrides.Severity)
+	if len(overrides.Command) > 0 {
+		def.Command = overrides.Command
+	}
+	if len(overrides.Args) > 0 {
+		def.Args = overrides.Args
+	}
+	return def
+}
+
+// parseEnv parses comma separated KEY=value pairs
+func parseEnv(s string) (map[string]string, error) {
+	env := make(map[string]string)
+	for _, pair := range strings.Split(s, ",") {
+		pair = strings.TrimSpace(pair)
+		if len(pair) == 0 {
+			continue
+		}
+		parts := strings.SplitN(pair, "=", 2)
+		if len(parts) != 2 || len(parts[0]) == 0 {
+			return nil, errors.New("environment variables must be given as KEY=value but got " + pair)
+		}
+		env[parts[0]] = parts[1]
+	}
+	return env, nil
+}
+
+// buildCheck creates a khcheck from a check definition, filling in the namespace, interval, timeout, resources,
+// and pod settings that checks should have
+func buildCheck(def checkDefinition) (*khcheckcrd.KuberhealthyCheck, error) {
+	if len(def.Name) == 0 {
+		return nil, errors.New("the check definition must have a name")
+	}
+	if errs := validation.IsDNS1123Subdomain(def.Name); len(errs) > 0 {
+		return nil, fmt.Errorf("the check name %s is invalid: %s", def.Name, strings.Join(errs, ", "))
+	}
+	if len(def.Image) == 0 {
+		return nil, errors.New("the check definition must have an image")
+	}
+	if len(def.Namespace) == 0 {
+		def.Namespace = defaultCheckNamespace
+	}
+
+	interval := defaultCheckInterval
+	if len(def.Interval) > 0 {
+		var err error
+		interval, err = time.ParseDuration(def.Interval)
+		if err != nil || interval <= 0 {
+			return nil, fmt.Errorf("the interval %s must be a positive duration such as 5m", def.Interval)
+		}
+	}
+
+	// a timeout longer than the interval lets a slow run hold up the runs after it
+	timeout := interval
+	if timeout > maxDefaultCheckTimeout {
+		timeout = maxDefaultCheckTimeout
+	}
+	if len(def.Timeout) > 0 {
+		var err error
+		timeout, err = time.ParseDuration(def.Timeout)
+		if err != nil || timeout <= 0 {
+			return nil, fmt.Errorf("the timeout %s must be a positive duration such as 2m", def.Timeout)
+		}
+		if timeout > interval {
+			return nil, fmt.Errorf("the timeout of %s is longer than the interval of %s", timeout, interval)
+		}
+	}
+
+	var env []apiv1.EnvVar
+	for name, value := range def.Env {
+		env = append(env, apiv1.EnvVar{Name: name, Value: value})
+	}
+	sort.Slice(env, func(i, j int) bool {
+		return env[i].Name < env[j].Name
+	})
+
+	gracePeriod := int64(5)
+	check := &khcheckcrd.KuberhealthyCheck{
+		TypeMeta: metav1.TypeMeta{APIVersion: "comcast.github.io/v1", Kind: "KuberhealthyCheck"},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      def.Name,
+			Namespace: def.Namespace,
+		},
+		Spec: khcheckcrd.CheckConfig{
+			RunInterval: interval.String(),
+			Timeout:     timeout.String(),
+			Severity:    def.Severity,
+			PodSpec: apiv1.PodSpec{
+				Containers: []apiv1.Container{{
+					Name:            "main",
+					Image:           def.Image,
+					ImagePullPolicy: apiv1.PullIfNotPresent,
+					Command:         def.Command,
+					Args:            def.Args,
+					Env:             env,
+					Resources: apiv1.ResourceRequirements{
+						Requests: apiv1.ResourceList{
+							apiv1.ResourceCPU:    resource.MustParse("15m"),
+							apiv1.ResourceMemory: resource.MustParse("15Mi"),
+						},
+						Limits: apiv1.ResourceList{
+							apiv1.ResourceCPU: resource.MustParse("25m"),
+						},
+					},
+				}},
+				RestartPolicy:                 apiv1.RestartPolicyNever,
+				TerminationGracePeriodSeconds: &gracePeriod,
+			},
+		},
+	}
+	return check, nil
+}
+
+// checkManifest returns a khcheck as a manifest without the blank fields that the khcheck has when it was not
+// read from the cluster, such as its creation timestamp
+func checkManifest(check *khcheckcrd.KuberhealthyCheck) (map[string]interface{}, error) {
+	b, err := json.Marshal(check)
+	if err != nil {
+		return nil, fmt.Errorf("error rendering khcheck: %w", err)
+	}
+	var manifest map[string]interface{}
+	err = json.Unmarshal(b, &manifest)
+	if err != nil {
+		return nil, fmt.Errorf("error rendering khcheck: %w", err)
+	}
+	pruneNulls(manifest)
+	return manifest, nil
+}
+
+// pruneNulls removes null and empty values from a manifest
+func pruneNulls(m map[string]interface{}) {
+	for k, v := range m {
+		switch value := v.(type) {
+		case nil:
+			delete(m, k)
+		case map[string]interface{}:
+			pruneNulls(value)
+			if len(value) == 0 {
+				delete(m, k)
+			}
+		case []interface{}:
+			for _, item := range value {
+				if itemMap, ok := item.(map[string]interface{}); ok {
+					pruneNulls(itemMap)
+				}
+			}
+		}
+	}
+}
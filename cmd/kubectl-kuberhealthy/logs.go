@@ -0,0 +1,85 @@
+// Copyright 2018 Comcast Cable Communications Management, LLC
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//     http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/Comcast/kuberhealthy/v2/pkg/apiclient"
+	"github.com/Comcast/kuberhealthy/v2/pkg/checks/external"
+	"github.com/Comcast/kuberhealthy/v2/pkg/kubeClient"
+)
+
+// showLogs prints the logs of the newest checker pod of a check.  Checker pods are found with the
+// Kubernetes API, since Kuberhealthy does not serve their logs.
+func showLogs(ctx context.Context, client *apiclient.Client) error {
+	namespace, name, err := splitCheck(checkArg)
+	if err != nil {
+		return err
+	}
+
+	// make sure the check exists so that a typo is not reported as a missing pod
+	_, err = client.GetCheck(ctx, namespace, name)
+	if err != nil {
+		return err
+	}
+
+	kubernetesClient, err := kubeClient.Create(kubeConfigFile)
+	if err != nil {
+		return fmt.Errorf("unable to create kubernetes client: %w", err)
+	}
+
+	// checker pods run in the namespace of their check, or in an ephemeral namespace annotated with it
+	pods, err := kubernetesClient.CoreV1().Pods(metav1.NamespaceAll).List(metav1.ListOptions{
+		LabelSelector: external.KuberhealthyCheckNameLabel + "=" + name,
+	})
+	if err != nil {
+		return fmt.Errorf("unable to list checker pods: %w", err)
+	}
+	var newest *v1.Pod
+	for i := range pods.Items {
+		pod := &pods.Items[i]
+		if pod.Namespace != namespace && pod.Annotations[external.KHCheckNamespaceAnnotation] != namespace {
+			continue
+		}
+		if newest == nil || newest.CreationTimestamp.Before(&pod.CreationTimestamp) {
+			newest = pod
+		}
+	}
+	if newest == nil {
+		return errors.New("no checker pod of check " + checkArg + " was found.  Checker pods are removed once their run is reaped.")
+	}
+
+	stream, err := kubernetesClient.CoreV1().Pods(newest.Namespace).GetLogs(newest.Name, &v1.PodLogOptions{Follow: follow}).Stream()
+	if err != nil {
+		return fmt.Errorf("unable to fetch the logs of checker pod %s/%s: %w", newest.Namespace, newest.Name, err)
+	}
+	defer stream.Close()
+
+	// close the stream on interrupt so that following the logs stops
+	go func() {
+		<-ctx.Done()
+		stream.Close()
+	}()
+	_, err = io.Copy(os.Stdout, stream)
+	if err != nil && ctx.Err() == nil {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,275 @@
+// Copyright 2018 Comcast Cable Communications Management, LLC
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//     http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// kubectl-kuberhealthy is a command line client for the Kuberhealthy API.  When installed on the PATH it
+// can also be run as a kubectl plugin with `kubectl kuberhealthy`.
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/integrii/flaggy"
+
+	"github.com/Comcast/kuberhealthy/v2/pkg/apiclient"
+	"github.com/Comcast/kuberhealthy/v2/pkg/runhistory"
+)
+
+// KH_URL is the environment variable that holds the base URL of Kuberhealthy
+const KH_URL = "KH_URL"
+
+// KH_API_TOKEN is the environment variable that holds the API token of Kuberhealthy
+const KH_API_TOKEN = "KH_API_TOKEN"
+
+// the global flags
+var khURL = os.Getenv(KH_URL)
+var apiToken = os.Getenv(KH_API_TOKEN)
+var output = "table"
+var kubeConfigFile = filepath.Join(os.Getenv("HOME"), ".kube", "config")
+
+// the flags and arguments of the subcommands
+var namespaces string
+var selector string
+var view string
+var checkArg string
+var wait bool
+var waitInterval = time.Second * 5
+var follow bool
+
+func main() {
+	flaggy.SetName("kubectl-kuberhealthy")
+	flaggy.SetDescription("Inspect and drive Kuberhealthy checks through the Kuberhealthy API")
+	flaggy.String(&khURL, "", "url", "The base URL of Kuberhealthy, such as http://kuberhealthy.kuberhealthy.  Defaults to the "+KH_URL+" environment variable.")
+	flaggy.String(&apiToken, "", "token", "The Kuberhealthy API token.  Defaults to the "+KH_API_TOKEN+" environment variable.")
+	flaggy.String(&output, "o", "output", "The output format, either table or json.")
+	flaggy.String(&kubeConfigFile, "", "kubeconfig", "The kubeconfig used to fetch the logs of checker pods.")
+
+	status := flaggy.NewSubcommand("status")
+	status.Description = "Show the overall status and the status of each check"
+	status.String(&namespaces, "n", "namespace", "A comma separated list of namespaces to show checks from.")
+	status.String(&selector, "l", "selector", "A label selector that checks must match.")
+	status.String(&view, "", "view", "The name of a configured status page view to show.")
+	flaggy.AttachSubcommand(status, 1)
+
+	list := flaggy.NewSubcommand("list")
+	list.Description = "List checks and their status"
+	list.String(&namespaces, "n", "namespace", "A comma separated list of namespaces to list checks from.")
+	list.String(&selector, "l", "selector", "A label selector that checks must match.")
+	flaggy.AttachSubcommand(list, 1)
+
+	run := flaggy.NewSubcommand("run")
+	run.Description = "Trigger a run of a check"
+	run.AddPositionalValue(&checkArg, "check", 1, true, "The check to run as namespace/name.")
+	run.Bool(&wait, "w", "wait", "Wait for the run to finish, and exit with an error if it did not succeed.")
+	run.Duration(&waitInterval, "", "interval", "How often the run is polled while waiting for it.")
+	flaggy.AttachSubcommand(run, 1)
+
+	runs := flaggy.NewSubcommand("runs")
+	runs.Description = "Show the recent run history of checks"
+	runs.AddPositionalValue(&checkArg, "check", 1, false, "Only show the runs of this check, as namespace/name.")
+	flaggy.AttachSubcommand(runs, 1)
+
+	logs := flaggy.NewSubcommand("logs")
+	logs.Description = "Show the logs of the current checker pod of a check"
+	logs.AddPositionalValue(&checkArg, "check", 1, true, "The check as namespace/name.")
+	logs.Bool(&follow, "f", "follow", "Stream the logs until the checker pod exits.")
+	flaggy.AttachSubcommand(logs, 1)
+
+	generate := flaggy.NewSubcommand("generate")
+	generate.Description = "Print a khcheck with best practice defaults for a short check definition"
+	generate.String(&definitionFile, "f", "file", "A YAML or JSON check definition with a name, image, command, args, env, interval, timeout, and severity.")
+	generate.String(&definitionFlags.Name, "", "name", "The name of the check.")
+	generate.String(&definitionFlags.Namespace, "n", "namespace", "The namespace of the check.  Defaults to "+defaultCheckNamespace+".")
+	generate.String(&definitionFlags.Image, "", "image", "The image of the checker pod.")
+	generate.StringSlice(&definitionFlags.Args, "", "arg", "An argument of the checker pod.  Can be given more than once.")
+	generate.String(&definitionEnv, "", "env", "Comma separated KEY=value environment variables of the checker pod.")
+	generate.String(&definitionFlags.Interval, "", "interval", "How often the check runs.  Defaults to "+defaultCheckInterval.String()+".")
+	generate.String(&definitionFlags.Timeout, "", "timeout", "How long a run can take.  Defaults to the interval, up to "+maxDefaultCheckTimeout.String()+".")
+	generate.String(&definitionFlags.Severity, "", "severity", "The severity of the check.")
+	flaggy.AttachSubcommand(generate, 1)
+
+	importBlackbox := flaggy.NewSubcommand("import-blackbox")
+	importBlackbox.Description = "Print khchecks that probe the targets of Prometheus blackbox exporter jobs"
+	importBlackbox.String(&blackboxConfigFile, "c", "config", "The blackbox exporter configuration with the modules of the probes.")
+	importBlackbox.String(&blackboxTargetsFile, "t", "targets", "The Prometheus configuration with the scrape jobs that list the probed targets.")
+	importBlackbox.String(&blackboxNamespace, "n", "namespace", "The namespace of the checks.  Defaults to "+defaultCheckNamespace+".")
+	flaggy.AttachSubcommand(importBlackbox, 1)
+
+	flaggy.Parse()
+
+	if output != "table" && output != "json" {
+		fail(errors.New("the output format must be table or json"))
+	}
+
+	// cancel requests and log streams on interrupt
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+	go func() {
+		<-interrupt
+		cancel()
+	}()
+
+	var err error
+	switch {
+	case status.Used:
+		err = showStatus(ctx, newClient())
+	case list.Used:
+		err = listChecks(ctx, newClient())
+	case run.Used:
+		err = runCheck(ctx, newClient())
+	case runs.Used:
+		err = showRuns(ctx, newClient())
+	case logs.Used:
+		err = showLogs(ctx, newClient())
+	case generate.Used:
+		err = generateCheck()
+	case importBlackbox.Used:
+		err = importBlackboxChecks()
+	default:
+		flaggy.ShowHelpAndExit("A subcommand is required")
+	}
+	if err != nil {
+		fail(err)
+	}
+}
+
+// newClient creates an API client from the global flags
+func newClient() *apiclient.Client {
+	if len(khURL) == 0 {
+		fail(errors.New("the Kuberhealthy URL must be set with --url or the " + KH_URL + " environment variable"))
+	}
+	return apiclient.NewClient(khURL, apiToken)
+}
+
+// showStatus prints the overall status and the status of each check
+func showStatus(ctx context.Context, client *apiclient.Client) error {
+	state, err := client.GetStatus(ctx, apiclient.StatusOptions{
+		Namespaces: splitNamespaces(namespaces),
+		Selector:   selector,
+		View:       view,
+	})
+	if err != nil {
+		return err
+	}
+	if output == "json" {
+		return printJSON(state)
+	}
+	printState(state)
+	return nil
+}
+
+// listChecks prints the status of each check
+func listChecks(ctx context.Context, client *apiclient.Client) error {
+	checks, err := client.ListChecks(ctx, splitNamespaces(namespaces), selector)
+	if err != nil {
+		return err
+	}
+	if output == "json" {
+		return printJSON(checks)
+	}
+	printChecks(checks)
+	return nil
+}
+
+// runCheck triggers a run of a check and optionally waits for it to finish
+func runCheck(ctx context.Context, client *apiclient.Client) error {
+	namespace, name, err := splitCheck(checkArg)
+	if err != nil {
+		return err
+	}
+	triggered, err := client.TriggerRun(ctx, namespace, name)
+	if err != nil {
+		return err
+	}
+	if !wait {
+		if output == "json" {
+			return printJSON(triggered)
+		}
+		fmt.Println("Triggered run", triggered.RunID, "of check", checkArg)
+		return nil
+	}
+
+	if output != "json" {
+		fmt.Println("Triggered run", triggered.RunID, "of check", checkArg+".  Waiting for it to finish...")
+	}
+	result, err := client.WaitForRun(ctx, triggered.RunID, waitInterval)
+	if err != nil {
+		return err
+	}
+	if output == "json" {
+		err = printJSON(result)
+	} else {
+		printRuns([]runhistory.Run{result})
+	}
+	if err != nil {
+		return err
+	}
+	if result.Phase != runhistory.PhaseSucceeded {
+		return errors.New("run " + result.ID + " of check " + checkArg + " finished as " + string(result.Phase))
+	}
+	return nil
+}
+
+// showRuns prints the recent runs of all checks, or of a single check
+func showRuns(ctx context.Context, client *apiclient.Client) error {
+	var namespace, name string
+	if len(checkArg) > 0 {
+		var err error
+		namespace, name, err = splitCheck(checkArg)
+		if err != nil {
+			return err
+		}
+	}
+	runs, err := client.ListRuns(ctx, namespace, name)
+	if err != nil {
+		return err
+	}
+	if output == "json" {
+		return printJSON(runs)
+	}
+	printRuns(runs)
+	return nil
+}
+
+// splitCheck splits a check argument of the form namespace/name
+func splitCheck(s string) (string, string, error) {
+	parts := strings.Split(s, "/")
+	if len(parts) != 2 || len(parts[0]) == 0 || len(parts[1]) == 0 {
+		return "", "", errors.New("expected a check as namespace/name but got " + s)
+	}
+	return parts[0], parts[1], nil
+}
+
+// splitNamespaces splits a comma separated list of namespaces
+func splitNamespaces(s string) []string {
+	var namespaces []string
+	for _, namespace := range strings.Split(s, ",") {
+		namespace = strings.TrimSpace(namespace)
+		if len(namespace) > 0 {
+			namespaces = append(namespaces, namespace)
+		}
+	}
+	return namespaces
+}
+
+// fail prints an error and exits
+func fail(err error) {
+	fmt.Fprintln(os.Stderr, "Error:", err)
+	os.Exit(1)
+}
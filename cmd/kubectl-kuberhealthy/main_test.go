@@ -0,0 +1,144 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+// TestSplitCheck validates that checks are parsed from namespace/name arguments
+func TestSplitCheck(t *testing.T) {
+	namespace, name, err := splitCheck("kuberhealthy/deployment")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if namespace != "kuberhealthy" || name != "deployment" {
+		t.Fatal("Expected kuberhealthy/deployment but got", namespace, name)
+	}
+	for _, invalid := range []string{"deployment", "/deployment", "kuberhealthy/", "a/b/c"} {
+		_, _, err := splitCheck(invalid)
+		if err == nil {
+			t.Fatal("Expected an error for check", invalid)
+		}
+	}
+}
+
+// TestSplitNamespaces validates that blank namespaces are dropped from namespace lists
+func TestSplitNamespaces(t *testing.T) {
+	namespaces := splitNamespaces(" kuberhealthy,,default ")
+	if len(namespaces) != 2 || namespaces[0] != "kuberhealthy" || namespaces[1] != "default" {
+		t.Fatal("Expected two namespaces but got", namespaces)
+	}
+	if len(splitNamespaces("")) != 0 {
+		t.Fatal("Expected no namespaces from a blank list")
+	}
+}
+
+// TestBuildCheck validates that check definitions are filled in with defaults and that invalid definitions are rejected
+func TestBuildCheck(t *testing.T) {
+	check, err := buildCheck(checkDefinition{Name: "my-check", Image: "example/check:1.0", Interval: "10m", Env: map[string]string{"B": "2", "A": "1"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if check.Namespace != defaultCheckNamespace {
+		t.Fatal("Expected the default namespace but got", check.Namespace)
+	}
+	if check.Spec.Timeout != maxDefaultCheckTimeout.String() {
+		t.Fatal("Expected the timeout to default to", maxDefaultCheckTimeout, "but got", check.Spec.Timeout)
+	}
+	container := check.Spec.PodSpec.Containers[0]
+	if container.Env[0].Name != "A" || container.Env[1].Name != "B" {
+		t.Fatal("Expected environment variables sorted by name but got", container.Env)
+	}
+	if container.Resources.Requests.Cpu().IsZero() {
+		t.Fatal("Expected the container to request CPU")
+	}
+
+	for _, invalid := range []checkDefinition{
+		{Image: "example/check:1.0"},
+		{Name: "Not_Valid", Image: "example/check:1.0"},
+		{Name: "my-check"},
+		{Name: "my-check", Image: "example/check:1.0", Interval: "1m", Timeout: "2m"},
+		{Name: "my-check", Image: "example/check:1.0", Interval: "often"},
+	} {
+		_, err := buildCheck(invalid)
+		if err == nil {
+			t.Fatal("Expected an error for check definition", invalid)
+		}
+	}
+}
+
+// TestParseEnv validates that environment variables are parsed from KEY=value pairs
+func TestParseEnv(t *testing.T) {
+	env, err := parseEnv("A=1, B=x=y,")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(env) != 2 || env["A"] != "1" || env["B"] != "x=y" {
+		t.Fatal("Expected two environment variables but got", env)
+	}
+	_, err = parseEnv("A")
+	if err == nil {
+		t.Fatal("Expected an error for an environment variable without a value")
+	}
+}
+
+// TestImportBlackbox validates that the targets of blackbox exporter jobs become checks and that probes that can
+// not be imported are skipped with a warning
+func TestImportBlackbox(t *testing.T) {
+	config := blackboxConfig{Modules: map[string]blackboxModule{
+		"http_2xx":    {Prober: "http", Timeout: "5s"},
+		"tcp_connect": {Prober: "tcp"},
+		"dns":         {Prober: "dns", DNS: map[string]interface{}{"query_name": "example.com", "query_type": "A"}},
+		"icmp":        {Prober: "icmp"},
+	}}
+	var prometheus prometheusConfig
+	prometheus.Global.ScrapeInterval = "2m"
+	for module, target := range map[string]string{"http_2xx": "example.com", "tcp_connect": "db:5432", "dns": "10.0.0.10", "icmp": "10.0.0.1"} {
+		job := scrapeConfig{JobName: module, MetricsPath: "/probe", Params: map[string][]string{"module": {module}}}
+		job.StaticConfigs = append(job.StaticConfigs, struct {
+			Targets []string `json:"targets"`
+		}{Targets: []string{target}})
+		prometheus.ScrapeConfigs = append(prometheus.ScrapeConfigs, job)
+	}
+
+	checks, warnings, err := importBlackbox(config, prometheus, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(checks) != 3 {
+		t.Fatal("Expected three checks but got", len(checks))
+	}
+	if len(warnings) != 2 {
+		t.Fatal("Expected warnings about the query type and the icmp probe but got", warnings)
+	}
+	args := make(map[string]string)
+	for _, check := range checks {
+		if check.Spec.RunInterval != "2m0s" {
+			t.Fatal("Expected checks to run at the scrape interval but got", check.Spec.RunInterval)
+		}
+		args[check.Name] = strings.Join(check.Spec.PodSpec.Containers[0].Args, " ")
+	}
+	if args["http-2xx-example-com"] != "http --url http://example.com --requestTimeout 5s" {
+		t.Fatal("Unexpected arguments of the http check:", args["http-2xx-example-com"])
+	}
+	if args["tcp-connect-db-5432"] != "ports --targets tcp://db:5432" {
+		t.Fatal("Unexpected arguments of the tcp check:", args["tcp-connect-db-5432"])
+	}
+	if args["dns-10-0-0-10"] != "dns --hosts example.com --server 10.0.0.10:53" {
+		t.Fatal("Unexpected arguments of the dns check:", args["dns-10-0-0-10"])
+	}
+}
+
+// TestUniqueCheckName validates that check names are valid and not reused
+func TestUniqueCheckName(t *testing.T) {
+	names := make(map[string]bool)
+	first := uniqueCheckName("http_2xx", "https://Example.com/", names)
+	second := uniqueCheckName("http_2xx", "http://example.com", names)
+	if first != "http-2xx-example-com" || second != "http-2xx-example-com-2" {
+		t.Fatal("Expected unique check names but got", first, second)
+	}
+	long := uniqueCheckName("http", "https://"+strings.Repeat("a", 100)+".com", names)
+	if len(long) > maxCheckNameLength {
+		t.Fatal("Expected the check name to be shortened but got", long)
+	}
+}
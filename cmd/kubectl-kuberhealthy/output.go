@@ -0,0 +1,130 @@
+// Copyright 2018 Comcast Cable Communications Management, LLC
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//     http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/Comcast/kuberhealthy/v2/pkg/health"
+	"github.com/Comcast/kuberhealthy/v2/pkg/runhistory"
+)
+
+// printJSON prints a value as indented JSON
+func printJSON(v interface{}) error {
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(v)
+}
+
+// printState prints the overall status followed by a table of checks
+func printState(state health.State) {
+	if state.OK {
+		fmt.Println("Overall status: OK")
+	} else {
+		fmt.Println("Overall status: FAILING")
+		for _, e := range state.Errors {
+			fmt.Println("  " + e)
+		}
+	}
+	if len(state.CurrentMaster) > 0 {
+		fmt.Println("Master:", state.CurrentMaster)
+	}
+	fmt.Println()
+	printChecks(state.CheckDetails)
+}
+
+// printChecks prints a table of checks sorted by namespace and name
+func printChecks(checks map[string]health.CheckDetails) {
+	keys := make([]string, 0, len(checks))
+	for key := range checks {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+	fmt.Fprintln(w, "NAMESPACE\tNAME\tSTATUS\tLAST RUN\tDURATION\tERROR")
+	for _, key := range keys {
+		details := checks[key]
+		name := key[strings.Index(key, "/")+1:]
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", details.Namespace, name, checkStatus(details), age(details.LastRun), details.RunDuration, firstError(details.Errors))
+	}
+	w.Flush()
+}
+
+// printRuns prints a table of check runs
+func printRuns(runs []runhistory.Run) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+	fmt.Fprintln(w, "RUN\tNAMESPACE\tNAME\tPHASE\tTRIGGERED\tSTARTED\tDURATION\tDIGEST\tERROR")
+	for _, run := range runs {
+		started := "-"
+		if run.Started != nil {
+			started = age(*run.Started)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%t\t%s\t%s\t%s\t%s\n", run.ID, run.Namespace, run.Name, run.Phase, run.Triggered, started, run.Duration, shortDigest(run.ImageDigest), firstError(run.Errors))
+	}
+	w.Flush()
+}
+
+// checkStatus describes the status of a check in a single word
+func checkStatus(details health.CheckDetails) string {
+	switch {
+	case details.Silence != nil:
+		return "Silenced"
+	case details.Unknown:
+		return "Unknown"
+	case details.Stale:
+		return "Stale"
+	case details.OK:
+		return "OK"
+	}
+	return "Failing"
+}
+
+// age formats how long ago a time was, rounded to the second
+func age(t time.Time) string {
+	if t.IsZero() {
+		return "-"
+	}
+	return time.Since(t).Round(time.Second).String() + " ago"
+}
+
+// firstError returns the first of a list of errors, noting how many more there are
+func firstError(errors []string) string {
+	switch len(errors) {
+	case 0:
+		return ""
+	case 1:
+		return errors[0]
+	}
+	return fmt.Sprintf("%s (and %d more)", errors[0], len(errors)-1)
+}
+
+// shortDigest returns the first 12 hex characters of an image digest, the way container tools abbreviate image
+// IDs, or a dash when there is no digest
+func shortDigest(digest string) string {
+	if len(digest) == 0 {
+		return "-"
+	}
+	if i := strings.Index(digest, ":"); i >= 0 {
+		digest = digest[i+1:]
+	}
+	if len(digest) > 12 {
+		digest = digest[:12]
+	}
+	return digest
+}
@@ -0,0 +1,360 @@
+// Copyright 2018 Comcast Cable Communications Management, LLC
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//     http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"crypto/subtle"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/Comcast/kuberhealthy/v2/pkg/apiclient"
+	"github.com/Comcast/kuberhealthy/v2/pkg/masterCalculation"
+	"github.com/Comcast/kuberhealthy/v2/pkg/runhistory"
+	"github.com/Comcast/kuberhealthy/v2/pkg/usage"
+)
+
+// apiPrefix is the path that all versioned API endpoints are served under
+const apiPrefix = "/api/v1/"
+
+// forwardedHeader is set on API requests that were forwarded to another pod so they are never forwarded twice.  It is
+// only trusted on requests sent by the Kuberhealthy pod it names.
+const forwardedHeader = "X-Kuberhealthy-Forwarded-By"
+
+// ErrCheckNotFound is returned when an API request references a check that is not running
+var ErrCheckNotFound = errors.New("check not found")
+
+// ErrRunAlreadyQueued is returned when a run is triggered for a check that already has a triggered run queued
+var ErrRunAlreadyQueued = errors.New("a run is already queued for this check")
+
+// apiError is the body written back to API callers when a request fails
+type apiError struct {
+	Error string `json:"error"`
+}
+
+// writeAPIResponse writes a JSON body with the supplied status code
+func writeAPIResponse(w http.ResponseWriter, code int, body interface{}) error {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	return json.NewEncoder(w).Encode(body)
+}
+
+// writeAPIError writes an error message as JSON with the supplied status code
+func writeAPIError(w http.ResponseWriter, code int, message string) error {
+	return writeAPIResponse(w, code, apiError{Error: message})
+}
+
+// authorizeAPIRequest validates the bearer token on an API request against the configured API token
+func authorizeAPIRequest(r *http.Request) bool {
+	if len(apiToken) == 0 {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(bearerToken(r)), []byte(apiToken)) == 1
+}
+
+// apiHandler serves the authenticated API used to drive checks from outside of the cluster, such as
+// from a CI pipeline.  Requests that reach a Kuberhealthy instance which is not the master are forwarded
+// to the master, because only the master runs checks.  When checks are sharded, every instance serves
+// requests and those about a check are forwarded to the instance that runs it.  Requests are dispatched
+// to the first of the apiRoutes that matches their method and path.
+func (k *Kuberhealthy) apiHandler(w http.ResponseWriter, r *http.Request) error {
+	log.Infoln("Client connected to API endpoint", r.URL.Path, "from", r.RemoteAddr, r.UserAgent())
+
+	if len(apiToken) == 0 {
+		return writeAPIError(w, http.StatusNotFound, "the API is disabled because no API token is configured")
+	}
+	if !authorizeAPIRequest(r) {
+		return writeAPIError(w, http.StatusUnauthorized, "a valid bearer token is required")
+	}
+
+	// only other Kuberhealthy pods can mark a request as forwarded, or callers could have requests about a check
+	// served by a pod that does not run it
+	if len(r.Header.Get(forwardedHeader)) > 0 && !forwardedByPeer(r) {
+		log.Warningln("Ignoring the", forwardedHeader, "header of API request", r.URL.Path, "from", r.RemoteAddr, "because it was not sent by a kuberhealthy pod")
+		r.Header.Del(forwardedHeader)
+	}
+
+	// without sharding, the master serves every request because it runs every check
+	if !shardChecks && !isMaster {
+		return k.forwardToMaster(w, r)
+	}
+
+	// grafana serves its own protocol below its prefix
+	path := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, apiPrefix), "/"), "/")
+	if path[0] == "grafana" {
+		return k.grafanaHandler(w, r, path[1:])
+	}
+
+	pathMatched := false
+	for _, route := range apiRoutes {
+		params, ok := matchAPIRoute(route.Path, r.URL.Path)
+		if !ok {
+			continue
+		}
+		pathMatched = true
+		if route.Method != r.Method {
+			continue
+		}
+
+		// with sharding, requests about a check are served by the pod that runs it.  Requests forwarded
+		// from another pod are always served here so that they are never forwarded twice.
+		if shardChecks && len(params["name"]) > 0 && len(r.Header.Get(forwardedHeader)) == 0 {
+			owner := shards.owner(params["namespace"], params["name"])
+			if len(owner) > 0 && owner != podHostname {
+				return k.forwardToPod(w, r, owner)
+			}
+		}
+		return route.handler(k, w, r, params)
+	}
+	if pathMatched {
+		return writeAPIError(w, http.StatusMethodNotAllowed, r.Method+" is not supported on "+r.URL.Path)
+	}
+	return writeAPIError(w, http.StatusNotFound, "no API endpoint at "+r.URL.Path)
+}
+
+// forwardedByPeer returns true if an API request was sent by the running Kuberhealthy pod named in its
+// forwarded header, as determined by the IP of the pod
+func forwardedByPeer(r *http.Request) bool {
+	pods, err := kubernetesClient.CoreV1().Pods(podNamespace).List(metav1.ListOptions{
+		LabelSelector: "app=kuberhealthy", FieldSelector: "status.phase=Running",
+	})
+	if err != nil {
+		log.Errorln("Unable to list kuberhealthy pods to verify a forwarded API request:", err)
+		return false
+	}
+	name := r.Header.Get(forwardedHeader)
+	for _, p := range pods.Items {
+		if p.Name == name && len(p.Status.PodIP) > 0 && p.Status.PodIP == sourceIP(r.RemoteAddr) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchAPIRoute matches a request path against a route path, where segments in braces such as {name}
+// match any single segment.  The values of those segments are returned by name.
+func matchAPIRoute(pattern string, path string) (map[string]string, bool) {
+	patternSegments := strings.Split(strings.Trim(pattern, "/"), "/")
+	pathSegments := strings.Split(strings.Trim(path, "/"), "/")
+	if len(patternSegments) != len(pathSegments) {
+		return nil, false
+	}
+	params := make(map[string]string)
+	for i, segment := range patternSegments {
+		if strings.HasPrefix(segment, "{") && strings.HasSuffix(segment, "}") {
+			if len(pathSegments[i]) == 0 {
+				return nil, false
+			}
+			params[strings.Trim(segment, "{}")] = pathSegments[i]
+			continue
+		}
+		if segment != pathSegments[i] {
+			return nil, false
+		}
+	}
+	return params, true
+}
+
+// triggerRunHandler triggers an immediate run of the specified check and returns the UUID of the run
+func (k *Kuberhealthy) triggerRunHandler(w http.ResponseWriter, namespace string, name string) error {
+	runID, err := k.triggerRun(namespace, name)
+	switch err {
+	case nil:
+	case ErrCheckNotFound:
+		return writeAPIError(w, http.StatusNotFound, "no check named "+name+" is running in namespace "+namespace)
+	case ErrRunAlreadyQueued:
+		return writeAPIError(w, http.StatusConflict, err.Error())
+	default:
+		return writeAPIError(w, http.StatusInternalServerError, err.Error())
+	}
+
+	return writeAPIResponse(w, http.StatusAccepted, apiclient.TriggerRunResponse{
+		RunID:     runID,
+		Name:      name,
+		Namespace: namespace,
+	})
+}
+
+// getRunHandler returns the state of the check run with the supplied run UUID
+func (k *Kuberhealthy) getRunHandler(w http.ResponseWriter, r *http.Request, runID string) error {
+	run, ok := k.findRun(r, runID)
+	if !ok {
+		return writeAPIError(w, http.StatusNotFound, "no record of a run with UUID "+runID)
+	}
+	return writeAPIResponse(w, http.StatusOK, run)
+}
+
+// listRunsHandler returns the recent check runs, optionally only those of a namespace or check name
+func (k *Kuberhealthy) listRunsHandler(w http.ResponseWriter, r *http.Request) error {
+	namespace := r.URL.Query().Get("namespace")
+	name := r.URL.Query().Get("check")
+	runs := []runhistory.Run{}
+	for _, run := range k.allRuns(r) {
+		if len(namespace) != 0 && run.Namespace != namespace {
+			continue
+		}
+		if len(name) != 0 && run.Name != name {
+			continue
+		}
+		runs = append(runs, run)
+	}
+	return writeAPIResponse(w, http.StatusOK, runs)
+}
+
+// listUsageHandler returns the resources reserved by the checker pods of each check, optionally only those of a
+// namespace or check name
+func (k *Kuberhealthy) listUsageHandler(w http.ResponseWriter, r *http.Request) error {
+	namespace := r.URL.Query().Get("namespace")
+	name := r.URL.Query().Get("check")
+	checks := []usage.CheckUsage{}
+	for _, u := range k.allUsage(r) {
+		if len(namespace) != 0 && u.Namespace != namespace {
+			continue
+		}
+		if len(name) != 0 && u.Name != name {
+			continue
+		}
+		checks = append(checks, u)
+	}
+	return writeAPIResponse(w, http.StatusOK, checks)
+}
+
+// statusHandler returns the current status of the checks selected by the query
+func (k *Kuberhealthy) statusHandler(w http.ResponseWriter, r *http.Request) error {
+	state, err := k.queryState(r.URL.Query(), r.URL.Query().Get("view"))
+	switch err.(type) {
+	case nil:
+	case errViewNotFound:
+		return writeAPIError(w, http.StatusNotFound, err.Error())
+	default:
+		return writeAPIError(w, http.StatusBadRequest, err.Error())
+	}
+	return writeAPIResponse(w, http.StatusOK, state)
+}
+
+// listChecksHandler returns the current status of every check in the namespaces of the query
+func (k *Kuberhealthy) listChecksHandler(w http.ResponseWriter, r *http.Request) error {
+	state, err := k.queryState(r.URL.Query(), "")
+	if err != nil {
+		return writeAPIError(w, http.StatusBadRequest, err.Error())
+	}
+	return writeAPIResponse(w, http.StatusOK, state.CheckDetails)
+}
+
+// getCheckHandler returns the current status of a single check
+func (k *Kuberhealthy) getCheckHandler(w http.ResponseWriter, namespace string, name string) error {
+	details, ok := k.getCurrentState(nil).CheckDetails[checkKey(namespace, name)]
+	if !ok {
+		return writeAPIError(w, http.StatusNotFound, "no check named "+name+" in namespace "+namespace)
+	}
+	return writeAPIResponse(w, http.StatusOK, details)
+}
+
+// forwardToMaster proxies an API request to the current master Kuberhealthy pod
+func (k *Kuberhealthy) forwardToMaster(w http.ResponseWriter, r *http.Request) error {
+	if len(r.Header.Get(forwardedHeader)) > 0 {
+		return writeAPIError(w, http.StatusServiceUnavailable, "master election is in progress, try again shortly")
+	}
+
+	masterName, err := masterCalculation.CalculateMaster(kubernetesClient)
+	if err != nil {
+		return writeAPIError(w, http.StatusServiceUnavailable, "unable to determine the master kuberhealthy pod: "+err.Error())
+	}
+	return k.forwardToPod(w, r, masterName)
+}
+
+// forwardToPod proxies an API request to another Kuberhealthy pod
+func (k *Kuberhealthy) forwardToPod(w http.ResponseWriter, r *http.Request, podName string) error {
+	target, transport, err := k.peerTarget(podName)
+	if err != nil {
+		return writeAPIError(w, http.StatusServiceUnavailable, err.Error())
+	}
+	proxy := httputil.NewSingleHostReverseProxy(target)
+	proxy.Transport = transport
+
+	log.Infoln("Forwarding API request", r.URL.Path, "to kuberhealthy pod", podName)
+	r.Header.Set(forwardedHeader, podHostname)
+	proxy.ServeHTTP(w, r)
+	return nil
+}
+
+// peerGet sends an API GET request to another Kuberhealthy pod with the credentials of the original request
+// and decodes a successful response into out.  The status code of the response is returned.
+func (k *Kuberhealthy) peerGet(r *http.Request, podName string, path string, out interface{}) (int, error) {
+	target, transport, err := k.peerTarget(podName)
+	if err != nil {
+		return 0, err
+	}
+	req, err := http.NewRequest(http.MethodGet, target.String()+path, nil)
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Authorization", r.Header.Get("Authorization"))
+	req.Header.Set(forwardedHeader, podHostname)
+
+	client := &http.Client{Transport: transport, Timeout: time.Second * 10}
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return resp.StatusCode, nil
+	}
+	return resp.StatusCode, json.NewDecoder(resp.Body).Decode(out)
+}
+
+// peerTarget returns the URL of another Kuberhealthy pod along with the transport used to reach it
+func (k *Kuberhealthy) peerTarget(podName string) (*url.URL, http.RoundTripper, error) {
+	pod, err := kubernetesClient.CoreV1().Pods(podNamespace).Get(podName, metav1.GetOptions{})
+	if err != nil {
+		return nil, nil, errors.New("unable to fetch kuberhealthy pod " + podName + ": " + err.Error())
+	}
+	_, port, err := net.SplitHostPort(k.ListenAddr)
+	if err != nil {
+		return nil, nil, errors.New("unable to determine the listen port: " + err.Error())
+	}
+
+	scheme := "http"
+	if tlsReloader != nil {
+		scheme = "https"
+	}
+	target := &url.URL{Scheme: scheme, Host: net.JoinHostPort(pod.Status.PodIP, port)}
+
+	// other pods serve the same certificate that checker pods verify with the reporting URL's host name.  They
+	// verify client certificates with the client CA bundle, so that certificate is presented to them as well.
+	if tlsReloader == nil {
+		return target, http.DefaultTransport, nil
+	}
+	tlsConfig := &tls.Config{
+		MinVersion:           tls.VersionTLS12,
+		GetClientCertificate: tlsReloader.ClientCertificate,
+	}
+	if reportingURL, err := url.Parse(externalCheckReportingURL); err == nil {
+		tlsConfig.ServerName = reportingURL.Hostname()
+	}
+	if bundle := tlsReloader.CABundle(); len(bundle) > 0 {
+		tlsConfig.RootCAs = x509.NewCertPool()
+		tlsConfig.RootCAs.AppendCertsFromPEM(bundle)
+	}
+	return target, &http.Transport{TLSClientConfig: tlsConfig}, nil
+}
@@ -0,0 +1,351 @@
+// Copyright 2018 Comcast Cable Communications Management, LLC
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//     http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/Comcast/kuberhealthy/v2/pkg/apiclient"
+	"github.com/Comcast/kuberhealthy/v2/pkg/health"
+	"github.com/Comcast/kuberhealthy/v2/pkg/runhistory"
+)
+
+// silenceBody is a silence request for two hours
+const silenceBody = `{"duration": "2h", "reason": "INC-123", "author": "oncall"}`
+
+// TestAPITokenRejection makes sure the API is disabled without a token and that requests without the
+// configured token are refused
+func TestAPITokenRejection(t *testing.T) {
+	h := newAPIHarness(t, "kuberhealthy-a", kuberhealthyPod("kuberhealthy-a", "127.0.0.1"))
+	defer h.close()
+	h.addCheck("test-check")
+
+	for _, token := range []string{"", "wrong-token", testAPIToken + "x"} {
+		w := h.request(http.MethodGet, apiPrefix+"silences", token, "")
+		if w.Code != http.StatusUnauthorized {
+			t.Fatal("Expected status", http.StatusUnauthorized, "for token", token, "but got", w.Code)
+		}
+	}
+
+	w := h.request(http.MethodGet, apiPrefix+"silences", testAPIToken, "")
+	if w.Code != http.StatusOK {
+		t.Fatal("Expected status", http.StatusOK, "for the configured token but got", w.Code, w.Body.String())
+	}
+
+	apiToken = ""
+	w = h.request(http.MethodGet, apiPrefix+"silences", "", "")
+	if w.Code != http.StatusNotFound {
+		t.Fatal("Expected status", http.StatusNotFound, "when no API token is configured but got", w.Code)
+	}
+}
+
+// TestAPIForwardToMaster makes sure a pod that is not the master forwards API requests to the master with
+// the caller's credentials and passes the master's response back
+func TestAPIForwardToMaster(t *testing.T) {
+	var forwardedBy, authorization, path string
+	master := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		forwardedBy = r.Header.Get(forwardedHeader)
+		authorization = r.Header.Get("Authorization")
+		path = r.URL.Path
+		writeAPIResponse(w, http.StatusCreated, health.Silence{Name: "from-master"})
+	}))
+	defer master.Close()
+	masterURL, err := url.Parse(master.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	masterHost, masterPort, err := net.SplitHostPort(masterURL.Host)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	h := newAPIHarness(t, "kuberhealthy-b", kuberhealthyPod("kuberhealthy-a", masterHost), kuberhealthyPod("kuberhealthy-b", "127.0.0.2"))
+	defer h.close()
+	h.kh.ListenAddr = ":" + masterPort
+
+	w := h.request(http.MethodPost, apiPrefix+"checks/"+defaultNamespace+"/test-check/silence", testAPIToken, silenceBody)
+	if w.Code != http.StatusCreated {
+		t.Fatal("Expected the master's status", http.StatusCreated, "but got", w.Code, w.Body.String())
+	}
+	silence := health.Silence{}
+	err = json.Unmarshal(w.Body.Bytes(), &silence)
+	if err != nil {
+		t.Fatal("Unable to decode the forwarded response:", err)
+	}
+	if silence.Name != "from-master" {
+		t.Fatal("Expected the master's response but got", w.Body.String())
+	}
+	if forwardedBy != "kuberhealthy-b" {
+		t.Fatal("Expected the request to be forwarded by kuberhealthy-b but got", forwardedBy)
+	}
+	if authorization != "Bearer "+testAPIToken {
+		t.Fatal("Expected the caller's credentials to be forwarded but got", authorization)
+	}
+	if path != apiPrefix+"checks/"+defaultNamespace+"/test-check/silence" {
+		t.Fatal("Expected the request path to be forwarded but got", path)
+	}
+	if len(h.silences.list()) != 0 {
+		t.Fatal("Expected the master to create the silence but the forwarding pod created", h.silences.list())
+	}
+
+	// a request that the master already forwarded is never forwarded again
+	r := h.newRequest(http.MethodGet, apiPrefix+"silences", testAPIToken, "")
+	r.Header.Set(forwardedHeader, "kuberhealthy-a")
+	r.RemoteAddr = net.JoinHostPort(masterHost, "40000")
+	rw := h.serve(r)
+	if rw.Code != http.StatusServiceUnavailable {
+		t.Fatal("Expected status", http.StatusServiceUnavailable, "for a request forwarded twice but got", rw.Code)
+	}
+}
+
+// TestAPICreateAndExpireSilence makes sure silences created through the API are named by the API server,
+// silence the check, and are deleted by the master once they expire
+func TestAPICreateAndExpireSilence(t *testing.T) {
+	h := newAPIHarness(t, "kuberhealthy-a", kuberhealthyPod("kuberhealthy-a", "127.0.0.1"))
+	defer h.close()
+	h.addCheck("test-check")
+
+	w := h.request(http.MethodPost, apiPrefix+"checks/"+defaultNamespace+"/missing-check/silence", testAPIToken, silenceBody)
+	if w.Code != http.StatusNotFound {
+		t.Fatal("Expected status", http.StatusNotFound, "when silencing a missing check but got", w.Code)
+	}
+
+	// silences created back to back must not collide
+	names := make(map[string]bool)
+	for i := 0; i < 2; i++ {
+		w := h.request(http.MethodPost, apiPrefix+"checks/"+defaultNamespace+"/test-check/silence", testAPIToken, silenceBody)
+		if w.Code != http.StatusCreated {
+			t.Fatal("Expected status", http.StatusCreated, "but got", w.Code, w.Body.String())
+		}
+		silence := health.Silence{}
+		err := json.Unmarshal(w.Body.Bytes(), &silence)
+		if err != nil {
+			t.Fatal("Unable to decode the created silence:", err)
+		}
+		if names[silence.Name] {
+			t.Fatal("Expected silences created back to back to have distinct names but got", silence.Name, "twice")
+		}
+		names[silence.Name] = true
+	}
+	if len(h.silences.list()) != 2 {
+		t.Fatal("Expected 2 khsilence resources but got", len(h.silences.list()))
+	}
+
+	active := silences.Get(defaultNamespace, "test-check")
+	if active == nil {
+		t.Fatal("Expected the check to be silenced after creating a silence")
+	}
+	if active.Reason != "INC-123" || active.Author != "oncall" {
+		t.Fatal("Expected the silence reason and author to be kept but got", active.Reason, active.Author)
+	}
+
+	// expire both silences and make sure the master deletes them and lifts the silence
+	for _, s := range h.silences.list() {
+		s.Spec.Expires = time.Now().Add(-time.Minute)
+		h.silences.update(s)
+	}
+	err := h.kh.refreshSilences()
+	if err != nil {
+		t.Fatal("Unable to refresh silences:", err)
+	}
+	if len(h.silences.list()) != 0 {
+		t.Fatal("Expected the master to delete expired silences but found", len(h.silences.list()))
+	}
+	if silences.Get(defaultNamespace, "test-check") != nil {
+		t.Fatal("Expected the check to no longer be silenced once its silences expired")
+	}
+}
+
+// TestAPIForwardWithClientCertificates makes sure requests forwarded to the master present the server
+// certificate, so that forwarding works when the master verifies client certificates
+func TestAPIForwardWithClientCertificates(t *testing.T) {
+	h := newAPIHarness(t, "kuberhealthy-b", kuberhealthyPod("kuberhealthy-a", "127.0.0.1"), kuberhealthyPod("kuberhealthy-b", "127.0.0.2"))
+	defer h.close()
+	h.useTLS()
+
+	savedReportingURL := externalCheckReportingURL
+	defer func() { externalCheckReportingURL = savedReportingURL }()
+	externalCheckReportingURL = "https://127.0.0.1/externalCheckStatus"
+
+	var peerName string
+	master := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.TLS != nil && len(r.TLS.PeerCertificates) > 0 {
+			peerName = r.TLS.PeerCertificates[0].Subject.CommonName
+		}
+		writeAPIResponse(w, http.StatusOK, map[string]health.Silence{})
+	}))
+	master.TLS = tlsReloader.ServerConfig(true)
+	master.StartTLS()
+	defer master.Close()
+	masterURL, err := url.Parse(master.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, masterPort, err := net.SplitHostPort(masterURL.Host)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.kh.ListenAddr = ":" + masterPort
+
+	w := h.request(http.MethodGet, apiPrefix+"silences", testAPIToken, "")
+	if w.Code != http.StatusOK {
+		t.Fatal("Expected the master's status", http.StatusOK, "but got", w.Code, w.Body.String())
+	}
+	if peerName != "kuberhealthy" {
+		t.Fatal("Expected the forwarding pod to present its certificate but the master saw", peerName)
+	}
+}
+
+// TestAPIForwardedHeaderFromCaller makes sure callers can not mark their requests as forwarded to have them
+// served by a pod that does not run the check, while requests forwarded by other pods are served where they land
+func TestAPIForwardedHeaderFromCaller(t *testing.T) {
+	var forwardedBy string
+	var served int
+	owner := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		served++
+		forwardedBy = r.Header.Get(forwardedHeader)
+		writeAPIResponse(w, http.StatusOK, health.CheckDetails{})
+	}))
+	defer owner.Close()
+	ownerURL, err := url.Parse(owner.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ownerHost, ownerPort, err := net.SplitHostPort(ownerURL.Host)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	h := newAPIHarness(t, "kuberhealthy-b", kuberhealthyPod("kuberhealthy-a", ownerHost), kuberhealthyPod("kuberhealthy-b", "127.0.0.2"))
+	defer h.close()
+	h.kh.ListenAddr = ":" + ownerPort
+	h.shard("kuberhealthy-a", "kuberhealthy-b")
+
+	// find a check that the other pod runs
+	var name string
+	for i := 0; i < 100 && len(name) == 0; i++ {
+		candidate := "check-" + strconv.Itoa(i)
+		if shards.owner(defaultNamespace, candidate) == "kuberhealthy-a" {
+			name = candidate
+		}
+	}
+	if len(name) == 0 {
+		t.Fatal("Expected kuberhealthy-a to own one of 100 checks")
+	}
+	path := apiPrefix + "checks/" + defaultNamespace + "/" + name
+
+	// a caller claiming the request was forwarded by the owner is still routed to the owner
+	r := h.newRequest(http.MethodGet, path, testAPIToken, "")
+	r.Header.Set(forwardedHeader, "kuberhealthy-a")
+	r.RemoteAddr = "192.0.2.10:40000"
+	w := h.serve(r)
+	if w.Code != http.StatusOK || served != 1 {
+		t.Fatal("Expected the request to be routed to the owning shard but got", w.Code, "with", served, "requests served by the owner")
+	}
+	if forwardedBy != "kuberhealthy-b" {
+		t.Fatal("Expected the request to be forwarded by kuberhealthy-b but got", forwardedBy)
+	}
+
+	// a request forwarded by the owner itself is served here so that it is never forwarded back
+	r = h.newRequest(http.MethodGet, path, testAPIToken, "")
+	r.Header.Set(forwardedHeader, "kuberhealthy-a")
+	r.RemoteAddr = net.JoinHostPort(ownerHost, "40000")
+	w = h.serve(r)
+	if served != 1 {
+		t.Fatal("Expected a request forwarded by another pod to be served where it landed but it was forwarded again")
+	}
+	if w.Code != http.StatusNotFound {
+		t.Fatal("Expected status", http.StatusNotFound, "for a check this pod does not run but got", w.Code)
+	}
+}
+
+// TestAPITriggerRun makes sure runs can be triggered through the API, that triggering a check that is not
+// running is refused, and that only one triggered run can be queued at a time
+func TestAPITriggerRun(t *testing.T) {
+	h := newAPIHarness(t, "kuberhealthy-a", kuberhealthyPod("kuberhealthy-a", "127.0.0.1"))
+	defer h.close()
+	h.addCheck("test-check")
+
+	w := h.request(http.MethodPost, apiPrefix+"checks/"+defaultNamespace+"/test-check/run", testAPIToken, "")
+	if w.Code != http.StatusAccepted {
+		t.Fatal("Expected status", http.StatusAccepted, "but got", w.Code, w.Body.String())
+	}
+	resp := apiclient.TriggerRunResponse{}
+	err := json.Unmarshal(w.Body.Bytes(), &resp)
+	if err != nil {
+		t.Fatal("Unable to decode the triggered run:", err)
+	}
+	if len(resp.RunID) == 0 || resp.Name != "test-check" || resp.Namespace != defaultNamespace {
+		t.Fatal("Expected the run UUID and check of the triggered run but got", w.Body.String())
+	}
+	queued := <-h.kh.runTrigger(defaultNamespace, "test-check")
+	if queued != resp.RunID {
+		t.Fatal("Expected the check to be triggered with run UUID", resp.RunID, "but got", queued)
+	}
+
+	w = h.request(http.MethodPost, apiPrefix+"checks/"+defaultNamespace+"/missing-check/run", testAPIToken, "")
+	if w.Code != http.StatusNotFound {
+		t.Fatal("Expected status", http.StatusNotFound, "when triggering a missing check but got", w.Code)
+	}
+
+	// the check never picks up the first of these runs, so the second finds it still queued
+	w = h.request(http.MethodPost, apiPrefix+"checks/"+defaultNamespace+"/test-check/run", testAPIToken, "")
+	if w.Code != http.StatusAccepted {
+		t.Fatal("Expected status", http.StatusAccepted, "but got", w.Code, w.Body.String())
+	}
+	w = h.request(http.MethodPost, apiPrefix+"checks/"+defaultNamespace+"/test-check/run", testAPIToken, "")
+	if w.Code != http.StatusConflict {
+		t.Fatal("Expected status", http.StatusConflict, "while a run is already queued but got", w.Code, w.Body.String())
+	}
+}
+
+// TestAPIGetRun makes sure the state of a run can be looked up by its run UUID, that unknown runs are not found,
+// and that runs can not be looked up without the API token
+func TestAPIGetRun(t *testing.T) {
+	h := newAPIHarness(t, "kuberhealthy-a", kuberhealthyPod("kuberhealthy-a", "127.0.0.1"))
+	defer h.close()
+	h.kh.runHistory.Start("run-1", defaultNamespace, "test-check")
+	h.kh.runHistory.Finish("run-1", runhistory.PhaseFailed, []string{"check failed"})
+
+	w := h.request(http.MethodGet, apiPrefix+"runs/run-1", testAPIToken, "")
+	if w.Code != http.StatusOK {
+		t.Fatal("Expected status", http.StatusOK, "but got", w.Code, w.Body.String())
+	}
+	run := runhistory.Run{}
+	err := json.Unmarshal(w.Body.Bytes(), &run)
+	if err != nil {
+		t.Fatal("Unable to decode the run:", err)
+	}
+	if run.ID != "run-1" || run.Name != "test-check" || run.Namespace != defaultNamespace || run.Phase != runhistory.PhaseFailed {
+		t.Fatal("Expected the failed run of test-check but got", w.Body.String())
+	}
+	if len(run.Errors) != 1 || run.Errors[0] != "check failed" {
+		t.Fatal("Expected the errors of the run but got", run.Errors)
+	}
+
+	w = h.request(http.MethodGet, apiPrefix+"runs/unknown-run", testAPIToken, "")
+	if w.Code != http.StatusNotFound {
+		t.Fatal("Expected status", http.StatusNotFound, "for an unknown run but got", w.Code)
+	}
+
+	w = h.request(http.MethodGet, apiPrefix+"runs/run-1", "", "")
+	if w.Code != http.StatusUnauthorized {
+		t.Fatal("Expected status", http.StatusUnauthorized, "without the API token but got", w.Code)
+	}
+}
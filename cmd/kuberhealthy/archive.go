@@ -0,0 +1,87 @@
+// Copyright 2018 Comcast Cable Communications Management, LLC
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//     http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/Comcast/kuberhealthy/v2/pkg/archive"
+)
+
+// podLogsCheck is implemented by checks that capture the logs of the pods of their failed runs
+type podLogsCheck interface {
+	PodLogs() map[string]string
+}
+
+// archiveRun uploads the state of a check after a run, along with the logs of its checker pod if the run
+// failed, to the archive.  The upload happens in the background so that a slow bucket does not hold up the
+// next run.  Does nothing when archival is disabled.
+func (k *Kuberhealthy) archiveRun(c KuberhealthyCheck) {
+	if archiver == nil {
+		return
+	}
+	logger := checkLogger(c)
+
+	details, err := getCheckState(c)
+	if err != nil {
+		logger.Errorln("Error fetching check state to archive:", err)
+		return
+	}
+	run := archive.Run{
+		Check:     c.Name(),
+		Namespace: c.CheckNamespace(),
+		RunID:     details.CurrentUUID,
+		Finished:  time.Now(),
+		Details:   details,
+	}
+	if plc, ok := c.(podLogsCheck); ok && !details.LastRunOK {
+		run.PodLogs = plc.PodLogs()
+	}
+
+	go func() {
+		err := archiver.Archive(run)
+		if err != nil {
+			logger.Errorln("Error archiving check run:", err)
+		}
+	}()
+}
+
+// archivePruner deletes archived check runs that are older than the archive retention period on an interval
+// until the context for it is canceled
+func (k *Kuberhealthy) archivePruner(ctx context.Context) {
+	if archiver == nil || archiver.Retention <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(archivePruneInterval)
+	defer ticker.Stop()
+	log.Infoln("archive pruner: starting up")
+
+	for {
+		select {
+		case <-ticker.C:
+			deleted, err := archiver.Prune(time.Now())
+			if err != nil {
+				log.Errorln("archive pruner: Error when pruning archived check runs:", err)
+			}
+			if deleted > 0 {
+				log.Infoln("archive pruner: removed", deleted, "archived objects older than", archiver.Retention)
+			}
+		case <-ctx.Done():
+			log.Infoln("archive pruner: stopping")
+			return
+		}
+	}
+}
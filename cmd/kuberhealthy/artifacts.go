@@ -0,0 +1,237 @@
+// Copyright 2018 Comcast Cable Communications Management, LLC
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//     http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	log "github.com/sirupsen/logrus"
+	v1 "k8s.io/api/core/v1"
+	k8sErrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+
+	"github.com/Comcast/kuberhealthy/v2/pkg/checks/external/status"
+	"github.com/Comcast/kuberhealthy/v2/pkg/health"
+)
+
+// CheckArtifactsLabel is applied to the config maps that hold the artifacts of a check.  Its value is the
+// name of the check.
+const CheckArtifactsLabel = "kuberhealthy-check-artifacts"
+
+// artifactsRunIDAnnotation holds the UUID of the run whose artifacts are stored in a config map
+const artifactsRunIDAnnotation = "comcast.github.io/run-id"
+
+// maxArtifactSize is the largest artifact a checker pod can upload, and maxArtifactsSize the most that all
+// artifacts of a run can add up to.  Config maps can not hold more than 1MiB.
+const maxArtifactSize = 512 * 1024
+const maxArtifactsSize = 900 * 1024
+
+// errArtifactsTooLarge is returned when an artifact does not fit into the config map of its run
+var errArtifactsTooLarge = errors.New("artifacts of this run exceed the maximum size of " + fmt.Sprint(maxArtifactsSize) + " bytes")
+
+// artifactConfigMapName returns the name of the config map that holds the artifacts of a check
+func artifactConfigMapName(checkName string) string {
+	return sanitizeResourceName(checkName) + "-artifacts"
+}
+
+// externalCheckArtifactHandler accepts artifacts uploaded by external checker pods.  Callers are validated
+// the same way as status reports, so only the checker pod of a check's current run can upload artifacts for
+// it.  The artifact is stored in the check's artifact config map and referenced from the check's state.
+func (k *Kuberhealthy) externalCheckArtifactHandler(w http.ResponseWriter, r *http.Request) error {
+	logger := log.WithFields(log.Fields{LogFieldRequestID: uuid.New().String(), "transport": "http"})
+	logger.Infoln("Client connected to check artifact handler from", r.RemoteAddr, r.UserAgent())
+
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return nil
+	}
+
+	// when mutual TLS is enabled, artifacts must come with a verified client certificate
+	if tlsReloader != nil && tlsReloader.MutualTLS() && (r.TLS == nil || len(r.TLS.PeerCertificates) == 0) {
+		w.WriteHeader(http.StatusUnauthorized)
+		logger.Infoln("Client did not present a client certificate:", r.RemoteAddr)
+		auditRejectedReport(PodReportIPInfo{IP: sourceIP(r.RemoteAddr)}, "client did not present a client certificate")
+		return nil
+	}
+
+	ipReport, err := k.validateExternalRequest(r.RemoteAddr, bearerToken(r))
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		logger.Infoln("Failed to look up pod by IP:", r.RemoteAddr, err)
+		auditRejectedReport(PodReportIPInfo{IP: sourceIP(r.RemoteAddr)}, err.Error())
+		return nil
+	}
+	logger = reportLogger(logger, ipReport)
+
+	// artifact names become config map keys
+	name := r.URL.Query().Get(status.ArtifactNameParam)
+	if errs := validation.IsConfigMapKey(name); len(errs) > 0 {
+		w.WriteHeader(http.StatusBadRequest)
+		logger.Infoln("Client sent an invalid artifact name", name+":", strings.Join(errs, ", "))
+		auditRejectedReport(ipReport, "invalid artifact name "+name)
+		return nil
+	}
+
+	b, err := ioutil.ReadAll(io.LimitReader(r.Body, maxArtifactSize+1))
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		logger.Infoln("Failed to read artifact body:", err)
+		return nil
+	}
+	if len(b) > maxArtifactSize {
+		w.WriteHeader(http.StatusRequestEntityTooLarge)
+		logger.Infoln("Client sent an artifact larger than", maxArtifactSize, "bytes")
+		auditRejectedReport(ipReport, "artifact "+name+" is too large")
+		return nil
+	}
+
+	artifact, err := storeArtifact(ipReport, name, b)
+	if errors.Is(err, errArtifactsTooLarge) {
+		w.WriteHeader(http.StatusRequestEntityTooLarge)
+		logger.Infoln("Artifact", name, "was refused:", err)
+		auditRejectedReport(ipReport, err.Error())
+		return nil
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return fmt.Errorf("failed to store artifact %s: %w", name, err)
+	}
+
+	err = setCheckArtifact(ipReport.Name, ipReport.Namespace, artifact)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return fmt.Errorf("failed to record artifact %s on check state: %w", name, err)
+	}
+
+	w.WriteHeader(http.StatusOK)
+	logger.Infoln("Stored artifact", name, "of", len(b), "bytes in config map", artifact.ConfigMap)
+	return nil
+}
+
+// storeArtifact stores an artifact in the artifact config map of its check.  The config map only holds the
+// artifacts of one run, so the artifacts of earlier runs are dropped when a new run uploads its first one.
+func storeArtifact(ipReport PodReportIPInfo, name string, data []byte) (health.Artifact, error) {
+	configMapClient := kubernetesClient.CoreV1().ConfigMaps(ipReport.Namespace)
+	configMapName := artifactConfigMapName(ipReport.Name)
+	artifact := health.Artifact{
+		Name:      name,
+		Size:      len(data),
+		RunID:     ipReport.UUID,
+		ConfigMap: ipReport.Namespace + "/" + configMapName,
+		Uploaded:  time.Now(),
+	}
+
+	cm, err := configMapClient.Get(configMapName, metav1.GetOptions{})
+	if k8sErrors.IsNotFound(err) {
+		cm = &v1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      configMapName,
+				Namespace: ipReport.Namespace,
+				Labels:    map[string]string{CheckArtifactsLabel: ipReport.Name},
+			},
+		}
+		setArtifactData(cm, ipReport.UUID, name, data)
+		_, err = configMapClient.Create(cm)
+		return artifact, err
+	}
+	if err != nil {
+		return artifact, err
+	}
+
+	if cm.Annotations[artifactsRunIDAnnotation] != ipReport.UUID {
+		cm.BinaryData = nil
+	}
+	size := len(data)
+	for key, v := range cm.BinaryData {
+		if key != name {
+			size += len(v)
+		}
+	}
+	if size > maxArtifactsSize {
+		return artifact, errArtifactsTooLarge
+	}
+	setArtifactData(cm, ipReport.UUID, name, data)
+	_, err = configMapClient.Update(cm)
+	return artifact, err
+}
+
+// setArtifactData adds an artifact of the supplied run to a config map
+func setArtifactData(cm *v1.ConfigMap, runID string, name string, data []byte) {
+	if cm.Annotations == nil {
+		cm.Annotations = make(map[string]string)
+	}
+	cm.Annotations[artifactsRunIDAnnotation] = runID
+	if cm.BinaryData == nil {
+		cm.BinaryData = make(map[string][]byte)
+	}
+	cm.BinaryData[name] = data
+}
+
+// artifactsOfRun returns the artifacts that were uploaded by the supplied run
+func artifactsOfRun(artifacts []health.Artifact, runID string) []health.Artifact {
+	var runArtifacts []health.Artifact
+	for _, a := range artifacts {
+		if a.RunID == runID {
+			runArtifacts = append(runArtifacts, a)
+		}
+	}
+	return runArtifacts
+}
+
+// reapCheckArtifacts removes the artifact config maps of khchecks which no longer exist
+func (k *Kuberhealthy) reapCheckArtifacts() error {
+	configMaps, err := listConfigMaps(metav1.ListOptions{LabelSelector: CheckArtifactsLabel})
+	if err != nil {
+		return fmt.Errorf("error listing check artifacts for reaping: %w", err)
+	}
+	if len(configMaps.Items) == 0 {
+		return nil
+	}
+
+	khChecks, err := listChecks()
+	if err != nil {
+		return fmt.Errorf("error listing khChecks for artifact reaping: %w", err)
+	}
+
+	for _, cm := range configMaps.Items {
+		if !watchesNamespace(cm.GetNamespace()) {
+			continue
+		}
+		checkName := cm.Labels[CheckArtifactsLabel]
+		var found bool
+		for _, khCheck := range khChecks.Items {
+			if khCheck.GetName() == checkName && khCheck.GetNamespace() == cm.GetNamespace() {
+				found = true
+				break
+			}
+		}
+		if found {
+			continue
+		}
+
+		log.Infoln("khState reaper: removing artifacts", cm.GetName(), "in", cm.GetNamespace())
+		err = kubernetesClient.CoreV1().ConfigMaps(cm.GetNamespace()).Delete(cm.GetName(), &metav1.DeleteOptions{})
+		if err != nil && !k8sErrors.IsNotFound(err) {
+			log.Errorln("khState reaper: error removing artifacts for check", checkName+":", err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"reflect"
+	"sort"
+
+	log "github.com/sirupsen/logrus"
+	apiv1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/client-go/tools/cache"
+
+	"github.com/Comcast/kuberhealthy/v2/pkg/khcheckcrd"
+	"github.com/Comcast/kuberhealthy/v2/pkg/namespacefilter"
+)
+
+// CheckInformer keeps a cache of the khcheck resources in the namespaces that Kuberhealthy watches, so that
+// khchecks are discovered from a watch instead of by listing every khcheck in the cluster.  When the namespace
+// filter names every namespace it allows, only those namespaces are watched.  Otherwise khchecks are watched
+// cluster wide and the ones in namespaces that are not allowed are left out.
+type CheckInformer struct {
+	filter      namespacefilter.Filter
+	stores      []cache.Store      // a store of khchecks for each watched namespace
+	namespaces  cache.Store        // the namespaces and their labels.  Nil unless namespaces are selected by label.
+	controllers []cache.Controller // the controllers that keep the stores up to date
+	changes     chan struct{}      // signaled when a khcheck or the labels of a namespace change
+}
+
+// NewCheckInformer creates a CheckInformer that watches the khchecks in the namespaces allowed by the filter
+func NewCheckInformer(filter namespacefilter.Filter) *CheckInformer {
+	ci := &CheckInformer{
+		filter:  filter,
+		changes: make(chan struct{}, 1),
+	}
+
+	checkHandler := cache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) {
+			log.Debugln("khcheck informer saw an added event")
+			ci.notify()
+		},
+		UpdateFunc: func(oldObj, newObj interface{}) {
+			log.Debugln("khcheck informer saw a modified event")
+			ci.notify()
+		},
+		DeleteFunc: func(obj interface{}) {
+			log.Debugln("khcheck informer saw a deleted event")
+			ci.notify()
+		},
+	}
+
+	namespaces := filter.Namespaces()
+	if namespaces == nil {
+		namespaces = []string{metav1.NamespaceAll}
+	}
+	for _, namespace := range namespaces {
+		listWatch := cache.NewListWatchFromClient(khCheckClient.RestClient(), checkCRDResource, namespace, fields.Everything())
+		store, controller := cache.NewInformer(listWatch, &khcheckcrd.KuberhealthyCheck{}, 0, checkHandler)
+		ci.stores = append(ci.stores, store)
+		ci.controllers = append(ci.controllers, controller)
+	}
+
+	// khchecks come and go with the labels of their namespace
+	if filter.NeedsLabels() {
+		namespaceHandler := cache.ResourceEventHandlerFuncs{
+			AddFunc: func(obj interface{}) {
+				ci.notify()
+			},
+			UpdateFunc: func(oldObj, newObj interface{}) {
+				oldNamespace, ok := oldObj.(*apiv1.Namespace)
+				newNamespace, ok2 := newObj.(*apiv1.Namespace)
+				if ok && ok2 && reflect.DeepEqual(oldNamespace.Labels, newNamespace.Labels) {
+					return
+				}
+				log.Debugln("khcheck informer saw namespace labels change")
+				ci.notify()
+			},
+			DeleteFunc: func(obj interface{}) {
+				ci.notify()
+			},
+		}
+		listWatch := cache.NewListWatchFromClient(kubernetesClient.CoreV1().RESTClient(), "namespaces", metav1.NamespaceAll, fields.Everything())
+		store, controller := cache.NewInformer(listWatch, &apiv1.Namespace{}, 0, namespaceHandler)
+		ci.namespaces = store
+		ci.controllers = append(ci.controllers, controller)
+	}
+
+	return ci
+}
+
+// notify signals a change without blocking.  Changes that come in before the last one was received are
+// collapsed into one.
+func (ci *CheckInformer) notify() {
+	select {
+	case ci.changes <- struct{}{}:
+	default:
+	}
+}
+
+// Changes returns a channel that is signaled when the watched khchecks change
+func (ci *CheckInformer) Changes() <-chan struct{} {
+	return ci.changes
+}
+
+// Run keeps the cache up to date until the stop channel is closed
+func (ci *CheckInformer) Run(stopCh <-chan struct{}) {
+	log.Infoln("khcheck informer starting for", ci.filter)
+	for _, controller := range ci.controllers {
+		go controller.Run(stopCh)
+	}
+	<-stopCh
+	log.Infoln("khcheck informer stopped")
+}
+
+// HasSynced returns true once every watched khcheck and namespace has been listed at least once
+func (ci *CheckInformer) HasSynced() bool {
+	for _, controller := range ci.controllers {
+		if !controller.HasSynced() {
+			return false
+		}
+	}
+	return true
+}
+
+// Watches returns true if khchecks in the namespace are watched
+func (ci *CheckInformer) Watches(namespace string) bool {
+	if !ci.filter.MatchesName(namespace) {
+		return false
+	}
+	if ci.namespaces == nil {
+		return true
+	}
+	obj, exists, err := ci.namespaces.GetByKey(namespace)
+	if err != nil || !exists {
+		return false
+	}
+	ns, ok := obj.(*apiv1.Namespace)
+	if !ok {
+		log.Warningln("khcheck informer found an item in its namespace cache that is not a namespace")
+		return false
+	}
+	return ci.filter.Matches(namespace, ns.Labels)
+}
+
+// List returns a copy of every watched khcheck, sorted by namespace and name
+func (ci *CheckInformer) List() *khcheckcrd.KuberhealthyCheckList {
+	l := &khcheckcrd.KuberhealthyCheckList{}
+	for _, store := range ci.stores {
+		for _, obj := range store.List() {
+			var check khcheckcrd.KuberhealthyCheck
+			switch c := obj.(type) {
+			case *khcheckcrd.KuberhealthyCheck:
+				c.DeepCopyInto(&check)
+			case khcheckcrd.KuberhealthyCheck:
+				c.DeepCopyInto(&check)
+			default:
+				log.Warningln("khcheck informer found an item in its cache that is not a khcheck")
+				continue
+			}
+			if !ci.Watches(check.Namespace) {
+				continue
+			}
+			l.Items = append(l.Items, check)
+		}
+	}
+	sort.Slice(l.Items, func(i, j int) bool {
+		if l.Items[i].Namespace != l.Items[j].Namespace {
+			return l.Items[i].Namespace < l.Items[j].Namespace
+		}
+		return l.Items[i].Name < l.Items[j].Name
+	})
+	return l
+}
+
+// listChecks lists the khchecks that Kuberhealthy watches from the khcheck informer, or from the API when
+// the informer is not running
+func listChecks() (*khcheckcrd.KuberhealthyCheckList, error) {
+	if checkInformer == nil {
+		return khCheckClient.List(metav1.ListOptions{}, checkCRDResource, "")
+	}
+	return checkInformer.List(), nil
+}
+
+// watchesNamespace returns true if Kuberhealthy watches the khchecks in the namespace.  Kuberhealthy leaves the
+// checker pods, khstates, and artifacts of namespaces it does not watch alone.
+func watchesNamespace(namespace string) bool {
+	return checkInformer == nil || checkInformer.Watches(namespace)
+}
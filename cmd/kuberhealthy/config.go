@@ -0,0 +1,194 @@
+// Copyright 2018 Comcast Cable Communications Management, LLC
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//     http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"reflect"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	v1 "k8s.io/api/core/v1"
+
+	"github.com/Comcast/kuberhealthy/v2/pkg/khconfig"
+	"github.com/Comcast/kuberhealthy/v2/pkg/notify"
+)
+
+// configReloadInterval is how often the config file is checked for changes
+var configReloadInterval = time.Second * 10
+
+// flagSettings are the settings set by flags and environment variables, which the config file is applied over.
+// Settings removed from the config file go back to these.
+var flagSettings operatorSettings
+
+// operatorSettings are the settings that can be changed in the config file while Kuberhealthy is running
+type operatorSettings struct {
+	logLevel             log.Level
+	defaultRunInterval   time.Duration
+	defaultRunTimeout    time.Duration
+	notificationConfig   *notify.Config
+	notificationThrottle time.Duration
+	notificationRenotify time.Duration
+	checkPodLabels       map[string]string
+	checkPodAnnotations  map[string]string
+	checkNodeSelector    map[string]string
+	checkTolerations     []v1.Toleration
+	checkPriorityClass   string
+	podDeleteGracePeriod time.Duration
+	podForceDeleteAfter  time.Duration
+}
+
+// currentSettings returns the settings currently in use
+func currentSettings() operatorSettings {
+	return operatorSettings{
+		logLevel:             log.GetLevel(),
+		defaultRunInterval:   defaultRunInterval,
+		defaultRunTimeout:    defaultRunTimeout,
+		notificationConfig:   notificationConfig,
+		notificationThrottle: notificationThrottle,
+		notificationRenotify: notificationRenotify,
+		checkPodLabels:       checkPodLabels,
+		checkPodAnnotations:  checkPodAnnotations,
+		checkNodeSelector:    checkNodeSelector,
+		checkTolerations:     checkTolerations,
+		checkPriorityClass:   checkPriorityClass,
+		podDeleteGracePeriod: podDeleteGracePeriod,
+		podForceDeleteAfter:  podForceDeleteAfter,
+	}
+}
+
+// checkSettingsEqual returns true if two sets of settings configure checker pods the same way
+func checkSettingsEqual(a operatorSettings, b operatorSettings) bool {
+	return a.defaultRunInterval == b.defaultRunInterval &&
+		a.defaultRunTimeout == b.defaultRunTimeout &&
+		reflect.DeepEqual(a.checkPodLabels, b.checkPodLabels) &&
+		reflect.DeepEqual(a.checkPodAnnotations, b.checkPodAnnotations) &&
+		reflect.DeepEqual(a.checkNodeSelector, b.checkNodeSelector) &&
+		reflect.DeepEqual(a.checkTolerations, b.checkTolerations) &&
+		a.checkPriorityClass == b.checkPriorityClass &&
+		a.podDeleteGracePeriod == b.podDeleteGracePeriod &&
+		a.podForceDeleteAfter == b.podForceDeleteAfter
+}
+
+// settingsFromConfig returns the settings of flags and environment variables with the settings of the config
+// file applied over them
+func settingsFromConfig(c khconfig.Config) operatorSettings {
+	s := flagSettings
+	if len(c.LogLevel) > 0 {
+		s.logLevel, _ = log.ParseLevel(c.LogLevel) // validated when the config file is loaded
+	}
+	if c.DefaultRunInterval != nil {
+		s.defaultRunInterval = c.DefaultRunInterval.Duration
+	}
+	if c.DefaultTimeout != nil {
+		s.defaultRunTimeout = c.DefaultTimeout.Duration
+	}
+	if c.Notifications != nil {
+		s.notificationConfig = c.Notifications
+	}
+	if c.NotificationThrottle != nil {
+		s.notificationThrottle = c.NotificationThrottle.Duration
+	}
+	if c.NotificationRenotify != nil {
+		s.notificationRenotify = c.NotificationRenotify.Duration
+	}
+
+	p := c.PodDefaults
+	if p == nil {
+		return s
+	}
+	if p.Labels != nil {
+		s.checkPodLabels = p.Labels
+	}
+	if p.Annotations != nil {
+		s.checkPodAnnotations = p.Annotations
+	}
+	if p.NodeSelector != nil {
+		s.checkNodeSelector = p.NodeSelector
+	}
+	if p.Tolerations != nil {
+		s.checkTolerations = p.Tolerations
+	}
+	if len(p.PriorityClassName) > 0 {
+		s.checkPriorityClass = p.PriorityClassName
+	}
+	if p.DeleteGracePeriod != nil {
+		s.podDeleteGracePeriod = p.DeleteGracePeriod.Duration
+	}
+	if p.ForceDeleteAfter != nil {
+		s.podForceDeleteAfter = p.ForceDeleteAfter.Duration
+	}
+	return s
+}
+
+// applyConfig puts the settings of the config file into use.  Notification settings are handed to the
+// notification dispatcher, if one is running.
+func applyConfig(c khconfig.Config) error {
+	s := settingsFromConfig(c)
+
+	// build the notifier before changing anything so that a bad webhook leaves the settings as they were
+	var sender notify.Sender = discardNotifications{}
+	if s.notificationConfig != nil {
+		notifier, err := notify.New(*s.notificationConfig)
+		if err != nil {
+			return err
+		}
+		sender = notifier
+	}
+
+	if s.logLevel != log.GetLevel() {
+		log.Infoln("config: setting log level to", s.logLevel)
+		log.SetLevel(s.logLevel)
+	}
+	defaultRunInterval = s.defaultRunInterval
+	defaultRunTimeout = s.defaultRunTimeout
+	notificationConfig = s.notificationConfig
+	notificationThrottle = s.notificationThrottle
+	notificationRenotify = s.notificationRenotify
+	checkPodLabels = s.checkPodLabels
+	checkPodAnnotations = s.checkPodAnnotations
+	checkNodeSelector = s.checkNodeSelector
+	checkTolerations = s.checkTolerations
+	checkPriorityClass = s.checkPriorityClass
+	podDeleteGracePeriod = s.podDeleteGracePeriod
+	podForceDeleteAfter = s.podForceDeleteAfter
+
+	if notificationDispatcher != nil {
+		notificationDispatcher.Reconfigure(sender, notificationThrottle, notificationRenotify)
+	}
+	return nil
+}
+
+// reloadConfig applies a changed config file and returns true if checks must be restarted to pick up the
+// changed settings
+func reloadConfig(c khconfig.Config) (bool, error) {
+	before := currentSettings()
+	if len(c.ListenAddress) > 0 && c.ListenAddress != listenAddress {
+		log.Warningln("config: the listen address changed to", c.ListenAddress, "and will be used once Kuberhealthy restarts")
+	}
+	err := applyConfig(c)
+	if err != nil {
+		return false, err
+	}
+	if notificationDispatcher == nil && notificationConfig != nil {
+		log.Warningln("config: notifications were added to the config file and will be sent once Kuberhealthy restarts")
+	}
+	return !checkSettingsEqual(before, currentSettings()), nil
+}
+
+// discardNotifications is the notification sender used when notifications are removed from the config file
+type discardNotifications struct{}
+
+// Notify drops the notification
+func (discardNotifications) Notify(ctx context.Context, e notify.Event, channels []string) error {
+	return nil
+}
@@ -17,37 +17,64 @@ import (
 	"time"
 
 	log "github.com/sirupsen/logrus"
-	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 
 	"github.com/Comcast/kuberhealthy/v2/pkg/health"
-	"github.com/Comcast/kuberhealthy/v2/pkg/khstatecrd"
+	"github.com/Comcast/kuberhealthy/v2/pkg/statestore"
 )
 
-// setCheckStateResource puts a check state's state into the specified CRD resource.  It sets the AuthoritativePod
-// to the server's hostname and sets the LastUpdate time to now.
-func setCheckStateResource(checkName string, checkNamespace string, state health.CheckDetails) error {
+// setCheckStateResource puts a check state's state into the state store.  It sets the AuthoritativePod
+// to the server's hostname and sets the LastUpdate time to now.  Only the fields of state that differ from
+// base, the state it was made from, are written over the stored state.
+func setCheckStateResource(checkName string, checkNamespace string, base health.CheckDetails, state health.CheckDetails) error {
 
 	name := sanitizeResourceName(checkName)
 
-	// we must fetch the existing state to use the current resource version
-	// int found within
-	existingState, err := khStateClient.Get(metav1.GetOptions{}, stateCRDResource, name, checkNamespace)
-	if err != nil {
-		return errors.New("Error retrieving CRD for: " + name + " " + err.Error())
-	}
-	resourceVersion := existingState.GetResourceVersion()
-
 	// set the pod name that wrote the khstate
 	state.AuthoritativePod = podHostname
 	state.LastRun = time.Now() // set the time the khstate was last
 
-	khState := khstatecrd.NewKuberhealthyState(name, state)
-	khState.SetResourceVersion(resourceVersion)
-	// TODO - if "try again" message found in error, then try again
-
 	log.Debugln(checkNamespace, checkName, "writing khstate with ok:", state.OK, "and errors:", state.Errors, "at last run:", state.LastRun)
-	_, err = khStateClient.Update(&khState, stateCRDResource, name, checkNamespace)
-	return err
+	return stateStore.Update(name, checkNamespace, base, state)
+}
+
+// setCheckProgress records an in-progress update on a check's khstate.  The LastRun time is left alone
+// because it is used to detect when a checker pod has sent its final report.
+func setCheckProgress(checkName string, checkNamespace string, progress health.Progress) error {
+
+	name := sanitizeResourceName(checkName)
+
+	existingState, err := stateStore.Get(name, checkNamespace)
+	if err != nil {
+		return errors.New("Error retrieving khstate for: " + name + " " + err.Error())
+	}
+	base := existingState.DeepCopy()
+	existingState.Progress = &progress
+
+	log.Debugln(checkNamespace, checkName, "writing khstate progress:", progress.Percent, progress.Message)
+	return stateStore.Update(name, checkNamespace, base, existingState)
+}
+
+// setCheckArtifact records an artifact uploaded by the current run on a check's khstate.  Artifacts of earlier
+// runs are dropped and an artifact that was uploaded again under the same name replaces the earlier upload.
+func setCheckArtifact(checkName string, checkNamespace string, artifact health.Artifact) error {
+
+	name := sanitizeResourceName(checkName)
+
+	existingState, err := stateStore.Get(name, checkNamespace)
+	if err != nil {
+		return errors.New("Error retrieving khstate for: " + name + " " + err.Error())
+	}
+	base := existingState.DeepCopy()
+	artifacts := []health.Artifact{}
+	for _, a := range artifactsOfRun(existingState.Artifacts, artifact.RunID) {
+		if a.Name != artifact.Name {
+			artifacts = append(artifacts, a)
+		}
+	}
+	existingState.Artifacts = append(artifacts, artifact)
+
+	log.Debugln(checkNamespace, checkName, "writing khstate artifact:", artifact.Name, artifact.Size)
+	return stateStore.Update(name, checkNamespace, base, existingState)
 }
 
 // sanitizeResourceName cleans up the check names for use in CRDs.
@@ -62,50 +89,41 @@ func sanitizeResourceName(c string) string {
 	return strings.Replace(nameLower, " ", "-", -1)
 }
 
-// ensureStateResourceExists checks for the existence of the specified resource and creates it if it does not exist
+// ensureStateResourceExists checks for the existence of the specified check state and creates it if it does not exist
 func ensureStateResourceExists(checkName string, checkNamespace string) error {
 	name := sanitizeResourceName(checkName)
 
-	log.Debugln("Checking existence of custom resource:", name)
-	state, err := khStateClient.Get(metav1.GetOptions{}, stateCRDResource, name, checkNamespace)
-	if err != nil {
-		if strings.Contains(err.Error(), "not found") {
-			log.Infoln("Custom resource not found, creating resource:", name, " - ", err)
-			initialDetails := health.NewCheckDetails()
-			initialState := khstatecrd.NewKuberhealthyState(name, initialDetails)
-			_, err := khStateClient.Create(&initialState, stateCRDResource, checkNamespace)
-			if err != nil {
-				return errors.New("Error creating custom resource: " + name + ": " + err.Error())
-			}
-		} else {
-			return err
+	log.Debugln("Checking existence of check state:", name)
+	_, err := stateStore.Get(name, checkNamespace)
+	if errors.Is(err, statestore.ErrNotFound) {
+		log.Infoln("Check state not found, creating it:", name)
+		err = stateStore.Set(name, checkNamespace, health.NewCheckDetails())
+		if err != nil {
+			return errors.New("Error creating check state: " + name + ": " + err.Error())
 		}
+		return nil
 	}
-	if state.Spec.Errors != nil {
-		log.Debugln("khstate custom resource found:", name)
-	}
-	return nil
+	return err
 }
 
-// getCheckState retrieves the check values from the kuberhealthy khstate
-// custom resource
+// getCheckState retrieves the check values from the state store
 func getCheckState(c KuberhealthyCheck) (health.CheckDetails, error) {
 
 	var state = health.NewCheckDetails()
 	var err error
 	name := sanitizeResourceName(c.Name())
 
-	// make sure the CRD exists, even when checking status
+	// make sure the state exists, even when checking status
 	err = ensureStateResourceExists(c.Name(), c.CheckNamespace())
 	if err != nil {
-		return state, errors.New("Error validating CRD exists: " + name + " " + err.Error())
+		return state, errors.New("Error validating check state exists: " + name + " " + err.Error())
 	}
 
-	log.Debugln("Retrieving khstate custom resource for:", name)
-	khstate, err := khStateClient.Get(metav1.GetOptions{}, stateCRDResource, name, c.CheckNamespace())
+	log.Debugln("Retrieving check state for:", name)
+	state, err = stateStore.Get(name, c.CheckNamespace())
 	if err != nil {
-		return state, errors.New("Error retrieving custom khstate resource: " + name + " " + err.Error())
+		return state, errors.New("Error retrieving check state: " + name + " " + err.Error())
 	}
-	log.Debugln("Successfully retrieved khstate resource:", name)
-	return khstate.Spec, nil
+	log.Debugln("Successfully retrieved check state:", name)
+	return state, nil
 }
@@ -0,0 +1,144 @@
+// Copyright 2018 Comcast Cable Communications Management, LLC
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//     http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// debugChecksPath serves what the goroutine of each check is doing
+const debugChecksPath = "/debug/checks"
+
+// the phases of the goroutine running a check, as shown on the debug endpoint
+const (
+	checkPhaseStarting   = "starting"   // waiting out the delay before the first run
+	checkPhaseRunning    = "running"    // running the check
+	checkPhaseReporting  = "reporting"  // storing the result of a run
+	checkPhaseIdle       = "idle"       // waiting for the next run
+	checkPhaseRestarting = "restarting" // waiting to restart after the run loop panicked
+)
+
+// checkDebugStatus is what the goroutine running a check is doing
+type checkDebugStatus struct {
+	Namespace  string
+	Name       string
+	Scheduled  bool       // a goroutine on this instance is running the check
+	Phase      string     // what the goroutine is doing, such as idle, or creating or waiting on a checker pod
+	PhaseSince *time.Time `json:",omitempty"` // when the goroutine entered its phase
+	LastTick   *time.Time `json:",omitempty"` // when the goroutine last beat
+	NextTickBy *time.Time `json:",omitempty"` // when the goroutine is expected to beat again
+	NextRun    *time.Time `json:",omitempty"` // when the next run is scheduled, while the goroutine is idle
+	Interval   string     `json:",omitempty"` // the current interval between runs, including any backoff
+	Stuck      bool       // the goroutine did not beat again when expected
+}
+
+// debugChecksResponse is the response of the debug checks endpoint
+type debugChecksResponse struct {
+	Pod      string
+	IsMaster bool
+	Checks   []checkDebugStatus
+}
+
+// debugChecksHandler serves what the goroutine of every check on this instance is doing.  Checks that are
+// configured but have no goroutine are shown as not scheduled, and goroutines that are still running after
+// their check was removed are shown as well.
+func (k *Kuberhealthy) debugChecksHandler(w http.ResponseWriter, r *http.Request) error {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return nil
+	}
+
+	b, err := json.MarshalIndent(k.debugChecks(time.Now()), "", "  ")
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return err
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_, err = w.Write(b)
+	return err
+}
+
+// debugChecks returns what the goroutine of every check on this instance is doing
+func (k *Kuberhealthy) debugChecks(now time.Time) debugChecksResponse {
+	response := debugChecksResponse{
+		Pod:      podHostname,
+		IsMaster: isMaster,
+		Checks:   []checkDebugStatus{},
+	}
+
+	heartbeats := k.heartbeats.Statuses(now)
+	statuses := make(map[string]checkDebugStatus)
+	for _, s := range heartbeats {
+		status := checkDebugStatus{
+			Name:      s.Name,
+			Scheduled: true,
+			Phase:     s.Phase,
+			Stuck:     s.Missed,
+		}
+		if !s.PhaseSince.IsZero() {
+			status.PhaseSince = &s.PhaseSince
+		}
+		if !s.LastBeat.IsZero() {
+			status.LastTick = &s.LastBeat
+		}
+		if !s.Deadline.IsZero() {
+			status.NextTickBy = &s.Deadline
+		}
+		// an idle check beats with the time of its next run plus the grace period
+		if s.Phase == checkPhaseIdle && !s.Deadline.IsZero() {
+			nextRun := s.Deadline.Add(-staleCheckGrace)
+			status.NextRun = &nextRun
+		}
+		statuses[s.Name] = status
+	}
+
+	k.schedulesMu.Lock()
+	schedules := k.schedules
+	k.schedulesMu.Unlock()
+
+	for _, c := range k.Checks {
+		key := checkKey(c.CheckNamespace(), c.Name())
+		status, ok := statuses[key]
+		delete(statuses, key)
+		if !ok {
+			status.Phase = "not scheduled"
+		}
+		status.Namespace = c.CheckNamespace()
+		status.Name = c.Name()
+
+		// checks that run pods report which part of their run they are in
+		if pc, ok := c.(phasedCheck); ok && status.Phase == checkPhaseRunning && len(pc.Phase()) > 0 {
+			status.Phase = pc.Phase()
+		}
+		if schedule, ok := schedules[key]; ok {
+			status.Interval = schedule.Stats().Interval.String()
+		}
+		response.Checks = append(response.Checks, status)
+	}
+
+	// goroutines left running for checks that are no longer configured
+	for _, s := range heartbeats {
+		status, ok := statuses[s.Name]
+		if !ok {
+			continue
+		}
+		parts := strings.SplitN(s.Name, "/", 2)
+		if len(parts) == 2 {
+			status.Namespace, status.Name = parts[0], parts[1]
+		}
+		response.Checks = append(response.Checks, status)
+	}
+	return response
+}
@@ -0,0 +1,56 @@
+// Copyright 2018 Comcast Cable Communications Management, LLC
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//     http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"net/http"
+
+	log "github.com/sirupsen/logrus"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// dryRunHandler renders the checker pod for a khcheck as YAML without creating it, so that check authors can
+// see the environment variables, labels, and security settings Kuberhealthy applies.  The check is selected
+// with the namespace and name URL query parameters (i.e. /dryRun?namespace=kuberhealthy&name=my-check).
+func (k *Kuberhealthy) dryRunHandler(w http.ResponseWriter, r *http.Request) error {
+	log.Infoln("Client connected to dry run endpoint from", r.RemoteAddr, r.UserAgent())
+
+	values := r.URL.Query()
+	namespace := values.Get("namespace")
+	name := values.Get("name")
+	if len(namespace) == 0 || len(name) == 0 {
+		http.Error(w, "the namespace and name query parameters are required", http.StatusBadRequest)
+		return nil
+	}
+
+	khCheck, err := khCheckClient.Get(metav1.GetOptions{}, checkCRDResource, namespace, name)
+	if err != nil {
+		http.Error(w, "unable to fetch khcheck "+namespace+"/"+name+": "+err.Error(), http.StatusNotFound)
+		return err
+	}
+	khCheck, err = expandCheck(khCheck)
+	if err != nil {
+		http.Error(w, "unable to create khcheck "+namespace+"/"+name+" from its template: "+err.Error(), http.StatusBadRequest)
+		return err
+	}
+
+	// a fresh checker is built so that the dry run never touches a check that is currently running
+	b, err := newExternalChecker(khCheck).DryRunYAML()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return err
+	}
+
+	w.Header().Set("Content-Type", "application/yaml")
+	_, err = w.Write(b)
+	return err
+}
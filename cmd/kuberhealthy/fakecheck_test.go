@@ -51,7 +51,7 @@ func (fc *FakeCheck) CurrentStatus() (bool, []string) {
 	return fc.OK, fc.Errors
 }
 
-func (fc *FakeCheck) Run(c *kubernetes.Clientset) error {
+func (fc *FakeCheck) Run(c kubernetes.Interface) error {
 	if fc.ShouldHaveRunError {
 		return errors.New(fc.FakeError)
 	}
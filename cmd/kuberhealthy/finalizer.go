@@ -0,0 +1,152 @@
+// Copyright 2018 Comcast Cable Communications Management, LLC
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//     http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+	k8sErrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/Comcast/kuberhealthy/v2/pkg/checks/external"
+	"github.com/Comcast/kuberhealthy/v2/pkg/khcheckcrd"
+	"github.com/Comcast/kuberhealthy/v2/pkg/statestore"
+)
+
+// checkCleanupFinalizer is added to khchecks so that they are not removed until Kuberhealthy has deleted everything
+// it created for them
+const checkCleanupFinalizer = "comcast.github.io/check-cleanup"
+
+// hasCheckFinalizer returns true if a khcheck has the cleanup finalizer
+func hasCheckFinalizer(check *khcheckcrd.KuberhealthyCheck) bool {
+	for _, f := range check.Finalizers {
+		if f == checkCleanupFinalizer {
+			return true
+		}
+	}
+	return false
+}
+
+// ensureCheckFinalizer adds the cleanup finalizer to a khcheck that does not have it yet.  The khcheck is fetched
+// again before it is updated, so that the expanded template of a check is never written back to it.
+func ensureCheckFinalizer(namespace string, name string) error {
+	check, err := khCheckClient.Get(metav1.GetOptions{}, checkCRDResource, namespace, name)
+	if err != nil {
+		return fmt.Errorf("error fetching khcheck %s in %s to add its finalizer: %w", name, namespace, err)
+	}
+	if hasCheckFinalizer(check) || check.DeletionTimestamp != nil {
+		return nil
+	}
+	check.Finalizers = append(check.Finalizers, checkCleanupFinalizer)
+	_, err = khCheckClient.Update(check, checkCRDResource, namespace, name)
+	if err != nil {
+		return fmt.Errorf("error adding the finalizer to khcheck %s in %s: %w", name, namespace, err)
+	}
+	log.Infoln("Added the cleanup finalizer to khcheck", name, "in", namespace)
+	return nil
+}
+
+// finalizeCheck deletes the checker pods, ephemeral namespaces, service account, role, role binding, artifacts, and
+// khstate of a khcheck that is being deleted, then removes its cleanup finalizer so that the khcheck is removed.
+// The finalizer is kept when anything could not be deleted, so that the cleanup is retried by the reaper.
+func finalizeCheck(namespace string, name string) error {
+	var errs []string
+	collect := func(what string, err error) {
+		if err != nil && !k8sErrors.IsNotFound(err) && !errors.Is(err, statestore.ErrNotFound) {
+			errs = append(errs, "error deleting "+what+": "+err.Error())
+		}
+	}
+
+	checkSelector := external.KuberhealthyCheckNameLabel + "=" + name
+	pods, err := kubernetesClient.CoreV1().Pods(namespace).List(metav1.ListOptions{LabelSelector: checkSelector})
+	collect("checker pods", err)
+	if err == nil {
+		gracePeriod := int64(podDeleteGracePeriod.Seconds())
+		for _, p := range pods.Items {
+			collect("checker pod "+p.Name, kubernetesClient.CoreV1().Pods(namespace).Delete(p.Name, &metav1.DeleteOptions{GracePeriodSeconds: &gracePeriod}))
+		}
+	}
+
+	// ephemeral namespaces hold the copied secrets, config maps, and service accounts of their runs.  Checks
+	// can not create them in namespaced mode.
+	if !namespacedMode {
+		namespaces, err := kubernetesClient.CoreV1().Namespaces().List(metav1.ListOptions{LabelSelector: external.EphemeralNamespaceLabel + "=" + namespace + "," + checkSelector})
+		collect("ephemeral namespaces", err)
+		if err == nil {
+			propagation := metav1.DeletePropagationBackground
+			for _, ns := range namespaces.Items {
+				collect("ephemeral namespace "+ns.Name, kubernetesClient.CoreV1().Namespaces().Delete(ns.Name, &metav1.DeleteOptions{PropagationPolicy: &propagation}))
+			}
+		}
+	}
+
+	policies, err := kubernetesClient.NetworkingV1().NetworkPolicies(namespace).List(metav1.ListOptions{LabelSelector: checkSelector})
+	collect("network policies", err)
+	if err == nil {
+		for _, p := range policies.Items {
+			collect("network policy "+p.Name, kubernetesClient.NetworkingV1().NetworkPolicies(namespace).Delete(p.Name, &metav1.DeleteOptions{}))
+		}
+	}
+
+	serviceAccount := external.CheckServiceAccountName(name)
+	collect("role binding "+serviceAccount, kubernetesClient.RbacV1().RoleBindings(namespace).Delete(serviceAccount, &metav1.DeleteOptions{}))
+	collect("role "+serviceAccount, kubernetesClient.RbacV1().Roles(namespace).Delete(serviceAccount, &metav1.DeleteOptions{}))
+	collect("service account "+serviceAccount, kubernetesClient.CoreV1().ServiceAccounts(namespace).Delete(serviceAccount, &metav1.DeleteOptions{}))
+	collect("artifacts", kubernetesClient.CoreV1().ConfigMaps(namespace).Delete(artifactConfigMapName(name), &metav1.DeleteOptions{}))
+	collect("khstate", stateStore.Delete(name, namespace))
+
+	if len(errs) > 0 {
+		return fmt.Errorf("error cleaning up khcheck %s in %s: %s", name, namespace, strings.Join(errs, ", "))
+	}
+
+	check, err := khCheckClient.Get(metav1.GetOptions{}, checkCRDResource, namespace, name)
+	if k8sErrors.IsNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("error fetching khcheck %s in %s to remove its finalizer: %w", name, namespace, err)
+	}
+	var finalizers []string
+	for _, f := range check.Finalizers {
+		if f != checkCleanupFinalizer {
+			finalizers = append(finalizers, f)
+		}
+	}
+	check.Finalizers = finalizers
+	_, err = khCheckClient.Update(check, checkCRDResource, namespace, name)
+	if err != nil {
+		return fmt.Errorf("error removing the finalizer from khcheck %s in %s: %w", name, namespace, err)
+	}
+	log.Infoln("Cleaned up khcheck", name, "in", namespace, "and removed its finalizer")
+	return nil
+}
+
+// finalizeDeletedChecks cleans up every khcheck that is being deleted and still has the cleanup finalizer
+func (k *Kuberhealthy) finalizeDeletedChecks() error {
+	khChecks, err := listChecks()
+	if err != nil {
+		return fmt.Errorf("error listing khChecks for finalizing: %w", err)
+	}
+	for _, c := range khChecks.Items {
+		if c.DeletionTimestamp == nil || !hasCheckFinalizer(&c) {
+			continue
+		}
+		err = finalizeCheck(c.Namespace, c.Name)
+		if err != nil {
+			log.Errorln("khState reaper:", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,71 @@
+// Copyright 2018 Comcast Cable Communications Management, LLC
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//     http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/Comcast/kuberhealthy/v2/pkg/grafana"
+)
+
+// grafanaHandler serves the Grafana JSON datasource protocol over the run history of the master.  The
+// datasource is tested with a GET of its root, and the search, query, and annotations endpoints are POSTed
+// to with JSON bodies.
+func (k *Kuberhealthy) grafanaHandler(w http.ResponseWriter, r *http.Request, path []string) error {
+	if len(path) == 0 {
+		return writeAPIResponse(w, http.StatusOK, struct{}{})
+	}
+	if len(path) != 1 {
+		return writeAPIError(w, http.StatusNotFound, "no Grafana endpoint at "+r.URL.Path)
+	}
+	if r.Method != http.MethodPost {
+		return writeAPIError(w, http.StatusMethodNotAllowed, "Grafana requests must be sent with POST")
+	}
+
+	switch path[0] {
+	case "search":
+		var req grafana.SearchRequest
+		if !decodeGrafanaRequest(w, r, &req) {
+			return nil
+		}
+		return writeAPIResponse(w, http.StatusOK, grafana.Search(k.allRuns(r), req.Target))
+	case "query":
+		var req grafana.QueryRequest
+		if !decodeGrafanaRequest(w, r, &req) {
+			return nil
+		}
+		return writeAPIResponse(w, http.StatusOK, grafana.Query(k.allRuns(r), req))
+	case "annotations":
+		var req grafana.AnnotationRequest
+		if !decodeGrafanaRequest(w, r, &req) {
+			return nil
+		}
+		return writeAPIResponse(w, http.StatusOK, grafana.Annotations(k.allRuns(r), req))
+	}
+	return writeAPIError(w, http.StatusNotFound, "no Grafana endpoint at "+r.URL.Path)
+}
+
+// decodeGrafanaRequest decodes the JSON body of a Grafana request and writes an error back to the caller
+// when it is malformed.  Returns false if the body could not be decoded.  Search requests may have an
+// empty body.
+func decodeGrafanaRequest(w http.ResponseWriter, r *http.Request, v interface{}) bool {
+	if r.ContentLength == 0 {
+		return true
+	}
+	err := json.NewDecoder(r.Body).Decode(v)
+	if err != nil {
+		writeAPIError(w, http.StatusBadRequest, "invalid request body: "+err.Error())
+		return false
+	}
+	return true
+}
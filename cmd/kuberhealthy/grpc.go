@@ -0,0 +1,206 @@
+// Copyright 2018 Comcast Cable Communications Management, LLC
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//     http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"io"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	log "github.com/sirupsen/logrus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	grpcstatus "google.golang.org/grpc/status"
+
+	"github.com/Comcast/kuberhealthy/v2/pkg/checks/external/reportgrpc"
+	"github.com/Comcast/kuberhealthy/v2/pkg/checks/external/status"
+	"github.com/Comcast/kuberhealthy/v2/pkg/health"
+	"github.com/Comcast/kuberhealthy/v2/pkg/tracing"
+)
+
+// reportServer implements the gRPC ReportService that external checker pods can use instead of
+// the HTTP /externalCheckStatus endpoint
+type reportServer struct {
+	kh *Kuberhealthy
+}
+
+// StartGRPCServer starts the gRPC reporting server at the configured listener and restarts it if it crashes
+func (k *Kuberhealthy) StartGRPCServer() {
+	log.Infoln("Configuring gRPC report server")
+
+	var opts []grpc.ServerOption
+	if tlsReloader != nil {
+		opts = append(opts, grpc.Creds(credentials.NewTLS(tlsReloader.ServerConfig(true))))
+	}
+	server := grpc.NewServer(opts...)
+	reportgrpc.RegisterReportServiceServer(server, &reportServer{kh: k})
+
+	// start gRPC server any time it exits
+	for {
+		log.Infoln("Starting gRPC report service on", grpcListenAddress)
+		listener, err := net.Listen("tcp", grpcListenAddress)
+		if err != nil {
+			log.Errorln("gRPC server ERROR:", err)
+			time.Sleep(time.Second / 2)
+			continue
+		}
+		err = server.Serve(listener)
+		if err != nil {
+			log.Errorln("gRPC server ERROR:", err)
+		}
+		time.Sleep(time.Second / 2)
+	}
+}
+
+// validateCaller looks up the calling pod from the peer address of a gRPC call
+func (s *reportServer) validateCaller(ctx context.Context, logger *log.Entry) (PodReportIPInfo, error) {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return PodReportIPInfo{}, grpcstatus.Error(codes.InvalidArgument, "unable to determine calling address")
+	}
+
+	logger.Infoln("validating external check status report from:", p.Addr.String())
+	ipReport, err := s.kh.validateExternalRequest(p.Addr.String(), bearerTokenFromContext(ctx))
+	if err != nil {
+		logger.Infoln("Failed to look up pod by IP:", p.Addr.String(), err)
+		auditRejectedReport(PodReportIPInfo{IP: sourceIP(p.Addr.String())}, err.Error())
+		return ipReport, grpcstatus.Error(codes.PermissionDenied, err.Error())
+	}
+	return ipReport, nil
+}
+
+// reportFromRequest converts a gRPC report into the report format used by the HTTP endpoint
+func reportFromRequest(r *reportgrpc.ReportRequest) status.Report {
+	state := status.Report{
+		OK:         r.Ok,
+		Errors:     r.Errors,
+		InProgress: !r.Final,
+		Progress:   int(r.ProgressPercent),
+		Message:    r.ProgressMessage,
+	}
+	if len(r.Measurements) > 0 {
+		state.Measurements = health.Measurements(r.Measurements)
+	}
+	if state.Errors == nil {
+		state.Errors = []string{}
+	}
+	for _, a := range r.Assertions {
+		state.Assertions = append(state.Assertions, health.Assertion{
+			Name:  a.Name,
+			OK:    a.Ok,
+			Error: a.Error,
+		})
+	}
+	return state
+}
+
+// bearerTokenFromContext returns the bearer token a checker pod sent in the authorization metadata of a gRPC call
+func bearerTokenFromContext(ctx context.Context) string {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok || len(md.Get("authorization")) == 0 {
+		return ""
+	}
+	return strings.TrimPrefix(md.Get("authorization")[0], "Bearer ")
+}
+
+// traceparentFromContext returns the trace context a checker pod sent in the metadata of a gRPC call, if any
+func traceparentFromContext(ctx context.Context) tracing.SpanContext {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok || len(md.Get(tracing.TraceparentHeader)) == 0 {
+		return tracing.SpanContext{}
+	}
+	sc, err := tracing.ParseTraceparent(md.Get(tracing.TraceparentHeader)[0])
+	if err != nil {
+		log.Debugln("Ignoring invalid traceparent in gRPC metadata:", err)
+	}
+	return sc
+}
+
+// store validates and stores a report from a checker pod
+func (s *reportServer) store(ctx context.Context, logger *log.Entry, ipReport PodReportIPInfo, state status.Report) (*reportgrpc.ReportResponse, error) {
+	err := state.Validate()
+	if err != nil {
+		logger.Infoln("Client sent an invalid report:", err)
+		auditRejectedReport(ipReport, "invalid report: "+err.Error())
+		return nil, grpcstatus.Error(codes.InvalidArgument, err.Error())
+	}
+
+	if state.InProgress {
+		err = s.kh.storeExternalProgress(logger, ipReport, state)
+	} else {
+		err = s.kh.storeExternalReport(logger, ipReport, state, traceparentFromContext(ctx))
+	}
+	if err != nil {
+		return nil, grpcstatus.Error(codes.Internal, err.Error())
+	}
+
+	logger.Infoln("Request completed successfully.")
+	return &reportgrpc.ReportResponse{Accepted: true}, nil
+}
+
+// Report handles a single final report from an external checker pod
+func (s *reportServer) Report(ctx context.Context, r *reportgrpc.ReportRequest) (*reportgrpc.ReportResponse, error) {
+	logger := log.WithFields(log.Fields{LogFieldRequestID: uuid.New().String(), "transport": "grpc"})
+	logger.Infoln("Client connected to gRPC check report handler")
+
+	ipReport, err := s.validateCaller(ctx, logger)
+	if err != nil {
+		return nil, err
+	}
+	logger = reportLogger(logger, ipReport)
+	logger.Infoln("Calling pod validated")
+
+	// unary reports are always final
+	state := reportFromRequest(r)
+	state.InProgress = false
+	return s.store(ctx, logger, ipReport, state)
+}
+
+// StreamReport handles a stream of progress updates followed by a final report from an external checker pod
+func (s *reportServer) StreamReport(stream reportgrpc.ReportService_StreamReportServer) error {
+	logger := log.WithFields(log.Fields{LogFieldRequestID: uuid.New().String(), "transport": "grpc"})
+	logger.Infoln("Client connected to gRPC check report stream")
+
+	ipReport, err := s.validateCaller(stream.Context(), logger)
+	if err != nil {
+		return err
+	}
+	logger = reportLogger(logger, ipReport)
+	logger.Infoln("Calling pod validated")
+
+	for {
+		r, err := stream.Recv()
+		if err == io.EOF {
+			logger.Infoln("Client closed report stream without sending a final report")
+			return grpcstatus.Error(codes.InvalidArgument, "stream closed without a final report")
+		}
+		if err != nil {
+			logger.Infoln("Error receiving from report stream:", err)
+			return err
+		}
+
+		// progress updates are stored and the stream continues until a final report comes in
+		resp, err := s.store(stream.Context(), logger, ipReport, reportFromRequest(r))
+		if err != nil {
+			return err
+		}
+		if r.Final {
+			return stream.SendAndClose(resp)
+		}
+	}
+}
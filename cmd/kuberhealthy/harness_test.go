@@ -0,0 +1,366 @@
+// Copyright 2018 Comcast Cable Communications Management, LLC
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//     http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	apiv1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/Comcast/kuberhealthy/v2/pkg/health"
+	"github.com/Comcast/kuberhealthy/v2/pkg/khsilencecrd"
+	"github.com/Comcast/kuberhealthy/v2/pkg/khtls"
+	"github.com/Comcast/kuberhealthy/v2/pkg/statestore"
+)
+
+// the API token that requests made by the harness present
+const testAPIToken = "test-token"
+
+// apiHarness runs the API handlers of a Kuberhealthy pod against a fake kubernetes clientset, an in-memory
+// state store, and a fake khsilence API, so that requests can be served without a cluster.  The globals the
+// handlers read are replaced for the test and restored by close.
+type apiHarness struct {
+	t        *testing.T
+	client   *fake.Clientset
+	kh       *Kuberhealthy
+	silences *fakeSilenceAPI
+	dir      string
+	saved    harnessGlobals
+}
+
+// harnessGlobals are the globals the harness replaces
+type harnessGlobals struct {
+	kubernetesClient kubernetes.Interface
+	khSilenceClient  *khsilencecrd.KuberhealthySilenceClient
+	stateStore       statestore.StateStore
+	stateStoreType   string
+	apiToken         string
+	podHostname      string
+	podNamespace     string
+	isMaster         bool
+	shardChecks      bool
+	silences         *silenceCache
+	tlsReloader      *khtls.Reloader
+	shards           *shardTracker
+}
+
+// newAPIHarness creates a harness for the Kuberhealthy pod named podName.  The supplied pods are the running
+// Kuberhealthy pods, the first of which by name is the master.
+func newAPIHarness(t *testing.T, podName string, pods ...*apiv1.Pod) *apiHarness {
+	h := &apiHarness{
+		t:        t,
+		silences: newFakeSilenceAPI(),
+		saved: harnessGlobals{
+			kubernetesClient: kubernetesClient,
+			khSilenceClient:  khSilenceClient,
+			stateStore:       stateStore,
+			stateStoreType:   stateStoreType,
+			apiToken:         apiToken,
+			podHostname:      podHostname,
+			podNamespace:     podNamespace,
+			isMaster:         isMaster,
+			shardChecks:      shardChecks,
+			silences:         silences,
+			tlsReloader:      tlsReloader,
+			shards:           shards,
+		},
+	}
+
+	var objects []runtime.Object
+	for _, p := range pods {
+		objects = append(objects, p)
+	}
+	h.client = fake.NewSimpleClientset(objects...)
+
+	// the khsilence client only connects with a kubeconfig file, which points it at the fake khsilence API
+	dir, err := ioutil.TempDir("", "kuberhealthy-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.dir = dir
+	kubeConfig := filepath.Join(dir, "kubeconfig")
+	err = ioutil.WriteFile(kubeConfig, []byte("apiVersion: v1\nkind: Config\nclusters:\n- name: fake\n  cluster:\n    server: "+
+		h.silences.server.URL+"\ncontexts:\n- name: fake\n  context:\n    cluster: fake\ncurrent-context: fake\n"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	khSilenceClient, err = khsilencecrd.Client(silenceCRDGroup, silenceCRDVersion, kubeConfig, "")
+	if err != nil {
+		t.Fatal("Unable to create the khsilence client:", err)
+	}
+
+	kubernetesClient = h.client
+	stateStore = statestore.NewMemoryStore()
+	stateStoreType = "memory"
+	apiToken = testAPIToken
+	podHostname = podName
+	podNamespace = defaultNamespace
+	isMaster = len(pods) == 0 || pods[0].Name == podName
+	shardChecks = false
+	silences = &silenceCache{active: make(map[string]health.Silence)}
+	tlsReloader = nil
+	shards = &shardTracker{}
+
+	h.kh = NewKuberhealthy()
+	return h
+}
+
+// close restores the globals the harness replaced and stops the fake khsilence API
+func (h *apiHarness) close() {
+	kubernetesClient = h.saved.kubernetesClient
+	khSilenceClient = h.saved.khSilenceClient
+	stateStore = h.saved.stateStore
+	stateStoreType = h.saved.stateStoreType
+	apiToken = h.saved.apiToken
+	podHostname = h.saved.podHostname
+	podNamespace = h.saved.podNamespace
+	isMaster = h.saved.isMaster
+	shardChecks = h.saved.shardChecks
+	silences = h.saved.silences
+	tlsReloader = h.saved.tlsReloader
+	shards = h.saved.shards
+	h.silences.server.Close()
+	os.RemoveAll(h.dir)
+}
+
+// addCheck adds a fake check in the default namespace that API requests can refer to.  Like StartChecks, it
+// makes the channel that runs of the check are triggered through, which the returned check never reads.
+func (h *apiHarness) addCheck(name string) *FakeCheck {
+	fc := NewFakeCheck()
+	fc.CheckName = name
+	fc.Namespace = defaultNamespace
+	h.kh.AddCheck(fc)
+
+	h.kh.runTriggersMu.Lock()
+	defer h.kh.runTriggersMu.Unlock()
+	if h.kh.runTriggers == nil {
+		h.kh.runTriggers = make(map[string]chan string)
+	}
+	h.kh.runTriggers[checkKey(defaultNamespace, name)] = make(chan string, 1)
+	return fc
+}
+
+// request serves an API request with the supplied bearer token and returns the response
+func (h *apiHarness) request(method string, path string, token string, body string) *httptest.ResponseRecorder {
+	return h.serve(h.newRequest(method, path, token, body))
+}
+
+// newRequest creates an API request with the supplied bearer token
+func (h *apiHarness) newRequest(method string, path string, token string, body string) *http.Request {
+	r := httptest.NewRequest(method, path, strings.NewReader(body))
+	if len(token) > 0 {
+		r.Header.Set("Authorization", "Bearer "+token)
+	}
+	return r
+}
+
+// serve serves an API request and returns the response
+func (h *apiHarness) serve(r *http.Request) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	err := h.kh.apiHandler(w, r)
+	if err != nil {
+		h.t.Fatal("Expected the API handler to serve", r.Method, r.URL.Path, "but got", err)
+	}
+	return w
+}
+
+// shard splits checks across the supplied Kuberhealthy pods
+func (h *apiHarness) shard(members ...string) {
+	shardChecks = true
+	shards.setUpcoming(members)
+	shards.settle()
+}
+
+// useTLS writes a CA and a certificate signed by it for 127.0.0.1 to the harness directory and serves peer
+// connections with them, as Kuberhealthy does when started with TLS and a client CA bundle
+func (h *apiHarness) useTLS() {
+	ca, caKey, caPEM, _ := newTestCert(h.t, "ca", nil, nil)
+	_, _, certPEM, keyPEM := newTestCert(h.t, "kuberhealthy", ca, caKey)
+	files := map[string][]byte{"ca.crt": caPEM, "tls.crt": certPEM, "tls.key": keyPEM}
+	for name, contents := range files {
+		err := ioutil.WriteFile(filepath.Join(h.dir, name), contents, 0600)
+		if err != nil {
+			h.t.Fatal(err)
+		}
+	}
+
+	var err error
+	tlsReloader, err = khtls.NewReloader(filepath.Join(h.dir, "tls.crt"), filepath.Join(h.dir, "tls.key"), filepath.Join(h.dir, "ca.crt"))
+	if err != nil {
+		h.t.Fatal("Unable to load the test certificates:", err)
+	}
+}
+
+// newTestCert creates a certificate for 127.0.0.1 that is valid for server and client authentication and
+// signed by parent, or a self-signed CA if parent is nil
+func newTestCert(t *testing.T, name string, parent *x509.Certificate, parentKey *ecdsa.PrivateKey) (*x509.Certificate, *ecdsa.PrivateKey, []byte, []byte) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Minute),
+		NotAfter:     time.Now().Add(time.Hour),
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	if parent == nil {
+		template.IsCA = true
+		template.KeyUsage = x509.KeyUsageCertSign
+		template.BasicConstraintsValid = true
+		parent, parentKey = template, key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, parentKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+	return cert, key, certPEM, keyPEM
+}
+
+// kuberhealthyPod returns a running Kuberhealthy pod with the supplied name and IP
+func kuberhealthyPod(name string, ip string) *apiv1.Pod {
+	return &apiv1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: defaultNamespace,
+			Labels:    map[string]string{"app": "kuberhealthy"},
+		},
+		Status: apiv1.PodStatus{Phase: apiv1.PodRunning, PodIP: ip},
+	}
+}
+
+// fakeSilenceAPI serves the khsilence resources of the API server from memory.  Silences created with a
+// generated name are named the way the API server names them.
+type fakeSilenceAPI struct {
+	server *httptest.Server
+
+	mu        sync.Mutex
+	silences  map[string]khsilencecrd.KuberhealthySilence // keyed by namespace/name
+	generated int
+}
+
+// newFakeSilenceAPI starts a fake khsilence API
+func newFakeSilenceAPI() *fakeSilenceAPI {
+	f := &fakeSilenceAPI{silences: make(map[string]khsilencecrd.KuberhealthySilence)}
+	f.server = httptest.NewServer(http.HandlerFunc(f.serveHTTP))
+	return f
+}
+
+// serveHTTP creates, lists, and deletes silences at /apis/<group>/<version>[/namespaces/<namespace>]/khsilences
+func (f *fakeSilenceAPI) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	path := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, "/apis/"+silenceCRDGroup+"/"+silenceCRDVersion), "/"), "/")
+	namespace := ""
+	if len(path) >= 2 && path[0] == "namespaces" {
+		namespace = path[1]
+		path = path[2:]
+	}
+	if len(path) == 0 || path[0] != silenceCRDResource {
+		http.NotFound(w, r)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	switch {
+	case r.Method == http.MethodPost && len(path) == 1:
+		s := khsilencecrd.KuberhealthySilence{}
+		err := json.NewDecoder(r.Body).Decode(&s)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if len(s.Name) == 0 {
+			f.generated++
+			s.Name = s.GenerateName + strconv.Itoa(f.generated)
+		}
+		s.Namespace = namespace
+		if _, exists := f.silences[namespace+"/"+s.Name]; exists {
+			w.WriteHeader(http.StatusConflict)
+			json.NewEncoder(w).Encode(metav1.Status{Status: metav1.StatusFailure, Reason: metav1.StatusReasonAlreadyExists, Code: http.StatusConflict})
+			return
+		}
+		f.silences[namespace+"/"+s.Name] = s
+		s.APIVersion = silenceCRDGroup + "/" + silenceCRDVersion
+		s.Kind = "KuberhealthySilence"
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(s)
+	case r.Method == http.MethodGet && len(path) == 1:
+		l := khsilencecrd.KuberhealthySilenceList{}
+		l.APIVersion = silenceCRDGroup + "/" + silenceCRDVersion
+		l.Kind = "KuberhealthySilenceList"
+		for _, s := range f.silences {
+			if len(namespace) == 0 || s.Namespace == namespace {
+				l.Items = append(l.Items, s)
+			}
+		}
+		json.NewEncoder(w).Encode(l)
+	case r.Method == http.MethodDelete && len(path) == 2:
+		delete(f.silences, namespace+"/"+path[1])
+		json.NewEncoder(w).Encode(metav1.Status{Status: metav1.StatusSuccess})
+	default:
+		http.Error(w, "unsupported request", http.StatusMethodNotAllowed)
+	}
+}
+
+// list returns the stored silences
+func (f *fakeSilenceAPI) list() []khsilencecrd.KuberhealthySilence {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var l []khsilencecrd.KuberhealthySilence
+	for _, s := range f.silences {
+		l = append(l, s)
+	}
+	return l
+}
+
+// update replaces a stored silence
+func (f *fakeSilenceAPI) update(s khsilencecrd.KuberhealthySilence) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.silences[s.Namespace+"/"+s.Name] = s
+}
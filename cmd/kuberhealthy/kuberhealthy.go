@@ -17,8 +17,11 @@ import (
 	"errors"
 	"fmt"
 	"io/ioutil"
+	"net"
 	"net/http"
 	"reflect"
+	"runtime/debug"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -26,16 +29,25 @@ import (
 	"github.com/google/uuid"
 	log "github.com/sirupsen/logrus"
 	v1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/apimachinery/pkg/watch"
 	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
 
+	"github.com/Comcast/kuberhealthy/v2/pkg/audit"
 	"github.com/Comcast/kuberhealthy/v2/pkg/checks/external"
 	"github.com/Comcast/kuberhealthy/v2/pkg/checks/external/status"
+	"github.com/Comcast/kuberhealthy/v2/pkg/federation"
 	"github.com/Comcast/kuberhealthy/v2/pkg/health"
+	"github.com/Comcast/kuberhealthy/v2/pkg/heartbeat"
 	"github.com/Comcast/kuberhealthy/v2/pkg/khcheckcrd"
+	"github.com/Comcast/kuberhealthy/v2/pkg/khconfig"
 	"github.com/Comcast/kuberhealthy/v2/pkg/masterCalculation"
 	"github.com/Comcast/kuberhealthy/v2/pkg/metrics"
+	"github.com/Comcast/kuberhealthy/v2/pkg/runhistory"
+	"github.com/Comcast/kuberhealthy/v2/pkg/scheduler"
+	"github.com/Comcast/kuberhealthy/v2/pkg/tracing"
+	"github.com/Comcast/kuberhealthy/v2/pkg/usage"
 )
 
 // Kuberhealthy represents the kuberhealthy server and its checks
@@ -44,50 +56,108 @@ type Kuberhealthy struct {
 	ListenAddr         string // the listen address, such as ":80"
 	MetricForwarder    metrics.Client
 	overrideKubeClient *kubernetes.Clientset
-	cancelChecksFunc   context.CancelFunc // invalidates the context of all running checks
-	wg                 sync.WaitGroup     // used to track running checks
-	shutdownCtxFunc    context.CancelFunc // used to shutdown the main control select
-	stateReflector     *StateReflector    // a reflector that can cache the current state of the khState resources
+	cancelChecksFunc   context.CancelFunc     // invalidates the context of all running checks
+	cancelMasterFunc   context.CancelFunc     // invalidates the context of the loops that only the master runs
+	wg                 sync.WaitGroup         // used to track running checks
+	shutdownCtxFunc    context.CancelFunc     // used to shutdown the main control select
+	stateReflector     *StateReflector        // a reflector that can cache the current state of the khState resources
+	runTriggers        map[string]chan string // per-check channels used to trigger an immediate run with a run UUID
+	runTriggersMu      sync.Mutex
+	runHistory         *runhistory.History            // recent check runs by run UUID
+	schedules          map[string]*scheduler.Schedule // the run schedule of each running check
+	schedulesMu        sync.Mutex
+	heartbeats         *heartbeat.Monitor // heartbeats of the goroutines running each check
+	usage              *usage.Tracker     // the resources reserved by the checker pods of each check
 }
 
+// maxRunHistory is the number of recent check runs that can be looked up by their run UUID
+const maxRunHistory = 1000
+
 // NewKuberhealthy creates a new kuberhealthy checker instance
 func NewKuberhealthy() *Kuberhealthy {
 	kh := &Kuberhealthy{}
 	kh.stateReflector = NewStateReflector()
+	kh.runHistory = runhistory.New(maxRunHistory)
+	kh.heartbeats = heartbeat.New()
+	kh.usage = usage.New()
 	return kh
 }
 
+// executionErrorCategory returns the category of an error that kept a check from running.  Errors are caused by
+// the infrastructure unless they were marked as caused by the configuration of the check.
+func executionErrorCategory(err error) string {
+	if external.IsConfigError(err) {
+		return health.ErrorCategoryConfiguration
+	}
+	return health.ErrorCategoryInfrastructure
+}
+
 // setCheckExecutionError sets an execution error for a check name in
-// its crd status
-func (k *Kuberhealthy) setCheckExecutionError(checkName string, checkNamespace string, exErr error) {
+// its crd status.  The error is passed through the check's debouncer and
+// counted as a failed run in the check's run history when a debouncer is
+// supplied.
+func (k *Kuberhealthy) setCheckExecutionError(checkName string, checkNamespace string, exErr error, debouncer *health.Debouncer) {
+	logger := log.WithFields(log.Fields{
+		external.LogFieldCheck:     checkName,
+		external.LogFieldNamespace: checkNamespace,
+	})
 	details := health.NewCheckDetails()
 	check, err := k.getCheck(checkName, checkNamespace)
 	if err != nil {
-		log.Errorln(err)
+		logger.Errorln(err)
 	}
 	if check != nil {
 		details.Namespace = check.CheckNamespace()
+		details.StaleAt = k.staleAt(check)
+		details.UnknownAt = unknownAt(check)
+	}
+	details.LastRunOK = false
+	details.LastRunErrors = []string{"Check execution error: " + exErr.Error()}
+	details.ErrorCategory = executionErrorCategory(exErr)
+	details.OK, details.Errors = details.LastRunOK, details.LastRunErrors
+	if debouncer != nil {
+		details.OK, details.Errors = debouncer.Record(details.LastRunOK, details.LastRunErrors)
 	}
-	details.OK = false
-	details.Errors = []string{"Check execution error: " + exErr.Error()}
 
 	// we need to maintain the current UUID, which means fetching it first
 	khc, err := k.getCheck(checkName, checkNamespace)
 	if err != nil {
-		log.Errorln("Error when setting execution error on check", checkName, checkNamespace, err)
+		logger.Errorln("Error when setting execution error on check:", err)
 	}
 	checkState, err := getCheckState(khc)
 	if err != nil {
-		log.Errorln("Error when setting execution error on check (getting check state for current UUID)", checkName, checkNamespace, err)
+		logger.Errorln("Error when setting execution error on check (getting check state for current UUID):", err)
 	}
+	base := checkState.DeepCopy()
 	details.CurrentUUID = checkState.CurrentUUID
+	details.RunningUUIDs = checkState.RunningUUIDs
+	details.LastReport = checkState.LastReport
+	details.Artifacts = artifactsOfRun(checkState.Artifacts, checkState.CurrentUUID)
+	details.SLOTarget = checkState.SLOTarget
+	details.Severity = checkState.Severity
+	details.Labels = checkState.Labels
+	details.Warnings = checkState.Warnings
+	if wc, ok := check.(warningCheck); ok {
+		details.Warnings = wc.Warnings()
+	}
+	details.Pod = checkerPod(check, checkState.Pod)
+	details.RunHistory = checkState.RunHistory
+	details.Architectures = checkState.Architectures
+	details.Baselines = checkState.Baselines
+	details.ErrorHistory = checkState.ErrorHistory
+	if debouncer != nil {
+		details.RecordRun(time.Now(), details.LastRunOK)
+		details.RecordErrors(time.Now(), details.LastRunErrors)
+		recordRunArchitecture(check, &details)
+	}
+	logger = logger.WithField(external.LogFieldRunID, details.CurrentUUID)
 
-	log.Debugln("Setting execution state of check", checkName, "to", details.OK, details.Errors, details.CurrentUUID)
+	logger.Debugln("Setting execution state of check to", details.OK, details.Errors)
 
 	// store the check state with the CRD
-	err = k.storeCheckState(checkName, checkNamespace, details)
+	err = k.storeCheckState(checkName, checkNamespace, base, details)
 	if err != nil {
-		log.Errorln("Was unable to write an execution error to the CRD status with error:", err)
+		logger.Errorln("Was unable to write an execution error to the CRD status with error:", err)
 	}
 }
 
@@ -106,6 +176,13 @@ func (k *Kuberhealthy) Shutdown(doneChan chan struct{}) {
 	time.Sleep(5) // help prevent more checks from starting in a race before control system stop happens
 	log.Infoln("shutdown: stopping checks")
 	k.StopChecks() // stop all checks
+	if stateWriteCoalescer != nil {
+		log.Infoln("shutdown: flushing", stateWriteCoalescer.Pending(), "pending check state writes")
+		err := stateWriteCoalescer.Flush(context.Background())
+		if err != nil {
+			log.Errorln("shutdown: failed to flush check state:", err)
+		}
+	}
 	log.Infoln("shutdown: ready for main program shutdown")
 	doneChan <- struct{}{}
 }
@@ -119,6 +196,14 @@ func (k *Kuberhealthy) StopChecks() {
 		k.cancelChecksFunc()
 	}
 
+	// stopped checks can no longer be triggered
+	k.runTriggersMu.Lock()
+	k.runTriggers = nil
+	k.runTriggersMu.Unlock()
+	k.schedulesMu.Lock()
+	k.schedules = nil
+	k.schedulesMu.Unlock()
+
 	// call a shutdown on all checks concurrently
 	for _, c := range k.Checks {
 		go func() {
@@ -145,6 +230,51 @@ func (k *Kuberhealthy) Start(ctx context.Context) {
 	// start the khState reflector
 	go k.stateReflector.Start()
 
+	// discover khchecks in the namespaces Kuberhealthy serves and wait for them to be listed, so that checks
+	// are not configured from an empty cache
+	go checkInformer.Run(ctx.Done())
+	if !cache.WaitForCacheSync(ctx.Done(), checkInformer.HasSynced) {
+		log.Errorln("control: khcheck informer did not sync before shutdown")
+		return
+	}
+
+	// flush batched writes of check state
+	if stateWriteCoalescer != nil {
+		go stateWriteCoalescer.Run(ctx)
+	}
+
+	// pick up rotated TLS certificates
+	if tlsReloader != nil {
+		go tlsReloader.Watch(ctx, time.Minute)
+	}
+
+	// poll the status of federated clusters
+	if federator != nil {
+		go federator.Run(ctx, federationPollInterval)
+	}
+
+	// push the status of this cluster to a central collector
+	if upstreamPusher != nil {
+		go upstreamPusher.Run(ctx, func() health.State {
+			return k.getCurrentState([]string{})
+		})
+	}
+
+	// keep the silences of checks up to date for the status page
+	go k.silenceWatcher(ctx)
+
+	// send the alerts of failing checks to Alertmanager
+	if alertNotifier != nil {
+		go alertNotifier.Run(ctx, func() health.State {
+			return k.getCurrentState([]string{})
+		})
+	}
+
+	// export the spans of traced check runs
+	if tracing.DefaultTracer != nil {
+		go tracing.DefaultTracer.Run(ctx, tracingExportInterval)
+	}
+
 	// if influxdb is enabled, configure it
 	if enableInflux {
 		k.configureInfluxForwarding()
@@ -153,6 +283,11 @@ func (k *Kuberhealthy) Start(ctx context.Context) {
 	// Start the web server and restart it if it crashes
 	go kuberhealthy.StartWebServer()
 
+	// Start the gRPC report service if it is enabled
+	if len(grpcListenAddress) > 0 {
+		go kuberhealthy.StartGRPCServer()
+	}
+
 	// find all the external checks from the khcheckcrd resources on the cluster and keep them in sync.
 	// use rate limiting to avoid reconfiguration spam
 	maxUpdateInterval := time.Second * 10
@@ -161,11 +296,18 @@ func (k *Kuberhealthy) Start(ctx context.Context) {
 	go notifyChanLimiter(maxUpdateInterval, externalChecksUpdateChan, externalChecksUpdateChanLimited)
 	go k.monitorExternalChecks(externalChecksUpdateChan)
 
+	// pick up changes to the config file
+	configChangedChan := make(chan khconfig.Config, 1)
+	if configWatcher != nil {
+		go configWatcher.Watch(ctx, configReloadInterval, configChangedChan)
+	}
+
 	// we use two channels to indicate when we gain or lose master status. use rate limiting to avoid
 	// reconfiguration spam
 	becameMasterChan := make(chan struct{}, 10)
 	lostMasterChan := make(chan struct{}, 10)
-	go k.masterMonitor(becameMasterChan, lostMasterChan)
+	shardsChangedChan := make(chan struct{}, 10)
+	go k.masterMonitor(becameMasterChan, lostMasterChan, shardsChangedChan)
 
 	// loop and select channels to do appropriate thing when master changes
 	for {
@@ -174,17 +316,48 @@ func (k *Kuberhealthy) Start(ctx context.Context) {
 			log.Infoln("control: shutting down from context abort...")
 			return
 		case <-becameMasterChan: // we have become the current master instance and should run checks
+			k.startMasterLoops()
+			// when checks are sharded, every instance runs its own checks regardless of master status
+			if shardChecks {
+				log.Infoln("control: Became master.")
+				continue
+			}
 			// reset checks and re-add from configuration settings
 			log.Infoln("control: Became master. Reconfiguring and starting checks.")
 			k.StartChecks()
 		case <-lostMasterChan: // we are no longer master
+			k.stopMasterLoops()
+			if shardChecks {
+				log.Infoln("control: Lost master.")
+				continue
+			}
 			log.Infoln("control: Lost master. Stopping checks.")
 			k.StopChecks()
+		case <-shardsChangedChan: // kuberhealthy pods have come or gone and checks may have moved between them
+			changed, err := k.ownedChecksChanged()
+			if err != nil {
+				log.Errorln("control: Failed to determine which checks this instance owns:", err)
+				continue
+			}
+			if changed {
+				log.Infoln("control: Checks were rebalanced across kuberhealthy pods. Restarting checks.")
+				k.RestartChecks()
+			}
+		case c := <-configChangedChan: // the config file changed
+			restart, err := reloadConfig(c)
+			if err != nil {
+				log.Errorln("control: Failed to apply the changed config file:", err)
+				continue
+			}
+			if restart && (isMaster || shardChecks) {
+				log.Infoln("control: Restarting checks to apply the changed config file")
+				k.RestartChecks()
+			}
 		case <-externalChecksUpdateChanLimited: // external check change detected
 			log.Infoln("control: Witnessed a khcheck resource change...")
 
-			// if we are master, stop, reconfigure our khchecks, and start again with the new configuration
-			if isMaster {
+			// if we run checks, stop, reconfigure our khchecks, and start again with the new configuration
+			if isMaster || shardChecks {
 				log.Infoln("control: Reloading external check configurations due to khcheck update")
 				k.RestartChecks()
 			}
@@ -192,6 +365,27 @@ func (k *Kuberhealthy) Start(ctx context.Context) {
 	}
 }
 
+// startMasterLoops starts the loops that only the master runs, such as the khState reaper
+func (k *Kuberhealthy) startMasterLoops() {
+	k.stopMasterLoops()
+	ctx, cancelFunc := context.WithCancel(context.Background())
+	k.cancelMasterFunc = cancelFunc
+
+	log.Infoln("control: reaper starting!")
+	go k.khStateResourceReaper(ctx)
+
+	// prune archived check runs past their retention period
+	go k.archivePruner(ctx)
+}
+
+// stopMasterLoops stops the loops started by startMasterLoops
+func (k *Kuberhealthy) stopMasterLoops() {
+	if k.cancelMasterFunc != nil {
+		k.cancelMasterFunc()
+		k.cancelMasterFunc = nil
+	}
+}
+
 // RestartChecks does a stop and start on all kuberhealthy checks
 func (k *Kuberhealthy) RestartChecks() {
 	k.StopChecks()
@@ -213,6 +407,18 @@ func (k *Kuberhealthy) khStateResourceReaper(ctx context.Context) {
 			if err != nil {
 				log.Errorln("khState reaper: Error when reaping khState resources:", err)
 			}
+			err = k.reapCheckServiceAccounts()
+			if err != nil {
+				log.Errorln("khState reaper: Error when reaping check service accounts:", err)
+			}
+			err = k.reapCheckArtifacts()
+			if err != nil {
+				log.Errorln("khState reaper: Error when reaping check artifacts:", err)
+			}
+			err = k.finalizeDeletedChecks()
+			if err != nil {
+				log.Errorln("khState reaper: Error when finalizing deleted khchecks:", err)
+			}
 		case <-ctx.Done():
 			log.Infoln("khState reaper: stopping")
 			return
@@ -226,27 +432,31 @@ func (k *Kuberhealthy) khStateResourceReaper(ctx context.Context) {
 func (k *Kuberhealthy) reapKHStateResources() error {
 
 	// list all khStates in the cluster
-	khStates, err := khStateClient.List(metav1.ListOptions{}, stateCRDResource, "")
+	khStates, err := listCheckStates()
 	if err != nil {
 		return fmt.Errorf("khState reaper: error listing khStates for reaping: %w", err)
 	}
 
 	// list all khChecks
-	khChecks, err := khCheckClient.List(metav1.ListOptions{}, checkCRDResource, "")
+	khChecks, err := listChecks()
 	if err != nil {
 		return fmt.Errorf("khState reaper: error listing khChecks for khState reaping: %w", err)
 	}
 
-	log.Infoln("khState reaper: analyzing", len(khStates.Items), "khState resources")
+	log.Infoln("khState reaper: analyzing", len(khStates), "khState resources")
 
 	// any khState that does not have a matching khCheck should be deleted (ignore errors)
-	for _, khState := range khStates.Items {
-		log.Debugln("khState reaper: analyzing khState", khState.GetName(), "in", khState.GetName())
+	for _, khState := range khStates {
+		// the khStates of namespaces that are not watched belong to other Kuberhealthy instances
+		if !watchesNamespace(khState.Namespace) {
+			continue
+		}
+		log.Debugln("khState reaper: analyzing khState", khState.Name, "in", khState.Namespace)
 		var foundKHCheck bool
 		for _, khCheck := range khChecks.Items {
-			log.Debugln("khState reaper:", khCheck.GetName(), "==", khState.GetName(), "&&", khCheck.GetNamespace(), "==", khState.GetNamespace())
-			if khCheck.GetName() == khState.GetName() && khCheck.GetNamespace() == khState.GetNamespace() {
-				log.Infoln("khState reaper:", khState.GetName(), "in", khState.GetNamespace(), "is still valid")
+			log.Debugln("khState reaper:", khCheck.GetName(), "==", khState.Name, "&&", khCheck.GetNamespace(), "==", khState.Namespace)
+			if khCheck.GetName() == khState.Name && khCheck.GetNamespace() == khState.Namespace {
+				log.Infoln("khState reaper:", khState.Name, "in", khState.Namespace, "is still valid")
 				foundKHCheck = true
 				break
 			}
@@ -254,8 +464,8 @@ func (k *Kuberhealthy) reapKHStateResources() error {
 
 		// if we didn't find a matching khCheck, delete the rogue khState
 		if !foundKHCheck {
-			log.Infoln("khState reaper: removing khState", khState.GetName(), "in", khState.GetNamespace())
-			_, err := khStateClient.Delete(&khState, stateCRDResource, khState.GetName(), khState.GetNamespace())
+			log.Infoln("khState reaper: removing khState", khState.Name, "in", khState.Namespace)
+			err := stateStore.Delete(khState.Name, khState.Namespace)
 			if err != nil {
 				log.Errorln(fmt.Errorf("khState reaper: error when removing invalid khstate: %w", err))
 			}
@@ -266,46 +476,11 @@ func (k *Kuberhealthy) reapKHStateResources() error {
 
 }
 
-// watchForKHCheckChanges watches for changes to khcheck objects and returns them through the specified channel
+// watchForKHCheckChanges signals the specified channel when the khcheck informer sees khcheck objects change
 func (k *Kuberhealthy) watchForKHCheckChanges(c chan struct{}) {
-
 	log.Debugln("Spawned watcher for KH check changes")
-
-	for {
-		log.Debugln("Starting a watch for khcheck object changes")
-
-		// wait a second so we don't retry too quickly on error
-		time.Sleep(time.Second)
-
-		// start a watch on khcheck resources
-		watcher, err := khCheckClient.Watch(metav1.ListOptions{})
-		if err != nil {
-			log.Errorln("error watching khcheck objects:", err)
-			continue
-		}
-
-		// loop over results and return them to the calling channel until we hit an error, then close and restart
-		for khc := range watcher.ResultChan() {
-			switch khc.Type {
-			case watch.Added:
-				log.Debugln("khcheck monitor saw an added event")
-				c <- struct{}{}
-			case watch.Modified:
-				log.Debugln("khcheck monitor saw a modified event")
-				c <- struct{}{}
-			case watch.Deleted:
-				log.Debugln("khcheck monitor saw a deleted event")
-				c <- struct{}{}
-			case watch.Error:
-				log.Debugln("khcheck monitor saw an error event")
-				e := khc.Object.(*metav1.Status)
-				log.Errorln("Error when watching for khcheck changes:", e.Reason)
-				break
-			default:
-				log.Warningln("khcheck monitor saw an unknown event type and ignored it:", khc.Type)
-			}
-		}
-		watcher.Stop()
+	for range checkInformer.Changes() {
+		c <- struct{}{}
 	}
 }
 
@@ -314,10 +489,14 @@ func (k *Kuberhealthy) monitorExternalChecks(notify chan struct{}) {
 
 	// make a map of resource versions so we know when things change
 	knownSettings := make(map[string]khcheckcrd.CheckConfig)
+	knownLabels := make(map[string]map[string]string)
 
 	// start watching for events to changes in the background
 	c := make(chan struct{})
 	go k.watchForKHCheckChanges(c)
+	if !namespacedMode {
+		go k.watchForKHCheckTemplateChanges(c)
+	}
 
 	// each time  we see a change in our khcheck structs, we should look at every object to see if something has changed
 	for {
@@ -326,8 +505,8 @@ func (k *Kuberhealthy) monitorExternalChecks(notify chan struct{}) {
 		<-c
 		log.Debugln("Change notification received. Scanning for external check changes...")
 
-		// fetch all khcheck resources from all namespaces
-		l, err := khCheckClient.List(metav1.ListOptions{}, checkCRDResource, "")
+		// fetch all khcheck resources from all namespaces with their templates expanded
+		l, _, err := listExpandedChecks()
 		if err != nil {
 			log.Errorln("Error listing check configuration resources", err)
 			continue
@@ -349,6 +528,7 @@ func (k *Kuberhealthy) monitorExternalChecks(notify chan struct{}) {
 			if !existsInItems {
 				log.Debugln("Detected khcheck deletion for", mapName)
 				delete(knownSettings, mapName)
+				delete(knownLabels, mapName)
 				foundChange = true
 			}
 		}
@@ -368,47 +548,41 @@ func (k *Kuberhealthy) monitorExternalChecks(notify chan struct{}) {
 				continue
 			}
 
+			// a khcheck that is being deleted is stopped once, and cleaned up when the checks are reloaded
+			if i.DeletionTimestamp != nil {
+				if _, exists := knownSettings[mapName]; exists {
+					log.Debugln("Detected khcheck deletion in progress for", mapName)
+					delete(knownSettings, mapName)
+					delete(knownLabels, mapName)
+					foundChange = true
+				}
+				continue
+			}
+
 			// if we don't know about this check yet, just store the state and continue.  The check is already
 			// loaded on the first check configuration run.
 			_, exists := knownSettings[mapName]
 			if !exists {
 				log.Debugln("First time seeing khcheck of name", mapName)
 				knownSettings[mapName] = i.Spec
+				knownLabels[mapName] = i.Labels
 				foundChange = true
 			}
 
-			// check if run interval has changed
-			if knownSettings[mapName].RunInterval != i.Spec.RunInterval {
-				log.Debugln("The khcheck run interval for", mapName, "has changed.")
-				foundChange = true
-			}
-
-			// check if run timeout has changed
-			if knownSettings[mapName].Timeout != i.Spec.Timeout {
-				log.Debugln("The khcheck timeout for", mapName, "has changed.")
-				foundChange = true
-			}
-
-			// check if extraLabels has changed
-			if !foundChange && !reflect.DeepEqual(knownSettings[mapName].ExtraLabels, i.Spec.ExtraLabels) {
-				log.Debugln("The khcheck extra labels for", mapName, "has changed.")
+			// check if the settings of the check have changed
+			if checkConfigChanged(mapName, knownSettings[mapName], i.Spec) {
 				foundChange = true
 			}
 
-			// check if extraAnnotations has changed
-			if !foundChange && !reflect.DeepEqual(knownSettings[mapName].ExtraAnnotations, i.Spec.ExtraAnnotations) {
-				log.Debugln("The khcheck extra annotations for", mapName, "has changed.")
-				foundChange = true
-			}
-
-			// check if CheckConfig has changed (PodSpec)
-			if !foundChange && !reflect.DeepEqual(knownSettings[mapName].PodSpec, i.Spec.PodSpec) {
-				log.Debugln("The khcheck for", mapName, "has changed.")
+			// check if the labels that status page views select checks by have changed
+			if !reflect.DeepEqual(knownLabels[mapName], i.Labels) {
+				log.Debugln("The khcheck labels for", mapName, "have changed.")
 				foundChange = true
 			}
 
 			// finally, update known settings before continuing to the next interval
 			knownSettings[mapName] = i.Spec
+			knownLabels[mapName] = i.Labels
 		}
 
 		// if a change was detected, we signal the notify channel
@@ -419,6 +593,150 @@ func (k *Kuberhealthy) monitorExternalChecks(notify chan struct{}) {
 	}
 }
 
+// checkConfigChanged returns true if the settings of a khcheck changed in a way that requires the check to be
+// reloaded
+func checkConfigChanged(mapName string, known khcheckcrd.CheckConfig, current khcheckcrd.CheckConfig) bool {
+	// check if run interval has changed
+	if known.RunInterval != current.RunInterval {
+		log.Debugln("The khcheck run interval for", mapName, "has changed.")
+		return true
+	}
+
+	// check if run timeout has changed
+	if known.Timeout != current.Timeout {
+		log.Debugln("The khcheck timeout for", mapName, "has changed.")
+		return true
+	}
+
+	// check if extraLabels has changed
+	if !reflect.DeepEqual(known.ExtraLabels, current.ExtraLabels) {
+		log.Debugln("The khcheck extra labels for", mapName, "has changed.")
+		return true
+	}
+
+	// check if extraAnnotations has changed
+	if !reflect.DeepEqual(known.ExtraAnnotations, current.ExtraAnnotations) {
+		log.Debugln("The khcheck extra annotations for", mapName, "has changed.")
+		return true
+	}
+
+	// check if the requested service account has changed
+	if !reflect.DeepEqual(known.ServiceAccount, current.ServiceAccount) {
+		log.Debugln("The khcheck service account for", mapName, "has changed.")
+		return true
+	}
+
+	// check if the referenced secrets or config maps have changed
+	if !reflect.DeepEqual(known.Secrets, current.Secrets) || !reflect.DeepEqual(known.ConfigMaps, current.ConfigMaps) {
+		log.Debugln("The khcheck secret or config map references for", mapName, "have changed.")
+		return true
+	}
+
+	// check if the health thresholds have changed
+	if known.FailureThreshold != current.FailureThreshold || known.SuccessThreshold != current.SuccessThreshold {
+		log.Debugln("The khcheck failure or success threshold for", mapName, "has changed.")
+		return true
+	}
+
+	// check if the service level objective has changed
+	if known.SLOTarget != current.SLOTarget {
+		log.Debugln("The khcheck SLO target for", mapName, "has changed.")
+		return true
+	}
+
+	// check if the severity has changed
+	if known.Severity != current.Severity {
+		log.Debugln("The khcheck severity for", mapName, "has changed.")
+		return true
+	}
+
+	// check if the notification channels have changed
+	if !reflect.DeepEqual(known.NotificationChannels, current.NotificationChannels) {
+		log.Debugln("The khcheck notification channels for", mapName, "have changed.")
+		return true
+	}
+
+	// check if the mode has changed
+	if known.Mode != current.Mode {
+		log.Debugln("The khcheck mode for", mapName, "has changed.")
+		return true
+	}
+
+	// check if the ephemeral namespace settings have changed
+	if !reflect.DeepEqual(known.EphemeralNamespace, current.EphemeralNamespace) {
+		log.Debugln("The khcheck ephemeral namespace settings for", mapName, "have changed.")
+		return true
+	}
+
+	// check if the network policy settings have changed
+	if !reflect.DeepEqual(known.NetworkPolicy, current.NetworkPolicy) {
+		log.Debugln("The khcheck network policy settings for", mapName, "have changed.")
+		return true
+	}
+
+	// check if the setup or teardown hooks have changed
+	if !reflect.DeepEqual(known.Setup, current.Setup) || !reflect.DeepEqual(known.Teardown, current.Teardown) {
+		log.Debugln("The khcheck setup or teardown hooks for", mapName, "have changed.")
+		return true
+	}
+
+	// check if the timeout budget has changed
+	if !reflect.DeepEqual(known.TimeoutBudget, current.TimeoutBudget) {
+		log.Debugln("The khcheck timeout budget for", mapName, "has changed.")
+		return true
+	}
+
+	// check if the operating system or architecture of the check's nodes has changed
+	if known.OS != current.OS || !reflect.DeepEqual(known.Architectures, current.Architectures) {
+		log.Debugln("The khcheck node operating system or architecture for", mapName, "has changed.")
+		return true
+	}
+
+	// check if the number of runs that can overlap has changed
+	if known.MaxOverlappingRuns != current.MaxOverlappingRuns {
+		log.Debugln("The khcheck maximum overlapping runs for", mapName, "has changed.")
+		return true
+	}
+
+	// check if the result TTL has changed
+	if known.ResultTTL != current.ResultTTL {
+		log.Debugln("The khcheck result TTL for", mapName, "has changed.")
+		return true
+	}
+
+	// check if the regression rules have changed
+	if !reflect.DeepEqual(known.Regressions, current.Regressions) {
+		log.Debugln("The khcheck regression rules for", mapName, "have changed.")
+		return true
+	}
+
+	// check if the security policy opt-out has changed
+	if known.DisableSecurityPolicy != current.DisableSecurityPolicy {
+		log.Debugln("The khcheck security policy opt-out for", mapName, "has changed.")
+		return true
+	}
+
+	// check if pod spec templating has been turned on or off
+	if known.ExpandTemplates != current.ExpandTemplates {
+		log.Debugln("The khcheck pod spec templating for", mapName, "has changed.")
+		return true
+	}
+
+	// check if the template or the values of its parameters have changed
+	if !reflect.DeepEqual(known.Template, current.Template) {
+		log.Debugln("The khcheck template for", mapName, "has changed.")
+		return true
+	}
+
+	// check if CheckConfig has changed (PodSpec)
+	if !reflect.DeepEqual(known.PodSpec, current.PodSpec) {
+		log.Debugln("The khcheck for", mapName, "has changed.")
+		return true
+	}
+
+	return false
+}
+
 // setExternalChecks syncs up the state of the external-checks installed in this
 // Kuberhealthy struct.
 func (k *Kuberhealthy) addExternalChecks() error {
@@ -426,7 +744,7 @@ func (k *Kuberhealthy) addExternalChecks() error {
 	log.Debugln("Fetching khcheck configurations...")
 
 	// list all checks from all namespaces
-	l, err := khCheckClient.List(metav1.ListOptions{}, checkCRDResource, "")
+	l, expandErrors, err := listExpandedChecks()
 	if err != nil {
 		return err
 	}
@@ -435,52 +753,220 @@ func (k *Kuberhealthy) addExternalChecks() error {
 
 	// iterate on each check CRD resource and add it as a check
 	for _, r := range l.Items {
+		// when checks are sharded, another kuberhealthy pod runs the checks that this one does not own
+		if !ownsCheck(r.Namespace, r.Name) {
+			log.Debugln("Skipping check CRD", r.Name, "in", r.Namespace, "owned by kuberhealthy pod", shards.owner(r.Namespace, r.Name))
+			continue
+		}
+
+		// checks whose template can not be expanded have no checker pod to run
+		err = expandErrors[checkKey(r.Namespace, r.Name)]
+		if err != nil {
+			log.Errorln("External check", r.Name, "in namespace", r.Namespace, "could not be created from its template:", err)
+			k.setCheckExecutionError(r.Name, r.Namespace, external.NewConfigError(err), nil)
+			continue
+		}
+
+		// checks that are being deleted are cleaned up instead of run
+		if r.DeletionTimestamp != nil {
+			if hasCheckFinalizer(&r) {
+				log.Infoln("Cleaning up deleted external check:", r.Name)
+				err = finalizeCheck(r.Namespace, r.Name)
+				if err != nil {
+					log.Errorln(err)
+				}
+			}
+			continue
+		}
+		if enableCheckFinalizers && !hasCheckFinalizer(&r) {
+			err = ensureCheckFinalizer(r.Namespace, r.Name)
+			if err != nil {
+				log.Errorln(err)
+			}
+		}
+
 		log.Debugln("Loading check CRD:", r.Name)
 
 		log.Debugf("External check custom resource loaded: %v", r)
 
-		// create a new kubernetes client for this external checker
 		log.Infoln("Enabling external check:", r.Name)
-		c := external.New(kubernetesClient, &r, khCheckClient, khStateClient, externalCheckReportingURL)
+		c := newExternalChecker(&r)
 
-		// parse the run interval string from the custom resource and setup the run interval
-		c.RunInterval, err = time.ParseDuration(r.Spec.RunInterval)
+		// the check is still added when its images are not allowed or its references are missing so that the
+		// error shows on the status page.  Its runs fail without creating a pod.
+		err = c.ValidateImages()
 		if err != nil {
-			log.Errorln("Error parsing duration for check", c.CheckName, "in namespace", c.Namespace, err)
-			log.Errorln("Defaulting check to a runtime of ten minutes.")
-			c.RunInterval = DefaultRunInterval
+			log.Errorln("External check", c.CheckName, "in namespace", c.Namespace, "was rejected:", err)
+			k.setCheckExecutionError(c.Name(), c.CheckNamespace(), external.NewConfigError(err), nil)
 		}
-
-		log.Debugln("RunInterval for check:", c.CheckName, "set to", c.RunInterval)
-
-		// parse the user specified timeout if present
-		c.RunTimeout = khcheckcrd.DefaultTimeout
-		if len(r.Spec.Timeout) > 0 {
-			c.RunTimeout, err = time.ParseDuration(r.Spec.Timeout)
+		if err == nil {
+			err = c.ValidateReferences()
 			if err != nil {
-				log.Errorln("Error parsing timeout for check", c.CheckName, "in namespace", c.Namespace, err)
-				log.Errorln("Defaulting check to a timeout of", khcheckcrd.DefaultTimeout)
+				log.Errorln("External check", c.CheckName, "in namespace", c.Namespace, "has invalid references:", err)
+				k.setCheckExecutionError(c.Name(), c.CheckNamespace(), external.NewConfigError(err), nil)
 			}
 		}
 
-		log.Debugln("RunTimeout for check:", c.CheckName, "set to", c.RunTimeout)
+		// add the check into the checker
+		k.AddCheck(c)
+	}
+
+	return nil
+}
+
+// newExternalChecker creates an external checker from a khcheck custom resource with the operator's
+// configuration applied to it
+func newExternalChecker(r *khcheckcrd.KuberhealthyCheck) *external.Checker {
+	c := external.New(kubernetesClient, r, khCheckClient, stateStore, externalCheckReportingURL)
+	c.GRPCReportingAddress = externalCheckGRPCReportingAddress
+	c.NamespaceScoped = namespacedMode
+	c.ReportingProxy = reportingProxy
+	c.ReportingNoProxy = reportingNoProxy
+	c.SecurityPolicy = checkSecurityPolicy
+	c.ImagePolicy = checkImagePolicy
+	c.RolePolicy = checkRolePolicy
+	c.DefaultLabels = checkPodLabels
+	c.DefaultAnnotations = checkPodAnnotations
+	c.DefaultNodeSelector = checkNodeSelector
+	c.DefaultPriorityClass = checkPriorityClass
+	c.DefaultTolerations = checkTolerations
+	c.PodDeleteGracePeriod = podDeleteGracePeriod
+	c.PodRateLimiter = podRateLimiter
+	c.PodForceDeleteAfter = podForceDeleteAfter
+	c.RecordPodSpecMutations = recordPodSpecMutations
+	c.KuberhealthyNamespace = podNamespace
+	c.KuberhealthyOwner = kuberhealthyOwner
+	c.ReportingPodLabels = reportingPodLabels
+	c.CollectPodLogs = archiver != nil
+	c.Debug = enableDebug
+	c.TLS = tlsReloader
+	c.ClientCertSecret = checkClientCertSecret
+	c.TokenAudience = checkTokenAudience
+	c.DisableSecurityPolicy = r.Spec.DisableSecurityPolicy
+	c.ExpandTemplates = r.Spec.ExpandTemplates
+	c.Secrets = r.Spec.Secrets
+	c.ConfigMaps = r.Spec.ConfigMaps
+	c.FailureThreshold = r.Spec.FailureThreshold
+	c.Daemon = r.Spec.Mode == khcheckcrd.ModeDaemon
+	if len(r.Spec.Mode) > 0 && r.Spec.Mode != khcheckcrd.ModeDaemon && r.Spec.Mode != khcheckcrd.ModeRun {
+		log.Errorln("External check", c.CheckName, "in namespace", c.Namespace, "has unknown mode", r.Spec.Mode+".  Defaulting to", khcheckcrd.ModeRun, "mode.")
+	}
+	c.SuccessThreshold = r.Spec.SuccessThreshold
+	c.SLOTarget = sloTarget
+	if r.Spec.SLOTarget > 0 {
+		c.SLOTarget = r.Spec.SLOTarget
+	}
+	c.NotificationChannels = r.Spec.NotificationChannels
+	c.Severity = r.Spec.Severity
+	if len(r.Spec.ResultTTL) > 0 {
+		ttl, err := time.ParseDuration(r.Spec.ResultTTL)
+		if err != nil || ttl < 0 {
+			log.Errorln("Error parsing result TTL for check", c.CheckName, "in namespace", c.Namespace+":", r.Spec.ResultTTL, "is not a duration such as 30m.  Results of the check will not expire.")
+		} else {
+			c.ResultTTL = ttl
+		}
+	}
+	c.CheckLabels = r.Labels
+	c.EphemeralNamespace = r.Spec.EphemeralNamespace
+	c.NetworkPolicy = r.Spec.NetworkPolicy
+	if c.EphemeralNamespace != nil && c.Daemon {
+		log.Warningln("External check", c.CheckName, "in namespace", c.Namespace, "requested an ephemeral namespace, which is not used in", khcheckcrd.ModeDaemon, "mode.")
+	}
+	c.OS = r.Spec.OS
+	c.Architectures = r.Spec.Architectures
+	c.Regressions = r.Spec.Regressions
+	c.Setup = r.Spec.Setup
+	c.Teardown = r.Spec.Teardown
+	if c.Teardown != nil && c.Daemon {
+		log.Warningln("External check", c.CheckName, "in namespace", c.Namespace, "has a teardown hook, which is not run in", khcheckcrd.ModeDaemon, "mode.")
+	}
+	c.MaxOverlappingRuns = r.Spec.MaxOverlappingRuns
+	if c.MaxOverlappingRuns > 0 {
+		if c.Daemon {
+			log.Warningln("External check", c.CheckName, "in namespace", c.Namespace, "allows overlapping runs, which are not used in", khcheckcrd.ModeDaemon, "mode.")
+		}
+		// each run copies the settings of the loaded check, so runs keep its pinned images and are not
+		// validated again
+		c.NewRunChecker = c.Copy
+	}
+
+	// parse the run interval string from the custom resource and setup the run interval
+	var err error
+	c.RunInterval, err = time.ParseDuration(r.Spec.RunInterval)
+	if err != nil {
+		log.Errorln("Error parsing duration for check", c.CheckName, "in namespace", c.Namespace, err)
+		log.Errorln("Defaulting check to a runtime of", defaultRunInterval)
+		c.RunInterval = defaultRunInterval
+	}
+
+	log.Debugln("RunInterval for check:", c.CheckName, "set to", c.RunInterval)
 
-		// add on extra annotations and labels
-		if c.ExtraAnnotations != nil {
-			log.Debugln("External check setting extra annotations:", c.ExtraAnnotations)
-			c.ExtraAnnotations = r.Spec.ExtraAnnotations
+	// parse the user specified timeout if present
+	c.RunTimeout = defaultRunTimeout
+	if len(r.Spec.Timeout) > 0 {
+		c.RunTimeout, err = time.ParseDuration(r.Spec.Timeout)
+		if err != nil {
+			log.Errorln("Error parsing timeout for check", c.CheckName, "in namespace", c.Namespace, err)
+			log.Errorln("Defaulting check to a timeout of", defaultRunTimeout)
+			c.RunTimeout = defaultRunTimeout
 		}
-		if c.ExtraLabels != nil {
-			log.Debugln("External check setting extra labels:", c.ExtraLabels)
-			c.ExtraLabels = r.Spec.ExtraLabels
+	}
+
+	log.Debugln("RunTimeout for check:", c.CheckName, "set to", c.RunTimeout)
+
+	// parse the timeout budgets of the phases of a run if present
+	c.PhaseTimeouts, err = external.ParseTimeoutBudget(r.Spec.TimeoutBudget)
+	if err != nil {
+		log.Errorln("Error parsing timeout budget for check", c.CheckName, "in namespace", c.Namespace, err)
+		log.Errorln("Limiting check only by its timeout of", c.RunTimeout)
+	}
+
+	// warn about timeouts that let runs pile up or never apply, and clamp them if asked to
+	c.TimingWarnings = c.ValidateTiming(clampCheckTimeouts)
+	for _, warning := range c.TimingWarnings {
+		log.Warningln("External check", c.CheckName, "in namespace", c.Namespace+":", warning)
+	}
+
+	// run the check by the digests its image tags point to now, so that runs only change images when the
+	// check is reloaded
+	if pinCheckImageDigests {
+		c.ImageWarnings = c.PinImageDigests(imageResolver)
+		for _, warning := range c.ImageWarnings {
+			log.Warningln("External check", c.CheckName, "in namespace", c.Namespace+":", warning)
 		}
-		log.Debugln("External check labels and annotations:", c.ExtraLabels, c.ExtraAnnotations)
+	}
 
-		// add the check into the checker
-		k.AddCheck(c)
+	// parse the user specified teardown timeout if present
+	if c.Teardown != nil && len(c.Teardown.Timeout) > 0 {
+		c.TeardownTimeout, err = time.ParseDuration(c.Teardown.Timeout)
+		if err != nil {
+			log.Errorln("Error parsing teardown timeout for check", c.CheckName, "in namespace", c.Namespace, err)
+			log.Errorln("Defaulting check to a teardown timeout of", external.DefaultTeardownTimeout)
+			c.TeardownTimeout = external.DefaultTeardownTimeout
+		}
 	}
 
-	return nil
+	// add on extra annotations and labels
+	if c.ExtraAnnotations != nil {
+		log.Debugln("External check setting extra annotations:", c.ExtraAnnotations)
+		c.ExtraAnnotations = r.Spec.ExtraAnnotations
+	}
+	if c.ExtraLabels != nil {
+		log.Debugln("External check setting extra labels:", c.ExtraLabels)
+		c.ExtraLabels = r.Spec.ExtraLabels
+	}
+	log.Debugln("External check labels and annotations:", c.ExtraLabels, c.ExtraAnnotations)
+
+	// give the check a dedicated service account if it asked for one
+	if r.Spec.ServiceAccount != nil {
+		c.ServiceAccountRules = r.Spec.ServiceAccount.Rules
+		if c.ServiceAccountRules == nil {
+			c.ServiceAccountRules = []rbacv1.PolicyRule{}
+		}
+		log.Debugln("External check", c.CheckName, "requested a service account with rules:", c.ServiceAccountRules)
+	}
+
+	return c
 }
 
 // StartChecks starts all checks concurrently and ensures they stay running
@@ -498,16 +984,32 @@ func (k *Kuberhealthy) StartChecks() {
 	ctx, cancelFunc := context.WithCancel(context.Background())
 	k.cancelChecksFunc = cancelFunc
 
+	// make channels that can be used to trigger checks out of band
+	k.runTriggersMu.Lock()
+	k.runTriggers = make(map[string]chan string)
+	for _, c := range k.Checks {
+		if _, ok := c.(runIDCheck); ok {
+			k.runTriggers[checkKey(c.CheckNamespace(), c.Name())] = make(chan string, 1)
+		}
+	}
+	k.runTriggersMu.Unlock()
+	k.schedulesMu.Lock()
+	k.schedules = make(map[string]*scheduler.Schedule)
+	k.schedulesMu.Unlock()
+
 	// start each check with this check group's context
 	for _, c := range k.Checks {
 		k.wg.Add(1)
 		// start the check in its own routine
-		go k.runCheck(ctx, c)
+		go k.superviseCheck(ctx, c)
 	}
 
-	// spin up the khState reaper with a context after checks have been configured and started
-	log.Infoln("control: reaper starting!")
-	go k.khStateResourceReaper(ctx)
+	// send throttled notifications and renotify checks that remain failing
+	if notificationDispatcher != nil {
+		go notificationDispatcher.Run(ctx, func() health.State {
+			return k.getCurrentState([]string{})
+		})
+	}
 }
 
 // masterStatusWatcher watches for master change events and updates the global upcomingMasterState along
@@ -542,6 +1044,16 @@ func (k *Kuberhealthy) masterStatusWatcher() {
 				log.Errorln(err)
 			}
 
+			// record the running kuberhealthy pods that checks are split across
+			if shardChecks {
+				pods, err := masterCalculation.RunningPods(kubernetesClient)
+				if err != nil {
+					log.Errorln(err)
+				} else {
+					shards.setUpcoming(pods)
+				}
+			}
+
 			// update the time we last saw a master event
 			lastMasterChangeTime = time.Now()
 		}
@@ -550,7 +1062,7 @@ func (k *Kuberhealthy) masterStatusWatcher() {
 
 // masterMonitor periodically evaluates the current and upcoming master state
 // and makes it so when appropriate
-func (k *Kuberhealthy) masterMonitor(becameMasterChan chan struct{}, lostMasterChan chan struct{}) {
+func (k *Kuberhealthy) masterMonitor(becameMasterChan chan struct{}, lostMasterChan chan struct{}, shardsChangedChan chan struct{}) {
 
 	// watch master pod event changes and recalculate the current master state of this pdo with each
 	go k.masterStatusWatcher()
@@ -571,29 +1083,130 @@ func (k *Kuberhealthy) masterMonitor(becameMasterChan chan struct{}, lostMasterC
 
 		// dupe the global to prevent races
 		goingToBeMaster := upcomingMasterState
+		wasMaster := isMaster
 
-		// stop checks if we are no longer the master
-		if goingToBeMaster && !isMaster {
+		// refresh global isMaster state before signaling so that the control loop sees the new state
+		isMaster = goingToBeMaster
+
+		// start checks if we are now master
+		if goingToBeMaster && !wasMaster {
 			becameMasterChan <- struct{}{}
 		}
 
-		// start checks if we are now master
-		if !goingToBeMaster && isMaster {
+		// stop checks if we are no longer the master
+		if !goingToBeMaster && wasMaster {
 			lostMasterChan <- struct{}{}
 		}
 
-		// refresh global isMaster state
-		isMaster = goingToBeMaster
+		// rebalance checks once the kuberhealthy pods they are split across have settled
+		if shardChecks && shards.settle() {
+			shardsChangedChan <- struct{}{}
+		}
+	}
+}
+
+// checkRestartBackoff spaces out the restarts of a check's run loop after it panics repeatedly
+var checkRestartBackoff = scheduler.Backoff{Threshold: 1, MaxInterval: time.Minute * 5}
+
+// checkRestartDelay is how long the run loop of a check waits before restarting after its first panic
+const checkRestartDelay = time.Second * 5
+
+// superviseCheck runs a check's run loop until the context is canceled and restarts it whenever it panics.
+// The panic is recorded as a failure of the check, and each consecutive panic doubles the wait before the
+// next restart so that a check that always panics does not spin.
+func (k *Kuberhealthy) superviseCheck(ctx context.Context, c KuberhealthyCheck) {
+	logger := checkLogger(c)
+	var panics int
+	for {
+		started := time.Now()
+		err := k.runCheckRecovered(ctx, c)
+		if err == nil {
+			return
+		}
+
+		// a run loop that stayed up longer than the longest backoff starts its backoff over
+		if time.Since(started) > checkRestartBackoff.MaxInterval {
+			panics = 0
+		}
+		panics++
+		delay := checkRestartBackoff.Interval(checkRestartDelay, panics-1)
+		logger.Errorln("Restarting check in", delay, "after it panicked", panics, "times in a row")
+		k.setCheckExecutionError(c.Name(), c.CheckNamespace(), err, nil)
+
+		key := checkKey(c.CheckNamespace(), c.Name())
+		k.heartbeats.SetPhase(key, checkPhaseRestarting)
+		k.heartbeats.Beat(key, time.Now().Add(delay+staleCheckGrace))
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			k.heartbeats.Remove(key)
+			return
+		}
 	}
 }
 
+// runCheckRecovered runs a check's run loop and returns an error if the run loop panics
+func (k *Kuberhealthy) runCheckRecovered(ctx context.Context, c KuberhealthyCheck) (err error) {
+	defer func() {
+		r := recover()
+		if r == nil {
+			return
+		}
+		checkLogger(c).WithField("stack", string(debug.Stack())).Errorln("Recovered from panic in check:", r)
+		err = fmt.Errorf("check panicked: %v", r)
+	}()
+	k.runCheck(ctx, c)
+	return nil
+}
+
 // runCheck runs a check on an interval and sets its status each run
 func (k *Kuberhealthy) runCheck(ctx context.Context, c KuberhealthyCheck) {
 
-	log.Println("Starting check:", c.CheckNamespace(), "/", c.Name())
+	logger := checkLogger(c)
+	logger.Infoln("Starting check")
+
+	// the check beats before each wait so that a check that stops making progress fails the liveness probe
+	key := checkKey(c.CheckNamespace(), c.Name())
+	defer k.heartbeats.Remove(key)
+
+	// runs can also be triggered out of band with a specific run UUID
+	trigger := k.runTrigger(c.CheckNamespace(), c.Name())
+	var runID string
+
+	// stagger the first run so that checks started together do not run in lockstep
+	delay := scheduler.StartDelay(key, c.Interval(), spreadCheckStarts, checkStartJitter)
+	if delay > 0 {
+		logger.Infoln("Delaying first run of check by", delay)
+		k.heartbeats.SetPhase(key, checkPhaseStarting)
+		k.heartbeats.Beat(key, time.Now().Add(delay+staleCheckGrace))
+		select {
+		case <-time.After(delay):
+		case runID = <-trigger:
+		case <-ctx.Done():
+			logger.Infoln("Shutting down check run due to context cancellation")
+			return
+		}
+	}
+
+	// run on an interval specified by the package.  Runs are kept on a fixed grid from the first run so
+	// that the time each run takes does not push back the runs after it.
+	schedule := scheduler.NewSchedule(time.Now(), c.Interval(), skipOverlappingRuns)
+	k.setSchedule(c.CheckNamespace(), c.Name(), schedule)
 
-	// run on an interval specified by the package
-	ticker := time.NewTicker(c.Interval())
+	// the reported health of the check only changes after enough runs in a row agree
+	loop := &runLoop{key: key, logger: logger, schedule: schedule, debouncer: &health.Debouncer{}}
+	if tc, ok := c.(thresholdCheck); ok {
+		loop.debouncer.FailureThreshold, loop.debouncer.SuccessThreshold = tc.Thresholds()
+	}
+
+	// checks whose runs can overlap run each run on a checker of its own, with up to the allowed number of
+	// earlier runs still in progress
+	var slots chan struct{}
+	oc, overlaps := c.(overlapCheck)
+	if overlaps && oc.OverlappingRuns() > 0 {
+		logger.Infoln("Allowing up to", oc.OverlappingRuns(), "earlier runs of check to be in progress when a new run starts")
+		slots = make(chan struct{}, oc.OverlappingRuns()+1)
+	}
 
 	// run the check forever and write its results to the kuberhealthy
 	// CRD resource for the check
@@ -604,89 +1217,435 @@ func (k *Kuberhealthy) runCheck(ctx context.Context, c KuberhealthyCheck) {
 		case <-ctx.Done():
 			// we don't need to call a check shutdown here because the same func that cancels this context calls
 			// shutdown on all the checks configured in the kuberhealthy struct.
-			log.Infoln("Shutting down check run due to context cancellation:", c.Name(), "in namespace", c.CheckNamespace())
+			logger.Infoln("Shutting down check run due to context cancellation")
 			return
 		default:
 		}
 
-		// Run the check
-		log.Infoln("Running check:", c.Name())
-		// Record check run start time
-		checkStartTime := time.Now()
-		err := c.Run(kubernetesClient)
-		if err != nil {
-			log.Errorln("Error running check:", c.Name(), "in namespace", c.CheckNamespace()+":", err)
-			if strings.Contains(err.Error(), "pod deleted expectedly") {
-				log.Infoln("Skipping this run due to expected pod removal before completion")
-				<-ticker.C
+		// low priority checks hold off while the cluster is under resource pressure.  Runs triggered out of
+		// band always run.
+		if len(runID) == 0 {
+			if reason := pressureSkipReason(logger, c); len(reason) > 0 {
+				k.heartbeats.SetPhase(key, checkPhaseReporting)
+				k.skipRunForPressure(logger, c, reason)
+				runID = k.waitForDeferredRun(ctx, key, schedule, trigger)
+				continue
 			}
-			// set any check run errors in the CRD
-			k.setCheckExecutionError(c.Name(), c.CheckNamespace(), err)
-			<-ticker.C
-			continue
 		}
-		log.Debugln("Done running check:", c.Name(), "in namespace", c.CheckNamespace())
-
-		// Record check run end time
-		// Subtract 10 seconds from run time since there are two 5 second sleeps during the check run where kuberhealthy
-		// waits for all pods to clear before running the check and waits for all pods to exit once the check has finished
-		// running. Both occur before and after the checker pod completes its run.
-		checkRunDuration := time.Now().Sub(checkStartTime) - time.Second*10
-
-		// make a new state for this check and fill it from the check's current status
-		checkDetails, err := getCheckState(c)
-		if err != nil {
-			log.Errorln("Error setting check state after run:", c.Name(), "in namespace", c.CheckNamespace()+":", err)
-		}
-		details := health.NewCheckDetails()
-		details.Namespace = c.CheckNamespace()
-		details.OK, details.Errors = c.CurrentStatus()
-		details.RunDuration = checkRunDuration.String()
-		details.CurrentUUID = checkDetails.CurrentUUID
-
-		// send data to the metric forwarder if configured
-		if k.MetricForwarder != nil {
-			checkStatus := 0
-			if details.OK {
-				checkStatus = 1
-			}
 
-			runDuration, err := time.ParseDuration(details.RunDuration)
-			if err != nil {
-				log.Errorln("Error parsing run duration", err)
+		if slots == nil {
+			k.runOnce(c, runID, loop)
+		} else {
+			select {
+			case slots <- struct{}{}:
+			case <-ctx.Done():
+				logger.Infoln("Shutting down check run due to context cancellation")
+				return
 			}
+			k.startOverlappingRun(ctx, oc.RunChecker(), runID, loop, slots)
+		}
 
-			tags := map[string]string{
-				"KuberhealthyPod": details.AuthoritativePod,
-				"Namespace":       c.CheckNamespace(),
-				"Name":            c.Name(),
-				"Errors":          strings.Join(details.Errors, ","),
-			}
-			metric := metrics.Metric{
-				{c.Name() + "." + c.CheckNamespace(): checkStatus},
-				{"RunDuration." + c.Name() + "." + c.CheckNamespace(): runDuration.Seconds()},
-			}
-			err = k.MetricForwarder.Push(metric, tags)
+		logger.Infoln("Waiting for next run of check")
+		runID = k.waitForNextRun(ctx, key, schedule, trigger) // wait for next run
+	}
+}
+
+// runLoop is what the run loop of a check keeps between runs
+type runLoop struct {
+	mu        sync.Mutex // held while the result of a run is recorded
+	key       string
+	logger    *log.Entry
+	schedule  *scheduler.Schedule
+	debouncer *health.Debouncer
+	failures  int // consecutive failed runs, used to back off checks that keep failing
+}
+
+// runOnce runs a check and stores its result.  When no run UUID is supplied, a new one is generated for checks
+// that accept one.
+func (k *Kuberhealthy) runOnce(c KuberhealthyCheck, runID string, loop *runLoop) {
+	// Run the check
+	k.heartbeats.Beat(loop.key, time.Now().Add(runDuration(c)+staleCheckGrace))
+	k.heartbeats.SetPhase(loop.key, checkPhaseRunning)
+	runLogger := loop.logger
+	// Record check run start time
+	checkStartTime := time.Now()
+	var err error
+	if rc, ok := c.(runIDCheck); ok {
+		// runs of checks that accept a run UUID are recorded so their results can be polled
+		triggered := len(runID) > 0
+		if !triggered {
+			runID = uuid.New().String()
+		}
+		runLogger = loop.logger.WithField(external.LogFieldRunID, runID)
+		if triggered {
+			runLogger.Infoln("Running check with triggered run UUID")
+		} else {
+			runLogger.Infoln("Running check")
+		}
+		k.runHistory.Start(runID, c.CheckNamespace(), c.Name())
+		err = rc.RunWithID(kubernetesClient, runID)
+		k.recordRunResult(c, runID, err)
+		k.recordUsage(c, time.Now().Sub(checkStartTime))
+	} else {
+		runLogger.Infoln("Running check")
+		err = c.Run(kubernetesClient)
+	}
+
+	// runs that overlap record their results one at a time
+	loop.mu.Lock()
+	defer loop.mu.Unlock()
+	if err != nil {
+		runLogger.Errorln("Error running check:", err)
+		if strings.Contains(err.Error(), "pod deleted expectedly") {
+			runLogger.Infoln("Skipping this run due to expected pod removal before completion")
+			loop.schedule.Skip(time.Now())
+		} else {
+			loop.failures++
+			k.backOff(runLogger, c, loop.schedule, loop.failures)
+		}
+		// set any check run errors in the CRD
+		k.heartbeats.SetPhase(loop.key, checkPhaseReporting)
+		k.setCheckExecutionError(c.Name(), c.CheckNamespace(), err, loop.debouncer)
+		k.archiveRun(c)
+		return
+	}
+	runLogger.Debugln("Done running check")
+
+	// Record check run end time
+	// Subtract 10 seconds from run time since there are two 5 second sleeps during the check run where kuberhealthy
+	// waits for all pods to clear before running the check and waits for all pods to exit once the check has finished
+	// running. Both occur before and after the checker pod completes its run.
+	checkRunDuration := time.Now().Sub(checkStartTime) - time.Second*10
+
+	// make a new state for this check and fill it from the check's current status
+	k.heartbeats.SetPhase(loop.key, checkPhaseReporting)
+	checkDetails, err := getCheckState(c)
+	if err != nil {
+		runLogger.Errorln("Error setting check state after run:", err)
+	}
+	base := checkDetails.DeepCopy()
+	details := health.NewCheckDetails()
+	details.Namespace = c.CheckNamespace()
+	details.LastRunOK, details.LastRunErrors = c.CurrentStatus()
+	details.OK, details.Errors = loop.debouncer.Record(details.LastRunOK, details.LastRunErrors)
+	if !details.LastRunOK {
+		details.ErrorCategory = health.ErrorCategoryCheck
+	}
+	details.RunDuration = checkRunDuration.String()
+	details.CurrentUUID = checkDetails.CurrentUUID
+	details.RunningUUIDs = checkDetails.RunningUUIDs
+	details.LastReport = checkDetails.LastReport
+	details.Assertions = checkDetails.Assertions
+	details.Artifacts = artifactsOfRun(checkDetails.Artifacts, checkDetails.CurrentUUID)
+	details.RunHistory = checkDetails.RunHistory
+	details.RecordRun(time.Now(), details.LastRunOK)
+	details.ErrorHistory = checkDetails.ErrorHistory
+	details.RecordErrors(time.Now(), details.LastRunErrors)
+	details.Architectures = checkDetails.Architectures
+	details.Measurements = checkDetails.Measurements
+	details.Baselines = checkDetails.Baselines
+	recordRunArchitecture(c, &details)
+	if sc, ok := c.(sloCheck); ok {
+		details.SLOTarget = sc.AvailabilityTarget()
+	}
+	if sc, ok := c.(severityCheck); ok {
+		details.Severity = sc.RollupSeverity()
+	}
+	if lc, ok := c.(labeledCheck); ok {
+		details.Labels = lc.Labels()
+	}
+	if wc, ok := c.(warningCheck); ok {
+		details.Warnings = wc.Warnings()
+	}
+	details.Pod = checkerPod(c, checkDetails.Pod)
+
+	// back off before the check is stored so that its stale time accounts for the new interval
+	if details.LastRunOK {
+		loop.failures = 0
+	} else {
+		loop.failures++
+	}
+	k.backOff(runLogger, c, loop.schedule, loop.failures)
+	details.StaleAt = k.staleAt(c)
+	details.UnknownAt = unknownAt(c)
+
+	// send data to the metric forwarder if configured
+	if k.MetricForwarder != nil {
+		checkStatus := 0
+		if details.OK {
+			checkStatus = 1
+		}
+
+		runDuration, err := time.ParseDuration(details.RunDuration)
+		if err != nil {
+			runLogger.Errorln("Error parsing run duration", err)
+		}
+
+		tags := map[string]string{
+			"KuberhealthyPod": details.AuthoritativePod,
+			"Namespace":       c.CheckNamespace(),
+			"Name":            c.Name(),
+			"Errors":          strings.Join(details.Errors, ","),
+		}
+		metric := metrics.Metric{
+			{c.Name() + "." + c.CheckNamespace(): checkStatus},
+			{"RunDuration." + c.Name() + "." + c.CheckNamespace(): runDuration.Seconds()},
+		}
+		err = k.MetricForwarder.Push(metric, tags)
+		if err != nil {
+			runLogger.Errorln("Error forwarding metrics", err)
+		}
+	}
+
+	runLogger.WithFields(log.Fields{
+		"ok":       details.OK,
+		"errors":   details.Errors,
+		"duration": details.RunDuration,
+	}).Infoln("Setting state of check")
+
+	// store the check state with the CRD
+	err = k.storeCheckState(c.Name(), c.CheckNamespace(), base, details)
+	if err != nil {
+		runLogger.Errorln("Error storing CRD state for check:", err)
+	}
+	k.archiveRun(c)
+}
+
+// startOverlappingRun runs a check on a checker of its own in the background, so that the run loop can start
+// the next run while this one is still in progress.  The run gives up its slot once its result is stored, and
+// is shut down when the run loop is.
+func (k *Kuberhealthy) startOverlappingRun(ctx context.Context, runner *external.Checker, runID string, loop *runLoop, slots chan struct{}) {
+	done := make(chan struct{})
+	go func() {
+		select {
+		case <-ctx.Done():
+			err := runner.Shutdown()
 			if err != nil {
-				log.Errorln("Error forwarding metrics", err)
+				loop.logger.Errorln("Error shutting down overlapping run of check:", err)
 			}
+		case <-done:
 		}
+	}()
+	go func() {
+		defer func() { <-slots }()
+		defer close(done)
+		k.runOnce(runner, runID, loop)
+	}()
+}
 
-		log.Infoln("Setting state of check", c.Name(), "in namespace", c.CheckNamespace(), "to", details.OK, details.Errors, details.RunDuration, details.CurrentUUID)
+// backOff sets the interval of a check's schedule based on its number of consecutive failures.  Checks
+// return to their normal interval as soon as they succeed.
+func (k *Kuberhealthy) backOff(logger *log.Entry, c KuberhealthyCheck, schedule *scheduler.Schedule, failures int) {
+	interval := failureBackoff.Interval(c.Interval(), failures)
+	if interval == schedule.Stats().Interval {
+		return
+	}
+	if interval == c.Interval() {
+		logger.Infoln("Check returned to its normal interval of", interval)
+	} else {
+		logger.Warningln("Check failed", failures, "times in a row. Backing off to an interval of", interval)
+	}
+	schedule.SetInterval(interval)
+}
 
-		// store the check state with the CRD
-		err = k.storeCheckState(c.Name(), c.CheckNamespace(), details)
-		if err != nil {
-			log.Errorln("Error storing CRD state for check:", c.Name(), "in namespace", c.CheckNamespace(), err)
+// waitForNextRun blocks until the check's next scheduled run or a run is triggered out of band.  The run
+// UUID of a triggered run is returned.  A blank UUID is returned for regular runs.
+func (k *Kuberhealthy) waitForNextRun(ctx context.Context, key string, schedule *scheduler.Schedule, trigger chan string) string {
+	scheduled := schedule.Next(time.Now())
+	k.heartbeats.Beat(key, scheduled.Add(staleCheckGrace))
+	k.heartbeats.SetPhase(key, checkPhaseIdle)
+	timer := time.NewTimer(time.Until(scheduled))
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		schedule.Started(scheduled, time.Now())
+		return ""
+	case runID := <-trigger:
+		return runID
+	case <-ctx.Done():
+		return ""
+	}
+}
+
+// recordRunResult records the outcome of a check run in the run history
+func (k *Kuberhealthy) recordRunResult(c KuberhealthyCheck, runID string, err error) {
+	if pc, ok := c.(podCheck); ok && pc.CheckerPod() != nil {
+		k.runHistory.SetImage(runID, pc.CheckerPod().Image, pc.CheckerPod().ImageDigest)
+	}
+	switch {
+	case err == nil:
+		ok, errors := c.CurrentStatus()
+		phase := runhistory.PhaseSucceeded
+		if !ok {
+			phase = runhistory.PhaseFailed
+		}
+		k.runHistory.Finish(runID, phase, errors)
+	case err == external.ErrPodRemovedExpectedly:
+		k.runHistory.Finish(runID, runhistory.PhaseSkipped, []string{err.Error()})
+	case external.IsTimeout(err):
+		k.runHistory.Finish(runID, runhistory.PhaseTimedOut, []string{err.Error()})
+	default:
+		k.runHistory.Finish(runID, runhistory.PhaseFailed, []string{err.Error()})
+	}
+}
+
+// recordUsage adds the resources that the checker pod of a run requested for the duration of the run to the
+// usage of its check.  Runs that did not create a checker pod are not counted.
+func (k *Kuberhealthy) recordUsage(c KuberhealthyCheck, duration time.Duration) {
+	pc, ok := c.(podCheck)
+	if !ok || pc.CheckerPod() == nil {
+		return
+	}
+	k.usage.Record(c.CheckNamespace(), c.Name(), pc.PodRequests(), duration)
+}
+
+// auditReport records a report-in from a checker pod in the audit log
+func auditReport(eventType audit.EventType, ipReport PodReportIPInfo, state status.Report) {
+	err := auditLog.Record(audit.Event{
+		Type:      eventType,
+		Check:     ipReport.Name,
+		Namespace: ipReport.Namespace,
+		RunID:     ipReport.UUID,
+		Pod:       ipReport.Pod,
+		SourceIP:  ipReport.IP,
+		OK:        audit.Bool(state.OK),
+		Errors:    state.Errors,
+	})
+	if err != nil {
+		log.Errorln("Failed to record report in audit log:", err)
+	}
+}
+
+// auditRejectedReport records a report-in that was refused in the audit log.  The calling pod is only
+// known when the report was rejected after the pod was validated.
+func auditRejectedReport(ipReport PodReportIPInfo, reason string) {
+	err := auditLog.Record(audit.Event{
+		Type:      audit.EventReportRejected,
+		Check:     ipReport.Name,
+		Namespace: ipReport.Namespace,
+		RunID:     ipReport.UUID,
+		Pod:       ipReport.Pod,
+		SourceIP:  ipReport.IP,
+		Reason:    reason,
+	})
+	if err != nil {
+		log.Errorln("Failed to record rejected report in audit log:", err)
+	}
+}
+
+// sourceIP returns the IP of a remote ip:port address
+func sourceIP(remoteAddr string) string {
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		return remoteAddr
+	}
+	return host
+}
+
+// checkLogger returns a log entry with fields that correlate log lines to a check
+func checkLogger(c KuberhealthyCheck) *log.Entry {
+	return log.WithFields(log.Fields{
+		external.LogFieldCheck:     c.Name(),
+		external.LogFieldNamespace: c.CheckNamespace(),
+	})
+}
+
+// checkKey returns the key used to look up a check by namespace and name
+func checkKey(namespace string, name string) string {
+	return namespace + "/" + name
+}
+
+// setSchedule records the run schedule of the specified check so its statistics can be exported
+func (k *Kuberhealthy) setSchedule(namespace string, name string, schedule *scheduler.Schedule) {
+	k.schedulesMu.Lock()
+	defer k.schedulesMu.Unlock()
+	if k.schedules != nil {
+		k.schedules[checkKey(namespace, name)] = schedule
+	}
+}
+
+// staleAt returns the time after which a check is shown as failed if it has not completed another run.
+// The next run of a check must complete within its current interval, including any backoff, and its
+// timeout, with a grace period on top.
+func (k *Kuberhealthy) staleAt(c KuberhealthyCheck) time.Time {
+	interval := c.Interval()
+	k.schedulesMu.Lock()
+	schedule, ok := k.schedules[checkKey(c.CheckNamespace(), c.Name())]
+	k.schedulesMu.Unlock()
+	if ok {
+		interval = schedule.Stats().Interval
+	}
+	return time.Now().Add(interval + runDuration(c) + staleCheckGrace)
+}
+
+// runDuration returns the longest a run of a check can take, which is its timeout along with the time its
+// teardown can take afterwards
+func runDuration(c KuberhealthyCheck) time.Duration {
+	if tc, ok := c.(teardownCheck); ok {
+		return c.Timeout() + tc.TeardownDuration()
+	}
+	return c.Timeout()
+}
+
+// unknownAt returns the time after which the health of a check is shown as unknown if no new result has
+// arrived, or the zero time if the results of the check do not expire
+func unknownAt(c KuberhealthyCheck) time.Time {
+	rc, ok := c.(resultTTLCheck)
+	if !ok || rc.ResultLifetime() <= 0 {
+		return time.Time{}
+	}
+	return time.Now().Add(rc.ResultLifetime())
+}
+
+// checkSchedules returns the scheduling statistics of all running checks
+func (k *Kuberhealthy) checkSchedules() []metrics.CheckSchedule {
+	k.schedulesMu.Lock()
+	defer k.schedulesMu.Unlock()
+	var schedules []metrics.CheckSchedule
+	for _, c := range k.Checks {
+		schedule, ok := k.schedules[checkKey(c.CheckNamespace(), c.Name())]
+		if !ok {
+			continue
 		}
+		schedules = append(schedules, metrics.CheckSchedule{
+			Check:     c.Name(),
+			Namespace: c.CheckNamespace(),
+			Stats:     schedule.Stats(),
+		})
+	}
+	return schedules
+}
+
+// runTrigger returns the channel used to trigger runs of the specified check
+func (k *Kuberhealthy) runTrigger(namespace string, name string) chan string {
+	k.runTriggersMu.Lock()
+	defer k.runTriggersMu.Unlock()
+	return k.runTriggers[checkKey(namespace, name)]
+}
+
+// triggerRun queues an immediate run of the specified check and returns the UUID the run will use.  The
+// run starts as soon as the check's current run, if any, has finished.
+func (k *Kuberhealthy) triggerRun(namespace string, name string) (string, error) {
+	trigger := k.runTrigger(namespace, name)
+	if trigger == nil {
+		return "", ErrCheckNotFound
+	}
 
-		log.Infoln("Waiting for next run of check", c.Name(), "in namespace", c.CheckNamespace())
-		<-ticker.C // wait for next run
+	// the run is queued in the history first so that it is never recorded as started before it is queued
+	runID := uuid.New().String()
+	k.runHistory.Queue(runID, namespace, name)
+	select {
+	case trigger <- runID:
+		log.Infoln("Triggered run of check", name, "in namespace", namespace, "with run UUID", runID)
+		return runID, nil
+	default:
+		k.runHistory.Remove(runID)
+		return "", ErrRunAlreadyQueued
 	}
 }
 
-// storeCheckState stores the check state in its cluster CRD
-func (k *Kuberhealthy) storeCheckState(checkName string, checkNamespace string, details health.CheckDetails) error {
+// storeCheckState stores the check state in its cluster CRD.  base is the state that details was made from.
+func (k *Kuberhealthy) storeCheckState(checkName string, checkNamespace string, base health.CheckDetails, details health.CheckDetails) error {
 
 	// ensure the CRD resource exits
 	err := ensureStateResourceExists(checkName, checkNamespace)
@@ -695,11 +1654,37 @@ func (k *Kuberhealthy) storeCheckState(checkName string, checkNamespace string,
 	}
 
 	// put the status on the CRD from the check
-	err = setCheckStateResource(checkName, checkNamespace, details)
+	previous, found := k.stateReflector.CurrentStatus().CheckDetails[checkNamespace+"/"+checkName]
+	err = setCheckStateResource(checkName, checkNamespace, base, details)
 	if err != nil {
 		return err
 	}
 
+	// changes to the reported health of the check are audited along with the errors that caused them
+	if !found || previous.OK != details.OK {
+		event := audit.Event{
+			Type:      audit.EventStateChange,
+			Check:     checkName,
+			Namespace: checkNamespace,
+			RunID:     details.CurrentUUID,
+			OK:        audit.Bool(details.OK),
+			Errors:    details.Errors,
+		}
+		if found {
+			event.PreviousOK = audit.Bool(previous.OK)
+		}
+		err = auditLog.Record(event)
+		if err != nil {
+			log.Errorln("Failed to record state change of check", checkName, "in audit log:", err)
+		}
+	}
+
+	// webhooks are notified when a check starts failing, fails differently, or recovers.  New checks only
+	// notify if they fail.
+	if (found && previous.OK != details.OK) || (!details.OK && (!found || !reflect.DeepEqual(previous.Errors, details.Errors))) {
+		k.notifyStateChange(checkName, checkNamespace, details)
+	}
+
 	log.Debugln("Successfully updated CRD for check:", checkName, "in namespace", checkNamespace)
 	return err
 }
@@ -722,6 +1707,107 @@ func (k *Kuberhealthy) StartWebServer() {
 		}
 	})
 
+	// Accept artifacts uploaded by external checker pods as evidence for their runs
+	http.HandleFunc(status.ArtifactPath, func(w http.ResponseWriter, r *http.Request) {
+		err := k.externalCheckArtifactHandler(w, r)
+		if err != nil {
+			log.Errorln("externalCheckArtifact endpoint error:", err)
+		}
+	})
+
+	// Serve the authenticated, versioned API and its OpenAPI specification
+	http.HandleFunc(apiPrefix, func(w http.ResponseWriter, r *http.Request) {
+		err := k.apiHandler(w, r)
+		if err != nil {
+			log.Errorln("api endpoint error:", err)
+		}
+	})
+	http.HandleFunc(openAPIPath, func(w http.ResponseWriter, r *http.Request) {
+		err := openAPIHandler(w, r)
+		if err != nil {
+			log.Errorln("openapi endpoint error:", err)
+		}
+	})
+
+	// Render checker pods without creating them when enabled
+	if enableDryRun {
+		http.HandleFunc("/dryRun", func(w http.ResponseWriter, r *http.Request) {
+			err := k.dryRunHandler(w, r)
+			if err != nil {
+				log.Errorln("dryRun endpoint error:", err)
+			}
+		})
+	}
+
+	// Serve profiles, runtime statistics, and goroutine dumps when enabled
+	if enableProfiling {
+		log.Warningln("Profiling endpoints are enabled")
+		http.HandleFunc(profilingPrefix, func(w http.ResponseWriter, r *http.Request) {
+			err := profilingHandler(w, r)
+			if err != nil {
+				log.Errorln("profiling endpoint error:", err)
+			}
+		})
+		http.HandleFunc(varsPath, func(w http.ResponseWriter, r *http.Request) {
+			err := varsHandler(w, r)
+			if err != nil {
+				log.Errorln("vars endpoint error:", err)
+			}
+		})
+		http.HandleFunc(goroutinesPath, func(w http.ResponseWriter, r *http.Request) {
+			err := goroutinesHandler(w, r)
+			if err != nil {
+				log.Errorln("goroutines endpoint error:", err)
+			}
+		})
+	}
+
+	// Serve the combined status of federated clusters when enabled
+	if federator != nil {
+		http.HandleFunc("/federation", func(w http.ResponseWriter, r *http.Request) {
+			err := k.federationHandler(w, r)
+			if err != nil {
+				log.Errorln("federation endpoint error:", err)
+			}
+		})
+		http.HandleFunc("/federation/push", func(w http.ResponseWriter, r *http.Request) {
+			err := k.federationPushHandler(w, r)
+			if err != nil {
+				log.Errorln("federation push endpoint error:", err)
+			}
+		})
+	}
+
+	// Serve the liveness and readiness of Kuberhealthy itself
+	http.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		err := k.livenessHandler(w, r)
+		if err != nil {
+			log.Errorln("healthz endpoint error:", err)
+		}
+	})
+	http.HandleFunc("/ready", func(w http.ResponseWriter, r *http.Request) {
+		err := k.readinessHandler(w, r)
+		if err != nil {
+			log.Errorln("ready endpoint error:", err)
+		}
+	})
+
+	// Serve what the goroutine of each check is doing, so that stuck checks can be told apart from idle ones
+	http.HandleFunc(debugChecksPath, func(w http.ResponseWriter, r *http.Request) {
+		err := k.debugChecksHandler(w, r)
+		if err != nil {
+			log.Errorln("debug checks endpoint error:", err)
+		}
+	})
+
+	// Serve and change the log level, so that debug logging can be turned on without a redeploy
+	http.HandleFunc(logLevelPath, func(w http.ResponseWriter, r *http.Request) {
+		err := logLevelHandler(w, r)
+		if err != nil {
+			log.Errorln("log level endpoint error:", err)
+		}
+	})
+
 	// Assign all requests to be handled by the healthCheckHandler function
 	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
 		err := k.healthCheckHandler(w, r)
@@ -733,7 +1819,18 @@ func (k *Kuberhealthy) StartWebServer() {
 	// start web server any time it exits
 	for {
 		log.Infoln("Starting web services on port", k.ListenAddr)
-		err := http.ListenAndServe(k.ListenAddr, nil)
+		var err error
+		if tlsReloader != nil {
+			// client certificates are only required on the report-in endpoint, so the status page and
+			// metrics stay reachable without one
+			server := &http.Server{
+				Addr:      k.ListenAddr,
+				TLSConfig: tlsReloader.ServerConfig(false),
+			}
+			err = server.ListenAndServeTLS("", "")
+		} else {
+			err = http.ListenAndServe(k.ListenAddr, nil)
+		}
 		if err != nil {
 			log.Errorln("Web server ERROR:", err)
 		}
@@ -746,12 +1843,15 @@ type PodReportIPInfo struct {
 	Name      string
 	UUID      string
 	Namespace string
+	Pod       string // the name of the calling pod
+	IP        string // the IP the report came from
 }
 
 // validateExternalRequest calls the Kubernetes API to fetch details about a pod by it's source IP
 // and then validates that the pod is allowed to report the status of a check.  The pod is expected
-// to have the environment variables KH_CHECK_NAME and KH_RUN_UUID
-func (k *Kuberhealthy) validateExternalRequest(remoteIPPort string) (PodReportIPInfo, error) {
+// to have the environment variables KH_CHECK_NAME and KH_RUN_UUID.  When a check token audience is configured,
+// the bearer token sent with the request must also have been issued to the calling pod.
+func (k *Kuberhealthy) validateExternalRequest(remoteIPPort string, token string) (PodReportIPInfo, error) {
 
 	var podUUID string
 	var podCheckName string
@@ -780,6 +1880,16 @@ func (k *Kuberhealthy) validateExternalRequest(remoteIPPort string) (PodReportIP
 	}
 
 	podCheckNamespace = pod.GetNamespace()
+
+	// pods running in an ephemeral namespace report for the check namespace on their annotation.  The
+	// annotation is only trusted when the pod's namespace was created by Kuberhealthy for that check.
+	if ns := pod.Annotations[external.KHCheckNamespaceAnnotation]; len(ns) > 0 && ns != podCheckNamespace {
+		err = validateEphemeralNamespace(podCheckNamespace, ns, podCheckName)
+		if err != nil {
+			return reportInfo, err
+		}
+		podCheckNamespace = ns
+	}
 	log.Debugln("Found check named", podCheckName, "in namespace", podCheckNamespace)
 
 	// pile up all the env vars for searching
@@ -823,6 +1933,8 @@ func (k *Kuberhealthy) validateExternalRequest(remoteIPPort string) (PodReportIP
 	reportInfo.Name = podCheckName
 	reportInfo.Namespace = podCheckNamespace
 	reportInfo.UUID = podUUID
+	reportInfo.Pod = pod.GetName()
+	reportInfo.IP = ip
 
 	// next, we check the uuid against the check name to see if this uuid is the expected one.  if it isn't,
 	// we return an error
@@ -834,9 +1946,33 @@ func (k *Kuberhealthy) validateExternalRequest(remoteIPPort string) (PodReportIP
 		return reportInfo, errors.New("pod was not properly whitelisted for reporting status of check " + podCheckName + " with uuid " + podUUID + " and namespace " + podCheckNamespace)
 	}
 
+	// the report must come from the checker pod Kuberhealthy recorded for the run.  Other workloads could
+	// otherwise copy the check's annotation, labels, and run UUID onto their own pods.
+	checkState, err := stateStore.Get(podCheckName, podCheckNamespace)
+	if err != nil {
+		return reportInfo, fmt.Errorf("failed to fetch the checker pod of run %s with error: %w", podUUID, err)
+	}
+	err = external.ValidateReportingPod(&pod, checkState, podUUID, ip)
+	if err != nil {
+		return reportInfo, err
+	}
+
+	// the pod IP is only as trustworthy as the network, so bind the report to the identity of the pod itself
+	if len(checkTokenAudience) > 0 {
+		err = external.ValidateReportingToken(kubernetesClient, &pod, checkTokenAudience, token)
+		if err != nil {
+			return reportInfo, err
+		}
+	}
+
 	return reportInfo, nil
 }
 
+// bearerToken returns the bearer token sent in the Authorization header of a request
+func bearerToken(r *http.Request) string {
+	return strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+}
+
 // fetchPodByIPForDuration attempts to fetch a pod by its IP repeatedly for the supplied duration.  If the pod is found,
 // then we return it.  If the pod is not found after the duraiton, we return an error
 func (k *Kuberhealthy) fetchPodByIPForDuration(remoteIP string, d time.Duration) (v1.Pod, error) {
@@ -858,16 +1994,28 @@ func (k *Kuberhealthy) fetchPodByIPForDuration(remoteIP string, d time.Duration)
 	}
 }
 
+// validateEphemeralNamespace ensures that a pod namespace is an ephemeral namespace Kuberhealthy created for
+// the named check in the check namespace, so that pods in it may report for that check
+func validateEphemeralNamespace(podNamespace string, checkNamespace string, checkName string) error {
+	ns, err := kubernetesClient.CoreV1().Namespaces().Get(podNamespace, metav1.GetOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to fetch namespace %s of calling pod: %w", podNamespace, err)
+	}
+	if ns.Labels[external.EphemeralNamespaceLabel] != checkNamespace || ns.Labels[external.KuberhealthyCheckNameLabel] != checkName {
+		return fmt.Errorf("calling pod in namespace %s is not in an ephemeral namespace of check %s/%s", podNamespace, checkNamespace, checkName)
+	}
+	return nil
+}
+
 // fetchPodByIP fetches the pod by it's IP address.
 func (k *Kuberhealthy) fetchPodByIP(remoteIP string) (v1.Pod, error) {
 	var pod v1.Pod
 
 	// find the pod by its IP address
-	podClient := kubernetesClient.CoreV1().Pods("")
 	listOptions := metav1.ListOptions{
 		FieldSelector: "status.podIP==" + remoteIP + ",status.phase==Running",
 	}
-	podList, err := podClient.List(listOptions)
+	podList, err := listPods(listOptions)
 	if err != nil {
 		return pod, errors.New("failed to fetch pod with remote ip " + remoteIP + " with error: " + err.Error())
 	}
@@ -896,8 +2044,17 @@ func (k *Kuberhealthy) fetchPodByIP(remoteIP string) (v1.Pod, error) {
 	return podList.Items[0], nil
 }
 
-func (k *Kuberhealthy) externalCheckReportHandlerLog(s ...interface{}) {
-	log.Infoln(s...)
+// LogFieldRequestID is the structured log field that correlates log lines to a report sent by a checker pod
+const LogFieldRequestID = "request_id"
+
+// reportLogger returns a log entry with fields that correlate log lines to a report sent by a checker pod
+func reportLogger(logger *log.Entry, ipReport PodReportIPInfo) *log.Entry {
+	return logger.WithFields(log.Fields{
+		external.LogFieldCheck:     ipReport.Name,
+		external.LogFieldNamespace: ipReport.Namespace,
+		external.LogFieldRunID:     ipReport.UUID,
+		external.LogFieldPod:       ipReport.Pod,
+	})
 }
 
 // externalCheckReportHandler handles requests coming from external checkers reporting their status.
@@ -908,85 +2065,230 @@ func (k *Kuberhealthy) externalCheckReportHandlerLog(s ...interface{}) {
 // to be reporting its status.
 func (k *Kuberhealthy) externalCheckReportHandler(w http.ResponseWriter, r *http.Request) error {
 	// make a request ID for tracking this request
-	requestID := "web: " + uuid.New().String()
+	logger := log.WithFields(log.Fields{LogFieldRequestID: uuid.New().String(), "transport": "http"})
+
+	logger.Infoln("Client connected to check report handler from", r.RemoteAddr, r.UserAgent())
 
-	k.externalCheckReportHandlerLog(requestID, "Client connected to check report handler from", r.RemoteAddr, r.UserAgent())
+	// when mutual TLS is enabled, reports must come with a verified client certificate
+	if tlsReloader != nil && tlsReloader.MutualTLS() && (r.TLS == nil || len(r.TLS.PeerCertificates) == 0) {
+		w.WriteHeader(http.StatusUnauthorized)
+		logger.Infoln("Client did not present a client certificate:", r.RemoteAddr)
+		auditRejectedReport(PodReportIPInfo{IP: sourceIP(r.RemoteAddr)}, "client did not present a client certificate")
+		return nil
+	}
 
 	// validate the calling pod to ensure that it has a proper KH_CHECK_NAME and KH_RUN_UUID
-	k.externalCheckReportHandlerLog(requestID, "validating external check status report from: ", r.RemoteAddr)
-	ipReport, err := k.validateExternalRequest(r.RemoteAddr)
+	logger.Infoln("validating external check status report from: ", r.RemoteAddr)
+	ipReport, err := k.validateExternalRequest(r.RemoteAddr, bearerToken(r))
 	if err != nil {
 		w.WriteHeader(http.StatusBadRequest)
-		k.externalCheckReportHandlerLog(requestID, "Failed to look up pod by IP:", r.RemoteAddr, err)
+		logger.Infoln("Failed to look up pod by IP:", r.RemoteAddr, err)
+		auditRejectedReport(PodReportIPInfo{IP: sourceIP(r.RemoteAddr)}, err.Error())
 		return nil
 	}
-	k.externalCheckReportHandlerLog(requestID, "Calling pod is", ipReport.Name, "in namespace", ipReport.Namespace)
-
-	// append pod info to request id for easy check tracing in logs
-	requestID = requestID + " (" + ipReport.Namespace + "/" + ipReport.Name + ")"
+	// add the check and run of the calling pod to every following log line
+	logger = reportLogger(logger, ipReport)
+	logger.Infoln("Calling pod validated")
 
 	// ensure the client is sending a valid payload in the request body
 	b, err := ioutil.ReadAll(r.Body)
 	if err != nil {
 		w.WriteHeader(http.StatusBadRequest)
-		k.externalCheckReportHandlerLog(requestID, "Failed to read request body:", err.Error(), r.RemoteAddr)
+		logger.Infoln("Failed to read request body:", err.Error(), r.RemoteAddr)
 		return nil
 	}
-	log.Debugln("Check report body:", string(b))
+	logger.Debugln("Check report body:", string(b))
 
 	// decode the bytes into a status struct as used by the client
 	state := status.Report{}
 	err = json.Unmarshal(b, &state)
 	if err != nil {
 		w.WriteHeader(http.StatusBadRequest)
-		k.externalCheckReportHandlerLog(requestID, "Failed to unmarshal state json:", err, r.RemoteAddr)
+		logger.Infoln("Failed to unmarshal state json:", err, r.RemoteAddr)
+		auditRejectedReport(ipReport, "failed to unmarshal report: "+err.Error())
 		return nil
 	}
-	log.Debugf("Check report after unmarshal: +%v\n", state)
+	logger.Debugf("Check report after unmarshal: +%v\n", state)
 
 	// ensure that if ok is set to false, then an error is provided
-	if !state.OK {
-		if len(state.Errors) == 0 {
-			w.WriteHeader(http.StatusBadRequest)
-			k.externalCheckReportHandlerLog(requestID, "Client attempted to report OK false without any error strings")
-			return nil
-		}
-		for _, e := range state.Errors {
-			if len(e) == 0 {
-				w.WriteHeader(http.StatusBadRequest)
-				k.externalCheckReportHandlerLog(requestID, "Client attempted to report a blank error string")
-				return nil
-			}
+	err = state.Validate()
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		logger.Infoln("Client sent an invalid report:", err)
+		auditRejectedReport(ipReport, "invalid report: "+err.Error())
+		return nil
+	}
+
+	// in-progress updates are recorded without completing the run
+	if state.InProgress {
+		err = k.storeExternalProgress(logger, ipReport, state)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			return err
 		}
+		w.WriteHeader(http.StatusOK)
+		logger.Infoln("Progress update completed successfully.")
+		return nil
+	}
+
+	// since the check is validated, we can proceed to update the status now
+	parent, err := tracing.ParseTraceparent(r.Header.Get(tracing.TraceparentHeader))
+	if err != nil && len(r.Header.Get(tracing.TraceparentHeader)) > 0 {
+		logger.Debugln("Ignoring invalid traceparent header:", err)
 	}
+	err = k.storeExternalReport(logger, ipReport, state, parent)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return err
+	}
+
+	// write ok back to caller
+	w.WriteHeader(http.StatusOK)
+	logger.Infoln("Request completed successfully.")
+	return nil
+}
+
+// storeExternalReport stores a validated report from an external checker pod in the check's khstate resource.
+// This is shared by the HTTP and gRPC reporting endpoints.  The report is traced as a child of the trace
+// context sent by the checker pod, or of the check's current run when the pod sent none.
+func (k *Kuberhealthy) storeExternalReport(logger *log.Entry, ipReport PodReportIPInfo, state status.Report, parent tracing.SpanContext) (err error) {
+	if !parent.IsValid() {
+		parent = k.runTraceContext(ipReport.Name, ipReport.Namespace)
+	}
+	span := tracing.Start("report in", parent)
+	span.SetAttribute("kuberhealthy.check", ipReport.Name)
+	span.SetAttribute("kuberhealthy.namespace", ipReport.Namespace)
+	span.SetAttribute("kuberhealthy.run_uuid", ipReport.UUID)
+	span.SetAttribute("kuberhealthy.ok", strconv.FormatBool(state.OK))
+	defer func() {
+		span.SetError(err)
+		span.Finish()
+	}()
 
 	// Need to fetch current check run duration so we do not overwrite it when updating KHState object
-	checkDetails := k.stateReflector.CurrentStatus().CheckDetails
+	current, found := k.stateReflector.CheckDetails(ipReport.Namespace, ipReport.Name)
+	if found && len(current.RunningUUIDs) > 0 {
+		// overlapping runs start often enough that the reflector can miss the newest run
+		latest, err := stateStore.Get(ipReport.Name, ipReport.Namespace)
+		if err == nil {
+			current = latest
+		}
+	}
 	checkRunDuration := time.Duration(0).String()
-	if checkDetails != nil {
-		checkRunDuration = checkDetails[ipReport.Namespace+"/"+ipReport.Name].RunDuration
+	base := health.CheckDetails{}
+	if found {
+		checkRunDuration = current.RunDuration
+		base = current.DeepCopy()
 	}
 
-	// create a details object from our incoming status report before storing it as a khstate custom resource
+	// create a details object from our incoming status report before storing it as a khstate custom resource.
+	// The report is stored as the result of the last run while the reported health is carried over until the
+	// check's run loop applies its failure and success thresholds.
 	details := health.NewCheckDetails()
-	details.Errors = state.Errors
-	details.OK = state.OK
+	details.LastRunErrors = state.Errors
+	details.LastRunOK = state.OK
+	if !state.OK {
+		details.ErrorCategory = health.ErrorCategoryCheck
+	}
+	details.OK = true
+	if found {
+		details.OK = current.OK
+		if current.Errors != nil {
+			details.Errors = current.Errors
+		}
+	}
 	details.RunDuration = checkRunDuration
 	details.Namespace = ipReport.Namespace
 	details.CurrentUUID = ipReport.UUID
+	details.LastReport = time.Now()
+	details.Assertions = state.Assertions
+	if found {
+		details.StaleAt = current.StaleAt
+		details.Artifacts = artifactsOfRun(current.Artifacts, ipReport.UUID)
+		details.SLOTarget = current.SLOTarget
+		details.Severity = current.Severity
+		details.Labels = current.Labels
+		details.Warnings = current.Warnings
+		details.Pod = current.Pod
+		details.RunHistory = current.RunHistory
+		details.ErrorHistory = current.ErrorHistory
+		if current.Running(ipReport.UUID) {
+			// the report of an overlapping run leaves the newest run current
+			details.CurrentUUID = current.CurrentUUID
+			details.RunningUUIDs = current.RunningUUIDs
+			details.FinishRun(ipReport.UUID)
+		}
+		details.Architectures = current.Architectures
+		details.Baselines = current.Baselines
+	}
 
-	// since the check is validated, we can proceed to update the status now
-	k.externalCheckReportHandlerLog(requestID, "Setting check with name", ipReport.Name, "in namespace", ipReport.Namespace, "to 'OK' state:", details.OK, "uuid", details.CurrentUUID)
-	err = k.storeCheckState(ipReport.Name, ipReport.Namespace, details)
+	// measurements that regressed fail the run before it is stored
+	details.Measurements = state.Measurements
+	if c, err := k.getCheck(ipReport.Name, ipReport.Namespace); err == nil {
+		if rc, ok := c.(regressionCheck); ok {
+			rc.CheckRegressions(&details)
+		}
+		details.UnknownAt = unknownAt(c)
+	}
+
+	auditReport(audit.EventReport, ipReport, state)
+	logger.Infoln("Setting check to 'OK' state:", details.LastRunOK)
+	err = k.storeCheckState(ipReport.Name, ipReport.Namespace, base, details)
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		k.externalCheckReportHandlerLog(requestID, "failed to store check state for %s: %w", ipReport.Name, err)
+		logger.Errorln("failed to store check state:", err)
 		return fmt.Errorf("failed to store check state for %s: %w", ipReport.Name, err)
 	}
+	return nil
+}
 
-	// write ok back to caller
-	w.WriteHeader(http.StatusOK)
-	k.externalCheckReportHandlerLog(requestID, "Request completed successfully.")
+// recordRunArchitecture records the result of the last run of a check as the result of the node architecture
+// it ran on, if the check chose its architectures
+func recordRunArchitecture(c KuberhealthyCheck, details *health.CheckDetails) {
+	ac, ok := c.(architectureCheck)
+	if !ok || len(ac.RunArchitecture()) == 0 {
+		return
+	}
+	details.RecordArchitecture(ac.RunArchitecture(), ac.NodeArchitectures(), time.Now())
+}
+
+// checkerPod returns the details of the checker pod of the last run of a check, or the supplied details of an
+// earlier run if the last run did not start a pod
+func checkerPod(c KuberhealthyCheck, previous *health.PodDetails) *health.PodDetails {
+	pc, ok := c.(podCheck)
+	if !ok || pc.CheckerPod() == nil {
+		return previous
+	}
+	return pc.CheckerPod()
+}
+
+// runTraceContext returns the trace context of the current run of a check, if the check is traced
+func (k *Kuberhealthy) runTraceContext(name string, namespace string) tracing.SpanContext {
+	c, err := k.getCheck(name, namespace)
+	if err != nil {
+		return tracing.SpanContext{}
+	}
+	if tc, ok := c.(tracedCheck); ok {
+		return tc.TraceContext()
+	}
+	return tracing.SpanContext{}
+}
+
+// storeExternalProgress records an in-progress update from an external checker pod on the check's khstate
+// resource.  This is shared by the HTTP and gRPC reporting endpoints.
+func (k *Kuberhealthy) storeExternalProgress(logger *log.Entry, ipReport PodReportIPInfo, state status.Report) error {
+	progress := health.Progress{
+		Percent: state.Progress,
+		Message: state.Message,
+		Updated: time.Now(),
+	}
+
+	auditReport(audit.EventProgress, ipReport, state)
+	logger.Infoln("Setting progress of check to", progress.Percent, "percent:", progress.Message)
+	err := setCheckProgress(ipReport.Name, ipReport.Namespace, progress)
+	if err != nil {
+		logger.Errorln("failed to store check progress:", err)
+		return fmt.Errorf("failed to store check progress for %s: %w", ipReport.Name, err)
+	}
 	return nil
 }
 
@@ -1008,6 +2310,16 @@ func (k *Kuberhealthy) prometheusMetricsHandler(w http.ResponseWriter, r *http.R
 	log.Infoln("Client connected to prometheus metrics endpoint from", r.RemoteAddr, r.UserAgent())
 	state := k.getCurrentState([]string{})
 	m := metrics.GenerateMetrics(state)
+	m += metrics.GenerateScheduleMetrics(k.checkSchedules())
+	rateLimitStats := append(kubeAPIRateLimiter.Stats(), podRateLimiter.Stats()...)
+	if stateWriteCoalescer != nil {
+		rateLimitStats = append(rateLimitStats, stateWriteCoalescer.Limiter.Stats()...)
+	}
+	m += metrics.GenerateRateLimitMetrics(rateLimitStats)
+	m += metrics.GenerateUsageMetrics(k.usage.List())
+	if federator != nil {
+		m += metrics.GenerateFederationMetrics(k.federatedClusterStates(state))
+	}
 	// write summarized health check results back to caller
 	_, err := w.Write([]byte(m))
 	if err != nil {
@@ -1016,8 +2328,65 @@ func (k *Kuberhealthy) prometheusMetricsHandler(w http.ResponseWriter, r *http.R
 	return err
 }
 
-// healthCheckHandler returns the current status of checks loaded into Kuberhealthy
-// as JSON to the client. Respects namespace requests via URL query parameters (i.e. /?namespace=default)
+// federationHandler returns the combined status of this cluster and every federated cluster as JSON
+func (k *Kuberhealthy) federationHandler(w http.ResponseWriter, r *http.Request) error {
+	log.Infoln("Client connected to federation status page from", r.RemoteAddr, r.UserAgent())
+	status := federator.Status(k.getCurrentState([]string{}))
+
+	b, err := json.MarshalIndent(status, "", "  ")
+	if err != nil {
+		log.Warningln("Error marshaling federation status json for caller:", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return err
+	}
+	_, err = w.Write(b)
+	if err != nil {
+		log.Warningln("Error writing federation status to caller:", err)
+	}
+	return err
+}
+
+// maxFederationPushSize is the largest status body a federated cluster can push
+const maxFederationPushSize = 10 << 20
+
+// federationPushHandler accepts signed status pushed by federated clusters
+func (k *Kuberhealthy) federationPushHandler(w http.ResponseWriter, r *http.Request) error {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return fmt.Errorf("unexpected %s request to federation push endpoint from %s", r.Method, r.RemoteAddr)
+	}
+
+	cluster := r.Header.Get(federation.ClusterHeader)
+	body, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, maxFederationPushSize))
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return fmt.Errorf("error reading status pushed by cluster %s: %w", cluster, err)
+	}
+
+	err = federator.Receive(cluster, r.Header.Get(federation.TimestampHeader), r.Header.Get(federation.SignatureHeader), body)
+	if err != nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		return err
+	}
+	log.Debugln("Received status pushed by federated cluster", cluster, "from", r.RemoteAddr)
+	return nil
+}
+
+// federatedClusterStates returns the state of this cluster and every federated cluster for metrics
+func (k *Kuberhealthy) federatedClusterStates(local health.State) []metrics.ClusterState {
+	var states []metrics.ClusterState
+	for name, c := range federator.Status(local).Clusters {
+		states = append(states, metrics.ClusterState{
+			Cluster:   name,
+			Reachable: c.Reachable,
+			State:     c.State,
+		})
+	}
+	return states
+}
+
+// healthCheckHandler returns the current status of checks loaded into Kuberhealthy as JSON, plaintext, or
+// Nagios plugin output to the client. Respects namespace requests via URL query parameters (i.e. /?namespace=default)
 func (k *Kuberhealthy) healthCheckHandler(w http.ResponseWriter, r *http.Request) error {
 	log.Infoln("Client connected to status page from", r.RemoteAddr, r.UserAgent())
 
@@ -1029,31 +2398,41 @@ func (k *Kuberhealthy) healthCheckHandler(w http.ResponseWriter, r *http.Request
 		return err
 	}
 
-	// get URL query parameters if there are any
-	values := r.URL.Query()
-	namespaceValue := values.Get("namespace")
-	// .Get() will return an "" if there is no value associated -- we do not want to pass "" as a requested namespace
-	var namespaces []string
-	if len(namespaceValue) != 0 {
-		namespaceSplits := strings.Split(namespaceValue, ",")
-		// a query like (/?namespace=,) will cause .Split() to return an array of two empty strings ["", ""]
-		// so we need to filter those out
-		for _, namespaceSplit := range namespaceSplits {
-			if len(namespaceSplit) != 0 {
-				namespaces = append(namespaces, namespaceSplit)
-			}
-		}
+	// the status can be written as JSON, plaintext, or Nagios plugin output
+	format, err := health.NegotiateFormat(r)
+	if err != nil {
+		return writeAPIError(w, http.StatusBadRequest, err.Error())
 	}
 
-	// fetch the current status from our khstate resources
-	state := k.getCurrentState(namespaces)
+	// pollers asking for the same status within the cache TTL share a single rendered response
+	key := format + " " + r.URL.Path + "?" + r.URL.RawQuery
+	statusResponses.Get(key, func(w http.ResponseWriter) {
+		err := k.writeStatus(w, r, format)
+		if err != nil {
+			log.Warningln("Error writing health check results to caller:", err)
+		}
+	}).ServeHTTP(w, r)
+	return nil
+}
 
-	// write summarized health check results back to caller
-	err = state.WriteHTTPStatusResponse(w)
-	if err != nil {
-		log.Warningln("Error writing health check results to caller:", err)
+// writeStatus writes the status of the checks selected by a status page request in the supplied format
+func (k *Kuberhealthy) writeStatus(w http.ResponseWriter, r *http.Request, format string) error {
+	// checks can be selected with query parameters or by serving a pre-configured view
+	var view string
+	if strings.HasPrefix(r.URL.Path, statusViewPrefix) {
+		view = strings.Trim(strings.TrimPrefix(r.URL.Path, statusViewPrefix), "/")
 	}
-	return err
+	state, err := k.queryState(r.URL.Query(), view)
+	switch err.(type) {
+	case nil:
+	case errViewNotFound:
+		return writeAPIError(w, http.StatusNotFound, err.Error())
+	default:
+		return writeAPIError(w, http.StatusBadRequest, err.Error())
+	}
+
+	// write summarized health check results back to caller in the format they asked for
+	return state.WriteHTTPStatusResponseFormat(w, format)
 }
 
 // getCurrentState fetches the current state of all checks from requested namespaces
@@ -1092,22 +2471,17 @@ func (k *Kuberhealthy) getCurrentStatusForNamespaces(namespaces []string) health
 				continue
 			}
 
-			// parse check status from CRD and add it to the global status of errors. Skip blank errors
-			for _, e := range checkState.Errors {
-				if len(strings.TrimSpace(e)) == 0 {
-					log.Warningln("Skipped an error that was blank when adding check details to current state.")
-					continue
-				}
-				statesForNamespaces.AddError(e)
-				log.Debugln("Status page: Setting global OK state to false due to check details not being OK")
-				statesForNamespaces.OK = false
-			}
-
 			// update check details struct
 			statesForNamespaces.CheckDetails[checkName] = checkState
 		}
 	}
 
+	// roll the status of the requested namespaces up into their overall status.  Namespaces that were asked
+	// for explicitly are rolled up even if they are excluded from the status of the whole cluster.
+	policy := rollupPolicy
+	policy.ExcludeNamespaces = nil
+	policy.Apply(&statesForNamespaces)
+
 	log.Infoln("khState reflector returning current status on", len(statesForNamespaces.CheckDetails), "khStates")
 	return statesForNamespaces
 }
@@ -1138,22 +2512,20 @@ func (k *Kuberhealthy) configureChecks() {
 }
 
 // isUUIDWhitelistedForCheck determines if the supplied uuid is whitelisted for the
-// check with the supplied name.  Only one UUID can be whitelisted at a time.
-// Operations are not atomic.  Whitelisting prevents expired or invalidated pods from
-// reporting into the status endpoint when they shouldn't be.
+// check with the supplied name.  Only the current run's UUID is whitelisted, along with
+// the UUIDs of overlapping runs that have not reported in yet for checks whose runs can
+// overlap.  Operations are not atomic.  Whitelisting prevents expired or invalidated pods
+// from reporting into the status endpoint when they shouldn't be.
 func (k *Kuberhealthy) isUUIDWhitelistedForCheck(checkName string, checkNamespace string, uuid string) (bool, error) {
 
 	// get the item in question
-	checkState, err := khStateClient.Get(metav1.GetOptions{}, stateCRDResource, checkName, checkNamespace)
+	checkState, err := stateStore.Get(checkName, checkNamespace)
 	if err != nil {
 		return false, err
 	}
 
-	log.Debugln("Validating current UUID", checkState.Spec.CurrentUUID, "vs incoming UUID:", uuid)
-	if checkState.Spec.CurrentUUID == uuid {
-		return true, nil
-	}
-	return false, nil
+	log.Debugln("Validating current UUID", checkState.CurrentUUID, "and running UUIDs", checkState.RunningUUIDs, "vs incoming UUID:", uuid)
+	return checkState.AuthorizesRun(uuid), nil
 }
 
 // configureInfluxForwarding sets up initial influxdb metric sending
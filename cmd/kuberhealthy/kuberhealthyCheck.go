@@ -15,6 +15,11 @@ import (
 	"time"
 
 	"k8s.io/client-go/kubernetes"
+
+	"github.com/Comcast/kuberhealthy/v2/pkg/checks/external"
+	"github.com/Comcast/kuberhealthy/v2/pkg/health"
+	"github.com/Comcast/kuberhealthy/v2/pkg/tracing"
+	"github.com/Comcast/kuberhealthy/v2/pkg/usage"
 )
 
 // KuberhealthyCheck represents the required methods for a check to be ran by
@@ -39,10 +44,100 @@ type KuberhealthyCheck interface {
 	// ticker ticks.  Results of the error are stored within the check
 	// and not tracked from the upstream worker that ticks.  Results should
 	// show up when CurrentStatus() is invoked.
-	Run(c *kubernetes.Clientset) error
+	Run(c kubernetes.Interface) error
 	// Shutdown is called when Kuberhealthy needs to close.  The check has up
 	// to 30 seconds to clean up anything in progress and begin shutdown.
 	// When the check completes and returns, we assume it is done shutting
 	// down.
 	Shutdown() error
 }
+
+// runIDCheck is implemented by checks that can run with a run UUID chosen by the caller, which allows
+// them to be triggered out of band through the API
+type runIDCheck interface {
+	RunWithID(c kubernetes.Interface, runID string) error
+}
+
+// tracedCheck is implemented by checks that trace their runs, so that reports sent during a run can join
+// the run's trace
+type tracedCheck interface {
+	TraceContext() tracing.SpanContext
+}
+
+// thresholdCheck is implemented by checks that need several failed or successful runs in a row before
+// their reported health changes
+type thresholdCheck interface {
+	Thresholds() (failureThreshold int, successThreshold int)
+}
+
+// sloCheck is implemented by checks that have a service level objective, which is the percentage of their
+// runs that are expected to succeed
+type sloCheck interface {
+	AvailabilityTarget() float64
+}
+
+// severityCheck is implemented by checks that have a severity, which weights them when the status of each
+// check is rolled up into the overall status
+type severityCheck interface {
+	RollupSeverity() string
+}
+
+// resultTTLCheck is implemented by checks whose results expire, after which the health of the check is shown as
+// unknown until a new result arrives
+type resultTTLCheck interface {
+	ResultLifetime() time.Duration
+}
+
+// teardownCheck is implemented by checks that tear down each run after it times out, which keeps the run going
+// for up to the duration of the teardown
+type teardownCheck interface {
+	TeardownDuration() time.Duration
+}
+
+// warningCheck is implemented by checks that report problems with their configuration that do not keep them
+// from running
+type warningCheck interface {
+	Warnings() []string
+}
+
+// podCheck is implemented by checks that run in a checker pod and can describe where and how the pod of their
+// last run ran, and what the pod requests from the scheduler
+type podCheck interface {
+	CheckerPod() *health.PodDetails
+	PodRequests() usage.Requests
+}
+
+// labeledCheck is implemented by checks that have labels, which status page views select checks by
+type labeledCheck interface {
+	Labels() map[string]string
+}
+
+// architectureCheck is implemented by checks that take turns running on nodes of several CPU architectures,
+// whose results are recorded per architecture
+type architectureCheck interface {
+	RunArchitecture() string
+	NodeArchitectures() []string
+}
+
+// regressionCheck is implemented by checks that fail runs whose reported measurements cross a threshold or
+// regress from their baseline
+type regressionCheck interface {
+	CheckRegressions(details *health.CheckDetails)
+}
+
+// notificationCheck is implemented by checks that route the notifications sent when their health changes
+type notificationCheck interface {
+	Channels() []string
+}
+
+// phasedCheck is implemented by checks that report what their current run is doing, such as creating or
+// waiting on a checker pod
+type phasedCheck interface {
+	Phase() string
+}
+
+// overlapCheck is implemented by checks whose runs can overlap, which run each run on a checker of its own
+type overlapCheck interface {
+	OverlappingRuns() int
+	RunChecker() *external.Checker
+}
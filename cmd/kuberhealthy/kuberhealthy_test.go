@@ -0,0 +1,48 @@
+// Copyright 2018 Comcast Cable Communications Management, LLC
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//     http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"testing"
+
+	apiv1 "k8s.io/api/core/v1"
+
+	"github.com/Comcast/kuberhealthy/v2/pkg/khcheckcrd"
+)
+
+// TestCheckConfigChanged validates that checks are reloaded when their settings change, including when only
+// the number of runs that can overlap changed
+func TestCheckConfigChanged(t *testing.T) {
+	config := func() khcheckcrd.CheckConfig {
+		return khcheckcrd.CheckConfig{
+			RunInterval: "10m",
+			Timeout:     "5m",
+			PodSpec:     apiv1.PodSpec{Containers: []apiv1.Container{{Name: "main", Image: "check:1"}}},
+		}
+	}
+	known := config()
+	if checkConfigChanged("kuberhealthy/test-check", known, config()) {
+		t.Fatal("Expected unchanged settings to not reload the check")
+	}
+
+	overlap := config()
+	overlap.MaxOverlappingRuns = 2
+	if !checkConfigChanged("kuberhealthy/test-check", known, overlap) {
+		t.Fatal("Expected a change of the maximum overlapping runs to reload the check")
+	}
+
+	image := config()
+	image.PodSpec.Containers[0].Image = "check:2"
+	if !checkConfigChanged("kuberhealthy/test-check", known, image) {
+		t.Fatal("Expected a change of the pod spec to reload the check")
+	}
+}
@@ -0,0 +1,157 @@
+// Copyright 2018 Comcast Cable Communications Management, LLC
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//     http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// logLevelPath serves and changes the log level of this Kuberhealthy pod
+const logLevelPath = "/debug/loglevel"
+
+// maxLogLevelRequestSize is the largest log level request body that is read
+const maxLogLevelRequestSize = 1024
+
+// logLevelRequest changes the log level, optionally only for a while
+type logLevelRequest struct {
+	Level    string // such as debug
+	Duration string `json:",omitempty"` // how long until the previous level is restored, such as 30m.  Blank keeps the level.
+}
+
+// logLevelResponse is the current log level
+type logLevelResponse struct {
+	Level     string
+	RevertsTo string     `json:",omitempty"` // the level that is restored once the change expires
+	RevertsAt *time.Time `json:",omitempty"` // when the change expires
+}
+
+// logLevelRevert restores the previous log level once a temporary change expires
+var logLevelRevert = &logLevelReverter{}
+
+// logLevelReverter holds the timer of a temporary log level change
+type logLevelReverter struct {
+	mu        sync.Mutex
+	timer     *time.Timer
+	level     log.Level // the level the change set
+	revertsTo log.Level
+	revertsAt time.Time
+}
+
+// set changes the log level.  When duration is not zero, the previous level is restored after it, unless the
+// level has been changed by something else in the meantime.  Any pending restore is canceled.
+func (l *logLevelReverter) set(level log.Level, duration time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	previous := log.GetLevel()
+	if l.timer != nil {
+		// a change made while another is pending restores the level from before the pending change
+		l.timer.Stop()
+		l.timer = nil
+		if previous == l.level {
+			previous = l.revertsTo
+		}
+	}
+	log.SetLevel(level)
+	if duration <= 0 {
+		return
+	}
+
+	l.level = level
+	l.revertsTo = previous
+	l.revertsAt = time.Now().Add(duration)
+	var timer *time.Timer
+	timer = time.AfterFunc(duration, func() {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		if l.timer != timer {
+			return
+		}
+		l.timer = nil
+		if log.GetLevel() != l.level {
+			return
+		}
+		log.Infoln("Log level change expired. Restoring log level", l.revertsTo)
+		log.SetLevel(l.revertsTo)
+	})
+	l.timer = timer
+}
+
+// status returns the current log level and any pending restore
+func (l *logLevelReverter) status() logLevelResponse {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	response := logLevelResponse{
+		Level: log.GetLevel().String(),
+	}
+	if l.timer != nil && log.GetLevel() == l.level {
+		revertsAt := l.revertsAt
+		response.RevertsTo = l.revertsTo.String()
+		response.RevertsAt = &revertsAt
+	}
+	return response
+}
+
+// logLevelHandler serves the log level of this Kuberhealthy pod on GET and changes it on PUT.  Both reading and
+// changing the log level require the API token, and the endpoint is disabled when no API token is configured.
+func logLevelHandler(w http.ResponseWriter, r *http.Request) error {
+	log.Infoln("Client connected to log level endpoint", r.URL.Path, "from", r.RemoteAddr, r.UserAgent())
+
+	if len(apiToken) == 0 {
+		return writeAPIError(w, http.StatusNotFound, "the log level endpoint is disabled because no API token is configured")
+	}
+	if !authorizeAPIRequest(r) {
+		return writeAPIError(w, http.StatusUnauthorized, "a valid bearer token is required")
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		return writeAPIResponse(w, http.StatusOK, logLevelRevert.status())
+	case http.MethodPut:
+	default:
+		return writeAPIError(w, http.StatusMethodNotAllowed, "only GET and PUT are supported")
+	}
+
+	b, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, maxLogLevelRequestSize))
+	if err != nil {
+		return writeAPIError(w, http.StatusBadRequest, "failed to read request body: "+err.Error())
+	}
+	var request logLevelRequest
+	err = json.Unmarshal(b, &request)
+	if err != nil {
+		return writeAPIError(w, http.StatusBadRequest, "failed to parse request body: "+err.Error())
+	}
+	level, err := log.ParseLevel(request.Level)
+	if err != nil {
+		return writeAPIError(w, http.StatusBadRequest, "Level must be one of "+getAllLogLevel())
+	}
+	var duration time.Duration
+	if len(request.Duration) > 0 {
+		duration, err = time.ParseDuration(request.Duration)
+		if err != nil || duration <= 0 {
+			return writeAPIError(w, http.StatusBadRequest, "Duration must be a positive duration such as 30m")
+		}
+	}
+
+	if duration > 0 {
+		log.Warningln("Log level changed to", level, "for", duration, "by", r.RemoteAddr)
+	} else {
+		log.Warningln("Log level changed to", level, "by", r.RemoteAddr)
+	}
+	logLevelRevert.set(level, duration)
+	return writeAPIResponse(w, http.StatusOK, logLevelRevert.status())
+}
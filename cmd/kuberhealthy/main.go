@@ -15,6 +15,8 @@ package main
 import (
 	"context"
 	"fmt"
+	"net"
+	"net/http"
 	"os"
 	"os/signal"
 	"path/filepath"
@@ -24,18 +26,45 @@ import (
 
 	"github.com/integrii/flaggy"
 	log "github.com/sirupsen/logrus"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/klog"
 
+	"github.com/Comcast/kuberhealthy/v2/pkg/alertmanager"
+	"github.com/Comcast/kuberhealthy/v2/pkg/archive"
+	"github.com/Comcast/kuberhealthy/v2/pkg/audit"
+	"github.com/Comcast/kuberhealthy/v2/pkg/checks/external"
+	"github.com/Comcast/kuberhealthy/v2/pkg/federation"
+	"github.com/Comcast/kuberhealthy/v2/pkg/health"
+	"github.com/Comcast/kuberhealthy/v2/pkg/imagepolicy"
 	"github.com/Comcast/kuberhealthy/v2/pkg/khcheckcrd"
+	"github.com/Comcast/kuberhealthy/v2/pkg/khchecktemplatecrd"
+	"github.com/Comcast/kuberhealthy/v2/pkg/khconfig"
+	"github.com/Comcast/kuberhealthy/v2/pkg/khsilencecrd"
 	"github.com/Comcast/kuberhealthy/v2/pkg/khstatecrd"
+	"github.com/Comcast/kuberhealthy/v2/pkg/khtls"
 	"github.com/Comcast/kuberhealthy/v2/pkg/kubeClient"
 	"github.com/Comcast/kuberhealthy/v2/pkg/masterCalculation"
+	"github.com/Comcast/kuberhealthy/v2/pkg/namespacefilter"
+	"github.com/Comcast/kuberhealthy/v2/pkg/notify"
+	"github.com/Comcast/kuberhealthy/v2/pkg/podsecurity"
+	"github.com/Comcast/kuberhealthy/v2/pkg/pressure"
+	"github.com/Comcast/kuberhealthy/v2/pkg/proxy"
+	"github.com/Comcast/kuberhealthy/v2/pkg/ratelimit"
+	"github.com/Comcast/kuberhealthy/v2/pkg/registry"
+	"github.com/Comcast/kuberhealthy/v2/pkg/responsecache"
+	"github.com/Comcast/kuberhealthy/v2/pkg/rolepolicy"
+	"github.com/Comcast/kuberhealthy/v2/pkg/scheduler"
+	"github.com/Comcast/kuberhealthy/v2/pkg/statestore"
+	"github.com/Comcast/kuberhealthy/v2/pkg/tracing"
 )
 
 // status represents the current Kuberhealthy OK:Error state
 var kubeConfigFile = filepath.Join(os.Getenv("HOME"), ".kube", "config")
 var listenAddress = ":8080"
+var grpcListenAddress = "" // the gRPC report service is disabled when this is blank
 var podCheckNamespaces = "kube-system"
 var podNamespace = os.Getenv("POD_NAMESPACE")
 var isMaster bool                  // indicates this instance is the master and should be running checks
@@ -53,6 +82,11 @@ var DSPauseContainerImageOverride string // specify an alternate location for th
 var DSTolerationOverride []string
 var logLevel = "info"
 
+// KHLogFormat sets the format of log lines.  Use json to ship structured logs to a log aggregator.
+const KHLogFormat = "KH_LOG_FORMAT"
+
+var logFormat = getLogFormat()
+
 // the hostname of this pod
 var podHostname string
 
@@ -65,11 +99,356 @@ const KHExternalReportingURL = "KH_EXTERNAL_REPORTING_URL"
 // default run interval set by kuberhealthy
 const DefaultRunInterval = time.Minute * 10
 
+// the run interval of khchecks with an unparsable run interval and the timeout of khchecks without a timeout.
+// Both can be changed in the config file.
+var defaultRunInterval = DefaultRunInterval
+var defaultRunTimeout = khcheckcrd.DefaultTimeout
+
 // the key used in the annotation that holds the check's short name
 const KH_CHECK_NAME_ANNOTATION_KEY = "comcast.github.io/check-name"
 
 var externalCheckReportingURL = os.Getenv(KHExternalReportingURL)
 
+// the address of the gRPC report service that is handed to checker pods, if any
+const KHExternalGRPCReportingAddress = "KH_EXTERNAL_GRPC_REPORTING_ADDRESS"
+
+var externalCheckGRPCReportingAddress = os.Getenv(KHExternalGRPCReportingAddress)
+
+// the proxy that checker pods report through, or direct to report without one, and the comma separated hosts
+// they reach without it.  Checker pods use their own proxy environment variables when blank.
+const KHReportingProxy = "KH_REPORTING_PROXY"
+const KHReportingNoProxy = "KH_REPORTING_NO_PROXY"
+
+var reportingProxy = os.Getenv(KHReportingProxy)
+var reportingNoProxy = os.Getenv(KHReportingNoProxy)
+
+// the security policy enforced on checker pods
+const KHCheckSecurityPolicy = "KH_CHECK_SECURITY_POLICY"
+const KHCheckSeccompProfile = "KH_CHECK_SECCOMP_PROFILE"
+
+var checkSecurityPolicyString = podsecurity.DefaultPolicyString
+var checkSeccompProfile = podsecurity.DefaultSeccompProfile
+var checkSecurityPolicy podsecurity.Policy
+
+// the registries and repositories checker pods can run images from, as comma separated globs and regular
+// expressions.  Every image is allowed when blank.
+const KHCheckImageAllowlist = "KH_CHECK_IMAGE_ALLOWLIST"
+
+var checkImageAllowlist = os.Getenv(KHCheckImageAllowlist)
+var checkImagePolicy imagepolicy.Policy
+
+// the RBAC rules khchecks can request for their dedicated service accounts, as comma separated verb:resource.group
+// entries.  No rules are allowed when blank.
+const KHCheckRoleAllowlist = "KH_CHECK_ROLE_ALLOWLIST"
+
+var checkRoleAllowlist = os.Getenv(KHCheckRoleAllowlist)
+var checkRolePolicy rolepolicy.Policy
+
+// resolves the image tags of checker pods to digests when checks are loaded, so that every run of a check uses
+// the same image until the check is reloaded
+const KHPinCheckImageDigests = "KH_PIN_CHECK_IMAGE_DIGESTS"
+
+var pinCheckImageDigests bool
+var imageResolver = registry.NewResolver(&http.Client{Timeout: time.Second * 10})
+
+// labels and annotations applied to every checker pod, such as cost allocation tags or service mesh sidecar
+// injection settings.  Each is a comma separated list of key=value pairs.
+const KHCheckPodLabels = "KH_CHECK_POD_LABELS"
+const KHCheckPodAnnotations = "KH_CHECK_POD_ANNOTATIONS"
+
+var checkPodLabelsString = os.Getenv(KHCheckPodLabels)
+var checkPodAnnotationsString = os.Getenv(KHCheckPodAnnotations)
+var checkPodLabels map[string]string
+var checkPodAnnotations map[string]string
+
+// the node pool checker pods are scheduled onto when they do not choose their own nodes.  The node selector is
+// a comma separated list of key=value pairs and the tolerations a comma separated list of key=value:Effect.
+const KHCheckNodeSelector = "KH_CHECK_NODE_SELECTOR"
+const KHCheckTolerations = "KH_CHECK_TOLERATIONS"
+
+var checkNodeSelectorString = os.Getenv(KHCheckNodeSelector)
+var checkTolerationsString = os.Getenv(KHCheckTolerations)
+var checkNodeSelector map[string]string
+var checkTolerations []v1.Toleration
+
+// the priority class of checker pods that do not set their own, such as a low priority class that never preempts
+// production workloads
+const KHCheckPriorityClass = "KH_CHECK_PRIORITY_CLASS"
+
+var checkPriorityClass = os.Getenv(KHCheckPriorityClass)
+
+// how long checker pods are given to exit when they are deleted, and how long they can stay terminating past
+// that before they are force deleted.  Force deletion is disabled when the cutoff is zero.
+const KHPodDeleteGracePeriod = "KH_POD_DELETE_GRACE_PERIOD"
+const KHPodForceDeleteAfter = "KH_POD_FORCE_DELETE_AFTER"
+
+var podDeleteGracePeriod = time.Second
+var podForceDeleteAfter = time.Minute
+
+// how many requests a second Kuberhealthy makes to the Kubernetes API, with bursts of up to the burst setting
+const KHKubeAPIQPS = "KH_KUBE_API_QPS"
+const KHKubeAPIBurst = "KH_KUBE_API_BURST"
+
+var kubeAPIQPS = float64(5)
+var kubeAPIBurst = 10
+var kubeAPIRateLimiter *ratelimit.Limiter
+
+// how many checker pods a second are created, deleted, and watched across all checks, with bursts of up to the
+// burst setting.  Pod operations are not limited beyond the Kubernetes API limits when the QPS is zero.
+const KHPodOperationQPS = "KH_POD_OPERATION_QPS"
+const KHPodOperationBurst = "KH_POD_OPERATION_BURST"
+
+var podOperationQPS float64
+var podOperationBurst = 10
+var podRateLimiter *ratelimit.Limiter
+
+// how often writes of check state are flushed to the state store, and how many writes a second are flushed with
+// bursts of up to the burst setting.  Writes go straight to the state store when the interval is zero.
+const KHStateWriteInterval = "KH_STATE_WRITE_INTERVAL"
+const KHStateWriteQPS = "KH_STATE_WRITE_QPS"
+const KHStateWriteBurst = "KH_STATE_WRITE_BURST"
+
+var stateWriteInterval time.Duration
+var stateWriteQPS = float64(10)
+var stateWriteBurst = 20
+var stateWriteCoalescer *statestore.Coalescer
+
+// the namespaces that khchecks are discovered in.  The allow and deny lists are comma separated namespace names
+// that can contain * wildcards, and the selector is a label selector that namespaces must match.  Every
+// namespace is watched when none are set.
+const KHCheckNamespaces = "KH_CHECK_NAMESPACES"
+const KHIgnoreCheckNamespaces = "KH_IGNORE_CHECK_NAMESPACES"
+const KHCheckNamespaceSelector = "KH_CHECK_NAMESPACE_SELECTOR"
+
+var checkNamespaces = os.Getenv(KHCheckNamespaces)
+var ignoreCheckNamespaces = os.Getenv(KHIgnoreCheckNamespaces)
+var checkNamespaceSelector = os.Getenv(KHCheckNamespaceSelector)
+var checkNamespaceFilter namespacefilter.Filter
+var checkInformer *CheckInformer
+
+// run with only namespace scoped permissions in the namespaces khchecks are discovered in, which default to the
+// namespace of Kuberhealthy.  Features that need cluster wide permissions are turned off.
+const KHNamespaced = "KH_NAMESPACED"
+
+var namespacedMode bool
+
+// the labels of the Kuberhealthy pods that checker pods with a network policy are allowed to report to.  This is
+// a comma separated list of key=value pairs.
+const KHReportingPodLabels = "KH_REPORTING_POD_LABELS"
+
+var reportingPodLabelsString = os.Getenv(KHReportingPodLabels)
+var reportingPodLabels map[string]string
+
+// the percentage of runs of each check that are expected to succeed.  Error budgets on the status page and in
+// metrics are measured against it.  Checks can override it with sloTarget in their spec.
+const KHSLOTarget = "KH_SLO_TARGET"
+
+var sloTarget = 99.0
+
+// how the status of each check is rolled up into the overall status.  Each failing check adds the weight of
+// its severity, and the cluster is unhealthy once the total reaches the failure threshold.  Severity weights
+// are a comma separated list of severity=weight pairs, and checks without a weighted severity weigh 1.
+// Checks in excluded namespaces never affect the overall status.
+const KHRollupFailureThreshold = "KH_ROLLUP_FAILURE_THRESHOLD"
+const KHRollupSeverityWeights = "KH_ROLLUP_SEVERITY_WEIGHTS"
+const KHRollupExcludeNamespaces = "KH_ROLLUP_EXCLUDE_NAMESPACES"
+
+var rollupFailureThreshold = health.DefaultRollupPolicy().FailureThreshold
+var rollupSeverityWeightsString = os.Getenv(KHRollupSeverityWeights)
+var rollupExcludeNamespacesString = os.Getenv(KHRollupExcludeNamespaces)
+var rollupPolicy = health.DefaultRollupPolicy()
+
+// status page views that select checks by their labels, served at /status/<name>.  A semicolon separated
+// list of name=selector pairs, such as team-payments=team=payments;platform=team in (platform,infra).
+const KHStatusViews = "KH_STATUS_VIEWS"
+
+var statusViewsString = os.Getenv(KHStatusViews)
+var statusViews map[string]labels.Selector
+
+// how long a rendered status page is served to pollers before it is rendered again.  Zero disables caching.
+const KHStatusCacheTTL = "KH_STATUS_CACHE_TTL"
+
+var statusCacheTTL = time.Second * 2
+var statusResponses = responsecache.New(statusCacheTTL)
+
+// record the changes made to the user-provided pod specs of checks and annotate checker pods with them
+const KHRecordPodSpecMutations = "KH_RECORD_POD_SPEC_MUTATIONS"
+
+var recordPodSpecMutations bool
+
+// add a finalizer to khchecks so that everything created for a check is deleted before the khcheck is removed
+const KHCheckFinalizers = "KH_CHECK_FINALIZERS"
+
+var enableCheckFinalizers = true
+
+// TLS configuration for the report-in listeners.  TLS is enabled when a certificate and key are supplied
+// and mutual TLS is enabled when a client CA bundle is also supplied.
+var tlsCertFile = ""
+var tlsKeyFile = ""
+var tlsClientCAFile = ""
+var checkClientCertSecret = "" // the secret in each check namespace holding client certificates for checker pods
+var tlsReloader *khtls.Reloader
+
+// the audience of the service account tokens projected into checker pods.  When set, reports are only accepted
+// with a token that the API server confirms was issued to the pod that sent them.
+const KHCheckTokenAudience = "KH_CHECK_TOKEN_AUDIENCE"
+
+var checkTokenAudience = os.Getenv(KHCheckTokenAudience)
+
+// serve the /dryRun endpoint that renders checker pods without creating them
+const KHEnableDryRun = "KH_ENABLE_DRY_RUN"
+
+var enableDryRun bool
+
+// serve the pprof, expvar, and goroutine dump endpoints used to diagnose a running Kuberhealthy
+const KHEnableProfiling = "KH_ENABLE_PROFILING"
+
+var enableProfiling bool
+
+// the bearer token required by the /api/v1/ endpoints.  The API is disabled when this is blank.
+const KHAPIToken = "KH_API_TOKEN"
+
+var apiToken = os.Getenv(KHAPIToken)
+
+// settings that stagger the first run of each check so that checks started together do not run in lockstep
+const KHSpreadCheckStarts = "KH_SPREAD_CHECK_STARTS"
+const KHCheckStartJitter = "KH_CHECK_START_JITTER"
+
+var spreadCheckStarts bool
+var checkStartJitter time.Duration
+
+// split checks across every running Kuberhealthy pod with consistent hashing instead of running them all on
+// the master
+const KHShardChecks = "KH_SHARD_CHECKS"
+
+var shardChecks bool
+
+// skip scheduled check runs that pass while the previous run is still in progress
+const KHSkipOverlappingRuns = "KH_SKIP_OVERLAPPING_RUNS"
+
+var skipOverlappingRuns bool
+
+// shorten check timeouts that are longer than their run interval, and timeout budgets that are longer than the
+// timeout, instead of only warning about them
+const KHClampCheckTimeouts = "KH_CLAMP_CHECK_TIMEOUTS"
+
+var clampCheckTimeouts bool
+
+// skip runs of checks of the listed severities while the cluster is under resource pressure, so that low
+// priority checks do not add to it.  The cluster is under pressure when more pods than the maximum are pending
+// or any node reports memory, disk, or PID pressure.  Skipped runs are retried after the defer delay, or at
+// their next scheduled run when the delay is zero.
+const KHPressureSkipSeverities = "KH_PRESSURE_SKIP_SEVERITIES"
+const KHPressureMaxPendingPods = "KH_PRESSURE_MAX_PENDING_PODS"
+const KHPressureDeferFor = "KH_PRESSURE_DEFER_FOR"
+
+var pressureSkipSeveritiesString = os.Getenv(KHPressureSkipSeverities)
+var pressureSkipSeverities []string
+var pressureMaxPendingPods = 50
+var pressureDeferFor time.Duration
+var pressureGuard *pressure.Guard
+
+// back off the interval of checks that fail repeatedly
+const KHFailureBackoffThreshold = "KH_FAILURE_BACKOFF_THRESHOLD"
+const KHFailureBackoffMaxInterval = "KH_FAILURE_BACKOFF_MAX_INTERVAL"
+
+var failureBackoff = scheduler.Backoff{MaxInterval: time.Hour}
+
+// checks that have not completed a run within their interval, timeout, and this grace period are shown as failed
+const KHStaleCheckGrace = "KH_STALE_CHECK_GRACE"
+
+var staleCheckGrace = time.Minute * 5
+
+// federation polls the status pages of Kuberhealthy in other clusters and serves a combined status
+const KHFederationConfig = "KH_FEDERATION_CONFIG"
+const KHFederationClusterName = "KH_FEDERATION_CLUSTER_NAME"
+const KHFederationPollInterval = "KH_FEDERATION_POLL_INTERVAL"
+const KHFederationStaleAfter = "KH_FEDERATION_STALE_AFTER"
+
+var federationConfigFile = os.Getenv(KHFederationConfig) // federation is disabled when this is blank
+var federationClusterName = "local"
+var federationPollInterval = time.Second * 30
+var federationStaleAfter = time.Minute * 5
+var federator *federation.Federator   // polls and receives the status of other clusters, if configured
+var upstreamPusher *federation.Pusher // pushes the status of this cluster to a central collector, if configured
+
+// OpenTelemetry tracing of check runs.  The standard OpenTelemetry environment variables are honored and
+// tracing is disabled when the OTLP endpoint is blank.
+const OTLPEndpointEnv = "OTEL_EXPORTER_OTLP_ENDPOINT"
+const OTLPServiceNameEnv = "OTEL_SERVICE_NAME"
+
+var otlpEndpoint = os.Getenv(OTLPEndpointEnv)
+var tracingServiceName = "kuberhealthy"
+var tracingExportInterval = time.Second * 5
+
+// audit logging of report-ins and check state changes.  Audit logging is disabled when the file is blank.
+const KHAuditLogFile = "KH_AUDIT_LOG_FILE"
+const KHAuditLogMaxSize = "KH_AUDIT_LOG_MAX_SIZE"
+const KHAuditLogMaxBackups = "KH_AUDIT_LOG_MAX_BACKUPS"
+
+var auditLogFile = os.Getenv(KHAuditLogFile)
+var auditLogMaxSize = 100 // megabytes
+var auditLogMaxBackups = 5
+var auditLog *audit.Log
+
+// archival of check results and the logs of failed checker pods to an S3 or GCS bucket.  The archive URL is in
+// the form s3://bucket/prefix or gs://bucket/prefix.  Archival is disabled when the URL is blank.
+const KHArchiveURL = "KH_ARCHIVE_URL"
+const KHArchiveEndpoint = "KH_ARCHIVE_ENDPOINT"
+const KHArchiveRegion = "KH_ARCHIVE_REGION"
+const KHArchiveRetention = "KH_ARCHIVE_RETENTION"
+
+var archiveURL = os.Getenv(KHArchiveURL)
+var archiveEndpoint = os.Getenv(KHArchiveEndpoint)
+var archiveRegion = os.Getenv(KHArchiveRegion)
+var archiveRetention = time.Hour * 24 * 30
+var archivePruneInterval = time.Hour
+var archiver *archive.Archiver
+
+// alerts for failing checks sent directly to a Prometheus Alertmanager.  Alerting is disabled when the URL is
+// blank.  The labels are a comma separated list of key=value pairs added to every alert.
+const KHAlertmanagerURL = "KH_ALERTMANAGER_URL"
+const KHAlertmanagerSeverity = "KH_ALERTMANAGER_SEVERITY"
+const KHAlertmanagerInterval = "KH_ALERTMANAGER_INTERVAL"
+const KHAlertmanagerLabels = "KH_ALERTMANAGER_LABELS"
+
+var alertmanagerURL = os.Getenv(KHAlertmanagerURL)
+var alertmanagerSeverity = alertmanager.DefaultSeverity
+var alertmanagerInterval = alertmanager.DefaultInterval
+var alertmanagerLabelsString = os.Getenv(KHAlertmanagerLabels)
+
+// the proxy alerts are sent to Alertmanager through, or direct to send them without one, and the comma
+// separated hosts reached without it.  Alerts are sent through the proxy environment variables when blank.
+const KHAlertmanagerProxy = "KH_ALERTMANAGER_PROXY"
+const KHAlertmanagerNoProxy = "KH_ALERTMANAGER_NO_PROXY"
+
+var alertmanagerProxy = os.Getenv(KHAlertmanagerProxy)
+var alertmanagerNoProxy = os.Getenv(KHAlertmanagerNoProxy)
+var alertNotifier *alertmanager.Notifier
+
+// the path of a YAML file listing the Microsoft Teams and generic chat webhooks that are notified when the
+// health of a check changes.  Notifications are disabled when blank.
+const KHNotificationConfig = "KH_NOTIFICATION_CONFIG"
+
+var notificationConfigPath = os.Getenv(KHNotificationConfig)
+var notificationConfig *notify.Config
+var notificationDispatcher *notify.Dispatcher
+
+// the least time between two notifications about the same check, and how often checks that remain failing
+// are notified again.  A renotify interval of zero disables renotifying.
+const KHNotificationThrottle = "KH_NOTIFICATION_THROTTLE"
+const KHNotificationRenotify = "KH_NOTIFICATION_RENOTIFY"
+
+var notificationThrottle = notify.DefaultThrottle
+var notificationRenotify = notify.DefaultRenotify
+
+// the path of a YAML file of operator-wide settings, usually a mounted ConfigMap.  Settings in the file take
+// precedence over their flags and environment variables, and changes to the file are picked up without a restart.
+const KHConfigFile = "KH_CONFIG_FILE"
+
+var configFile = os.Getenv(KHConfigFile)
+var configWatcher *khconfig.Watcher
+
 // InfluxDB connection configuration
 var enableInflux = false
 var influxURL = ""
@@ -80,6 +459,10 @@ var kuberhealthy *Kuberhealthy
 
 var khStateClient *khstatecrd.KuberhealthyStateClient
 
+// the store that check state is kept in.  One of crd, configmap, or memory.
+var stateStoreType = "crd"
+var stateStore statestore.StateStore
+
 // constants for using the kuberhealthy status CRD
 const stateCRDGroup = "comcast.github.io"
 const stateCRDVersion = "v1"
@@ -92,18 +475,118 @@ const checkCRDGroup = "comcast.github.io"
 const checkCRDVersion = "v1"
 const checkCRDResource = "khchecks"
 
+var khSilenceClient *khsilencecrd.KuberhealthySilenceClient
+
+// constants for using the kuberhealthy silence CRD
+const silenceCRDGroup = "comcast.github.io"
+const silenceCRDVersion = "v1"
+const silenceCRDResource = "khsilences"
+
+var khCheckTemplateClient *khchecktemplatecrd.KuberhealthyCheckTemplateClient
+
+// constants for using the kuberhealthy check template CRD
+const checkTemplateCRDGroup = "comcast.github.io"
+const checkTemplateCRDVersion = "v1"
+const checkTemplateCRDResource = "khchecktemplates"
+
+// how often silences are listed from the khsilence resources
+var silenceRefreshInterval = time.Second * 30
+
 // the global kubernetes client
-var kubernetesClient *kubernetes.Clientset
+var kubernetesClient kubernetes.Interface
+
+// the deployment that runs Kuberhealthy, which owns checker pods in its namespace, if it was found
+var kuberhealthyOwner *metav1.OwnerReference
 
-func init() {
+// configure parses the flags and environment variables and sets up the clients Kuberhealthy runs with.  It is
+// called by main rather than from init so that tests can run the handlers against fake clients.
+func configure() {
 
 	// setup flaggy
 	flaggy.SetDescription("Kuberhealthy is an in-cluster synthetic health checker for Kubernetes.")
 	flaggy.String(&kubeConfigFile, "", "kubecfg", "(optional) absolute path to the kubeconfig file")
 	flaggy.String(&listenAddress, "l", "listenAddress", "The port for kuberhealthy to listen on for web requests")
+	flaggy.String(&grpcListenAddress, "", "grpcListenAddress", "The address for the gRPC check report service to listen on.  Disabled when blank.")
+	flaggy.String(&checkSecurityPolicyString, "", "checkSecurityPolicy", "Comma separated security settings enforced on checker pods: runAsNonRoot, dropCapabilities, readOnlyRootFilesystem, seccomp.  Set to 'none' to disable.")
+	flaggy.String(&checkSeccompProfile, "", "checkSeccompProfile", "The seccomp profile applied to checker pods when the seccomp security setting is enforced.")
+	flaggy.String(&checkImageAllowlist, "", "checkImageAllowlist", "Comma separated globs, or regular expressions prefixed with regex:, of the images checker pods can run, such as quay.io/comcast/*.  Every image is allowed when blank.")
+	flaggy.String(&checkRoleAllowlist, "", "checkRoleAllowlist", "Comma separated verb:resource.group entries of the RBAC rules khchecks can request for their service accounts, such as get:pods,list:deployments.apps.  No rules are allowed when blank.")
+	flaggy.Bool(&pinCheckImageDigests, "", "pinCheckImageDigests", "Resolve the image tags of checker pods to digests when checks are loaded and run pods by digest.")
+	flaggy.String(&tlsCertFile, "", "tlsCertFile", "Path to the TLS certificate served by the web and gRPC listeners.  TLS is disabled when blank.")
+	flaggy.String(&tlsKeyFile, "", "tlsKeyFile", "Path to the TLS key served by the web and gRPC listeners.")
+	flaggy.String(&tlsClientCAFile, "", "tlsClientCAFile", "Path to a CA bundle used to verify client certificates from checker pods.  Enables mutual TLS.")
+	flaggy.String(&checkClientCertSecret, "", "checkClientCertSecret", "Name of a secret in each check's namespace holding a client certificate to mount into checker pods.")
+	flaggy.String(&checkTokenAudience, "", "checkTokenAudience", "The audience of the service account tokens projected into checker pods.  Reports must carry a valid token for the reporting pod when set.")
+	flaggy.String(&stateStoreType, "", "stateStore", "Where check state is stored.  One of crd, configmap, or memory.")
+	flaggy.String(&apiToken, "", "apiToken", "The bearer token required to use the /api/v1/ endpoints.  The API is disabled when blank.")
+	flaggy.Bool(&enableDryRun, "", "enableDryRun", "Set to true to serve the /dryRun endpoint, which renders the checker pod for a khcheck without creating it.")
+	flaggy.Bool(&enableProfiling, "", "enableProfiling", "Set to true to serve the /debug/pprof/, /debug/vars, and /debug/goroutines endpoints.  They require the API token, which must be set.")
+	flaggy.Bool(&spreadCheckStarts, "", "spreadCheckStarts", "Set to true to spread the first run of each check across its run interval.")
+	flaggy.Duration(&checkStartJitter, "", "checkStartJitter", "The maximum random delay added before the first run of each check.")
+	flaggy.Bool(&skipOverlappingRuns, "", "skipOverlappingRuns", "Set to true to skip scheduled check runs that pass while the previous run is still in progress.")
+	flaggy.Bool(&clampCheckTimeouts, "", "clampCheckTimeouts", "Set to true to shorten check timeouts that are longer than their run interval, and timeout budgets that are longer than the timeout.")
+	flaggy.String(&pressureSkipSeveritiesString, "", "pressureSkipSeverities", "Comma separated severities of checks whose runs are skipped while the cluster is under resource pressure.")
+	flaggy.Int(&pressureMaxPendingPods, "", "pressureMaxPendingPods", "The number of pending pods above which the cluster is under resource pressure.  Zero ignores pending pods.")
+	flaggy.Duration(&pressureDeferFor, "", "pressureDeferFor", "How long a run skipped under resource pressure is deferred before it is retried.  Zero skips the run until the next interval.")
+	flaggy.Int(&failureBackoff.Threshold, "", "failureBackoffThreshold", "The number of consecutive failures before a check's interval is doubled with each further failure.  Zero disables backoff.")
+	flaggy.Duration(&failureBackoff.MaxInterval, "", "failureBackoffMaxInterval", "The longest interval a failing check is backed off to.")
+	flaggy.Duration(&staleCheckGrace, "", "staleCheckGrace", "How long past its interval and timeout a check can go without completing a run before it is shown as failed.")
+	flaggy.String(&federationConfigFile, "", "federationConfig", "Path to a file listing the Kuberhealthy instances in other clusters to federate.  Federation is disabled when blank.")
+	flaggy.String(&federationClusterName, "", "federationClusterName", "The name of this cluster on the federation status page and metrics.")
+	flaggy.Duration(&federationPollInterval, "", "federationPollInterval", "How often the status pages of federated clusters are polled.")
+	flaggy.Duration(&federationStaleAfter, "", "federationStaleAfter", "How long a federated cluster can go without new status before it is shown as unreachable.")
+	flaggy.String(&otlpEndpoint, "", "otlpEndpoint", "The base URL of an OTLP/HTTP receiver that traces of check runs are exported to, such as http://otel-collector:4318.  Tracing is disabled when blank.")
+	flaggy.String(&auditLogFile, "", "auditLogFile", "Path to a file that report-ins and check state changes are recorded to.  Audit logging is disabled when blank.")
+	flaggy.Int(&auditLogMaxSize, "", "auditLogMaxSize", "The size in megabytes at which the audit log is rotated.")
+	flaggy.Int(&auditLogMaxBackups, "", "auditLogMaxBackups", "The number of rotated audit logs that are kept.")
+	flaggy.String(&archiveURL, "", "archiveURL", "The s3://bucket/prefix or gs://bucket/prefix that check results and the logs of failed checker pods are archived to.  Archival is disabled when blank.")
+	flaggy.String(&archiveEndpoint, "", "archiveEndpoint", "The endpoint of an S3 compatible object store to archive to instead of the one implied by archiveURL.")
+	flaggy.String(&archiveRegion, "", "archiveRegion", "The region of the archive bucket.")
+	flaggy.Duration(&archiveRetention, "", "archiveRetention", "How long archived check results are kept.  Zero keeps them forever.")
+	flaggy.String(&alertmanagerURL, "", "alertmanagerURL", "The base URL of a Prometheus Alertmanager that failing checks are sent to as alerts.  Alerting is disabled when blank.")
+	flaggy.String(&alertmanagerSeverity, "", "alertmanagerSeverity", "The severity label of alerts sent to Alertmanager.")
+	flaggy.Duration(&alertmanagerInterval, "", "alertmanagerInterval", "How often the alerts of failing checks are sent to Alertmanager.")
+	flaggy.String(&alertmanagerLabelsString, "", "alertmanagerLabels", "Comma separated key=value labels added to every alert sent to Alertmanager, such as the name of the cluster.")
+	flaggy.String(&alertmanagerProxy, "", "alertmanagerProxy", "The proxy alerts are sent to Alertmanager through, or direct.  Defaults to the proxy environment variables.")
+	flaggy.String(&alertmanagerNoProxy, "", "alertmanagerNoProxy", "Comma separated hosts that alerts reach without the Alertmanager proxy.")
+	flaggy.String(&notificationConfigPath, "", "notificationConfig", "The path of a YAML file listing the chat webhooks notified when the health of a check changes.  Notifications are disabled when blank.")
+	flaggy.Duration(&notificationThrottle, "", "notificationThrottle", "The least time between two notifications about the same check.")
+	flaggy.Duration(&notificationRenotify, "", "notificationRenotify", "How often checks that remain failing are notified again.  Zero disables renotifying.")
+	flaggy.String(&configFile, "", "config", "The path of a YAML file of operator-wide settings that take precedence over flags and are reloaded when the file changes.")
+	flaggy.String(&checkPodLabelsString, "", "checkPodLabels", "Comma separated key=value labels applied to every checker pod.  Labels in a khcheck's extraLabels take precedence.")
+	flaggy.String(&checkPodAnnotationsString, "", "checkPodAnnotations", "Comma separated key=value annotations applied to every checker pod.  Annotations in a khcheck's extraAnnotations take precedence.")
+	flaggy.String(&checkNodeSelectorString, "", "checkNodeSelector", "Comma separated key=value node labels that checker pods without their own node selector or node affinity are scheduled onto.")
+	flaggy.String(&checkTolerationsString, "", "checkTolerations", "Comma separated key=value:Effect tolerations applied to checker pods without their own tolerations.")
+	flaggy.String(&checkPriorityClass, "", "checkPriorityClass", "The priority class of checker pods that do not set their own priorityClassName.")
+	flaggy.Duration(&podDeleteGracePeriod, "", "podDeleteGracePeriod", "How long checker pods are given to exit when they are deleted.")
+	flaggy.Float64(&kubeAPIQPS, "", "kubeAPIQPS", "How many requests a second are made to the Kubernetes API.")
+	flaggy.Int(&kubeAPIBurst, "", "kubeAPIBurst", "How many requests to the Kubernetes API can be made at once above the QPS.")
+	flaggy.Float64(&podOperationQPS, "", "podOperationQPS", "How many checker pods a second are created, deleted, and watched across all checks.  Zero does not limit them beyond the Kubernetes API limits.")
+	flaggy.Int(&podOperationBurst, "", "podOperationBurst", "How many checker pod operations can be made at once above the pod operation QPS.")
+	flaggy.Duration(&stateWriteInterval, "", "stateWriteInterval", "How often writes of check state are batched and flushed to the state store.  Zero writes check state immediately.")
+	flaggy.Float64(&stateWriteQPS, "", "stateWriteQPS", "How many writes of check state a second are flushed to the state store when writes are batched.")
+	flaggy.Int(&stateWriteBurst, "", "stateWriteBurst", "How many writes of check state can be flushed at once above the state write QPS.")
+	flaggy.Duration(&podForceDeleteAfter, "", "podForceDeleteAfter", "How long a checker pod can stay terminating past its grace period before it is force deleted.  Zero disables force deletion.")
+	flaggy.Bool(&namespacedMode, "", "namespaced", "Run with only namespace scoped permissions in the check namespaces, which default to the namespace of Kuberhealthy.  Features that need cluster wide permissions are turned off.")
+	flaggy.String(&checkNamespaces, "", "checkNamespaces", "Comma separated namespaces that khchecks are discovered in.  Names can contain * wildcards.  Defaults to every namespace.")
+	flaggy.String(&ignoreCheckNamespaces, "", "ignoreCheckNamespaces", "Comma separated namespaces that khchecks are never discovered in.  Names can contain * wildcards.")
+	flaggy.String(&checkNamespaceSelector, "", "checkNamespaceSelector", "A label selector that namespaces must match for their khchecks to be discovered.")
+	flaggy.String(&reportingProxy, "", "reportingProxy", "The proxy checker pods report through, or direct.  Defaults to the proxy environment variables of checker pods.")
+	flaggy.String(&reportingNoProxy, "", "reportingNoProxy", "Comma separated hosts that checker pods reach without the reporting proxy.")
+	flaggy.String(&reportingPodLabelsString, "", "reportingPodLabels", "Comma separated key=value labels of the Kuberhealthy pods that checker pods with a network policy are allowed to report to.  Defaults to app=kuberhealthy.")
+	flaggy.Float64(&sloTarget, "", "sloTarget", "The percentage of runs of each check that are expected to succeed.  Error budgets are measured against it.")
+	flaggy.Float64(&rollupFailureThreshold, "", "rollupFailureThreshold", "The total weight of failing checks at which the overall status is unhealthy.")
+	flaggy.String(&rollupSeverityWeightsString, "", "rollupSeverityWeights", "Comma separated severity=weight pairs that weight failing checks by their severity, such as critical=1,warning=0.25.")
+	flaggy.String(&statusViewsString, "", "statusViews", "Semicolon separated name=selector pairs of status page views served at /status/<name>, such as team-payments=team=payments.")
+	flaggy.Duration(&statusCacheTTL, "", "statusCacheTTL", "How long a rendered status page is served to pollers before it is rendered again.  Zero disables caching.")
+	flaggy.String(&rollupExcludeNamespacesString, "", "rollupExcludeNamespaces", "Comma separated namespaces whose checks never affect the overall status.")
+	flaggy.Bool(&shardChecks, "", "shardChecks", "Set to true to split checks across every running Kuberhealthy pod instead of running them all on the master.")
+	flaggy.Bool(&recordPodSpecMutations, "", "recordPodSpecMutations", "Set to true to log the changes made to the pod specs of checks and annotate checker pods with them.")
+	flaggy.Bool(&enableCheckFinalizers, "", "checkFinalizers", "Set to false to stop adding the cleanup finalizer to khchecks.  Checks that already have it are still cleaned up when they are deleted.")
 	flaggy.Bool(&enableForceMaster, "", "forceMaster", "Set to true to enable local testing, forced master mode.")
 	flaggy.Bool(&enableDebug, "d", "debug", "Set to true to enable debug.")
 	flaggy.String(&logLevel, "", "log-level", fmt.Sprintf("Log level to be used one of [%s].", getAllLogLevel()))
+	flaggy.String(&logFormat, "", "logFormat", "The format of log lines, one of [text,json].")
 	// Influx flags
 	flaggy.String(&influxUsername, "", "influxUser", "Username for the InfluxDB instance")
 	flaggy.String(&influxPassword, "", "influxPassword", "Password for the InfluxDB instance")
@@ -120,17 +603,538 @@ func init() {
 	// log to stdout and set the level to info by default
 	log.SetOutput(os.Stdout)
 	log.SetLevel(parsedLogLevel)
+	formatter, err := logFormatter(logFormat)
+	if err != nil {
+		log.Fatalln("Unable to parse logFormat flag:", err)
+	}
+	log.SetFormatter(formatter)
 	log.Infoln("Startup Arguments:", os.Args)
 
+	// load TLS certificates for the report-in listeners
+	if len(tlsCertFile) > 0 || len(tlsKeyFile) > 0 {
+		tlsReloader, err = khtls.NewReloader(tlsCertFile, tlsKeyFile, tlsClientCAFile)
+		if err != nil {
+			log.Fatalln("Unable to load TLS certificates:", err)
+		}
+		log.Infoln("TLS enabled with certificate", tlsCertFile, "mutual TLS:", tlsReloader.MutualTLS())
+	}
+
 	// parse external check URL configuration
 	if len(externalCheckReportingURL) == 0 {
 		if len(podNamespace) == 0 {
 			log.Fatalln("KH_EXTERNAL_REPORTING_URL environment variable not set and POD_NAMESPACE environment variable was blank.  Could not determine Kuberhealthy callback URL.")
 		}
-		externalCheckReportingURL = "http://kuberhealthy." + podNamespace + ".svc.cluster.local/externalCheckStatus"
+		scheme := "http://"
+		if tlsReloader != nil {
+			scheme = "https://"
+		}
+		externalCheckReportingURL = scheme + "kuberhealthy." + podNamespace + ".svc.cluster.local/externalCheckStatus"
 	}
 	log.Infoln("External check reporting URL set to:", externalCheckReportingURL)
 
+	// the gRPC reporting address is only handed to checks when the gRPC service is enabled
+	if len(grpcListenAddress) > 0 && len(externalCheckGRPCReportingAddress) == 0 {
+		if len(podNamespace) == 0 {
+			log.Fatalln("KH_EXTERNAL_GRPC_REPORTING_ADDRESS environment variable not set and POD_NAMESPACE environment variable was blank.  Could not determine Kuberhealthy gRPC callback address.")
+		}
+		_, grpcPort, err := net.SplitHostPort(grpcListenAddress)
+		if err != nil {
+			log.Fatalln("Unable to parse grpcListenAddress flag:", err)
+		}
+		externalCheckGRPCReportingAddress = "kuberhealthy." + podNamespace + ".svc.cluster.local:" + grpcPort
+	}
+	if len(externalCheckGRPCReportingAddress) > 0 {
+		log.Infoln("External check gRPC reporting address set to:", externalCheckGRPCReportingAddress)
+	}
+
+	// parse the security policy enforced on checker pods
+	if len(os.Getenv(KHCheckSecurityPolicy)) > 0 {
+		checkSecurityPolicyString = os.Getenv(KHCheckSecurityPolicy)
+	}
+	if len(os.Getenv(KHCheckSeccompProfile)) > 0 {
+		checkSeccompProfile = os.Getenv(KHCheckSeccompProfile)
+	}
+	checkSecurityPolicy, err = podsecurity.ParsePolicy(checkSecurityPolicyString, checkSeccompProfile)
+	if err != nil {
+		log.Fatalln("Unable to parse checkSecurityPolicy:", err)
+	}
+	log.Infoln("Checker pod security policy set to:", checkSecurityPolicyString)
+
+	// parse whether checker pod images are pinned to digests
+	if len(os.Getenv(KHPinCheckImageDigests)) > 0 {
+		pinCheckImageDigests, err = strconv.ParseBool(os.Getenv(KHPinCheckImageDigests))
+		if err != nil {
+			log.Warningln("Failed to parse bool for", KHPinCheckImageDigests, "setting:", err)
+		}
+	}
+
+	// parse the images checker pods are allowed to run
+	checkImagePolicy, err = imagepolicy.ParsePolicy(checkImageAllowlist)
+	if err != nil {
+		log.Fatalln("Unable to parse checkImageAllowlist:", err)
+	}
+	if checkImagePolicy.Enabled() {
+		log.Infoln("Checker pod images restricted to:", checkImagePolicy)
+	}
+
+	// parse the rules khchecks can request for their service accounts
+	checkRolePolicy, err = rolepolicy.ParsePolicy(checkRoleAllowlist)
+	if err != nil {
+		log.Fatalln("Unable to parse checkRoleAllowlist:", err)
+	}
+	log.Infoln("Check service account rules restricted to:", checkRolePolicy)
+
+	// parse the labels and annotations applied to every checker pod
+	checkPodLabels, err = parseKeyValuePairs(checkPodLabelsString)
+	if err != nil {
+		log.Fatalln("Unable to parse checkPodLabels:", err)
+	}
+	checkPodAnnotations, err = parseKeyValuePairs(checkPodAnnotationsString)
+	if err != nil {
+		log.Fatalln("Unable to parse checkPodAnnotations:", err)
+	}
+	if len(checkPodLabels) > 0 || len(checkPodAnnotations) > 0 {
+		log.Infoln("Applying labels", checkPodLabels, "and annotations", checkPodAnnotations, "to all checker pods")
+	}
+
+	// handle running with namespaced permissions, which watches the namespace of Kuberhealthy by default
+	namespacedEnv := os.Getenv(KHNamespaced)
+	if len(namespacedEnv) > 0 {
+		namespacedMode, err = strconv.ParseBool(namespacedEnv)
+		if err != nil {
+			log.Warningln("Failed to parse bool for", KHNamespaced, "setting:", err)
+		}
+	}
+	if namespacedMode && len(checkNamespaces) == 0 {
+		checkNamespaces = podNamespace
+	}
+
+	// parse the namespaces that khchecks are discovered in
+	checkNamespaceFilter, err = namespacefilter.Parse(checkNamespaces, ignoreCheckNamespaces, checkNamespaceSelector)
+	if err != nil {
+		log.Fatalln("Unable to parse check namespaces:", err)
+	}
+	if namespacedMode {
+		err = validateNamespacedMode()
+		if err != nil {
+			log.Fatalln("Unable to run in namespaced mode:", err)
+		}
+		log.Infoln("Running in namespaced mode in namespaces", checkNamespaceFilter.Namespaces())
+	}
+
+	// validate the proxy checker pods report through
+	err = proxy.Validate(reportingProxy)
+	if err != nil {
+		log.Fatalln("Unable to parse reportingProxy:", err)
+	}
+
+	// parse the labels of the pods checker pods report to
+	reportingPodLabels, err = parseKeyValuePairs(reportingPodLabelsString)
+	if err != nil {
+		log.Fatalln("Unable to parse reportingPodLabels:", err)
+	}
+
+	// parse the default node pool of checker pods
+	checkNodeSelector, err = parseKeyValuePairs(checkNodeSelectorString)
+	if err != nil {
+		log.Fatalln("Unable to parse checkNodeSelector:", err)
+	}
+	checkTolerations, err = parseTolerations(checkTolerationsString)
+	if err != nil {
+		log.Fatalln("Unable to parse checkTolerations:", err)
+	}
+	if len(checkNodeSelector) > 0 || len(checkTolerations) > 0 {
+		log.Infoln("Scheduling checker pods with node selector", checkNodeSelector, "and", len(checkTolerations), "tolerations by default")
+	}
+
+	// handle enabling the dry run endpoint
+	dryRunEnv := os.Getenv(KHEnableDryRun)
+	if len(dryRunEnv) > 0 {
+		enableDryRun, err = strconv.ParseBool(dryRunEnv)
+		if err != nil {
+			log.Warningln("Failed to parse bool for", KHEnableDryRun, "setting:", err)
+		}
+	}
+
+	// handle enabling the profiling endpoints
+	profilingEnv := os.Getenv(KHEnableProfiling)
+	if len(profilingEnv) > 0 {
+		enableProfiling, err = strconv.ParseBool(profilingEnv)
+		if err != nil {
+			log.Warningln("Failed to parse bool for", KHEnableProfiling, "setting:", err)
+		}
+	}
+
+	// the profiling endpoints expose the memory and goroutine stacks of Kuberhealthy, so they are never
+	// served without the API token
+	if enableProfiling && len(apiToken) == 0 {
+		log.Fatalln("Profiling can not be enabled without an API token.  Set --apiToken or", KHAPIToken, "to enable profiling.")
+	}
+
+	// handle the default service level objective of checks
+	sloTargetEnv := os.Getenv(KHSLOTarget)
+	if len(sloTargetEnv) > 0 {
+		sloTarget, err = strconv.ParseFloat(sloTargetEnv, 64)
+		if err != nil {
+			log.Warningln("Failed to parse float for", KHSLOTarget, "setting:", err)
+		}
+	}
+
+	// handle the roll-up of check status into the overall status
+	rollupFailureThresholdEnv := os.Getenv(KHRollupFailureThreshold)
+	if len(rollupFailureThresholdEnv) > 0 {
+		rollupFailureThreshold, err = strconv.ParseFloat(rollupFailureThresholdEnv, 64)
+		if err != nil {
+			log.Warningln("Failed to parse float for", KHRollupFailureThreshold, "setting:", err)
+		}
+	}
+	if rollupFailureThreshold <= 0 {
+		log.Warningln("The roll-up failure threshold must be above zero.  Defaulting to", health.DefaultRollupPolicy().FailureThreshold)
+		rollupFailureThreshold = health.DefaultRollupPolicy().FailureThreshold
+	}
+	rollupPolicy.FailureThreshold = rollupFailureThreshold
+	severityWeights, err := parseKeyValuePairs(rollupSeverityWeightsString)
+	if err != nil {
+		log.Fatalln("Unable to parse rollupSeverityWeights:", err)
+	}
+	rollupPolicy.SeverityWeights = make(map[string]float64, len(severityWeights))
+	for severity, weight := range severityWeights {
+		rollupPolicy.SeverityWeights[severity], err = strconv.ParseFloat(weight, 64)
+		if err != nil {
+			log.Fatalln("Unable to parse weight of severity", severity, "in rollupSeverityWeights:", err)
+		}
+	}
+	rollupPolicy.ExcludeNamespaces = parseCommaList(rollupExcludeNamespacesString)
+
+	// handle status page views
+	statusViews, err = parseStatusViews(statusViewsString)
+	if err != nil {
+		log.Fatalln("Unable to parse statusViews:", err)
+	}
+
+	// handle caching the status page
+	statusCacheTTLEnv := os.Getenv(KHStatusCacheTTL)
+	if len(statusCacheTTLEnv) > 0 {
+		statusCacheTTL, err = time.ParseDuration(statusCacheTTLEnv)
+		if err != nil {
+			log.Warningln("Failed to parse duration for", KHStatusCacheTTL, "setting:", err)
+		}
+	}
+	statusResponses = responsecache.New(statusCacheTTL)
+
+	// handle recording pod spec mutations
+	recordPodSpecMutationsEnv := os.Getenv(KHRecordPodSpecMutations)
+	if len(recordPodSpecMutationsEnv) > 0 {
+		recordPodSpecMutations, err = strconv.ParseBool(recordPodSpecMutationsEnv)
+		if err != nil {
+			log.Warningln("Failed to parse bool for", KHRecordPodSpecMutations, "setting:", err)
+		}
+	}
+
+	// handle adding the cleanup finalizer to khchecks
+	checkFinalizersEnv := os.Getenv(KHCheckFinalizers)
+	if len(checkFinalizersEnv) > 0 {
+		enableCheckFinalizers, err = strconv.ParseBool(checkFinalizersEnv)
+		if err != nil {
+			log.Warningln("Failed to parse bool for", KHCheckFinalizers, "setting:", err)
+		}
+	}
+
+	// handle staggering check start times
+	spreadEnv := os.Getenv(KHSpreadCheckStarts)
+	if len(spreadEnv) > 0 {
+		spreadCheckStarts, err = strconv.ParseBool(spreadEnv)
+		if err != nil {
+			log.Warningln("Failed to parse bool for", KHSpreadCheckStarts, "setting:", err)
+		}
+	}
+	jitterEnv := os.Getenv(KHCheckStartJitter)
+	if len(jitterEnv) > 0 {
+		checkStartJitter, err = time.ParseDuration(jitterEnv)
+		if err != nil {
+			log.Warningln("Failed to parse duration for", KHCheckStartJitter, "setting:", err)
+		}
+	}
+
+	// handle sharding checks across pods
+	shardChecksEnv := os.Getenv(KHShardChecks)
+	if len(shardChecksEnv) > 0 {
+		shardChecks, err = strconv.ParseBool(shardChecksEnv)
+		if err != nil {
+			log.Warningln("Failed to parse bool for", KHShardChecks, "setting:", err)
+		}
+	}
+
+	// handle skipping overlapping check runs
+	skipOverlappingEnv := os.Getenv(KHSkipOverlappingRuns)
+	if len(skipOverlappingEnv) > 0 {
+		skipOverlappingRuns, err = strconv.ParseBool(skipOverlappingEnv)
+		if err != nil {
+			log.Warningln("Failed to parse bool for", KHSkipOverlappingRuns, "setting:", err)
+		}
+	}
+
+	// handle clamping check timeouts
+	clampCheckTimeoutsEnv := os.Getenv(KHClampCheckTimeouts)
+	if len(clampCheckTimeoutsEnv) > 0 {
+		clampCheckTimeouts, err = strconv.ParseBool(clampCheckTimeoutsEnv)
+		if err != nil {
+			log.Warningln("Failed to parse bool for", KHClampCheckTimeouts, "setting:", err)
+		}
+	}
+
+	// handle skipping low priority checks under cluster pressure
+	pressureMaxPendingPodsEnv := os.Getenv(KHPressureMaxPendingPods)
+	if len(pressureMaxPendingPodsEnv) > 0 {
+		pressureMaxPendingPods, err = strconv.Atoi(pressureMaxPendingPodsEnv)
+		if err != nil {
+			log.Warningln("Failed to parse int for", KHPressureMaxPendingPods, "setting:", err)
+		}
+	}
+	pressureDeferForEnv := os.Getenv(KHPressureDeferFor)
+	if len(pressureDeferForEnv) > 0 {
+		pressureDeferFor, err = time.ParseDuration(pressureDeferForEnv)
+		if err != nil {
+			log.Warningln("Failed to parse duration for", KHPressureDeferFor, "setting:", err)
+		}
+	}
+	pressureSkipSeverities = parseCommaList(pressureSkipSeveritiesString)
+
+	// handle backing off failing checks
+	backoffThresholdEnv := os.Getenv(KHFailureBackoffThreshold)
+	if len(backoffThresholdEnv) > 0 {
+		failureBackoff.Threshold, err = strconv.Atoi(backoffThresholdEnv)
+		if err != nil {
+			log.Warningln("Failed to parse int for", KHFailureBackoffThreshold, "setting:", err)
+		}
+	}
+	backoffMaxIntervalEnv := os.Getenv(KHFailureBackoffMaxInterval)
+	if len(backoffMaxIntervalEnv) > 0 {
+		failureBackoff.MaxInterval, err = time.ParseDuration(backoffMaxIntervalEnv)
+		if err != nil {
+			log.Warningln("Failed to parse duration for", KHFailureBackoffMaxInterval, "setting:", err)
+		}
+	}
+	staleCheckGraceEnv := os.Getenv(KHStaleCheckGrace)
+	if len(staleCheckGraceEnv) > 0 {
+		staleCheckGrace, err = time.ParseDuration(staleCheckGraceEnv)
+		if err != nil {
+			log.Warningln("Failed to parse duration for", KHStaleCheckGrace, "setting:", err)
+		}
+	}
+	podDeleteGracePeriodEnv := os.Getenv(KHPodDeleteGracePeriod)
+	if len(podDeleteGracePeriodEnv) > 0 {
+		podDeleteGracePeriod, err = time.ParseDuration(podDeleteGracePeriodEnv)
+		if err != nil {
+			log.Warningln("Failed to parse duration for", KHPodDeleteGracePeriod, "setting:", err)
+		}
+	}
+	podForceDeleteAfterEnv := os.Getenv(KHPodForceDeleteAfter)
+	if len(podForceDeleteAfterEnv) > 0 {
+		podForceDeleteAfter, err = time.ParseDuration(podForceDeleteAfterEnv)
+		if err != nil {
+			log.Warningln("Failed to parse duration for", KHPodForceDeleteAfter, "setting:", err)
+		}
+	}
+
+	// handle rate limiting calls to the Kubernetes API
+	kubeAPIQPSEnv := os.Getenv(KHKubeAPIQPS)
+	if len(kubeAPIQPSEnv) > 0 {
+		kubeAPIQPS, err = strconv.ParseFloat(kubeAPIQPSEnv, 64)
+		if err != nil {
+			log.Warningln("Failed to parse float for", KHKubeAPIQPS, "setting:", err)
+		}
+	}
+	kubeAPIBurstEnv := os.Getenv(KHKubeAPIBurst)
+	if len(kubeAPIBurstEnv) > 0 {
+		kubeAPIBurst, err = strconv.Atoi(kubeAPIBurstEnv)
+		if err != nil {
+			log.Warningln("Failed to parse int for", KHKubeAPIBurst, "setting:", err)
+		}
+	}
+	podOperationQPSEnv := os.Getenv(KHPodOperationQPS)
+	if len(podOperationQPSEnv) > 0 {
+		podOperationQPS, err = strconv.ParseFloat(podOperationQPSEnv, 64)
+		if err != nil {
+			log.Warningln("Failed to parse float for", KHPodOperationQPS, "setting:", err)
+		}
+	}
+	podOperationBurstEnv := os.Getenv(KHPodOperationBurst)
+	if len(podOperationBurstEnv) > 0 {
+		podOperationBurst, err = strconv.Atoi(podOperationBurstEnv)
+		if err != nil {
+			log.Warningln("Failed to parse int for", KHPodOperationBurst, "setting:", err)
+		}
+	}
+	stateWriteIntervalEnv := os.Getenv(KHStateWriteInterval)
+	if len(stateWriteIntervalEnv) > 0 {
+		stateWriteInterval, err = time.ParseDuration(stateWriteIntervalEnv)
+		if err != nil {
+			log.Warningln("Failed to parse duration for", KHStateWriteInterval, "setting:", err)
+		}
+	}
+	stateWriteQPSEnv := os.Getenv(KHStateWriteQPS)
+	if len(stateWriteQPSEnv) > 0 {
+		stateWriteQPS, err = strconv.ParseFloat(stateWriteQPSEnv, 64)
+		if err != nil {
+			log.Warningln("Failed to parse float for", KHStateWriteQPS, "setting:", err)
+		}
+	}
+	stateWriteBurstEnv := os.Getenv(KHStateWriteBurst)
+	if len(stateWriteBurstEnv) > 0 {
+		stateWriteBurst, err = strconv.Atoi(stateWriteBurstEnv)
+		if err != nil {
+			log.Warningln("Failed to parse int for", KHStateWriteBurst, "setting:", err)
+		}
+	}
+	kubeAPIRateLimiter = ratelimit.New("kubernetes_api", float32(kubeAPIQPS), kubeAPIBurst)
+	podRateLimiter = ratelimit.New("checker_pods", float32(podOperationQPS), podOperationBurst)
+
+	// handle federation with Kuberhealthy in other clusters
+	if len(os.Getenv(KHFederationClusterName)) > 0 {
+		federationClusterName = os.Getenv(KHFederationClusterName)
+	}
+	federationPollIntervalEnv := os.Getenv(KHFederationPollInterval)
+	if len(federationPollIntervalEnv) > 0 {
+		federationPollInterval, err = time.ParseDuration(federationPollIntervalEnv)
+		if err != nil {
+			log.Warningln("Failed to parse duration for", KHFederationPollInterval, "setting:", err)
+		}
+	}
+	federationStaleAfterEnv := os.Getenv(KHFederationStaleAfter)
+	if len(federationStaleAfterEnv) > 0 {
+		federationStaleAfter, err = time.ParseDuration(federationStaleAfterEnv)
+		if err != nil {
+			log.Warningln("Failed to parse duration for", KHFederationStaleAfter, "setting:", err)
+		}
+	}
+	if len(federationConfigFile) > 0 {
+		federationConfig, err := federation.LoadConfig(federationConfigFile)
+		if err != nil {
+			log.Fatalln("Unable to load federation config:", err)
+		}
+		if len(federationConfig.Clusters) > 0 {
+			federator, err = federation.New(federationClusterName, federationConfig.Clusters)
+			if err != nil {
+				log.Fatalln("Unable to configure federation:", err)
+			}
+			federator.StaleAfter = federationStaleAfter
+			log.Infoln("Federation enabled as cluster", federationClusterName, "with", len(federationConfig.Clusters), "remote clusters")
+		}
+		if federationConfig.Upstream != nil {
+			upstreamPusher, err = federation.NewPusher(federationClusterName, *federationConfig.Upstream)
+			if err != nil {
+				log.Fatalln("Unable to configure upstream status pushes:", err)
+			}
+			log.Infoln("Pushing status upstream to", federationConfig.Upstream.URL, "as cluster", federationClusterName, "every", upstreamPusher.Interval)
+		}
+	}
+
+	// handle tracing of check runs
+	if len(os.Getenv(OTLPServiceNameEnv)) > 0 {
+		tracingServiceName = os.Getenv(OTLPServiceNameEnv)
+	}
+	if len(otlpEndpoint) > 0 {
+		tracing.DefaultTracer = tracing.NewTracer(tracingServiceName, otlpEndpoint)
+		log.Infoln("Exporting traces of check runs to", otlpEndpoint, "as service", tracingServiceName)
+	}
+
+	// handle audit logging
+	auditLogMaxSizeEnv := os.Getenv(KHAuditLogMaxSize)
+	if len(auditLogMaxSizeEnv) > 0 {
+		auditLogMaxSize, err = strconv.Atoi(auditLogMaxSizeEnv)
+		if err != nil {
+			log.Warningln("Failed to parse int for", KHAuditLogMaxSize, "setting:", err)
+		}
+	}
+	auditLogMaxBackupsEnv := os.Getenv(KHAuditLogMaxBackups)
+	if len(auditLogMaxBackupsEnv) > 0 {
+		auditLogMaxBackups, err = strconv.Atoi(auditLogMaxBackupsEnv)
+		if err != nil {
+			log.Warningln("Failed to parse int for", KHAuditLogMaxBackups, "setting:", err)
+		}
+	}
+	if len(auditLogFile) > 0 {
+		auditLog, err = audit.New(auditLogFile, int64(auditLogMaxSize)*1024*1024, auditLogMaxBackups)
+		if err != nil {
+			log.Fatalln("Unable to open audit log:", err)
+		}
+		log.Infoln("Recording report-ins and check state changes to audit log", auditLogFile)
+	}
+
+	// handle archival of check results
+	archiveRetentionEnv := os.Getenv(KHArchiveRetention)
+	if len(archiveRetentionEnv) > 0 {
+		archiveRetention, err = time.ParseDuration(archiveRetentionEnv)
+		if err != nil {
+			log.Warningln("Failed to parse duration for", KHArchiveRetention, "setting:", err)
+		}
+	}
+	if len(archiveURL) > 0 {
+		store, prefix, err := archive.NewStoreFromURL(archiveURL, archiveEndpoint, archiveRegion)
+		if err != nil {
+			log.Fatalln("Unable to configure archival of check results:", err)
+		}
+		archiver = archive.New(store, prefix, archiveRetention)
+		log.Infoln("Archiving check results to", archiveURL, "for", archiveRetention)
+	}
+
+	// handle sending alerts to Alertmanager
+	if len(os.Getenv(KHAlertmanagerSeverity)) > 0 {
+		alertmanagerSeverity = os.Getenv(KHAlertmanagerSeverity)
+	}
+	alertmanagerIntervalEnv := os.Getenv(KHAlertmanagerInterval)
+	if len(alertmanagerIntervalEnv) > 0 {
+		alertmanagerInterval, err = time.ParseDuration(alertmanagerIntervalEnv)
+		if err != nil {
+			log.Warningln("Failed to parse duration for", KHAlertmanagerInterval, "setting:", err)
+		}
+	}
+	if len(alertmanagerURL) > 0 {
+		alertNotifier, err = alertmanager.New(alertmanagerURL)
+		if err != nil {
+			log.Fatalln("Unable to configure alerting:", err)
+		}
+		alertNotifier.Labels, err = parseKeyValuePairs(alertmanagerLabelsString)
+		if err != nil {
+			log.Fatalln("Unable to parse alertmanagerLabels:", err)
+		}
+		alertNotifier.Severity = alertmanagerSeverity
+		if len(alertmanagerProxy) > 0 || len(alertmanagerNoProxy) > 0 {
+			err = alertNotifier.SetProxy(alertmanagerProxy, alertmanagerNoProxy)
+			if err != nil {
+				log.Fatalln("Unable to configure alertmanagerProxy:", err)
+			}
+		}
+		if alertmanagerInterval > 0 {
+			alertNotifier.Interval = alertmanagerInterval
+		}
+		log.Infoln("Sending alerts for failing checks to Alertmanager at", alertmanagerURL, "every", alertNotifier.Interval)
+	}
+
+	// handle chat webhook notifications
+	notificationThrottleEnv := os.Getenv(KHNotificationThrottle)
+	if len(notificationThrottleEnv) > 0 {
+		notificationThrottle, err = time.ParseDuration(notificationThrottleEnv)
+		if err != nil {
+			log.Warningln("Failed to parse duration for", KHNotificationThrottle, "setting:", err)
+		}
+	}
+	notificationRenotifyEnv := os.Getenv(KHNotificationRenotify)
+	if len(notificationRenotifyEnv) > 0 {
+		notificationRenotify, err = time.ParseDuration(notificationRenotifyEnv)
+		if err != nil {
+			log.Warningln("Failed to parse duration for", KHNotificationRenotify, "setting:", err)
+		}
+	}
+	if len(notificationConfigPath) > 0 {
+		c, err := notify.LoadConfig(notificationConfigPath)
+		if err != nil {
+			log.Fatalln("Unable to load notification config:", err)
+		}
+		notificationConfig = &c
+	}
+
 	// handle debug logging
 	debugEnv := os.Getenv("DEBUG")
 	if len(debugEnv) > 0 {
@@ -148,6 +1152,36 @@ func init() {
 		klog.V(10)
 	}
 
+	// load operator-wide settings from the config file over the settings of flags and environment variables
+	flagSettings = currentSettings()
+	if len(configFile) > 0 {
+		var config khconfig.Config
+		configWatcher, config, err = khconfig.NewWatcher(configFile)
+		if err != nil {
+			log.Fatalln("Unable to load config file:", err)
+		}
+		if len(config.ListenAddress) > 0 {
+			listenAddress = config.ListenAddress
+		}
+		err = applyConfig(config)
+		if err != nil {
+			log.Fatalln("Unable to apply config file:", err)
+		}
+		log.Infoln("Loaded settings from config file", configFile)
+	}
+
+	// setup chat webhook notifications
+	if notificationConfig != nil {
+		notifier, err := notify.New(*notificationConfig)
+		if err != nil {
+			log.Fatalln("Unable to configure notifications:", err)
+		}
+		notificationDispatcher = notify.NewDispatcher(notifier)
+		notificationDispatcher.Throttle = notificationThrottle
+		notificationDispatcher.Renotify = notificationRenotify
+		log.Infoln("Notifying", len(notificationConfig.Webhooks), "webhooks of changes to the health of checks at most every", notificationThrottle)
+	}
+
 	// Handle force master mode
 	if enableForceMaster {
 		log.Infoln("Enabling forced master mode")
@@ -165,10 +1199,28 @@ func init() {
 	if err != nil {
 		log.Fatalln("Failed to bootstrap kubernetes clients:", err)
 	}
+
+	// checker pods are owned by the kuberhealthy deployment so that they are garbage collected with it
+	kuberhealthyOwner, err = external.KuberhealthyOwnerReference(kubernetesClient, podNamespace, podHostname)
+	if err != nil {
+		log.Warningln("Checker pods will not be owned by the kuberhealthy deployment:", err)
+	}
+
+	// watch for cluster pressure when checks are skipped under it.  Nodes and the pods of every namespace can
+	// not be listed in namespaced mode.
+	if len(pressureSkipSeverities) > 0 && namespacedMode {
+		log.Warningln("Runs of checks are not skipped under cluster resource pressure because it can not be measured in namespaced mode")
+	} else if len(pressureSkipSeverities) > 0 {
+		log.Infoln("Skipping runs of checks with severities", pressureSkipSeverities, "while the cluster is under resource pressure")
+		pressureGuard = pressure.New(kubernetesClient, pressureMaxPendingPods)
+	}
 }
 
 func main() {
 
+	// parse settings and connect to the cluster
+	configure()
+
 	// Create a new Kuberhealthy struct
 	kuberhealthy = NewKuberhealthy()
 	kuberhealthy.ListenAddr = listenAddress
@@ -234,7 +1286,7 @@ func determineCheckStateFromEnvVar(envVarName string) bool {
 func initKubernetesClients() error {
 
 	// make a new kuberhealthy client
-	kc, err := kubeClient.Create(kubeConfigFile)
+	kc, err := kubeClient.CreateWithRateLimiter(kubeConfigFile, kubeAPIRateLimiter.ClientRateLimiter("request"))
 	if err != nil {
 		return err
 	}
@@ -254,5 +1306,72 @@ func initKubernetesClients() error {
 	}
 	khStateClient = stateClient
 
+	// make a new crd silence client
+	silenceClient, err := khsilencecrd.Client(silenceCRDGroup, silenceCRDVersion, kubeConfigFile, "")
+	if err != nil {
+		return err
+	}
+	khSilenceClient = silenceClient
+
+	// make a new crd check template client
+	checkTemplateClient, err := khchecktemplatecrd.Client(checkTemplateCRDGroup, checkTemplateCRDVersion, kubeConfigFile, "")
+	if err != nil {
+		return err
+	}
+	khCheckTemplateClient = checkTemplateClient
+
+	// discover khchecks from a watch of the namespaces that Kuberhealthy serves
+	checkInformer = NewCheckInformer(checkNamespaceFilter)
+
+	// make the store that check state is kept in
+	switch stateStoreType {
+	case "crd":
+		stateStore = statestore.NewCRDStore(khStateClient)
+	case "configmap":
+		stateStore = statestore.NewConfigMapStore(kubernetesClient)
+	case "memory":
+		// reports that reach a pod other than the master would only be stored in that pod's memory
+		replicas, err := desiredReplicas()
+		if err != nil {
+			return fmt.Errorf("unable to verify that the memory state store runs in a single replica: %w", err)
+		}
+		if replicas > 1 {
+			return fmt.Errorf("the memory state store is not shared between Kuberhealthy pods and requires a single replica, but %d replicas are configured", replicas)
+		}
+		stateStore = statestore.NewMemoryStore()
+	default:
+		return fmt.Errorf("unknown state store type: %s", stateStoreType)
+	}
+
+	// batch writes of check state so that many checks finishing together do not flood the API server.  Check
+	// state in memory is already written immediately.
+	if stateWriteInterval > 0 && stateStoreType != "memory" {
+		stateWriteCoalescer = statestore.NewCoalescer(stateStore, stateWriteInterval, ratelimit.New("state_writes", float32(stateWriteQPS), stateWriteBurst))
+		stateStore = stateWriteCoalescer
+	}
+
 	return nil
 }
+
+// desiredReplicas returns the number of Kuberhealthy pods that the replica set of this pod is configured to run.
+// A pod that is not owned by a replica set is the only one.
+func desiredReplicas() (int32, error) {
+	pod, err := kubernetesClient.CoreV1().Pods(podNamespace).Get(podHostname, metav1.GetOptions{})
+	if err != nil {
+		return 0, fmt.Errorf("failed to get pod %s: %w", podHostname, err)
+	}
+	for _, owner := range pod.OwnerReferences {
+		if owner.Kind != "ReplicaSet" {
+			continue
+		}
+		rs, err := kubernetesClient.AppsV1().ReplicaSets(podNamespace).Get(owner.Name, metav1.GetOptions{})
+		if err != nil {
+			return 0, fmt.Errorf("failed to get replica set %s: %w", owner.Name, err)
+		}
+		if rs.Spec.Replicas == nil {
+			return 1, nil
+		}
+		return *rs.Spec.Replicas, nil
+	}
+	return 1, nil
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"errors"
+
+	apiv1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/Comcast/kuberhealthy/v2/pkg/khsilencecrd"
+	"github.com/Comcast/kuberhealthy/v2/pkg/statestore"
+)
+
+// errNamespacedMode is returned by features that need cluster wide permissions when Kuberhealthy runs in
+// namespaced mode
+var errNamespacedMode = errors.New("not available when Kuberhealthy runs in namespaced mode")
+
+// validateNamespacedMode returns an error if Kuberhealthy can not run with only the permissions of the namespaces
+// it watches.  Every namespace must be named, since namespaces can not be listed or matched by label, and
+// features that can not degrade without cluster wide permissions must be off.
+func validateNamespacedMode() error {
+	if checkNamespaceFilter.NeedsLabels() {
+		return errors.New("namespaces can not be selected by label in namespaced mode")
+	}
+	if len(checkNamespaceFilter.Namespaces()) == 0 {
+		return errors.New("namespaced mode requires checkNamespaces to name every namespace without wildcards")
+	}
+	if len(checkTokenAudience) > 0 {
+		return errors.New("checkTokenAudience requires cluster wide permission to review tokens and can not be used in namespaced mode")
+	}
+	return nil
+}
+
+// scopedNamespaces returns the namespaces that Kuberhealthy lists and watches resources in.  This is every
+// namespace at once, unless Kuberhealthy runs in namespaced mode and has to list each namespace it watches by
+// itself.
+func scopedNamespaces() []string {
+	if !namespacedMode {
+		return []string{metav1.NamespaceAll}
+	}
+	return checkNamespaceFilter.Namespaces()
+}
+
+// listCheckStates lists the state of every check from the state store in the namespaces Kuberhealthy lists
+// resources in
+func listCheckStates() ([]statestore.CheckState, error) {
+	var states []statestore.CheckState
+	for _, namespace := range scopedNamespaces() {
+		s, err := stateStore.List(namespace)
+		if err != nil {
+			return nil, err
+		}
+		states = append(states, s...)
+	}
+	return states, nil
+}
+
+// listPods lists the pods that match the options in the namespaces Kuberhealthy lists resources in
+func listPods(opts metav1.ListOptions) (*apiv1.PodList, error) {
+	l := &apiv1.PodList{}
+	for _, namespace := range scopedNamespaces() {
+		pods, err := kubernetesClient.CoreV1().Pods(namespace).List(opts)
+		if err != nil {
+			return nil, err
+		}
+		l.Items = append(l.Items, pods.Items...)
+	}
+	return l, nil
+}
+
+// listConfigMaps lists the config maps that match the options in the namespaces Kuberhealthy lists resources in
+func listConfigMaps(opts metav1.ListOptions) (*apiv1.ConfigMapList, error) {
+	l := &apiv1.ConfigMapList{}
+	for _, namespace := range scopedNamespaces() {
+		configMaps, err := kubernetesClient.CoreV1().ConfigMaps(namespace).List(opts)
+		if err != nil {
+			return nil, err
+		}
+		l.Items = append(l.Items, configMaps.Items...)
+	}
+	return l, nil
+}
+
+// listServiceAccounts lists the service accounts that match the options in the namespaces Kuberhealthy lists
+// resources in
+func listServiceAccounts(opts metav1.ListOptions) (*apiv1.ServiceAccountList, error) {
+	l := &apiv1.ServiceAccountList{}
+	for _, namespace := range scopedNamespaces() {
+		serviceAccounts, err := kubernetesClient.CoreV1().ServiceAccounts(namespace).List(opts)
+		if err != nil {
+			return nil, err
+		}
+		l.Items = append(l.Items, serviceAccounts.Items...)
+	}
+	return l, nil
+}
+
+// listSilences lists the khsilences in the namespaces Kuberhealthy lists resources in
+func listSilences() (*khsilencecrd.KuberhealthySilenceList, error) {
+	l := &khsilencecrd.KuberhealthySilenceList{}
+	for _, namespace := range scopedNamespaces() {
+		silences, err := khSilenceClient.List(metav1.ListOptions{}, silenceCRDResource, namespace)
+		if err != nil {
+			return nil, err
+		}
+		l.Items = append(l.Items, silences.Items...)
+	}
+	return l, nil
+}
@@ -0,0 +1,62 @@
+// Copyright 2018 Comcast Cable Communications Management, LLC
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//     http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/Comcast/kuberhealthy/v2/pkg/health"
+	"github.com/Comcast/kuberhealthy/v2/pkg/notify"
+)
+
+// notifyStateChange hands a message about a check whose health changed to the notification dispatcher, which
+// posts it to the webhooks the check routes its notifications to, or to the default webhooks, unless it is a
+// duplicate or throttled.  Messages are posted in the background so that a slow webhook does not hold up the
+// check.  Does nothing when notifications are disabled.
+func (k *Kuberhealthy) notifyStateChange(checkName string, checkNamespace string, details health.CheckDetails) {
+	if notificationDispatcher == nil {
+		return
+	}
+	if silences.Get(checkNamespace, checkName) != nil {
+		log.Debugln("Not notifying webhooks of state change of silenced check", checkName, "in namespace", checkNamespace)
+		return
+	}
+
+	var channels []string
+	c, err := k.getCheck(checkName, checkNamespace)
+	if err == nil {
+		if nc, ok := c.(notificationCheck); ok {
+			channels = nc.Channels()
+		}
+	}
+
+	event := notify.Event{
+		Check:     checkName,
+		Namespace: checkNamespace,
+		OK:        details.OK,
+		Errors:    details.Errors,
+		Category:  details.ErrorCategory,
+		RunID:     details.CurrentUUID,
+		Time:      time.Now(),
+	}
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+		defer cancel()
+		err := notificationDispatcher.Dispatch(ctx, event, channels)
+		if err != nil {
+			log.Errorln("Failed to notify webhooks of state change of check", checkName, "in namespace", checkNamespace+":", err)
+		}
+	}()
+}
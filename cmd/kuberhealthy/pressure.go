@@ -0,0 +1,87 @@
+// Copyright 2018 Comcast Cable Communications Management, LLC
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//     http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/Comcast/kuberhealthy/v2/pkg/scheduler"
+)
+
+// pressureSkipReason returns why the next run of a check should be skipped because the cluster is under
+// resource pressure.  Only checks of the severities listed in pressureSkipSeverities are skipped.  A blank
+// reason is returned when the check should run.
+func pressureSkipReason(logger *log.Entry, c KuberhealthyCheck) string {
+	if pressureGuard == nil {
+		return ""
+	}
+	sc, ok := c.(severityCheck)
+	if !ok || !containsString(sc.RollupSeverity(), pressureSkipSeverities) {
+		return ""
+	}
+
+	status, err := pressureGuard.Check()
+	if err != nil {
+		logger.Warningln("Unable to determine if the cluster is under pressure.  Running check anyway:", err)
+		return ""
+	}
+	if !status.UnderPressure {
+		return ""
+	}
+	return "skipped due to cluster pressure: " + strings.Join(status.Reasons, ", ")
+}
+
+// skipRunForPressure records that a run of a check was skipped because the cluster is under resource
+// pressure.  The results of the previous run are kept, so a skipped run neither passes nor fails the check.
+func (k *Kuberhealthy) skipRunForPressure(logger *log.Entry, c KuberhealthyCheck, reason string) {
+	logger.Warningln("Skipping run of check:", reason)
+
+	details, err := getCheckState(c)
+	if err != nil {
+		logger.Errorln("Error getting check state to record skipped run:", err)
+		return
+	}
+	base := details.DeepCopy()
+	details.Skipped = reason
+	details.StaleAt = k.staleAt(c)
+	err = k.storeCheckState(c.Name(), c.CheckNamespace(), base, details)
+	if err != nil {
+		logger.Errorln("Error storing CRD state for skipped check:", err)
+	}
+}
+
+// waitForDeferredRun blocks until a run skipped under pressure is retried, which is after pressureDeferFor
+// or at the check's next scheduled run, whichever is sooner.  Skipped runs wait for the next scheduled run
+// when runs are not deferred.  The run UUID of a run triggered out of band is returned.
+func (k *Kuberhealthy) waitForDeferredRun(ctx context.Context, key string, schedule *scheduler.Schedule, trigger chan string) string {
+	retry := time.Now().Add(pressureDeferFor)
+	if pressureDeferFor <= 0 || !retry.Before(schedule.Next(time.Now())) {
+		return k.waitForNextRun(ctx, key, schedule, trigger)
+	}
+
+	k.heartbeats.Beat(key, retry.Add(staleCheckGrace))
+	k.heartbeats.SetPhase(key, checkPhaseIdle)
+	timer := time.NewTimer(time.Until(retry))
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return ""
+	case runID := <-trigger:
+		return runID
+	case <-ctx.Done():
+		return ""
+	}
+}
@@ -0,0 +1,188 @@
+// Copyright 2018 Comcast Cable Communications Management, LLC
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//     http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"runtime"
+	"runtime/pprof"
+	"runtime/trace"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// the paths of the profiling endpoints.  net/http/pprof and expvar are not imported because they register
+// their endpoints on the default mux as soon as they are imported, which would serve them without the flag.
+const profilingPrefix = "/debug/pprof/"
+const varsPath = "/debug/vars"
+const goroutinesPath = "/debug/goroutines"
+
+// maxProfileDuration is the longest CPU profile or execution trace that can be requested
+const maxProfileDuration = time.Minute * 5
+
+// authorizeProfilingRequest writes an error and returns false if a request to a profiling endpoint is not
+// allowed.  Profiling requests must present the API token like API requests do.
+func authorizeProfilingRequest(w http.ResponseWriter, r *http.Request) bool {
+	log.Infoln("Client connected to profiling endpoint", r.URL.Path, "from", r.RemoteAddr, r.UserAgent())
+	if !authorizeAPIRequest(r) {
+		http.Error(w, "a valid bearer token is required", http.StatusUnauthorized)
+		return false
+	}
+	if r.Method != http.MethodGet {
+		http.Error(w, "only GET is supported", http.StatusMethodNotAllowed)
+		return false
+	}
+	return true
+}
+
+// profileDuration returns the seconds query parameter of a request as a duration, or the supplied default
+func profileDuration(r *http.Request, defaultDuration time.Duration) (time.Duration, error) {
+	seconds := r.URL.Query().Get("seconds")
+	if len(seconds) == 0 {
+		return defaultDuration, nil
+	}
+	s, err := strconv.Atoi(seconds)
+	if err != nil || s <= 0 {
+		return 0, fmt.Errorf("invalid seconds: %s", seconds)
+	}
+	d := time.Duration(s) * time.Second
+	if d > maxProfileDuration {
+		return 0, fmt.Errorf("profiles can be at most %s long", maxProfileDuration)
+	}
+	return d, nil
+}
+
+// profilingHandler serves the profiles of Kuberhealthy in the format read by go tool pprof.  The index lists
+// the available profiles, /debug/pprof/profile records a CPU profile, /debug/pprof/trace records an execution
+// trace, and every other path serves the runtime profile of that name, such as heap or goroutine.
+func profilingHandler(w http.ResponseWriter, r *http.Request) error {
+	if !authorizeProfilingRequest(w, r) {
+		return nil
+	}
+
+	name := strings.TrimPrefix(r.URL.Path, profilingPrefix)
+	switch name {
+	case "":
+		return writeProfileIndex(w)
+	case "profile":
+		d, err := profileDuration(r, time.Second*30)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return nil
+		}
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Header().Set("Content-Disposition", `attachment; filename="profile"`)
+		err = pprof.StartCPUProfile(w)
+		if err != nil {
+			http.Error(w, "could not start CPU profile: "+err.Error(), http.StatusInternalServerError)
+			return err
+		}
+		sleepOrCancel(r, d)
+		pprof.StopCPUProfile()
+		return nil
+	case "trace":
+		d, err := profileDuration(r, time.Second)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return nil
+		}
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Header().Set("Content-Disposition", `attachment; filename="trace"`)
+		err = trace.Start(w)
+		if err != nil {
+			http.Error(w, "could not start execution trace: "+err.Error(), http.StatusInternalServerError)
+			return err
+		}
+		sleepOrCancel(r, d)
+		trace.Stop()
+		return nil
+	}
+
+	p := pprof.Lookup(name)
+	if p == nil {
+		http.Error(w, "unknown profile: "+name, http.StatusNotFound)
+		return nil
+	}
+	debug, _ := strconv.Atoi(r.URL.Query().Get("debug"))
+	if debug > 0 {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	} else {
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Header().Set("Content-Disposition", `attachment; filename="`+name+`"`)
+	}
+	return p.WriteTo(w, debug)
+}
+
+// sleepOrCancel waits for the supplied duration or until the client goes away
+func sleepOrCancel(r *http.Request, d time.Duration) {
+	select {
+	case <-time.After(d):
+	case <-r.Context().Done():
+	}
+}
+
+// writeProfileIndex lists the available profiles and how many samples each holds
+func writeProfileIndex(w http.ResponseWriter) error {
+	profiles := pprof.Profiles()
+	sort.Slice(profiles, func(i, j int) bool { return profiles[i].Name() < profiles[j].Name() })
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	var b strings.Builder
+	b.WriteString("Profiles of this Kuberhealthy instance.  Add ?debug=1 for a text version.\n\n")
+	for _, p := range profiles {
+		fmt.Fprintf(&b, "%s%s (%d)\n", profilingPrefix, p.Name(), p.Count())
+	}
+	fmt.Fprintf(&b, "%sprofile?seconds=30 (CPU profile)\n", profilingPrefix)
+	fmt.Fprintf(&b, "%strace?seconds=1 (execution trace)\n", profilingPrefix)
+	_, err := w.Write([]byte(b.String()))
+	return err
+}
+
+// varsHandler serves the command line, memory statistics, and goroutine count of Kuberhealthy as JSON in the
+// same format as the expvar package
+func varsHandler(w http.ResponseWriter, r *http.Request) error {
+	if !authorizeProfilingRequest(w, r) {
+		return nil
+	}
+
+	var memStats runtime.MemStats
+	runtime.ReadMemStats(&memStats)
+	vars := map[string]interface{}{
+		"cmdline":    os.Args,
+		"memstats":   memStats,
+		"goroutines": runtime.NumGoroutine(),
+	}
+	b, err := json.MarshalIndent(vars, "", "  ")
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return err
+	}
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	_, err = w.Write(b)
+	return err
+}
+
+// goroutinesHandler serves the stack of every goroutine, which shows what a stuck watch or check is blocked on
+func goroutinesHandler(w http.ResponseWriter, r *http.Request) error {
+	if !authorizeProfilingRequest(w, r) {
+		return nil
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	return pprof.Lookup("goroutine").WriteTo(w, 2)
+}
@@ -0,0 +1,71 @@
+// Copyright 2018 Comcast Cable Communications Management, LLC
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//     http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+
+	log "github.com/sirupsen/logrus"
+	k8sErrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/Comcast/kuberhealthy/v2/pkg/checks/external"
+)
+
+// reapCheckServiceAccounts removes the service accounts, roles, and role bindings that were created for
+// khchecks which no longer exist or no longer request a service account.
+func (k *Kuberhealthy) reapCheckServiceAccounts() error {
+
+	// list all service accounts created for checks
+	serviceAccounts, err := listServiceAccounts(metav1.ListOptions{LabelSelector: external.CheckServiceAccountLabel})
+	if err != nil {
+		return fmt.Errorf("error listing check service accounts for reaping: %w", err)
+	}
+
+	// list all khChecks, including the service accounts requested by their templates
+	khChecks, _, err := listExpandedChecks()
+	if err != nil {
+		return fmt.Errorf("error listing khChecks for service account reaping: %w", err)
+	}
+
+	for _, sa := range serviceAccounts.Items {
+		checkName := sa.Labels[external.CheckServiceAccountLabel]
+
+		// the service account is still valid if its khcheck exists and still asks for it
+		var stillRequested bool
+		for _, khCheck := range khChecks.Items {
+			if khCheck.GetName() == checkName && khCheck.GetNamespace() == sa.GetNamespace() && khCheck.Spec.ServiceAccount != nil {
+				stillRequested = true
+				break
+			}
+		}
+		if stillRequested {
+			continue
+		}
+
+		log.Infoln("khState reaper: removing service account, role, and role binding", sa.GetName(), "in", sa.GetNamespace())
+		err = kubernetesClient.RbacV1().RoleBindings(sa.GetNamespace()).Delete(sa.GetName(), &metav1.DeleteOptions{})
+		if err != nil && !k8sErrors.IsNotFound(err) {
+			log.Errorln("khState reaper: error removing role binding for check", checkName+":", err)
+		}
+		err = kubernetesClient.RbacV1().Roles(sa.GetNamespace()).Delete(sa.GetName(), &metav1.DeleteOptions{})
+		if err != nil && !k8sErrors.IsNotFound(err) {
+			log.Errorln("khState reaper: error removing role for check", checkName+":", err)
+		}
+		err = kubernetesClient.CoreV1().ServiceAccounts(sa.GetNamespace()).Delete(sa.GetName(), &metav1.DeleteOptions{})
+		if err != nil && !k8sErrors.IsNotFound(err) {
+			log.Errorln("khState reaper: error removing service account for check", checkName+":", err)
+		}
+	}
+
+	return nil
+}
@@ -1,7 +1,6 @@
 package main
 
 import (
-	"strings"
 	"time"
 
 	"k8s.io/apimachinery/pkg/fields"
@@ -11,16 +10,19 @@ import (
 
 	"github.com/Comcast/kuberhealthy/v2/pkg/health"
 	"github.com/Comcast/kuberhealthy/v2/pkg/khstatecrd"
+	"github.com/Comcast/kuberhealthy/v2/pkg/statestore"
 )
 
 // StateReflector watches the state of khstate objects and stores them in a local cache.  Then, when the current
 // state of checks is requested, the CurrentStatus func can serve it rapidly from cache.  Needs to run in the
-// background and can be stopped/started by simply calling `Stop()` on it.
+// background and can be stopped/started by simply calling `Stop()` on it.  When check state is not kept in
+// khstate resources, the current state is listed from the state store instead.  In namespaced mode, each
+// namespace Kuberhealthy watches has a reflector of its own.
 type StateReflector struct {
-	reflector        *cache.Reflector
+	reflectors       []*cache.Reflector
 	reflectorSigChan chan struct{} // the channel that indicates when the cache sync should stop
 	resyncPeriod     time.Duration // the period for full API re-syncs
-	store            cache.Store
+	stores           []cache.Store // the cache of each reflector
 }
 
 // NewReflector creates a new StateReflector for watching the state of khstate resoruces on the server
@@ -29,10 +31,18 @@ func NewStateReflector() *StateReflector {
 	sr.reflectorSigChan = make(chan struct{})
 	sr.resyncPeriod = time.Minute * 5
 
-	// structure the reflector and its required elements
-	khStateListWatch := cache.NewListWatchFromClient(khStateClient.RestClient(), stateCRDResource, "", fields.Everything())
-	sr.store = cache.NewStore(cache.MetaNamespaceKeyFunc)
-	sr.reflector = cache.NewReflector(khStateListWatch, &khstatecrd.KuberhealthyState{}, sr.store, sr.resyncPeriod)
+	// only khstate resources can be watched
+	if stateStoreType != "crd" {
+		return &sr
+	}
+
+	// structure the reflectors and their required elements
+	for _, namespace := range scopedNamespaces() {
+		khStateListWatch := cache.NewListWatchFromClient(khStateClient.RestClient(), stateCRDResource, namespace, fields.Everything())
+		store := cache.NewStore(cache.MetaNamespaceKeyFunc)
+		sr.stores = append(sr.stores, store)
+		sr.reflectors = append(sr.reflectors, cache.NewReflector(khStateListWatch, &khstatecrd.KuberhealthyState{}, store, sr.resyncPeriod))
+	}
 
 	return &sr
 }
@@ -41,14 +51,33 @@ func NewStateReflector() *StateReflector {
 func (sr *StateReflector) Stop() {
 	log.Infoln("khState reflector stopping")
 	if sr.reflectorSigChan != nil {
-		sr.reflectorSigChan <- struct{}{}
+		for range sr.reflectors {
+			sr.reflectorSigChan <- struct{}{}
+		}
 	}
 }
 
 // Start begins the store and resync operations in the background
 func (sr *StateReflector) Start() {
+	if len(sr.reflectors) == 0 {
+		log.Infoln("khState reflector not started because check state is stored in", stateStoreType)
+		return
+	}
 	log.Infoln("khState reflector starting")
-	sr.reflector.Run(sr.reflectorSigChan)
+	for _, r := range sr.reflectors {
+		go r.Run(sr.reflectorSigChan)
+	}
+}
+
+// HasSynced returns true once every reflector has listed the khstate resources at least once.  Always true
+// when check state is not kept in khstate resources.
+func (sr *StateReflector) HasSynced() bool {
+	for _, r := range sr.reflectors {
+		if len(r.LastSyncResourceVersion()) == 0 {
+			return false
+		}
+	}
+	return true
 }
 
 // CurrentStatus returns the current summary of checks as known by the cache.
@@ -58,46 +87,99 @@ func (sr *StateReflector) CurrentStatus() health.State {
 	log.Infoln("khState reflector fetching current status")
 	state := health.NewState()
 
-	// if the store is nil, then we just return a blank slate
-	if sr.store == nil {
-		log.Warningln("attempted to fetch CurrentStatus from khStateReflector, but the store was nil")
-		return state
-	}
-
-	// list all objects from the storage cache
-	khStateList := sr.store.List()
-	for i, khStateUndefined := range khStateList {
-		log.Debugln("state reflector store item from listing:", i, khStateUndefined)
-		khState, ok := khStateUndefined.(*khstatecrd.KuberhealthyState)
-		if !ok {
-			log.Warningln("attempted to convert item from state cache reflector to a khstatecrd.KuberhealthyState, but the type was invalid")
-			continue
-		}
-
-		log.Debugln("Getting status of check for web request to status page:", khState.GetName(), khState.GetNamespace())
+	for _, khState := range sr.listStates() {
+		log.Debugln("Getting status of check for web request to status page:", khState.Name, khState.Namespace)
 
 		// skip the check if it has never been run before.  This prevents checks that have not yet
 		// run from showing in the status page.
-		if len(khState.Spec.AuthoritativePod) == 0 {
-			log.Debugln("Output for", khState.GetName(), khState.GetNamespace(), "hidden from status page due to blank authoritative pod")
+		if len(khState.Details.AuthoritativePod) == 0 {
+			log.Debugln("Output for", khState.Name, khState.Namespace, "hidden from status page due to blank authoritative pod")
 			continue
 		}
 
-		// parse check status from CRD and add it to the global status of errors. Skip blank errors
-		for _, e := range khState.Spec.Errors {
-			if len(strings.TrimSpace(e)) == 0 {
-				log.Warningln("Skipped an error that was blank when adding check details to current state.")
-				continue
-			}
-			state.AddError(e)
-			log.Debugln("Status page: Setting global OK state to false due to check details not being OK")
-			state.OK = false
+		// checks whose last result outlived their result TTL are shown as unknown, and checks that stopped
+		// completing runs are shown as failed, instead of with their last result
+		if khState.Details.MarkUnknown(time.Now()) {
+			log.Warningln("Check", khState.Name, "in namespace", khState.Namespace, "has not reported a result since", khState.Details.LastRun, "and its health is unknown")
+		} else if khState.Details.MarkStale(time.Now()) {
+			log.Warningln("Check", khState.Name, "in namespace", khState.Namespace, "has not completed a run since", khState.Details.LastRun, "and is stale")
 		}
 
+		// compute the availability of the check from its run history
+		khState.Details.ComputeAvailability(time.Now())
+
+		// silenced checks are shown with their errors, but do not affect the overall status
+		khState.Details.Silence = silences.Get(khState.Namespace, khState.Name)
+
 		// update check details struct
-		state.CheckDetails[khState.GetNamespace()+"/"+khState.GetName()] = khState.Spec
+		state.CheckDetails[khState.Namespace+"/"+khState.Name] = khState.Details
 	}
 
+	// roll the status of each check up into the overall status
+	rollupPolicy.Apply(&state)
+
 	log.Infoln("khState reflector returning current status on", len(state.CheckDetails), "khStates")
 	return state
 }
+
+// CheckDetails returns the stored details of a single check as known by the cache.  Unlike CurrentStatus,
+// stale checks are returned as they were stored.  Checks that have never run are not found.
+func (sr *StateReflector) CheckDetails(namespace string, name string) (health.CheckDetails, bool) {
+	for _, khState := range sr.listStates() {
+		if khState.Namespace == namespace && khState.Name == name && len(khState.Details.AuthoritativePod) > 0 {
+			return khState.Details, true
+		}
+	}
+	return health.CheckDetails{}, false
+}
+
+// listStates returns the state of all checks from the reflector cache, or from the state store if
+// the reflector is not in use
+func (sr *StateReflector) listStates() []statestore.CheckState {
+
+	// without a reflector, list directly from the state store
+	if len(sr.reflectors) == 0 {
+		states, err := listCheckStates()
+		if err != nil {
+			log.Errorln("khState reflector failed to list check states from the state store:", err)
+		}
+		return watchedStates(states)
+	}
+
+	// list all objects from the storage caches
+	var cached []interface{}
+	for _, store := range sr.stores {
+		cached = append(cached, store.List()...)
+	}
+	var states []statestore.CheckState
+	for i, khStateUndefined := range cached {
+		log.Debugln("state reflector store item from listing:", i, khStateUndefined)
+		khState, ok := khStateUndefined.(*khstatecrd.KuberhealthyState)
+		if !ok {
+			log.Warningln("attempted to convert item from state cache reflector to a khstatecrd.KuberhealthyState, but the type was invalid")
+			continue
+		}
+		states = append(states, statestore.CheckState{
+			Name:      khState.GetName(),
+			Namespace: khState.GetNamespace(),
+			Details:   khState.Spec,
+		})
+	}
+
+	// writes that have not been flushed yet are newer than the cache
+	if stateWriteCoalescer != nil {
+		states = stateWriteCoalescer.Overlay(states)
+	}
+	return watchedStates(states)
+}
+
+// watchedStates returns the states of checks in namespaces that Kuberhealthy watches
+func watchedStates(states []statestore.CheckState) []statestore.CheckState {
+	var watched []statestore.CheckState
+	for _, s := range states {
+		if watchesNamespace(s.Namespace) {
+			watched = append(watched, s)
+		}
+	}
+	return watched
+}
@@ -0,0 +1,204 @@
+// Copyright 2018 Comcast Cable Communications Management, LLC
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//     http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"net/http"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/Comcast/kuberhealthy/v2/pkg/apiclient"
+	"github.com/Comcast/kuberhealthy/v2/pkg/health"
+	"github.com/Comcast/kuberhealthy/v2/pkg/openapi"
+	"github.com/Comcast/kuberhealthy/v2/pkg/runhistory"
+	"github.com/Comcast/kuberhealthy/v2/pkg/usage"
+)
+
+// openAPIPath is the path that the OpenAPI specification of the API is served at
+const openAPIPath = "/openapi.json"
+
+// apiRoute is an endpoint of the versioned API along with the handler that serves it.  The endpoint
+// documents the route in the OpenAPI specification.
+type apiRoute struct {
+	openapi.Endpoint
+	handler func(k *Kuberhealthy, w http.ResponseWriter, r *http.Request, params map[string]string) error
+}
+
+// stringParameter returns an optional query parameter that holds a string
+func stringParameter(name string, description string) openapi.Parameter {
+	return openapi.Parameter{Name: name, In: "query", Description: description, Schema: &openapi.Schema{Type: "string"}}
+}
+
+// apiRoutes are the endpoints of the versioned API.  Every route is served by apiHandler and described
+// in the OpenAPI specification.
+var apiRoutes = []apiRoute{
+	{
+		Endpoint: openapi.Endpoint{
+			Method:      http.MethodGet,
+			Path:        apiPrefix + "status",
+			OperationID: "getStatus",
+			Summary:     "Get the overall status and the status of every check",
+			Query: []openapi.Parameter{
+				stringParameter("namespace", "a comma separated list of namespaces to include"),
+				stringParameter("selector", "a label selector that checks must match"),
+				stringParameter("view", "the name of a configured status page view"),
+			},
+			Response: health.State{},
+		},
+		handler: func(k *Kuberhealthy, w http.ResponseWriter, r *http.Request, params map[string]string) error {
+			return k.statusHandler(w, r)
+		},
+	},
+	{
+		Endpoint: openapi.Endpoint{
+			Method:      http.MethodGet,
+			Path:        apiPrefix + "checks",
+			OperationID: "listChecks",
+			Summary:     "List the status of every check by namespace/name",
+			Query: []openapi.Parameter{
+				stringParameter("namespace", "a comma separated list of namespaces to include"),
+				stringParameter("selector", "a label selector that checks must match"),
+			},
+			Response: map[string]health.CheckDetails{},
+		},
+		handler: func(k *Kuberhealthy, w http.ResponseWriter, r *http.Request, params map[string]string) error {
+			return k.listChecksHandler(w, r)
+		},
+	},
+	{
+		Endpoint: openapi.Endpoint{
+			Method:      http.MethodGet,
+			Path:        apiPrefix + "checks/{namespace}/{name}",
+			OperationID: "getCheck",
+			Summary:     "Get the status of a check",
+			Response:    health.CheckDetails{},
+		},
+		handler: func(k *Kuberhealthy, w http.ResponseWriter, r *http.Request, params map[string]string) error {
+			return k.getCheckHandler(w, params["namespace"], params["name"])
+		},
+	},
+	{
+		Endpoint: openapi.Endpoint{
+			Method:      http.MethodPost,
+			Path:        apiPrefix + "checks/{namespace}/{name}/run",
+			OperationID: "runCheck",
+			Summary:     "Trigger an immediate run of a check",
+			Status:      http.StatusAccepted,
+			Response:    apiclient.TriggerRunResponse{},
+		},
+		handler: func(k *Kuberhealthy, w http.ResponseWriter, r *http.Request, params map[string]string) error {
+			return k.triggerRunHandler(w, params["namespace"], params["name"])
+		},
+	},
+	{
+		Endpoint: openapi.Endpoint{
+			Method:      http.MethodPost,
+			Path:        apiPrefix + "checks/{namespace}/{name}/silence",
+			OperationID: "silenceCheck",
+			Summary:     "Silence a check for a duration",
+			Request:     apiclient.SilenceRequest{},
+			Status:      http.StatusCreated,
+			Response:    health.Silence{},
+		},
+		handler: func(k *Kuberhealthy, w http.ResponseWriter, r *http.Request, params map[string]string) error {
+			return k.createSilenceHandler(w, r, params["namespace"], params["name"])
+		},
+	},
+	{
+		Endpoint: openapi.Endpoint{
+			Method:      http.MethodDelete,
+			Path:        apiPrefix + "checks/{namespace}/{name}/silence",
+			OperationID: "unsilenceCheck",
+			Summary:     "Lift every silence of a check",
+			Status:      http.StatusNoContent,
+		},
+		handler: func(k *Kuberhealthy, w http.ResponseWriter, r *http.Request, params map[string]string) error {
+			return k.deleteSilenceHandler(w, params["namespace"], params["name"])
+		},
+	},
+	{
+		Endpoint: openapi.Endpoint{
+			Method:      http.MethodGet,
+			Path:        apiPrefix + "silences",
+			OperationID: "listSilences",
+			Summary:     "List the active silences by check",
+			Response:    map[string]health.Silence{},
+		},
+		handler: func(k *Kuberhealthy, w http.ResponseWriter, r *http.Request, params map[string]string) error {
+			return writeAPIResponse(w, http.StatusOK, silences.List())
+		},
+	},
+	{
+		Endpoint: openapi.Endpoint{
+			Method:      http.MethodGet,
+			Path:        apiPrefix + "runs",
+			OperationID: "listRuns",
+			Summary:     "List the recent check runs",
+			Query: []openapi.Parameter{
+				stringParameter("namespace", "only list the runs of checks in this namespace"),
+				stringParameter("check", "only list the runs of checks with this name"),
+			},
+			Response: []runhistory.Run{},
+		},
+		handler: func(k *Kuberhealthy, w http.ResponseWriter, r *http.Request, params map[string]string) error {
+			return k.listRunsHandler(w, r)
+		},
+	},
+	{
+		Endpoint: openapi.Endpoint{
+			Method:      http.MethodGet,
+			Path:        apiPrefix + "runs/{id}",
+			OperationID: "getRun",
+			Summary:     "Get a check run by its run UUID",
+			Response:    runhistory.Run{},
+		},
+		handler: func(k *Kuberhealthy, w http.ResponseWriter, r *http.Request, params map[string]string) error {
+			return k.getRunHandler(w, r, params["id"])
+		},
+	},
+	{
+		Endpoint: openapi.Endpoint{
+			Method:      http.MethodGet,
+			Path:        apiPrefix + "usage",
+			OperationID: "listUsage",
+			Summary:     "List the CPU and memory reserved by the checker pods of each check since Kuberhealthy started",
+			Query: []openapi.Parameter{
+				stringParameter("namespace", "only list the usage of checks in this namespace"),
+				stringParameter("check", "only list the usage of checks with this name"),
+			},
+			Response: []usage.CheckUsage{},
+		},
+		handler: func(k *Kuberhealthy, w http.ResponseWriter, r *http.Request, params map[string]string) error {
+			return k.listUsageHandler(w, r)
+		},
+	},
+}
+
+// openAPIDocument describes the versioned API
+func openAPIDocument() *openapi.Document {
+	d := openapi.NewDocument("Kuberhealthy", "v1", "Drive and inspect Kuberhealthy checks.  Every endpoint requires the configured API token as a bearer token.")
+	d.RequireBearerAuth()
+	for _, route := range apiRoutes {
+		d.Add(route.Endpoint)
+	}
+	return d
+}
+
+// openAPIHandler serves the OpenAPI specification of the versioned API.  The specification is served
+// without authentication so that clients can be generated from it.
+func openAPIHandler(w http.ResponseWriter, r *http.Request) error {
+	log.Infoln("Client connected to OpenAPI specification from", r.RemoteAddr, r.UserAgent())
+	if r.Method != http.MethodGet {
+		return writeAPIError(w, http.StatusMethodNotAllowed, "the specification must be fetched with GET")
+	}
+	return writeAPIResponse(w, http.StatusOK, openAPIDocument())
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// apiCheckTimeout is how long the readiness endpoint waits for the Kubernetes API to respond
+const apiCheckTimeout = time.Second * 3
+
+// selfHealth is the response of the liveness and readiness endpoints of Kuberhealthy itself
+type selfHealth struct {
+	OK     bool
+	Errors []string
+}
+
+// livenessHandler serves the liveness of Kuberhealthy.  Kuberhealthy is not live when the goroutine running
+// any of its checks has stopped making progress, so that a wedged instance is restarted by its probe.
+func (k *Kuberhealthy) livenessHandler(w http.ResponseWriter, r *http.Request) error {
+	return writeSelfHealth(w, k.livenessErrors())
+}
+
+// readinessHandler serves the readiness of Kuberhealthy.  Kuberhealthy is ready when it is live, the
+// Kubernetes API can be reached, and the khstate reflector and khcheck informer have synced.
+func (k *Kuberhealthy) readinessHandler(w http.ResponseWriter, r *http.Request) error {
+	errs := k.livenessErrors()
+	if !k.stateReflector.HasSynced() {
+		errs = append(errs, "khstate reflector has not synced")
+	}
+	if checkInformer != nil && !checkInformer.HasSynced() {
+		errs = append(errs, "khcheck informer has not synced")
+	}
+	err := apiReachable(apiCheckTimeout)
+	if err != nil {
+		errs = append(errs, "unable to reach the Kubernetes API: "+err.Error())
+	}
+	return writeSelfHealth(w, errs)
+}
+
+// livenessErrors returns an error for each check whose goroutine has missed its heartbeat
+func (k *Kuberhealthy) livenessErrors() []string {
+	var errs []string
+	for _, key := range k.heartbeats.Missed(time.Now()) {
+		errs = append(errs, "check "+key+" has stopped making progress")
+	}
+	return errs
+}
+
+// apiReachable returns an error if the Kubernetes API does not respond within the timeout
+func apiReachable(timeout time.Duration) error {
+	if kubernetesClient == nil {
+		return errors.New("no Kubernetes client configured")
+	}
+	errChan := make(chan error, 1)
+	go func() {
+		_, err := kubernetesClient.Discovery().ServerVersion()
+		errChan <- err
+	}()
+	select {
+	case err := <-errChan:
+		return err
+	case <-time.After(timeout):
+		return errors.New("timed out after " + timeout.String())
+	}
+}
+
+// writeSelfHealth writes the supplied errors as a selfHealth response.  The response is a 503 when there
+// are any errors.
+func writeSelfHealth(w http.ResponseWriter, errs []string) error {
+	status := selfHealth{
+		OK:     len(errs) == 0,
+		Errors: errs,
+	}
+	if status.Errors == nil {
+		status.Errors = []string{}
+	}
+	b, err := json.Marshal(status)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return err
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if !status.OK {
+		log.Warningln("Kuberhealthy is not healthy:", errs)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	_, err = w.Write(b)
+	return err
+}
@@ -0,0 +1,188 @@
+// Copyright 2018 Comcast Cable Communications Management, LLC
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//     http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"sort"
+	"sync"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/Comcast/kuberhealthy/v2/pkg/runhistory"
+	"github.com/Comcast/kuberhealthy/v2/pkg/sharding"
+	"github.com/Comcast/kuberhealthy/v2/pkg/usage"
+)
+
+// shards tracks the Kuberhealthy pods that checks are split across when sharding is enabled
+var shards = &shardTracker{}
+
+// shardTracker holds the ring that checks are assigned to Kuberhealthy pods with.  The pod watcher records
+// the running pods as they come and go, and the master monitor adopts them into the ring once they settle
+// so that checks are not shuffled around while pods are still starting.
+type shardTracker struct {
+	mu       sync.RWMutex
+	ring     *sharding.Ring // the settled ring that checks are assigned with
+	upcoming []string       // the running pods seen most recently by the pod watcher
+}
+
+// setUpcoming records the running pods that the ring will be made of once they settle
+func (s *shardTracker) setUpcoming(members []string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.upcoming = members
+}
+
+// settle adopts the upcoming pods into the ring and returns true if the members of the ring changed
+func (s *shardTracker) settle() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.upcoming) == 0 {
+		return false
+	}
+	ring := sharding.NewRing(s.upcoming, sharding.DefaultReplicas)
+	if ring.Equal(s.ring) {
+		return false
+	}
+	log.Infoln("shards: checks are now split across", len(s.upcoming), "kuberhealthy pods:", s.upcoming)
+	s.ring = ring
+	return true
+}
+
+// owner returns the pod that runs a check, or a blank string before any pods are known
+func (s *shardTracker) owner(namespace string, name string) string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.ring == nil {
+		return ""
+	}
+	return s.ring.Owner(checkKey(namespace, name))
+}
+
+// members returns the pods of the ring
+func (s *shardTracker) members() []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.ring == nil {
+		return nil
+	}
+	return s.ring.Members()
+}
+
+// ownsCheck returns true if this instance should run a check.  Without sharding, the master runs every check.
+func ownsCheck(namespace string, name string) bool {
+	if !shardChecks {
+		return true
+	}
+	return shards.owner(namespace, name) == podHostname
+}
+
+// ownedChecksChanged returns true if the khchecks that this instance owns differ from the checks it is running
+func (k *Kuberhealthy) ownedChecksChanged() (bool, error) {
+	l, err := listChecks()
+	if err != nil {
+		return false, err
+	}
+	owned := make(map[string]bool)
+	for _, r := range l.Items {
+		if ownsCheck(r.Namespace, r.Name) {
+			owned[checkKey(r.Namespace, r.Name)] = true
+		}
+	}
+	if len(owned) != len(k.Checks) {
+		return true, nil
+	}
+	for _, c := range k.Checks {
+		if !owned[checkKey(c.CheckNamespace(), c.Name())] {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// allRuns returns the runs remembered by this instance along with, when checks are sharded, the runs
+// remembered by every other Kuberhealthy pod.  Runs are sorted from oldest to newest by when they started.
+func (k *Kuberhealthy) allRuns(r *http.Request) []runhistory.Run {
+	runs := k.runHistory.List()
+	if !shardChecks || len(r.Header.Get(forwardedHeader)) > 0 {
+		return runs
+	}
+
+	for _, pod := range shards.members() {
+		if pod == podHostname {
+			continue
+		}
+		var peerRuns []runhistory.Run
+		_, err := k.peerGet(r, pod, apiPrefix+"runs", &peerRuns)
+		if err != nil {
+			log.Errorln("shards: failed to fetch the runs of kuberhealthy pod", pod+":", err)
+			continue
+		}
+		runs = append(runs, peerRuns...)
+	}
+
+	// runs that have not started yet are the newest
+	sort.SliceStable(runs, func(i, j int) bool {
+		if runs[i].Started == nil || runs[j].Started == nil {
+			return runs[j].Started == nil && runs[i].Started != nil
+		}
+		return runs[i].Started.Before(*runs[j].Started)
+	})
+	return runs
+}
+
+// allUsage returns the usage recorded by this instance along with, when checks are sharded, the usage recorded
+// by every other Kuberhealthy pod.  Usage of a check that moved between pods is added up.
+func (k *Kuberhealthy) allUsage(r *http.Request) []usage.CheckUsage {
+	checks := k.usage.List()
+	if !shardChecks || len(r.Header.Get(forwardedHeader)) > 0 {
+		return checks
+	}
+
+	for _, pod := range shards.members() {
+		if pod == podHostname {
+			continue
+		}
+		var peerUsage []usage.CheckUsage
+		_, err := k.peerGet(r, pod, apiPrefix+"usage", &peerUsage)
+		if err != nil {
+			log.Errorln("shards: failed to fetch the check usage of kuberhealthy pod", pod+":", err)
+			continue
+		}
+		checks = append(checks, peerUsage...)
+	}
+	return usage.Merge(checks)
+}
+
+// findRun returns a run remembered by this instance or, when checks are sharded, by another Kuberhealthy pod
+func (k *Kuberhealthy) findRun(r *http.Request, runID string) (runhistory.Run, bool) {
+	run, ok := k.runHistory.Get(runID)
+	if ok || !shardChecks || len(r.Header.Get(forwardedHeader)) > 0 {
+		return run, ok
+	}
+
+	for _, pod := range shards.members() {
+		if pod == podHostname {
+			continue
+		}
+		code, err := k.peerGet(r, pod, apiPrefix+"runs/"+url.PathEscape(runID), &run)
+		if err != nil {
+			log.Errorln("shards: failed to fetch run", runID, "from kuberhealthy pod", pod+":", err)
+			continue
+		}
+		if code == http.StatusOK {
+			return run, true
+		}
+	}
+	return runhistory.Run{}, false
+}
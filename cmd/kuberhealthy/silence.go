@@ -0,0 +1,233 @@
+// Copyright 2018 Comcast Cable Communications Management, LLC
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//     http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/Comcast/kuberhealthy/v2/pkg/apiclient"
+	"github.com/Comcast/kuberhealthy/v2/pkg/health"
+	"github.com/Comcast/kuberhealthy/v2/pkg/khsilencecrd"
+)
+
+// ErrSilenceNotFound is returned when a check that is not silenced is unsilenced
+var ErrSilenceNotFound = errors.New("check is not silenced")
+
+// silenceCache holds the active silences of checks, keyed by namespace/check.  It is refreshed from the
+// khsilence resources in the background on every Kuberhealthy instance so that the status page of each
+// instance knows which checks are silenced.
+type silenceCache struct {
+	mu     sync.RWMutex
+	active map[string]health.Silence
+}
+
+// silences are the active silences of checks
+var silences = &silenceCache{active: make(map[string]health.Silence)}
+
+// Get returns the silence of a check, or nil if the check is not silenced
+func (sc *silenceCache) Get(namespace string, name string) *health.Silence {
+	sc.mu.RLock()
+	defer sc.mu.RUnlock()
+	s, ok := sc.active[namespace+"/"+name]
+	if !ok || !time.Now().Before(s.Expires) {
+		return nil
+	}
+	return &s
+}
+
+// List returns every active silence, keyed by namespace/check
+func (sc *silenceCache) List() map[string]health.Silence {
+	sc.mu.RLock()
+	defer sc.mu.RUnlock()
+	now := time.Now()
+	active := make(map[string]health.Silence, len(sc.active))
+	for key, s := range sc.active {
+		if now.Before(s.Expires) {
+			active[key] = s
+		}
+	}
+	return active
+}
+
+// set replaces the active silences and returns the keys of the checks that are no longer silenced
+func (sc *silenceCache) set(active map[string]health.Silence) []string {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	var lifted []string
+	for key := range sc.active {
+		if _, ok := active[key]; !ok {
+			lifted = append(lifted, key)
+		}
+	}
+	sc.active = active
+	return lifted
+}
+
+// silenceWatcher refreshes the silence cache from the khsilence resources until the context is canceled
+func (k *Kuberhealthy) silenceWatcher(ctx context.Context) {
+	ticker := time.NewTicker(silenceRefreshInterval)
+	defer ticker.Stop()
+	for {
+		err := k.refreshSilences()
+		if err != nil {
+			log.Errorln("silences: failed to refresh silences:", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			log.Infoln("silences: stopping")
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// refreshSilences lists the khsilence resources and updates the silence cache.  The master also deletes
+// expired silences and notifies the checks that are still failing once their silence is lifted.
+func (k *Kuberhealthy) refreshSilences() error {
+	l, err := listSilences()
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	if isMaster {
+		for _, s := range l.Items {
+			if s.Active(now) {
+				continue
+			}
+			log.Infoln("silences: deleting expired silence", s.Name, "of check", s.Spec.Check, "in namespace", s.Namespace)
+			err := khSilenceClient.Delete(silenceCRDResource, s.Name, s.Namespace)
+			if err != nil {
+				log.Errorln("silences: failed to delete expired silence", s.Name, "in namespace", s.Namespace+":", err)
+			}
+		}
+	}
+
+	lifted := silences.set(khsilencecrd.ActiveSilences(l.Items, now))
+	if !isMaster {
+		return nil
+	}
+	for _, key := range lifted {
+		details, ok := k.stateReflector.CurrentStatus().CheckDetails[key]
+		if !ok || details.OK {
+			continue
+		}
+		k.notifyStateChange(checkNameFromKey(key, details.Namespace), details.Namespace, details)
+	}
+	return nil
+}
+
+// checkNameFromKey returns the name of a check from its namespace/check key
+func checkNameFromKey(key string, namespace string) string {
+	return key[len(namespace)+1:]
+}
+
+// silenceCheck creates a khsilence resource that silences a check for the requested duration
+func (k *Kuberhealthy) silenceCheck(namespace string, name string, duration time.Duration, req apiclient.SilenceRequest) (health.Silence, error) {
+	if _, err := k.getCheck(name, namespace); err != nil {
+		return health.Silence{}, ErrCheckNotFound
+	}
+
+	spec := khsilencecrd.SilenceSpec{
+		Check:   name,
+		Reason:  req.Reason,
+		Author:  req.Author,
+		Expires: time.Now().Add(duration),
+	}
+
+	// the API server names each silence so that silences created in the same second do not collide
+	silence := khsilencecrd.NewKuberhealthySilence("", namespace, spec)
+	silence.GenerateName = name + "-"
+	created, err := khSilenceClient.Create(&silence, silenceCRDResource, namespace)
+	if err != nil {
+		return health.Silence{}, err
+	}
+	log.Infoln("Check", name, "in namespace", namespace, "silenced by", req.Author, "until", spec.Expires, "because:", req.Reason)
+
+	err = k.refreshSilences()
+	if err != nil {
+		log.Errorln("silences: failed to refresh silences:", err)
+	}
+	return health.Silence{Name: created.Name, Reason: spec.Reason, Author: spec.Author, Expires: spec.Expires}, nil
+}
+
+// unsilenceCheck deletes every khsilence resource of a check
+func (k *Kuberhealthy) unsilenceCheck(namespace string, name string) error {
+	l, err := khSilenceClient.List(metav1.ListOptions{}, silenceCRDResource, namespace)
+	if err != nil {
+		return err
+	}
+	found := false
+	for _, s := range l.Items {
+		if s.Spec.Check != name {
+			continue
+		}
+		found = true
+		err := khSilenceClient.Delete(silenceCRDResource, s.Name, s.Namespace)
+		if err != nil {
+			return err
+		}
+	}
+	if !found {
+		return ErrSilenceNotFound
+	}
+	log.Infoln("Check", name, "in namespace", namespace, "unsilenced")
+	return k.refreshSilences()
+}
+
+// createSilenceHandler silences a check for the duration in the request
+func (k *Kuberhealthy) createSilenceHandler(w http.ResponseWriter, r *http.Request, namespace string, name string) error {
+	var req apiclient.SilenceRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		return writeAPIError(w, http.StatusBadRequest, "unable to decode silence request: "+err.Error())
+	}
+	duration, err := time.ParseDuration(req.Duration)
+	if err != nil || duration <= 0 {
+		return writeAPIError(w, http.StatusBadRequest, "silences require a positive duration, such as 2h")
+	}
+	if len(req.Reason) == 0 || len(req.Author) == 0 {
+		return writeAPIError(w, http.StatusBadRequest, "silences require a reason and an author")
+	}
+	silence, err := k.silenceCheck(namespace, name, duration, req)
+	switch err {
+	case nil:
+	case ErrCheckNotFound:
+		return writeAPIError(w, http.StatusNotFound, "no check named "+name+" is running in namespace "+namespace)
+	default:
+		return writeAPIError(w, http.StatusInternalServerError, err.Error())
+	}
+	return writeAPIResponse(w, http.StatusCreated, silence)
+}
+
+// deleteSilenceHandler lifts every silence of a check
+func (k *Kuberhealthy) deleteSilenceHandler(w http.ResponseWriter, namespace string, name string) error {
+	err := k.unsilenceCheck(namespace, name)
+	switch err {
+	case nil:
+	case ErrSilenceNotFound:
+		return writeAPIError(w, http.StatusNotFound, "check "+name+" in namespace "+namespace+" is not silenced")
+	default:
+		return writeAPIError(w, http.StatusInternalServerError, err.Error())
+	}
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
@@ -0,0 +1,133 @@
+// Copyright 2018 Comcast Cable Communications Management, LLC
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//     http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/watch"
+
+	"github.com/Comcast/kuberhealthy/v2/pkg/khcheckcrd"
+	"github.com/Comcast/kuberhealthy/v2/pkg/khchecktemplatecrd"
+)
+
+// listExpandedChecks lists every khcheck with the khchecktemplate it references expanded into its spec.  Checks
+// whose template can not be expanded are returned as they are, and the reason is returned keyed by the
+// namespace/name of the check.
+func listExpandedChecks() (*khcheckcrd.KuberhealthyCheckList, map[string]error, error) {
+	l, err := listChecks()
+	if err != nil {
+		return l, nil, err
+	}
+
+	// only list templates when a check uses one
+	var usesTemplates bool
+	for _, c := range l.Items {
+		if c.Spec.Template != nil {
+			usesTemplates = true
+			break
+		}
+	}
+	if !usesTemplates {
+		return l, nil, nil
+	}
+
+	// khchecktemplates are cluster scoped, so checks created from them can not run in namespaced mode
+	if namespacedMode {
+		expandErrors := make(map[string]error)
+		for _, c := range l.Items {
+			if c.Spec.Template != nil {
+				expandErrors[checkKey(c.Namespace, c.Name)] = fmt.Errorf("khchecktemplate %s can not be used: khchecktemplates are cluster scoped and %w", c.Spec.Template.Name, errNamespacedMode)
+			}
+		}
+		return l, expandErrors, nil
+	}
+
+	templateList, err := khCheckTemplateClient.List(metav1.ListOptions{}, checkTemplateCRDResource)
+	if err != nil {
+		return l, nil, fmt.Errorf("error listing khchecktemplates: %w", err)
+	}
+	templates := make(map[string]khchecktemplatecrd.KuberhealthyCheckTemplate)
+	for _, t := range templateList.Items {
+		templates[t.Name] = t
+	}
+
+	expandErrors := make(map[string]error)
+	for i, c := range l.Items {
+		if c.Spec.Template == nil {
+			continue
+		}
+		t, ok := templates[c.Spec.Template.Name]
+		if !ok {
+			expandErrors[checkKey(c.Namespace, c.Name)] = fmt.Errorf("khchecktemplate %s does not exist", c.Spec.Template.Name)
+			continue
+		}
+		expanded, err := khchecktemplatecrd.Expand(c, t)
+		if err != nil {
+			expandErrors[checkKey(c.Namespace, c.Name)] = err
+			continue
+		}
+		l.Items[i] = expanded
+	}
+	return l, expandErrors, nil
+}
+
+// expandCheck returns a khcheck with the khchecktemplate it references expanded into its spec.  Checks that do
+// not reference a template are returned as they are.
+func expandCheck(c *khcheckcrd.KuberhealthyCheck) (*khcheckcrd.KuberhealthyCheck, error) {
+	if c.Spec.Template == nil {
+		return c, nil
+	}
+	if namespacedMode {
+		return c, fmt.Errorf("khchecktemplate %s can not be used: khchecktemplates are cluster scoped and %w", c.Spec.Template.Name, errNamespacedMode)
+	}
+	t, err := khCheckTemplateClient.Get(metav1.GetOptions{}, checkTemplateCRDResource, c.Spec.Template.Name)
+	if err != nil {
+		return c, fmt.Errorf("error fetching khchecktemplate %s: %w", c.Spec.Template.Name, err)
+	}
+	expanded, err := khchecktemplatecrd.Expand(*c, *t)
+	return &expanded, err
+}
+
+// watchForKHCheckTemplateChanges signals the specified channel whenever a khchecktemplate changes, so that the
+// khchecks created from it are scanned for changes
+func (k *Kuberhealthy) watchForKHCheckTemplateChanges(c chan struct{}) {
+
+	log.Debugln("Spawned watcher for KH check template changes")
+
+	for {
+		// wait a second so we don't retry too quickly on error
+		time.Sleep(time.Second)
+
+		watcher, err := khCheckTemplateClient.Watch(metav1.ListOptions{}, checkTemplateCRDResource)
+		if err != nil {
+			log.Errorln("error watching khchecktemplate objects:", err)
+			continue
+		}
+
+		for e := range watcher.ResultChan() {
+			switch e.Type {
+			case watch.Added, watch.Modified, watch.Deleted:
+				log.Debugln("khchecktemplate monitor saw a", e.Type, "event")
+				c <- struct{}{}
+			case watch.Error:
+				log.Errorln("Error when watching for khchecktemplate changes:", e.Object)
+			default:
+				log.Warningln("khchecktemplate monitor saw an unknown event type and ignored it:", e.Type)
+			}
+		}
+		watcher.Stop()
+	}
+}
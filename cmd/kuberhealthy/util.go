@@ -18,6 +18,7 @@ import (
 	"time"
 
 	log "github.com/sirupsen/logrus"
+	v1 "k8s.io/api/core/v1"
 )
 
 // getEnvVar attempts to retrieve and then validates an environmental variable
@@ -39,6 +40,100 @@ func getAllLogLevel() string {
 	return strings.Join(levelStrings, ",")
 }
 
+// getLogFormat returns the log format set by environment variable, or text if none is set
+func getLogFormat() string {
+	format := os.Getenv(KHLogFormat)
+	if len(format) == 0 {
+		return "text"
+	}
+	return format
+}
+
+// logFormatter returns the logrus formatter for the supplied log format
+func logFormatter(format string) (log.Formatter, error) {
+	switch strings.ToLower(format) {
+	case "text", "":
+		return &log.TextFormatter{}, nil
+	case "json":
+		return &log.JSONFormatter{}, nil
+	default:
+		return nil, errors.New("unknown log format " + format + ".  Use text or json")
+	}
+}
+
+// parseCommaList parses a comma separated list, skipping blank entries
+func parseCommaList(s string) []string {
+	var list []string
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if len(part) > 0 {
+			list = append(list, part)
+		}
+	}
+	return list
+}
+
+// parseKeyValuePairs parses a comma separated list of key=value pairs.  Values may contain commas, such as
+// "config.linkerd.io/skip-outbound-ports=443,8443", because anything after a comma that is not followed by a
+// new key=value pair is kept as part of the previous value.
+func parseKeyValuePairs(s string) (map[string]string, error) {
+	pairs := make(map[string]string)
+	var lastKey string
+	for _, part := range strings.Split(s, ",") {
+		if len(strings.TrimSpace(part)) == 0 {
+			continue
+		}
+		kv := strings.SplitN(part, "=", 2)
+		if len(kv) == 1 {
+			if len(lastKey) == 0 {
+				return nil, errors.New("expected key=value but got " + part)
+			}
+			pairs[lastKey] = pairs[lastKey] + "," + part
+			continue
+		}
+		lastKey = strings.TrimSpace(kv[0])
+		if len(lastKey) == 0 {
+			return nil, errors.New("blank key in " + part)
+		}
+		pairs[lastKey] = strings.TrimSpace(kv[1])
+	}
+	return pairs, nil
+}
+
+// parseTolerations parses a comma separated list of tolerations in the form key=value:Effect.  The value
+// can be left off to tolerate any value of the key, and the effect can be left off to tolerate all effects.
+func parseTolerations(s string) ([]v1.Toleration, error) {
+	var tolerations []v1.Toleration
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if len(part) == 0 {
+			continue
+		}
+		t := v1.Toleration{Operator: v1.TolerationOpExists}
+		keyValue := part
+		if i := strings.LastIndex(part, ":"); i >= 0 {
+			keyValue = part[:i]
+			t.Effect = v1.TaintEffect(part[i+1:])
+			switch t.Effect {
+			case v1.TaintEffectNoSchedule, v1.TaintEffectPreferNoSchedule, v1.TaintEffectNoExecute:
+			default:
+				return nil, errors.New("unknown taint effect " + string(t.Effect) + " in toleration " + part)
+			}
+		}
+		kv := strings.SplitN(keyValue, "=", 2)
+		t.Key = kv[0]
+		if len(t.Key) == 0 {
+			return nil, errors.New("blank key in toleration " + part)
+		}
+		if len(kv) == 2 {
+			t.Operator = v1.TolerationOpEqual
+			t.Value = kv[1]
+		}
+		tolerations = append(tolerations, t)
+	}
+	return tolerations, nil
+}
+
 // notifyChanLimiter takes in a chan used for notifications and smooths it out to at most
 // one single notification every 10 seconds.  This will continuously empty whatever the inChan
 // channel fed to it is.  Useful for controlling noisy upstream channel spam. Also smooths notifications
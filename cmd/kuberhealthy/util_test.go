@@ -12,6 +12,7 @@ import (
 
 	"github.com/Comcast/kuberhealthy/v2/pkg/checks/external"
 	"github.com/Comcast/kuberhealthy/v2/pkg/khcheckcrd"
+	"github.com/Comcast/kuberhealthy/v2/pkg/statestore"
 )
 
 const defaultNamespace = "kuberhealthy"
@@ -31,7 +32,7 @@ func newExternalTestCheck(c *kubernetes.Clientset) (*external.Checker, error) {
 // spec file for pods
 func newTestCheckFromSpec(c *kubernetes.Clientset, spec *khcheckcrd.KuberhealthyCheck) *external.Checker {
 	// create a new checker and insert this pod spec
-	checker := external.New(c, spec) // external checker does not ever return an error so we drop it
+	checker := external.New(c, spec, nil, statestore.NewMemoryStore(), "") // external checker does not ever return an error so we drop it
 	checker.Debug = true
 	return checker
 }
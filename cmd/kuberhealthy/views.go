@@ -0,0 +1,112 @@
+// Copyright 2018 Comcast Cable Communications Management, LLC
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//     http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"errors"
+	"net/url"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/labels"
+
+	"github.com/Comcast/kuberhealthy/v2/pkg/health"
+)
+
+// statusViewPrefix is the path that pre-configured status page views are served under
+const statusViewPrefix = "/status/"
+
+// errViewNotFound is returned when the status of a view that is not configured is requested
+type errViewNotFound string
+
+// Error returns the message of the error
+func (e errViewNotFound) Error() string {
+	return "no status page view named " + string(e)
+}
+
+// parseStatusViews parses a semicolon separated list of name=selector pairs, where each selector is a
+// Kubernetes label selector such as team=payments or tier in (frontend,backend)
+func parseStatusViews(s string) (map[string]labels.Selector, error) {
+	views := make(map[string]labels.Selector)
+	for _, part := range strings.Split(s, ";") {
+		if len(strings.TrimSpace(part)) == 0 {
+			continue
+		}
+		kv := strings.SplitN(part, "=", 2)
+		name := strings.TrimSpace(kv[0])
+		if len(kv) == 1 || len(name) == 0 || strings.Contains(name, "/") {
+			return nil, errors.New("expected name=selector but got " + part)
+		}
+		selector, err := labels.Parse(strings.TrimSpace(kv[1]))
+		if err != nil {
+			return nil, errors.New("invalid label selector for view " + name + ": " + err.Error())
+		}
+		views[name] = selector
+	}
+	return views, nil
+}
+
+// filterStateByLabels returns the checks of a state whose labels match every supplied selector, with the
+// overall status rolled up from only those checks
+func filterStateByLabels(state health.State, selectors []labels.Selector, policy health.RollupPolicy) health.State {
+	filtered := state.Filter(func(d health.CheckDetails) bool {
+		for _, selector := range selectors {
+			if !selector.Matches(labels.Set(d.Labels)) {
+				return false
+			}
+		}
+		return true
+	})
+	policy.Apply(&filtered)
+	return filtered
+}
+
+// queryState returns the current state of the checks selected by the namespace and selector query
+// parameters and by the named view, if any.  The namespace parameter is a comma separated list.
+func (k *Kuberhealthy) queryState(values url.Values, view string) (health.State, error) {
+	// .Get() will return an "" if there is no value associated -- we do not want to pass "" as a requested namespace
+	var namespaces []string
+	for _, namespace := range strings.Split(values.Get("namespace"), ",") {
+		// a query like (/?namespace=,) will cause .Split() to return an array of two empty strings ["", ""]
+		// so we need to filter those out
+		if len(namespace) != 0 {
+			namespaces = append(namespaces, namespace)
+		}
+	}
+
+	// checks can be selected by their labels with a selector in the query or a pre-configured view
+	var selectors []labels.Selector
+	if len(view) != 0 {
+		selector, ok := statusViews[view]
+		if !ok {
+			return health.State{}, errViewNotFound(view)
+		}
+		selectors = append(selectors, selector)
+	}
+	if selectorValue := values.Get("selector"); len(selectorValue) != 0 {
+		selector, err := labels.Parse(selectorValue)
+		if err != nil {
+			return health.State{}, errors.New("invalid label selector: " + err.Error())
+		}
+		selectors = append(selectors, selector)
+	}
+
+	// fetch the current status from our khstate resources
+	state := k.getCurrentState(namespaces)
+	if len(selectors) > 0 {
+		policy := rollupPolicy
+		if len(namespaces) != 0 {
+			policy.ExcludeNamespaces = nil
+		}
+		state = filterStateByLabels(state, selectors, policy)
+	}
+	return state, nil
+}
@@ -13,6 +13,7 @@ package main
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"io/ioutil"
 	"net/http"
@@ -60,7 +61,7 @@ func TestWebServer(t *testing.T) {
 	kh.AddCheck(fc)
 
 	t.Log("Starting Kuberhealthy checks")
-	go kh.Start()
+	go kh.Start(context.Background())
 	// give the checker time to make CRDs
 	t.Log("Waiting for checks to run")
 	time.Sleep(time.Second * 2)
@@ -110,7 +111,7 @@ func TestWebServerNotOK(t *testing.T) {
 	kh.AddCheck(fc)
 
 	// run the checker for enough time to make and update CRD entries, then stop it
-	go kh.Start()
+	go kh.Start(context.Background())
 	time.Sleep(time.Second * 5)
 	kh.StopChecks()
 
@@ -3,7 +3,7 @@
 package main
 
 import (
-	checkclient "github.com/Comcast/kuberhealthy/v2/pkg/checks/external/checkClient"
+	checkclient "github.com/Comcast/kuberhealthy/v2/pkg/checkclient"
 	"github.com/Comcast/kuberhealthy/v2/pkg/kubeClient"
 	"os"
 	"path/filepath"
@@ -0,0 +1,210 @@
+// Package alertmanager sends failing checks to a Prometheus Alertmanager as alerts and resolves them when the
+// checks recover, so that failing checks can page without scraping Kuberhealthy and writing alert rules.
+package alertmanager
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/Comcast/kuberhealthy/v2/pkg/health"
+	"github.com/Comcast/kuberhealthy/v2/pkg/proxy"
+)
+
+// AlertsPath is the Alertmanager API endpoint that alerts are posted to
+const AlertsPath = "/api/v2/alerts"
+
+// AlertName is the alertname label of every alert sent for a failing check
+const AlertName = "KuberhealthyCheckFailed"
+
+// DefaultSeverity is the severity label of alerts when none is configured
+const DefaultSeverity = "critical"
+
+// DefaultInterval is how often firing alerts are sent to Alertmanager when no interval is configured
+var DefaultInterval = time.Minute
+
+// resendFactor is how many intervals a firing alert stays active in Alertmanager without being sent again.
+// This lets alerts expire on their own if Kuberhealthy stops sending them.
+const resendFactor = 4
+
+// Alert is an alert in the format of the Alertmanager v2 API
+type Alert struct {
+	Labels      map[string]string `json:"labels"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+	StartsAt    time.Time         `json:"startsAt,omitempty"`
+	EndsAt      time.Time         `json:"endsAt,omitempty"`
+}
+
+// Notifier sends an alert to Alertmanager for every failing check on an interval and resolves the alerts of
+// checks that recover or are removed
+type Notifier struct {
+	URL      string            // the base URL of Alertmanager
+	Severity string            // the severity label of alerts
+	Labels   map[string]string // extra labels added to every alert, such as the name of the cluster
+	Interval time.Duration     // how often firing alerts are sent
+
+	client *http.Client
+	mu     sync.Mutex
+	firing map[string]time.Time // the checks with firing alerts and when each started failing
+}
+
+// New creates a notifier that sends alerts to the Alertmanager at the supplied URL
+func New(url string) (*Notifier, error) {
+	if len(url) == 0 {
+		return nil, errors.New("alertmanager url was blank")
+	}
+	return &Notifier{
+		URL:      strings.TrimSuffix(url, "/"),
+		Severity: DefaultSeverity,
+		Interval: DefaultInterval,
+		client:   &http.Client{},
+		firing:   make(map[string]time.Time),
+	}, nil
+}
+
+// SetProxy sends alerts through the proxy at proxyURL, or directly when it is proxy.Direct.  Hosts in the comma
+// separated noProxy list are reached directly.  Alerts are sent through the proxy environment variables until
+// a proxy is set.
+func (n *Notifier) SetProxy(proxyURL string, noProxy string) error {
+	transport, err := proxy.Transport(proxyURL, noProxy)
+	if err != nil {
+		return err
+	}
+	n.client = &http.Client{Transport: transport}
+	return nil
+}
+
+// Run sends the alerts for the status returned by currentState on the notifier's interval until the context
+// is canceled
+func (n *Notifier) Run(ctx context.Context, currentState func() health.State) {
+	ticker := time.NewTicker(n.Interval)
+	defer ticker.Stop()
+	for {
+		notifyCtx, cancel := context.WithTimeout(ctx, n.Interval)
+		err := n.Notify(notifyCtx, currentState())
+		cancel()
+		if err != nil {
+			log.Warningln("alertmanager: failed to send alerts:", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			log.Infoln("alertmanager: shutting down from context abort")
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Notify sends an alert for each failing check in the supplied status, along with resolved alerts for the
+// checks that were failing the last time alerts were sent and no longer are
+func (n *Notifier) Notify(ctx context.Context, state health.State) error {
+	alerts, firing := n.alerts(state, time.Now())
+	if len(alerts) == 0 {
+		return nil
+	}
+
+	body, err := json.Marshal(alerts)
+	if err != nil {
+		return fmt.Errorf("error marshaling alerts: %w", err)
+	}
+	req, err := http.NewRequest(http.MethodPost, n.URL+AlertsPath, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("unexpected status code %d from %s", resp.StatusCode, n.URL+AlertsPath)
+	}
+
+	// checks are only forgotten once Alertmanager has accepted their resolved alerts
+	n.mu.Lock()
+	n.firing = firing
+	n.mu.Unlock()
+	return nil
+}
+
+// alerts returns the alerts to send for the supplied status, sorted by check, along with the checks that
+// are firing after they are sent
+func (n *Notifier) alerts(state health.State, now time.Time) ([]Alert, map[string]time.Time) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	firing := make(map[string]time.Time)
+	var alerts []Alert
+	for key, d := range state.CheckDetails {
+		if d.OK || d.Silence != nil {
+			continue
+		}
+		started, ok := n.firing[key]
+		if !ok {
+			started = now
+		}
+		firing[key] = started
+		alert := n.alert(key, d.Namespace)
+		if len(d.ErrorCategory) > 0 {
+			alert.Labels["category"] = d.ErrorCategory
+		}
+		alert.Annotations = map[string]string{
+			"summary":     "Kuberhealthy check " + key + " is failing",
+			"description": strings.Join(d.Errors, "\n"),
+		}
+		alert.StartsAt = started
+		alert.EndsAt = now.Add(n.Interval * resendFactor)
+		alerts = append(alerts, alert)
+	}
+
+	// checks that recovered or were removed have their alerts resolved
+	for key, started := range n.firing {
+		if _, ok := firing[key]; ok {
+			continue
+		}
+		namespace := key
+		if i := strings.Index(key, "/"); i >= 0 {
+			namespace = key[:i]
+		}
+		if d, ok := state.CheckDetails[key]; ok {
+			namespace = d.Namespace
+		}
+		alert := n.alert(key, namespace)
+		alert.Annotations = map[string]string{"summary": "Kuberhealthy check " + key + " has recovered"}
+		alert.StartsAt = started
+		alert.EndsAt = now
+		alerts = append(alerts, alert)
+	}
+
+	sort.Slice(alerts, func(i, j int) bool {
+		return alerts[i].Labels["namespace"]+"/"+alerts[i].Labels["check"] < alerts[j].Labels["namespace"]+"/"+alerts[j].Labels["check"]
+	})
+	return alerts, firing
+}
+
+// alert returns an alert with the labels of the check with the supplied key.  Keys of the status page are
+// in the form namespace/check.
+func (n *Notifier) alert(key string, namespace string) Alert {
+	labels := make(map[string]string, len(n.Labels)+4)
+	for k, v := range n.Labels {
+		labels[k] = v
+	}
+	labels["alertname"] = AlertName
+	labels["check"] = strings.TrimPrefix(key, namespace+"/")
+	labels["namespace"] = namespace
+	labels["severity"] = n.Severity
+	return Alert{Labels: labels}
+}
@@ -0,0 +1,122 @@
+package alertmanager
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Comcast/kuberhealthy/v2/pkg/health"
+)
+
+// TestNotify validates that failing checks are sent as firing alerts and resolved once they recover
+func TestNotify(t *testing.T) {
+	var received [][]Alert
+	status := http.StatusOK
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != AlertsPath || r.Method != http.MethodPost {
+			t.Error("Expected alerts to be posted to", AlertsPath, "but got", r.Method, r.URL.Path)
+		}
+		var alerts []Alert
+		err := json.NewDecoder(r.Body).Decode(&alerts)
+		if err != nil {
+			t.Error(err)
+		}
+		received = append(received, alerts)
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+
+	n, err := New(server.URL + "/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	n.Labels = map[string]string{"cluster": "prod"}
+
+	state := health.NewState()
+	state.CheckDetails["kuberhealthy/dns"] = health.CheckDetails{OK: true, Namespace: "kuberhealthy"}
+	err = n.Notify(context.Background(), state)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(received) != 0 {
+		t.Fatal("Expected nothing to be sent while every check is healthy but got", received)
+	}
+
+	state.CheckDetails["kuberhealthy/deployment"] = health.CheckDetails{OK: false, Namespace: "kuberhealthy", Errors: []string{"deployment did not roll out"}, ErrorCategory: health.ErrorCategoryCheck}
+	err = n.Notify(context.Background(), state)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(received) != 1 || len(received[0]) != 1 {
+		t.Fatal("Expected one firing alert to be sent but got", received)
+	}
+	firing := received[0][0]
+	if firing.Labels["alertname"] != AlertName || firing.Labels["check"] != "deployment" || firing.Labels["namespace"] != "kuberhealthy" || firing.Labels["severity"] != DefaultSeverity || firing.Labels["cluster"] != "prod" || firing.Labels["category"] != health.ErrorCategoryCheck {
+		t.Fatal("Unexpected labels on firing alert:", firing.Labels)
+	}
+	if firing.Annotations["description"] != "deployment did not roll out" || !firing.EndsAt.After(firing.StartsAt) {
+		t.Fatal("Unexpected firing alert:", firing)
+	}
+
+	// resolved alerts are sent again until Alertmanager accepts them
+	state.CheckDetails["kuberhealthy/deployment"] = health.CheckDetails{OK: true, Namespace: "kuberhealthy"}
+	status = http.StatusInternalServerError
+	if n.Notify(context.Background(), state) == nil {
+		t.Fatal("Expected an error when Alertmanager rejects alerts")
+	}
+	status = http.StatusOK
+	err = n.Notify(context.Background(), state)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(received) != 3 || len(received[2]) != 1 {
+		t.Fatal("Expected the resolved alert to be sent again but got", received)
+	}
+	resolved := received[2][0]
+	if resolved.Labels["check"] != "deployment" || !resolved.StartsAt.Equal(firing.StartsAt) || resolved.EndsAt.After(resolved.StartsAt.Add(n.Interval)) {
+		t.Fatal("Unexpected resolved alert:", resolved)
+	}
+
+	// nothing is sent once the alert is resolved
+	err = n.Notify(context.Background(), state)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(received) != 3 {
+		t.Fatal("Expected nothing more to be sent after the alert was resolved but got", received[3:])
+	}
+}
+
+// TestSetProxy validates that alerts are sent through the proxy of the notifier and that invalid proxies are
+// refused
+func TestSetProxy(t *testing.T) {
+	var hosts []string
+	proxyServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hosts = append(hosts, r.Host)
+	}))
+	defer proxyServer.Close()
+
+	n, err := New("http://alertmanager.example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n.SetProxy("alertmanager-proxy:3128", "") == nil {
+		t.Fatal("Expected a proxy without a scheme to be refused")
+	}
+	err = n.SetProxy(proxyServer.URL, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	state := health.NewState()
+	state.CheckDetails["kuberhealthy/deployment"] = health.CheckDetails{OK: false, Namespace: "kuberhealthy", Errors: []string{"deployment did not roll out"}}
+	err = n.Notify(context.Background(), state)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(hosts) != 1 || hosts[0] != "alertmanager.example.com" {
+		t.Fatal("Expected alerts to alertmanager.example.com to be sent through the proxy but got", hosts)
+	}
+}
@@ -0,0 +1,304 @@
+// Copyright 2018 Comcast Cable Communications Management, LLC
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//     http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package apiclient is a client for the versioned Kuberhealthy API.  It is meant for CLIs, CI jobs, and
+// operators that drive or inspect checks from outside of Kuberhealthy, such as a pipeline that triggers a
+// check and waits for its run to finish.
+package apiclient
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/Comcast/kuberhealthy/v2/pkg/health"
+	"github.com/Comcast/kuberhealthy/v2/pkg/runhistory"
+	"github.com/Comcast/kuberhealthy/v2/pkg/usage"
+)
+
+// APIPath is the path that the versioned API is served under
+const APIPath = "/api/v1/"
+
+// TriggerRunResponse is returned when a check run is triggered
+type TriggerRunResponse struct {
+	RunID     string `json:"runID"`
+	Name      string `json:"name"`
+	Namespace string `json:"namespace"`
+}
+
+// SilenceRequest is the body of requests that silence a check
+type SilenceRequest struct {
+	Duration string `json:"duration"` // how long the check is silenced for, such as 2h
+	Reason   string `json:"reason"`   // why the check is silenced, such as a link to the incident
+	Author   string `json:"author"`   // who silenced the check
+}
+
+// StatusOptions select the checks included in a status.  The zero value selects every check.
+type StatusOptions struct {
+	Namespaces []string // only include checks in these namespaces
+	Selector   string   // only include checks whose labels match this label selector
+	View       string   // only include checks in this configured status page view
+}
+
+// Error is returned when the API responds with an error
+type Error struct {
+	StatusCode int    // the HTTP status code of the response
+	Message    string // the error message from the API
+}
+
+// Error returns the message of the error along with its status code
+func (e *Error) Error() string {
+	return fmt.Sprintf("kuberhealthy api returned %d: %s", e.StatusCode, e.Message)
+}
+
+// IsNotFound returns true if the error is an API error reporting that a check, run, or silence was not found
+func IsNotFound(err error) bool {
+	apiErr, ok := err.(*Error)
+	return ok && apiErr.StatusCode == http.StatusNotFound
+}
+
+// Client calls the versioned Kuberhealthy API
+type Client struct {
+	URL        string        // the base URL of Kuberhealthy, such as http://kuberhealthy.kuberhealthy
+	Token      string        // the API token sent as a bearer token
+	Retries    int           // how many times a request that fails with a network or server error is retried
+	RetryDelay time.Duration // how long to wait between retries
+	HTTPClient *http.Client  // the client used to send requests
+}
+
+// NewClient creates a client for the Kuberhealthy at the supplied base URL that authenticates with the
+// supplied API token
+func NewClient(baseURL string, token string) *Client {
+	return &Client{
+		URL:        baseURL,
+		Token:      token,
+		Retries:    3,
+		RetryDelay: time.Second * 2,
+		HTTPClient: &http.Client{Timeout: time.Second * 30},
+	}
+}
+
+// GetStatus returns the overall status and the status of the checks selected by the options
+func (c *Client) GetStatus(ctx context.Context, opts StatusOptions) (health.State, error) {
+	query := url.Values{}
+	if len(opts.Namespaces) > 0 {
+		query.Set("namespace", strings.Join(opts.Namespaces, ","))
+	}
+	if len(opts.Selector) > 0 {
+		query.Set("selector", opts.Selector)
+	}
+	if len(opts.View) > 0 {
+		query.Set("view", opts.View)
+	}
+	var state health.State
+	err := c.do(ctx, http.MethodGet, "status", query, nil, &state)
+	return state, err
+}
+
+// ListChecks returns the status of every check, keyed by namespace/name.  When namespaces or a label selector
+// are supplied, only the matching checks are returned.
+func (c *Client) ListChecks(ctx context.Context, namespaces []string, selector string) (map[string]health.CheckDetails, error) {
+	query := url.Values{}
+	if len(namespaces) > 0 {
+		query.Set("namespace", strings.Join(namespaces, ","))
+	}
+	if len(selector) > 0 {
+		query.Set("selector", selector)
+	}
+	checks := make(map[string]health.CheckDetails)
+	err := c.do(ctx, http.MethodGet, "checks", query, nil, &checks)
+	return checks, err
+}
+
+// GetCheck returns the status of a single check
+func (c *Client) GetCheck(ctx context.Context, namespace string, name string) (health.CheckDetails, error) {
+	var details health.CheckDetails
+	err := c.do(ctx, http.MethodGet, checkPath(namespace, name), nil, nil, &details)
+	return details, err
+}
+
+// TriggerRun queues an immediate run of a check and returns the UUID of the run
+func (c *Client) TriggerRun(ctx context.Context, namespace string, name string) (TriggerRunResponse, error) {
+	var resp TriggerRunResponse
+	err := c.do(ctx, http.MethodPost, checkPath(namespace, name)+"/run", nil, nil, &resp)
+	return resp, err
+}
+
+// GetRun returns the state of a check run by its run UUID
+func (c *Client) GetRun(ctx context.Context, runID string) (runhistory.Run, error) {
+	var run runhistory.Run
+	err := c.do(ctx, http.MethodGet, "runs/"+url.PathEscape(runID), nil, nil, &run)
+	return run, err
+}
+
+// ListRuns returns the recent check runs from oldest to newest.  When a namespace or check name is supplied,
+// only the runs of matching checks are returned.
+func (c *Client) ListRuns(ctx context.Context, namespace string, name string) ([]runhistory.Run, error) {
+	query := url.Values{}
+	if len(namespace) > 0 {
+		query.Set("namespace", namespace)
+	}
+	if len(name) > 0 {
+		query.Set("check", name)
+	}
+	var runs []runhistory.Run
+	err := c.do(ctx, http.MethodGet, "runs", query, nil, &runs)
+	return runs, err
+}
+
+// ListUsage returns the CPU and memory reserved by the checker pods of each check since Kuberhealthy started.
+// When a namespace or check name is supplied, only the usage of matching checks is returned.
+func (c *Client) ListUsage(ctx context.Context, namespace string, name string) ([]usage.CheckUsage, error) {
+	query := url.Values{}
+	if len(namespace) > 0 {
+		query.Set("namespace", namespace)
+	}
+	if len(name) > 0 {
+		query.Set("check", name)
+	}
+	var checks []usage.CheckUsage
+	err := c.do(ctx, http.MethodGet, "usage", query, nil, &checks)
+	return checks, err
+}
+
+// WaitForRun polls a check run on the supplied interval until it is done, and returns its final state
+func (c *Client) WaitForRun(ctx context.Context, runID string, interval time.Duration) (runhistory.Run, error) {
+	for {
+		run, err := c.GetRun(ctx, runID)
+		if err != nil || run.Phase.Done() {
+			return run, err
+		}
+		select {
+		case <-ctx.Done():
+			return run, ctx.Err()
+		case <-time.After(interval):
+		}
+	}
+}
+
+// Silence silences a check and returns the silence that was created
+func (c *Client) Silence(ctx context.Context, namespace string, name string, req SilenceRequest) (health.Silence, error) {
+	var silence health.Silence
+	err := c.do(ctx, http.MethodPost, checkPath(namespace, name)+"/silence", nil, req, &silence)
+	return silence, err
+}
+
+// Unsilence lifts every silence of a check
+func (c *Client) Unsilence(ctx context.Context, namespace string, name string) error {
+	return c.do(ctx, http.MethodDelete, checkPath(namespace, name)+"/silence", nil, nil, nil)
+}
+
+// ListSilences returns the active silences, keyed by namespace/name of the silenced check
+func (c *Client) ListSilences(ctx context.Context) (map[string]health.Silence, error) {
+	silences := make(map[string]health.Silence)
+	err := c.do(ctx, http.MethodGet, "silences", nil, nil, &silences)
+	return silences, err
+}
+
+// checkPath returns the API path of a check
+func checkPath(namespace string, name string) string {
+	return "checks/" + url.PathEscape(namespace) + "/" + url.PathEscape(name)
+}
+
+// do sends a request to an API path and decodes the response into out, retrying requests that fail with a
+// network or server error.  Requests that the API refuses are not retried.
+func (c *Client) do(ctx context.Context, method string, path string, query url.Values, in interface{}, out interface{}) error {
+	if len(c.URL) == 0 {
+		return fmt.Errorf("kuberhealthy api url was blank")
+	}
+	target := strings.TrimSuffix(c.URL, "/") + APIPath + path
+	if len(query) > 0 {
+		target += "?" + query.Encode()
+	}
+	var body []byte
+	if in != nil {
+		var err error
+		body, err = json.Marshal(in)
+		if err != nil {
+			return fmt.Errorf("error marshaling request body: %w", err)
+		}
+	}
+
+	for attempt := 0; ; attempt++ {
+		err := c.send(ctx, method, target, body, out)
+		if err == nil {
+			return nil
+		}
+		if apiErr, ok := err.(*Error); ok && apiErr.StatusCode < http.StatusInternalServerError {
+			return err
+		}
+		if attempt >= c.Retries {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(c.RetryDelay):
+		}
+	}
+}
+
+// send sends a single request and decodes the response into out
+func (c *Client) send(ctx context.Context, method string, target string, body []byte, out interface{}) error {
+	httpClient := c.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, target, reader)
+	if err != nil {
+		return fmt.Errorf("error creating request to kuberhealthy api: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+c.Token)
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("bad %s request to kuberhealthy api: %w", method, err)
+	}
+	defer resp.Body.Close()
+	b, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("error reading response from kuberhealthy api: %w", err)
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		apiErr := &Error{StatusCode: resp.StatusCode, Message: resp.Status}
+		var body struct {
+			Error string `json:"error"`
+		}
+		if json.Unmarshal(b, &body) == nil && len(body.Error) > 0 {
+			apiErr.Message = body.Error
+		}
+		return apiErr
+	}
+	if out == nil || len(b) == 0 {
+		return nil
+	}
+	err = json.Unmarshal(b, out)
+	if err != nil {
+		return fmt.Errorf("error decoding response from kuberhealthy api: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,93 @@
+package apiclient
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Comcast/kuberhealthy/v2/pkg/runhistory"
+)
+
+// TestTriggerAndWait validates that runs are triggered with the API token and polled until they are done
+func TestTriggerAndWait(t *testing.T) {
+	var polls int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch {
+		case r.Method == http.MethodPost && r.URL.Path == "/api/v1/checks/kuberhealthy/deployment/run":
+			w.WriteHeader(http.StatusAccepted)
+			json.NewEncoder(w).Encode(TriggerRunResponse{RunID: "1234", Name: "deployment", Namespace: "kuberhealthy"})
+		case r.Method == http.MethodGet && r.URL.Path == "/api/v1/runs/1234":
+			polls++
+			phase := runhistory.PhaseRunning
+			if polls == 3 {
+				phase = runhistory.PhaseSucceeded
+			}
+			json.NewEncoder(w).Encode(runhistory.Run{ID: "1234", Phase: phase})
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"error":"no API endpoint at ` + r.URL.Path + `"}`))
+		}
+	}))
+	defer server.Close()
+
+	c := NewClient(server.URL+"/", "secret")
+	ctx := context.Background()
+	triggered, err := c.TriggerRun(ctx, "kuberhealthy", "deployment")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if triggered.RunID != "1234" {
+		t.Fatal("Expected the UUID of the run but got", triggered)
+	}
+	run, err := c.WaitForRun(ctx, triggered.RunID, time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if run.Phase != runhistory.PhaseSucceeded || polls != 3 {
+		t.Fatal("Expected the run to be polled until it succeeded but got", run, "after", polls, "polls")
+	}
+
+	_, err = c.GetRun(ctx, "5678")
+	if !IsNotFound(err) || err.(*Error).Message != "no API endpoint at /api/v1/runs/5678" {
+		t.Fatal("Expected a not found error with the message from the API but got", err)
+	}
+}
+
+// TestRetries validates that server errors are retried and refused requests are not
+func TestRetries(t *testing.T) {
+	var attempts int
+	code := http.StatusServiceUnavailable
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		if attempts < 3 {
+			w.WriteHeader(code)
+			return
+		}
+		w.Write([]byte(`{"kuberhealthy/deployment":{"reason":"INC-1","author":"oncall"}}`))
+	}))
+	defer server.Close()
+
+	c := NewClient(server.URL, "secret")
+	c.RetryDelay = time.Millisecond
+	silences, err := c.ListSilences(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if attempts != 3 || silences["kuberhealthy/deployment"].Reason != "INC-1" {
+		t.Fatal("Expected the request to succeed on the third attempt but got", silences, "after", attempts, "attempts")
+	}
+
+	attempts = 0
+	code = http.StatusConflict
+	_, err = c.TriggerRun(context.Background(), "kuberhealthy", "deployment")
+	if err == nil || attempts != 1 {
+		t.Fatal("Expected a refused request to fail without retrying but got", err, "after", attempts, "attempts")
+	}
+}
@@ -0,0 +1,200 @@
+// Package archive uploads the results of check runs, along with the logs of checker pods that failed, to an
+// object store such as an S3 or GCS bucket for compliance and long-term trend analysis.  Archived objects
+// older than the retention period are pruned.
+package archive
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Comcast/kuberhealthy/v2/pkg/health"
+)
+
+// Store is an object store that archived runs are written to
+type Store interface {
+	Put(key string, body []byte, contentType string) error
+	List(prefix string) ([]Object, error)
+	Delete(key string) error
+}
+
+// Object is an object in a Store
+type Object struct {
+	Key          string
+	LastModified time.Time
+}
+
+// Run is the result of a single check run
+type Run struct {
+	Check     string
+	Namespace string
+	RunID     string
+	Finished  time.Time           // when the run completed
+	Details   health.CheckDetails // the state of the check after the run
+	PodLogs   map[string]string   `json:"-"` // the logs of each container of the checker pod, if the run failed
+}
+
+// Archiver writes runs to a Store.  Each run is written as a JSON object, and the logs of its checker pod as
+// a text object next to it.  Objects are keyed by namespace, check, and the date of the run so that they can
+// be queried by date range.
+type Archiver struct {
+	Store     Store
+	Prefix    string        // prepended to the keys of all archived objects
+	Retention time.Duration // how long archived objects are kept.  Zero keeps them forever.
+}
+
+// New creates an archiver that writes to the supplied store
+func New(store Store, prefix string, retention time.Duration) *Archiver {
+	return &Archiver{
+		Store:     store,
+		Prefix:    strings.Trim(prefix, "/"),
+		Retention: retention,
+	}
+}
+
+// key returns the key of an object of the supplied run
+func (a *Archiver) key(r Run, extension string) string {
+	finished := r.Finished.UTC()
+	name := finished.Format("20060102T150405Z")
+	if len(r.RunID) > 0 {
+		name += "-" + r.RunID
+	}
+	return path.Join(a.Prefix, r.Namespace, r.Check, finished.Format("2006/01/02"), name+extension)
+}
+
+// Archive writes the result of a run and the logs of its checker pod to the store
+func (a *Archiver) Archive(r Run) error {
+	b, err := json.Marshal(r)
+	if err != nil {
+		return fmt.Errorf("error marshaling run of check %s: %w", r.Check, err)
+	}
+	err = a.Store.Put(a.key(r, ".json"), b, "application/json")
+	if err != nil {
+		return fmt.Errorf("error archiving run of check %s: %w", r.Check, err)
+	}
+
+	if len(r.PodLogs) == 0 {
+		return nil
+	}
+	err = a.Store.Put(a.key(r, ".log"), formatLogs(r.PodLogs), "text/plain")
+	if err != nil {
+		return fmt.Errorf("error archiving checker pod logs of check %s: %w", r.Check, err)
+	}
+	return nil
+}
+
+// formatLogs joins the logs of each container into one file with a header before each container
+func formatLogs(logs map[string]string) []byte {
+	containers := make([]string, 0, len(logs))
+	for c := range logs {
+		containers = append(containers, c)
+	}
+	sort.Strings(containers)
+
+	var b strings.Builder
+	for _, c := range containers {
+		b.WriteString("==> " + c + " <==\n")
+		b.WriteString(logs[c])
+		if !strings.HasSuffix(logs[c], "\n") {
+			b.WriteString("\n")
+		}
+	}
+	return []byte(b.String())
+}
+
+// Prune deletes archived objects that are older than the retention period and returns how many were deleted
+func (a *Archiver) Prune(now time.Time) (int, error) {
+	if a.Retention <= 0 {
+		return 0, nil
+	}
+	prefix := a.Prefix
+	if len(prefix) > 0 {
+		prefix += "/"
+	}
+	objects, err := a.Store.List(prefix)
+	if err != nil {
+		return 0, fmt.Errorf("error listing archived objects: %w", err)
+	}
+
+	cutoff := now.Add(-a.Retention)
+	var deleted int
+	for _, o := range objects {
+		if !o.LastModified.Before(cutoff) {
+			continue
+		}
+		err = a.Store.Delete(o.Key)
+		if err != nil {
+			return deleted, fmt.Errorf("error deleting archived object %s: %w", o.Key, err)
+		}
+		deleted++
+	}
+	return deleted, nil
+}
+
+// MemoryStore is a Store that keeps objects in memory.  It is used for testing.
+type MemoryStore struct {
+	mu      sync.Mutex
+	objects map[string]memoryObject
+}
+
+// memoryObject is an object held by a MemoryStore
+type memoryObject struct {
+	body         []byte
+	lastModified time.Time
+}
+
+// NewMemoryStore creates an empty in-memory store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{objects: make(map[string]memoryObject)}
+}
+
+// Put stores an object
+func (s *MemoryStore) Put(key string, body []byte, contentType string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.objects[key] = memoryObject{body: body, lastModified: time.Now()}
+	return nil
+}
+
+// Get returns the body of an object and whether it exists
+func (s *MemoryStore) Get(key string) ([]byte, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	o, ok := s.objects[key]
+	return o.body, ok
+}
+
+// Touch sets the last modified time of an object
+func (s *MemoryStore) Touch(key string, t time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	o := s.objects[key]
+	o.lastModified = t
+	s.objects[key] = o
+}
+
+// List returns the objects whose keys start with the supplied prefix, sorted by key
+func (s *MemoryStore) List(prefix string) ([]Object, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var objects []Object
+	for k, o := range s.objects {
+		if strings.HasPrefix(k, prefix) {
+			objects = append(objects, Object{Key: k, LastModified: o.lastModified})
+		}
+	}
+	sort.Slice(objects, func(i, j int) bool { return objects[i].Key < objects[j].Key })
+	return objects, nil
+}
+
+// Delete removes an object
+func (s *MemoryStore) Delete(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.objects, key)
+	return nil
+}
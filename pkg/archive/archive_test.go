@@ -0,0 +1,100 @@
+package archive
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Comcast/kuberhealthy/v2/pkg/health"
+)
+
+// TestArchive validates that runs and the logs of their checker pods are written under dated keys
+func TestArchive(t *testing.T) {
+	store := NewMemoryStore()
+	a := New(store, "/clusters/prod/", time.Hour)
+	finished := time.Date(2020, 3, 4, 5, 6, 7, 0, time.UTC)
+	details := health.NewCheckDetails()
+	details.LastRunErrors = []string{"deployment did not roll out"}
+
+	err := a.Archive(Run{Check: "deployment", Namespace: "kuberhealthy", RunID: "1234", Finished: finished, Details: details})
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, ok := store.Get("clusters/prod/kuberhealthy/deployment/2020/03/04/20200304T050607Z-1234.json")
+	if !ok {
+		objects, _ := store.List("")
+		t.Fatal("Expected the run to be archived under a dated key but found", objects)
+	}
+	var r Run
+	err = json.Unmarshal(b, &r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.RunID != "1234" || len(r.Details.LastRunErrors) != 1 {
+		t.Fatal("Expected the archived run to hold the run details but got", r)
+	}
+	_, ok = store.Get("clusters/prod/kuberhealthy/deployment/2020/03/04/20200304T050607Z-1234.log")
+	if ok {
+		t.Fatal("Expected no logs to be archived for a run without pod logs")
+	}
+
+	// failed runs carry the logs of their checker pod
+	err = a.Archive(Run{Check: "deployment", Namespace: "kuberhealthy", RunID: "5678", Finished: finished, PodLogs: map[string]string{"sidecar": "b", "main": "a\n"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, ok = store.Get("clusters/prod/kuberhealthy/deployment/2020/03/04/20200304T050607Z-5678.log")
+	if !ok {
+		t.Fatal("Expected the checker pod logs to be archived")
+	}
+	if string(b) != "==> main <==\na\n==> sidecar <==\nb\n" {
+		t.Fatal("Expected the logs of each container in order but got", string(b))
+	}
+	if strings.Contains(string(mustGet(t, store, "clusters/prod/kuberhealthy/deployment/2020/03/04/20200304T050607Z-5678.json")), "sidecar") {
+		t.Fatal("Expected pod logs to be left out of the archived run")
+	}
+}
+
+// TestPrune validates that only archived objects older than the retention period are deleted
+func TestPrune(t *testing.T) {
+	store := NewMemoryStore()
+	now := time.Now()
+	for _, key := range []string{"prefix/old", "prefix/new", "other/old"} {
+		err := store.Put(key, []byte("{}"), "application/json")
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	store.Touch("prefix/old", now.Add(-time.Hour*2))
+	store.Touch("other/old", now.Add(-time.Hour*2))
+
+	a := New(store, "prefix", time.Hour)
+	deleted, err := a.Prune(now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if deleted != 1 {
+		t.Fatal("Expected one archived object to be pruned but got", deleted)
+	}
+	objects, _ := store.List("")
+	if len(objects) != 2 || objects[0].Key != "other/old" || objects[1].Key != "prefix/new" {
+		t.Fatal("Expected only the old object under the prefix to be pruned but found", objects)
+	}
+
+	// a zero retention keeps everything
+	a.Retention = 0
+	deleted, err = a.Prune(now.Add(time.Hour * 24))
+	if err != nil || deleted != 0 {
+		t.Fatal("Expected nothing to be pruned without a retention period but got", deleted, err)
+	}
+}
+
+// mustGet returns the body of an object or fails the test
+func mustGet(t *testing.T, store *MemoryStore, key string) []byte {
+	b, ok := store.Get(key)
+	if !ok {
+		t.Fatal("Expected object", key, "to exist")
+	}
+	return b
+}
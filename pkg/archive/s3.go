@@ -0,0 +1,112 @@
+package archive
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3iface"
+)
+
+// GCSEndpoint is the S3 compatible endpoint of Google Cloud Storage.  GCS buckets are written with HMAC keys
+// supplied as AWS credentials.
+const GCSEndpoint = "https://storage.googleapis.com"
+
+// defaultRegion is used when no region is configured.  GCS accepts any region.
+const defaultRegion = "us-east-1"
+
+// S3Store is a Store backed by an S3 bucket or any S3 compatible object store, such as GCS
+type S3Store struct {
+	Bucket string
+	client s3iface.S3API
+}
+
+// NewS3Store creates a store that writes to the supplied bucket.  The endpoint is only needed for S3
+// compatible object stores other than AWS.  Credentials are read from the standard AWS environment
+// variables, shared config files, or instance roles.
+func NewS3Store(bucket string, endpoint string, region string) (*S3Store, error) {
+	if len(bucket) == 0 {
+		return nil, errors.New("archive bucket was blank")
+	}
+	if len(region) == 0 {
+		region = defaultRegion
+	}
+	config := aws.NewConfig().WithRegion(region).WithCredentialsChainVerboseErrors(true)
+	if len(endpoint) > 0 {
+		config = config.WithEndpoint(endpoint).WithS3ForcePathStyle(true)
+	}
+	sess, err := session.NewSession(config)
+	if err != nil {
+		return nil, fmt.Errorf("error creating object store session: %w", err)
+	}
+	return &S3Store{
+		Bucket: bucket,
+		client: s3.New(sess),
+	}, nil
+}
+
+// NewStoreFromURL creates a store from an archive URL in the form s3://bucket/prefix or gs://bucket/prefix
+// and returns it along with the prefix.  The endpoint overrides the endpoint implied by the URL scheme.
+func NewStoreFromURL(archiveURL string, endpoint string, region string) (*S3Store, string, error) {
+	u, err := url.Parse(archiveURL)
+	if err != nil {
+		return nil, "", fmt.Errorf("error parsing archive url: %w", err)
+	}
+	switch u.Scheme {
+	case "s3":
+	case "gs":
+		if len(endpoint) == 0 {
+			endpoint = GCSEndpoint
+		}
+	default:
+		return nil, "", errors.New("archive url must start with s3:// or gs:// but was " + archiveURL)
+	}
+	store, err := NewS3Store(u.Host, endpoint, region)
+	if err != nil {
+		return nil, "", err
+	}
+	return store, strings.Trim(u.Path, "/"), nil
+}
+
+// Put writes an object to the bucket
+func (s *S3Store) Put(key string, body []byte, contentType string) error {
+	_, err := s.client.PutObject(&s3.PutObjectInput{
+		Bucket:      aws.String(s.Bucket),
+		Key:         aws.String(key),
+		Body:        bytes.NewReader(body),
+		ContentType: aws.String(contentType),
+	})
+	return err
+}
+
+// List returns the objects in the bucket whose keys start with the supplied prefix
+func (s *S3Store) List(prefix string) ([]Object, error) {
+	var objects []Object
+	err := s.client.ListObjectsPages(&s3.ListObjectsInput{
+		Bucket: aws.String(s.Bucket),
+		Prefix: aws.String(prefix),
+	}, func(page *s3.ListObjectsOutput, lastPage bool) bool {
+		for _, o := range page.Contents {
+			objects = append(objects, Object{
+				Key:          aws.StringValue(o.Key),
+				LastModified: aws.TimeValue(o.LastModified),
+			})
+		}
+		return true
+	})
+	return objects, err
+}
+
+// Delete removes an object from the bucket
+func (s *S3Store) Delete(key string) error {
+	_, err := s.client.DeleteObject(&s3.DeleteObjectInput{
+		Bucket: aws.String(s.Bucket),
+		Key:    aws.String(key),
+	})
+	return err
+}
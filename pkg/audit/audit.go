@@ -0,0 +1,178 @@
+// Package audit records check report-ins and check state changes to a rotating local log file so that
+// the reasons a check changed state can be reviewed after an incident.  Each line of the log is one JSON
+// encoded Event.
+package audit
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// EventType is the kind of an audited event
+type EventType string
+
+// The types of audited events
+const (
+	EventReport         EventType = "Report"         // a checker pod reported the result of a run
+	EventProgress       EventType = "Progress"       // a checker pod reported the progress of a run
+	EventReportRejected EventType = "ReportRejected" // a report was refused because the caller or report was invalid
+	EventStateChange    EventType = "StateChange"    // the reported health of a check changed
+)
+
+// Event is a single audited event
+type Event struct {
+	Time       time.Time `json:"time"`
+	Type       EventType `json:"type"`
+	Check      string    `json:"check,omitempty"`
+	Namespace  string    `json:"namespace,omitempty"`
+	RunID      string    `json:"runID,omitempty"`
+	Pod        string    `json:"pod,omitempty"`
+	SourceIP   string    `json:"sourceIP,omitempty"`
+	OK         *bool     `json:"ok,omitempty"`
+	PreviousOK *bool     `json:"previousOK,omitempty"` // the health of the check before a state change.  Blank for new checks.
+	Errors     []string  `json:"errors,omitempty"`
+	Reason     string    `json:"reason,omitempty"` // why a report was rejected
+}
+
+// Bool returns a pointer to the supplied bool for use in events
+func Bool(b bool) *bool {
+	return &b
+}
+
+// Log appends events to a file and rotates it once it grows past MaxSize bytes.  The previous files are
+// kept with the suffixes .1 through .MaxBackups, with .1 being the newest.  A nil Log records nothing.
+type Log struct {
+	Path       string // the file events are appended to
+	MaxSize    int64  // the size in bytes at which the file is rotated.  Zero disables rotation.
+	MaxBackups int    // the number of rotated files that are kept
+
+	mu   sync.Mutex
+	file *os.File
+	size int64
+}
+
+// New opens the audit log at the supplied path, creating it and its directory if they do not exist
+func New(path string, maxSize int64, maxBackups int) (*Log, error) {
+	l := &Log{
+		Path:       path,
+		MaxSize:    maxSize,
+		MaxBackups: maxBackups,
+	}
+	err := os.MkdirAll(filepath.Dir(path), 0755)
+	if err != nil {
+		return nil, fmt.Errorf("error creating audit log directory: %w", err)
+	}
+	err = l.open()
+	if err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// open opens the log file for appending.  The caller must hold the lock.
+func (l *Log) open() error {
+	f, err := os.OpenFile(l.Path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("error opening audit log: %w", err)
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return fmt.Errorf("error reading size of audit log: %w", err)
+	}
+	l.file = f
+	l.size = info.Size()
+	return nil
+}
+
+// Record appends an event to the log.  The event time is set to now if it is blank.
+func (l *Log) Record(e Event) error {
+	if l == nil {
+		return nil
+	}
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+	b, err := json.Marshal(e)
+	if err != nil {
+		return fmt.Errorf("error marshaling audit event: %w", err)
+	}
+	b = append(b, '\n')
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.file == nil {
+		return fmt.Errorf("audit log %s is closed", l.Path)
+	}
+	if l.MaxSize > 0 && l.size > 0 && l.size+int64(len(b)) > l.MaxSize {
+		err = l.rotate()
+		if err != nil {
+			return err
+		}
+	}
+	n, err := l.file.Write(b)
+	l.size += int64(n)
+	if err != nil {
+		return fmt.Errorf("error writing audit event: %w", err)
+	}
+	return nil
+}
+
+// rotate moves the current file to the first backup, shifting older backups along and removing the oldest,
+// and then opens a new file.  The caller must hold the lock.
+func (l *Log) rotate() error {
+	err := l.file.Close()
+	if err != nil {
+		return fmt.Errorf("error closing audit log for rotation: %w", err)
+	}
+	l.file = nil
+
+	if l.MaxBackups < 1 {
+		err = os.Remove(l.Path)
+		if err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("error removing audit log for rotation: %w", err)
+		}
+		return l.open()
+	}
+
+	err = os.Remove(l.backupPath(l.MaxBackups))
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("error removing oldest audit log backup: %w", err)
+	}
+	for i := l.MaxBackups - 1; i >= 1; i-- {
+		err = os.Rename(l.backupPath(i), l.backupPath(i+1))
+		if err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("error rotating audit log backup: %w", err)
+		}
+	}
+	err = os.Rename(l.Path, l.backupPath(1))
+	if err != nil {
+		return fmt.Errorf("error rotating audit log: %w", err)
+	}
+	return l.open()
+}
+
+// backupPath returns the path of the numbered backup of the log
+func (l *Log) backupPath(i int) string {
+	return l.Path + "." + strconv.Itoa(i)
+}
+
+// Close closes the log file.  Events recorded after closing return an error.
+func (l *Log) Close() error {
+	if l == nil {
+		return nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.file == nil {
+		return nil
+	}
+	err := l.file.Close()
+	l.file = nil
+	return err
+}
@@ -0,0 +1,121 @@
+package audit
+
+import (
+	"bufio"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// readEvents reads all events from an audit log file
+func readEvents(t *testing.T, path string) []Event {
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	var events []Event
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var e Event
+		err = json.Unmarshal(scanner.Bytes(), &e)
+		if err != nil {
+			t.Fatal("Failed to decode audit event:", err)
+		}
+		events = append(events, e)
+	}
+	return events
+}
+
+// TestRecord validates that events are appended to the log as JSON lines
+func TestRecord(t *testing.T) {
+	dir, err := ioutil.TempDir("", "audit")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "logs", "audit.log")
+
+	l, err := New(path, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = l.Record(Event{Type: EventReport, Check: "deployment", RunID: "1234", SourceIP: "10.0.0.1", OK: Bool(false), Errors: []string{"failed"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = l.Record(Event{Type: EventStateChange, Check: "deployment", OK: Bool(false), PreviousOK: Bool(true)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	l.Close()
+
+	events := readEvents(t, path)
+	if len(events) != 2 {
+		t.Fatal("Expected two audit events but got", len(events))
+	}
+	if events[0].Time.IsZero() || events[0].SourceIP != "10.0.0.1" || *events[0].OK {
+		t.Fatal("Unexpected report event:", events[0])
+	}
+	if events[1].Type != EventStateChange || !*events[1].PreviousOK {
+		t.Fatal("Unexpected state change event:", events[1])
+	}
+
+	// a reopened log appends to the existing file
+	l, err = New(path, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	err = l.Record(Event{Type: EventReportRejected, Reason: "unknown pod"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(readEvents(t, path)) != 3 {
+		t.Fatal("Expected the reopened log to be appended to")
+	}
+}
+
+// TestRotate validates that the log is rotated at its maximum size and old backups are removed
+func TestRotate(t *testing.T) {
+	dir, err := ioutil.TempDir("", "audit")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "audit.log")
+
+	// every event is larger than the maximum size, so each one goes to a new file
+	l, err := New(path, 10, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	for _, check := range []string{"first", "second", "third", "fourth"} {
+		err = l.Record(Event{Type: EventReport, Check: check})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for path, check := range map[string]string{path: "fourth", path + ".1": "third", path + ".2": "second"} {
+		events := readEvents(t, path)
+		if len(events) != 1 || events[0].Check != check {
+			t.Fatal("Expected", path, "to hold the", check, "event but got", events)
+		}
+	}
+	_, err = os.Stat(path + ".3")
+	if !os.IsNotExist(err) {
+		t.Fatal("Expected only two backups to be kept")
+	}
+}
+
+// TestNilLog validates that a nil log records nothing without failing
+func TestNilLog(t *testing.T) {
+	var l *Log
+	if l.Record(Event{Type: EventReport}) != nil || l.Close() != nil {
+		t.Fatal("Expected a nil audit log to do nothing")
+	}
+}
@@ -0,0 +1,341 @@
+// Copyright 2018 Comcast Cable Communications Management, LLC
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//     http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package checkclient is the library external check authors import to report
+// the result of a check run back to Kuberhealthy.  Kuberhealthy sets the
+// reporting URL, run ID, and run deadline as environment variables on every
+// checker pod it spawns, so checks normally only need to call ReportSuccess
+// or ReportFailure.
+package checkclient
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Comcast/kuberhealthy/v2/pkg/checks/external/status"
+	"github.com/Comcast/kuberhealthy/v2/pkg/health"
+	"github.com/Comcast/kuberhealthy/v2/pkg/proxy"
+)
+
+// KuberhealthyURLEnv is the environment variable that holds the URL checks report to
+const KuberhealthyURLEnv = "KUBERHEALTHY_URL"
+
+// KuberhealthyRunIDEnv is the environment variable that holds the UUID of the current check run
+const KuberhealthyRunIDEnv = "KUBERHEALTHY_RUN_ID"
+
+// KuberhealthyDeadlineEnv is the environment variable that holds the unix time at which
+// Kuberhealthy will give up on the current check run
+const KuberhealthyDeadlineEnv = "KUBERHEALTHY_CHECK_DEADLINE"
+
+// KuberhealthyCABundleEnv is the environment variable that holds the PEM encoded CA bundle used to verify
+// Kuberhealthy when it serves TLS
+const KuberhealthyCABundleEnv = "KUBERHEALTHY_CA_BUNDLE"
+
+// KuberhealthyClientCertFileEnv and KuberhealthyClientKeyFileEnv are the environment variables that point
+// to the client certificate presented to Kuberhealthy when it requires mutual TLS
+const KuberhealthyClientCertFileEnv = "KUBERHEALTHY_CLIENT_CERT_FILE"
+const KuberhealthyClientKeyFileEnv = "KUBERHEALTHY_CLIENT_KEY_FILE"
+
+// KuberhealthyTokenFileEnv is the environment variable that points to the service account token sent with
+// reports when Kuberhealthy authenticates the pods that report to it
+const KuberhealthyTokenFileEnv = "KUBERHEALTHY_TOKEN_FILE"
+
+// KuberhealthyProxyEnv and KuberhealthyNoProxyEnv are the environment variables that hold the proxy reports
+// are sent through, or direct to send them without a proxy, and the comma separated hosts that are reached
+// without it.  Reports are sent through the HTTP_PROXY, HTTPS_PROXY, and NO_PROXY environment variables
+// when they are not set.
+const KuberhealthyProxyEnv = "KUBERHEALTHY_PROXY"
+const KuberhealthyNoProxyEnv = "KUBERHEALTHY_NO_PROXY"
+
+// KuberhealthyTraceparentEnv is the environment variable that holds the W3C traceparent of the current check
+// run when Kuberhealthy traces check runs.  Checks that create their own OpenTelemetry spans should use it as
+// their parent so that their spans join the trace of the run.
+const KuberhealthyTraceparentEnv = "TRACEPARENT"
+
+// legacy environment variables that older Kuberhealthy versions set on checker pods
+const legacyReportingURLEnv = "KH_REPORTING_URL"
+const legacyRunIDEnv = "KH_RUN_UUID"
+
+// Debug can be used to enable output logging from the checkclient
+var Debug bool
+
+// Client reports check results to Kuberhealthy
+type Client struct {
+	URL         string        // the URL reports are sent to
+	RunID       string        // the UUID of the current check run
+	Traceparent string        // the W3C traceparent sent with reports so that they join the trace of the run
+	TokenFile   string        // the file holding the service account token sent with reports, if Kuberhealthy requires one
+	Retries     int           // how many times a failed report is retried
+	RetryDelay  time.Duration // how long to wait between retries
+	HTTPClient  *http.Client  // the client used to send reports
+}
+
+// NewClient creates a client configured from the environment variables that Kuberhealthy
+// sets on checker pods
+func NewClient() *Client {
+	return &Client{
+		URL:         getEnvWithFallback(KuberhealthyURLEnv, legacyReportingURLEnv),
+		RunID:       getEnvWithFallback(KuberhealthyRunIDEnv, legacyRunIDEnv),
+		Traceparent: os.Getenv(KuberhealthyTraceparentEnv),
+		TokenFile:   os.Getenv(KuberhealthyTokenFileEnv),
+		Retries:     3,
+		RetryDelay:  time.Second * 2,
+		HTTPClient: &http.Client{
+			Timeout:   time.Second * 10,
+			Transport: newTransport(),
+		},
+	}
+}
+
+// newTransport creates an HTTP transport that sends reports through the configured proxy, trusts the
+// Kuberhealthy CA bundle, and presents the mounted client certificate, if Kuberhealthy provided them
+func newTransport() *http.Transport {
+	transport, err := proxy.Transport(os.Getenv(KuberhealthyProxyEnv), os.Getenv(KuberhealthyNoProxyEnv))
+	if err != nil {
+		writeLog("ERROR: failed to configure proxy: ", err)
+		transport = http.DefaultTransport.(*http.Transport).Clone()
+	}
+
+	caBundle := os.Getenv(KuberhealthyCABundleEnv)
+	certFile := os.Getenv(KuberhealthyClientCertFileEnv)
+	keyFile := os.Getenv(KuberhealthyClientKeyFileEnv)
+	if len(caBundle) == 0 && len(certFile) == 0 {
+		return transport
+	}
+
+	tlsConfig := &tls.Config{}
+	if len(caBundle) > 0 {
+		tlsConfig.RootCAs = x509.NewCertPool()
+		if !tlsConfig.RootCAs.AppendCertsFromPEM([]byte(caBundle)) {
+			writeLog("ERROR: no certificates found in ", KuberhealthyCABundleEnv)
+		}
+	}
+	if len(certFile) > 0 {
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			writeLog("ERROR: failed to load client certificate: ", err)
+		} else {
+			tlsConfig.Certificates = []tls.Certificate{cert}
+		}
+	}
+	transport.TLSClientConfig = tlsConfig
+	return transport
+}
+
+// ReportSuccess reports a successful check run to the Kuberhealthy service
+func ReportSuccess() error {
+	return NewClient().ReportSuccess()
+}
+
+// ReportFailure reports that the external checker has found problems.  You may
+// pass a slice of error message strings that will surface in the Kuberhealthy
+// status page for more context on the failure.
+func ReportFailure(errorMessages []string) error {
+	return NewClient().ReportFailure(errorMessages)
+}
+
+// ReportProgress tells Kuberhealthy how far along a long running check is.  Progress is
+// shown on the status page until a final report is sent.
+func ReportProgress(percent int, message string) error {
+	return NewClient().ReportProgress(percent, message)
+}
+
+// ReportAssertions reports the result of a check run as a set of named sub-check results.
+// The run is considered failed if any assertion is not OK.
+func ReportAssertions(assertions []health.Assertion) error {
+	return NewClient().ReportAssertions(assertions)
+}
+
+// ReportMeasurements reports the result of a check run along with numeric measurements of it, such as the
+// latency of a request.  The run is considered failed if there are any error messages.  Measurements can be
+// checked against regression rules in the khcheck spec.
+func ReportMeasurements(measurements health.Measurements, errorMessages []string) error {
+	return NewClient().ReportMeasurements(measurements, errorMessages)
+}
+
+// UploadArtifact uploads a file, such as a JSON report, packet capture, or screenshot, as evidence for the
+// current check run.  Artifacts are stored by Kuberhealthy and referenced from the result of the run.  Names
+// may contain letters, digits, '-', '_', and '.'.
+func UploadArtifact(name string, data []byte) error {
+	return NewClient().UploadArtifact(name, data)
+}
+
+// GetDeadline returns the time at which Kuberhealthy will stop waiting for this check run
+// to report in.  Checks should aim to report a result, even a partial failure, before then.
+func GetDeadline() (time.Time, error) {
+	deadlineEnv := os.Getenv(KuberhealthyDeadlineEnv)
+	if len(deadlineEnv) == 0 {
+		return time.Time{}, fmt.Errorf("%s environment variable was blank", KuberhealthyDeadlineEnv)
+	}
+	deadlineUnix, err := strconv.ParseInt(deadlineEnv, 10, 64)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("unable to parse %s environment variable: %w", KuberhealthyDeadlineEnv, err)
+	}
+	return time.Unix(deadlineUnix, 0), nil
+}
+
+// ReportSuccess reports a successful check run
+func (c *Client) ReportSuccess() error {
+	writeLog("DEBUG: Reporting SUCCESS")
+	return c.sendReport(status.NewReport([]string{}))
+}
+
+// ReportFailure reports a failed check run with the supplied error messages
+func (c *Client) ReportFailure(errorMessages []string) error {
+	writeLog("DEBUG: Reporting FAILURE")
+	return c.sendReport(status.NewReport(errorMessages))
+}
+
+// ReportProgress reports an intermediate progress update for the current check run
+func (c *Client) ReportProgress(percent int, message string) error {
+	writeLog("DEBUG: Reporting PROGRESS ", percent, "%")
+	return c.sendReport(status.NewProgressReport(percent, message))
+}
+
+// ReportAssertions reports a final check run result made up of named assertions
+func (c *Client) ReportAssertions(assertions []health.Assertion) error {
+	writeLog("DEBUG: Reporting ASSERTIONS")
+	return c.sendReport(status.NewAssertionReport(assertions))
+}
+
+// ReportMeasurements reports a final check run result that carries numeric measurements of the run
+func (c *Client) ReportMeasurements(measurements health.Measurements, errorMessages []string) error {
+	writeLog("DEBUG: Reporting MEASUREMENTS")
+	return c.sendReport(status.NewMeasurementReport(measurements, errorMessages))
+}
+
+// UploadArtifact uploads a file as evidence for the current check run
+func (c *Client) UploadArtifact(name string, data []byte) error {
+	writeLog("DEBUG: Uploading artifact ", name, " of ", len(data), " bytes")
+	artifactURL, err := c.artifactURL(name)
+	if err != nil {
+		return err
+	}
+	return c.postWithRetries(artifactURL, "application/octet-stream", data)
+}
+
+// artifactURL returns the URL artifacts with the supplied name are uploaded to.  The artifact endpoint is
+// served next to the status reporting endpoint.
+func (c *Client) artifactURL(name string) (string, error) {
+	if len(c.URL) == 0 {
+		return "", fmt.Errorf("kuberhealthy reporting url was blank. %s environment variable not set", KuberhealthyURLEnv)
+	}
+	u, err := url.Parse(c.URL)
+	if err != nil {
+		return "", fmt.Errorf("unable to parse kuberhealthy reporting url: %w", err)
+	}
+	u.Path = strings.TrimSuffix(u.Path, "/externalCheckStatus") + status.ArtifactPath
+	u.RawQuery = url.Values{status.ArtifactNameParam: []string{name}}.Encode()
+	return u.String(), nil
+}
+
+// sendReport marshals the report and sends it to Kuberhealthy, retrying on failure
+func (c *Client) sendReport(s status.Report) error {
+
+	writeLog("DEBUG: Sending report with error length of:", len(s.Errors))
+	writeLog("DEBUG: Sending report with ok state of:", s.OK)
+
+	if len(c.URL) == 0 {
+		return fmt.Errorf("kuberhealthy reporting url was blank. %s environment variable not set", KuberhealthyURLEnv)
+	}
+	writeLog("INFO: Using kuberhealthy reporting URL:", c.URL)
+
+	// marshal the request body
+	b, err := json.Marshal(s)
+	if err != nil {
+		return fmt.Errorf("error marshaling status report json: %w", err)
+	}
+
+	return c.postWithRetries(c.URL, "application/json", b)
+}
+
+// postWithRetries sends a request body to Kuberhealthy until it succeeds or we run out of retries
+func (c *Client) postWithRetries(target string, contentType string, b []byte) error {
+	for attempt := 0; ; attempt++ {
+		err := c.post(target, contentType, b)
+		if err == nil {
+			writeLog("INFO: Got a good http return status code from kuberhealthy URL:", target)
+			return nil
+		}
+		if attempt >= c.Retries {
+			return err
+		}
+		writeLog("ERROR: failed to report to kuberhealthy. retrying in", c.RetryDelay, ":", err)
+		time.Sleep(c.RetryDelay)
+	}
+}
+
+// post sends a single request body to Kuberhealthy
+func (c *Client) post(target string, contentType string, b []byte) error {
+	httpClient := c.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+
+	req, err := http.NewRequest(http.MethodPost, target, bytes.NewBuffer(b))
+	if err != nil {
+		return fmt.Errorf("error creating request to kuberhealthy status reporting url: %w", err)
+	}
+	req.Header.Set("Content-Type", contentType)
+	if len(c.Traceparent) > 0 {
+		req.Header.Set("traceparent", c.Traceparent)
+	}
+
+	// the token is read on every request because the kubelet rotates it before it expires
+	if len(c.TokenFile) > 0 {
+		token, err := ioutil.ReadFile(c.TokenFile)
+		if err != nil {
+			return fmt.Errorf("error reading service account token: %w", err)
+		}
+		req.Header.Set("Authorization", "Bearer "+strings.TrimSpace(string(token)))
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("bad POST request to kuberhealthy status reporting url: %w", err)
+	}
+	defer resp.Body.Close()
+	ioutil.ReadAll(resp.Body)
+
+	// make sure we got a 200 and consider it an error otherwise
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("bad status code from kuberhealthy status reporting url: %d %s", resp.StatusCode, resp.Status)
+	}
+	return nil
+}
+
+// getEnvWithFallback returns the value of the first environment variable that is set
+func getEnvWithFallback(names ...string) string {
+	for _, n := range names {
+		v := os.Getenv(n)
+		if len(v) > 0 {
+			return v
+		}
+	}
+	return ""
+}
+
+// writeLog writes a log entry if debugging is enabled
+func writeLog(i ...interface{}) {
+	if Debug {
+		log.Println("checkclient:", fmt.Sprint(i...))
+	}
+}
@@ -0,0 +1,241 @@
+package checkclient
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/Comcast/kuberhealthy/v2/pkg/checks/external/status"
+	"github.com/Comcast/kuberhealthy/v2/pkg/health"
+)
+
+func TestReportRetries(t *testing.T) {
+	var attempts int
+	var received status.Report
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		if attempts < 3 {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		err := json.NewDecoder(r.Body).Decode(&received)
+		if err != nil {
+			t.Fatal("failed to decode report:", err)
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	c := NewClient()
+	c.URL = server.URL
+	c.RetryDelay = time.Millisecond
+
+	err := c.ReportFailure([]string{"something broke"})
+	if err != nil {
+		t.Fatal("expected report to succeed after retrying:", err)
+	}
+	if attempts != 3 {
+		t.Fatal("expected 3 attempts, got", attempts)
+	}
+	if received.OK || len(received.Errors) != 1 {
+		t.Fatalf("unexpected report received: %+v", received)
+	}
+}
+
+func TestReportGivesUp(t *testing.T) {
+	var attempts int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer server.Close()
+
+	c := NewClient()
+	c.URL = server.URL
+	c.Retries = 1
+	c.RetryDelay = time.Millisecond
+
+	err := c.ReportSuccess()
+	if err == nil {
+		t.Fatal("expected an error after exhausting retries")
+	}
+	if attempts != 2 {
+		t.Fatal("expected 2 attempts, got", attempts)
+	}
+}
+
+func TestReportAssertions(t *testing.T) {
+	var received status.Report
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		err := json.NewDecoder(r.Body).Decode(&received)
+		if err != nil {
+			t.Fatal("failed to decode report:", err)
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	c := NewClient()
+	c.URL = server.URL
+
+	err := c.ReportAssertions([]health.Assertion{
+		{Name: "dns", OK: true},
+		{Name: "ingress", OK: false, Error: "timed out"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if received.OK || len(received.Assertions) != 2 {
+		t.Fatalf("unexpected report received: %+v", received)
+	}
+	if len(received.Errors) != 1 || received.Errors[0] != "ingress: timed out" {
+		t.Fatal("expected failed assertion to be reported as an error, got", received.Errors)
+	}
+
+	err = c.ReportMeasurements(health.Measurements{"latency_ms": 12.5}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !received.OK || received.Measurements["latency_ms"] != 12.5 {
+		t.Fatalf("unexpected measurement report received: %+v", received)
+	}
+
+	err = c.ReportProgress(50, "halfway")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !received.InProgress || received.Progress != 50 || received.Message != "halfway" {
+		t.Fatalf("unexpected progress report received: %+v", received)
+	}
+}
+
+func TestUploadArtifact(t *testing.T) {
+	var path, name, body string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.Path
+		name = r.URL.Query().Get(status.ArtifactNameParam)
+		b, _ := ioutil.ReadAll(r.Body)
+		body = string(b)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	c := NewClient()
+	c.URL = server.URL + "/externalCheckStatus"
+
+	err := c.UploadArtifact("report.json", []byte(`{"latency":"5s"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if path != status.ArtifactPath || name != "report.json" || body != `{"latency":"5s"}` {
+		t.Fatal("unexpected artifact upload received:", path, name, body)
+	}
+}
+
+func TestReportSendsToken(t *testing.T) {
+	var authorization string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authorization = r.Header.Get("Authorization")
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	tokenFile, err := ioutil.TempFile("", "token")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(tokenFile.Name())
+	_, err = tokenFile.WriteString("first-token\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tokenFile.Close()
+
+	c := NewClient()
+	c.URL = server.URL
+	c.TokenFile = tokenFile.Name()
+	err = c.ReportSuccess()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if authorization != "Bearer first-token" {
+		t.Fatal("expected the token to be sent as a bearer token, got", authorization)
+	}
+
+	// a rotated token is picked up by the next report
+	err = ioutil.WriteFile(tokenFile.Name(), []byte("second-token"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = c.ReportSuccess()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if authorization != "Bearer second-token" {
+		t.Fatal("expected the rotated token to be sent, got", authorization)
+	}
+}
+
+func TestNewClientFallsBackToLegacyEnv(t *testing.T) {
+	os.Unsetenv(KuberhealthyURLEnv)
+	os.Setenv(legacyReportingURLEnv, "http://legacy")
+	defer os.Unsetenv(legacyReportingURLEnv)
+
+	c := NewClient()
+	if c.URL != "http://legacy" {
+		t.Fatal("expected legacy reporting url, got", c.URL)
+	}
+
+	os.Setenv(KuberhealthyURLEnv, "http://current")
+	defer os.Unsetenv(KuberhealthyURLEnv)
+	c = NewClient()
+	if c.URL != "http://current" {
+		t.Fatal("expected current reporting url, got", c.URL)
+	}
+}
+
+func TestReportThroughProxy(t *testing.T) {
+	var host string
+	proxyServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host = r.Host
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer proxyServer.Close()
+
+	os.Setenv(KuberhealthyProxyEnv, proxyServer.URL)
+	defer os.Unsetenv(KuberhealthyProxyEnv)
+	c := NewClient()
+	c.URL = "http://kuberhealthy.example.com/externalCheckStatus"
+
+	err := c.ReportSuccess()
+	if err != nil {
+		t.Fatal("expected report to succeed through the proxy:", err)
+	}
+	if host != "kuberhealthy.example.com" {
+		t.Fatal("expected the report to kuberhealthy.example.com to be sent through the proxy, got", host)
+	}
+}
+
+func TestGetDeadline(t *testing.T) {
+	os.Unsetenv(KuberhealthyDeadlineEnv)
+	_, err := GetDeadline()
+	if err == nil {
+		t.Fatal("expected an error when the deadline is not set")
+	}
+
+	deadline := time.Now().Add(time.Minute).Truncate(time.Second)
+	os.Setenv(KuberhealthyDeadlineEnv, strconv.FormatInt(deadline.Unix(), 10))
+	defer os.Unsetenv(KuberhealthyDeadlineEnv)
+	d, err := GetDeadline()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !d.Equal(deadline) {
+		t.Fatal("expected deadline", deadline, "got", d)
+	}
+}
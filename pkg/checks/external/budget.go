@@ -0,0 +1,126 @@
+package external
+
+import (
+	"fmt"
+	"time"
+
+	apiv1 "k8s.io/api/core/v1"
+
+	"github.com/Comcast/kuberhealthy/v2/pkg/khcheckcrd"
+)
+
+// PhaseTimeouts limits how long each phase of a run can take.  A phase with a zero timeout is only limited by
+// the timeout of the run.
+type PhaseTimeouts struct {
+	Schedule  time.Duration // how long the checker pod can take to be scheduled onto a node
+	ImagePull time.Duration // how long the checker pod can take to pull its images and start once it is scheduled
+	Run       time.Duration // how long the checker pod can run before it reports in
+	Report    time.Duration // how long the checker pod can take to exit once it has reported in
+	Cleanup   time.Duration // how long the pods of earlier runs can take to clean up
+}
+
+// ParseTimeoutBudget parses the timeout budget of a khcheck.  A nil budget leaves every phase unlimited.
+func ParseTimeoutBudget(budget *khcheckcrd.TimeoutBudget) (PhaseTimeouts, error) {
+	var timeouts PhaseTimeouts
+	if budget == nil {
+		return timeouts, nil
+	}
+
+	phases := []struct {
+		name    string
+		value   string
+		timeout *time.Duration
+	}{
+		{"schedule", budget.Schedule, &timeouts.Schedule},
+		{"imagePull", budget.ImagePull, &timeouts.ImagePull},
+		{"run", budget.Run, &timeouts.Run},
+		{"report", budget.Report, &timeouts.Report},
+		{"cleanup", budget.Cleanup, &timeouts.Cleanup},
+	}
+	for _, phase := range phases {
+		if len(phase.value) == 0 {
+			continue
+		}
+		d, err := time.ParseDuration(phase.value)
+		if err != nil {
+			return PhaseTimeouts{}, fmt.Errorf("error parsing the %s timeout budget: %w", phase.name, err)
+		}
+		if d <= 0 {
+			return PhaseTimeouts{}, fmt.Errorf("the %s timeout budget must be positive", phase.name)
+		}
+		*phase.timeout = d
+	}
+	return timeouts, nil
+}
+
+// phaseTimer returns a channel that receives when the budget of a phase runs out.  Phases without a budget
+// get a nil channel, which never receives.
+func phaseTimer(budget time.Duration) <-chan time.Time {
+	if budget <= 0 {
+		return nil
+	}
+	return time.After(budget)
+}
+
+// podScheduled determines if a pod has been scheduled onto a node
+func podScheduled(p *apiv1.Pod) bool {
+	for _, condition := range p.Status.Conditions {
+		if condition.Type == apiv1.PodScheduled {
+			return condition.Status == apiv1.ConditionTrue
+		}
+	}
+	return len(p.Spec.NodeName) > 0
+}
+
+// ValidateTiming returns warnings about a timeout that is longer than the run interval, which lets runs pile up
+// behind each other when they can not overlap, and about timeout budgets that can never apply because they are
+// longer than the timeout.  When clamp is true, the timeout is shortened to the run interval and the budgets to
+// the timeout, and the warnings say so.
+func (ext *Checker) ValidateTiming(clamp bool) []string {
+	var warnings []string
+	if ext.RunTimeout <= 0 {
+		warnings = append(warnings, fmt.Sprintf("the timeout of %s is not positive, so runs time out immediately", ext.RunTimeout))
+	}
+
+	if !ext.Daemon && ext.MaxOverlappingRuns <= 0 && ext.RunInterval > 0 && ext.RunTimeout > ext.RunInterval {
+		warning := fmt.Sprintf("the timeout of %s is longer than the run interval of %s, so a slow run holds up the runs after it", ext.RunTimeout, ext.RunInterval)
+		if clamp {
+			ext.RunTimeout = ext.RunInterval
+			warning += ".  The timeout was clamped to " + ext.RunInterval.String()
+		}
+		warnings = append(warnings, warning)
+	}
+
+	phases := []struct {
+		name    string
+		timeout *time.Duration
+	}{
+		{"schedule", &ext.PhaseTimeouts.Schedule},
+		{"imagePull", &ext.PhaseTimeouts.ImagePull},
+		{"run", &ext.PhaseTimeouts.Run},
+		{"report", &ext.PhaseTimeouts.Report},
+		{"cleanup", &ext.PhaseTimeouts.Cleanup},
+	}
+	for _, phase := range phases {
+		if ext.RunTimeout <= 0 || *phase.timeout <= ext.RunTimeout {
+			continue
+		}
+		warning := fmt.Sprintf("the %s timeout budget of %s is longer than the timeout of %s, so it never applies", phase.name, *phase.timeout, ext.RunTimeout)
+		if clamp {
+			*phase.timeout = ext.RunTimeout
+			warning += ".  The budget was clamped to " + ext.RunTimeout.String()
+		}
+		warnings = append(warnings, warning)
+	}
+
+	if !ext.Daemon && ext.ResultTTL > 0 && ext.RunInterval > 0 && ext.ResultTTL < ext.RunInterval {
+		warnings = append(warnings, fmt.Sprintf("the result TTL of %s is shorter than the run interval of %s, so the health of the check is unknown between runs", ext.ResultTTL, ext.RunInterval))
+	}
+
+	// the pod can run out of time before it starts when starting it alone can take the whole timeout
+	startup := ext.PhaseTimeouts.Schedule + ext.PhaseTimeouts.ImagePull
+	if ext.PhaseTimeouts.Schedule > 0 && ext.PhaseTimeouts.ImagePull > 0 && ext.RunTimeout > 0 && startup >= ext.RunTimeout {
+		warnings = append(warnings, fmt.Sprintf("the schedule and imagePull timeout budgets add up to %s, which leaves no time of the timeout of %s for the checker pod to run", startup, ext.RunTimeout))
+	}
+	return warnings
+}
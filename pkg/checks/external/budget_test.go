@@ -0,0 +1,64 @@
+package external
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Comcast/kuberhealthy/v2/pkg/khcheckcrd"
+)
+
+// TestParseTimeoutBudget validates that the phases of a timeout budget are parsed and that invalid budgets
+// are rejected
+func TestParseTimeoutBudget(t *testing.T) {
+	timeouts, err := ParseTimeoutBudget(nil)
+	if err != nil || timeouts != (PhaseTimeouts{}) {
+		t.Fatal("Expected no budget to leave every phase unlimited but got", timeouts, err)
+	}
+
+	timeouts, err = ParseTimeoutBudget(&khcheckcrd.TimeoutBudget{ImagePull: "10m", Run: "30s"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := PhaseTimeouts{ImagePull: time.Minute * 10, Run: time.Second * 30}
+	if timeouts != expected {
+		t.Fatal("Expected", expected, "but got", timeouts)
+	}
+
+	for _, budget := range []khcheckcrd.TimeoutBudget{{Schedule: "soon"}, {Cleanup: "-1m"}, {Report: "0s"}} {
+		_, err = ParseTimeoutBudget(&budget)
+		if err == nil {
+			t.Fatal("Expected an error parsing budget", budget)
+		}
+	}
+}
+
+// TestValidateTiming validates that timeouts longer than the run interval and budgets longer than the timeout
+// are warned about, and clamped when asked to
+func TestValidateTiming(t *testing.T) {
+	ext := &Checker{RunInterval: time.Minute, RunTimeout: time.Minute * 5, PhaseTimeouts: PhaseTimeouts{Schedule: time.Minute * 3, ImagePull: time.Minute * 2}}
+	warnings := ext.ValidateTiming(false)
+	if len(warnings) != 2 || ext.RunTimeout != time.Minute*5 {
+		t.Fatal("Expected a warning about the timeout and the startup budgets without changing them but got", warnings, ext.RunTimeout)
+	}
+
+	warnings = ext.ValidateTiming(true)
+	if len(warnings) != 4 || ext.RunTimeout != time.Minute || ext.PhaseTimeouts.Schedule != time.Minute || ext.PhaseTimeouts.ImagePull != time.Minute {
+		t.Fatal("Expected the timeout to be clamped to the interval and the budgets to the timeout but got", warnings, ext.RunTimeout, ext.PhaseTimeouts)
+	}
+	if len(ext.ValidateTiming(false)) != 1 {
+		t.Fatal("Expected only the startup budgets to still be warned about once clamped but got", ext.ValidateTiming(false))
+	}
+
+	// checks whose runs can overlap can have a timeout longer than their interval
+	ext = &Checker{RunInterval: time.Minute, RunTimeout: time.Minute * 5, MaxOverlappingRuns: 4}
+	if warnings := ext.ValidateTiming(true); len(warnings) != 0 || ext.RunTimeout != time.Minute*5 {
+		t.Fatal("Expected no warnings for overlapping runs but got", warnings)
+	}
+
+	// results that expire before the next run leave the check unknown between runs
+	ext = &Checker{RunInterval: time.Minute * 10, RunTimeout: time.Minute, ResultTTL: time.Minute * 5}
+	if warnings := ext.ValidateTiming(false); len(warnings) != 1 || !strings.Contains(warnings[0], "result TTL") {
+		t.Fatal("Expected a warning about the result TTL but got", warnings)
+	}
+}
@@ -2,6 +2,9 @@
 // externally spawned checker pod to Kuberhealthy.  The URL that reports are
 // sent to are pulled from the environment variables of the pod because
 // Kuberhealthy sets them all all external checkers when they are spawned.
+//
+// Deprecated: use github.com/Comcast/kuberhealthy/v2/pkg/checkclient, which
+// adds retries, timeouts, and access to the check run deadline.
 package checkclient
 
 import (
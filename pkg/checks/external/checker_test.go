@@ -3,16 +3,20 @@ package external
 import (
 	"errors"
 	"log"
+	"os"
 	"testing"
 	"time"
 
 	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/fake"
 
 	"github.com/Comcast/kuberhealthy/v2/pkg/khcheckcrd"
 	"github.com/Comcast/kuberhealthy/v2/pkg/khstatecrd"
 	"github.com/Comcast/kuberhealthy/v2/pkg/kubeClient"
+	"github.com/Comcast/kuberhealthy/v2/pkg/statestore"
 
 	apiv1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
 var client *kubernetes.Clientset
@@ -29,6 +33,13 @@ var checkCRDScanInterval = time.Second * 5 // how often we scan for changes to c
 
 func init() {
 
+	// tests that need a real cluster are skipped when no kubeconfig is present.  The rest of the tests
+	// run against the fake clients in harness_test.go.
+	if _, err := os.Stat(kubeConfigFile); err != nil {
+		log.Println("No kubeconfig found at", kubeConfigFile, "- skipping tests that require a cluster")
+		return
+	}
+
 	// create a kubernetes clientset for our tests to use
 	var err error
 	client, err = kubeClient.Create(kubeConfigFile)
@@ -52,9 +63,16 @@ func init() {
 
 }
 
+// requireCluster skips the calling test when no cluster is available to run it against
+func requireCluster(t *testing.T) {
+	if client == nil {
+		t.Skip("skipping test that requires a kubernetes cluster")
+	}
+}
+
 // newTestChecker creates a new test checker struct with a basic set of defaults
 // that work out of the box
-func newTestChecker(client *kubernetes.Clientset) (*Checker, error) {
+func newTestChecker(client kubernetes.Interface) (*Checker, error) {
 	podCheckFile := "test/basicCheckerPod.yaml"
 	p, err := loadTestPodSpecFile(podCheckFile)
 	if err != nil {
@@ -66,6 +84,7 @@ func newTestChecker(client *kubernetes.Clientset) (*Checker, error) {
 
 // TestExternalChecker tests the external checker end to end
 func TestExternalChecker(t *testing.T) {
+	requireCluster(t)
 
 	// make a new default checker of this check
 	checker, err := newTestChecker(client)
@@ -83,9 +102,13 @@ func TestExternalChecker(t *testing.T) {
 
 // newTestCheckFromSpec creates a new test checker but using the supplied
 // spec file for a khcheck
-func newTestCheckFromSpec(client *kubernetes.Clientset, checkSpec *khcheckcrd.KuberhealthyCheck, reportingURL string) *Checker {
+func newTestCheckFromSpec(client kubernetes.Interface, checkSpec *khcheckcrd.KuberhealthyCheck, reportingURL string) *Checker {
 	// create a new checker and insert this pod spec
-	checker := New(client, checkSpec, khCheckClient, khStateClient, reportingURL) // external checker does not ever return an error so we drop it
+	var stateStore statestore.StateStore = statestore.NewMemoryStore()
+	if khStateClient != nil {
+		stateStore = statestore.NewCRDStore(khStateClient)
+	}
+	checker := New(client, checkSpec, khCheckClient, stateStore, reportingURL) // external checker does not ever return an error so we drop it
 	checker.Debug = true
 	return checker
 }
@@ -93,6 +116,7 @@ func newTestCheckFromSpec(client *kubernetes.Clientset, checkSpec *khcheckcrd.Ku
 // TestExternalCheckerSanitation tests the external checker in a situation
 // where it should fail
 func TestExternalCheckerSanitation(t *testing.T) {
+	requireCluster(t)
 	t.Parallel()
 
 	// make a new default checker of this check
@@ -164,6 +188,7 @@ func deleteKHCheckSpec(checkName string, checkNamespace string) error {
 // TestWriteWhitelistedUUID tests writing a UUID to a check without
 // removing other properties of the check
 func TestWriteWhitelistedUUID(t *testing.T) {
+	requireCluster(t)
 
 	// create a client for kubernetes
 	client, err := kubeClient.Create(kubeConfigFile)
@@ -231,6 +256,7 @@ func TestWriteWhitelistedUUID(t *testing.T) {
 // TestGetWhitelistedUUIDForExternalCheck validates that setting
 // and fetching whitelist UUIDs works properly
 func TestGetWhitelistedUUIDForExternalCheck(t *testing.T) {
+	requireCluster(t)
 	var testUUID = "test-UUID-1234"
 
 	// make an external check and cause it to write a whitelist
@@ -277,7 +303,7 @@ func TestGetWhitelistedUUIDForExternalCheck(t *testing.T) {
 }
 
 func TestSanityCheck(t *testing.T) {
-	c, err := newTestChecker(client)
+	c, err := newTestChecker(fake.NewSimpleClientset())
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -312,3 +338,45 @@ func TestSanityCheck(t *testing.T) {
 	}
 
 }
+
+// TestDryRun validates that a dry run renders the checker pod without creating it
+func TestDryRun(t *testing.T) {
+	kubeClient := fake.NewSimpleClientset()
+	c, err := newTestChecker(kubeClient)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p, err := c.DryRun()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Labels[kuberhealthyRunIDLabel] != DryRunUUID {
+		t.Fatal("Expected dry run pod to have the dry run UUID but got:", p.Labels[kuberhealthyRunIDLabel])
+	}
+	var foundUUID bool
+	for _, e := range p.Spec.Containers[0].Env {
+		if e.Name == KHRunUUID && e.Value == DryRunUUID {
+			foundUUID = true
+		}
+	}
+	if !foundUUID {
+		t.Fatal("Dry run pod was not given the", KHRunUUID, "environment variable")
+	}
+
+	// nothing should have been created on the cluster
+	pods, err := kubeClient.CoreV1().Pods(c.Namespace).List(metav1.ListOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pods.Items) != 0 {
+		t.Fatal("Dry run created", len(pods.Items), "pods")
+	}
+
+	// invalid pod specs are still rejected
+	c.OriginalPodSpec = apiv1.PodSpec{}
+	_, err = c.DryRun()
+	if err == nil {
+		t.Fatal("Expected dry run of a check with no containers to fail")
+	}
+}
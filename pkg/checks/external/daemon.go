@@ -0,0 +1,161 @@
+package external
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	apiv1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/Comcast/kuberhealthy/v2/pkg/tracing"
+)
+
+// runDaemon runs one iteration of a daemon mode check.  Daemon checks keep a single long-running checker
+// pod that reports in on its own schedule.  Each iteration starts the daemon pod if it is not running and
+// fails if the pod has not reported in within the check's timeout.  The daemon pod keeps the run UUID it
+// was started with, so the supplied run UUID is only used when a new daemon pod is started.
+func (ext *Checker) runDaemon(runID string) error {
+	running, err := ext.daemonRunning()
+	if err != nil {
+		return ext.newError("failed to look up daemon pod: " + err.Error())
+	}
+	if !running {
+		err = ext.startDaemon(runID)
+		if err != nil {
+			return err
+		}
+	}
+	return ext.checkDaemonReports()
+}
+
+// daemonRunning returns true if the daemon pod of this check exists and has not exited
+func (ext *Checker) daemonRunning() (bool, error) {
+	if len(ext.podName()) == 0 {
+		return false, nil
+	}
+	p, err := ext.getPodClient().Get(ext.podName(), metav1.GetOptions{})
+	if err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			ext.log("daemon pod", ext.podName(), "no longer exists")
+			return false, nil
+		}
+		return false, err
+	}
+	if p.Status.Phase != apiv1.PodRunning && p.Status.Phase != apiv1.PodPending {
+		ext.log("daemon pod", ext.podName(), "is no longer running with status", p.Status.Phase, p.Status.Message)
+		return false, nil
+	}
+	return true, nil
+}
+
+// startDaemon replaces any existing daemon pod of this check with a new one started with the supplied
+// run UUID and waits for it to start
+func (ext *Checker) startDaemon(runID string) error {
+
+	// create a context for this daemon pod that is canceled when the check shuts down
+	ext.shutdownCTX, ext.shutdownCTXFunc = context.WithCancel(context.Background())
+
+	// remove the previous daemon pod along with daemon pods left behind by another Kuberhealthy instance
+	if len(ext.podName()) > 0 {
+		err := ext.deletePod(ext.podName(), ext.RunTimeout)
+		if err != nil {
+			return ext.newError("failed to remove previous daemon pod: " + err.Error())
+		}
+	}
+	ext.cleanup()
+
+	// only the new daemon pod is allowed to report in
+	uuidSpan := tracing.Start("set run uuid", ext.runSpan.SpanContext())
+	err := ext.setCheckUUID(runID)
+	uuidSpan.SetError(err)
+	uuidSpan.Finish()
+	if err != nil {
+		return err
+	}
+	ext.regeneratePodName()
+	ext.nextArchitecture()
+	ext.runDeadline = time.Time{}
+
+	// configure and validate the daemon pod the same way as the pods of regular runs
+	err = ext.validatePodSpec()
+	if err != nil {
+		return NewConfigError(err)
+	}
+	err = ext.configureUserPodSpec()
+	if err != nil {
+		return ext.newError("failed to configure pod spec for Kubernetes from user specified pod spec: " + err.Error())
+	}
+	err = ext.ValidateReferences()
+	if err != nil {
+		return ext.newError(err.Error())
+	}
+	err = ext.ensureServiceAccount()
+	if err != nil {
+		return ext.newError("failed to create service account for checker pod: " + err.Error())
+	}
+	err = ext.createNetworkPolicy()
+	if err != nil {
+		return ext.newError("failed to create network policy for daemon pod: " + err.Error())
+	}
+	err = ext.sanityCheck()
+	if err != nil {
+		return err
+	}
+
+	ext.log("starting daemon pod for external check:", ext.CheckName)
+	createSpan := tracing.Start("create pod", ext.runSpan.SpanContext())
+	createdPod, err := ext.createPod()
+	createSpan.SetError(err)
+	createSpan.Finish()
+	if err != nil {
+		return ext.newError("failed to create daemon pod for checker: " + err.Error())
+	}
+	ext.daemonStarted = time.Now()
+
+	startSpan := tracing.Start("wait for pod start", ext.runSpan.SpanContext())
+	startSpan.SetAttribute("k8s.pod.name", createdPod.Name)
+	defer startSpan.Finish()
+	select {
+	case <-time.After(ext.RunTimeout):
+		ext.cleanup()
+		return ext.newTimeoutError("failed to see daemon pod running within timeout")
+	case err = <-ext.waitForPodStart(nil):
+		if err != nil {
+			ext.cleanup()
+			return ext.newError("error when waiting for daemon pod to start: " + err.Error())
+		}
+		ext.log("daemon pod is running:", ext.podName())
+	case <-ext.shutdownCTX.Done():
+		ext.log("shutting down check. aborting watch for daemon pod to start")
+	}
+	return nil
+}
+
+// checkDaemonReports returns a timeout error if the daemon pod has not reported in within the check's
+// timeout.  Newly started daemon pods are given the full timeout to send their first report.
+func (ext *Checker) checkDaemonReports() error {
+	state, err := ext.getKHState()
+	if err != nil {
+		return ext.newError("failed to fetch the last report of the daemon pod: " + err.Error())
+	}
+
+	since := ext.daemonStarted
+	if state.LastReport.After(since) {
+		since = state.LastReport
+	}
+	if time.Since(since) > ext.RunTimeout {
+		return ext.newTimeoutError("daemon pod " + ext.podName() + " has not reported in within " + ext.RunTimeout.String())
+	}
+	return nil
+}
+
+// stopDaemon removes the daemon pod of this check
+func (ext *Checker) stopDaemon() error {
+	if len(ext.podName()) == 0 {
+		return nil
+	}
+	ext.log("removing daemon pod", ext.podName())
+	defer ext.deleteNetworkPolicy()
+	return ext.deletePod(ext.podName(), ext.Timeout())
+}
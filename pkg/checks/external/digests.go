@@ -0,0 +1,70 @@
+package external
+
+import (
+	apiv1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/Comcast/kuberhealthy/v2/pkg/registry"
+)
+
+// PinImageDigests replaces the image tags of the checker pod and of its setup and teardown hooks with the
+// digests they currently point to, so that every run of the check uses the same image until the check is
+// reloaded.  Images that can not be resolved keep their tags and are returned as warnings.
+func (ext *Checker) PinImageDigests(resolver *registry.Resolver) []string {
+	creds, warnings := ext.imagePullCredentials()
+
+	pin := func(containers []apiv1.Container) {
+		for i := range containers {
+			if len(containers[i].Image) == 0 {
+				continue
+			}
+			pinned, err := resolver.Resolve(containers[i].Image, creds)
+			if err != nil {
+				warnings = append(warnings, "image of container "+containers[i].Name+" was not pinned to a digest: "+err.Error())
+				continue
+			}
+			if pinned != containers[i].Image {
+				ext.log("Pinned image", containers[i].Image, "of container", containers[i].Name, "to", pinned)
+			}
+			containers[i].Image = pinned
+		}
+	}
+	pin(ext.PodSpec.InitContainers)
+	pin(ext.PodSpec.Containers)
+	if ext.Setup != nil {
+		pin(ext.Setup.Containers)
+	}
+	if ext.Teardown != nil {
+		pin(ext.Teardown.Containers)
+	}
+	return warnings
+}
+
+// imagePullCredentials reads the registry credentials of the image pull secrets of the checker pod
+func (ext *Checker) imagePullCredentials() (registry.Credentials, []string) {
+	creds := make(registry.Credentials)
+	var warnings []string
+	for _, ref := range ext.PodSpec.ImagePullSecrets {
+		secret, err := ext.KubeClient.CoreV1().Secrets(ext.Namespace).Get(ref.Name, metav1.GetOptions{})
+		if err != nil {
+			warnings = append(warnings, "image pull secret "+ref.Name+" could not be read to resolve image digests: "+err.Error())
+			continue
+		}
+		config, ok := secret.Data[apiv1.DockerConfigJsonKey]
+		if !ok {
+			config, ok = secret.Data[apiv1.DockerConfigKey]
+		}
+		if !ok {
+			continue
+		}
+		secretCreds, err := registry.ParseDockerConfig(config)
+		if err != nil {
+			warnings = append(warnings, "image pull secret "+ref.Name+" could not be parsed: "+err.Error())
+			continue
+		}
+		for host, cred := range secretCreds {
+			creds[host] = cred
+		}
+	}
+	return creds, warnings
+}
@@ -0,0 +1,59 @@
+package external
+
+import (
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	apiv1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/Comcast/kuberhealthy/v2/pkg/registry"
+)
+
+// TestPinImageDigests validates that the images of a checker pod are pinned to their digests with the
+// credentials of its image pull secrets, and that images that can not be resolved keep their tags
+func TestPinImageDigests(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, pass, ok := r.BasicAuth()
+		if !ok || user != "robot" || pass != "secret" {
+			w.Header().Set("WWW-Authenticate", `Basic realm="registry"`)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if r.URL.Path != "/v2/checks/http/manifests/1.0" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Docker-Content-Digest", "sha256:0123")
+	}))
+	defer server.Close()
+	host := strings.TrimPrefix(server.URL, "https://")
+
+	auth := base64.StdEncoding.EncodeToString([]byte("robot:secret"))
+	client := fake.NewSimpleClientset(&apiv1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "pull", Namespace: defaultNamespace},
+		Type:       apiv1.SecretTypeDockerConfigJson,
+		Data:       map[string][]byte{apiv1.DockerConfigJsonKey: []byte(`{"auths":{"` + host + `":{"auth":"` + auth + `"}}}`)},
+	})
+	ext := &Checker{CheckName: "my-check", Namespace: defaultNamespace, KubeClient: client}
+	ext.PodSpec.ImagePullSecrets = []apiv1.LocalObjectReference{{Name: "pull"}}
+	ext.PodSpec.Containers = []apiv1.Container{
+		{Name: "main", Image: host + "/checks/http:1.0"},
+		{Name: "sidecar", Image: host + "/checks/missing:1.0"},
+	}
+
+	warnings := ext.PinImageDigests(registry.NewResolver(server.Client()))
+	if ext.PodSpec.Containers[0].Image != host+"/checks/http@sha256:0123" {
+		t.Fatal("Expected the main container to be pinned to its digest but got", ext.PodSpec.Containers[0].Image)
+	}
+	if ext.PodSpec.Containers[1].Image != host+"/checks/missing:1.0" {
+		t.Fatal("Expected an image that can not be resolved to keep its tag but got", ext.PodSpec.Containers[1].Image)
+	}
+	if len(warnings) != 1 || !strings.Contains(warnings[0], "sidecar") {
+		t.Fatal("Expected a warning about the sidecar image but got", warnings)
+	}
+}
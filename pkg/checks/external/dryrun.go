@@ -0,0 +1,56 @@
+package external
+
+import (
+	"errors"
+	"time"
+
+	"github.com/ghodss/yaml"
+	apiv1 "k8s.io/api/core/v1"
+)
+
+// DryRunUUID is the run UUID placed in checker pods rendered by a dry run
+const DryRunUUID = "00000000-0000-0000-0000-000000000000"
+
+// DryRun validates the check and returns the checker pod exactly as it would be created, with all
+// environment variables, labels, annotations, and security settings applied.  Nothing is created
+// on the cluster.  DryRun must not be called on a checker that is currently running.
+func (ext *Checker) DryRun() (*apiv1.Pod, error) {
+	if ext.Namespace == "" {
+		return nil, errors.New("check namespace can not be empty")
+	}
+	if ext.CheckName == "" {
+		return nil, errors.New("check name can not be empty")
+	}
+
+	if ext.currentCheckUUID == "" {
+		ext.currentCheckUUID = DryRunUUID
+	}
+	ext.regeneratePodName()
+	ext.nextArchitecture()
+	ext.runDeadline = time.Now().Add(ext.RunTimeout)
+
+	// the spec is configured first so that validation sees the current spec and not one left over from a previous run
+	err := ext.configureUserPodSpec()
+	if err != nil {
+		return nil, ext.newError("failed to configure pod spec for Kubernetes from user specified pod spec: " + err.Error())
+	}
+
+	err = ext.validatePodSpec()
+	if err != nil {
+		return nil, err
+	}
+
+	p := ext.podManifest()
+	p.APIVersion = "v1"
+	p.Kind = "Pod"
+	return p, nil
+}
+
+// DryRunYAML renders the checker pod from DryRun as YAML
+func (ext *Checker) DryRunYAML() ([]byte, error) {
+	p, err := ext.DryRun()
+	if err != nil {
+		return nil, err
+	}
+	return yaml.Marshal(p)
+}
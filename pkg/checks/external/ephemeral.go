@@ -0,0 +1,188 @@
+package external
+
+import (
+	"strings"
+
+	apiv1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	k8sErrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// EphemeralNamespaceLabel is applied to the ephemeral namespaces created for check runs.  Its value is the
+// namespace of the khcheck that owns the namespace, which is where reports from pods in the namespace count.
+const EphemeralNamespaceLabel = "kuberhealthy-check-namespace"
+
+// ephemeralNamespacePrefix prefixes the names of ephemeral namespaces
+const ephemeralNamespacePrefix = "khcheck-"
+
+// denyIngressPolicyName is the name of the network policy that denies ingress traffic to ephemeral namespaces
+const denyIngressPolicyName = "kuberhealthy-deny-ingress"
+
+// ephemeralQuotaName is the name of the resource quota applied to ephemeral namespaces
+const ephemeralQuotaName = "kuberhealthy-quota"
+
+// podNamespace returns the namespace checker pods of the current run are created in.  This is the ephemeral
+// namespace of the run when one was created, and the check's namespace otherwise.
+func (ext *Checker) podNamespace() string {
+	if len(ext.runNamespace) > 0 {
+		return ext.runNamespace
+	}
+	return ext.Namespace
+}
+
+// ephemeralNamespaceName returns the name of the ephemeral namespace for the current run.  Names are made of
+// the check name and the start of the run UUID and are kept within the 63 characters allowed for namespaces.
+func (ext *Checker) ephemeralNamespaceName() string {
+	runID := ext.currentCheckUUID
+	if len(runID) > 8 {
+		runID = runID[:8]
+	}
+	checkName := ext.CheckName
+	if max := 63 - len(ephemeralNamespacePrefix) - len(runID) - 1; len(checkName) > max {
+		checkName = checkName[:max]
+	}
+	return strings.ToLower(ephemeralNamespacePrefix + strings.TrimRight(checkName, "-") + "-" + runID)
+}
+
+// ephemeralNamespaceSelector selects the ephemeral namespaces created for this check
+func (ext *Checker) ephemeralNamespaceSelector() string {
+	return EphemeralNamespaceLabel + "=" + ext.Namespace + "," + KuberhealthyCheckNameLabel + "=" + ext.CheckName
+}
+
+// createEphemeralNamespace creates the namespace the current run happens in along with its resource quota
+// and network policy, and copies the secrets and config maps referenced by the check into it.  Ephemeral
+// namespaces left behind by earlier runs are removed first.  Nothing is done if the check did not ask for
+// an ephemeral namespace.
+func (ext *Checker) createEphemeralNamespace() error {
+	ext.runNamespace = ""
+	if ext.EphemeralNamespace == nil {
+		return nil
+	}
+
+	ext.deleteStaleEphemeralNamespaces()
+
+	name := ext.ephemeralNamespaceName()
+	ext.log("Creating ephemeral namespace", name, "for this run")
+	_, err := ext.KubeClient.CoreV1().Namespaces().Create(&apiv1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: name,
+			Labels: map[string]string{
+				EphemeralNamespaceLabel:    ext.Namespace,
+				KuberhealthyCheckNameLabel: ext.CheckName,
+				kuberhealthyRunIDLabel:     ext.currentCheckUUID,
+			},
+		},
+	})
+	if err != nil {
+		return err
+	}
+	ext.runNamespace = name
+
+	// limit the resources the check can use in the namespace
+	if len(ext.EphemeralNamespace.ResourceQuota) > 0 {
+		_, err = ext.KubeClient.CoreV1().ResourceQuotas(name).Create(&apiv1.ResourceQuota{
+			ObjectMeta: metav1.ObjectMeta{Name: ephemeralQuotaName, Namespace: name},
+			Spec:       apiv1.ResourceQuotaSpec{Hard: ext.EphemeralNamespace.ResourceQuota},
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	// an empty pod selector with no ingress rules denies all ingress traffic to every pod in the namespace
+	if !ext.EphemeralNamespace.AllowIngress {
+		_, err = ext.KubeClient.NetworkingV1().NetworkPolicies(name).Create(&networkingv1.NetworkPolicy{
+			ObjectMeta: metav1.ObjectMeta{Name: denyIngressPolicyName, Namespace: name},
+			Spec: networkingv1.NetworkPolicySpec{
+				PodSelector: metav1.LabelSelector{},
+				PolicyTypes: []networkingv1.PolicyType{networkingv1.PolicyTypeIngress},
+			},
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return ext.copyReferences()
+}
+
+// copyReferences copies the secrets and config maps referenced by the check from the check's namespace into
+// the ephemeral namespace of the current run, where the checker pod can mount them
+func (ext *Checker) copyReferences() error {
+	for _, ref := range ext.Secrets {
+		secret, err := ext.KubeClient.CoreV1().Secrets(ext.Namespace).Get(ref.Name, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+		_, err = ext.KubeClient.CoreV1().Secrets(ext.runNamespace).Create(&apiv1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: secret.Name, Namespace: ext.runNamespace, Labels: secret.Labels},
+			Type:       secret.Type,
+			Data:       secret.Data,
+		})
+		if err != nil && !k8sErrors.IsAlreadyExists(err) {
+			return err
+		}
+	}
+
+	for _, ref := range ext.ConfigMaps {
+		configMap, err := ext.KubeClient.CoreV1().ConfigMaps(ext.Namespace).Get(ref.Name, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+		_, err = ext.KubeClient.CoreV1().ConfigMaps(ext.runNamespace).Create(&apiv1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: configMap.Name, Namespace: ext.runNamespace, Labels: configMap.Labels},
+			Data:       configMap.Data,
+			BinaryData: configMap.BinaryData,
+		})
+		if err != nil && !k8sErrors.IsAlreadyExists(err) {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// deleteEphemeralNamespace deletes the ephemeral namespace of the current run, which removes everything the
+// check created in it.  Kubernetes finishes the deletion in the background.
+func (ext *Checker) deleteEphemeralNamespace() {
+	if len(ext.runNamespace) == 0 {
+		return
+	}
+	ext.log("Deleting ephemeral namespace", ext.runNamespace)
+	err := ext.deleteNamespace(ext.runNamespace)
+	if err != nil {
+		ext.log("error deleting ephemeral namespace", ext.runNamespace+":", err)
+	}
+	ext.runNamespace = ""
+}
+
+// deleteStaleEphemeralNamespaces deletes ephemeral namespaces of this check that were left behind, such as
+// when Kuberhealthy restarted in the middle of a run
+func (ext *Checker) deleteStaleEphemeralNamespaces() {
+	namespaces, err := ext.KubeClient.CoreV1().Namespaces().List(metav1.ListOptions{LabelSelector: ext.ephemeralNamespaceSelector()})
+	if err != nil {
+		ext.log("error listing ephemeral namespaces left behind by earlier runs:", err)
+		return
+	}
+	for _, ns := range namespaces.Items {
+		if ns.Status.Phase == apiv1.NamespaceTerminating {
+			continue
+		}
+		ext.log("Deleting ephemeral namespace", ns.Name, "left behind by an earlier run")
+		err = ext.deleteNamespace(ns.Name)
+		if err != nil {
+			ext.log("error deleting ephemeral namespace", ns.Name+":", err)
+		}
+	}
+}
+
+// deleteNamespace deletes a namespace in the background, ignoring namespaces that are already gone
+func (ext *Checker) deleteNamespace(name string) error {
+	propagation := metav1.DeletePropagationBackground
+	err := ext.KubeClient.CoreV1().Namespaces().Delete(name, &metav1.DeleteOptions{PropagationPolicy: &propagation})
+	if k8sErrors.IsNotFound(err) {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,23 @@
+package external
+
+import (
+	"testing"
+
+	apiv1 "k8s.io/api/core/v1"
+
+	"github.com/Comcast/kuberhealthy/v2/pkg/khcheckcrd"
+)
+
+// TestValidatePodSpecNamespaceScoped validates that checks can not ask for ephemeral namespaces when
+// Kuberhealthy only has namespaced permissions
+func TestValidatePodSpecNamespaceScoped(t *testing.T) {
+	ext := &Checker{CheckName: "my-check", EphemeralNamespace: &khcheckcrd.EphemeralNamespace{}}
+	ext.PodSpec.Containers = []apiv1.Container{{Name: "main", Image: "busybox:1.31"}}
+	if err := ext.validatePodSpec(); err != nil {
+		t.Fatal("Expected an ephemeral namespace to be allowed with cluster wide permissions but got", err)
+	}
+	ext.NamespaceScoped = true
+	if err := ext.validatePodSpec(); err == nil {
+		t.Fatal("Expected an ephemeral namespace to be rejected in namespaced mode")
+	}
+}
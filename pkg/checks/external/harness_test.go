@@ -0,0 +1,951 @@
+package external
+
+import (
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	apiv1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	k8sErrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+
+	"github.com/Comcast/kuberhealthy/v2/pkg/health"
+	"github.com/Comcast/kuberhealthy/v2/pkg/khcheckcrd"
+	"github.com/Comcast/kuberhealthy/v2/pkg/statestore"
+)
+
+func init() {
+	// the fake clients respond instantly, so there is no reason to wait between polls
+	pollInterval = time.Millisecond * 10
+	podResyncInterval = time.Millisecond * 100
+}
+
+// harness runs a checker against fake kubernetes clients and an in-memory state store so that
+// the lifecycle of checker pods can be driven by hand from tests
+type harness struct {
+	t          *testing.T
+	client     *fake.Clientset
+	stateStore statestore.StateStore
+	checker    *Checker
+
+	sync.Mutex
+	podWatches int // the number of pod watches the checker has opened
+}
+
+// newHarness creates a checker backed by fake clients.  A watch reactor is prepended to the fake clientset
+// so the harness can tell when the checker has started watching for pod events.  The reactor does not
+// handle the watch, so it still falls through to the fake clientset's object tracker.
+func newHarness(t *testing.T) *harness {
+	h := &harness{
+		t:          t,
+		client:     fake.NewSimpleClientset(),
+		stateStore: statestore.NewMemoryStore(),
+	}
+
+	h.client.PrependWatchReactor("pods", func(action k8stesting.Action) (bool, watch.Interface, error) {
+		h.Lock()
+		h.podWatches++
+		h.Unlock()
+		return false, nil, nil
+	})
+
+	spec, err := loadTestPodSpecFile("test/basicCheckerPod.yaml")
+	if err != nil {
+		t.Fatal("Unable to load kubernetes pod spec:", err)
+	}
+	spec.Namespace = defaultNamespace
+	h.checker = New(h.client, spec, nil, h.stateStore, DefaultKuberhealthyReportingURL)
+	// debug mode streams pod logs, which the fake clientset can not serve
+	h.checker.Debug = false
+	h.checker.RunTimeout = time.Second * 10
+	return h
+}
+
+// run starts a check run in the background and returns a channel that receives its result
+func (h *harness) run() chan error {
+	result := make(chan error, 1)
+	go func() {
+		result <- h.checker.Run(h.client)
+	}()
+	return result
+}
+
+// waitFor polls the supplied condition until it is true or the test times out
+func (h *harness) waitFor(description string, condition func() bool) {
+	deadline := time.Now().Add(time.Second * 5)
+	for !condition() {
+		if time.Now().After(deadline) {
+			h.t.Fatal("Timed out waiting for", description)
+		}
+		time.Sleep(time.Millisecond * 10)
+	}
+}
+
+// waitForPod waits for the checker to create its pod and start watching it, then returns the pod.  Pods
+// without a run UUID label were not created by the checker and are ignored.
+func (h *harness) waitForPod() *apiv1.Pod {
+	return h.waitForPodIn(defaultNamespace)
+}
+
+// waitForPodIn waits for the checker to create its pod in the supplied namespace and start watching it
+func (h *harness) waitForPodIn(namespace string) *apiv1.Pod {
+	var pod *apiv1.Pod
+	h.waitFor("checker pod to be created", func() bool {
+		pods, err := h.client.CoreV1().Pods(namespace).List(metav1.ListOptions{})
+		if err != nil {
+			return false
+		}
+		for i := range pods.Items {
+			if len(pods.Items[i].Labels[kuberhealthyRunIDLabel]) > 0 {
+				pod = &pods.Items[i]
+				return true
+			}
+		}
+		return false
+	})
+
+	// the checker watches for pod removal and for the pod to start running
+	h.waitFor("checker to watch its pod", func() bool {
+		h.Lock()
+		defer h.Unlock()
+		return h.podWatches >= 2
+	})
+	return pod
+}
+
+// setPodPhase moves the checker pod to the supplied phase, which notifies any watchers
+func (h *harness) setPodPhase(pod *apiv1.Pod, phase apiv1.PodPhase) {
+	pod.Status.Phase = phase
+	_, err := h.client.CoreV1().Pods(pod.Namespace).UpdateStatus(pod)
+	if err != nil {
+		h.t.Fatal("Failed to update checker pod phase:", err)
+	}
+}
+
+// report records a status report for the check the same way the report handler would
+func (h *harness) report() {
+	details, err := h.stateStore.Get(h.checker.CheckName, h.checker.Namespace)
+	if err != nil {
+		h.t.Fatal("Failed to fetch check state:", err)
+	}
+	details.OK = true
+	details.LastRun = time.Now()
+	details.LastReport = time.Now()
+	err = h.stateStore.Set(h.checker.CheckName, h.checker.Namespace, details)
+	if err != nil {
+		h.t.Fatal("Failed to store check state:", err)
+	}
+}
+
+// result waits for the check run to finish and returns its error
+func (h *harness) result(c chan error) error {
+	select {
+	case err := <-c:
+		return err
+	case <-time.After(time.Second * 15):
+		h.t.Fatal("Timed out waiting for check run to finish")
+	}
+	return nil
+}
+
+// TestHarnessPodCreation validates the checker pod is created with the labels and environment
+// variables that checks rely on
+func TestHarnessPodCreation(t *testing.T) {
+	h := newHarness(t)
+	c := h.run()
+	pod := h.waitForPod()
+
+	if pod.Labels[KuberhealthyCheckNameLabel] != h.checker.CheckName {
+		t.Fatal("Checker pod is missing the check name label:", pod.Labels)
+	}
+	if pod.Labels[kuberhealthyRunIDLabel] != h.checker.currentCheckUUID {
+		t.Fatal("Checker pod run id label", pod.Labels[kuberhealthyRunIDLabel], "does not match", h.checker.currentCheckUUID)
+	}
+	if pod.Annotations[KH_CHECK_NAME_ANNOTATION_KEY] != h.checker.CheckName {
+		t.Fatal("Checker pod is missing the check name annotation:", pod.Annotations)
+	}
+
+	env := make(map[string]string)
+	var namespaceFromField bool
+	for _, e := range pod.Spec.Containers[0].Env {
+		env[e.Name] = e.Value
+		if e.Name == KHPodNamespace && e.ValueFrom != nil && e.ValueFrom.FieldRef != nil {
+			namespaceFromField = e.ValueFrom.FieldRef.FieldPath == "metadata.namespace"
+		}
+	}
+	for _, name := range []string{KHReportingURL, KHRunUUID, KuberhealthyCheckDeadline} {
+		if len(env[name]) == 0 {
+			t.Fatal("Checker pod is missing environment variable", name)
+		}
+	}
+	if !namespaceFromField {
+		t.Fatal("Checker pod is not given its namespace from the downward API")
+	}
+	if env[KHRunUUID] != h.checker.currentCheckUUID {
+		t.Fatal("Checker pod was given run UUID", env[KHRunUUID], "but expected", h.checker.currentCheckUUID)
+	}
+	if env["SOME_ENV_VAR"] != "12345" {
+		t.Fatal("Checker pod lost the environment variables from its spec:", env)
+	}
+
+	// finish the run so the checker does not leak into other tests
+	h.setPodPhase(pod, apiv1.PodRunning)
+	h.report()
+	h.setPodPhase(pod, apiv1.PodSucceeded)
+	err := h.result(c)
+	if err != nil {
+		t.Fatal("Expected check run to succeed but got:", err)
+	}
+}
+
+// TestHarnessPodExit validates that a run completes once the pod starts, reports in, and exits
+func TestHarnessPodExit(t *testing.T) {
+	h := newHarness(t)
+	c := h.run()
+	pod := h.waitForPod()
+
+	pod.Spec.NodeName = "node-a"
+	h.setPodPhase(pod, apiv1.PodRunning)
+	h.report()
+	h.setPodPhase(pod, apiv1.PodSucceeded)
+
+	err := h.result(c)
+	if err != nil {
+		t.Fatal("Expected check run to succeed but got:", err)
+	}
+	details := h.checker.CheckerPod()
+	if details == nil || details.Name != pod.Name || details.Node != "node-a" {
+		t.Fatal("Expected the details of the checker pod on node-a but got", details)
+	}
+}
+
+// TestHarnessStartupTimeout validates that a run fails when its pod never starts running
+func TestHarnessStartupTimeout(t *testing.T) {
+	h := newHarness(t)
+	h.checker.RunTimeout = time.Millisecond * 500
+	c := h.run()
+	h.waitForPod()
+
+	err := h.result(c)
+	if err == nil {
+		t.Fatal("Expected check run to time out waiting for its pod to start")
+	}
+	if err.Error() != h.checker.newError("failed to see pod running within timeout").Error() {
+		t.Fatal("Expected a startup timeout but got:", err)
+	}
+	if !IsTimeout(err) {
+		t.Fatal("Expected startup timeout to be reported as a timeout")
+	}
+}
+
+// TestHarnessPodRemoved validates that a run is skipped when its pod is removed before it reports in
+func TestHarnessPodRemoved(t *testing.T) {
+	h := newHarness(t)
+	c := h.run()
+	pod := h.waitForPod()
+
+	err := h.client.CoreV1().Pods(pod.Namespace).Delete(pod.Name, &metav1.DeleteOptions{})
+	if err != nil {
+		t.Fatal("Failed to delete checker pod:", err)
+	}
+
+	err = h.result(c)
+	if err != ErrPodRemovedExpectedly {
+		t.Fatal("Expected the check run to be skipped but got:", err)
+	}
+}
+
+// TestHarnessWatchInterrupted validates that a run still sees its pod start and exit when the API server
+// closes pod watches and then stops sending events on them, as happens while it restarts
+func TestHarnessWatchInterrupted(t *testing.T) {
+	h := newHarness(t)
+	var closedWatches int
+	h.client.PrependWatchReactor("pods", func(action k8stesting.Action) (bool, watch.Interface, error) {
+		h.Lock()
+		defer h.Unlock()
+		h.podWatches++
+		w := watch.NewFake()
+		if closedWatches < 4 {
+			closedWatches++
+			w.Stop()
+		}
+		return true, w, nil
+	})
+	c := h.run()
+	pod := h.waitForPod()
+
+	h.setPodPhase(pod, apiv1.PodRunning)
+	h.report()
+	h.setPodPhase(pod, apiv1.PodSucceeded)
+
+	err := h.result(c)
+	if err != nil {
+		t.Fatal("Expected check run to succeed without pod watch events but got:", err)
+	}
+}
+
+// TestHarnessWatchMissedRemoval validates that a run notices its pod was removed while no watch saw it
+func TestHarnessWatchMissedRemoval(t *testing.T) {
+	h := newHarness(t)
+	h.client.PrependWatchReactor("pods", func(action k8stesting.Action) (bool, watch.Interface, error) {
+		h.Lock()
+		defer h.Unlock()
+		h.podWatches++
+		return true, watch.NewFake(), nil
+	})
+	c := h.run()
+	pod := h.waitForPod()
+	h.setPodPhase(pod, apiv1.PodRunning)
+
+	err := h.client.CoreV1().Pods(pod.Namespace).Delete(pod.Name, &metav1.DeleteOptions{})
+	if err != nil {
+		t.Fatal("Failed to delete checker pod:", err)
+	}
+
+	err = h.result(c)
+	if err != ErrPodRemovedExpectedly {
+		t.Fatal("Expected the check run to be skipped but got:", err)
+	}
+}
+
+// TestHarnessStuckPod validates that a run force deletes pods of earlier runs that are stuck terminating
+// before it creates its own pod, and leaves completed pods alone
+func TestHarnessStuckPod(t *testing.T) {
+	h := newHarness(t)
+	h.checker.PodForceDeleteAfter = time.Minute
+
+	deletedAt := metav1.NewTime(time.Now().Add(-time.Minute * 2))
+	stuck := &apiv1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              h.checker.CheckName + "-stuck",
+			Namespace:         defaultNamespace,
+			Labels:            map[string]string{KuberhealthyCheckNameLabel: h.checker.CheckName},
+			DeletionTimestamp: &deletedAt,
+		},
+		Status: apiv1.PodStatus{Phase: apiv1.PodRunning},
+	}
+	completed := &apiv1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      h.checker.CheckName + "-completed",
+			Namespace: defaultNamespace,
+			Labels:    map[string]string{KuberhealthyCheckNameLabel: h.checker.CheckName},
+		},
+		Status: apiv1.PodStatus{Phase: apiv1.PodSucceeded},
+	}
+	for _, p := range []*apiv1.Pod{stuck, completed} {
+		_, err := h.client.CoreV1().Pods(defaultNamespace).Create(p)
+		if err != nil {
+			t.Fatal("Failed to create pod of an earlier run:", err)
+		}
+	}
+
+	c := h.run()
+	pod := h.waitForPod()
+	_, err := h.client.CoreV1().Pods(defaultNamespace).Get(stuck.Name, metav1.GetOptions{})
+	if err == nil {
+		t.Fatal("Expected the stuck pod to be force deleted before the checker pod was created")
+	}
+	_, err = h.client.CoreV1().Pods(defaultNamespace).Get(completed.Name, metav1.GetOptions{})
+	if err != nil {
+		t.Fatal("Expected the completed pod to be left alone but got:", err)
+	}
+
+	h.setPodPhase(pod, apiv1.PodRunning)
+	h.report()
+	h.setPodPhase(pod, apiv1.PodSucceeded)
+	err = h.result(c)
+	if err != nil {
+		t.Fatal("Expected check run to succeed but got:", err)
+	}
+}
+
+// TestHarnessAdoptPod validates that a pod still working on a valid run is adopted instead of replaced, and
+// that running pods of stale runs are deleted by the next run that does not adopt them
+func TestHarnessAdoptPod(t *testing.T) {
+	h := newHarness(t)
+
+	details := health.NewCheckDetails()
+	details.CurrentUUID = "adopted-run"
+	err := h.stateStore.Set(h.checker.CheckName, h.checker.Namespace, details)
+	if err != nil {
+		t.Fatal("Failed to store check state:", err)
+	}
+	newPod := func(name string, runID string) *apiv1.Pod {
+		p := &apiv1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: defaultNamespace,
+				Labels: map[string]string{
+					KuberhealthyCheckNameLabel: h.checker.CheckName,
+					kuberhealthyRunIDLabel:     runID,
+				},
+				CreationTimestamp: metav1.NewTime(time.Now()),
+			},
+			Status: apiv1.PodStatus{Phase: apiv1.PodRunning},
+		}
+		p, err := h.client.CoreV1().Pods(defaultNamespace).Create(p)
+		if err != nil {
+			t.Fatal("Failed to create pod of an earlier run:", err)
+		}
+		return p
+	}
+	adopted := newPod(h.checker.CheckName+"-adopted", "adopted-run")
+	stale := newPod(h.checker.CheckName+"-stale", "stale-run")
+
+	// the run resumes with the adopted pod rather than creating a new one
+	c := h.run()
+	h.waitFor("checker to adopt the pod", func() bool {
+		h.Lock()
+		defer h.Unlock()
+		return h.podWatches >= 1
+	})
+	pods, _ := h.client.CoreV1().Pods(defaultNamespace).List(metav1.ListOptions{})
+	if len(pods.Items) != 2 {
+		t.Fatal("Expected no new checker pod to be created but got", pods.Items)
+	}
+	h.report()
+	h.setPodPhase(adopted, apiv1.PodSucceeded)
+	err = h.result(c)
+	if err != nil {
+		t.Fatal("Expected the adopted run to succeed but got:", err)
+	}
+	if h.checker.currentCheckUUID != "adopted-run" || h.checker.podName() != adopted.Name {
+		t.Fatal("Expected the run to keep the UUID and pod of the adopted run but got", h.checker.currentCheckUUID, h.checker.podName())
+	}
+
+	// with no valid run left, the next run removes the stale pod and starts its own
+	c = h.run()
+	var pod *apiv1.Pod
+	h.waitFor("checker pod to be created", func() bool {
+		pods, err := h.client.CoreV1().Pods(defaultNamespace).List(metav1.ListOptions{})
+		if err != nil {
+			return false
+		}
+		for i := range pods.Items {
+			if pods.Items[i].Name != adopted.Name && pods.Items[i].Name != stale.Name {
+				pod = &pods.Items[i]
+				return true
+			}
+		}
+		return false
+	})
+	_, err = h.client.CoreV1().Pods(defaultNamespace).Get(stale.Name, metav1.GetOptions{})
+	if err == nil {
+		t.Fatal("Expected the pod of the stale run to be deleted before the checker pod was created")
+	}
+	h.waitFor("checker to watch its pod", func() bool {
+		h.Lock()
+		defer h.Unlock()
+		return h.podWatches >= 3
+	})
+	h.setPodPhase(pod, apiv1.PodRunning)
+	h.report()
+	h.setPodPhase(pod, apiv1.PodSucceeded)
+	err = h.result(c)
+	if err != nil {
+		t.Fatal("Expected check run to succeed but got:", err)
+	}
+}
+
+// TestHarnessImagePullError validates that a run fails when its pod can not pull its image
+func TestHarnessImagePullError(t *testing.T) {
+	h := newHarness(t)
+	c := h.run()
+	pod := h.waitForPod()
+
+	pod.Status.ContainerStatuses = []apiv1.ContainerStatus{{
+		Name: "main",
+		State: apiv1.ContainerState{
+			Waiting: &apiv1.ContainerStateWaiting{Reason: "ErrImagePull"},
+		},
+	}}
+	h.setPodPhase(pod, apiv1.PodPending)
+
+	err := h.result(c)
+	if err == nil {
+		t.Fatal("Expected check run to fail on an image pull error")
+	}
+	t.Log("got expected error:", err)
+}
+
+// TestHarnessPodCreateFailure validates that a checker pod that could not be created is not left authorized to
+// report for its run, such as when another pod already had its name
+func TestHarnessPodCreateFailure(t *testing.T) {
+	h := newHarness(t)
+	h.client.PrependReactor("create", "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, k8sErrors.NewAlreadyExists(apiv1.Resource("pods"), "taken")
+	})
+
+	err := h.result(h.run())
+	if err == nil {
+		t.Fatal("Expected check run to fail when its pod can not be created")
+	}
+	details, err := h.stateStore.Get(h.checker.CheckName, h.checker.Namespace)
+	if err != nil {
+		t.Fatal("Failed to fetch check state:", err)
+	}
+	if len(details.RunPods) != 0 {
+		t.Fatal("Expected no checker pod to be recorded for the run but got", details.RunPods)
+	}
+}
+
+// TestHarnessRunWithID validates that a run triggered with a specific run UUID hands that UUID to its pod
+func TestHarnessRunWithID(t *testing.T) {
+	h := newHarness(t)
+	runID := "0e6a2b44-1e79-4b52-a8b6-5b8d3d7e4b0a"
+	c := make(chan error, 1)
+	go func() {
+		c <- h.checker.RunWithID(h.client, runID)
+	}()
+	pod := h.waitForPod()
+
+	if pod.Labels[kuberhealthyRunIDLabel] != runID {
+		t.Fatal("Expected checker pod to have run UUID", runID, "but got", pod.Labels[kuberhealthyRunIDLabel])
+	}
+	details, err := h.stateStore.Get(h.checker.CheckName, h.checker.Namespace)
+	if err != nil {
+		t.Fatal("Failed to fetch check state:", err)
+	}
+	if details.CurrentUUID != runID {
+		t.Fatal("Expected run UUID", runID, "to be whitelisted but found", details.CurrentUUID)
+	}
+
+	h.setPodPhase(pod, apiv1.PodRunning)
+	h.report()
+	h.setPodPhase(pod, apiv1.PodSucceeded)
+	err = h.result(c)
+	if err != nil {
+		t.Fatal("Expected check run to succeed but got:", err)
+	}
+}
+
+// TestHarnessReferences validates that referenced secrets and config maps are made available to the checker pod
+func TestHarnessReferences(t *testing.T) {
+	h := newHarness(t)
+	h.checker.Secrets = []khcheckcrd.ResourceRef{{Name: "credentials", MountPath: "/etc/credentials", Env: true, EnvPrefix: "CREDS_"}}
+	h.checker.ConfigMaps = []khcheckcrd.ResourceRef{{Name: "settings", Env: true}}
+	_, err := h.client.CoreV1().Secrets(defaultNamespace).Create(&apiv1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "credentials"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = h.client.CoreV1().ConfigMaps(defaultNamespace).Create(&apiv1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "settings"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := h.run()
+	pod := h.waitForPod()
+
+	var foundVolume bool
+	for _, v := range pod.Spec.Volumes {
+		if v.Secret != nil && v.Secret.SecretName == "credentials" {
+			foundVolume = true
+		}
+	}
+	if !foundVolume {
+		t.Fatal("Checker pod is missing a volume for the referenced secret:", pod.Spec.Volumes)
+	}
+	container := pod.Spec.Containers[0]
+	var foundMount bool
+	for _, m := range container.VolumeMounts {
+		if m.MountPath == "/etc/credentials" && m.ReadOnly {
+			foundMount = true
+		}
+	}
+	if !foundMount {
+		t.Fatal("Checker pod is missing a read only mount for the referenced secret:", container.VolumeMounts)
+	}
+	if len(container.EnvFrom) != 2 || container.EnvFrom[0].SecretRef == nil || container.EnvFrom[0].Prefix != "CREDS_" || container.EnvFrom[1].ConfigMapRef == nil {
+		t.Fatal("Checker pod was not given environment variables from the referenced resources:", container.EnvFrom)
+	}
+
+	h.setPodPhase(pod, apiv1.PodRunning)
+	h.report()
+	h.setPodPhase(pod, apiv1.PodSucceeded)
+	err = h.result(c)
+	if err != nil {
+		t.Fatal("Expected check run to succeed but got:", err)
+	}
+}
+
+// TestHarnessEphemeralNamespace validates that a run happens in its own namespace with a resource quota,
+// a network policy, and copies of referenced secrets, and that the namespace is deleted after the run
+func TestHarnessEphemeralNamespace(t *testing.T) {
+	h := newHarness(t)
+	h.checker.EphemeralNamespace = &khcheckcrd.EphemeralNamespace{
+		ResourceQuota: apiv1.ResourceList{apiv1.ResourcePods: resource.MustParse("2")},
+	}
+	h.checker.Secrets = []khcheckcrd.ResourceRef{{Name: "credentials", Env: true}}
+	_, err := h.client.CoreV1().Secrets(defaultNamespace).Create(&apiv1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "credentials"},
+		Data:       map[string][]byte{"token": []byte("secret")},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the selector is read before the run starts, since the run writes the checker's namespace settings
+	selector := h.checker.ephemeralNamespaceSelector()
+	c := h.run()
+	var namespace string
+	h.waitFor("ephemeral namespace to be created", func() bool {
+		namespaces, err := h.client.CoreV1().Namespaces().List(metav1.ListOptions{LabelSelector: selector})
+		if err != nil || len(namespaces.Items) == 0 {
+			return false
+		}
+		namespace = namespaces.Items[0].Name
+		return true
+	})
+	pod := h.waitForPodIn(namespace)
+
+	if pod.Annotations[KHCheckNamespaceAnnotation] != defaultNamespace {
+		t.Fatal("Expected checker pod to be annotated with the check namespace but got:", pod.Annotations)
+	}
+	quota, err := h.client.CoreV1().ResourceQuotas(namespace).Get(ephemeralQuotaName, metav1.GetOptions{})
+	if err != nil {
+		t.Fatal("Expected a resource quota in the ephemeral namespace but got:", err)
+	}
+	if quota.Spec.Hard.Pods().Value() != 2 {
+		t.Fatal("Expected the resource quota to limit pods to 2 but got", quota.Spec.Hard)
+	}
+	_, err = h.client.NetworkingV1().NetworkPolicies(namespace).Get(denyIngressPolicyName, metav1.GetOptions{})
+	if err != nil {
+		t.Fatal("Expected a network policy denying ingress in the ephemeral namespace but got:", err)
+	}
+	secret, err := h.client.CoreV1().Secrets(namespace).Get("credentials", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal("Expected the referenced secret to be copied into the ephemeral namespace but got:", err)
+	}
+	if string(secret.Data["token"]) != "secret" {
+		t.Fatal("Expected the copied secret to keep its data but got", secret.Data)
+	}
+
+	h.setPodPhase(pod, apiv1.PodRunning)
+	h.report()
+	h.setPodPhase(pod, apiv1.PodSucceeded)
+	err = h.result(c)
+	if err != nil {
+		t.Fatal("Expected check run to succeed but got:", err)
+	}
+	_, err = h.client.CoreV1().Namespaces().Get(namespace, metav1.GetOptions{})
+	if !k8sErrors.IsNotFound(err) {
+		t.Fatal("Expected the ephemeral namespace to be deleted after the run but got:", err)
+	}
+}
+
+// TestHarnessNetworkPolicy validates that the egress traffic of the checker pod is limited by a network
+// policy that allows DNS, reports, and the declared destinations, and that the policy is removed after the run
+func TestHarnessNetworkPolicy(t *testing.T) {
+	h := newHarness(t)
+	h.checker.KuberhealthyNamespace = "kuberhealthy"
+	h.checker.NetworkPolicy = &khcheckcrd.NetworkPolicyConfig{
+		Egress: []networkingv1.NetworkPolicyEgressRule{{
+			To: []networkingv1.NetworkPolicyPeer{{IPBlock: &networkingv1.IPBlock{CIDR: "10.0.0.0/8"}}},
+		}},
+	}
+	_, err := h.client.NetworkingV1().NetworkPolicies(defaultNamespace).Create(&networkingv1.NetworkPolicy{
+		ObjectMeta: metav1.ObjectMeta{Name: "left-behind", Labels: map[string]string{KuberhealthyCheckNameLabel: h.checker.CheckName}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := h.run()
+	pod := h.waitForPod()
+
+	policies, err := h.client.NetworkingV1().NetworkPolicies(defaultNamespace).List(metav1.ListOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(policies.Items) != 1 || policies.Items[0].Name != pod.Name {
+		t.Fatal("Expected only a network policy named after the checker pod but got", policies.Items)
+	}
+	policy := policies.Items[0]
+	if policy.Spec.PodSelector.MatchLabels[kuberhealthyRunIDLabel] != pod.Labels[kuberhealthyRunIDLabel] {
+		t.Fatal("Expected the network policy to select the checker pod but got", policy.Spec.PodSelector)
+	}
+	if len(policy.Spec.PolicyTypes) != 1 || policy.Spec.PolicyTypes[0] != networkingv1.PolicyTypeEgress {
+		t.Fatal("Expected the network policy to only limit egress but got", policy.Spec.PolicyTypes)
+	}
+	if len(policy.Spec.Egress) != 3 {
+		t.Fatal("Expected egress rules for DNS, reporting, and the declared destination but got", policy.Spec.Egress)
+	}
+	reporting := policy.Spec.Egress[1].To[0]
+	if reporting.PodSelector.MatchLabels["app"] != "kuberhealthy" || reporting.NamespaceSelector.MatchLabels[namespaceNameLabel] != "kuberhealthy" {
+		t.Fatal("Expected the network policy to allow reports to the Kuberhealthy pods but got", reporting)
+	}
+	if policy.Spec.Egress[2].To[0].IPBlock.CIDR != "10.0.0.0/8" {
+		t.Fatal("Expected the network policy to allow the declared destination but got", policy.Spec.Egress[2])
+	}
+
+	h.setPodPhase(pod, apiv1.PodRunning)
+	h.report()
+	h.setPodPhase(pod, apiv1.PodSucceeded)
+	err = h.result(c)
+	if err != nil {
+		t.Fatal("Expected check run to succeed but got:", err)
+	}
+	policies, err = h.client.NetworkingV1().NetworkPolicies(defaultNamespace).List(metav1.ListOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(policies.Items) != 0 {
+		t.Fatal("Expected the network policy to be deleted after the run but got", policies.Items)
+	}
+}
+
+// TestHarnessMissingReference validates that a run fails before creating a pod when a referenced secret is missing
+func TestHarnessMissingReference(t *testing.T) {
+	h := newHarness(t)
+	h.checker.Secrets = []khcheckcrd.ResourceRef{{Name: "missing", Env: true}}
+
+	err := h.result(h.run())
+	if err == nil {
+		t.Fatal("Expected check run to fail when a referenced secret is missing")
+	}
+	t.Log("got expected error:", err)
+
+	pods, err := h.client.CoreV1().Pods(defaultNamespace).List(metav1.ListOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pods.Items) != 0 {
+		t.Fatal("Expected no checker pod to be created but found", len(pods.Items))
+	}
+
+	// references must say how the resource is used
+	h.checker.Secrets = []khcheckcrd.ResourceRef{{Name: "missing"}}
+	err = h.checker.ValidateReferences()
+	if err == nil {
+		t.Fatal("Expected a reference without a mountPath or env to be rejected")
+	}
+}
+
+// TestHarnessDaemon validates that daemon checks keep one pod running, fail when it stops reporting in,
+// and replace it when it exits
+func TestHarnessDaemon(t *testing.T) {
+	h := newHarness(t)
+	h.checker.Daemon = true
+	h.checker.RunTimeout = time.Millisecond * 500
+
+	// the first run starts the daemon pod
+	c := h.run()
+	h.waitFor("daemon pod to be watched", func() bool {
+		h.Lock()
+		defer h.Unlock()
+		return h.podWatches >= 1
+	})
+	pods, err := h.client.CoreV1().Pods(defaultNamespace).List(metav1.ListOptions{})
+	if err != nil || len(pods.Items) != 1 {
+		t.Fatal("Expected one daemon pod but got", pods, err)
+	}
+	pod := &pods.Items[0]
+	if pod.Spec.RestartPolicy != apiv1.RestartPolicyAlways {
+		t.Fatal("Expected the daemon pod to always be restarted but got", pod.Spec.RestartPolicy)
+	}
+	for _, e := range pod.Spec.Containers[0].Env {
+		if e.Name == KuberhealthyCheckDeadline {
+			t.Fatal("Expected the daemon pod to have no run deadline")
+		}
+	}
+	h.setPodPhase(pod, apiv1.PodRunning)
+	err = h.result(c)
+	if err != nil {
+		t.Fatal("Expected the daemon pod to be given time to report in but got:", err)
+	}
+
+	// runs fail once the daemon pod stops reporting in and recover when it reports again
+	time.Sleep(h.checker.RunTimeout)
+	err = h.result(h.run())
+	if !IsTimeout(err) {
+		t.Fatal("Expected a timeout when the daemon pod has not reported in but got:", err)
+	}
+	h.report()
+	err = h.result(h.run())
+	if err != nil {
+		t.Fatal("Expected the run to succeed after the daemon pod reported in but got:", err)
+	}
+	pods, _ = h.client.CoreV1().Pods(defaultNamespace).List(metav1.ListOptions{})
+	if len(pods.Items) != 1 || pods.Items[0].Labels[kuberhealthyRunIDLabel] != pod.Labels[kuberhealthyRunIDLabel] {
+		t.Fatal("Expected the daemon pod to be kept between runs but got", pods.Items)
+	}
+
+	// a daemon pod that exits is replaced with a new run UUID
+	h.setPodPhase(pod, apiv1.PodFailed)
+	c = h.run()
+	h.waitFor("replacement daemon pod to be watched", func() bool {
+		h.Lock()
+		defer h.Unlock()
+		return h.podWatches >= 2
+	})
+	pods, _ = h.client.CoreV1().Pods(defaultNamespace).List(metav1.ListOptions{})
+	if len(pods.Items) != 1 || pods.Items[0].Labels[kuberhealthyRunIDLabel] == pod.Labels[kuberhealthyRunIDLabel] {
+		t.Fatal("Expected the exited daemon pod to be replaced but got", pods.Items)
+	}
+	h.setPodPhase(&pods.Items[0], apiv1.PodRunning)
+	err = h.result(c)
+	if err != nil {
+		t.Fatal("Expected the replacement daemon pod to start but got:", err)
+	}
+	details, err := h.stateStore.Get(h.checker.CheckName, h.checker.Namespace)
+	if err != nil || details.CurrentUUID != pods.Items[0].Labels[kuberhealthyRunIDLabel] {
+		t.Fatal("Expected the replacement daemon pod to be whitelisted but got", details.CurrentUUID, err)
+	}
+}
+
+// waitForTeardownPod waits for the checker to create the teardown pod of the supplied checker pod
+func (h *harness) waitForTeardownPod(pod *apiv1.Pod) *apiv1.Pod {
+	var teardown *apiv1.Pod
+	h.waitFor("teardown pod to be created", func() bool {
+		var err error
+		teardown, err = h.client.CoreV1().Pods(pod.Namespace).Get(pod.Name+"-teardown", metav1.GetOptions{})
+		return err == nil
+	})
+	return teardown
+}
+
+// TestHarnessHooks validates that the setup hook runs as init containers of the checker pod and that the
+// teardown hook runs in its own pod once the checker pod is done
+func TestHarnessHooks(t *testing.T) {
+	h := newHarness(t)
+	h.checker.Setup = &khcheckcrd.Hook{Command: []string{"/app/create-fixture"}}
+	h.checker.Teardown = &khcheckcrd.Hook{Containers: []apiv1.Container{
+		{Name: "clean", Image: "busybox"},
+		{Name: "verify", Image: "busybox"},
+	}}
+	c := h.run()
+	pod := h.waitForPod()
+
+	if len(pod.Spec.InitContainers) == 0 || pod.Spec.InitContainers[0].Name != "setup" {
+		t.Fatal("Expected the setup hook to be the first init container but got", pod.Spec.InitContainers)
+	}
+	setup := pod.Spec.InitContainers[0]
+	if setup.Image != pod.Spec.Containers[0].Image || setup.Command[0] != "/app/create-fixture" {
+		t.Fatal("Expected the setup command to run in the image of the checker pod but got", setup)
+	}
+	if !containsEnvVarName(KHRunUUID, envVarNames(setup.Env)) {
+		t.Fatal("Expected the setup hook to be given the run UUID but got", setup.Env)
+	}
+
+	h.setPodPhase(pod, apiv1.PodRunning)
+	h.report()
+	h.setPodPhase(pod, apiv1.PodSucceeded)
+
+	teardown := h.waitForTeardownPod(pod)
+	if teardown.Labels[HookLabel] != "teardown" || teardown.Labels[kuberhealthyRunIDLabel] != pod.Labels[kuberhealthyRunIDLabel] {
+		t.Fatal("Expected the teardown pod to be labeled with its hook and run but got", teardown.Labels)
+	}
+	if len(teardown.Spec.InitContainers) != 1 || teardown.Spec.InitContainers[0].Name != "clean" || len(teardown.Spec.Containers) != 1 || teardown.Spec.Containers[0].Name != "verify" {
+		t.Fatal("Expected the teardown containers to run one after another but got", teardown.Spec.InitContainers, teardown.Spec.Containers)
+	}
+	h.setPodPhase(teardown, apiv1.PodSucceeded)
+
+	err := h.result(c)
+	if err != nil {
+		t.Fatal("Expected check run to succeed but got:", err)
+	}
+	_, err = h.client.CoreV1().Pods(teardown.Namespace).Get(teardown.Name, metav1.GetOptions{})
+	if !k8sErrors.IsNotFound(err) {
+		t.Fatal("Expected the teardown pod to be deleted but got", err)
+	}
+}
+
+// TestHarnessTeardownAfterTimeout validates that the teardown hook runs when a run times out and that the run
+// still reports its timeout when the teardown fails
+func TestHarnessTeardownAfterTimeout(t *testing.T) {
+	h := newHarness(t)
+	h.checker.RunTimeout = time.Millisecond * 500
+	h.checker.Teardown = &khcheckcrd.Hook{Command: []string{"/app/delete-fixture"}}
+	c := h.run()
+	pod := h.waitForPod()
+
+	teardown := h.waitForTeardownPod(pod)
+	h.setPodPhase(teardown, apiv1.PodFailed)
+
+	err := h.result(c)
+	if !IsTimeout(err) {
+		t.Fatal("Expected the run to time out but got:", err)
+	}
+}
+
+// TestHarnessSetupFailure validates that a run fails as soon as its setup hook fails
+func TestHarnessSetupFailure(t *testing.T) {
+	h := newHarness(t)
+	h.checker.Setup = &khcheckcrd.Hook{Command: []string{"/app/create-fixture"}}
+	c := h.run()
+	pod := h.waitForPod()
+
+	pod.Status.InitContainerStatuses = []apiv1.ContainerStatus{{
+		Name:  "setup",
+		State: apiv1.ContainerState{Terminated: &apiv1.ContainerStateTerminated{ExitCode: 1}},
+	}}
+	h.setPodPhase(pod, apiv1.PodFailed)
+
+	err := h.result(c)
+	if err == nil || !strings.Contains(err.Error(), "init container setup exited with code 1") {
+		t.Fatal("Expected the run to fail on the setup hook but got:", err)
+	}
+}
+
+// envVarNames returns the names of the supplied environment variables
+func envVarNames(env []apiv1.EnvVar) []string {
+	var names []string
+	for _, e := range env {
+		names = append(names, e.Name)
+	}
+	return names
+}
+
+// TestHarnessScheduleBudget validates that a run times out when its pod is not scheduled within the schedule
+// budget, even though the run timeout has not passed
+func TestHarnessScheduleBudget(t *testing.T) {
+	h := newHarness(t)
+	h.checker.PhaseTimeouts = PhaseTimeouts{Schedule: time.Millisecond * 300}
+	c := h.run()
+	h.waitForPod()
+
+	err := h.result(c)
+	if !IsTimeout(err) || !strings.Contains(err.Error(), "schedule budget") {
+		t.Fatal("Expected the run to run out of its schedule budget but got:", err)
+	}
+}
+
+// TestHarnessImagePullBudget validates that the image pull budget starts once the pod is scheduled
+func TestHarnessImagePullBudget(t *testing.T) {
+	h := newHarness(t)
+	h.checker.PhaseTimeouts = PhaseTimeouts{Schedule: time.Second * 5, ImagePull: time.Millisecond * 300}
+	c := h.run()
+	pod := h.waitForPod()
+
+	pod.Spec.NodeName = "node-1"
+	_, err := h.client.CoreV1().Pods(pod.Namespace).Update(pod)
+	if err != nil {
+		t.Fatal("Failed to schedule checker pod:", err)
+	}
+
+	err = h.result(c)
+	if !IsTimeout(err) || !strings.Contains(err.Error(), "image pull budget") {
+		t.Fatal("Expected the run to run out of its image pull budget but got:", err)
+	}
+}
+
+// TestHarnessRunBudget validates that a run times out when its pod does not report in within the run budget
+func TestHarnessRunBudget(t *testing.T) {
+	h := newHarness(t)
+	h.checker.PhaseTimeouts = PhaseTimeouts{Run: time.Millisecond * 300}
+	c := h.run()
+	pod := h.waitForPod()
+	h.setPodPhase(pod, apiv1.PodRunning)
+
+	err := h.result(c)
+	if !IsTimeout(err) || !strings.Contains(err.Error(), "run budget") {
+		t.Fatal("Expected the run to run out of its run budget but got:", err)
+	}
+}
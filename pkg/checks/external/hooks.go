@@ -0,0 +1,170 @@
+package external
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	apiv1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/Comcast/kuberhealthy/v2/pkg/khcheckcrd"
+)
+
+// HookLabel is set on the pods that run the teardown hook of a check, which tells them apart from checker pods
+const HookLabel = "kuberhealthy-hook"
+
+// DefaultTeardownTimeout is how long the teardown of a run can take when the check does not set a timeout
+const DefaultTeardownTimeout = time.Minute * 5
+
+// the names of the hooks, which are also the names of the containers that run their commands
+const hookSetup = "setup"
+const hookTeardown = "teardown"
+
+// hookContainers returns the containers that run a hook in the order they run.  The hook's command runs first
+// in a container named after the hook with the image of the checker pod's first container.
+func (ext *Checker) hookContainers(name string, hook *khcheckcrd.Hook) []apiv1.Container {
+	if hook == nil {
+		return nil
+	}
+	var containers []apiv1.Container
+	if len(hook.Command) > 0 && len(ext.OriginalPodSpec.Containers) > 0 {
+		main := ext.OriginalPodSpec.Containers[0]
+		containers = append(containers, apiv1.Container{
+			Name:            name,
+			Image:           main.Image,
+			ImagePullPolicy: main.ImagePullPolicy,
+			Command:         hook.Command,
+			Resources:       main.Resources,
+		})
+	}
+	for _, c := range hook.Containers {
+		containers = append(containers, *c.DeepCopy())
+	}
+	return containers
+}
+
+// validateHooks ensures that the setup and teardown hooks of the check have something to run
+func (ext *Checker) validateHooks() error {
+	hooks := map[string]*khcheckcrd.Hook{hookSetup: ext.Setup, hookTeardown: ext.Teardown}
+	for _, name := range []string{hookSetup, hookTeardown} {
+		hook := hooks[name]
+		if hook == nil {
+			continue
+		}
+		if len(hook.Command) == 0 && len(hook.Containers) == 0 {
+			return errors.New("the " + name + " hook has no command or containers")
+		}
+		for _, c := range hook.Containers {
+			if len(c.Image) == 0 {
+				return errors.New("no image found in the " + name + " hook for container " + c.Name + ".")
+			}
+		}
+	}
+	return nil
+}
+
+// configureHookContainers gives the containers of a hook the same environment variables and references as
+// the containers of the checker pod
+func (ext *Checker) configureHookContainers(containers []apiv1.Container, envVars []apiv1.EnvVar, injectedVarNames []string) {
+	for i := range containers {
+		containers[i].Env = resetInjectedContainerEnvVars(containers[i].Env, injectedVarNames)
+		containers[i].Env = append(containers[i].Env, envVars...)
+		ext.mountReferences(&containers[i])
+	}
+}
+
+// newTeardownPodSpec builds the spec of the teardown pod from the configured spec of the checker pod, so that
+// the teardown runs with the same service account, volumes, and scheduling.  All but the last container run
+// as init containers so that the containers run one after another.
+func newTeardownPodSpec(spec apiv1.PodSpec, containers []apiv1.Container) *apiv1.PodSpec {
+	teardown := spec.DeepCopy()
+	teardown.InitContainers = containers[:len(containers)-1]
+	teardown.Containers = containers[len(containers)-1:]
+	teardown.RestartPolicy = apiv1.RestartPolicyNever
+	return teardown
+}
+
+// teardownPodName returns the name of the pod that runs the teardown hook of the current run
+func (ext *Checker) teardownPodName() string {
+	return ext.podName() + "-" + hookTeardown
+}
+
+// TeardownDuration returns how long the teardown hook can keep a run going after its timeout, which is the
+// teardown timeout for checks that have a teardown hook
+func (ext *Checker) TeardownDuration() time.Duration {
+	if ext.Teardown == nil {
+		return 0
+	}
+	return ext.TeardownTimeout
+}
+
+// finishRun runs the teardown hook once the checker pod of the current run is done, whether the run
+// succeeded, failed, or timed out, and returns the result of the run.  A failed teardown fails a run that
+// otherwise succeeded.  Runs aborted by a shutdown are not torn down because their pod is left running for
+// the next Kuberhealthy instance to adopt.
+func (ext *Checker) finishRun(runErr error) error {
+	if ext.Teardown == nil || ext.teardownPodSpec == nil {
+		return runErr
+	}
+	if ext.shutdownCTX.Err() != nil {
+		ext.log("Skipping teardown of a run aborted by shutdown")
+		return runErr
+	}
+
+	err := ext.runTeardown()
+	if err != nil {
+		ext.log("error running teardown:", err)
+		if runErr == nil {
+			return ext.newError("teardown failed: " + err.Error())
+		}
+	}
+	return runErr
+}
+
+// runTeardown creates the pod that runs the teardown hook of the current run and waits up to the teardown
+// timeout for it to exit.  The pod is deleted once it exits or times out.
+func (ext *Checker) runTeardown() error {
+	name := ext.teardownPodName()
+	p := ext.podManifest()
+	p.Name = name
+	p.Spec = *ext.teardownPodSpec
+	p.Labels[HookLabel] = hookTeardown
+	delete(p.Annotations, PodSpecMutationsAnnotation)
+
+	ext.log("Creating teardown pod", name)
+	err := ext.waitForPodRateLimit(context.Background(), "create")
+	if err != nil {
+		return err
+	}
+	_, err = ext.getPodClient().Create(p)
+	if err != nil {
+		return fmt.Errorf("error creating teardown pod %s: %w", name, err)
+	}
+	defer func() {
+		err := ext.deletePodWithGracePeriod(name, ext.PodDeleteGracePeriod)
+		if err != nil {
+			ext.log("error deleting teardown pod", name+":", err)
+		}
+	}()
+
+	deadline := time.Now().Add(ext.TeardownTimeout)
+	for {
+		p, err := ext.getPodClient().Get(name, metav1.GetOptions{})
+		if err != nil {
+			return fmt.Errorf("error getting teardown pod %s: %w", name, err)
+		}
+		switch p.Status.Phase {
+		case apiv1.PodSucceeded:
+			ext.log("Teardown pod", name, "succeeded")
+			return nil
+		case apiv1.PodFailed:
+			return fmt.Errorf("teardown pod %s failed", name)
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("teardown pod %s did not finish within %s", name, ext.TeardownTimeout)
+		}
+		time.Sleep(pollInterval)
+	}
+}
@@ -0,0 +1,42 @@
+package external
+
+import "fmt"
+
+// ValidateImages ensures that every container of the checker pod and of its setup and teardown hooks runs an
+// image that the image policy allows
+func (ext *Checker) ValidateImages() error {
+	if !ext.ImagePolicy.Enabled() {
+		return nil
+	}
+
+	type container struct {
+		kind  string
+		name  string
+		image string
+	}
+	var containers []container
+	for _, c := range ext.PodSpec.InitContainers {
+		containers = append(containers, container{"init container", c.Name, c.Image})
+	}
+	for _, c := range ext.PodSpec.Containers {
+		containers = append(containers, container{"container", c.Name, c.Image})
+	}
+	if ext.Setup != nil {
+		for _, c := range ext.Setup.Containers {
+			containers = append(containers, container{"setup container", c.Name, c.Image})
+		}
+	}
+	if ext.Teardown != nil {
+		for _, c := range ext.Teardown.Containers {
+			containers = append(containers, container{"teardown container", c.Name, c.Image})
+		}
+	}
+
+	for _, c := range containers {
+		if len(c.image) == 0 || ext.ImagePolicy.Allows(c.image) {
+			continue
+		}
+		return fmt.Errorf("image %s of %s %s of check %s is not allowed by the check image allowlist %s", c.image, c.kind, c.name, ext.CheckName, ext.ImagePolicy)
+	}
+	return nil
+}
@@ -0,0 +1,42 @@
+package external
+
+import (
+	"testing"
+
+	apiv1 "k8s.io/api/core/v1"
+
+	"github.com/Comcast/kuberhealthy/v2/pkg/imagepolicy"
+	"github.com/Comcast/kuberhealthy/v2/pkg/khcheckcrd"
+)
+
+// TestValidateImages validates that checks are rejected when any of their containers runs an image that is
+// not in the image allowlist
+func TestValidateImages(t *testing.T) {
+	policy, err := imagepolicy.ParsePolicy("quay.io/comcast/*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ext := &Checker{CheckName: "my-check"}
+	ext.PodSpec.Containers = []apiv1.Container{{Name: "main", Image: "busybox:1.31"}}
+	if err := ext.ValidateImages(); err != nil {
+		t.Fatal("Expected every image to be allowed without an image policy but got", err)
+	}
+
+	ext.ImagePolicy = policy
+	err = ext.ValidateImages()
+	if err == nil {
+		t.Fatal("Expected an error for an image that is not in the allowlist")
+	}
+	if err := ext.validatePodSpec(); err == nil {
+		t.Fatal("Expected the pod spec to be invalid when its image is not in the allowlist")
+	}
+
+	ext.PodSpec.Containers[0].Image = "quay.io/comcast/khcheck:1.0.0"
+	if err := ext.ValidateImages(); err != nil {
+		t.Fatal("Expected an allowed image to pass but got", err)
+	}
+	ext.Teardown = &khcheckcrd.Hook{Containers: []apiv1.Container{{Name: "cleanup", Image: "alpine:3.11"}}}
+	if err := ext.ValidateImages(); err == nil {
+		t.Fatal("Expected an error for a teardown container image that is not in the allowlist")
+	}
+}
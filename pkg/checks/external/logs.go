@@ -0,0 +1,65 @@
+package external
+
+import (
+	apiv1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// podLogTailLines and podLogLimitBytes bound how much of each container's logs is captured from a failed
+// checker pod
+const podLogTailLines = 1000
+const podLogLimitBytes = 256 * 1024
+
+// PodLogs returns the logs of each container of the checker pod of the last run, if the run failed and
+// CollectPodLogs is enabled
+func (ext *Checker) PodLogs() map[string]string {
+	ext.podLogsMu.RLock()
+	defer ext.podLogsMu.RUnlock()
+	return ext.podLogs
+}
+
+// setPodLogs replaces the captured logs of the last run
+func (ext *Checker) setPodLogs(logs map[string]string) {
+	ext.podLogsMu.Lock()
+	defer ext.podLogsMu.Unlock()
+	ext.podLogs = logs
+}
+
+// collectPodLogs captures the logs of the current checker pod when the run ended with the supplied error or
+// the pod reported a failure.  Runs that were skipped because their pod was removed are not captured.
+func (ext *Checker) collectPodLogs(runErr error) {
+	ext.setPodLogs(nil)
+	if !ext.CollectPodLogs || runErr == ErrPodRemovedExpectedly {
+		return
+	}
+	if runErr == nil {
+		ok, _ := ext.CurrentStatus()
+		if ok {
+			return
+		}
+	}
+
+	podClient := ext.KubeClient.CoreV1().Pods(ext.podNamespace())
+	pod, err := podClient.Get(ext.podName(), metav1.GetOptions{})
+	if err != nil {
+		ext.log("Unable to fetch checker pod", ext.podName(), "to capture its logs:", err)
+		return
+	}
+
+	tailLines := int64(podLogTailLines)
+	limitBytes := int64(podLogLimitBytes)
+	logs := make(map[string]string)
+	for _, c := range append(pod.Spec.InitContainers, pod.Spec.Containers...) {
+		b, err := podClient.GetLogs(pod.Name, &apiv1.PodLogOptions{
+			Container:  c.Name,
+			TailLines:  &tailLines,
+			LimitBytes: &limitBytes,
+		}).Do().Raw()
+		if err != nil {
+			ext.log("Unable to capture logs of container", c.Name, "of checker pod", pod.Name+":", err)
+			continue
+		}
+		logs[c.Name] = string(b)
+	}
+	ext.setPodLogs(logs)
+}
@@ -0,0 +1,82 @@
+package external
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"sync"
+	"time"
+
+	apiv1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// podLogStreamDrain is how long the end of a run waits for the output of the checker pod to finish streaming
+// before the streams are closed
+const podLogStreamDrain = time.Second * 2
+
+// streamPodLogs follows the output of every container of the current checker pod and writes each line to the
+// Kuberhealthy log as it is written, so that new check images can be debugged without fetching their logs.
+// The returned function waits briefly for the output to finish and then stops streaming.
+func (ext *Checker) streamPodLogs() func() {
+	ctx, cancel := context.WithCancel(context.Background())
+	var wg sync.WaitGroup
+
+	pod, err := ext.getPodClient().Get(ext.podName(), metav1.GetOptions{})
+	if err != nil {
+		ext.log("Unable to fetch checker pod", ext.podName(), "to stream its logs:", err)
+		return cancel
+	}
+	for _, c := range append(pod.Spec.InitContainers, pod.Spec.Containers...) {
+		wg.Add(1)
+		go func(container string) {
+			defer wg.Done()
+			ext.streamContainerLogs(ctx, pod.Name, container)
+		}(c.Name)
+	}
+
+	return func() {
+		done := make(chan struct{})
+		go func() {
+			wg.Wait()
+			close(done)
+		}()
+		select {
+		case <-done:
+		case <-time.After(podLogStreamDrain):
+		}
+		cancel()
+	}
+}
+
+// streamContainerLogs follows the output of a container of a checker pod until it exits or the context is
+// canceled
+func (ext *Checker) streamContainerLogs(ctx context.Context, podName string, container string) {
+	stream, err := ext.getPodClient().GetLogs(podName, &apiv1.PodLogOptions{
+		Container: container,
+		Follow:    true,
+	}).Stream()
+	if err != nil {
+		ext.log("Unable to stream logs of container", container, "of checker pod", podName+":", err)
+		return
+	}
+	defer stream.Close()
+
+	// close the stream when streaming is stopped so that reading from it returns
+	go func() {
+		<-ctx.Done()
+		stream.Close()
+	}()
+	ext.logPodOutput(container, stream)
+}
+
+// logPodOutput writes each line of the output of a checker pod container to the Kuberhealthy log, prefixed with
+// the check name and run UUID
+func (ext *Checker) logPodOutput(container string, r io.Reader) {
+	logger := ext.logger().WithField(LogFieldContainer, container)
+	prefix := "[" + ext.CheckName + " " + ext.currentCheckUUID + "] "
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		logger.Infoln(prefix + scanner.Text())
+	}
+}
@@ -0,0 +1,33 @@
+package external
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// TestLogPodOutput validates that each line of checker pod output is logged with the check name and run UUID
+func TestLogPodOutput(t *testing.T) {
+	var buf bytes.Buffer
+	out := log.StandardLogger().Out
+	log.SetOutput(&buf)
+	defer log.SetOutput(out)
+
+	ext := &Checker{CheckName: "my-check", currentCheckUUID: "1234"}
+	ext.logPodOutput("main", strings.NewReader("starting\nall good\n"))
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatal("Expected two log lines but got", lines)
+	}
+	for _, line := range lines {
+		if !strings.Contains(line, "[my-check 1234]") || !strings.Contains(line, "container=main") {
+			t.Fatal("Expected the log line to name the check, run, and container but got", line)
+		}
+	}
+	if !strings.Contains(lines[1], "all good") {
+		t.Fatal("Expected the second line of output but got", lines[1])
+	}
+}
@@ -20,17 +20,71 @@ import (
 
 	apiv1 "k8s.io/api/core/v1"
 	policyv1 "k8s.io/api/policy/v1beta1"
+	rbacv1 "k8s.io/api/rbac/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
 	typedv1 "k8s.io/client-go/kubernetes/typed/core/v1"
 
 	"github.com/Comcast/kuberhealthy/v2/pkg/health"
+	"github.com/Comcast/kuberhealthy/v2/pkg/imagepolicy"
 	"github.com/Comcast/kuberhealthy/v2/pkg/khcheckcrd"
-	"github.com/Comcast/kuberhealthy/v2/pkg/khstatecrd"
+	"github.com/Comcast/kuberhealthy/v2/pkg/khtls"
+	"github.com/Comcast/kuberhealthy/v2/pkg/podsecurity"
+	"github.com/Comcast/kuberhealthy/v2/pkg/podtemplate"
+	"github.com/Comcast/kuberhealthy/v2/pkg/ratelimit"
+	"github.com/Comcast/kuberhealthy/v2/pkg/rolepolicy"
+	"github.com/Comcast/kuberhealthy/v2/pkg/statestore"
+	"github.com/Comcast/kuberhealthy/v2/pkg/tracing"
 )
 
 // KHReportingURL is the environment variable used to tell external checks where to send their status updates
 const KHReportingURL = "KH_REPORTING_URL"
 
+// KuberhealthyURL is the environment variable used by the checkclient package to find the reporting URL.
+// It is set alongside KHReportingURL.
+const KuberhealthyURL = "KUBERHEALTHY_URL"
+
+// KuberhealthyRunID is the environment variable used by the checkclient package to find the run UUID.
+// It is set alongside KHRunUUID.
+const KuberhealthyRunID = "KUBERHEALTHY_RUN_ID"
+
+// KuberhealthyCheckDeadline is the environment variable used to tell external checks the unix time at which
+// Kuberhealthy will stop waiting for them to report in and remove their pod.
+const KuberhealthyCheckDeadline = "KUBERHEALTHY_CHECK_DEADLINE"
+
+// KHGRPCReportingAddress is the environment variable used to tell external checks where the gRPC report
+// service is listening.  It is only set when the gRPC report service is enabled.
+const KHGRPCReportingAddress = "KH_GRPC_REPORTING_ADDRESS"
+
+// KuberhealthyCABundle is the environment variable used to hand checks the PEM encoded CA bundle they can
+// use to verify the Kuberhealthy reporting endpoint when it serves TLS.
+const KuberhealthyCABundle = "KUBERHEALTHY_CA_BUNDLE"
+
+// KuberhealthyProxy and KuberhealthyNoProxy are the environment variables used to tell checks which proxy to
+// report through, or direct to report without one, and which hosts to reach without it.
+const KuberhealthyProxy = "KUBERHEALTHY_PROXY"
+const KuberhealthyNoProxy = "KUBERHEALTHY_NO_PROXY"
+
+// KuberhealthyClientCertFile and KuberhealthyClientKeyFile are the environment variables used to tell checks
+// where the client certificate they should present to Kuberhealthy is mounted.
+const KuberhealthyClientCertFile = "KUBERHEALTHY_CLIENT_CERT_FILE"
+const KuberhealthyClientKeyFile = "KUBERHEALTHY_CLIENT_KEY_FILE"
+
+// clientTLSVolumeName and clientTLSMountPath are used to mount the client certificate secret into checker pods
+const clientTLSVolumeName = "kuberhealthy-client-tls"
+const clientTLSMountPath = "/etc/kuberhealthy/tls"
+
+// KuberhealthyTokenFile is the environment variable used to tell checks where the service account token they
+// should send with their reports is mounted
+const KuberhealthyTokenFile = "KUBERHEALTHY_TOKEN_FILE"
+
+// tokenVolumeName and tokenMountPath are used to mount the projected service account token into checker pods.
+// tokenExpirationSeconds is the shortest lifetime Kubernetes allows, and the kubelet rotates the token before
+// it expires.
+const tokenVolumeName = "kuberhealthy-token"
+const tokenMountPath = "/var/run/secrets/kuberhealthy"
+const tokenExpirationSeconds = 600
+
 // KHRunUUID is the environment variable used to tell external checks their check's UUID so that they
 // can be de-duplicated on the server side.
 const KHRunUUID = "KH_RUN_UUID"
@@ -38,6 +92,10 @@ const KHRunUUID = "KH_RUN_UUID"
 // KH_CHECK_NAME_ANNOTATION_KEY is the annotation which holds the check's name for later validation when the pod calls in
 const KH_CHECK_NAME_ANNOTATION_KEY = "comcast.github.io/check-name"
 
+// KHCheckNamespaceAnnotation is the annotation which holds the check's namespace on checker pods that run in
+// an ephemeral namespace, so that their reports can be attributed to the check
+const KHCheckNamespaceAnnotation = "comcast.github.io/check-namespace"
+
 // KHPodNamespace is the namespace variable used to tell external checks their namespace to perform
 // checks in.
 const KHPodNamespace = "KH_POD_NAMESPACE"
@@ -46,15 +104,32 @@ const KHPodNamespace = "KH_POD_NAMESPACE"
 // are expected to report into.
 const DefaultKuberhealthyReportingURL = "http://kuberhealthy.kuberhealthy.svc.cluster.local/externalCheckStatus"
 
+// the structured log fields that correlate log lines to a check run
+const LogFieldCheck = "check"
+const LogFieldNamespace = "namespace"
+const LogFieldRunID = "run_id"
+const LogFieldPod = "pod"
+const LogFieldContainer = "container"
+
 // kuberhealthyRunIDLabel is the pod label for the kuberhealthy run id value
 const kuberhealthyRunIDLabel = "kuberhealthy-run-id"
 
-// kuberhealthyCheckNameLabel is the label used to flag this pod as being managed by this checker
-const kuberhealthyCheckNameLabel = "kuberhealthy-check-name"
+// KuberhealthyCheckNameLabel is the label used to flag this pod as being managed by this checker
+const KuberhealthyCheckNameLabel = "kuberhealthy-check-name"
 
 // defaultTimeout is the default time a pod is allowed to run when this checker is created
 const defaultTimeout = time.Minute * 15
 
+// defaultPodDeleteGracePeriod is the default time checker pods are given to exit when they are deleted
+const defaultPodDeleteGracePeriod = time.Second
+
+// defaultPodForceDeleteAfter is the default time a checker pod can stay terminating past its grace period
+// before it is force deleted
+const defaultPodForceDeleteAfter = time.Minute
+
+// pollInterval is how long the checker waits between polls of the API while waiting on a checker pod
+var pollInterval = time.Second * 5
+
 // constant for the error when a pod is deleted expectedly during a check run
 var ErrPodRemovedExpectedly = errors.New("pod deleted expectedly")
 
@@ -77,26 +152,97 @@ type Checker struct {
 	Namespace                string
 	RunInterval              time.Duration // how often this check runs a loop
 	RunTimeout               time.Duration // time check must run completely within
-	KubeClient               *kubernetes.Clientset
+	KubeClient               kubernetes.Interface
 	KHCheckClient            *khcheckcrd.KuberhealthyCheckClient
-	KHStateClient            *khstatecrd.KuberhealthyStateClient
-	PodSpec                  apiv1.PodSpec // the current pod spec we are using after enforcement of settings
-	OriginalPodSpec          apiv1.PodSpec // the user-provided spec of the pod
-	RunID                    string        // the uuid of the current run
-	KuberhealthyReportingURL string        // the URL that the check should want to report results back to
+	StateStore               statestore.StateStore // where the state of this check is read and written
+	PodSpec                  apiv1.PodSpec         // the current pod spec we are using after enforcement of settings
+	OriginalPodSpec          apiv1.PodSpec         // the user-provided spec of the pod
+	RunID                    string                // the uuid of the current run
+	KuberhealthyReportingURL string                // the URL that the check should want to report results back to
+	GRPCReportingAddress     string                // the address of the gRPC report service, if enabled
+	ReportingProxy           string                // the proxy checks report through, or direct.  Checks use their proxy environment variables when blank.
+	ReportingNoProxy         string                // comma separated hosts that checks reach without the reporting proxy
 	ExtraAnnotations         map[string]string
 	ExtraLabels              map[string]string
-	currentCheckUUID         string             // the UUID of the current external checker running
-	Debug                    bool               // indicates we should run in debug mode - run once and stop
-	shutdownCTXFunc          context.CancelFunc // used to cancel things in-flight when shutting down gracefully
-	shutdownCTX              context.Context    // a context used for shutting down the check gracefully
-	wg                       sync.WaitGroup     // used to track background workers and processes
-	hostname                 string             // hostname cache
-	checkPodName             string             // the current unique checker pod name
+	DefaultAnnotations       map[string]string               // operator-wide annotations applied to every checker pod before ExtraAnnotations
+	DefaultLabels            map[string]string               // operator-wide labels applied to every checker pod before ExtraLabels
+	DefaultNodeSelector      map[string]string               // the node selector used for checker pods that do not choose their own nodes
+	DefaultTolerations       []apiv1.Toleration              // the tolerations used for checker pods that do not set their own
+	DefaultPriorityClass     string                          // the priority class used for checker pods that do not set their own
+	PodDeleteGracePeriod     time.Duration                   // how long checker pods are given to exit when they are deleted
+	PodForceDeleteAfter      time.Duration                   // how long a checker pod can stay terminating past its grace period before it is force deleted.  Zero disables force deletion.
+	PodRateLimiter           *ratelimit.Limiter              // limits how fast checker pods are created, deleted, and watched.  Nil does not limit them.
+	RecordPodSpecMutations   bool                            // records the changes made to the user-provided pod spec and annotates checker pods with them
+	podSpecMutations         []PodSpecMutation               // the changes made to the user-provided pod spec by the last configureUserPodSpec
+	ServiceAccountRules      []rbacv1.PolicyRule             // rules for a dedicated service account, if the check requested one
+	SecurityPolicy           podsecurity.Policy              // the security settings enforced on the checker pod
+	ImagePolicy              imagepolicy.Policy              // the images checker pods are allowed to run
+	RolePolicy               rolepolicy.Policy               // the rules a check can request for its dedicated service account
+	TLS                      *khtls.Reloader                 // the TLS certificates of the reporting endpoint, if TLS is enabled
+	ClientCertSecret         string                          // the secret holding client certificates to mount into checker pods
+	TokenAudience            string                          // the audience of the service account token mounted into checker pods to authenticate their reports, if enabled
+	OS                       string                          // the operating system of the nodes checker pods are scheduled to, if the check chose one
+	Architectures            []string                        // the CPU architectures of the nodes checker pods are scheduled to in turn, if the check chose any
+	runArchitecture          string                          // the CPU architecture of the nodes the checker pod of the current run is scheduled to
+	architectureRuns         int                             // the number of runs scheduled onto a chosen architecture, which picks the next one
+	Regressions              []khcheckcrd.RegressionRule     // rules that fail runs whose measurements cross a threshold or regress from their baseline
+	DisableSecurityPolicy    bool                            // opts this check out of the security policy
+	ExpandTemplates          bool                            // expands Go templates in the pod spec and teardown hook of every run
+	Secrets                  []khcheckcrd.ResourceRef        // secrets mounted into or injected into the checker pod
+	ConfigMaps               []khcheckcrd.ResourceRef        // config maps mounted into or injected into the checker pod
+	EphemeralNamespace       *khcheckcrd.EphemeralNamespace  // runs each checker pod in its own namespace, if the check asked for one
+	runNamespace             string                          // the ephemeral namespace of the current run, if one was created
+	NamespaceScoped          bool                            // Kuberhealthy only has permissions in the namespaces it watches, so cluster scoped features are unavailable
+	NetworkPolicy            *khcheckcrd.NetworkPolicyConfig // limits the egress traffic of checker pods, if the check asked for it
+	Setup                    *khcheckcrd.Hook                // runs before the containers of every checker pod, if the check asked for it
+	Teardown                 *khcheckcrd.Hook                // runs after the checker pod of every run is done, if the check asked for it
+	PhaseTimeouts            PhaseTimeouts                   // limits how long each phase of a run can take within the run timeout
+	TimingWarnings           []string                        // problems found with the run interval, timeout, and timeout budgets of the check
+	ImageWarnings            []string                        // images that could not be pinned to their digests
+	TeardownTimeout          time.Duration                   // how long the teardown of a run can take
+	teardownPodSpec          *apiv1.PodSpec                  // the spec of the teardown pod of the current run, configured along with the checker pod
+	KuberhealthyNamespace    string                          // the namespace of the Kuberhealthy pods that checker pods report to
+	KuberhealthyOwner        *metav1.OwnerReference          // the Kuberhealthy deployment, which also owns checker pods in its namespace, if it was found
+	CheckUID                 types.UID                       // the UID of the khcheck resource, which owns checker pods in its namespace
+	ReportingPodLabels       map[string]string               // the labels of the Kuberhealthy pods that checker pods report to
+	CollectPodLogs           bool                            // captures the logs of checker pods of failed runs so they can be archived
+	podLogs                  map[string]string               // the logs captured from the checker pod of the last run, by container
+	podLogsMu                sync.RWMutex                    // guards the captured pod logs
+	runSpan                  *tracing.Span                   // the trace span of the current run
+	runSpanMu                sync.RWMutex                    // guards the run span, which is read by the reporting endpoints
+	FailureThreshold         int                             // consecutive failed runs before the check is reported unhealthy
+	Daemon                   bool                            // keeps one long-running checker pod that reports on its own schedule instead of a pod per run
+	MaxOverlappingRuns       int                             // how many earlier runs can still be in progress when a new run starts
+	NewRunChecker            func() *Checker                 // creates the checker of each run when runs can overlap
+	daemonStarted            time.Time                       // when the current daemon pod was started
+	SuccessThreshold         int                             // consecutive successful runs before an unhealthy check is reported healthy
+	SLOTarget                float64                         // the percentage of runs expected to succeed, used to compute error budgets
+	NotificationChannels     []string                        // the webhooks notified when the health of the check changes
+	Severity                 string                          // how important the check is, which weights it when rolling up the overall status
+	ResultTTL                time.Duration                   // how long a result is trusted before the health of the check is shown as unknown.  Zero trusts results until the check is stale.
+	CheckLabels              map[string]string               // the labels of the khcheck resource, which status page views select checks by
+	currentCheckUUID         string                          // the UUID of the current external checker running
+	runDeadline              time.Time                       // the time at which the current run times out
+	Debug                    bool                            // indicates we should run in debug mode, which streams the output of checker pods into the Kuberhealthy log
+	shutdownCTXFunc          context.CancelFunc              // used to cancel things in-flight when shutting down gracefully
+	shutdownCTX              context.Context                 // a context used for shutting down the check gracefully
+	wg                       sync.WaitGroup                  // used to track background workers and processes
+	hostname                 string                          // hostname cache
+	checkPodName             string                          // the current unique checker pod name
+	phase                    string                          // what the current run is doing, such as creating its pod
+	phaseMu                  sync.RWMutex                    // guards the phase, which is read by the debug endpoint
+	checkerPod               *health.PodDetails              // the node, image, and timing of the checker pod of the current run, as last observed
+	checkerPodMu             sync.RWMutex                    // guards the checker pod details
 }
 
+// the phases of a check run, as returned by Phase
+const (
+	PhaseCreating = "creating" // preparing and creating the checker pod
+	PhaseWaiting  = "waiting"  // waiting for the checker pod to start, report in, and exit
+)
+
 // New creates a new external checker
-func New(client *kubernetes.Clientset, checkConfig *khcheckcrd.KuberhealthyCheck, khCheckClient *khcheckcrd.KuberhealthyCheckClient, khStateClient *khstatecrd.KuberhealthyStateClient, reportingURL string) *Checker {
+func New(client kubernetes.Interface, checkConfig *khcheckcrd.KuberhealthyCheck, khCheckClient *khcheckcrd.KuberhealthyCheckClient, stateStore statestore.StateStore, reportingURL string) *Checker {
 	if len(checkConfig.Namespace) == 0 {
 		checkConfig.Namespace = "kuberhealthy"
 	}
@@ -107,10 +253,14 @@ func New(client *kubernetes.Clientset, checkConfig *khcheckcrd.KuberhealthyCheck
 	return &Checker{
 		Namespace:                checkConfig.Namespace,
 		KHCheckClient:            khCheckClient,
-		KHStateClient:            khStateClient,
+		StateStore:               stateStore,
 		CheckName:                checkConfig.Name,
+		CheckUID:                 checkConfig.UID,
 		KuberhealthyReportingURL: reportingURL,
 		RunTimeout:               defaultTimeout,
+		TeardownTimeout:          DefaultTeardownTimeout,
+		PodDeleteGracePeriod:     defaultPodDeleteGracePeriod,
+		PodForceDeleteAfter:      defaultPodForceDeleteAfter,
 		ExtraAnnotations:         make(map[string]string),
 		ExtraLabels:              make(map[string]string),
 		OriginalPodSpec:          checkConfig.Spec.PodSpec,
@@ -151,20 +301,63 @@ func (ext *Checker) CurrentStatus() (bool, []string) {
 	// fetch the state from the resource
 	state, err := ext.getKHState()
 	if err != nil {
-		if strings.Contains(err.Error(), "not found") {
+		if errors.Is(err, statestore.ErrNotFound) {
 			// if the resource is not found, we default to "up" so not to throw alarms before the first run completes
 			return true, []string{}
 		}
 		return false, []string{err.Error()} // any other errors in fetching state will be seen as the check being down
 	}
 
-	ext.log("length of error message slice:", len(state.Spec.Errors), state.Spec.Errors)
-	if len(state.Spec.Errors) > 0 {
+	// the result of the most recent run is used, as the debounced health is managed by Kuberhealthy
+	ext.log("length of error message slice:", len(state.LastRunErrors), state.LastRunErrors)
+	if len(state.LastRunErrors) > 0 {
 		ext.log("reporting check as OK=FALSE due to error messages > 0")
-		return false, state.Spec.Errors
+		return false, state.LastRunErrors
 	}
 	ext.log("reporting OK=TRUE due to error messages NOT > 0")
-	return true, state.Spec.Errors
+	return true, []string{}
+}
+
+// Thresholds returns the number of consecutive failed and successful runs needed to change the
+// reported health of this check
+func (ext *Checker) Thresholds() (int, int) {
+	return ext.FailureThreshold, ext.SuccessThreshold
+}
+
+// AvailabilityTarget returns the percentage of runs of this check that are expected to succeed
+func (ext *Checker) AvailabilityTarget() float64 {
+	return ext.SLOTarget
+}
+
+// RollupSeverity returns the severity of this check, which weights it when rolling up the overall status
+func (ext *Checker) RollupSeverity() string {
+	return ext.Severity
+}
+
+// ResultLifetime returns how long a result of this check is trusted before its health is shown as unknown, or
+// zero if results are trusted until the check is stale
+func (ext *Checker) ResultLifetime() time.Duration {
+	return ext.ResultTTL
+}
+
+// Warnings returns the problems found with the configuration of this check, which do not keep it from running
+func (ext *Checker) Warnings() []string {
+	if len(ext.ImageWarnings) == 0 {
+		return ext.TimingWarnings
+	}
+	warnings := make([]string, 0, len(ext.TimingWarnings)+len(ext.ImageWarnings))
+	warnings = append(warnings, ext.TimingWarnings...)
+	return append(warnings, ext.ImageWarnings...)
+}
+
+// Labels returns the labels of the khcheck resource of this check
+func (ext *Checker) Labels() map[string]string {
+	return ext.CheckLabels
+}
+
+// Channels returns the names of the webhooks notified when the health of this check changes
+func (ext *Checker) Channels() []string {
+	return ext.NotificationChannels
 }
 
 // Name returns the name of this check.  This name is used
@@ -191,20 +384,70 @@ func (ext *Checker) Timeout() time.Duration {
 
 // Run executes the checker.  This is ran on each "tick" of
 // the RunInterval and is executed by the Kuberhealthy checker
-func (ext *Checker) Run(client *kubernetes.Clientset) error {
+func (ext *Checker) Run(client kubernetes.Interface) error {
+	// generate a new UUID for each run
+	return ext.RunWithID(client, uuid.New().String())
+}
+
+// TraceContext returns the trace context of the current run.  The context is blank when no run is in progress.
+func (ext *Checker) TraceContext() tracing.SpanContext {
+	ext.runSpanMu.RLock()
+	defer ext.runSpanMu.RUnlock()
+	return ext.runSpan.SpanContext()
+}
+
+// RunWithID executes the checker using the supplied run UUID.  This is used when the caller needs
+// to know the UUID of the run before it starts, such as when a run is triggered through the API.
+func (ext *Checker) RunWithID(client kubernetes.Interface, runID string) (err error) {
 
 	// store the client in the checker
 	ext.KubeClient = client
 
-	// generate a new UUID for each run
-	err := ext.setNewCheckUUID()
+	// logs are only kept for the run that captured them
+	ext.setPodLogs(nil)
+
+	// trace the whole run.  Each step of the run is traced as a child of this span.
+	ext.runSpanMu.Lock()
+	ext.runSpan = tracing.Start("check run", tracing.SpanContext{})
+	ext.runSpanMu.Unlock()
+	ext.runSpan.SetAttribute("kuberhealthy.check", ext.CheckName)
+	ext.runSpan.SetAttribute("kuberhealthy.namespace", ext.Namespace)
+	ext.runSpan.SetAttribute("kuberhealthy.run_uuid", runID)
+	defer func() {
+		if err != ErrPodRemovedExpectedly {
+			ext.runSpan.SetError(err)
+		}
+		ext.runSpan.Finish()
+	}()
+
+	// daemon checks keep their pod running between runs
+	if ext.Daemon {
+		ext.log("Running daemon check iteration")
+		return ext.runDaemon(runID)
+	}
+
+	// a pod that is still working on a valid run, such as one left behind by a restart of Kuberhealthy, is
+	// adopted rather than replaced.  The adopted run keeps its own run UUID.
+	adopted, err := ext.adoptablePod()
 	if err != nil {
-		return err
+		ext.log("Error looking for a checker pod to adopt:", err)
 	}
+	if adopted != nil {
+		ext.runSpan.SetAttribute("kuberhealthy.run_uuid", adopted.Labels[kuberhealthyRunIDLabel])
+		err = ext.resumeRun(adopted)
+	} else {
+		uuidSpan := tracing.Start("set run uuid", ext.runSpan.SpanContext())
+		err = ext.setCheckUUID(runID)
+		uuidSpan.SetError(err)
+		uuidSpan.Finish()
+		if err != nil {
+			return err
+		}
 
-	// run a check iteration
-	ext.log("Running external check iteration")
-	err = ext.RunOnce()
+		// run a check iteration
+		ext.log("Running external check iteration")
+		err = ext.RunOnce()
+	}
 
 	// if the pod was removed, we skip this run gracefully
 	if err != nil && err.Error() == ErrPodRemovedExpectedly.Error() {
@@ -215,17 +458,62 @@ func (ext *Checker) Run(client *kubernetes.Clientset) error {
 	// if the pod had an error, we set the error
 	if err != nil {
 		ext.log("Error with running external check:", err)
+		ext.finishOverlappingRun()
 		return err
 	}
 
 	return nil
 }
 
+// adoptablePod returns a checker pod of this check that is still working on a valid run.  A pod is valid
+// when it carries the run UUID that is currently allowed to report in, has not exited or been deleted, and
+// was created within the run timeout.  Returns nil when there is no such pod.  Runs in ephemeral namespaces are
+// never adopted because the namespaces of earlier runs are removed when a new run starts.
+func (ext *Checker) adoptablePod() (*apiv1.Pod, error) {
+	if ext.EphemeralNamespace != nil {
+		return nil, nil
+	}
+	state, err := ext.getKHState()
+	if errors.Is(err, statestore.ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(state.CurrentUUID) == 0 {
+		return nil, nil
+	}
+
+	pods, err := ext.getPodClient().List(metav1.ListOptions{
+		LabelSelector: KuberhealthyCheckNameLabel + "=" + ext.CheckName + "," + kuberhealthyRunIDLabel + "=" + state.CurrentUUID + ",!" + HookLabel,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// other pods can copy the labels of the run, so only the checker pod recorded for it is adopted
+	recorded := state.RunPod(state.CurrentUUID)
+	for i := range pods.Items {
+		p := &pods.Items[i]
+		if len(recorded) > 0 && p.Namespace+"/"+p.Name != recorded {
+			continue
+		}
+		if p.DeletionTimestamp != nil || (p.Status.Phase != apiv1.PodPending && p.Status.Phase != apiv1.PodRunning) {
+			continue
+		}
+		if time.Since(p.CreationTimestamp.Time) >= ext.RunTimeout {
+			continue
+		}
+		return p, nil
+	}
+	return nil, nil
+}
+
 // getCheck gets the CRD information for this check from the kubernetes API.
 func (ext *Checker) getCheck() (*khcheckcrd.KuberhealthyCheck, error) {
 
 	// get the item in question and return it along with any errors
-	log.Debugln("Fetching check", ext.CheckName, "in namespace", ext.Namespace)
+	ext.logger().Debugln("Fetching check")
 	checkConfig, err := ext.KHCheckClient.Get(metav1.GetOptions{}, checkCRDResource, ext.Namespace, ext.CheckName)
 	if err != nil {
 		return &khcheckcrd.KuberhealthyCheck{}, err
@@ -237,10 +525,13 @@ func (ext *Checker) getCheck() (*khcheckcrd.KuberhealthyCheck, error) {
 // cleanup cleans up any running checker pods by evicting them
 func (ext *Checker) cleanup() {
 	ext.log("Evicting up any running pods with name", ext.podName())
-	podClient := ext.KubeClient.CoreV1().Pods(ext.Namespace)
+	podClient := ext.KubeClient.CoreV1().Pods(ext.podNamespace())
 
 	// find all pods that are running still so we can evict them (not delete - for records)
-	checkLabelSelector := kuberhealthyCheckNameLabel + " = " + ext.CheckName
+	checkLabelSelector := KuberhealthyCheckNameLabel + " = " + ext.CheckName
+	if ext.MaxOverlappingRuns > 0 {
+		checkLabelSelector += "," + kuberhealthyRunIDLabel + " = " + ext.currentCheckUUID
+	}
 	ext.log("eviction: looking for pods with the label", checkLabelSelector, "and status.phase=Running")
 	podList, err := podClient.List(metav1.ListOptions{
 		FieldSelector: "status.phase=Running",
@@ -277,7 +568,12 @@ func (ext *Checker) evictPod(podName string, podNamespace string) {
 			GracePeriodSeconds: &gracePeriodSeconds,
 		},
 	}
-	err := podClient.Evict(eviction)
+	err := ext.waitForPodRateLimit(context.Background(), "evict")
+	if err != nil {
+		ext.log("error when waiting to cleanup/evict checker pod", podName, "in namespace", podNamespace+":", err)
+		return
+	}
+	err = podClient.Evict(eviction)
 	if err != nil {
 		ext.log("error when trying to cleanup/evict checker pod", podName, "in namespace", podNamespace+":", err)
 	}
@@ -289,54 +585,29 @@ func (ext *Checker) setUUID(uuid string) error {
 	checkState, err := ext.getKHState()
 
 	// if the fetch operation had an error, but it wasn't 'not found', we return here
-	if err != nil && !strings.Contains(err.Error(), "not found") {
+	if err != nil && !errors.Is(err, statestore.ErrNotFound) {
 		return fmt.Errorf("error setting uuid for check %s %w", ext.CheckName, err)
 	}
 
-	// if the check was not found, we create a fresh one and start there
-	if err != nil && strings.Contains(err.Error(), "not found") {
-		ext.log("khstate did not exist, so a default object will be created")
-		details := health.NewCheckDetails()
-		details.Namespace = ext.CheckNamespace()
-		details.AuthoritativePod = ext.hostname
-		details.OK = true
-		details.RunDuration = time.Duration(0).String()
-		newState := khstatecrd.NewKuberhealthyState(ext.CheckName, details)
-		newState.Namespace = ext.Namespace
-		ext.log("Creating khstate", newState.Name, newState.Namespace, "because it did not exist")
-		_, err = ext.KHStateClient.Create(&newState, stateCRDResource, ext.CheckNamespace())
-		if err != nil {
-			ext.log("failed to create a khstate after finding that it did not exist:", err)
-			return err
-		}
+	// only the fields changed from the stored state are written, so the changes of other writers are kept
+	base := checkState.DeepCopy()
 
-		// checkState will be the new check we just created
-		checkState, err = ext.getKHState()
-		if err != nil {
-			ext.log("failed to fetch khstate khstate after creating it because it did not exist:", err)
-			return err
-		}
+	// if the check was not found, we start with a fresh one
+	if err != nil {
+		ext.log("khstate did not exist, so a default object will be created")
+		checkState = health.NewCheckDetails()
+		checkState.Namespace = ext.CheckNamespace()
+		checkState.AuthoritativePod = ext.hostname
+		checkState.OK = true
+		checkState.RunDuration = time.Duration(0).String()
 	}
 
-	// assign the new uuid
-	checkState.Spec.CurrentUUID = uuid
-
-	// update the resource with the new values we want
-	ext.log("Updating khstate", checkState.Name, checkState.Namespace, "to setUUID:", checkState.Spec.CurrentUUID)
-	_, err = ext.KHStateClient.Update(checkState, stateCRDResource, ext.Name(), ext.CheckNamespace())
-
-	// We commonly see a race here with the following type of error:
-	// "Check execution error: Operation cannot be fulfilled on khchecks.comcast.github.io \"pod-restarts\": the object
-	// has been modified; please apply your changes to the latest version and try again"
-	//
-	// If we see this error, we try again.
-	for err != nil && strings.Contains(err.Error(), "the object has been modified") {
-		ext.log("Failed to write new UUID for check because object was modified by another process.  Retrying in 5s")
-		time.Sleep(time.Second * 5)
-		_, err = ext.KHStateClient.Update(checkState, stateCRDResource, ext.Name(), ext.CheckNamespace())
-	}
+	// assign the new uuid.  Earlier runs that can overlap this one stay authorized to report.
+	checkState.StartRun(uuid, ext.MaxOverlappingRuns)
 
-	return err
+	// update the state with the new values we want
+	ext.log("Updating khstate", ext.CheckName, ext.CheckNamespace(), "to setUUID:", checkState.CurrentUUID)
+	return ext.StateStore.Update(ext.CheckName, ext.CheckNamespace(), base, checkState)
 }
 
 // watchForCheckerPodShutdown watches for the pod running checks to be shut down.  This means that either the pod
@@ -348,165 +619,110 @@ func (ext *Checker) watchForCheckerPodShutdown(shutdownEventNotifyC chan struct{
 	ext.wg.Add(1)
 	defer ext.wg.Done()
 
-	// make a channel to abort the waiter with and start it in the background
 	listOptions := metav1.ListOptions{
 		LabelSelector: kuberhealthyRunIDLabel + "=" + ext.currentCheckUUID,
 	}
 
-	// start a new watcher with the api
-	watcher := ext.startPodWatcher(listOptions, ctx)
-
-	// use the watcher to wait for a deleted event
-	sawRemovalChan := make(chan struct{}, 2) // indicates that the watch saw the pod be removed
-	stoppedChan := make(chan struct{}, 2)    // indicates that the watch stopped for some reason and needs restarted
-	go ext.waitForDeletedEvent(watcher.ResultChan(), sawRemovalChan, stoppedChan)
-
-	// whenever this func ends, remember to clean up the watcher if its provisioned
-	defer func() {
-		if watcher != nil {
-			watcher.Stop()
-		}
-	}()
-
-	// wait to see if the removal happens or the abort happens first by listening for events from the waiter
-	for {
-		select {
-		case <-stoppedChan: // the watcher has stopped
-			// re-create a watcher and restart it if it closes for any reason
-			watcher = ext.startPodWatcher(listOptions, ctx)
-			go ext.waitForDeletedEvent(watcher.ResultChan(), sawRemovalChan, stoppedChan) // restart the watch
-		case <-sawRemovalChan: // we saw the watched pod remove
-			ext.log("pod shutdown monitor witnessed the checker pod being removed")
-			shutdownEventNotifyC <- struct{}{}
-			return
-		case <-ctx.Done(): // we saw an abort (cancel) from upstream
-			ext.log("checker pod shutdown monitor saw an abort message. shutting down external check shutdown monitoring")
-			return
+	// watch the pod until it is removed.  The watch survives the API server closing it.
+	ext.log("starting pod shutdown watcher")
+	err := ext.watchPods(ctx, listOptions, func(eventType watch.EventType, p *apiv1.Pod) (bool, error) {
+		if eventType != watch.Deleted {
+			ext.log("checker pod shutdown monitor saw a", string(eventType), "event. the pod is", p.Status.Phase)
+			return false, nil
 		}
+		ext.log("checker pod shutdown monitor saw a deleted event. notifying that pod has shutdown")
+		return true, nil
+	})
+	if err == errPodWatchStopped {
+		ext.log("checker pod shutdown monitor saw an abort message. shutting down external check shutdown monitoring")
+		return
+	}
+	if err != nil {
+		ext.log("checker pod shutdown monitor gave up watching for the checker pod to be removed:", err)
+		return
 	}
-}
-
-// startPodWatcher tries to start a watcher with the specified list options
-func (ext *Checker) startPodWatcher(listOptions metav1.ListOptions, ctx context.Context) watch.Interface {
-
-	var fails int
-	var maxFails = 30
-
-	// create the pod client used with the watcher
-	podClient := ext.KubeClient.CoreV1().Pods(ext.Namespace)
-
-	for {
-		ext.log("creating a pod watcher")
-
-		// if the upstream context expires, give up
-		select {
-		case <-ctx.Done():
-			ext.log("aborting watcher start due to context cancellation")
-			return nil
-		default:
-		}
 
-		// start a new watch request
-		watcher, err := podClient.Watch(listOptions)
+	ext.log("pod shutdown monitor witnessed the checker pod being removed")
+	shutdownEventNotifyC <- struct{}{}
+}
 
-		// if we got our watcher, we stop trying to make one
-		if err == nil {
-			ext.log("created a pod watcher successfully")
-			return watcher
-		}
+// doFinalUpdateCheck is used to do one final update check before we conclude that the pod disappeared expectedly.
+func (ext *Checker) doFinalUpdateCheck(lastReportTime time.Time) (bool, error) {
+	ext.log("witnessed checker pod removal. aborting watch for pod status report. check run skipped gracefully")
+	// sometimes the shutdown event comes in before the status update notify, even if the pod did report in
+	// before the pod shut down.  So, we do one final check here to see if the pod has checked in before
+	// concluding that the pod has been shut down unexpectedly.
 
-		// if we have failed, up our fail count and exit it its been too many
-		fails++
-		if fails > maxFails {
-			ext.log("reached maximum fails for starting a watcher. triggering fatal shutdown.")
-			log.Fatal("Unable to start watch for checker pod shutdown:", err)
-		}
+	// if the pod has not updated, we finally conclude that this pod has gone away unexpectedly
+	return ext.podHasReportedInAfterTime(lastReportTime)
+}
 
-		ext.log("error when watching for checker pod shutdown:", err.Error())
-		time.Sleep(time.Second) // wait between retries to start a watch
-	}
+// newError returns an error from the provided string by pre-pending the pod's name and namespace to it
+func (ext *Checker) newError(s string) error {
+	return errors.New(ext.CheckNamespace() + "/" + ext.Name() + ": " + s)
 }
 
-// waitForDeletedEvent watches a channel of results from a pod watch and notifies the returned channel when a
-// removal is observed.  The supplied abort channel is for shutting down gracefully.
-func (ext *Checker) waitForDeletedEvent(eventsIn <-chan watch.Event, sawRemovalChan chan struct{}, stoppedChan chan struct{}) {
+// timeoutError is returned when a check run does not complete within its timeout
+type timeoutError struct {
+	msg string
+}
 
-	ext.wg.Add(1)
-	defer ext.wg.Done()
+func (e timeoutError) Error() string { return e.msg }
 
-	// restart the watcher repeatedly forever until we are told to shutdown
-	ext.log("starting pod shutdown watcher")
+// Timeout indicates that this error was caused by a timeout
+func (e timeoutError) Timeout() bool { return true }
 
-	// watch events for a removal
-	for e := range eventsIn {
-		ext.log("got a result when watching for pod to remove")
-		switch e.Type {
-		case watch.Modified: // this section is entirely informational
-			ext.log("checker pod shutdown monitor saw a modified event.")
-			p, ok := e.Object.(*apiv1.Pod)
-			if !ok {
-				ext.log("checker pod shutdown monitor saw a modified event and the object was not a pod. skipped.")
-				continue
-			}
-			ext.log("checker pod shutdown monitor saw a modified event. the pod changed to ", p.Status.Phase)
-			return
-		case watch.Deleted:
-			ext.log("checker pod shutdown monitor saw a deleted event. notifying that pod has shutdown")
-			sawRemovalChan <- struct{}{}
-			return
-		case watch.Error:
-			ext.log("khcheck monitor saw an error event")
-			e, ok := e.Object.(*metav1.Status)
-			if ok {
-				ext.log("pod removal monitor had an error when watching for pod changes:", e.Reason)
-			}
-		default:
-			ext.log("pod removal monitor saw an irrelevant event type and ignored it:", e.Type)
-		}
-	}
+// newTimeoutError returns a timeout error from the provided string formatted like newError
+func (ext *Checker) newTimeoutError(s string) error {
+	return timeoutError{msg: ext.newError(s).Error()}
+}
 
-	// if the watch ends for any reason, we notify the listeners that our watch has ended
-	ext.log("pod removal monitor ended unexpectedly")
-	stoppedChan <- struct{}{}
+// IsTimeout returns true if the error was returned because a check run did not complete within its timeout
+func IsTimeout(err error) bool {
+	var t interface{ Timeout() bool }
+	return errors.As(err, &t) && t.Timeout()
+}
 
+// configError is returned when a check can not run because its khcheck is configured wrong, such as a pod spec
+// without containers or a reference to a secret that does not exist
+type configError struct {
+	err error
 }
 
-// doFinalUpdateCheck is used to do one final update check before we conclude that the pod disappeared expectedly.
-func (ext *Checker) doFinalUpdateCheck(lastReportTime time.Time) (bool, error) {
-	ext.log("witnessed checker pod removal. aborting watch for pod status report. check run skipped gracefully")
-	// sometimes the shutdown event comes in before the status update notify, even if the pod did report in
-	// before the pod shut down.  So, we do one final check here to see if the pod has checked in before
-	// concluding that the pod has been shut down unexpectedly.
+func (e configError) Error() string { return e.err.Error() }
 
-	// fetch the lastUpdateTime from the khstate as of right now
-	currentUpdateTime, err := ext.getCheckLastUpdateTime()
-	if err != nil {
-		return false, err
-	}
+func (e configError) Unwrap() error { return e.err }
 
-	// if the pod has not updated, we finally conclude that this pod has gone away unexpectedly
-	ext.log("Last report time was:", lastReportTime, "vs", currentUpdateTime)
-	if !currentUpdateTime.After(lastReportTime) {
-		return false, nil
-	}
+// Config indicates that this error was caused by the configuration of the check
+func (e configError) Config() bool { return true }
 
-	return true, nil
+// NewConfigError marks an error as caused by the configuration of a check
+func NewConfigError(err error) error {
+	return configError{err: err}
 }
 
-// newError returns an error from the provided string by pre-pending the pod's name and namespace to it
-func (ext *Checker) newError(s string) error {
-	return errors.New(ext.CheckNamespace() + "/" + ext.Name() + ": " + s)
+// IsConfigError returns true if the error was returned because the check is configured wrong
+func IsConfigError(err error) bool {
+	var c interface{ Config() bool }
+	return errors.As(err, &c) && c.Config()
 }
 
 // RunOnce runs one check loop.  This creates a checker pod and ensures it starts,
 // then ensures it changes to Running properly
-func (ext *Checker) RunOnce() error {
+func (ext *Checker) RunOnce() (err error) {
 
 	// create a context for this run
 	ext.shutdownCTX, ext.shutdownCTXFunc = context.WithCancel(context.Background())
+	ext.setPhase(PhaseCreating)
+	defer ext.setPhase("")
 
 	// regenerate the checker pod name with a new timestamp
 	ext.regeneratePodName()
+	ext.nextArchitecture()
+	ext.setCheckerPod(nil)
+
+	// calculate when this run times out so the deadline can be handed to the checker pod
+	ext.runDeadline = time.Now().Add(ext.RunTimeout)
 
 	// fetch the currently known lastReportTime for this check.  We will use this to know when the pod has
 	// fully reported back with a status before exiting
@@ -519,14 +735,44 @@ func (ext *Checker) RunOnce() error {
 	ext.log("Validating pod spec of external check")
 	err = ext.validatePodSpec()
 	if err != nil {
-		return err
+		return NewConfigError(err)
 	}
 
 	// condition the spec with the required labels and environment variables
 	ext.log("Configuring spec of external check")
 	err = ext.configureUserPodSpec()
 	if err != nil {
-		return ext.newError("failed to configure pod spec for Kubernetes from user specified pod spec: " + err.Error())
+		return NewConfigError(ext.newError("failed to configure pod spec for Kubernetes from user specified pod spec: " + err.Error()))
+	}
+
+	// ensure referenced secrets and config maps exist before a pod is created that depends on them
+	err = ext.ValidateReferences()
+	if err != nil {
+		return NewConfigError(ext.newError(err.Error()))
+	}
+
+	// create the ephemeral namespace of this run if the check asked for one.  Everything the run creates in
+	// it is removed with the namespace when the run ends.
+	err = ext.createEphemeralNamespace()
+	defer ext.deleteEphemeralNamespace()
+	if err != nil {
+		return ext.newError("failed to create ephemeral namespace for checker pod: " + err.Error())
+	}
+
+	// capture the logs of a failed checker pod before its namespace can be removed
+	defer func() { ext.collectPodLogs(err) }()
+
+	// create the service account, role, and role binding the check requested
+	err = ext.ensureServiceAccount()
+	if err != nil {
+		return ext.newError("failed to create service account for checker pod: " + err.Error())
+	}
+
+	// limit the egress traffic of the checker pod if the check asked for it
+	err = ext.createNetworkPolicy()
+	defer ext.deleteNetworkPolicy()
+	if err != nil {
+		return ext.newError("failed to create network policy for checker pod: " + err.Error())
 	}
 
 	// sanity check our settings
@@ -538,7 +784,7 @@ func (ext *Checker) RunOnce() error {
 
 	// init a timeout for this whole check
 	ext.log("Timeout set to", ext.RunTimeout.String())
-	timeoutChan := time.After(ext.RunTimeout)
+	timeoutChan := time.After(time.Until(ext.runDeadline))
 
 	// waiting for all checker pods are gone...
 	ext.log("Waiting for all existing pods to clean up")
@@ -548,7 +794,12 @@ func (ext *Checker) RunOnce() error {
 		ext.cleanup()
 		errorMessage := "failed to see pod cleanup within timeout"
 		ext.log(errorMessage)
-		return ext.newError(errorMessage)
+		return ext.newTimeoutError(errorMessage)
+	case <-phaseTimer(ext.PhaseTimeouts.Cleanup):
+		ext.cleanup()
+		errorMessage := "failed to see pod cleanup within cleanup budget of " + ext.PhaseTimeouts.Cleanup.String()
+		ext.log(errorMessage)
+		return ext.newTimeoutError(errorMessage)
 	case err = <-ext.waitForAllPodsToClear():
 		if err != nil {
 			errorMessage := "error waiting for pod to clean up: " + err.Error()
@@ -561,6 +812,58 @@ func (ext *Checker) RunOnce() error {
 	}
 	ext.log("No checker pods exist.")
 
+	// tear down once the checker pod is done, even when the run failed or timed out
+	defer func() { err = ext.finishRun(err) }()
+	return ext.runPod(nil, lastReportTime, timeoutChan)
+}
+
+// resumeRun takes over the run of a checker pod that is still working on a valid run, such as a pod left
+// behind when Kuberhealthy restarted in the middle of a run, and waits for it to report in and exit instead
+// of replacing it with a new pod.  The run keeps the run UUID and deadline of the adopted pod.
+func (ext *Checker) resumeRun(pod *apiv1.Pod) (err error) {
+
+	// create a context for this run
+	ext.shutdownCTX, ext.shutdownCTXFunc = context.WithCancel(context.Background())
+
+	ext.currentCheckUUID = pod.Labels[kuberhealthyRunIDLabel]
+	ext.checkPodName = pod.Name
+	ext.runArchitecture = pod.Labels[ArchitectureLabel]
+	ext.setPhase(PhaseWaiting)
+	defer ext.setPhase("")
+	ext.runDeadline = pod.CreationTimestamp.Add(ext.RunTimeout)
+	ext.log("Adopted checker pod", pod.Name, "with run UUID", ext.currentCheckUUID, "and resuming its run")
+	ext.setCheckerPod(nil)
+	ext.observePod(pod)
+
+	// runs started before checker pods were recorded have their pod recorded when it is adopted
+	recordErr := ext.recordRunPod(pod.Namespace, pod.Name)
+	if recordErr != nil {
+		ext.log("Error recording adopted checker pod", pod.Name, "of run", ext.currentCheckUUID+":", recordErr)
+	}
+
+	// the network policy of the adopted pod was created along with it
+	defer ext.deleteNetworkPolicy()
+	defer func() { ext.collectPodLogs(err) }()
+
+	// the teardown pod is configured the same way as the adopted pod was
+	if ext.Teardown != nil {
+		configErr := ext.configureUserPodSpec()
+		if configErr != nil {
+			ext.log("error configuring teardown of adopted run:", configErr)
+		}
+	}
+	defer func() { err = ext.finishRun(err) }()
+
+	// any report sent since the pod was created belongs to the adopted run
+	timeoutChan := time.After(time.Until(ext.runDeadline))
+	return ext.runPod(pod, pod.CreationTimestamp.Time, timeoutChan)
+}
+
+// runPod creates the checker pod of the current run and waits for it to start, report in since the supplied
+// time, and exit.  When an adopted pod is supplied, no pod is created and the run continues with that pod.
+func (ext *Checker) runPod(adopted *apiv1.Pod, lastReportTime time.Time, timeoutChan <-chan time.Time) error {
+	var err error
+
 	// Spawn a waiter to see if the pod is deleted.  If this happens, we consider this check aborted cleanly
 	// and continue on to the next interval.
 	shutdownEventNotifyC := make(chan struct{})
@@ -569,47 +872,98 @@ func (ext *Checker) RunOnce() error {
 	go ext.watchForCheckerPodShutdown(shutdownEventNotifyC, watchForPodShutdownCtx)
 
 	// Spawn kubernetes pod to run our external check
-	ext.log("creating pod for external check:", ext.CheckName)
-	ext.log("checker pod annotations and labels:", ext.ExtraAnnotations, ext.ExtraLabels)
-	createdPod, err := ext.createPod()
-	if err != nil {
-		ext.log("error creating pod")
-		return ext.newError("failed to create pod for checker: " + err.Error())
+	if adopted == nil {
+		ext.log("creating pod for external check:", ext.CheckName)
+		ext.log("checker pod annotations and labels:", ext.ExtraAnnotations, ext.ExtraLabels)
+		createSpan := tracing.Start("create pod", ext.runSpan.SpanContext())
+		createdPod, err := ext.createPod()
+		createSpan.SetError(err)
+		createSpan.Finish()
+		if err != nil {
+			ext.log("error creating pod")
+			return ext.newError("failed to create pod for checker: " + err.Error())
+		}
+		ext.log("Check", ext.Name(), "created pod", createdPod.Name, "in namespace", createdPod.Namespace)
+	}
+	ext.setPhase(PhaseWaiting)
+
+	// watch for pod to start with a timeout (include time for a new node to be created).  The time it
+	// takes the pod to be scheduled and start is traced as its own span.  Adopted pods that are already
+	// running do not need to be waited on.
+	startSpan := tracing.Start("wait for pod start", ext.runSpan.SpanContext())
+	startSpan.SetAttribute("k8s.pod.name", ext.podName())
+	defer startSpan.Finish()
+	var startChan chan error
+	scheduledChan := make(chan struct{})
+	if adopted != nil && adopted.Status.Phase == apiv1.PodRunning {
+		startChan = make(chan error, 1)
+		startChan <- nil
+	} else {
+		startChan = ext.waitForPodStart(scheduledChan)
 	}
-	ext.log("Check", ext.Name(), "created pod", createdPod.Name, "in namespace", createdPod.Namespace)
 
-	// watch for pod to start with a timeout (include time for a new node to be created)
-	select {
-	case <-timeoutChan:
-		ext.log("timed out waiting for pod to startup")
-		ext.cleanup()
-		return ext.newError("failed to see pod running within timeout")
-	case <-shutdownEventNotifyC:
-		ext.log("pod removed expectedly while waiting for pod to start running")
-		return ErrPodRemovedExpectedly
-	case err = <-ext.waitForPodStart():
-		if err != nil {
+	// the image pull budget starts once the pod is scheduled
+	scheduleTimeoutChan := phaseTimer(ext.PhaseTimeouts.Schedule)
+	var imagePullTimeoutChan <-chan time.Time
+	for started := false; !started; {
+		select {
+		case <-timeoutChan:
+			ext.log("timed out waiting for pod to startup")
 			ext.cleanup()
-			errorMessage := "error when waiting for pod to start: " + err.Error()
-			ext.log(errorMessage)
-			return ext.newError(errorMessage)
+			return ext.newTimeoutError("failed to see pod running within timeout")
+		case <-scheduleTimeoutChan:
+			ext.log("timed out waiting for pod to be scheduled")
+			ext.cleanup()
+			return ext.newTimeoutError("failed to see pod scheduled within schedule budget of " + ext.PhaseTimeouts.Schedule.String())
+		case <-scheduledChan:
+			ext.log("External check pod was scheduled:", ext.podName())
+			scheduledChan = nil
+			scheduleTimeoutChan = nil
+			imagePullTimeoutChan = phaseTimer(ext.PhaseTimeouts.ImagePull)
+		case <-imagePullTimeoutChan:
+			ext.log("timed out waiting for pod to pull its images and start")
+			ext.cleanup()
+			return ext.newTimeoutError("failed to see pod running within image pull budget of " + ext.PhaseTimeouts.ImagePull.String())
+		case <-shutdownEventNotifyC:
+			ext.log("pod removed expectedly while waiting for pod to start running")
+			return ErrPodRemovedExpectedly
+		case err = <-startChan:
+			if err != nil {
+				ext.cleanup()
+				errorMessage := "error when waiting for pod to start: " + err.Error()
+				ext.log(errorMessage)
+				return ext.newError(errorMessage)
+			}
+			// flag the pod as running until this run ends
+			ext.log("External check pod is running:", ext.podName())
+			started = true
+			if ext.Debug {
+				stopStreaming := ext.streamPodLogs()
+				defer stopStreaming()
+			}
+		case <-ext.shutdownCTX.Done():
+			ext.log("shutting down check. aborting watch for pod to start")
+			return nil
 		}
-		// flag the pod as running until this run ends
-		ext.log("External check pod is running:", ext.podName())
-	case <-ext.shutdownCTX.Done():
-		ext.log("shutting down check. aborting watch for pod to start")
-		return nil
 	}
+	startSpan.Finish()
 
 	// validate that the pod was able to update its khstate
-	ext.log("Waiting for pod status to be reported from pod", ext.podName(), "in namespace", ext.Namespace)
+	ext.log("Waiting for pod status to be reported from pod", ext.podName(), "in namespace", ext.podNamespace())
+	runningSpan := tracing.Start("wait for report", ext.runSpan.SpanContext())
+	defer runningSpan.Finish()
 	select {
 	case <-timeoutChan:
 		ext.log("timed out waiting for pod status to be reported")
 		ext.cleanup()
 		errorMessage := "timed out waiting for checker pod to report in"
 		ext.log(errorMessage)
-		return ext.newError(errorMessage)
+		return ext.newTimeoutError(errorMessage)
+	case <-phaseTimer(ext.PhaseTimeouts.Run):
+		ext.cleanup()
+		errorMessage := "timed out waiting for checker pod to report in within run budget of " + ext.PhaseTimeouts.Run.String()
+		ext.log(errorMessage)
+		return ext.newTimeoutError(errorMessage)
 	case <-shutdownEventNotifyC:
 		ext.log("got notification that pod has shutdown while waiting for it to report in")
 		hasUpdated, err := ext.doFinalUpdateCheck(lastReportTime)
@@ -632,17 +986,25 @@ func (ext *Checker) RunOnce() error {
 		ext.log("shutting down check. aborting wait for pod status to update")
 		return nil
 	}
+	runningSpan.Finish()
 
 	// after the pod reports in, we no longer want to watch for it to be removed, so we shut that waiter down
 	cancelWatchForPodShutdown()
 
 	// validate that the pod stopped running properly (wait for the pod to exit)
+	exitSpan := tracing.Start("wait for pod exit", ext.runSpan.SpanContext())
+	defer exitSpan.Finish()
 	select {
 	case <-timeoutChan:
 		errorMessage := "timed out waiting for pod to exit"
 		ext.log(errorMessage)
 		ext.cleanup()
-		return ext.newError(errorMessage)
+		return ext.newTimeoutError(errorMessage)
+	case <-phaseTimer(ext.PhaseTimeouts.Report):
+		errorMessage := "timed out waiting for pod to exit within report budget of " + ext.PhaseTimeouts.Report.String()
+		ext.log(errorMessage)
+		ext.cleanup()
+		return ext.newTimeoutError(errorMessage)
 	case err = <-ext.waitForPodExit():
 		ext.log("External check pod is done running:", ext.podName())
 		if err != nil {
@@ -659,19 +1021,62 @@ func (ext *Checker) RunOnce() error {
 	return nil
 }
 
+// Phase returns what the current run of the check is doing, or a blank string between runs
+func (ext *Checker) Phase() string {
+	ext.phaseMu.RLock()
+	defer ext.phaseMu.RUnlock()
+	return ext.phase
+}
+
+// setPhase records what the current run of the check is doing
+func (ext *Checker) setPhase(phase string) {
+	ext.phaseMu.Lock()
+	defer ext.phaseMu.Unlock()
+	ext.phase = phase
+}
+
 // log writes a normal InfoLn message output prefixed with this checker's name on it
 func (ext *Checker) log(s ...interface{}) {
-	log.Infoln(ext.currentCheckUUID+" "+ext.Namespace+"/"+ext.CheckName+":", s)
+	ext.logger().Infoln(s...)
 }
 
-// deletePod deletes the pod with the specified name>  If the pod is 'not found', an
-// error is NOT returned.
-func (ext *Checker) deletePod(podName string) error {
+// logger returns a log entry with fields that correlate log lines to the current check run
+func (ext *Checker) logger() *log.Entry {
+	fields := log.Fields{
+		LogFieldCheck:     ext.CheckName,
+		LogFieldNamespace: ext.Namespace,
+	}
+	if len(ext.currentCheckUUID) > 0 {
+		fields[LogFieldRunID] = ext.currentCheckUUID
+	}
+	if len(ext.checkPodName) > 0 {
+		fields[LogFieldPod] = ext.checkPodName
+	}
+	return log.WithFields(fields)
+}
+
+// deletePod deletes the pod with the specified name and waits up to the timeout for it to be removed.  If
+// the pod is 'not found', an error is NOT returned.
+func (ext *Checker) deletePod(podName string, timeout time.Duration) error {
 	ext.log("Deleting pod with name", podName)
-	podClient := ext.KubeClient.CoreV1().Pods(ext.Namespace)
-	gracePeriodSeconds := int64(1)
+	err := ext.deletePodWithGracePeriod(podName, ext.PodDeleteGracePeriod)
+	if err != nil {
+		return err
+	}
+	return ext.waitForPodDeletion(podName, timeout)
+}
+
+// deletePodWithGracePeriod deletes the pod with the specified name in the foreground without waiting for
+// it to be removed.  A grace period of zero force deletes the pod.  If the pod is 'not found', an error
+// is NOT returned.
+func (ext *Checker) deletePodWithGracePeriod(podName string, gracePeriod time.Duration) error {
+	err := ext.waitForPodRateLimit(context.Background(), "delete")
+	if err != nil {
+		return err
+	}
+	gracePeriodSeconds := int64(gracePeriod.Seconds())
 	deletionPolicy := metav1.DeletePropagationForeground
-	err := podClient.Delete(podName, &metav1.DeleteOptions{
+	err = ext.getPodClient().Delete(podName, &metav1.DeleteOptions{
 		GracePeriodSeconds: &gracePeriodSeconds,
 		PropagationPolicy:  &deletionPolicy,
 	})
@@ -681,12 +1086,54 @@ func (ext *Checker) deletePod(podName string) error {
 	return nil
 }
 
+// waitForPodDeletion waits up to the timeout for the pod with the specified name to be removed.  The pod is
+// force deleted if it stays terminating for too long.
+func (ext *Checker) waitForPodDeletion(podName string, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for {
+		p, err := ext.getPodClient().Get(podName, metav1.GetOptions{})
+		if err != nil {
+			if strings.Contains(err.Error(), "not found") {
+				ext.log("pod", podName, "was removed")
+				return nil
+			}
+			return err
+		}
+		err = ext.forceDeleteStuckPod(p)
+		if err != nil {
+			return err
+		}
+		if time.Now().After(deadline) {
+			return errors.New("pod " + podName + " still exists " + timeout.String() + " after it was deleted")
+		}
+		time.Sleep(pollInterval)
+	}
+}
+
+// forceDeleteStuckPod force deletes the supplied pod if it has been terminating for longer than
+// PodForceDeleteAfter past its grace period.  Pods that are not terminating are left alone.
+func (ext *Checker) forceDeleteStuckPod(p *apiv1.Pod) error {
+	if ext.PodForceDeleteAfter <= 0 || p.DeletionTimestamp == nil {
+		return nil
+	}
+	stuckFor := time.Since(p.DeletionTimestamp.Time)
+	if stuckFor < ext.PodForceDeleteAfter {
+		return nil
+	}
+	ext.log("force deleting pod", p.Name, "which has been terminating for", stuckFor.Round(time.Second), "past its grace period")
+	return ext.deletePodWithGracePeriod(p.Name, 0)
+}
+
 // sanityCheck runs a basic sanity check on the checker before running
 func (ext *Checker) sanityCheck() error {
 	if ext.Namespace == "" {
 		return errors.New("check namespace can not be empty")
 	}
 
+	if ext.CheckName == "" {
+		return errors.New("check name can not be empty")
+	}
+
 	if ext.KubeClient == nil {
 		return errors.New("kubeClient can not be nil")
 	}
@@ -698,10 +1145,9 @@ func (ext *Checker) sanityCheck() error {
 	return nil
 }
 
-// getKHState gets the khstate for this check from the resource in the API server
-func (ext *Checker) getKHState() (*khstatecrd.KuberhealthyState, error) {
-	// fetch the khstate as it exists
-	return ext.KHStateClient.Get(metav1.GetOptions{}, stateCRDResource, ext.CheckName, ext.Namespace)
+// getKHState gets the state of this check from the state store
+func (ext *Checker) getKHState() (health.CheckDetails, error) {
+	return ext.StateStore.Get(ext.CheckName, ext.Namespace)
 }
 
 // getCheckLastUpdateTime fetches the last time the khstate custom resource for this check was updated
@@ -710,11 +1156,11 @@ func (ext *Checker) getCheckLastUpdateTime() (time.Time, error) {
 
 	// fetch the state from the resource
 	state, err := ext.getKHState()
-	if err != nil && strings.Contains(err.Error(), "not found") {
+	if errors.Is(err, statestore.ErrNotFound) {
 		return time.Time{}, nil
 	}
 
-	return state.Spec.LastRun, err
+	return state.LastRun, err
 }
 
 // waitForPodStatusUpdate waits for a pod status to update from the specified time
@@ -733,7 +1179,7 @@ func (ext *Checker) waitForPodStatusUpdate(lastUpdateTime time.Time) chan error
 		for {
 
 			// wait between requests to the api
-			time.Sleep(time.Second * 5)
+			time.Sleep(pollInterval)
 			ext.log("waiting for external checker pod to report in...")
 
 			// if the context is canceled, we stop
@@ -766,45 +1212,54 @@ func (ext *Checker) waitForPodStatusUpdate(lastUpdateTime time.Time) chan error
 	return outChan
 }
 
-// podHasReportedInAfterTime indicates if a pod has reported a state since the supplied timestamp
+// podHasReportedInAfterTime indicates if a pod has reported a state since the supplied timestamp.  When runs
+// overlap, the report must also have come from the pod of this run, which is no longer running once it has.
 func (ext *Checker) podHasReportedInAfterTime(t time.Time) (bool, error) {
-	// fetch the lastUpdateTime from the khstate as of right now
-	currentUpdateTime, err := ext.getCheckLastUpdateTime()
+	// fetch the state from the resource as of right now
+	state, err := ext.getKHState()
+	if errors.Is(err, statestore.ErrNotFound) {
+		return false, nil
+	}
 	if err != nil {
 		return false, err
 	}
 
 	// if the pod has updated, then we return and were done waiting
-	ext.log("Last report time was:", t, "vs", currentUpdateTime)
-	if currentUpdateTime.After(t) {
-		return true, nil
+	ext.log("Last report time was:", t, "vs", state.LastRun)
+	if !state.LastRun.After(t) {
+		return false, nil
+	}
+	if ext.MaxOverlappingRuns > 0 && state.Running(ext.currentCheckUUID) {
+		ext.log("the report since", t, "came from an overlapping run")
+		return false, nil
 	}
 
-	return false, nil
+	return true, nil
 }
 
-// waitForAllPodsToClear waits for all pods to clear up and be gone
+// waitForAllPodsToClear waits for all pods of this check that are still running or terminating to be gone,
+// so that a new run does not start alongside the pods of an earlier one.  Pods that are still running were
+// not adopted, so their runs are stale and they are deleted, unless they belong to overlapping runs that have
+// not reported in yet.  Completed pods are left for the reaper, and pods stuck terminating are force deleted.
 func (ext *Checker) waitForAllPodsToClear() chan error {
 
-	ext.log("waiting for pod to clear")
+	ext.log("waiting for pods to clear")
 
 	// make the output channel we will return and close it whenever we are done
 	outChan := make(chan error, 2)
 
-	// setup a pod watching client for our current KH pod
-	podClient := ext.KubeClient.CoreV1().Pods(ext.Namespace)
+	// setup a pod listing client for the pods of this check
+	podClient := ext.KubeClient.CoreV1().Pods(ext.podNamespace())
+	checkLabelSelector := KuberhealthyCheckNameLabel + "=" + ext.CheckName
 
 	go func() {
 
 		ext.wg.Add(1)
 		defer ext.wg.Done()
 
-		// watch events and return when the pod is in state running
+		// poll the pods of this check until none are left running or terminating
 		for {
-			log.Debugln("Waiting for checker pod", ext.podName(), "to clear...")
-
-			// wait between requests
-			time.Sleep(time.Second * 5)
+			ext.logger().Debugln("Waiting for checker pods to clear...")
 
 			// if the context is canceled, we stop
 			select {
@@ -814,22 +1269,56 @@ func (ext *Checker) waitForAllPodsToClear() chan error {
 			default:
 			}
 
-			// fetch the pod by name
-			p, err := podClient.Get(ext.podName(), metav1.GetOptions{})
-
-			// if we got a "not found" message, then we are done.  This is the happy path.
+			pods, err := podClient.List(metav1.ListOptions{
+				LabelSelector: checkLabelSelector,
+			})
 			if err != nil {
-				if strings.Contains(err.Error(), "not found") {
-					ext.log("all pods cleared")
-					outChan <- nil
+				outChan <- err
+				return
+			}
+
+			// the pods of overlapping runs that have not reported in yet are not stale
+			var running health.CheckDetails
+			if ext.MaxOverlappingRuns > 0 {
+				running, err = ext.getKHState()
+				if err != nil && !errors.Is(err, statestore.ErrNotFound) {
+					outChan <- err
 					return
 				}
+			}
 
-				// if the error was anything else, we return it upstream
-				outChan <- err
+			var remaining []string
+			for i := range pods.Items {
+				p := &pods.Items[i]
+				if p.DeletionTimestamp == nil && (p.Status.Phase == apiv1.PodSucceeded || p.Status.Phase == apiv1.PodFailed) {
+					continue
+				}
+				if p.DeletionTimestamp == nil && running.Running(p.Labels[kuberhealthyRunIDLabel]) {
+					continue
+				}
+				if p.DeletionTimestamp == nil {
+					ext.log("deleting pod", p.Name, "of a stale run")
+					err = ext.deletePodWithGracePeriod(p.Name, ext.PodDeleteGracePeriod)
+				} else {
+					err = ext.forceDeleteStuckPod(p)
+				}
+				if err != nil {
+					outChan <- err
+					return
+				}
+				remaining = append(remaining, p.Name)
+			}
+
+			// if no pods are left, we are done.  This is the happy path.
+			if len(remaining) == 0 {
+				ext.log("all pods cleared")
+				outChan <- nil
 				return
 			}
-			ext.log("pod", ext.podName(), "still exists with status", p.Status.Phase, p.Status.Message, "- waiting for removal...")
+			ext.log("pods", remaining, "still exist - waiting for removal...")
+
+			// wait between requests
+			time.Sleep(pollInterval)
 		}
 	}()
 
@@ -845,7 +1334,7 @@ func (ext *Checker) waitForPodExit() chan error {
 	outChan := make(chan error, 50)
 
 	// setup a pod watching client for our current KH pod
-	podClient := ext.KubeClient.CoreV1().Pods(ext.Namespace)
+	podClient := ext.KubeClient.CoreV1().Pods(ext.podNamespace())
 
 	go func() {
 
@@ -871,7 +1360,8 @@ func (ext *Checker) waitForPodExit() chan error {
 
 			// watch events and return when the pod is in state running
 			var podExists bool
-			for _, p := range pods.Items {
+			for i, p := range pods.Items {
+				ext.observePod(&pods.Items[i])
 
 				// if the pod is running or pending, we consider it to "exist"
 				if p.Status.Phase == apiv1.PodRunning || p.Status.Phase == apiv1.PodPending {
@@ -897,7 +1387,7 @@ func (ext *Checker) waitForPodExit() chan error {
 				// context is not canceled yet, continue
 			}
 
-			time.Sleep(time.Second * 5) // sleep between polls
+			time.Sleep(pollInterval) // sleep between polls
 		}
 
 	}()
@@ -905,81 +1395,76 @@ func (ext *Checker) waitForPodExit() chan error {
 	return outChan
 }
 
-// waitForPodStart returns a channel that notifies when the checker pod has advanced beyond 'Pending'
-func (ext *Checker) waitForPodStart() chan error {
+// waitForPodStart returns a channel that notifies when the checker pod has advanced beyond 'Pending'.  The
+// scheduled channel is closed once the pod is scheduled onto a node, unless it is nil.
+func (ext *Checker) waitForPodStart(scheduled chan struct{}) chan error {
 
 	ext.log("waiting for pod to be running")
 
 	// make the output channel we will return
 	outChan := make(chan error, 50)
 
-	// setup a pod watching client for our current KH pod
-	podClient := ext.KubeClient.CoreV1().Pods(ext.Namespace)
+	listOptions := metav1.ListOptions{
+		LabelSelector: kuberhealthyRunIDLabel + "=" + ext.currentCheckUUID,
+	}
 
 	go func() {
 
 		ext.wg.Add(1)
 		defer ext.wg.Done()
 
-		// watch over and over again until we see our event or run out of time
-		for {
-
-			ext.log("starting pod running watcher")
-
-			// start watching
-			watcher, err := podClient.Watch(metav1.ListOptions{
-				LabelSelector: kuberhealthyRunIDLabel + "=" + ext.currentCheckUUID,
-			})
-			if err != nil {
-				outChan <- err
-				return
+		// watch the pod until it starts running.  The watch survives the API server closing it.
+		err := ext.watchPods(ext.shutdownCTX, listOptions, func(eventType watch.EventType, p *apiv1.Pod) (bool, error) {
+			ext.log("got an event while waiting for pod to start running")
+			if eventType == watch.Deleted {
+				return false, nil
 			}
 
-			// watch events and return when the pod is in state running
-			for e := range watcher.ResultChan() {
+			ext.observePod(p)
 
-				ext.log("got an event while waiting for pod to start running")
+			// signal when the pod is scheduled so that the time it takes to pull images can be measured
+			if scheduled != nil && podScheduled(p) {
+				close(scheduled)
+				scheduled = nil
+			}
 
-				// try to cast the incoming object to a pod and skip the event if we cant
-				p, ok := e.Object.(*apiv1.Pod)
-				if !ok {
-					ext.log("got a watch event for a non-pod object and ignored it")
+			// catch when the pod has an error image pull and return it as an error #201
+			for _, containerStat := range p.Status.ContainerStatuses {
+				if containerStat.State.Waiting == nil {
 					continue
 				}
+				if containerStat.State.Waiting.Reason == "ErrImagePull" {
+					ext.log("pod had an error image pull")
+					return true, errors.New(containerStat.State.Waiting.Reason)
+				}
+			}
 
-				// catch when the pod has an error image pull and return it as an error #201
-				for _, containerStat := range p.Status.ContainerStatuses {
-					if containerStat.State.Waiting == nil {
-						continue
+			// catch when an init container such as the setup hook failed, which keeps the pod from ever running
+			if p.Status.Phase == apiv1.PodFailed {
+				for _, containerStat := range p.Status.InitContainerStatuses {
+					terminated := containerStat.State.Terminated
+					if terminated != nil && terminated.ExitCode != 0 {
+						ext.log("pod had a failed init container")
+						return true, fmt.Errorf("init container %s exited with code %d", containerStat.Name, terminated.ExitCode)
 					}
-					if containerStat.State.Waiting.Reason == "ErrImagePull" {
-						ext.log("pod had an error image pull")
-						outChan <- errors.New(containerStat.State.Waiting.Reason)
-						watcher.Stop()
-						return
-					}
-				}
-				// read the status of this pod (its ours)
-				ext.log("pod state is now:", string(p.Status.Phase))
-				if p.Status.Phase == apiv1.PodRunning || p.Status.Phase == apiv1.PodFailed || p.Status.Phase == apiv1.PodSucceeded {
-					ext.log("pod is now either running, failed, or succeeded")
-					outChan <- nil
-					watcher.Stop()
-					return
 				}
+			}
 
-				// if the context is done, we break the loop and return
-				select {
-				case <-ext.shutdownCTX.Done():
-					ext.log("external checker pod startup watch aborted due to check context being aborted")
-					outChan <- nil
-					watcher.Stop()
-					return
-				default:
-					// context is not canceled yet, continue
-				}
+			// read the status of this pod (its ours)
+			ext.log("pod state is now:", string(p.Status.Phase))
+			if p.Status.Phase == apiv1.PodRunning || p.Status.Phase == apiv1.PodFailed || p.Status.Phase == apiv1.PodSucceeded {
+				ext.log("pod is now either running, failed, or succeeded")
+				return true, nil
 			}
+			return false, nil
+		})
+
+		// if the context is done, we return cleanly
+		if err == errPodWatchStopped {
+			ext.log("external checker pod startup watch aborted due to check context being aborted")
+			err = nil
 		}
+		outChan <- err
 	}()
 
 	return outChan
@@ -1006,23 +1491,122 @@ func (ext *Checker) validatePodSpec() error {
 		}
 	}
 
-	return nil
+	// ephemeral namespaces are cluster scoped and can not be created with namespaced permissions
+	if ext.EphemeralNamespace != nil && ext.NamespaceScoped {
+		return errors.New("ephemeral namespaces are not available when Kuberhealthy runs in namespaced mode")
+	}
+
+	err := ext.validatePlatform()
+	if err != nil {
+		return err
+	}
+
+	err = ext.ValidateImages()
+	if err != nil {
+		return err
+	}
+
+	err = ext.ValidateServiceAccountRules()
+	if err != nil {
+		return err
+	}
+
+	return ext.validateHooks()
 }
 
 // createPod prepares and creates the checker pod using the kubernetes API
 func (ext *Checker) createPod() (*apiv1.Pod, error) {
 	ext.log("Creating external checker pod named", ext.podName())
+	err := ext.waitForPodRateLimit(ext.shutdownCTX, "create")
+	if err != nil {
+		return nil, err
+	}
+
+	// the pod is recorded before it is created so that it is allowed to report as soon as it starts
+	err = ext.recordRunPod(ext.podNamespace(), ext.podName())
+	if err != nil {
+		return nil, fmt.Errorf("error recording checker pod %s of run %s: %w", ext.podName(), ext.currentCheckUUID, err)
+	}
+	pod, err := ext.KubeClient.CoreV1().Pods(ext.podNamespace()).Create(ext.podManifest())
+	if err != nil {
+		// a pod that was not created, such as another pod that already had its name, must not report for the run
+		forgetErr := ext.forgetRunPod()
+		if forgetErr != nil {
+			ext.log("Error removing checker pod", ext.podName(), "that failed to be created from run", ext.currentCheckUUID+":", forgetErr)
+		}
+		return nil, err
+	}
+	return pod, nil
+}
+
+// recordRunPod records the checker pod of the current run on the khstate of the check, which makes it the only
+// pod whose reports are accepted for the run
+func (ext *Checker) recordRunPod(namespace string, name string) error {
+	checkState, err := ext.getKHState()
+	if err != nil {
+		return err
+	}
+	base := checkState.DeepCopy()
+	checkState.RecordRunPod(ext.currentCheckUUID, namespace+"/"+name)
+	return ext.StateStore.Update(ext.CheckName, ext.CheckNamespace(), base, checkState)
+}
+
+// forgetRunPod removes the checker pod recorded for the current run from the khstate of the check
+func (ext *Checker) forgetRunPod() error {
+	checkState, err := ext.getKHState()
+	if err != nil {
+		return err
+	}
+	base := checkState.DeepCopy()
+	checkState.ForgetRunPod(ext.currentCheckUUID)
+	return ext.StateStore.Update(ext.CheckName, ext.CheckNamespace(), base, checkState)
+}
+
+// waitForPodRateLimit waits until the pod rate limiter allows an operation on a checker pod, or until the
+// context is canceled.  Deletions wait without a deadline so that checker pods are still cleaned up while
+// the check shuts down.
+func (ext *Checker) waitForPodRateLimit(ctx context.Context, operation string) error {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	err := ext.PodRateLimiter.Wait(ctx, operation)
+	if err != nil {
+		return fmt.Errorf("error waiting for the pod rate limiter to %s a checker pod: %w", operation, err)
+	}
+	return nil
+}
+
+// podManifest builds the checker pod from the configured pod spec with all enforced labels and annotations
+func (ext *Checker) podManifest() *apiv1.Pod {
 	p := &apiv1.Pod{}
 	p.Annotations = make(map[string]string)
 	p.Labels = make(map[string]string)
-	p.Namespace = ext.Namespace
+	p.Namespace = ext.podNamespace()
 	p.Name = ext.podName()
 	p.Spec = ext.PodSpec
+	p.OwnerReferences = ext.podOwnerReferences()
 
 	// enforce various labels and annotations on all checker pods created
 	ext.addKuberhealthyLabels(p)
 
-	return ext.KubeClient.CoreV1().Pods(ext.Namespace).Create(p)
+	// apply annotations required by the security policy, such as the seccomp profile
+	if !ext.DisableSecurityPolicy && ext.OS != khcheckcrd.OSWindows {
+		for k, v := range ext.SecurityPolicy.Annotations() {
+			p.Annotations[k] = v
+		}
+	}
+
+	// pods in ephemeral namespaces carry the namespace of their check for report validation
+	if ext.podNamespace() != ext.Namespace {
+		p.Annotations[KHCheckNamespaceAnnotation] = ext.Namespace
+	}
+
+	// show the changes made to the user-provided pod spec for debugging
+	if mutations, ok := ext.podSpecMutationsAnnotation(); ok {
+		p.Annotations[PodSpecMutationsAnnotation] = mutations
+	}
+
+	return p
 }
 
 // configureUserPodSpec configures a user-specified pod spec with
@@ -1031,8 +1615,38 @@ func (ext *Checker) createPod() (*apiv1.Pod, error) {
 // overwrite user-specified values.
 func (ext *Checker) configureUserPodSpec() error {
 
-	// start with a fresh spec each time we regenerate the spec
-	ext.PodSpec = ext.OriginalPodSpec
+	// start with a fresh spec each time we regenerate the spec.  The spec is deep copied so that
+	// changes to containers never leak back into the user-provided spec.
+	ext.PodSpec = *ext.OriginalPodSpec.DeepCopy()
+	ext.podSpecMutations = nil
+	ext.teardownPodSpec = nil
+
+	// the setup hook runs as init containers ahead of the pod's own so that it is done before the check starts
+	setup := ext.hookContainers(hookSetup, ext.Setup)
+	ext.PodSpec.InitContainers = append(setup, ext.PodSpec.InitContainers...)
+
+	// expand templates in the user-provided spec and the teardown hook before Kuberhealthy's own settings
+	// are applied.  Templates are only expanded for checks that opt in, so that strings that happen to contain
+	// {{, such as kubectl go-template arguments, are passed through as they are written.
+	teardown := apiv1.PodSpec{Containers: ext.hookContainers(hookTeardown, ext.Teardown)}
+	if ext.ExpandTemplates {
+		values := podtemplate.Values{
+			Namespace:    ext.podNamespace(),
+			CheckName:    ext.CheckName,
+			RunID:        ext.currentCheckUUID,
+			PodName:      ext.podName(),
+			ReportingURL: ext.KuberhealthyReportingURL,
+		}
+		var err error
+		ext.PodSpec, err = podtemplate.Expand(ext.PodSpec, values)
+		if err != nil {
+			return err
+		}
+		teardown, err = podtemplate.Expand(teardown, values)
+		if err != nil {
+			return err
+		}
+	}
 
 	// specify environment variables that need applied.  We apply environment
 	// variables that set the report-in URL of kuberhealthy along with
@@ -1046,6 +1660,14 @@ func (ext *Checker) configureUserPodSpec() error {
 			Name:  KHRunUUID,
 			Value: ext.currentCheckUUID,
 		},
+		{
+			Name:  KuberhealthyURL,
+			Value: ext.KuberhealthyReportingURL,
+		},
+		{
+			Name:  KuberhealthyRunID,
+			Value: ext.currentCheckUUID,
+		},
 		{
 			Name: KHPodNamespace,
 			ValueFrom: &apiv1.EnvVarSource{
@@ -1056,14 +1678,173 @@ func (ext *Checker) configureUserPodSpec() error {
 		},
 	}
 
+	// daemon pods run until they are replaced, so only the pods of regular runs have a deadline
+	if !ext.Daemon {
+		overwriteEnvVars = append(overwriteEnvVars, apiv1.EnvVar{
+			Name:  KuberhealthyCheckDeadline,
+			Value: strconv.FormatInt(ext.runDeadline.Unix(), 10),
+		})
+	}
+
+	// only hand out the gRPC report address when the gRPC report service is enabled
+	if len(ext.GRPCReportingAddress) > 0 {
+		overwriteEnvVars = append(overwriteEnvVars, apiv1.EnvVar{
+			Name:  KHGRPCReportingAddress,
+			Value: ext.GRPCReportingAddress,
+		})
+	}
+
+	// tell checks how to reach the reporting endpoint when the cluster routes outbound requests through a proxy
+	if len(ext.ReportingProxy) > 0 {
+		overwriteEnvVars = append(overwriteEnvVars, apiv1.EnvVar{
+			Name:  KuberhealthyProxy,
+			Value: ext.ReportingProxy,
+		})
+	}
+	if len(ext.ReportingNoProxy) > 0 {
+		overwriteEnvVars = append(overwriteEnvVars, apiv1.EnvVar{
+			Name:  KuberhealthyNoProxy,
+			Value: ext.ReportingNoProxy,
+		})
+	}
+
+	// hand out the CA bundle so checks can verify the reporting endpoint
+	if ext.TLS != nil && len(ext.TLS.CABundle()) > 0 {
+		overwriteEnvVars = append(overwriteEnvVars, apiv1.EnvVar{
+			Name:  KuberhealthyCABundle,
+			Value: string(ext.TLS.CABundle()),
+		})
+	}
+
+	// mount the client certificate secret so checks can present it to the reporting endpoint
+	if len(ext.ClientCertSecret) > 0 {
+		overwriteEnvVars = append(overwriteEnvVars, apiv1.EnvVar{
+			Name:  KuberhealthyClientCertFile,
+			Value: filepath.Join(clientTLSMountPath, apiv1.TLSCertKey),
+		}, apiv1.EnvVar{
+			Name:  KuberhealthyClientKeyFile,
+			Value: filepath.Join(clientTLSMountPath, apiv1.TLSPrivateKeyKey),
+		})
+		ext.PodSpec.Volumes = append(ext.PodSpec.Volumes, apiv1.Volume{
+			Name: clientTLSVolumeName,
+			VolumeSource: apiv1.VolumeSource{
+				Secret: &apiv1.SecretVolumeSource{
+					SecretName: ext.ClientCertSecret,
+				},
+			},
+		})
+		for i := range ext.PodSpec.Containers {
+			ext.PodSpec.Containers[i].VolumeMounts = append(ext.PodSpec.Containers[i].VolumeMounts, apiv1.VolumeMount{
+				Name:      clientTLSVolumeName,
+				MountPath: clientTLSMountPath,
+				ReadOnly:  true,
+			})
+		}
+	}
+
+	// mount a short-lived service account token bound to the pod so checks can prove their identity when they report
+	if len(ext.TokenAudience) > 0 {
+		overwriteEnvVars = append(overwriteEnvVars, apiv1.EnvVar{
+			Name:  KuberhealthyTokenFile,
+			Value: filepath.Join(tokenMountPath, "token"),
+		})
+		expirationSeconds := int64(tokenExpirationSeconds)
+		ext.PodSpec.Volumes = append(ext.PodSpec.Volumes, apiv1.Volume{
+			Name: tokenVolumeName,
+			VolumeSource: apiv1.VolumeSource{
+				Projected: &apiv1.ProjectedVolumeSource{
+					Sources: []apiv1.VolumeProjection{{
+						ServiceAccountToken: &apiv1.ServiceAccountTokenProjection{
+							Audience:          ext.TokenAudience,
+							ExpirationSeconds: &expirationSeconds,
+							Path:              "token",
+						},
+					}},
+				},
+			},
+		})
+		for i := range ext.PodSpec.Containers {
+			ext.PodSpec.Containers[i].VolumeMounts = append(ext.PodSpec.Containers[i].VolumeMounts, apiv1.VolumeMount{
+				Name:      tokenVolumeName,
+				MountPath: tokenMountPath,
+				ReadOnly:  true,
+			})
+		}
+	}
+
+	// hand out the trace context of this run so the check's own spans join the same trace
+	if ext.runSpan.Recording() {
+		overwriteEnvVars = append(overwriteEnvVars, apiv1.EnvVar{
+			Name:  tracing.TraceparentEnv,
+			Value: ext.runSpan.SpanContext().Traceparent(),
+		})
+	}
+
+	// make referenced secrets and config maps available to the checker pod
+	ext.injectReferences()
+
 	// apply overwrite env vars on every container in the pod
+	injectedVarNames := []string{KuberhealthyCheckDeadline, KHGRPCReportingAddress, KuberhealthyProxy, KuberhealthyNoProxy, KuberhealthyCABundle, KuberhealthyClientCertFile, KuberhealthyClientKeyFile, KuberhealthyTokenFile, tracing.TraceparentEnv}
+	for _, e := range overwriteEnvVars {
+		injectedVarNames = append(injectedVarNames, e.Name)
+	}
 	for i := range ext.PodSpec.Containers {
-		ext.PodSpec.Containers[i].Env = resetInjectedContainerEnvVars(ext.PodSpec.Containers[i].Env, []string{KHReportingURL, KHRunUUID, KHPodNamespace})
+		ext.recordEnvMutations(ext.PodSpec.Containers[i], overwriteEnvVars)
+		ext.PodSpec.Containers[i].Env = resetInjectedContainerEnvVars(ext.PodSpec.Containers[i].Env, injectedVarNames)
 		ext.PodSpec.Containers[i].Env = append(ext.PodSpec.Containers[i].Env, overwriteEnvVars...)
 	}
+	ext.configureHookContainers(ext.PodSpec.InitContainers[:len(setup)], overwriteEnvVars, injectedVarNames)
+	ext.configureHookContainers(teardown.Containers, overwriteEnvVars, injectedVarNames)
 
-	// enforce restart policy of never
-	ext.PodSpec.RestartPolicy = apiv1.RestartPolicyNever
+	// enforce restart policy of never.  Daemon pods are restarted by Kubernetes whenever they exit.
+	restartPolicy := apiv1.RestartPolicyNever
+	if ext.Daemon {
+		restartPolicy = apiv1.RestartPolicyAlways
+	}
+	ext.recordMutation("restartPolicy", string(ext.PodSpec.RestartPolicy), string(restartPolicy))
+	ext.PodSpec.RestartPolicy = restartPolicy
+
+	// use the dedicated service account if the check requested one
+	if ext.ServiceAccountRules != nil {
+		if len(ext.PodSpec.ServiceAccountName) > 0 {
+			ext.log("overriding service account", ext.PodSpec.ServiceAccountName, "with the service account requested in the khcheck spec")
+		}
+		ext.recordMutation("serviceAccountName", ext.PodSpec.ServiceAccountName, CheckServiceAccountName(ext.CheckName))
+		ext.PodSpec.ServiceAccountName = CheckServiceAccountName(ext.CheckName)
+	}
+
+	// schedule the pod onto the operator's default node pool unless the check chose its own nodes
+	nodeSelector, tolerations, affinity := formatMutationValue(ext.PodSpec.NodeSelector), formatMutationValue(ext.PodSpec.Tolerations), formatMutationValue(ext.PodSpec.Affinity)
+	ext.applyDefaultScheduling()
+	ext.applyPlatformScheduling()
+	ext.recordMutation("nodeSelector", nodeSelector, formatMutationValue(ext.PodSpec.NodeSelector))
+	ext.recordMutation("tolerations", tolerations, formatMutationValue(ext.PodSpec.Tolerations))
+	ext.recordMutation("affinity", affinity, formatMutationValue(ext.PodSpec.Affinity))
+
+	// give the pod the operator's default priority, such as one that never preempts production workloads
+	if len(ext.DefaultPriorityClass) > 0 && len(ext.PodSpec.PriorityClassName) == 0 && ext.PodSpec.Priority == nil {
+		ext.recordMutation("priorityClassName", ext.PodSpec.PriorityClassName, ext.DefaultPriorityClass)
+		ext.PodSpec.PriorityClassName = ext.DefaultPriorityClass
+	}
+
+	// the teardown pod is run with everything the checker pod was given
+	if len(teardown.Containers) > 0 {
+		ext.teardownPodSpec = newTeardownPodSpec(ext.PodSpec, teardown.Containers)
+	}
+
+	// harden the pods unless the check opted out of the security policy.  The policy is made of Linux
+	// settings that Windows nodes do not support.
+	if ext.DisableSecurityPolicy {
+		ext.log("check opted out of the security policy")
+	} else if ext.OS == khcheckcrd.OSWindows {
+		ext.log("not applying the security policy to a check that runs on Windows nodes")
+	} else if ext.SecurityPolicy.Enabled() {
+		ext.PodSpec = ext.SecurityPolicy.Apply(ext.PodSpec)
+		if ext.teardownPodSpec != nil {
+			hardened := ext.SecurityPolicy.Apply(*ext.teardownPodSpec)
+			ext.teardownPodSpec = &hardened
+		}
+	}
 
 	// enforce namespace as namespace of this checker
 	ext.Namespace = ext.CheckNamespace()
@@ -1071,6 +1852,21 @@ func (ext *Checker) configureUserPodSpec() error {
 	return nil
 }
 
+// applyDefaultScheduling applies the default node selector to pod specs without a node selector or node
+// affinity, and the default tolerations to pod specs without tolerations
+func (ext *Checker) applyDefaultScheduling() {
+	hasNodeAffinity := ext.PodSpec.Affinity != nil && ext.PodSpec.Affinity.NodeAffinity != nil
+	if len(ext.DefaultNodeSelector) > 0 && len(ext.PodSpec.NodeSelector) == 0 && len(ext.PodSpec.NodeName) == 0 && !hasNodeAffinity {
+		ext.PodSpec.NodeSelector = make(map[string]string)
+		for k, v := range ext.DefaultNodeSelector {
+			ext.PodSpec.NodeSelector[k] = v
+		}
+	}
+	if len(ext.DefaultTolerations) > 0 && len(ext.PodSpec.Tolerations) == 0 {
+		ext.PodSpec.Tolerations = append([]apiv1.Toleration{}, ext.DefaultTolerations...)
+	}
+}
+
 // addKuberhealthyLabels adds the appropriate labels to a kuberhealthy
 // external checker pod.
 func (ext *Checker) addKuberhealthyLabels(pod *apiv1.Pod) {
@@ -1082,22 +1878,33 @@ func (ext *Checker) addKuberhealthyLabels(pod *apiv1.Pod) {
 		pod.ObjectMeta.Labels = make(map[string]string)
 	}
 
-	// apply all extra labels to pod as specified by khcheck spec
+	// apply the operator-wide default labels, then all extra labels as specified by khcheck spec so that
+	// checks can override the defaults
+	for k, v := range ext.DefaultLabels {
+		pod.ObjectMeta.Labels[k] = v
+	}
 	for k, v := range ext.ExtraLabels {
 		pod.ObjectMeta.Labels[k] = v
 	}
 
 	// stack the kuberhealthy run id on top of the existing labels
 	pod.ObjectMeta.Labels[kuberhealthyRunIDLabel] = ext.currentCheckUUID
-	pod.ObjectMeta.Labels[kuberhealthyCheckNameLabel] = ext.CheckName
+	pod.ObjectMeta.Labels[KuberhealthyCheckNameLabel] = ext.CheckName
 	pod.ObjectMeta.Labels["app"] = "kuberhealthy-check" // enforce a the label with an app name
+	if len(ext.runArchitecture) > 0 {
+		pod.ObjectMeta.Labels[ArchitectureLabel] = ext.runArchitecture
+	}
 
 	// ensure annotations map isnt nil
 	if pod.ObjectMeta.Annotations == nil {
 		pod.ObjectMeta.Annotations = make(map[string]string)
 	}
 
-	// ensure all extra annotations are applied as specified in the khcheck
+	// ensure the operator-wide default annotations and then all extra annotations are applied as specified
+	// in the khcheck
+	for k, v := range ext.DefaultAnnotations {
+		pod.ObjectMeta.Annotations[k] = v
+	}
 	for k, v := range ext.ExtraAnnotations {
 		pod.ObjectMeta.Annotations[k] = v
 	}
@@ -1107,11 +1914,10 @@ func (ext *Checker) addKuberhealthyLabels(pod *apiv1.Pod) {
 
 }
 
-// createCheckUUID creates a UUID that represents a single run of the external check
-func (ext *Checker) setNewCheckUUID() error {
-	uniqueID := uuid.New()
-	ext.currentCheckUUID = uniqueID.String()
-	log.Debugln("Generated new UUID for external check:", ext.currentCheckUUID)
+// setCheckUUID sets the UUID that represents a single run of the external check
+func (ext *Checker) setCheckUUID(runID string) error {
+	ext.currentCheckUUID = runID
+	ext.logger().Debugln("Using UUID for external check:", ext.currentCheckUUID)
 
 	// set whitelist in check configuration CRD so only this
 	// currently running pod can report-in with a status update
@@ -1123,7 +1929,7 @@ func (ext *Checker) setNewCheckUUID() error {
 func (ext *Checker) podExists() (bool, error) {
 
 	// setup a pod watching client for our current KH pod
-	podClient := ext.KubeClient.CoreV1().Pods(ext.Namespace)
+	podClient := ext.KubeClient.CoreV1().Pods(ext.podNamespace())
 
 	// if the pod is "not found", then it does not exist
 	p, err := podClient.Get(ext.podName(), metav1.GetOptions{})
@@ -1151,7 +1957,7 @@ func (ext *Checker) waitForShutdown(ctx context.Context) error {
 	// repeatedly fetch the pod until its gone or the context
 	// is canceled
 	for {
-		time.Sleep(time.Second * 5)
+		time.Sleep(pollInterval)
 		exists, err := ext.podExists()
 		if err != nil {
 			ext.log("shutdown completed with error: ", err)
@@ -1180,6 +1986,15 @@ func (ext *Checker) Shutdown() error {
 		ext.shutdownCTXFunc()
 	}
 
+	// daemon pods do not exit on their own, so they are removed
+	if ext.Daemon {
+		err := ext.stopDaemon()
+		if err != nil {
+			ext.log("Error removing daemon pod during shutdown:", err)
+			return err
+		}
+	}
+
 	// make a context to track pod removal and cleanup
 	ctx, _ := context.WithTimeout(context.Background(), ext.Timeout())
 
@@ -1200,7 +2015,7 @@ func (ext *Checker) Shutdown() error {
 
 // getPodClient returns a client for Kubernetes pods
 func (ext *Checker) getPodClient() typedv1.PodInterface {
-	return ext.KubeClient.CoreV1().Pods(ext.Namespace)
+	return ext.KubeClient.CoreV1().Pods(ext.podNamespace())
 }
 
 // resetInjectedContainerEnvVars resets injected environment variables
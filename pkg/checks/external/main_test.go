@@ -2,6 +2,8 @@ package external
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
 	"io/ioutil"
 	"os"
 	"testing"
@@ -14,8 +16,10 @@ import (
 
 	log "github.com/sirupsen/logrus"
 
+	"github.com/Comcast/kuberhealthy/v2/pkg/health"
 	"github.com/Comcast/kuberhealthy/v2/pkg/khcheckcrd"
 	"github.com/Comcast/kuberhealthy/v2/pkg/kubeClient"
+	"github.com/Comcast/kuberhealthy/v2/pkg/podsecurity"
 
 	apiv1 "k8s.io/api/core/v1"
 	// metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -79,6 +83,8 @@ func TestLoadPodSpecTestFile(t *testing.T) {
 
 // TestShutdown tests shutting down a check while its running
 func TestShutdown(t *testing.T) {
+	requireCluster(t)
+
 	// create a kubernetes clientset
 	client, err := kubeClient.Create(kubeConfigFile)
 	if err != nil {
@@ -125,3 +131,348 @@ func TestShutdown(t *testing.T) {
 		t.Log("Check shutdown properly and without error")
 	}
 }
+
+// TestLoggerFields validates that log lines carry the check, namespace, run, and pod of the current run
+func TestLoggerFields(t *testing.T) {
+	ext := &Checker{CheckName: "deployment", Namespace: "kuberhealthy"}
+	fields := ext.logger().Data
+	if fields[LogFieldCheck] != "deployment" || fields[LogFieldNamespace] != "kuberhealthy" {
+		t.Fatal("Expected check and namespace log fields but got", fields)
+	}
+	if _, ok := fields[LogFieldRunID]; ok {
+		t.Fatal("Expected no run id log field before a run starts but got", fields)
+	}
+
+	ext.currentCheckUUID = "1234"
+	ext.checkPodName = "deployment-1600000000"
+	fields = ext.logger().Data
+	if fields[LogFieldRunID] != "1234" || fields[LogFieldPod] != "deployment-1600000000" {
+		t.Fatal("Expected run id and pod log fields but got", fields)
+	}
+}
+
+// TestAddKuberhealthyLabels validates that operator-wide labels and annotations are applied to checker pods
+// and can be overridden by the khcheck
+func TestAddKuberhealthyLabels(t *testing.T) {
+	ext := &Checker{
+		CheckName:          "deployment",
+		DefaultLabels:      map[string]string{"cost-center": "platform", "team": "sre"},
+		DefaultAnnotations: map[string]string{"sidecar.istio.io/inject": "false"},
+		ExtraLabels:        map[string]string{"team": "apps", "app": "overridden"},
+		ExtraAnnotations:   map[string]string{"sidecar.istio.io/inject": "true"},
+	}
+	pod := &apiv1.Pod{}
+	ext.addKuberhealthyLabels(pod)
+
+	if pod.Labels["cost-center"] != "platform" || pod.Labels["team"] != "apps" {
+		t.Fatal("Expected default labels overridden by extra labels but got", pod.Labels)
+	}
+	if pod.Labels["app"] != "kuberhealthy-check" || pod.Labels[KuberhealthyCheckNameLabel] != "deployment" {
+		t.Fatal("Expected kuberhealthy labels to take precedence but got", pod.Labels)
+	}
+	if pod.Annotations["sidecar.istio.io/inject"] != "true" || pod.Annotations[KH_CHECK_NAME_ANNOTATION_KEY] != "deployment" {
+		t.Fatal("Expected default annotations overridden by extra annotations but got", pod.Annotations)
+	}
+}
+
+// TestApplyDefaultScheduling validates that checker pods are scheduled onto the default node pool unless
+// they choose their own nodes
+func TestApplyDefaultScheduling(t *testing.T) {
+	ext := &Checker{
+		DefaultNodeSelector: map[string]string{"pool": "ops"},
+		DefaultTolerations:  []apiv1.Toleration{{Key: "dedicated", Operator: apiv1.TolerationOpEqual, Value: "ops", Effect: apiv1.TaintEffectNoSchedule}},
+	}
+	ext.applyDefaultScheduling()
+	if ext.PodSpec.NodeSelector["pool"] != "ops" || len(ext.PodSpec.Tolerations) != 1 {
+		t.Fatal("Expected the default node selector and tolerations but got", ext.PodSpec.NodeSelector, ext.PodSpec.Tolerations)
+	}
+
+	// checks with their own scheduling keep it
+	ext.PodSpec = apiv1.PodSpec{
+		Affinity:    &apiv1.Affinity{NodeAffinity: &apiv1.NodeAffinity{}},
+		Tolerations: []apiv1.Toleration{{Key: "gpu", Operator: apiv1.TolerationOpExists}},
+	}
+	ext.applyDefaultScheduling()
+	if len(ext.PodSpec.NodeSelector) != 0 || len(ext.PodSpec.Tolerations) != 1 || ext.PodSpec.Tolerations[0].Key != "gpu" {
+		t.Fatal("Expected the check's own scheduling to be kept but got", ext.PodSpec.NodeSelector, ext.PodSpec.Tolerations)
+	}
+}
+
+// TestDefaultPriorityClass validates that checker pods get the default priority class unless their pod spec
+// sets its own priority
+func TestDefaultPriorityClass(t *testing.T) {
+	ext := &Checker{
+		CheckName:            "deployment",
+		Namespace:            "kuberhealthy",
+		DefaultPriorityClass: "kuberhealthy-checks",
+		currentCheckUUID:     "1234",
+		OriginalPodSpec: apiv1.PodSpec{
+			Containers: []apiv1.Container{{Name: "checker", Image: "quay.io/comcast/khcheck"}},
+		},
+	}
+	err := ext.configureUserPodSpec()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ext.PodSpec.PriorityClassName != "kuberhealthy-checks" {
+		t.Fatal("Expected the default priority class but got", ext.PodSpec.PriorityClassName)
+	}
+
+	ext.OriginalPodSpec.PriorityClassName = "system-cluster-critical"
+	err = ext.configureUserPodSpec()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ext.PodSpec.PriorityClassName != "system-cluster-critical" {
+		t.Fatal("Expected the check's own priority class to be kept but got", ext.PodSpec.PriorityClassName)
+	}
+}
+
+// TestCheckRegressions validates that runs fail when a measurement crosses a threshold or regresses from a full
+// baseline, and that measurements are added to the baseline
+func TestCheckRegressions(t *testing.T) {
+	max := 500.0
+	ext := &Checker{
+		CheckName: "dns",
+		Regressions: []khcheckcrd.RegressionRule{
+			{Measurement: "latency_ms", Max: &max, MaxIncreasePercent: 50, BaselineRuns: 3},
+		},
+	}
+
+	details := health.NewCheckDetails()
+	details.LastRunOK = true
+	details.Measurements = health.Measurements{"latency_ms": 200}
+	details.Baselines = health.Baselines{"latency_ms": {100, 100}}
+	ext.CheckRegressions(&details)
+	if !details.LastRunOK {
+		t.Fatal("Expected no regression before the baseline is full but got", details.LastRunErrors)
+	}
+	if len(details.Baselines["latency_ms"]) != 3 {
+		t.Fatal("Expected the measurement to be added to its baseline but got", details.Baselines)
+	}
+
+	details.Measurements = health.Measurements{"latency_ms": 600}
+	ext.CheckRegressions(&details)
+	if details.LastRunOK || len(details.LastRunErrors) != 2 {
+		t.Fatal("Expected the run to fail for crossing the maximum and regressing from its baseline but got", details.LastRunErrors)
+	}
+	if details.LastRunErrors[1] != "latency_ms of 600 is 350.0% above its baseline of 133.33" {
+		t.Fatal("Expected the regression from the baseline to be described but got", details.LastRunErrors[1])
+	}
+	if b := details.Baselines["latency_ms"]; len(b) != 3 || b[2] != 600 {
+		t.Fatal("Expected the oldest value to roll out of the baseline but got", b)
+	}
+}
+
+// TestApplyPlatformScheduling validates that checker pods are scheduled onto nodes of the operating system of
+// the check, and that Windows checks tolerate the Windows node taint
+func TestApplyPlatformScheduling(t *testing.T) {
+	ext := &Checker{
+		OS: khcheckcrd.OSWindows,
+		PodSpec: apiv1.PodSpec{
+			NodeSelector: map[string]string{"pool": "ops", apiv1.LabelOSStable: khcheckcrd.OSLinux},
+		},
+	}
+	ext.applyPlatformScheduling()
+	if ext.PodSpec.NodeSelector[apiv1.LabelOSStable] != khcheckcrd.OSWindows || ext.PodSpec.NodeSelector["pool"] != "ops" {
+		t.Fatal("Expected the node selector to select Windows nodes of the pool but got", ext.PodSpec.NodeSelector)
+	}
+	if len(ext.PodSpec.Tolerations) != 1 || ext.PodSpec.Tolerations[0].Key != WindowsTaintKey {
+		t.Fatal("Expected the Windows node taint to be tolerated but got", ext.PodSpec.Tolerations)
+	}
+
+	// the toleration is not added twice when the pod already tolerates every taint
+	ext.PodSpec = apiv1.PodSpec{Tolerations: []apiv1.Toleration{{Operator: apiv1.TolerationOpExists}}}
+	ext.applyPlatformScheduling()
+	if len(ext.PodSpec.Tolerations) != 1 {
+		t.Fatal("Expected the check's own tolerations to be kept but got", ext.PodSpec.Tolerations)
+	}
+
+	// Linux checks only select Linux nodes
+	ext = &Checker{OS: khcheckcrd.OSLinux}
+	ext.applyPlatformScheduling()
+	if ext.PodSpec.NodeSelector[apiv1.LabelOSStable] != khcheckcrd.OSLinux || len(ext.PodSpec.Tolerations) != 0 {
+		t.Fatal("Expected only a Linux node selector but got", ext.PodSpec.NodeSelector, ext.PodSpec.Tolerations)
+	}
+
+	ext.OS = "plan9"
+	if ext.validatePlatform() == nil {
+		t.Fatal("Expected an unsupported operating system to be invalid")
+	}
+}
+
+// TestArchitectureScheduling validates that runs take turns between the architectures of the check and that
+// the architecture of each run is required by node affinity on top of the pod spec's own
+func TestArchitectureScheduling(t *testing.T) {
+	ext := &Checker{Architectures: []string{"amd64", "arm64"}}
+	var runs []string
+	for i := 0; i < 3; i++ {
+		ext.nextArchitecture()
+		runs = append(runs, ext.RunArchitecture())
+	}
+	if runs[0] != "amd64" || runs[1] != "arm64" || runs[2] != "amd64" {
+		t.Fatal("Expected runs to take turns between architectures but got", runs)
+	}
+
+	// every node selector term of the pod spec requires the architecture
+	ext.PodSpec = apiv1.PodSpec{Affinity: &apiv1.Affinity{NodeAffinity: &apiv1.NodeAffinity{
+		RequiredDuringSchedulingIgnoredDuringExecution: &apiv1.NodeSelector{NodeSelectorTerms: []apiv1.NodeSelectorTerm{
+			{MatchExpressions: []apiv1.NodeSelectorRequirement{{Key: "pool", Operator: apiv1.NodeSelectorOpIn, Values: []string{"ops"}}}},
+			{MatchExpressions: []apiv1.NodeSelectorRequirement{{Key: "pool", Operator: apiv1.NodeSelectorOpIn, Values: []string{"edge"}}}},
+		}},
+	}}}
+	ext.applyPlatformScheduling()
+	for _, term := range ext.PodSpec.Affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution.NodeSelectorTerms {
+		last := term.MatchExpressions[len(term.MatchExpressions)-1]
+		if len(term.MatchExpressions) != 2 || last.Key != apiv1.LabelArchStable || last.Values[0] != "amd64" {
+			t.Fatal("Expected every node selector term to require the amd64 architecture but got", term)
+		}
+	}
+
+	// pod specs without node affinity get a term of their own, and the checker pod is labeled with the architecture
+	ext.PodSpec = apiv1.PodSpec{}
+	ext.applyPlatformScheduling()
+	terms := ext.PodSpec.Affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution.NodeSelectorTerms
+	if len(terms) != 1 || len(terms[0].MatchExpressions) != 1 {
+		t.Fatal("Expected one node selector term requiring the architecture but got", terms)
+	}
+	pod := &apiv1.Pod{}
+	ext.addKuberhealthyLabels(pod)
+	if pod.Labels[ArchitectureLabel] != "amd64" {
+		t.Fatal("Expected the checker pod to be labeled with the architecture of the run but got", pod.Labels)
+	}
+}
+
+// TestWindowsSecurityPolicy validates that the Linux security policy is not applied to Windows checker pods
+func TestWindowsSecurityPolicy(t *testing.T) {
+	ext := &Checker{
+		CheckName:        "windows",
+		Namespace:        "kuberhealthy",
+		OS:               khcheckcrd.OSWindows,
+		SecurityPolicy:   podsecurity.Policy{RunAsNonRoot: true, SeccompProfile: podsecurity.DefaultSeccompProfile},
+		currentCheckUUID: "1234",
+		OriginalPodSpec: apiv1.PodSpec{
+			Containers: []apiv1.Container{{Name: "checker", Image: "quay.io/comcast/khcheck:windows"}},
+		},
+	}
+	err := ext.configureUserPodSpec()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ext.PodSpec.SecurityContext != nil || ext.PodSpec.Containers[0].SecurityContext != nil {
+		t.Fatal("Expected no security context on a Windows checker pod but got", ext.PodSpec.SecurityContext, ext.PodSpec.Containers[0].SecurityContext)
+	}
+	if _, ok := ext.podManifest().Annotations[podsecurity.SeccompPodAnnotation]; ok {
+		t.Fatal("Expected no seccomp annotation on a Windows checker pod")
+	}
+}
+
+// TestPodSpecMutations validates that overridden user-specified values are recorded and annotated on the
+// checker pod when pod spec mutations are recorded
+func TestPodSpecMutations(t *testing.T) {
+	ext := &Checker{
+		CheckName:              "deployment",
+		Namespace:              "kuberhealthy",
+		RecordPodSpecMutations: true,
+		currentCheckUUID:       "1234",
+		OriginalPodSpec: apiv1.PodSpec{
+			RestartPolicy: apiv1.RestartPolicyOnFailure,
+			Containers: []apiv1.Container{{
+				Name: "checker",
+				Env: []apiv1.EnvVar{
+					{Name: KHRunUUID, Value: "user-uuid"},
+					{Name: "SOME_ENV_VAR", Value: "12345"},
+				},
+			}},
+		},
+	}
+	err := ext.configureUserPodSpec()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mutations := make(map[string]PodSpecMutation)
+	for _, m := range ext.podSpecMutations {
+		mutations[m.Field] = m
+	}
+	if len(mutations) != 2 {
+		t.Fatal("Expected the restart policy and run UUID mutations but got", ext.podSpecMutations)
+	}
+	if m := mutations["restartPolicy"]; m.Original != "OnFailure" || m.Value != "Never" {
+		t.Fatal("Expected the restart policy mutation to be recorded but got", m)
+	}
+	if m := mutations["containers[checker].env["+KHRunUUID+"]"]; m.Original != "user-uuid" || m.Value != "1234" {
+		t.Fatal("Expected the run UUID mutation to be recorded but got", m)
+	}
+
+	var annotated []PodSpecMutation
+	err = json.Unmarshal([]byte(ext.podManifest().Annotations[PodSpecMutationsAnnotation]), &annotated)
+	if err != nil || len(annotated) != 2 {
+		t.Fatal("Expected the mutations to be annotated on the checker pod but got", annotated, err)
+	}
+
+	// nothing is recorded or annotated unless enabled
+	ext.RecordPodSpecMutations = false
+	err = ext.configureUserPodSpec()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ext.podSpecMutations) != 0 || len(ext.podManifest().Annotations[PodSpecMutationsAnnotation]) != 0 {
+		t.Fatal("Expected no mutations to be recorded but got", ext.podSpecMutations)
+	}
+}
+
+// TestIsConfigError validates that errors marked as configuration errors are recognized after being wrapped and
+// keep their message
+func TestIsConfigError(t *testing.T) {
+	err := NewConfigError(errors.New("no containers found in checks PodSpec"))
+	if !IsConfigError(fmt.Errorf("run failed: %w", err)) {
+		t.Fatal("Expected a wrapped configuration error to be recognized")
+	}
+	if err.Error() != "no containers found in checks PodSpec" {
+		t.Fatal("Expected a configuration error to keep its message but got", err.Error())
+	}
+	if IsConfigError(errors.New("pod could not be scheduled")) {
+		t.Fatal("Expected an infrastructure error to not be a configuration error")
+	}
+}
+
+// TestPodSpecTemplatesOptIn validates that strings containing {{ are passed through unchanged unless the check
+// opts in to pod spec templating
+func TestPodSpecTemplatesOptIn(t *testing.T) {
+	goTemplate := `--output=go-template={{range .items}}{{.metadata.name}}{{"\n"}}{{end}}`
+	ext := &Checker{
+		CheckName:        "kubectl",
+		Namespace:        "kuberhealthy",
+		currentCheckUUID: "1234",
+		OriginalPodSpec: apiv1.PodSpec{
+			Containers: []apiv1.Container{{
+				Name:  "checker",
+				Image: "bitnami/kubectl:1.17",
+				Args:  []string{"get", "pods", goTemplate},
+				Env:   []apiv1.EnvVar{{Name: "TARGET", Value: "svc.{{ .Namespace }}"}},
+			}},
+		},
+	}
+	err := ext.configureUserPodSpec()
+	if err != nil {
+		t.Fatal("Expected a pod spec with {{ to be left alone without templating but got", err)
+	}
+	c := ext.PodSpec.Containers[0]
+	if c.Args[2] != goTemplate {
+		t.Fatal("Expected the go-template argument to pass through unchanged but got", c.Args[2])
+	}
+	if c.Env[0].Value != "svc.{{ .Namespace }}" {
+		t.Fatal("Expected the environment variable to pass through unchanged but got", c.Env[0].Value)
+	}
+
+	ext.ExpandTemplates = true
+	ext.OriginalPodSpec.Containers[0].Args = []string{"get", "pods"}
+	err = ext.configureUserPodSpec()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if value := ext.PodSpec.Containers[0].Env[0].Value; value != "svc.kuberhealthy" {
+		t.Fatal("Expected the template to be expanded when the check opts in but got", value)
+	}
+}
@@ -0,0 +1,109 @@
+package external
+
+import (
+	"encoding/json"
+	"fmt"
+
+	apiv1 "k8s.io/api/core/v1"
+)
+
+// PodSpecMutationsAnnotation is the checker pod annotation that lists the changes Kuberhealthy made to the
+// user-provided pod spec when pod spec mutations are recorded
+const PodSpecMutationsAnnotation = "comcast.github.io/pod-spec-mutations"
+
+// PodSpecMutation is a change Kuberhealthy made to the user-provided pod spec of a check
+type PodSpecMutation struct {
+	Field    string `json:"field"`
+	Original string `json:"original,omitempty"` // blank when the user did not set the field
+	Value    string `json:"value"`
+}
+
+// recordMutation records a change to a field of the pod spec when pod spec mutations are recorded.  A
+// warning is logged when a value the user specified was overridden.
+func (ext *Checker) recordMutation(field string, original string, value string) {
+	if !ext.RecordPodSpecMutations || original == value {
+		return
+	}
+	if len(original) > 0 {
+		ext.logger().Warningln("Overrode user-specified", field, "of", original, "with", value)
+	}
+	ext.podSpecMutations = append(ext.podSpecMutations, PodSpecMutation{
+		Field:    field,
+		Original: original,
+		Value:    value,
+	})
+}
+
+// recordEnvMutations records the environment variables of a container that are about to be overwritten
+// by the supplied Kuberhealthy environment variables.  Variables the user did not set are not recorded.
+func (ext *Checker) recordEnvMutations(container apiv1.Container, overwriteEnvVars []apiv1.EnvVar) {
+	if !ext.RecordPodSpecMutations {
+		return
+	}
+	userVars := make(map[string]string)
+	for _, e := range container.Env {
+		userVars[e.Name] = envVarValue(e)
+	}
+	for _, e := range overwriteEnvVars {
+		original, ok := userVars[e.Name]
+		if !ok {
+			continue
+		}
+		ext.recordMutation("containers["+container.Name+"].env["+e.Name+"]", original, envVarValue(e))
+	}
+}
+
+// envVarValue returns a description of the value of an environment variable for recording mutations
+func envVarValue(e apiv1.EnvVar) string {
+	if e.ValueFrom == nil {
+		return e.Value
+	}
+	if e.ValueFrom.FieldRef != nil {
+		return "fieldRef:" + e.ValueFrom.FieldRef.FieldPath
+	}
+	if e.ValueFrom.SecretKeyRef != nil {
+		return "secretKeyRef:" + e.ValueFrom.SecretKeyRef.Name + "/" + e.ValueFrom.SecretKeyRef.Key
+	}
+	if e.ValueFrom.ConfigMapKeyRef != nil {
+		return "configMapKeyRef:" + e.ValueFrom.ConfigMapKeyRef.Name + "/" + e.ValueFrom.ConfigMapKeyRef.Key
+	}
+	return "valueFrom"
+}
+
+// podSpecMutationsAnnotation returns the recorded mutations encoded for the pod spec mutations annotation.
+// Returns false when there are no mutations to annotate.
+func (ext *Checker) podSpecMutationsAnnotation() (string, bool) {
+	if !ext.RecordPodSpecMutations || len(ext.podSpecMutations) == 0 {
+		return "", false
+	}
+	b, err := json.Marshal(ext.podSpecMutations)
+	if err != nil {
+		ext.log("failed to encode pod spec mutations:", err)
+		return "", false
+	}
+	return string(b), true
+}
+
+// formatMutationValue formats a map or slice field of the pod spec as JSON for recording mutations.  Blank
+// values are formatted as a blank string.
+func formatMutationValue(v interface{}) string {
+	switch t := v.(type) {
+	case map[string]string:
+		if len(t) == 0 {
+			return ""
+		}
+	case []apiv1.Toleration:
+		if len(t) == 0 {
+			return ""
+		}
+	case *apiv1.Affinity:
+		if t == nil {
+			return ""
+		}
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(b)
+}
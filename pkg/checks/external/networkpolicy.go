@@ -0,0 +1,115 @@
+package external
+
+import (
+	apiv1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	k8sErrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+// namespaceNameLabel is the label Kubernetes sets on every namespace to its name
+const namespaceNameLabel = "kubernetes.io/metadata.name"
+
+// DefaultReportingPodLabels are the labels of the Kuberhealthy pods that checker pods report to
+var DefaultReportingPodLabels = map[string]string{"app": "kuberhealthy"}
+
+// networkPolicyManifest builds the network policy that limits the egress traffic of the current checker pod
+// to DNS, the Kuberhealthy pods it reports to, and the destinations declared in the khcheck spec
+func (ext *Checker) networkPolicyManifest() *networkingv1.NetworkPolicy {
+
+	// DNS is needed to resolve the reporting service and any declared destinations by name
+	udp := apiv1.ProtocolUDP
+	tcp := apiv1.ProtocolTCP
+	dnsPort := intstr.FromInt(53)
+	dnsRule := networkingv1.NetworkPolicyEgressRule{
+		Ports: []networkingv1.NetworkPolicyPort{
+			{Protocol: &udp, Port: &dnsPort},
+			{Protocol: &tcp, Port: &dnsPort},
+		},
+	}
+
+	// reports go to the Kuberhealthy pods, which may be in another namespace than the checker pod
+	reportingPodLabels := ext.ReportingPodLabels
+	if len(reportingPodLabels) == 0 {
+		reportingPodLabels = DefaultReportingPodLabels
+	}
+	namespaceSelector := &metav1.LabelSelector{}
+	if len(ext.KuberhealthyNamespace) > 0 {
+		namespaceSelector.MatchLabels = map[string]string{namespaceNameLabel: ext.KuberhealthyNamespace}
+	}
+	reportingRule := networkingv1.NetworkPolicyEgressRule{
+		To: []networkingv1.NetworkPolicyPeer{{
+			PodSelector:       &metav1.LabelSelector{MatchLabels: reportingPodLabels},
+			NamespaceSelector: namespaceSelector,
+		}},
+	}
+
+	return &networkingv1.NetworkPolicy{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      ext.podName(),
+			Namespace: ext.podNamespace(),
+			Labels: map[string]string{
+				KuberhealthyCheckNameLabel: ext.CheckName,
+				kuberhealthyRunIDLabel:     ext.currentCheckUUID,
+			},
+		},
+		Spec: networkingv1.NetworkPolicySpec{
+			PodSelector: metav1.LabelSelector{MatchLabels: map[string]string{kuberhealthyRunIDLabel: ext.currentCheckUUID}},
+			PolicyTypes: []networkingv1.PolicyType{networkingv1.PolicyTypeEgress},
+			Egress:      append([]networkingv1.NetworkPolicyEgressRule{dnsRule, reportingRule}, ext.NetworkPolicy.Egress...),
+		},
+	}
+}
+
+// createNetworkPolicy creates the network policy for the current checker pod.  Network policies left behind
+// by earlier runs of this check are removed first.  Nothing is done if the check did not ask for a network
+// policy.
+func (ext *Checker) createNetworkPolicy() error {
+	if ext.NetworkPolicy == nil {
+		return nil
+	}
+
+	ext.deleteStaleNetworkPolicies()
+
+	policy := ext.networkPolicyManifest()
+	ext.log("Creating network policy", policy.Name, "to limit the egress traffic of the checker pod")
+	_, err := ext.KubeClient.NetworkingV1().NetworkPolicies(policy.Namespace).Create(policy)
+	if k8sErrors.IsAlreadyExists(err) {
+		return nil
+	}
+	return err
+}
+
+// deleteNetworkPolicy deletes the network policy of the current checker pod, if there is one
+func (ext *Checker) deleteNetworkPolicy() {
+	if ext.NetworkPolicy == nil || len(ext.podName()) == 0 {
+		return
+	}
+	ext.log("Deleting network policy", ext.podName())
+	err := ext.KubeClient.NetworkingV1().NetworkPolicies(ext.podNamespace()).Delete(ext.podName(), &metav1.DeleteOptions{})
+	if err != nil && !k8sErrors.IsNotFound(err) {
+		ext.log("error deleting network policy", ext.podName()+":", err)
+	}
+}
+
+// deleteStaleNetworkPolicies deletes the network policies of this check that belong to other runs, such as
+// ones left behind when Kuberhealthy restarted in the middle of a run
+func (ext *Checker) deleteStaleNetworkPolicies() {
+	policyClient := ext.KubeClient.NetworkingV1().NetworkPolicies(ext.podNamespace())
+	policies, err := policyClient.List(metav1.ListOptions{LabelSelector: KuberhealthyCheckNameLabel + "=" + ext.CheckName})
+	if err != nil {
+		ext.log("error listing network policies left behind by earlier runs:", err)
+		return
+	}
+	for _, p := range policies.Items {
+		if p.Name == ext.podName() {
+			continue
+		}
+		ext.log("Deleting network policy", p.Name, "left behind by an earlier run")
+		err = policyClient.Delete(p.Name, &metav1.DeleteOptions{})
+		if err != nil && !k8sErrors.IsNotFound(err) {
+			ext.log("error deleting network policy", p.Name+":", err)
+		}
+	}
+}
@@ -0,0 +1,110 @@
+package external
+
+// OverlappingRuns returns how many earlier runs of this check can still be in progress when a new run starts.
+// Checks run in daemon mode or without a way to create the checker of each run do not overlap their runs.
+func (ext *Checker) OverlappingRuns() int {
+	if ext.Daemon || ext.NewRunChecker == nil {
+		return 0
+	}
+	return ext.MaxOverlappingRuns
+}
+
+// RunChecker returns a checker of its own for the next run of a check whose runs can overlap, so that the pod,
+// run UUID, and deadline of each run are tracked independently.  The runs still take turns between the node
+// architectures the check chose.
+func (ext *Checker) RunChecker() *Checker {
+	r := ext.NewRunChecker()
+	r.architectureRuns = ext.architectureRuns
+	ext.architectureRuns++
+	return r
+}
+
+// Copy returns a checker with the settings of this one, including its pod spec with images already pinned to their
+// digests, but none of the state of its runs.  It is used to create the checker of each overlapping run without
+// reloading the check.  The settings are shared rather than deep copied, since runs only read them.
+func (ext *Checker) Copy() *Checker {
+	return &Checker{
+		CheckName:                ext.CheckName,
+		Namespace:                ext.Namespace,
+		RunInterval:              ext.RunInterval,
+		RunTimeout:               ext.RunTimeout,
+		KubeClient:               ext.KubeClient,
+		KHCheckClient:            ext.KHCheckClient,
+		StateStore:               ext.StateStore,
+		PodSpec:                  *ext.OriginalPodSpec.DeepCopy(),
+		OriginalPodSpec:          *ext.OriginalPodSpec.DeepCopy(),
+		KuberhealthyReportingURL: ext.KuberhealthyReportingURL,
+		GRPCReportingAddress:     ext.GRPCReportingAddress,
+		ReportingProxy:           ext.ReportingProxy,
+		ReportingNoProxy:         ext.ReportingNoProxy,
+		ExtraAnnotations:         ext.ExtraAnnotations,
+		ExtraLabels:              ext.ExtraLabels,
+		DefaultAnnotations:       ext.DefaultAnnotations,
+		DefaultLabels:            ext.DefaultLabels,
+		DefaultNodeSelector:      ext.DefaultNodeSelector,
+		DefaultTolerations:       ext.DefaultTolerations,
+		DefaultPriorityClass:     ext.DefaultPriorityClass,
+		PodDeleteGracePeriod:     ext.PodDeleteGracePeriod,
+		PodForceDeleteAfter:      ext.PodForceDeleteAfter,
+		PodRateLimiter:           ext.PodRateLimiter,
+		RecordPodSpecMutations:   ext.RecordPodSpecMutations,
+		ServiceAccountRules:      ext.ServiceAccountRules,
+		SecurityPolicy:           ext.SecurityPolicy,
+		ImagePolicy:              ext.ImagePolicy,
+		RolePolicy:               ext.RolePolicy,
+		TLS:                      ext.TLS,
+		ClientCertSecret:         ext.ClientCertSecret,
+		TokenAudience:            ext.TokenAudience,
+		OS:                       ext.OS,
+		Architectures:            ext.Architectures,
+		Regressions:              ext.Regressions,
+		DisableSecurityPolicy:    ext.DisableSecurityPolicy,
+		ExpandTemplates:          ext.ExpandTemplates,
+		Secrets:                  ext.Secrets,
+		ConfigMaps:               ext.ConfigMaps,
+		EphemeralNamespace:       ext.EphemeralNamespace,
+		NamespaceScoped:          ext.NamespaceScoped,
+		NetworkPolicy:            ext.NetworkPolicy,
+		Setup:                    ext.Setup,
+		Teardown:                 ext.Teardown,
+		PhaseTimeouts:            ext.PhaseTimeouts,
+		TimingWarnings:           ext.TimingWarnings,
+		ImageWarnings:            ext.ImageWarnings,
+		TeardownTimeout:          ext.TeardownTimeout,
+		KuberhealthyNamespace:    ext.KuberhealthyNamespace,
+		KuberhealthyOwner:        ext.KuberhealthyOwner,
+		CheckUID:                 ext.CheckUID,
+		ReportingPodLabels:       ext.ReportingPodLabels,
+		CollectPodLogs:           ext.CollectPodLogs,
+		FailureThreshold:         ext.FailureThreshold,
+		Daemon:                   ext.Daemon,
+		MaxOverlappingRuns:       ext.MaxOverlappingRuns,
+		NewRunChecker:            ext.NewRunChecker,
+		SuccessThreshold:         ext.SuccessThreshold,
+		SLOTarget:                ext.SLOTarget,
+		NotificationChannels:     ext.NotificationChannels,
+		Severity:                 ext.Severity,
+		ResultTTL:                ext.ResultTTL,
+		CheckLabels:              ext.CheckLabels,
+		Debug:                    ext.Debug,
+		hostname:                 ext.hostname,
+	}
+}
+
+// finishOverlappingRun stops authorizing a run that failed without reporting in to report, so that later runs
+// treat its pod as stale.  Runs that can not overlap are left alone, since only the current run can report.
+func (ext *Checker) finishOverlappingRun() {
+	if ext.MaxOverlappingRuns <= 0 {
+		return
+	}
+	state, err := ext.getKHState()
+	if err != nil || !state.Running(ext.currentCheckUUID) {
+		return
+	}
+	base := state.DeepCopy()
+	state.FinishRun(ext.currentCheckUUID)
+	err = ext.StateStore.Update(ext.CheckName, ext.CheckNamespace(), base, state)
+	if err != nil {
+		ext.log("Error removing failed run", ext.currentCheckUUID, "from the running runs of the check:", err)
+	}
+}
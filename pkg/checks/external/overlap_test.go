@@ -0,0 +1,120 @@
+package external
+
+import (
+	"testing"
+	"time"
+
+	apiv1 "k8s.io/api/core/v1"
+
+	"github.com/Comcast/kuberhealthy/v2/pkg/khcheckcrd"
+	"github.com/Comcast/kuberhealthy/v2/pkg/statestore"
+)
+
+// TestRunChecker validates that overlapping runs get checkers of their own that keep taking turns between
+// architectures, and that checks without a way to create them do not overlap
+func TestRunChecker(t *testing.T) {
+	ext := &Checker{CheckName: "slow", Architectures: []string{"amd64", "arm64"}, MaxOverlappingRuns: 1}
+	if ext.OverlappingRuns() != 0 {
+		t.Fatal("Expected a check without a run checker to not overlap its runs")
+	}
+	ext.NewRunChecker = func() *Checker {
+		return &Checker{CheckName: ext.CheckName, Architectures: ext.Architectures, MaxOverlappingRuns: ext.MaxOverlappingRuns}
+	}
+	if ext.OverlappingRuns() != 1 {
+		t.Fatal("Expected the check to overlap one run but got", ext.OverlappingRuns())
+	}
+
+	var runs []string
+	for i := 0; i < 3; i++ {
+		r := ext.RunChecker()
+		if r == ext {
+			t.Fatal("Expected each run to get a checker of its own")
+		}
+		r.nextArchitecture()
+		runs = append(runs, r.RunArchitecture())
+	}
+	if runs[0] != "amd64" || runs[1] != "arm64" || runs[2] != "amd64" {
+		t.Fatal("Expected overlapping runs to take turns between architectures but got", runs)
+	}
+
+	ext.Daemon = true
+	if ext.OverlappingRuns() != 0 {
+		t.Fatal("Expected a daemon check to not overlap its runs")
+	}
+}
+
+// TestOverlappingReport validates that an overlapping run only sees a report as its own once its run UUID has
+// reported in
+func TestOverlappingReport(t *testing.T) {
+	store := statestore.NewMemoryStore()
+	first := &Checker{CheckName: "slow", Namespace: defaultNamespace, StateStore: store, MaxOverlappingRuns: 1}
+	second := &Checker{CheckName: "slow", Namespace: defaultNamespace, StateStore: store, MaxOverlappingRuns: 1}
+	started := time.Now()
+
+	first.currentCheckUUID = "1"
+	if err := first.setUUID("1"); err != nil {
+		t.Fatal(err)
+	}
+	second.currentCheckUUID = "2"
+	if err := second.setUUID("2"); err != nil {
+		t.Fatal(err)
+	}
+
+	// the second run reports in first
+	state, err := store.Get("slow", defaultNamespace)
+	if err != nil {
+		t.Fatal(err)
+	}
+	state.FinishRun("2")
+	state.LastRun = started.Add(time.Second)
+	if err := store.Set("slow", defaultNamespace, state); err != nil {
+		t.Fatal(err)
+	}
+
+	reported, err := first.podHasReportedInAfterTime(started)
+	if err != nil || reported {
+		t.Fatal("Expected the report of the second run to not complete the first run but got", reported, err)
+	}
+	reported, err = second.podHasReportedInAfterTime(started)
+	if err != nil || !reported {
+		t.Fatal("Expected the second run to see its report but got", reported, err)
+	}
+}
+
+// TestCopy validates that the checker of an overlapping run keeps the images the loaded check pinned and its
+// settings, but none of the state of the loaded check's runs
+func TestCopy(t *testing.T) {
+	check := &khcheckcrd.KuberhealthyCheck{}
+	check.Name = "slow"
+	check.Namespace = defaultNamespace
+	check.Spec.PodSpec = apiv1.PodSpec{Containers: []apiv1.Container{{Name: "main", Image: "check:latest"}}}
+	ext := New(nil, check, nil, statestore.NewMemoryStore(), "http://kuberhealthy/externalCheckStatus")
+	ext.MaxOverlappingRuns = 1
+	ext.RunTimeout = time.Minute
+	ext.NewRunChecker = ext.Copy
+
+	// pinning images changes the pod spec of the loaded check in place
+	ext.PodSpec.Containers[0].Image = "check@sha256:abc"
+	ext.currentCheckUUID = "1"
+	ext.RunID = "1"
+
+	r := ext.RunChecker()
+	if r == ext {
+		t.Fatal("Expected the run to get a checker of its own")
+	}
+	if r.OriginalPodSpec.Containers[0].Image != "check@sha256:abc" {
+		t.Fatal("Expected the run to keep the pinned image but got", r.OriginalPodSpec.Containers[0].Image)
+	}
+	if r.RunTimeout != time.Minute || r.MaxOverlappingRuns != 1 || r.StateStore != ext.StateStore || r.CheckName != "slow" {
+		t.Fatal("Expected the run to keep the settings of the check")
+	}
+	if len(r.currentCheckUUID) > 0 || len(r.RunID) > 0 {
+		t.Fatal("Expected the run to start without the run state of the check but got", r.currentCheckUUID, r.RunID)
+	}
+
+	// runs must not change the pod spec of the loaded check
+	r.OriginalPodSpec.Containers[0].Image = "other"
+	if ext.OriginalPodSpec.Containers[0].Image != "check@sha256:abc" {
+		t.Fatal("Expected the run to not change the pod spec of the check but got", ext.OriginalPodSpec.Containers[0].Image)
+	}
+}
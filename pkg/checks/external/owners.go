@@ -0,0 +1,65 @@
+package external
+
+import (
+	"fmt"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// the api version and kind of khcheck resources, which own their checker pods
+const khCheckAPIVersion = "comcast.github.io/v1"
+const khCheckKind = "KuberhealthyCheck"
+
+// podOwnerReferences returns the owner references of checker pods, so that the garbage collector deletes checker
+// pods left behind when their khcheck, or Kuberhealthy itself, is deleted while no Kuberhealthy pod is running to
+// clean them up by their labels.  Owners must be in the namespace of the pods they own, because the garbage
+// collector deletes pods whose owners it can not find there, so pods in ephemeral namespaces have no owners and
+// the Kuberhealthy deployment only owns pods in its own namespace.  A pod with both owners is deleted once both
+// are gone.
+func (ext *Checker) podOwnerReferences() []metav1.OwnerReference {
+	if ext.podNamespace() != ext.Namespace {
+		return nil
+	}
+	var owners []metav1.OwnerReference
+	if len(ext.CheckUID) > 0 {
+		owners = append(owners, metav1.OwnerReference{
+			APIVersion: khCheckAPIVersion,
+			Kind:       khCheckKind,
+			Name:       ext.CheckName,
+			UID:        ext.CheckUID,
+		})
+	}
+	if ext.KuberhealthyOwner != nil && ext.KuberhealthyNamespace == ext.Namespace {
+		owners = append(owners, *ext.KuberhealthyOwner)
+	}
+	return owners
+}
+
+// KuberhealthyOwnerReference finds the deployment that runs the supplied Kuberhealthy pod by following the owner
+// references of the pod to its replica set and of the replica set to its deployment.  Nil is returned when the pod
+// is not run by a deployment.
+func KuberhealthyOwnerReference(client kubernetes.Interface, namespace string, podName string) (*metav1.OwnerReference, error) {
+	pod, err := client.CoreV1().Pods(namespace).Get(podName, metav1.GetOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("error fetching kuberhealthy pod %s: %w", podName, err)
+	}
+	replicaSet := metav1.GetControllerOf(pod)
+	if replicaSet == nil || replicaSet.Kind != "ReplicaSet" {
+		return nil, nil
+	}
+	rs, err := client.AppsV1().ReplicaSets(namespace).Get(replicaSet.Name, metav1.GetOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("error fetching replica set %s of kuberhealthy pod %s: %w", replicaSet.Name, podName, err)
+	}
+	deployment := metav1.GetControllerOf(rs)
+	if deployment == nil || deployment.Kind != "Deployment" {
+		return nil, nil
+	}
+	return &metav1.OwnerReference{
+		APIVersion: deployment.APIVersion,
+		Kind:       deployment.Kind,
+		Name:       deployment.Name,
+		UID:        deployment.UID,
+	}, nil
+}
@@ -0,0 +1,75 @@
+package external
+
+import (
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	apiv1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+// TestPodOwnerReferences validates that checker pods are owned by their khcheck and the kuberhealthy deployment
+// only when the owners are in the namespace of the pod
+func TestPodOwnerReferences(t *testing.T) {
+	deployment := &metav1.OwnerReference{APIVersion: "apps/v1", Kind: "Deployment", Name: "kuberhealthy", UID: "2"}
+	ext := &Checker{
+		CheckName:             "http",
+		Namespace:             defaultNamespace,
+		CheckUID:              "1",
+		KuberhealthyNamespace: defaultNamespace,
+		KuberhealthyOwner:     deployment,
+	}
+	owners := ext.podManifest().OwnerReferences
+	if len(owners) != 2 || owners[0].Kind != khCheckKind || owners[0].Name != "http" || owners[0].UID != "1" || owners[1] != *deployment {
+		t.Fatal("Expected the checker pod to be owned by its khcheck and the kuberhealthy deployment but got", owners)
+	}
+
+	ext.KuberhealthyNamespace = "kuberhealthy-system"
+	owners = ext.podOwnerReferences()
+	if len(owners) != 1 || owners[0].Kind != khCheckKind {
+		t.Fatal("Expected a kuberhealthy deployment in another namespace to not own the checker pod but got", owners)
+	}
+
+	ext.runNamespace = "http-run"
+	owners = ext.podOwnerReferences()
+	if len(owners) != 0 {
+		t.Fatal("Expected a checker pod in an ephemeral namespace to have no owners but got", owners)
+	}
+}
+
+// TestKuberhealthyOwnerReference validates that the deployment of a kuberhealthy pod is found through its
+// replica set, and that pods not run by a deployment have no owner
+func TestKuberhealthyOwnerReference(t *testing.T) {
+	controller := true
+	rs := &appsv1.ReplicaSet{ObjectMeta: metav1.ObjectMeta{
+		Name:            "kuberhealthy-abc",
+		Namespace:       defaultNamespace,
+		OwnerReferences: []metav1.OwnerReference{{APIVersion: "apps/v1", Kind: "Deployment", Name: "kuberhealthy", UID: "2", Controller: &controller}},
+	}}
+	pod := &apiv1.Pod{ObjectMeta: metav1.ObjectMeta{
+		Name:            "kuberhealthy-abc-123",
+		Namespace:       defaultNamespace,
+		OwnerReferences: []metav1.OwnerReference{{APIVersion: "apps/v1", Kind: "ReplicaSet", Name: "kuberhealthy-abc", UID: "3", Controller: &controller}},
+	}}
+	bare := &apiv1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "kuberhealthy-dev", Namespace: defaultNamespace}}
+	client := fake.NewSimpleClientset(rs, pod, bare)
+
+	owner, err := KuberhealthyOwnerReference(client, defaultNamespace, pod.Name)
+	if err != nil {
+		t.Fatal("Expected the deployment to be found but got", err)
+	}
+	if owner == nil || owner.Kind != "Deployment" || owner.Name != "kuberhealthy" || owner.UID != "2" || owner.Controller != nil {
+		t.Fatal("Expected a plain owner reference to the kuberhealthy deployment but got", owner)
+	}
+
+	owner, err = KuberhealthyOwnerReference(client, defaultNamespace, bare.Name)
+	if err != nil || owner != nil {
+		t.Fatal("Expected a pod without a replica set to have no owner but got", owner, err)
+	}
+
+	_, err = KuberhealthyOwnerReference(client, defaultNamespace, "missing")
+	if err == nil {
+		t.Fatal("Expected a missing pod to fail")
+	}
+}
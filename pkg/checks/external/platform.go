@@ -0,0 +1,111 @@
+package external
+
+import (
+	"errors"
+
+	apiv1 "k8s.io/api/core/v1"
+
+	"github.com/Comcast/kuberhealthy/v2/pkg/khcheckcrd"
+)
+
+// WindowsTaintKey is the key of the taint that mixed clusters commonly set on Windows nodes to keep Linux
+// pods off of them.  Checker pods of Windows checks tolerate it.
+const WindowsTaintKey = "os"
+
+// validatePlatform ensures that the check asked for an operating system that checker pods can run on
+func (ext *Checker) validatePlatform() error {
+	switch ext.OS {
+	case "", khcheckcrd.OSLinux, khcheckcrd.OSWindows:
+		return nil
+	}
+	return errors.New("unsupported operating system " + ext.OS + ".  Must be " + khcheckcrd.OSLinux + " or " + khcheckcrd.OSWindows + ".")
+}
+
+// ArchitectureLabel is set on checker pods of checks that run on nodes of chosen CPU architectures and holds
+// the architecture of the run
+const ArchitectureLabel = "kuberhealthy-architecture"
+
+// nextArchitecture picks the CPU architecture of the nodes the next checker pod runs on.  Checks that run on
+// several architectures take turns between them so that every architecture is verified.
+func (ext *Checker) nextArchitecture() {
+	ext.runArchitecture = ""
+	if len(ext.Architectures) == 0 {
+		return
+	}
+	ext.runArchitecture = ext.Architectures[ext.architectureRuns%len(ext.Architectures)]
+	ext.architectureRuns++
+}
+
+// RunArchitecture returns the CPU architecture of the nodes the current or last checker pod ran on, if the
+// check chose its architectures
+func (ext *Checker) RunArchitecture() string {
+	return ext.runArchitecture
+}
+
+// NodeArchitectures returns the CPU architectures of the nodes this check runs on, if it chose any
+func (ext *Checker) NodeArchitectures() []string {
+	return ext.Architectures
+}
+
+// applyPlatformScheduling schedules the checker pod onto nodes of the operating system the check asked for and
+// the architecture of the current run.  The operating system takes precedence over the node selector of the
+// pod spec and the default node selector, which may select the Linux nodes of a mixed cluster.  The
+// architecture is required by node affinity on top of any the pod spec has.  Windows checks also tolerate
+// the taint commonly set on Windows nodes.
+func (ext *Checker) applyPlatformScheduling() {
+	if len(ext.runArchitecture) > 0 {
+		ext.requireNodeArchitecture(ext.runArchitecture)
+	}
+	if len(ext.OS) == 0 {
+		return
+	}
+	nodeSelector := make(map[string]string)
+	for k, v := range ext.PodSpec.NodeSelector {
+		nodeSelector[k] = v
+	}
+	nodeSelector[apiv1.LabelOSStable] = ext.OS
+	ext.PodSpec.NodeSelector = nodeSelector
+
+	if ext.OS != khcheckcrd.OSWindows {
+		return
+	}
+	for _, t := range ext.PodSpec.Tolerations {
+		if t.Key == WindowsTaintKey || (len(t.Key) == 0 && t.Operator == apiv1.TolerationOpExists) {
+			return
+		}
+	}
+	ext.PodSpec.Tolerations = append(ext.PodSpec.Tolerations, apiv1.Toleration{
+		Key:      WindowsTaintKey,
+		Operator: apiv1.TolerationOpEqual,
+		Value:    khcheckcrd.OSWindows,
+		Effect:   apiv1.TaintEffectNoSchedule,
+	})
+}
+
+// requireNodeArchitecture adds the architecture to the required node affinity of the pod spec.  Node selector
+// terms are ORed together, so the architecture is required by every term the pod spec already has.
+func (ext *Checker) requireNodeArchitecture(arch string) {
+	requirement := apiv1.NodeSelectorRequirement{
+		Key:      apiv1.LabelArchStable,
+		Operator: apiv1.NodeSelectorOpIn,
+		Values:   []string{arch},
+	}
+
+	if ext.PodSpec.Affinity == nil {
+		ext.PodSpec.Affinity = &apiv1.Affinity{}
+	}
+	if ext.PodSpec.Affinity.NodeAffinity == nil {
+		ext.PodSpec.Affinity.NodeAffinity = &apiv1.NodeAffinity{}
+	}
+	nodeAffinity := ext.PodSpec.Affinity.NodeAffinity
+	if nodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution == nil {
+		nodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution = &apiv1.NodeSelector{}
+	}
+	required := nodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution
+	if len(required.NodeSelectorTerms) == 0 {
+		required.NodeSelectorTerms = []apiv1.NodeSelectorTerm{{}}
+	}
+	for i := range required.NodeSelectorTerms {
+		required.NodeSelectorTerms[i].MatchExpressions = append(required.NodeSelectorTerms[i].MatchExpressions, requirement)
+	}
+}
@@ -0,0 +1,86 @@
+package external
+
+import (
+	"strings"
+	"time"
+
+	apiv1 "k8s.io/api/core/v1"
+
+	"github.com/Comcast/kuberhealthy/v2/pkg/health"
+	"github.com/Comcast/kuberhealthy/v2/pkg/usage"
+)
+
+// CheckerPod returns where the checker pod of the last run was scheduled, the image it ran, and how long it
+// took to start and run, as of the last time the pod was seen.  Returns nil if the run did not create a pod.
+func (ext *Checker) CheckerPod() *health.PodDetails {
+	ext.checkerPodMu.RLock()
+	defer ext.checkerPodMu.RUnlock()
+	return ext.checkerPod
+}
+
+// PodRequests returns the CPU and memory the checker pods of this check request from the scheduler
+func (ext *Checker) PodRequests() usage.Requests {
+	return usage.PodRequests(ext.PodSpec)
+}
+
+// setCheckerPod replaces the details of the checker pod of the current run
+func (ext *Checker) setCheckerPod(details *health.PodDetails) {
+	ext.checkerPodMu.Lock()
+	defer ext.checkerPodMu.Unlock()
+	ext.checkerPod = details
+}
+
+// observePod records the details of the checker pod of the current run each time it is seen while the run
+// waits on it, so that the last details are kept even when the pod is removed before the run ends
+func (ext *Checker) observePod(p *apiv1.Pod) {
+	if p == nil || p.Name != ext.podName() {
+		return
+	}
+	ext.setCheckerPod(podDetails(p))
+}
+
+// podDetails returns the node, image, and timing of the main container of a checker pod.  The main container
+// is the first container of the pod spec.
+func podDetails(p *apiv1.Pod) *health.PodDetails {
+	details := &health.PodDetails{
+		Name: p.Name,
+		Node: p.Spec.NodeName,
+	}
+	if len(p.Spec.Containers) == 0 {
+		return details
+	}
+	main := p.Spec.Containers[0]
+	details.Image = main.Image
+
+	for _, status := range p.Status.ContainerStatuses {
+		if status.Name != main.Name {
+			continue
+		}
+		details.ImageDigest = imageDigest(status.ImageID)
+
+		var started, finished time.Time
+		if status.State.Running != nil {
+			started = status.State.Running.StartedAt.Time
+		}
+		if status.State.Terminated != nil {
+			started = status.State.Terminated.StartedAt.Time
+			finished = status.State.Terminated.FinishedAt.Time
+		}
+		if !started.IsZero() && !p.CreationTimestamp.IsZero() {
+			details.StartLatency = started.Sub(p.CreationTimestamp.Time).String()
+		}
+		if !started.IsZero() && !finished.IsZero() {
+			details.RunDuration = finished.Sub(started).String()
+		}
+	}
+	return details
+}
+
+// imageDigest returns the digest of a container image ID such as
+// docker-pullable://quay.io/comcast/khcheck@sha256:0123, or the ID itself if it does not name a digest
+func imageDigest(imageID string) string {
+	if i := strings.LastIndex(imageID, "@"); i >= 0 {
+		return imageID[i+1:]
+	}
+	return strings.TrimPrefix(imageID, "docker://")
+}
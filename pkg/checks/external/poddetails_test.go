@@ -0,0 +1,48 @@
+package external
+
+import (
+	"testing"
+	"time"
+
+	apiv1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// TestPodDetails validates that the node, image digest, and timing of the main container are read from a
+// checker pod
+func TestPodDetails(t *testing.T) {
+	created := time.Now().Add(-time.Minute)
+	p := &apiv1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "check-1", CreationTimestamp: metav1.NewTime(created)},
+		Spec: apiv1.PodSpec{
+			NodeName:   "node-a",
+			Containers: []apiv1.Container{{Name: "main", Image: "quay.io/comcast/khcheck:1.0.0"}},
+		},
+		Status: apiv1.PodStatus{ContainerStatuses: []apiv1.ContainerStatus{{
+			Name:    "main",
+			ImageID: "docker-pullable://quay.io/comcast/khcheck@sha256:0123",
+			State: apiv1.ContainerState{Terminated: &apiv1.ContainerStateTerminated{
+				StartedAt:  metav1.NewTime(created.Add(time.Second * 5)),
+				FinishedAt: metav1.NewTime(created.Add(time.Second * 35)),
+			}},
+		}}},
+	}
+
+	details := podDetails(p)
+	if details.Node != "node-a" || details.Image != "quay.io/comcast/khcheck:1.0.0" {
+		t.Fatal("Expected the node and image of the pod but got", details.Node, details.Image)
+	}
+	if details.ImageDigest != "sha256:0123" {
+		t.Fatal("Expected the image digest sha256:0123 but got", details.ImageDigest)
+	}
+	if details.StartLatency != "5s" || details.RunDuration != "30s" {
+		t.Fatal("Expected a start latency of 5s and run duration of 30s but got", details.StartLatency, details.RunDuration)
+	}
+
+	// pods that have not started yet only have their placement
+	p.Status.ContainerStatuses = nil
+	details = podDetails(p)
+	if len(details.StartLatency) > 0 || len(details.ImageDigest) > 0 {
+		t.Fatal("Expected no timing or digest for a pod that has not started but got", details)
+	}
+}
@@ -0,0 +1,196 @@
+package external
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	apiv1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/watch"
+)
+
+// podResyncInterval is how often watched pods are listed again in case their watch missed an event
+var podResyncInterval = time.Minute
+
+// maxPodWatchFails is how many times in a row listing or watching pods can fail before a pod watch gives up
+const maxPodWatchFails = 30
+
+// bookmarkEvent is the type of watch events that only carry a newer resource version to resume the watch
+// from.  The API server sends them to clients that ask for them, and they are handled if they arrive.
+const bookmarkEvent watch.EventType = "BOOKMARK"
+
+// errPodWatchStopped is returned when a pod watch is stopped by its context before the handler finished
+var errPodWatchStopped = errors.New("pod watch stopped before it finished")
+
+// watchPods hands every change to the pods matching the list options to handle until handle returns true, handle
+// returns an error, or the context is canceled.  Pods are listed first and then watched from the resource version
+// of the list.  When the API server closes the watch, as it does when it restarts or the watch times out, the
+// watch resumes from the last resource version it saw.  Pods are listed again every podResyncInterval and
+// whenever the resource version has expired, and pods that disappeared between lists are handed to handle as
+// deleted, so that events missed while no watch was open are not lost.
+func (ext *Checker) watchPods(ctx context.Context, listOptions metav1.ListOptions, handle func(eventType watch.EventType, pod *apiv1.Pod) (bool, error)) error {
+	podClient := ext.KubeClient.CoreV1().Pods(ext.podNamespace())
+
+	known := make(map[string]bool) // the pods seen so far, so that deletions missed by the watch are noticed
+	var resourceVersion string
+	var resyncAt time.Time
+	var fails int
+	relist := true
+
+	// retry waits before listing or watching again after a failure and returns the error once it has failed too
+	// many times in a row
+	retry := func(err error) error {
+		fails++
+		if fails > maxPodWatchFails {
+			return err
+		}
+		ext.log("error when watching checker pods, retrying:", err)
+		relist = true
+		select {
+		case <-ctx.Done():
+			return errPodWatchStopped
+		case <-time.After(pollInterval):
+			return nil
+		}
+	}
+
+	for {
+		if ctx.Err() != nil {
+			return errPodWatchStopped
+		}
+
+		// list the pods to catch up on anything the watch missed
+		if relist {
+			pods, err := podClient.List(listOptions)
+			if err != nil {
+				err = retry(err)
+				if err != nil {
+					return err
+				}
+				continue
+			}
+
+			seen := make(map[string]bool)
+			for i := range pods.Items {
+				p := &pods.Items[i]
+				seen[p.Name] = true
+				eventType := watch.Modified
+				if !known[p.Name] {
+					eventType = watch.Added
+				}
+				known[p.Name] = true
+				done, err := handle(eventType, p)
+				if err != nil || done {
+					return err
+				}
+			}
+			for name := range known {
+				if seen[name] {
+					continue
+				}
+				ext.log("pod", name, "was removed while it was not being watched")
+				delete(known, name)
+				done, err := handle(watch.Deleted, &apiv1.Pod{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: ext.podNamespace()}})
+				if err != nil || done {
+					return err
+				}
+			}
+
+			resourceVersion = pods.ResourceVersion
+			resyncAt = time.Now().Add(podResyncInterval)
+			relist = false
+		}
+
+		// watch from where the list or the last watch left off
+		err := ext.waitForPodRateLimit(ctx, "watch")
+		if err != nil {
+			return errPodWatchStopped
+		}
+		watchOptions := listOptions
+		watchOptions.ResourceVersion = resourceVersion
+		timeoutSeconds := int64(time.Until(resyncAt).Seconds()) + 1
+		watchOptions.TimeoutSeconds = &timeoutSeconds
+		watcher, err := podClient.Watch(watchOptions)
+		if err != nil {
+			err = retry(err)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+		fails = 0
+
+		done, err := ext.consumePodWatch(ctx, watcher, resyncAt, known, &resourceVersion, &relist, handle)
+		watcher.Stop()
+		if err != nil || done {
+			return err
+		}
+	}
+}
+
+// consumePodWatch hands the events of a pod watch to handle until the watch closes or it is time to list the
+// pods again.  The resource version to resume from is updated as events arrive, and relist is set when the
+// resource version has expired and the pods must be listed again.
+func (ext *Checker) consumePodWatch(ctx context.Context, watcher watch.Interface, resyncAt time.Time, known map[string]bool, resourceVersion *string, relist *bool, handle func(eventType watch.EventType, pod *apiv1.Pod) (bool, error)) (bool, error) {
+	resync := time.NewTimer(time.Until(resyncAt))
+	defer resync.Stop()
+
+	for {
+		var e watch.Event
+		var ok bool
+		select {
+		case <-ctx.Done():
+			return false, errPodWatchStopped
+		case <-resync.C:
+			ext.log("resyncing checker pods")
+			*relist = true
+			return false, nil
+		case e, ok = <-watcher.ResultChan():
+		}
+
+		// the API server closed the watch, so resume it from the last resource version
+		if !ok {
+			ext.log("pod watch was closed by the API server. resuming from resource version", *resourceVersion)
+			return false, nil
+		}
+
+		switch e.Type {
+		case watch.Added, watch.Modified, watch.Deleted:
+			p, ok := e.Object.(*apiv1.Pod)
+			if !ok {
+				ext.log("got a watch event for a non-pod object and ignored it")
+				continue
+			}
+			*resourceVersion = p.ResourceVersion
+			if e.Type == watch.Deleted {
+				delete(known, p.Name)
+			} else {
+				known[p.Name] = true
+			}
+			done, err := handle(e.Type, p)
+			if err != nil || done {
+				return done, err
+			}
+		case bookmarkEvent:
+			p, ok := e.Object.(*apiv1.Pod)
+			if ok {
+				*resourceVersion = p.ResourceVersion
+			}
+		case watch.Error:
+			// an expired resource version can not be resumed from, so the pods are listed again
+			status, ok := e.Object.(*metav1.Status)
+			if ok {
+				ext.log("pod watch had an error:", status.Reason, status.Message)
+			}
+			if ok && status.Code == http.StatusGone {
+				*resourceVersion = ""
+			}
+			*relist = true
+			return false, nil
+		default:
+			ext.log("pod watch saw an irrelevant event type and ignored it:", e.Type)
+		}
+	}
+}
@@ -0,0 +1,110 @@
+package external
+
+import (
+	"fmt"
+	"reflect"
+
+	apiv1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	k8sErrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// CheckServiceAccountLabel is the label applied to the service account, role, and role binding that
+// Kuberhealthy creates for a check.  Its value is the name of the check that owns them.
+const CheckServiceAccountLabel = "kuberhealthy-check-service-account"
+
+// CheckServiceAccountName returns the name used for the service account, role, and role binding
+// that Kuberhealthy creates for the named check
+func CheckServiceAccountName(checkName string) string {
+	return "khcheck-" + checkName
+}
+
+// rbacObjectMeta returns the object metadata applied to all RBAC resources created for this check.  Resources
+// in ephemeral namespaces are removed along with the namespace, so they are not labeled for the reaper.
+func (ext *Checker) rbacObjectMeta() metav1.ObjectMeta {
+	labelName := CheckServiceAccountLabel
+	if ext.podNamespace() != ext.Namespace {
+		labelName = KuberhealthyCheckNameLabel
+	}
+	return metav1.ObjectMeta{
+		Name:      CheckServiceAccountName(ext.CheckName),
+		Namespace: ext.podNamespace(),
+		Labels: map[string]string{
+			labelName: ext.CheckName,
+		},
+	}
+}
+
+// ValidateServiceAccountRules ensures that the rules requested for the check's dedicated service account are
+// allowed by the role policy
+func (ext *Checker) ValidateServiceAccountRules() error {
+	if ext.ServiceAccountRules == nil {
+		return nil
+	}
+	err := ext.RolePolicy.Validate(ext.ServiceAccountRules)
+	if err != nil {
+		return fmt.Errorf("service account rules of check %s are not allowed: %w", ext.CheckName, err)
+	}
+	return nil
+}
+
+// ensureServiceAccount creates or updates the service account, role, and role binding requested
+// by this check's khcheck spec.  Nothing is done if the check did not request a service account.
+func (ext *Checker) ensureServiceAccount() error {
+	if ext.ServiceAccountRules == nil {
+		return nil
+	}
+
+	name := CheckServiceAccountName(ext.CheckName)
+	ext.log("Ensuring service account", name, "exists with", len(ext.ServiceAccountRules), "rules")
+
+	// create the service account if it does not exist yet
+	saClient := ext.KubeClient.CoreV1().ServiceAccounts(ext.podNamespace())
+	_, err := saClient.Get(name, metav1.GetOptions{})
+	if k8sErrors.IsNotFound(err) {
+		_, err = saClient.Create(&apiv1.ServiceAccount{ObjectMeta: ext.rbacObjectMeta()})
+	}
+	if err != nil {
+		return err
+	}
+
+	// create the role or update its rules if they have changed
+	roleClient := ext.KubeClient.RbacV1().Roles(ext.podNamespace())
+	role, err := roleClient.Get(name, metav1.GetOptions{})
+	if k8sErrors.IsNotFound(err) {
+		_, err = roleClient.Create(&rbacv1.Role{
+			ObjectMeta: ext.rbacObjectMeta(),
+			Rules:      ext.ServiceAccountRules,
+		})
+	} else if err == nil && !reflect.DeepEqual(role.Rules, ext.ServiceAccountRules) {
+		ext.log("Updating rules of role", name)
+		role.Rules = ext.ServiceAccountRules
+		_, err = roleClient.Update(role)
+	}
+	if err != nil {
+		return err
+	}
+
+	// bind the role to the service account
+	bindingClient := ext.KubeClient.RbacV1().RoleBindings(ext.podNamespace())
+	_, err = bindingClient.Get(name, metav1.GetOptions{})
+	if k8sErrors.IsNotFound(err) {
+		_, err = bindingClient.Create(&rbacv1.RoleBinding{
+			ObjectMeta: ext.rbacObjectMeta(),
+			RoleRef: rbacv1.RoleRef{
+				APIGroup: rbacv1.GroupName,
+				Kind:     "Role",
+				Name:     name,
+			},
+			Subjects: []rbacv1.Subject{
+				{
+					Kind:      rbacv1.ServiceAccountKind,
+					Name:      name,
+					Namespace: ext.podNamespace(),
+				},
+			},
+		})
+	}
+	return err
+}
@@ -0,0 +1,39 @@
+package external
+
+import (
+	"testing"
+
+	apiv1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+
+	"github.com/Comcast/kuberhealthy/v2/pkg/rolepolicy"
+)
+
+// TestValidateServiceAccountRules validates that checks are rejected when they request service account rules that
+// are not in the role allowlist
+func TestValidateServiceAccountRules(t *testing.T) {
+	ext := &Checker{CheckName: "my-check"}
+	ext.PodSpec.Containers = []apiv1.Container{{Name: "main", Image: "busybox:1.31"}}
+	if err := ext.ValidateServiceAccountRules(); err != nil {
+		t.Fatal("Expected a check without a service account to be valid but got", err)
+	}
+
+	ext.ServiceAccountRules = []rbacv1.PolicyRule{{APIGroups: []string{""}, Resources: []string{"pods"}, Verbs: []string{"get", "list"}}}
+	if err := ext.validatePodSpec(); err == nil {
+		t.Fatal("Expected the pod spec to be invalid when its rules are not in an empty allowlist")
+	}
+
+	policy, err := rolepolicy.ParsePolicy("get:pods,list:pods")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ext.RolePolicy = policy
+	if err := ext.ValidateServiceAccountRules(); err != nil {
+		t.Fatal("Expected allowed rules to pass but got", err)
+	}
+
+	ext.ServiceAccountRules = append(ext.ServiceAccountRules, rbacv1.PolicyRule{APIGroups: []string{"rbac.authorization.k8s.io"}, Resources: []string{"roles"}, Verbs: []string{"create"}})
+	if err := ext.ValidateServiceAccountRules(); err == nil {
+		t.Fatal("Expected an error for a rule that is not in the allowlist")
+	}
+}
@@ -0,0 +1,129 @@
+package external
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+
+	apiv1 "k8s.io/api/core/v1"
+	k8sErrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/Comcast/kuberhealthy/v2/pkg/khcheckcrd"
+)
+
+// secretVolumePrefix and configMapVolumePrefix prefix the names of volumes created for referenced resources
+const secretVolumePrefix = "kh-secret-"
+const configMapVolumePrefix = "kh-configmap-"
+
+// validateReference ensures a reference names a resource and says how it should be used
+func validateReference(kind string, ref khcheckcrd.ResourceRef) error {
+	if len(ref.Name) == 0 {
+		return errors.New(kind + " reference is missing a name")
+	}
+	if len(ref.MountPath) == 0 && !ref.Env {
+		return errors.New(kind + " reference " + ref.Name + " must set a mountPath, env, or both")
+	}
+	return nil
+}
+
+// ValidateReferences ensures that all secrets and config maps referenced by the check exist in the check's
+// namespace.  Missing resources would otherwise leave the checker pod stuck before it can start.
+func (ext *Checker) ValidateReferences() error {
+	for _, ref := range ext.Secrets {
+		err := validateReference("secret", ref)
+		if err != nil {
+			return err
+		}
+		_, err = ext.KubeClient.CoreV1().Secrets(ext.Namespace).Get(ref.Name, metav1.GetOptions{})
+		if k8sErrors.IsNotFound(err) {
+			return fmt.Errorf("secret %s referenced by check %s does not exist in namespace %s", ref.Name, ext.CheckName, ext.Namespace)
+		}
+		if err != nil {
+			return fmt.Errorf("error fetching secret %s referenced by check %s: %w", ref.Name, ext.CheckName, err)
+		}
+	}
+
+	for _, ref := range ext.ConfigMaps {
+		err := validateReference("config map", ref)
+		if err != nil {
+			return err
+		}
+		_, err = ext.KubeClient.CoreV1().ConfigMaps(ext.Namespace).Get(ref.Name, metav1.GetOptions{})
+		if k8sErrors.IsNotFound(err) {
+			return fmt.Errorf("config map %s referenced by check %s does not exist in namespace %s", ref.Name, ext.CheckName, ext.Namespace)
+		}
+		if err != nil {
+			return fmt.Errorf("error fetching config map %s referenced by check %s: %w", ref.Name, ext.CheckName, err)
+		}
+	}
+
+	return nil
+}
+
+// injectReferences mounts referenced secrets and config maps into every container of the pod spec and
+// injects their keys as environment variables where requested.  Volumes are named by their position in
+// the khcheck spec because resource names can be longer than volume names are allowed to be.
+func (ext *Checker) injectReferences() {
+	for i, ref := range ext.Secrets {
+		if len(ref.MountPath) > 0 {
+			ext.PodSpec.Volumes = append(ext.PodSpec.Volumes, apiv1.Volume{
+				Name: secretVolumePrefix + strconv.Itoa(i),
+				VolumeSource: apiv1.VolumeSource{
+					Secret: &apiv1.SecretVolumeSource{SecretName: ref.Name},
+				},
+			})
+		}
+	}
+
+	for i, ref := range ext.ConfigMaps {
+		if len(ref.MountPath) > 0 {
+			ext.PodSpec.Volumes = append(ext.PodSpec.Volumes, apiv1.Volume{
+				Name: configMapVolumePrefix + strconv.Itoa(i),
+				VolumeSource: apiv1.VolumeSource{
+					ConfigMap: &apiv1.ConfigMapVolumeSource{LocalObjectReference: apiv1.LocalObjectReference{Name: ref.Name}},
+				},
+			})
+		}
+	}
+
+	for c := range ext.PodSpec.Containers {
+		ext.mountReferences(&ext.PodSpec.Containers[c])
+	}
+}
+
+// mountReferences mounts the volumes of referenced secrets and config maps into a container and injects
+// their keys as environment variables where requested
+func (ext *Checker) mountReferences(container *apiv1.Container) {
+	for i, ref := range ext.Secrets {
+		if len(ref.MountPath) > 0 {
+			container.VolumeMounts = append(container.VolumeMounts, apiv1.VolumeMount{
+				Name:      secretVolumePrefix + strconv.Itoa(i),
+				MountPath: ref.MountPath,
+				ReadOnly:  true,
+			})
+		}
+		if ref.Env {
+			container.EnvFrom = append(container.EnvFrom, apiv1.EnvFromSource{
+				Prefix:    ref.EnvPrefix,
+				SecretRef: &apiv1.SecretEnvSource{LocalObjectReference: apiv1.LocalObjectReference{Name: ref.Name}},
+			})
+		}
+	}
+
+	for i, ref := range ext.ConfigMaps {
+		if len(ref.MountPath) > 0 {
+			container.VolumeMounts = append(container.VolumeMounts, apiv1.VolumeMount{
+				Name:      configMapVolumePrefix + strconv.Itoa(i),
+				MountPath: ref.MountPath,
+				ReadOnly:  true,
+			})
+		}
+		if ref.Env {
+			container.EnvFrom = append(container.EnvFrom, apiv1.EnvFromSource{
+				Prefix:       ref.EnvPrefix,
+				ConfigMapRef: &apiv1.ConfigMapEnvSource{LocalObjectReference: apiv1.LocalObjectReference{Name: ref.Name}},
+			})
+		}
+	}
+}
@@ -0,0 +1,68 @@
+package external
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/Comcast/kuberhealthy/v2/pkg/health"
+	"github.com/Comcast/kuberhealthy/v2/pkg/khcheckcrd"
+)
+
+// CheckRegressions fails the last run of the check when a measurement it reported breaks one of the check's
+// regression rules, then adds the measurements to the baselines the rules compare later runs to
+func (ext *Checker) CheckRegressions(details *health.CheckDetails) {
+	var regressions []string
+	windows := make(map[string]int)
+	for _, rule := range ext.Regressions {
+		runs := rule.BaselineRuns
+		if runs <= 0 {
+			runs = khcheckcrd.DefaultBaselineRuns
+		}
+		if runs > windows[rule.Measurement] {
+			windows[rule.Measurement] = runs
+		}
+
+		v, ok := details.Measurements[rule.Measurement]
+		if !ok {
+			continue
+		}
+		regressions = append(regressions, checkRegression(rule, v, details.Baselines, runs)...)
+	}
+
+	if len(regressions) > 0 {
+		ext.log("measurements of the last run regressed:", regressions)
+		errors := make([]string, 0, len(details.LastRunErrors)+len(regressions))
+		errors = append(errors, details.LastRunErrors...)
+		details.LastRunErrors = append(errors, regressions...)
+		details.LastRunOK = false
+	}
+	details.RecordBaselines(windows)
+}
+
+// checkRegression returns an error for each way a measured value breaks a regression rule
+func checkRegression(rule khcheckcrd.RegressionRule, v float64, baselines health.Baselines, runs int) []string {
+	var regressions []string
+	name := rule.Measurement
+
+	if rule.Max != nil && v > *rule.Max {
+		regressions = append(regressions, fmt.Sprintf("%s of %g is above the maximum of %g", name, v, *rule.Max))
+	}
+	if rule.Min != nil && v < *rule.Min {
+		regressions = append(regressions, fmt.Sprintf("%s of %g is below the minimum of %g", name, v, *rule.Min))
+	}
+
+	// changes are only measured once there is a full baseline to measure them against
+	baseline, ok := baselines.Mean(name, runs)
+	if !ok || baseline == 0 {
+		return regressions
+	}
+	change := (v - baseline) / math.Abs(baseline) * 100
+	baseline = math.Round(baseline*100) / 100
+	if rule.MaxIncreasePercent > 0 && change > rule.MaxIncreasePercent {
+		regressions = append(regressions, fmt.Sprintf("%s of %g is %.1f%% above its baseline of %g", name, v, change, baseline))
+	}
+	if rule.MaxDecreasePercent > 0 && -change > rule.MaxDecreasePercent {
+		regressions = append(regressions, fmt.Sprintf("%s of %g is %.1f%% below its baseline of %g", name, v, -change, baseline))
+	}
+	return regressions
+}
@@ -0,0 +1,11 @@
+// Package reportgrpc holds the Go bindings for report.proto, the gRPC
+// protocol external checks can use to report their status to Kuberhealthy
+// instead of posting JSON to the /externalCheckStatus endpoint.
+//
+// report.pb.go is generated from report.proto with protoc and the
+// protoc-gen-go plugin of github.com/golang/protobuf v1.2.0, which matches
+// the protobuf and gRPC versions in go.mod.  Run go generate in this
+// directory after changing report.proto.
+package reportgrpc
+
+//go:generate protoc --go_out=plugins=grpc:. report.proto
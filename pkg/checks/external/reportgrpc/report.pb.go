@@ -0,0 +1,406 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// source: report.proto
+
+package reportgrpc
+
+import proto "github.com/golang/protobuf/proto"
+import fmt "fmt"
+import math "math"
+
+import (
+	context "golang.org/x/net/context"
+	grpc "google.golang.org/grpc"
+)
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ = proto.Marshal
+var _ = fmt.Errorf
+var _ = math.Inf
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the proto package it is being compiled against.
+// A compilation error at this line likely means your copy of the
+// proto package needs to be updated.
+const _ = proto.ProtoPackageIsVersion2 // please upgrade the proto package
+
+type ReportRequest struct {
+	// ok indicates that the check run passed. Must be false if errors are set.
+	Ok bool `protobuf:"varint,1,opt,name=ok,proto3" json:"ok,omitempty"`
+	// errors are surfaced on the status page when ok is false.
+	Errors []string `protobuf:"bytes,2,rep,name=errors,proto3" json:"errors,omitempty"`
+	// final marks this request as the result of the run. Non-final requests are
+	// treated as progress updates.
+	Final bool `protobuf:"varint,3,opt,name=final,proto3" json:"final,omitempty"`
+	// progress_message is a human readable progress note for non-final updates.
+	ProgressMessage string `protobuf:"bytes,4,opt,name=progress_message,json=progressMessage,proto3" json:"progress_message,omitempty"`
+	// progress_percent is how far along the run is for non-final updates, from 0 to 100.
+	ProgressPercent int32 `protobuf:"varint,5,opt,name=progress_percent,json=progressPercent,proto3" json:"progress_percent,omitempty"`
+	// assertions are named sub-check results that make up a final report.
+	Assertions []*Assertion `protobuf:"bytes,6,rep,name=assertions,proto3" json:"assertions,omitempty"`
+	// measurements are numeric values measured during a final report's run,
+	// such as latencies or counts, keyed by name.
+	Measurements         map[string]float64 `protobuf:"bytes,7,rep,name=measurements,proto3" json:"measurements,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"fixed64,2,opt,name=value,proto3"`
+	XXX_NoUnkeyedLiteral struct{}           `json:"-"`
+	XXX_unrecognized     []byte             `json:"-"`
+	XXX_sizecache        int32              `json:"-"`
+}
+
+func (m *ReportRequest) Reset()         { *m = ReportRequest{} }
+func (m *ReportRequest) String() string { return proto.CompactTextString(m) }
+func (*ReportRequest) ProtoMessage()    {}
+func (*ReportRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_report_a0fb3419426055fa, []int{0}
+}
+func (m *ReportRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_ReportRequest.Unmarshal(m, b)
+}
+func (m *ReportRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_ReportRequest.Marshal(b, m, deterministic)
+}
+func (dst *ReportRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ReportRequest.Merge(dst, src)
+}
+func (m *ReportRequest) XXX_Size() int {
+	return xxx_messageInfo_ReportRequest.Size(m)
+}
+func (m *ReportRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_ReportRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ReportRequest proto.InternalMessageInfo
+
+func (m *ReportRequest) GetOk() bool {
+	if m != nil {
+		return m.Ok
+	}
+	return false
+}
+
+func (m *ReportRequest) GetErrors() []string {
+	if m != nil {
+		return m.Errors
+	}
+	return nil
+}
+
+func (m *ReportRequest) GetFinal() bool {
+	if m != nil {
+		return m.Final
+	}
+	return false
+}
+
+func (m *ReportRequest) GetProgressMessage() string {
+	if m != nil {
+		return m.ProgressMessage
+	}
+	return ""
+}
+
+func (m *ReportRequest) GetProgressPercent() int32 {
+	if m != nil {
+		return m.ProgressPercent
+	}
+	return 0
+}
+
+func (m *ReportRequest) GetAssertions() []*Assertion {
+	if m != nil {
+		return m.Assertions
+	}
+	return nil
+}
+
+func (m *ReportRequest) GetMeasurements() map[string]float64 {
+	if m != nil {
+		return m.Measurements
+	}
+	return nil
+}
+
+type Assertion struct {
+	Name string `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	Ok   bool   `protobuf:"varint,2,opt,name=ok,proto3" json:"ok,omitempty"`
+	// error describes why the assertion failed.
+	Error                string   `protobuf:"bytes,3,opt,name=error,proto3" json:"error,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *Assertion) Reset()         { *m = Assertion{} }
+func (m *Assertion) String() string { return proto.CompactTextString(m) }
+func (*Assertion) ProtoMessage()    {}
+func (*Assertion) Descriptor() ([]byte, []int) {
+	return fileDescriptor_report_a0fb3419426055fa, []int{1}
+}
+func (m *Assertion) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_Assertion.Unmarshal(m, b)
+}
+func (m *Assertion) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_Assertion.Marshal(b, m, deterministic)
+}
+func (dst *Assertion) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_Assertion.Merge(dst, src)
+}
+func (m *Assertion) XXX_Size() int {
+	return xxx_messageInfo_Assertion.Size(m)
+}
+func (m *Assertion) XXX_DiscardUnknown() {
+	xxx_messageInfo_Assertion.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_Assertion proto.InternalMessageInfo
+
+func (m *Assertion) GetName() string {
+	if m != nil {
+		return m.Name
+	}
+	return ""
+}
+
+func (m *Assertion) GetOk() bool {
+	if m != nil {
+		return m.Ok
+	}
+	return false
+}
+
+func (m *Assertion) GetError() string {
+	if m != nil {
+		return m.Error
+	}
+	return ""
+}
+
+type ReportResponse struct {
+	Accepted             bool     `protobuf:"varint,1,opt,name=accepted,proto3" json:"accepted,omitempty"`
+	Message              string   `protobuf:"bytes,2,opt,name=message,proto3" json:"message,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *ReportResponse) Reset()         { *m = ReportResponse{} }
+func (m *ReportResponse) String() string { return proto.CompactTextString(m) }
+func (*ReportResponse) ProtoMessage()    {}
+func (*ReportResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_report_a0fb3419426055fa, []int{2}
+}
+func (m *ReportResponse) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_ReportResponse.Unmarshal(m, b)
+}
+func (m *ReportResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_ReportResponse.Marshal(b, m, deterministic)
+}
+func (dst *ReportResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ReportResponse.Merge(dst, src)
+}
+func (m *ReportResponse) XXX_Size() int {
+	return xxx_messageInfo_ReportResponse.Size(m)
+}
+func (m *ReportResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_ReportResponse.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ReportResponse proto.InternalMessageInfo
+
+func (m *ReportResponse) GetAccepted() bool {
+	if m != nil {
+		return m.Accepted
+	}
+	return false
+}
+
+func (m *ReportResponse) GetMessage() string {
+	if m != nil {
+		return m.Message
+	}
+	return ""
+}
+
+func init() {
+	proto.RegisterType((*ReportRequest)(nil), "kuberhealthy.report.v1.ReportRequest")
+	proto.RegisterMapType((map[string]float64)(nil), "kuberhealthy.report.v1.ReportRequest.MeasurementsEntry")
+	proto.RegisterType((*Assertion)(nil), "kuberhealthy.report.v1.Assertion")
+	proto.RegisterType((*ReportResponse)(nil), "kuberhealthy.report.v1.ReportResponse")
+}
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ context.Context
+var _ grpc.ClientConn
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+const _ = grpc.SupportPackageIsVersion4
+
+// ReportServiceClient is the client API for ReportService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://godoc.org/google.golang.org/grpc#ClientConn.NewStream.
+type ReportServiceClient interface {
+	// Report submits a single, final check result.
+	Report(ctx context.Context, in *ReportRequest, opts ...grpc.CallOption) (*ReportResponse, error)
+	// StreamReport accepts any number of progress updates followed by a final
+	// check result.  The stream is closed by the server once the final result
+	// has been stored.
+	StreamReport(ctx context.Context, opts ...grpc.CallOption) (ReportService_StreamReportClient, error)
+}
+
+type reportServiceClient struct {
+	cc *grpc.ClientConn
+}
+
+func NewReportServiceClient(cc *grpc.ClientConn) ReportServiceClient {
+	return &reportServiceClient{cc}
+}
+
+func (c *reportServiceClient) Report(ctx context.Context, in *ReportRequest, opts ...grpc.CallOption) (*ReportResponse, error) {
+	out := new(ReportResponse)
+	err := c.cc.Invoke(ctx, "/kuberhealthy.report.v1.ReportService/Report", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *reportServiceClient) StreamReport(ctx context.Context, opts ...grpc.CallOption) (ReportService_StreamReportClient, error) {
+	stream, err := c.cc.NewStream(ctx, &_ReportService_serviceDesc.Streams[0], "/kuberhealthy.report.v1.ReportService/StreamReport", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &reportServiceStreamReportClient{stream}
+	return x, nil
+}
+
+type ReportService_StreamReportClient interface {
+	Send(*ReportRequest) error
+	CloseAndRecv() (*ReportResponse, error)
+	grpc.ClientStream
+}
+
+type reportServiceStreamReportClient struct {
+	grpc.ClientStream
+}
+
+func (x *reportServiceStreamReportClient) Send(m *ReportRequest) error {
+	return x.ClientStream.SendMsg(m)
+}
+
+func (x *reportServiceStreamReportClient) CloseAndRecv() (*ReportResponse, error) {
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	m := new(ReportResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// ReportServiceServer is the server API for ReportService service.
+type ReportServiceServer interface {
+	// Report submits a single, final check result.
+	Report(context.Context, *ReportRequest) (*ReportResponse, error)
+	// StreamReport accepts any number of progress updates followed by a final
+	// check result.  The stream is closed by the server once the final result
+	// has been stored.
+	StreamReport(ReportService_StreamReportServer) error
+}
+
+func RegisterReportServiceServer(s *grpc.Server, srv ReportServiceServer) {
+	s.RegisterService(&_ReportService_serviceDesc, srv)
+}
+
+func _ReportService_Report_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ReportRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ReportServiceServer).Report(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/kuberhealthy.report.v1.ReportService/Report",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ReportServiceServer).Report(ctx, req.(*ReportRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ReportService_StreamReport_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(ReportServiceServer).StreamReport(&reportServiceStreamReportServer{stream})
+}
+
+type ReportService_StreamReportServer interface {
+	SendAndClose(*ReportResponse) error
+	Recv() (*ReportRequest, error)
+	grpc.ServerStream
+}
+
+type reportServiceStreamReportServer struct {
+	grpc.ServerStream
+}
+
+func (x *reportServiceStreamReportServer) SendAndClose(m *ReportResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func (x *reportServiceStreamReportServer) Recv() (*ReportRequest, error) {
+	m := new(ReportRequest)
+	if err := x.ServerStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+var _ReportService_serviceDesc = grpc.ServiceDesc{
+	ServiceName: "kuberhealthy.report.v1.ReportService",
+	HandlerType: (*ReportServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Report",
+			Handler:    _ReportService_Report_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "StreamReport",
+			Handler:       _ReportService_StreamReport_Handler,
+			ClientStreams: true,
+		},
+	},
+	Metadata: "report.proto",
+}
+
+func init() { proto.RegisterFile("report.proto", fileDescriptor_report_a0fb3419426055fa) }
+
+var fileDescriptor_report_a0fb3419426055fa = []byte{
+	// 386 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xb4, 0x53, 0xc1, 0xaa, 0xd3, 0x40,
+	0x14, 0x65, 0x92, 0x97, 0xbc, 0x97, 0x6b, 0x7c, 0x3e, 0x87, 0x52, 0x86, 0xae, 0x62, 0x41, 0x89,
+	0x9b, 0x80, 0x75, 0xa1, 0xb8, 0x91, 0x0a, 0x75, 0x57, 0x90, 0xe9, 0x42, 0xd0, 0x45, 0x99, 0xc6,
+	0x6b, 0x5b, 0xd2, 0x64, 0xe2, 0x9d, 0x49, 0xa1, 0x7b, 0x7f, 0xce, 0xbf, 0x92, 0x4e, 0x92, 0xda,
+	0xa2, 0xa2, 0x9b, 0xb7, 0x9b, 0x73, 0xe6, 0xdc, 0xc3, 0xcd, 0x39, 0x19, 0x88, 0x09, 0x6b, 0x4d,
+	0x36, 0xab, 0x49, 0x5b, 0xcd, 0x87, 0x45, 0xb3, 0x42, 0xda, 0xa0, 0xda, 0xd9, 0xcd, 0x21, 0xeb,
+	0xae, 0xf6, 0x2f, 0xc6, 0xdf, 0x7d, 0x78, 0x28, 0x1d, 0x92, 0xf8, 0xad, 0x41, 0x63, 0xf9, 0x2d,
+	0x78, 0xba, 0x10, 0x2c, 0x61, 0xe9, 0x8d, 0xf4, 0x74, 0xc1, 0x87, 0x10, 0x22, 0x91, 0x26, 0x23,
+	0xbc, 0xc4, 0x4f, 0x23, 0xd9, 0x21, 0x3e, 0x80, 0xe0, 0xeb, 0xb6, 0x52, 0x3b, 0xe1, 0x3b, 0x69,
+	0x0b, 0xf8, 0x73, 0xb8, 0xab, 0x49, 0xaf, 0x09, 0x8d, 0x59, 0x96, 0x68, 0x8c, 0x5a, 0xa3, 0xb8,
+	0x4a, 0x58, 0x1a, 0xc9, 0x47, 0x3d, 0x3f, 0x6f, 0xe9, 0x0b, 0x69, 0x8d, 0x94, 0x63, 0x65, 0x45,
+	0x90, 0xb0, 0x34, 0xf8, 0x25, 0xfd, 0xd0, 0xd2, 0x7c, 0x0a, 0xa0, 0x8c, 0x41, 0xb2, 0x5b, 0x5d,
+	0x19, 0x11, 0x26, 0x7e, 0xfa, 0x60, 0xf2, 0x24, 0xfb, 0xf3, 0x27, 0x65, 0xd3, 0x5e, 0x29, 0xcf,
+	0x86, 0xf8, 0x67, 0x88, 0x4b, 0x54, 0xa6, 0x21, 0x2c, 0xb1, 0xb2, 0x46, 0x5c, 0x3b, 0x93, 0x57,
+	0x7f, 0x33, 0xb9, 0xc8, 0x24, 0x9b, 0x9f, 0x4d, 0xce, 0x2a, 0x4b, 0x07, 0x79, 0x61, 0x36, 0x7a,
+	0x0b, 0x8f, 0x7f, 0x93, 0xf0, 0x3b, 0xf0, 0x0b, 0x3c, 0xb8, 0x24, 0x23, 0x79, 0x3c, 0x1e, 0x23,
+	0xdb, 0xab, 0x5d, 0x83, 0xc2, 0x4b, 0x58, 0xca, 0x64, 0x0b, 0xde, 0x78, 0xaf, 0xd9, 0x78, 0x06,
+	0xd1, 0x69, 0x6d, 0xce, 0xe1, 0xaa, 0x52, 0x25, 0x76, 0x93, 0xee, 0xdc, 0xb5, 0xe2, 0x9d, 0x5a,
+	0x19, 0x40, 0xe0, 0x7a, 0x70, 0xe9, 0x47, 0xb2, 0x05, 0xe3, 0xf7, 0x70, 0xdb, 0x2f, 0x6e, 0x6a,
+	0x5d, 0x19, 0xe4, 0x23, 0xb8, 0x51, 0x79, 0x8e, 0xb5, 0xc5, 0x2f, 0x5d, 0xa7, 0x27, 0xcc, 0x05,
+	0x5c, 0xf7, 0x15, 0x79, 0xce, 0xa5, 0x87, 0x93, 0x1f, 0xac, 0xff, 0x2b, 0x16, 0x48, 0xfb, 0x6d,
+	0x8e, 0xfc, 0x23, 0x84, 0x2d, 0xc1, 0x9f, 0xfe, 0x57, 0x64, 0xa3, 0x67, 0xff, 0x92, 0x75, 0x0b,
+	0x2e, 0x21, 0x5e, 0x58, 0x42, 0x55, 0xde, 0x8b, 0x7d, 0xca, 0xde, 0xc5, 0x9f, 0xa0, 0xbd, 0x5d,
+	0x53, 0x9d, 0xaf, 0x42, 0xf7, 0x1c, 0x5e, 0xfe, 0x1c, 0x00, 0xdb, 0x5b, 0x69, 0x33, 0x1e, 0x03,
+	0x00, 0x00,
+}
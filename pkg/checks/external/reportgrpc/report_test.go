@@ -0,0 +1,101 @@
+package reportgrpc
+
+import (
+	"context"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// recordingServer stores every request it receives
+type recordingServer struct {
+	received []*ReportRequest
+}
+
+func (s *recordingServer) Report(ctx context.Context, r *ReportRequest) (*ReportResponse, error) {
+	s.received = append(s.received, r)
+	return &ReportResponse{Accepted: true}, nil
+}
+
+func (s *recordingServer) StreamReport(stream ReportService_StreamReportServer) error {
+	for {
+		r, err := stream.Recv()
+		if err == io.EOF {
+			return stream.SendAndClose(&ReportResponse{Accepted: true, Message: "stream done"})
+		}
+		if err != nil {
+			return err
+		}
+		s.received = append(s.received, r)
+	}
+}
+
+// newTestClient starts a server on an in-memory listener and returns a client for it
+func newTestClient(t *testing.T, srv ReportServiceServer) (ReportServiceClient, func()) {
+	listener := bufconn.Listen(1024 * 1024)
+	s := grpc.NewServer()
+	RegisterReportServiceServer(s, srv)
+	go s.Serve(listener)
+
+	dialer := func(string, time.Duration) (net.Conn, error) {
+		return listener.Dial()
+	}
+	conn, err := grpc.Dial("bufnet", grpc.WithDialer(dialer), grpc.WithInsecure())
+	if err != nil {
+		t.Fatal("failed to dial test server:", err)
+	}
+	return NewReportServiceClient(conn), func() {
+		conn.Close()
+		s.Stop()
+	}
+}
+
+func TestReport(t *testing.T) {
+	srv := &recordingServer{}
+	client, done := newTestClient(t, srv)
+	defer done()
+
+	resp, err := client.Report(context.Background(), &ReportRequest{Ok: false, Errors: []string{"broken"}, Final: true})
+	if err != nil {
+		t.Fatal("report failed:", err)
+	}
+	if !resp.Accepted {
+		t.Fatal("expected report to be accepted")
+	}
+	if len(srv.received) != 1 || srv.received[0].Errors[0] != "broken" || !srv.received[0].Final {
+		t.Fatalf("server did not receive the expected report: %+v", srv.received)
+	}
+}
+
+func TestStreamReport(t *testing.T) {
+	srv := &recordingServer{}
+	client, done := newTestClient(t, srv)
+	defer done()
+
+	stream, err := client.StreamReport(context.Background())
+	if err != nil {
+		t.Fatal("failed to open stream:", err)
+	}
+	err = stream.Send(&ReportRequest{ProgressMessage: "halfway"})
+	if err != nil {
+		t.Fatal("failed to send progress:", err)
+	}
+	err = stream.Send(&ReportRequest{Ok: true, Final: true, Assertions: []*Assertion{{Name: "dns", Ok: true}}, Measurements: map[string]float64{"latency_ms": 12.5}})
+	if err != nil {
+		t.Fatal("failed to send final report:", err)
+	}
+	resp, err := stream.CloseAndRecv()
+	if err != nil {
+		t.Fatal("failed to close stream:", err)
+	}
+	if resp.Message != "stream done" {
+		t.Fatal("unexpected response message:", resp.Message)
+	}
+	if len(srv.received) != 2 || srv.received[0].ProgressMessage != "halfway" || !srv.received[1].Ok || srv.received[1].Assertions[0].Name != "dns" || srv.received[1].Measurements["latency_ms"] != 12.5 {
+		t.Fatalf("server did not receive the expected reports: %+v", srv.received)
+	}
+}
@@ -0,0 +1,82 @@
+package external
+
+import (
+	"errors"
+	"fmt"
+
+	authenticationv1 "k8s.io/api/authentication/v1"
+	apiv1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/kubernetes"
+
+	"github.com/Comcast/kuberhealthy/v2/pkg/health"
+)
+
+// ValidateReportingPod ensures that a report from the supplied IP came from the checker pod Kuberhealthy created
+// for the run, and not from another workload that copied the check's annotations, labels, and run UUID.  The
+// namespace and name of the checker pod are recorded on the check state before the pod is created, and the report
+// is only accepted when the reporting pod is that pod, is running, and has the IP the report came from.  Teardown
+// pods are never recorded, so they can not report for their run.
+func ValidateReportingPod(pod *apiv1.Pod, state health.CheckDetails, runID string, ip string) error {
+	recorded := state.RunPod(runID)
+	if len(recorded) == 0 {
+		return fmt.Errorf("no checker pod was recorded for run %s", runID)
+	}
+	if pod.Namespace+"/"+pod.Name != recorded {
+		return fmt.Errorf("reporting pod %s/%s is not checker pod %s of run %s", pod.Namespace, pod.Name, recorded, runID)
+	}
+	if pod.Status.Phase != apiv1.PodRunning {
+		return fmt.Errorf("checker pod %s of run %s is %s instead of running", recorded, runID, pod.Status.Phase)
+	}
+	if pod.Status.PodIP != ip {
+		return fmt.Errorf("report came from %s but checker pod %s of run %s has IP %s", ip, recorded, runID, pod.Status.PodIP)
+	}
+	return nil
+}
+
+// podUIDExtra is the extra field of a token review that holds the UID of the pod a projected service account
+// token is bound to
+const podUIDExtra = "authentication.kubernetes.io/pod-uid"
+
+// ValidateReportingToken ensures that a report carries a service account token issued for the audience and bound
+// to the reporting pod.  The token is reviewed by the Kubernetes API, which proves that it was issued to the pod's
+// service account and has not expired.  Because the token is bound to the pod's UID, it can not be replayed by
+// any other pod.
+func ValidateReportingToken(client kubernetes.Interface, pod *apiv1.Pod, audience string, token string) error {
+	if len(token) == 0 {
+		return errors.New("report did not include a service account token")
+	}
+
+	review, err := client.AuthenticationV1().TokenReviews().Create(&authenticationv1.TokenReview{
+		Spec: authenticationv1.TokenReviewSpec{
+			Token:     token,
+			Audiences: []string{audience},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to review service account token: %w", err)
+	}
+	if !review.Status.Authenticated {
+		return fmt.Errorf("service account token was not authenticated: %s", review.Status.Error)
+	}
+	var audienceFound bool
+	for _, a := range review.Status.Audiences {
+		audienceFound = audienceFound || a == audience
+	}
+	if !audienceFound {
+		return fmt.Errorf("service account token was not issued for audience %s", audience)
+	}
+
+	serviceAccount := pod.Spec.ServiceAccountName
+	if len(serviceAccount) == 0 {
+		serviceAccount = "default"
+	}
+	expectedUser := "system:serviceaccount:" + pod.Namespace + ":" + serviceAccount
+	if review.Status.User.Username != expectedUser {
+		return fmt.Errorf("service account token belongs to %s instead of %s", review.Status.User.Username, expectedUser)
+	}
+	podUID := review.Status.User.Extra[podUIDExtra]
+	if len(podUID) != 1 || podUID[0] != string(pod.UID) {
+		return fmt.Errorf("service account token is not bound to reporting pod %s", pod.Name)
+	}
+	return nil
+}
@@ -0,0 +1,116 @@
+package external
+
+import (
+	"testing"
+
+	authenticationv1 "k8s.io/api/authentication/v1"
+	apiv1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+
+	"github.com/Comcast/kuberhealthy/v2/pkg/health"
+)
+
+// reportingTestPod returns a running pod with the labels of a checker pod of the supplied run
+func reportingTestPod(name string, uid string, runID string, ip string) *apiv1.Pod {
+	return &apiv1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: defaultNamespace,
+			UID:       types.UID(uid),
+			Labels:    map[string]string{KuberhealthyCheckNameLabel: "http", kuberhealthyRunIDLabel: runID},
+		},
+		Status: apiv1.PodStatus{Phase: apiv1.PodRunning, PodIP: ip},
+	}
+}
+
+// TestValidateReportingPod validates that reports are only accepted from the running checker pod recorded for
+// a run and from the IP in its status
+func TestValidateReportingPod(t *testing.T) {
+	checker := reportingTestPod("http-1", "1", "run-1", "10.0.0.1")
+	state := health.NewCheckDetails()
+	state.StartRun("run-1", 0)
+	state.RecordRunPod("run-1", defaultNamespace+"/http-1")
+
+	err := ValidateReportingPod(checker, state, "run-1", "10.0.0.1")
+	if err != nil {
+		t.Fatal("Expected the checker pod to be allowed to report but got", err)
+	}
+
+	err = ValidateReportingPod(checker, state, "run-1", "10.0.0.2")
+	if err == nil {
+		t.Fatal("Expected a report from another IP to be rejected")
+	}
+
+	err = ValidateReportingPod(checker, state, "run-2", "10.0.0.1")
+	if err == nil {
+		t.Fatal("Expected a report for a run without a recorded checker pod to be rejected")
+	}
+
+	// a pod that copied the labels of the run is rejected, and does not keep the checker pod from reporting
+	impostor := reportingTestPod("impostor", "2", "run-1", "10.0.0.3")
+	err = ValidateReportingPod(impostor, state, "run-1", "10.0.0.3")
+	if err == nil {
+		t.Fatal("Expected a report from a pod that copied the labels of the run to be rejected")
+	}
+	err = ValidateReportingPod(checker, state, "run-1", "10.0.0.1")
+	if err != nil {
+		t.Fatal("Expected the checker pod to be allowed to report alongside a pod that copied its labels but got", err)
+	}
+
+	// teardown pods can not report for their run
+	teardown := reportingTestPod("http-1-teardown", "3", "run-1", "10.0.0.4")
+	teardown.Labels[HookLabel] = hookTeardown
+	err = ValidateReportingPod(teardown, state, "run-1", "10.0.0.4")
+	if err == nil {
+		t.Fatal("Expected a report from a teardown pod to be rejected")
+	}
+
+	// the pod recorded for a run that is no longer authorized can not report
+	state.StartRun("run-2", 0)
+	err = ValidateReportingPod(checker, state, "run-1", "10.0.0.1")
+	if err == nil {
+		t.Fatal("Expected a report from the checker pod of an earlier run to be rejected")
+	}
+}
+
+// TestValidateReportingToken validates that reports are only accepted with a token that the Kubernetes API
+// authenticates for the audience and that is bound to the reporting pod
+func TestValidateReportingToken(t *testing.T) {
+	pod := reportingTestPod("http-1", "1", "run-1", "10.0.0.1")
+	pod.Spec.ServiceAccountName = "http-sa"
+
+	// the fake API authenticates tokens named after the UID of the pod they are bound to
+	client := fake.NewSimpleClientset()
+	client.PrependReactor("create", "tokenreviews", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		review := action.(k8stesting.CreateAction).GetObject().(*authenticationv1.TokenReview)
+		if review.Spec.Token == "invalid" {
+			return true, review, nil
+		}
+		review.Status.Authenticated = true
+		review.Status.Audiences = review.Spec.Audiences
+		review.Status.User.Username = "system:serviceaccount:" + defaultNamespace + ":http-sa"
+		review.Status.User.Extra = map[string]authenticationv1.ExtraValue{podUIDExtra: {review.Spec.Token}}
+		return true, review, nil
+	})
+
+	err := ValidateReportingToken(client, pod, "kuberhealthy", "1")
+	if err != nil {
+		t.Fatal("Expected a token bound to the reporting pod to be accepted but got", err)
+	}
+	for _, token := range []string{"", "invalid", "2"} {
+		err = ValidateReportingToken(client, pod, "kuberhealthy", token)
+		if err == nil {
+			t.Fatal("Expected token", token, "to be rejected")
+		}
+	}
+
+	pod.Spec.ServiceAccountName = "other-sa"
+	err = ValidateReportingToken(client, pod, "kuberhealthy", "1")
+	if err == nil {
+		t.Fatal("Expected a token of another service account to be rejected")
+	}
+}
@@ -3,10 +3,27 @@
 // status reporting endpoint.
 package status
 
+import (
+	"errors"
+	"math"
+
+	"github.com/Comcast/kuberhealthy/v2/pkg/health"
+)
+
+// ArtifactPath is the path of the Kuberhealthy endpoint that checker pods upload artifacts to.  The artifact is
+// sent as the body of a POST request and named with the ArtifactNameParam query parameter.
+const ArtifactPath = "/externalCheckArtifact"
+const ArtifactNameParam = "name"
+
 // Report is the format expected by the /externalCheckStatus endpoint
 type Report struct {
-	Errors []string
-	OK     bool
+	Errors       []string
+	OK           bool
+	InProgress   bool                `json:",omitempty"` // indicates this is an intermediate update and the run is not done yet
+	Progress     int                 `json:",omitempty"` // percent complete of an in-progress run, from 0 to 100
+	Message      string              `json:",omitempty"` // a description of what an in-progress run is doing
+	Assertions   []health.Assertion  `json:",omitempty"` // named sub-check results that make up this run
+	Measurements health.Measurements `json:",omitempty"` // numeric values measured during this run, such as latencies or counts
 }
 
 // NewReport creates a new error report to be sent to the server.  If
@@ -24,3 +41,86 @@ func NewReport(errorMessages []string) Report {
 		OK:     ok,
 	}
 }
+
+// NewProgressReport creates an intermediate report that tells Kuberhealthy how far along a run is
+func NewProgressReport(percent int, message string) Report {
+	return Report{
+		Errors:     []string{},
+		InProgress: true,
+		Progress:   percent,
+		Message:    message,
+	}
+}
+
+// NewAssertionReport creates a final report from a set of named sub-check results.  The
+// report is OK only if every assertion is OK, and each failed assertion adds an error.
+func NewAssertionReport(assertions []health.Assertion) Report {
+	var errorMessages []string
+	for _, a := range assertions {
+		if a.OK {
+			continue
+		}
+		errorMessages = append(errorMessages, a.Name+": "+a.Error)
+	}
+	r := NewReport(errorMessages)
+	if r.Errors == nil {
+		r.Errors = []string{}
+	}
+	r.Assertions = assertions
+	return r
+}
+
+// NewMeasurementReport creates a final report that carries numeric measurements of the run, such as latencies
+// or counts.  Like NewReport, the report is OK when there are no errors.
+func NewMeasurementReport(measurements health.Measurements, errorMessages []string) Report {
+	r := NewReport(errorMessages)
+	if r.Errors == nil {
+		r.Errors = []string{}
+	}
+	r.Measurements = measurements
+	return r
+}
+
+// Validate ensures that a report is well formed.  Final reports that are not OK must
+// carry at least one non-blank error, and can not contain failed assertions while OK.
+func (r Report) Validate() error {
+
+	// in-progress updates only need a sane percentage
+	if r.InProgress {
+		if r.Progress < 0 || r.Progress > 100 {
+			return errors.New("progress must be between 0 and 100")
+		}
+		return nil
+	}
+
+	for name, v := range r.Measurements {
+		if len(name) == 0 {
+			return errors.New("measurements must have a name")
+		}
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			return errors.New("measurement " + name + " must be a finite number")
+		}
+	}
+
+	for _, a := range r.Assertions {
+		if len(a.Name) == 0 {
+			return errors.New("assertions must have a name")
+		}
+		if !a.OK && r.OK {
+			return errors.New("report can not be OK when assertion " + a.Name + " failed")
+		}
+	}
+
+	if r.OK {
+		return nil
+	}
+	if len(r.Errors) == 0 {
+		return errors.New("client attempted to report OK false without any error strings")
+	}
+	for _, e := range r.Errors {
+		if len(e) == 0 {
+			return errors.New("client attempted to report a blank error string")
+		}
+	}
+	return nil
+}
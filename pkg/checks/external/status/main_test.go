@@ -0,0 +1,37 @@
+package status
+
+import (
+	"math"
+	"testing"
+
+	"github.com/Comcast/kuberhealthy/v2/pkg/health"
+)
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		report  Report
+		wantErr bool
+	}{
+		{name: "ok", report: NewReport(nil)},
+		{name: "failure with errors", report: NewReport([]string{"broken"})},
+		{name: "failure without errors", report: Report{OK: false}, wantErr: true},
+		{name: "blank error", report: Report{OK: false, Errors: []string{""}}, wantErr: true},
+		{name: "progress", report: NewProgressReport(50, "halfway")},
+		{name: "progress out of range", report: NewProgressReport(150, ""), wantErr: true},
+		{name: "failed assertions", report: NewAssertionReport([]health.Assertion{{Name: "dns", OK: false, Error: "no answer"}})},
+		{name: "passed assertions", report: NewAssertionReport([]health.Assertion{{Name: "dns", OK: true}})},
+		{name: "unnamed assertion", report: NewAssertionReport([]health.Assertion{{OK: true}}), wantErr: true},
+		{name: "ok with failed assertion", report: Report{OK: true, Assertions: []health.Assertion{{Name: "dns"}}}, wantErr: true},
+		{name: "measurements", report: NewMeasurementReport(health.Measurements{"latency_ms": 12.5}, nil)},
+		{name: "unnamed measurement", report: NewMeasurementReport(health.Measurements{"": 1}, nil), wantErr: true},
+		{name: "infinite measurement", report: NewMeasurementReport(health.Measurements{"latency_ms": math.Inf(1)}, nil), wantErr: true},
+	}
+
+	for _, tt := range tests {
+		err := tt.report.Validate()
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: expected error %t, got %v", tt.name, tt.wantErr, err)
+		}
+	}
+}
@@ -0,0 +1,303 @@
+// Package federation polls the status pages of Kuberhealthy instances running in other clusters so that
+// one Kuberhealthy instance can serve a combined status for a fleet of clusters.
+package federation
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ghodss/yaml"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/Comcast/kuberhealthy/v2/pkg/health"
+	"github.com/Comcast/kuberhealthy/v2/pkg/proxy"
+)
+
+// Config is the list of remote Kuberhealthy instances to federate and the upstream collector to push to,
+// as loaded from a YAML or JSON file
+type Config struct {
+	Clusters []Remote  `json:"clusters"`
+	Upstream *Upstream `json:"upstream,omitempty"`
+}
+
+// Remote is a Kuberhealthy instance in another cluster and the credentials used to reach its status page.
+// Remotes without a URL are not polled and only show the status they push.
+type Remote struct {
+	Name               string `json:"name"`                         // the cluster name used to label the remote's status
+	URL                string `json:"url,omitempty"`                // the URL of the remote's status page
+	SigningKey         string `json:"signingKey,omitempty"`         // the key that status pushed by the remote is signed with
+	SigningKeyFile     string `json:"signingKeyFile,omitempty"`     // a file holding the signing key, read on each push
+	BearerToken        string `json:"bearerToken,omitempty"`        // a bearer token sent with each request
+	BearerTokenFile    string `json:"bearerTokenFile,omitempty"`    // a file holding the bearer token, read on each request
+	Username           string `json:"username,omitempty"`           // a username for basic authentication
+	Password           string `json:"password,omitempty"`           // a password for basic authentication
+	CAFile             string `json:"caFile,omitempty"`             // a PEM encoded CA bundle used to verify the remote
+	InsecureSkipVerify bool   `json:"insecureSkipVerify,omitempty"` // skips verification of the remote's certificate
+	Proxy              string `json:"proxy,omitempty"`              // the proxy the remote is polled through, or direct.  Defaults to the proxy environment variables.
+	NoProxy            string `json:"noProxy,omitempty"`            // comma separated hosts reached without the proxy, added to NO_PROXY
+}
+
+// ClusterStatus is the most recently polled or pushed status of a single cluster
+type ClusterStatus struct {
+	health.State
+	Reachable bool      // false when the most recent poll of the cluster failed or its status is stale
+	LastPoll  time.Time // the time status was last polled from or pushed by the cluster
+	PollError string    `json:",omitempty"` // why the cluster is not reachable
+}
+
+// Status is the combined status of every cluster in the federation.  This is displayed on the federation
+// status page as JSON.
+type Status struct {
+	OK       bool
+	Errors   []string
+	Clusters map[string]ClusterStatus
+}
+
+// Federator polls the status pages of remote Kuberhealthy instances
+type Federator struct {
+	LocalName  string        // the cluster name of this Kuberhealthy instance
+	StaleAfter time.Duration // clusters without new status for this long are shown as unreachable.  Zero disables.
+
+	remotes  []Remote
+	clients  map[string]*http.Client
+	mu       sync.RWMutex
+	statuses map[string]ClusterStatus
+}
+
+// LoadConfig reads a federation config file
+func LoadConfig(path string) (Config, error) {
+	var config Config
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return config, fmt.Errorf("error reading federation config: %w", err)
+	}
+	err = yaml.Unmarshal(b, &config)
+	if err != nil {
+		return config, fmt.Errorf("error parsing federation config: %w", err)
+	}
+	return config, nil
+}
+
+// New creates a federator for the supplied remote clusters
+func New(localName string, remotes []Remote) (*Federator, error) {
+	f := &Federator{
+		LocalName: localName,
+		remotes:   remotes,
+		clients:   make(map[string]*http.Client),
+		statuses:  make(map[string]ClusterStatus),
+	}
+
+	for _, r := range remotes {
+		if len(r.Name) == 0 {
+			return nil, errors.New("federated clusters require a name")
+		}
+		if len(r.URL) == 0 && len(r.SigningKey) == 0 && len(r.SigningKeyFile) == 0 {
+			return nil, fmt.Errorf("federated cluster %s requires a url to poll or a signing key to accept pushes", r.Name)
+		}
+		if r.Name == localName {
+			return nil, fmt.Errorf("federated cluster %s has the same name as the local cluster", r.Name)
+		}
+		if _, exists := f.clients[r.Name]; exists {
+			return nil, fmt.Errorf("federated cluster %s is configured more than once", r.Name)
+		}
+		client, err := newClient(r.CAFile, r.InsecureSkipVerify, r.Proxy, r.NoProxy)
+		if err != nil {
+			return nil, fmt.Errorf("error configuring federated cluster %s: %w", r.Name, err)
+		}
+		f.clients[r.Name] = client
+		f.statuses[r.Name] = ClusterStatus{
+			State:     health.NewState(),
+			PollError: "no status received yet",
+		}
+	}
+	return f, nil
+}
+
+// newClient creates an HTTP client that sends requests through the supplied proxy and trusts the supplied CA
+// bundle, if one is configured
+func newClient(caFile string, insecureSkipVerify bool, proxyURL string, noProxy string) (*http.Client, error) {
+	transport, err := proxy.Transport(proxyURL, noProxy)
+	if err != nil {
+		return nil, err
+	}
+	if len(caFile) > 0 || insecureSkipVerify {
+		tlsConfig := &tls.Config{InsecureSkipVerify: insecureSkipVerify}
+		if len(caFile) > 0 {
+			caPEM, err := ioutil.ReadFile(caFile)
+			if err != nil {
+				return nil, fmt.Errorf("error reading CA bundle: %w", err)
+			}
+			tlsConfig.RootCAs = x509.NewCertPool()
+			if !tlsConfig.RootCAs.AppendCertsFromPEM(caPEM) {
+				return nil, fmt.Errorf("no certificates found in CA bundle %s", caFile)
+			}
+		}
+		transport.TLSClientConfig = tlsConfig
+	}
+	return &http.Client{Transport: transport}, nil
+}
+
+// setAuth adds bearer token or basic authentication to a request.  A token file takes precedence over
+// a token and is read on every request so that rotated tokens are picked up.
+func setAuth(req *http.Request, token string, tokenFile string, username string, password string) error {
+	token, err := readSecret(token, tokenFile)
+	if err != nil {
+		return fmt.Errorf("error reading bearer token: %w", err)
+	}
+	if len(token) > 0 {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	if len(username) > 0 {
+		req.SetBasicAuth(username, password)
+	}
+	return nil
+}
+
+// readSecret returns the trimmed contents of a file if one is supplied, or the value otherwise
+func readSecret(value string, file string) (string, error) {
+	if len(file) == 0 {
+		return value, nil
+	}
+	b, err := ioutil.ReadFile(file)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(b)), nil
+}
+
+// Run polls every remote cluster on the supplied interval until the context is canceled
+func (f *Federator) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		pollCtx, cancel := context.WithTimeout(ctx, interval)
+		f.Poll(pollCtx)
+		cancel()
+
+		select {
+		case <-ctx.Done():
+			log.Infoln("federation: shutting down from context abort")
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Poll fetches the status of every remote cluster once.  Clusters are polled concurrently.
+func (f *Federator) Poll(ctx context.Context) {
+	var wg sync.WaitGroup
+	for _, r := range f.remotes {
+		if len(r.URL) == 0 {
+			continue // push only
+		}
+		wg.Add(1)
+		go func(r Remote) {
+			defer wg.Done()
+			state, err := f.fetch(ctx, r)
+
+			f.mu.Lock()
+			defer f.mu.Unlock()
+			status := f.statuses[r.Name]
+			if err != nil {
+				log.Warningln("federation: failed to poll cluster", r.Name+":", err)
+				status.Reachable = false
+				status.PollError = err.Error()
+				f.statuses[r.Name] = status
+				return
+			}
+			f.statuses[r.Name] = ClusterStatus{
+				State:     state,
+				Reachable: true,
+				LastPoll:  time.Now(),
+			}
+		}(r)
+	}
+	wg.Wait()
+}
+
+// fetch retrieves the status page of a remote cluster
+func (f *Federator) fetch(ctx context.Context, r Remote) (health.State, error) {
+	var state health.State
+
+	req, err := http.NewRequest(http.MethodGet, r.URL, nil)
+	if err != nil {
+		return state, err
+	}
+	req = req.WithContext(ctx)
+	err = setAuth(req, r.BearerToken, r.BearerTokenFile, r.Username, r.Password)
+	if err != nil {
+		return state, err
+	}
+
+	resp, err := f.clients[r.Name].Do(req)
+	if err != nil {
+		return state, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return state, fmt.Errorf("unexpected status code %d from %s", resp.StatusCode, r.URL)
+	}
+
+	err = json.NewDecoder(resp.Body).Decode(&state)
+	if err != nil {
+		return state, fmt.Errorf("error decoding status from %s: %w", r.URL, err)
+	}
+	return state, nil
+}
+
+// Status returns the combined status of the local cluster and every remote cluster.  Errors are prefixed
+// with the name of the cluster they came from.  A cluster that can not be reached is not OK.
+func (f *Federator) Status(local health.State) Status {
+	status := Status{
+		OK:       true,
+		Errors:   []string{},
+		Clusters: make(map[string]ClusterStatus),
+	}
+
+	f.mu.RLock()
+	for name, s := range f.statuses {
+		if s.Reachable && f.StaleAfter > 0 && time.Since(s.LastPoll) > f.StaleAfter {
+			s.Reachable = false
+			s.PollError = "no status received since " + s.LastPoll.Format(time.RFC3339)
+		}
+		status.Clusters[name] = s
+	}
+	f.mu.RUnlock()
+	status.Clusters[f.LocalName] = ClusterStatus{
+		State:     local,
+		Reachable: true,
+		LastPoll:  time.Now(),
+	}
+
+	// build the combined errors in a stable order
+	names := make([]string, 0, len(status.Clusters))
+	for name := range status.Clusters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		s := status.Clusters[name]
+		if !s.Reachable {
+			status.OK = false
+			status.Errors = append(status.Errors, name+": "+s.PollError)
+			continue
+		}
+		if !s.OK {
+			status.OK = false
+		}
+		for _, e := range s.Errors {
+			status.Errors = append(status.Errors, name+": "+e)
+		}
+	}
+	return status
+}
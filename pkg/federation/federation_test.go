@@ -0,0 +1,117 @@
+package federation
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Comcast/kuberhealthy/v2/pkg/health"
+)
+
+// newRemoteServer serves a status page that requires the supplied bearer token
+func newRemoteServer(token string, state health.State) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer "+token {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		json.NewEncoder(w).Encode(state)
+	}))
+}
+
+// TestFederatorStatus validates that remote statuses are polled and combined with the local status
+func TestFederatorStatus(t *testing.T) {
+	eastState := health.NewState()
+	eastState.OK = false
+	eastState.Errors = []string{"deployment check failed"}
+	eastState.CheckDetails["kuberhealthy/deployment"] = health.CheckDetails{OK: false, Namespace: "kuberhealthy"}
+	east := newRemoteServer("east-token", eastState)
+	defer east.Close()
+	west := newRemoteServer("west-token", health.NewState())
+	defer west.Close()
+
+	f, err := New("local", []Remote{
+		{Name: "east", URL: east.URL, BearerToken: "east-token"},
+		{Name: "west", URL: west.URL, BearerToken: "wrong-token"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Poll(context.Background())
+
+	status := f.Status(health.NewState())
+	if status.OK {
+		t.Fatal("Expected the combined status to not be OK")
+	}
+	if len(status.Clusters) != 3 {
+		t.Fatal("Expected three clusters in the combined status but got", len(status.Clusters))
+	}
+	if !status.Clusters["local"].OK || !status.Clusters["local"].Reachable {
+		t.Fatal("Expected the local cluster to be OK and reachable")
+	}
+	if !status.Clusters["east"].Reachable || len(status.Clusters["east"].CheckDetails) != 1 {
+		t.Fatal("Expected the east cluster to be polled but got", status.Clusters["east"])
+	}
+	if status.Clusters["west"].Reachable {
+		t.Fatal("Expected the west cluster to be unreachable with the wrong token")
+	}
+	if len(status.Errors) != 2 || status.Errors[0] != "east: deployment check failed" {
+		t.Fatal("Unexpected combined errors:", status.Errors)
+	}
+}
+
+// TestNewValidation validates that misconfigured clusters are rejected
+func TestNewValidation(t *testing.T) {
+	_, err := New("local", []Remote{{Name: "local", URL: "http://example.com"}})
+	if err == nil {
+		t.Fatal("Expected an error for a remote named after the local cluster")
+	}
+	_, err = New("local", []Remote{{Name: "east", URL: "http://example.com"}, {Name: "east", URL: "http://example.org"}})
+	if err == nil {
+		t.Fatal("Expected an error for a duplicate cluster")
+	}
+	_, err = New("local", []Remote{{Name: "east"}})
+	if err == nil {
+		t.Fatal("Expected an error for a cluster without a url")
+	}
+	_, err = New("local", []Remote{{Name: "east", URL: "http://example.com", Proxy: "proxy.example.com:3128"}})
+	if err == nil {
+		t.Fatal("Expected an error for a cluster with an invalid proxy")
+	}
+	_, err = NewPusher("local", Upstream{URL: "http://example.com", Proxy: "ftp://proxy.example.com"})
+	if err == nil {
+		t.Fatal("Expected an error for an upstream collector with an invalid proxy")
+	}
+}
+
+// TestLoadConfig validates that federation config files are parsed
+func TestLoadConfig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "federation")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "clusters.yaml")
+	err = ioutil.WriteFile(path, []byte(`
+clusters:
+  - name: east
+    url: https://kuberhealthy.east.example.com
+    bearerTokenFile: /etc/federation/east-token
+`), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	config, err := LoadConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(config.Clusters) != 1 || config.Clusters[0].Name != "east" || config.Clusters[0].BearerTokenFile != "/etc/federation/east-token" {
+		t.Fatal("Unexpected federation config:", config)
+	}
+}
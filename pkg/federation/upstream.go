@@ -0,0 +1,222 @@
+package federation
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/Comcast/kuberhealthy/v2/pkg/health"
+)
+
+// the headers that identify and sign a status pushed to an upstream collector.  The signature is the hex
+// encoded HMAC-SHA256 of the timestamp, a period, and the request body.
+const ClusterHeader = "X-Kuberhealthy-Cluster"
+const TimestampHeader = "X-Kuberhealthy-Timestamp"
+const SignatureHeader = "X-Kuberhealthy-Signature"
+
+// MaxPushAge is how old the timestamp of a pushed status can be before it is rejected as a replay
+var MaxPushAge = time.Minute * 5
+
+// DefaultPushInterval is how often status is pushed upstream when the upstream config does not set an interval
+var DefaultPushInterval = time.Second * 30
+
+// Upstream is a central collector that the status of this cluster is pushed to.  This is useful when the
+// collector can not reach the cluster to poll it.
+type Upstream struct {
+	URL                string `json:"url"`                          // the URL status is posted to
+	Interval           string `json:"interval,omitempty"`           // how often status is pushed, such as 30s
+	SigningKey         string `json:"signingKey,omitempty"`         // the key pushed status is signed with
+	SigningKeyFile     string `json:"signingKeyFile,omitempty"`     // a file holding the signing key, read on each push
+	BearerToken        string `json:"bearerToken,omitempty"`        // a bearer token sent with each push
+	BearerTokenFile    string `json:"bearerTokenFile,omitempty"`    // a file holding the bearer token, read on each push
+	CAFile             string `json:"caFile,omitempty"`             // a PEM encoded CA bundle used to verify the collector
+	InsecureSkipVerify bool   `json:"insecureSkipVerify,omitempty"` // skips verification of the collector's certificate
+	Proxy              string `json:"proxy,omitempty"`              // the proxy status is pushed through, or direct.  Defaults to the proxy environment variables.
+	NoProxy            string `json:"noProxy,omitempty"`            // comma separated hosts reached without the proxy, added to NO_PROXY
+}
+
+// Report is the body of a status pushed to an upstream collector
+type Report struct {
+	Cluster string       // the name of the cluster the status came from
+	Sent    time.Time    // when the status was pushed
+	State   health.State // the full status page of the cluster
+}
+
+// Pusher pushes the status of this cluster to an upstream collector
+type Pusher struct {
+	Cluster  string        // the name this cluster is identified by upstream
+	Interval time.Duration // how often status is pushed
+
+	upstream Upstream
+	client   *http.Client
+}
+
+// NewPusher creates a pusher for the supplied upstream collector
+func NewPusher(cluster string, u Upstream) (*Pusher, error) {
+	if len(u.URL) == 0 {
+		return nil, errors.New("upstream collectors require a url")
+	}
+	if len(cluster) == 0 {
+		return nil, errors.New("pushing status upstream requires a cluster name")
+	}
+	p := &Pusher{
+		Cluster:  cluster,
+		Interval: DefaultPushInterval,
+		upstream: u,
+	}
+	if len(u.Interval) > 0 {
+		var err error
+		p.Interval, err = time.ParseDuration(u.Interval)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing upstream push interval: %w", err)
+		}
+	}
+	client, err := newClient(u.CAFile, u.InsecureSkipVerify, u.Proxy, u.NoProxy)
+	if err != nil {
+		return nil, fmt.Errorf("error configuring upstream collector: %w", err)
+	}
+	client.Timeout = p.Interval
+	p.client = client
+	return p, nil
+}
+
+// Run pushes the status returned by currentState on the pusher's interval until the context is canceled
+func (p *Pusher) Run(ctx context.Context, currentState func() health.State) {
+	ticker := time.NewTicker(p.Interval)
+	defer ticker.Stop()
+	for {
+		err := p.Push(ctx, currentState())
+		if err != nil {
+			log.Warningln("federation: failed to push status upstream:", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			log.Infoln("federation: shutting down upstream pushes from context abort")
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Push posts the supplied status to the upstream collector once
+func (p *Pusher) Push(ctx context.Context, state health.State) error {
+	body, err := json.Marshal(Report{
+		Cluster: p.Cluster,
+		Sent:    time.Now(),
+		State:   state,
+	})
+	if err != nil {
+		return fmt.Errorf("error marshaling status: %w", err)
+	}
+
+	req, err := http.NewRequest(http.MethodPost, p.upstream.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(ClusterHeader, p.Cluster)
+	err = setAuth(req, p.upstream.BearerToken, p.upstream.BearerTokenFile, "", "")
+	if err != nil {
+		return err
+	}
+
+	key, err := readSecret(p.upstream.SigningKey, p.upstream.SigningKeyFile)
+	if err != nil {
+		return fmt.Errorf("error reading signing key: %w", err)
+	}
+	if len(key) > 0 {
+		timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+		req.Header.Set(TimestampHeader, timestamp)
+		req.Header.Set(SignatureHeader, Sign([]byte(key), timestamp, body))
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("unexpected status code %d from %s", resp.StatusCode, p.upstream.URL)
+	}
+	return nil
+}
+
+// Sign returns the signature of a pushed status body sent at the supplied unix timestamp
+func Sign(key []byte, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify checks the signature of a pushed status body and rejects timestamps older than MaxPushAge
+func Verify(key []byte, timestamp string, body []byte, signature string, now time.Time) error {
+	sent, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid timestamp %q", timestamp)
+	}
+	age := now.Sub(time.Unix(sent, 0))
+	if age > MaxPushAge || age < -MaxPushAge {
+		return fmt.Errorf("timestamp is %s away from the current time", age)
+	}
+	if !hmac.Equal([]byte(Sign(key, timestamp, body)), []byte(signature)) {
+		return errors.New("signature does not match")
+	}
+	return nil
+}
+
+// ErrUnknownCluster is returned when status is pushed by a cluster that is not configured to push
+var ErrUnknownCluster = errors.New("cluster is not configured to push status")
+
+// Receive stores a status pushed by a remote cluster after verifying its signature.  Only clusters with a
+// signing key can push status.
+func (f *Federator) Receive(cluster string, timestamp string, signature string, body []byte) error {
+	var remote *Remote
+	for i := range f.remotes {
+		if f.remotes[i].Name == cluster {
+			remote = &f.remotes[i]
+		}
+	}
+	if remote == nil || (len(remote.SigningKey) == 0 && len(remote.SigningKeyFile) == 0) {
+		return ErrUnknownCluster
+	}
+
+	key, err := readSecret(remote.SigningKey, remote.SigningKeyFile)
+	if err != nil {
+		return fmt.Errorf("error reading signing key for cluster %s: %w", cluster, err)
+	}
+	err = Verify([]byte(key), timestamp, body, signature, time.Now())
+	if err != nil {
+		return fmt.Errorf("rejected status pushed by cluster %s: %w", cluster, err)
+	}
+
+	var report Report
+	err = json.Unmarshal(body, &report)
+	if err != nil {
+		return fmt.Errorf("error decoding status pushed by cluster %s: %w", cluster, err)
+	}
+	if report.Cluster != cluster {
+		return fmt.Errorf("status pushed by cluster %s is for cluster %s", cluster, report.Cluster)
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.statuses[cluster] = ClusterStatus{
+		State:     report.State,
+		Reachable: true,
+		LastPoll:  time.Now(),
+	}
+	return nil
+}
@@ -0,0 +1,97 @@
+package federation
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/Comcast/kuberhealthy/v2/pkg/health"
+)
+
+// TestPushReceive validates that status pushed upstream is verified and shown by the collector
+func TestPushReceive(t *testing.T) {
+	collector, err := New("central", []Remote{{Name: "edge", SigningKey: "secret"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		err := collector.Receive(r.Header.Get(ClusterHeader), r.Header.Get(TimestampHeader), r.Header.Get(SignatureHeader), body)
+		if err != nil {
+			t.Log(err)
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+	}))
+	defer server.Close()
+
+	state := health.NewState()
+	state.CheckDetails["kuberhealthy/deployment"] = health.CheckDetails{OK: true, Namespace: "kuberhealthy"}
+
+	// a pusher with the wrong key is rejected
+	wrongKey, err := NewPusher("edge", Upstream{URL: server.URL, SigningKey: "wrong"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if wrongKey.Push(context.Background(), state) == nil {
+		t.Fatal("Expected a push signed with the wrong key to be rejected")
+	}
+	if collector.Status(health.NewState()).Clusters["edge"].Reachable {
+		t.Fatal("Expected the edge cluster to be unreachable before it pushed status")
+	}
+
+	p, err := NewPusher("edge", Upstream{URL: server.URL, SigningKey: "secret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = p.Push(context.Background(), state)
+	if err != nil {
+		t.Fatal(err)
+	}
+	edge := collector.Status(health.NewState()).Clusters["edge"]
+	if !edge.Reachable || len(edge.CheckDetails) != 1 {
+		t.Fatal("Expected the pushed status of the edge cluster to be shown but got", edge)
+	}
+
+	// pushed status is shown as unreachable once it goes stale
+	collector.StaleAfter = time.Nanosecond
+	time.Sleep(time.Millisecond)
+	if collector.Status(health.NewState()).Clusters["edge"].Reachable {
+		t.Fatal("Expected stale status to be shown as unreachable")
+	}
+}
+
+// TestReceiveUnknownCluster validates that clusters without a signing key can not push status
+func TestReceiveUnknownCluster(t *testing.T) {
+	f, err := New("central", []Remote{{Name: "polled", URL: "http://example.com"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	for _, cluster := range []string{"polled", "unknown"} {
+		err = f.Receive(cluster, timestamp, Sign([]byte(""), timestamp, []byte("{}")), []byte("{}"))
+		if err != ErrUnknownCluster {
+			t.Fatal("Expected an unknown cluster error for", cluster, "but got", err)
+		}
+	}
+}
+
+// TestVerify validates that old timestamps and altered bodies are rejected
+func TestVerify(t *testing.T) {
+	key := []byte("secret")
+	now := time.Now()
+	timestamp := strconv.FormatInt(now.Unix(), 10)
+	signature := Sign(key, timestamp, []byte("body"))
+	if err := Verify(key, timestamp, []byte("body"), signature, now); err != nil {
+		t.Fatal("Expected a valid signature but got", err)
+	}
+	if Verify(key, timestamp, []byte("altered"), signature, now) == nil {
+		t.Fatal("Expected an altered body to be rejected")
+	}
+	if Verify(key, timestamp, []byte("body"), signature, now.Add(MaxPushAge*2)) == nil {
+		t.Fatal("Expected an old timestamp to be rejected")
+	}
+}
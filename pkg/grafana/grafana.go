@@ -0,0 +1,247 @@
+// Package grafana implements the Grafana JSON datasource protocol over the history of check runs, so that
+// the durations and failures of checks can be charted in Grafana without an intermediate database.
+package grafana
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Comcast/kuberhealthy/v2/pkg/runhistory"
+)
+
+// the metrics served for each check.  Targets are named <namespace>/<check>.<metric>.
+const (
+	MetricDuration = "duration_seconds" // how long each finished run took
+	MetricFailed   = "failed"           // 1 for each failed run and 0 for each successful run
+)
+
+// TableType is the target type Grafana requests when a panel shows its data as a table
+const TableType = "table"
+
+// Range is the time range of a query
+type Range struct {
+	From time.Time `json:"from"`
+	To   time.Time `json:"to"`
+}
+
+// Target is a single series requested by a query
+type Target struct {
+	Target string `json:"target"`
+	RefID  string `json:"refId"`
+	Type   string `json:"type"` // timeserie or table
+}
+
+// QueryRequest is the body of a query sent by Grafana
+type QueryRequest struct {
+	Range         Range    `json:"range"`
+	Targets       []Target `json:"targets"`
+	MaxDataPoints int      `json:"maxDataPoints"`
+}
+
+// SearchRequest is the body of a search sent by Grafana when it lists the available targets
+type SearchRequest struct {
+	Target string `json:"target"`
+}
+
+// TimeSeries is a series of [value, unix milliseconds] data points
+type TimeSeries struct {
+	Target     string       `json:"target"`
+	Datapoints [][2]float64 `json:"datapoints"`
+}
+
+// Column is a column of a Table
+type Column struct {
+	Text string `json:"text"`
+	Type string `json:"type"`
+}
+
+// Table is the response to a target of the table type
+type Table struct {
+	Type    string          `json:"type"`
+	Columns []Column        `json:"columns"`
+	Rows    [][]interface{} `json:"rows"`
+}
+
+// AnnotationQuery is the annotation configured in Grafana that annotations are requested for.  The query
+// limits annotations to the checks whose <namespace>/<check> starts with it.
+type AnnotationQuery struct {
+	Name       string `json:"name"`
+	Datasource string `json:"datasource"`
+	Enable     bool   `json:"enable"`
+	IconColor  string `json:"iconColor"`
+	Query      string `json:"query"`
+}
+
+// AnnotationRequest is the body of an annotation request sent by Grafana
+type AnnotationRequest struct {
+	Range      Range           `json:"range"`
+	Annotation AnnotationQuery `json:"annotation"`
+}
+
+// Annotation marks a failed run on a Grafana panel
+type Annotation struct {
+	Annotation AnnotationQuery `json:"annotation"`
+	Time       int64           `json:"time"` // unix milliseconds
+	Title      string          `json:"title"`
+	Text       string          `json:"text"`
+	Tags       []string        `json:"tags"`
+}
+
+// checkName returns the <namespace>/<check> name of a run
+func checkName(r runhistory.Run) string {
+	return r.Namespace + "/" + r.Name
+}
+
+// failed returns true when a run did not succeed.  Skipped runs are neither failed nor successful.
+func failed(r runhistory.Run) bool {
+	return r.Phase == runhistory.PhaseFailed || r.Phase == runhistory.PhaseTimedOut
+}
+
+// counted returns true when a run finished with a result that is charted
+func counted(r runhistory.Run) bool {
+	return r.Finished != nil && r.Phase.Done() && r.Phase != runhistory.PhaseSkipped
+}
+
+// Search returns the sorted targets of every check with runs in the history that contain the supplied text
+func Search(runs []runhistory.Run, text string) []string {
+	seen := make(map[string]bool)
+	targets := []string{}
+	for _, r := range runs {
+		name := checkName(r)
+		if seen[name] {
+			continue
+		}
+		seen[name] = true
+		for _, metric := range []string{MetricDuration, MetricFailed} {
+			target := name + "." + metric
+			if strings.Contains(target, text) {
+				targets = append(targets, target)
+			}
+		}
+	}
+	sort.Strings(targets)
+	return targets
+}
+
+// Query returns a time series or table for each target of the request from the runs that finished within the
+// range of the request
+func Query(runs []runhistory.Run, req QueryRequest) []interface{} {
+	var inRange []runhistory.Run
+	for _, r := range runs {
+		if !counted(r) || r.Finished.Before(req.Range.From) || r.Finished.After(req.Range.To) {
+			continue
+		}
+		inRange = append(inRange, r)
+	}
+	sort.SliceStable(inRange, func(i, j int) bool { return inRange[i].Finished.Before(*inRange[j].Finished) })
+
+	results := make([]interface{}, 0, len(req.Targets))
+	for _, t := range req.Targets {
+		if t.Type == TableType {
+			results = append(results, table(inRange, t.Target))
+			continue
+		}
+		results = append(results, timeSeries(inRange, t.Target, req.MaxDataPoints))
+	}
+	return results
+}
+
+// timeSeries returns the data points of a target.  Only the most recent points are kept when there are more
+// than maxDataPoints.
+func timeSeries(runs []runhistory.Run, target string, maxDataPoints int) TimeSeries {
+	series := TimeSeries{Target: target, Datapoints: [][2]float64{}}
+	dot := strings.LastIndex(target, ".")
+	if dot < 0 {
+		return series
+	}
+	name, metric := target[:dot], target[dot+1:]
+
+	for _, r := range runs {
+		if checkName(r) != name {
+			continue
+		}
+		var value float64
+		switch metric {
+		case MetricDuration:
+			d, err := time.ParseDuration(r.Duration)
+			if err != nil {
+				continue
+			}
+			value = d.Seconds()
+		case MetricFailed:
+			if failed(r) {
+				value = 1
+			}
+		default:
+			return series
+		}
+		series.Datapoints = append(series.Datapoints, [2]float64{value, float64(r.Finished.UnixNano() / int64(time.Millisecond))})
+	}
+
+	if maxDataPoints > 0 && len(series.Datapoints) > maxDataPoints {
+		series.Datapoints = series.Datapoints[len(series.Datapoints)-maxDataPoints:]
+	}
+	return series
+}
+
+// table returns a row for each run of the checks whose <namespace>/<check> starts with the target.  Metric
+// suffixes on the target are ignored so that the same target works as a table or a time series.
+func table(runs []runhistory.Run, target string) Table {
+	for _, metric := range []string{MetricDuration, MetricFailed} {
+		target = strings.TrimSuffix(target, "."+metric)
+	}
+	t := Table{
+		Type: TableType,
+		Columns: []Column{
+			{Text: "Time", Type: "time"},
+			{Text: "Check", Type: "string"},
+			{Text: "Run", Type: "string"},
+			{Text: "Phase", Type: "string"},
+			{Text: "Duration", Type: "number"},
+			{Text: "Errors", Type: "string"},
+		},
+		Rows: [][]interface{}{},
+	}
+	for _, r := range runs {
+		if !strings.HasPrefix(checkName(r), target) {
+			continue
+		}
+		var seconds float64
+		d, err := time.ParseDuration(r.Duration)
+		if err == nil {
+			seconds = d.Seconds()
+		}
+		t.Rows = append(t.Rows, []interface{}{
+			r.Finished.UnixNano() / int64(time.Millisecond),
+			checkName(r),
+			r.ID,
+			string(r.Phase),
+			seconds,
+			strings.Join(r.Errors, "; "),
+		})
+	}
+	return t
+}
+
+// Annotations returns an annotation for each failed run that finished within the range of the request
+func Annotations(runs []runhistory.Run, req AnnotationRequest) []Annotation {
+	annotations := []Annotation{}
+	for _, r := range runs {
+		if !counted(r) || !failed(r) || r.Finished.Before(req.Range.From) || r.Finished.After(req.Range.To) {
+			continue
+		}
+		if !strings.HasPrefix(checkName(r), req.Annotation.Query) {
+			continue
+		}
+		annotations = append(annotations, Annotation{
+			Annotation: req.Annotation,
+			Time:       r.Finished.UnixNano() / int64(time.Millisecond),
+			Title:      checkName(r) + " " + string(r.Phase),
+			Text:       strings.Join(r.Errors, "\n"),
+			Tags:       []string{r.Namespace, r.Name, string(r.Phase)},
+		})
+	}
+	sort.SliceStable(annotations, func(i, j int) bool { return annotations[i].Time < annotations[j].Time })
+	return annotations
+}
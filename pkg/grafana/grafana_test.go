@@ -0,0 +1,121 @@
+package grafana
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Comcast/kuberhealthy/v2/pkg/runhistory"
+)
+
+var now = time.Date(2020, 4, 2, 18, 0, 0, 0, time.UTC)
+
+// finishedRun returns a run of a check that finished the supplied time before now
+func finishedRun(id string, name string, phase runhistory.Phase, ago time.Duration, duration string) runhistory.Run {
+	finished := now.Add(-ago)
+	return runhistory.Run{
+		ID:        id,
+		Name:      name,
+		Namespace: "kuberhealthy",
+		Phase:     phase,
+		Errors:    []string{},
+		Finished:  &finished,
+		Duration:  duration,
+	}
+}
+
+// testRuns returns the run history used by the tests, from oldest to newest
+func testRuns() []runhistory.Run {
+	failedRun := finishedRun("3", "deployment", runhistory.PhaseFailed, time.Minute, "20s")
+	failedRun.Errors = []string{"deployment did not roll out"}
+	return []runhistory.Run{
+		finishedRun("1", "deployment", runhistory.PhaseSucceeded, time.Hour*2, "10s"),
+		finishedRun("2", "deployment", runhistory.PhaseSucceeded, time.Minute*2, "30s"),
+		finishedRun("4", "dns", runhistory.PhaseSkipped, time.Minute, ""),
+		failedRun,
+		{ID: "5", Name: "dns", Namespace: "kuberhealthy", Phase: runhistory.PhaseRunning},
+	}
+}
+
+// TestSearch validates that every check in the history is offered as a target
+func TestSearch(t *testing.T) {
+	targets := Search(testRuns(), "")
+	expected := []string{
+		"kuberhealthy/deployment.duration_seconds",
+		"kuberhealthy/deployment.failed",
+		"kuberhealthy/dns.duration_seconds",
+		"kuberhealthy/dns.failed",
+	}
+	if len(targets) != len(expected) {
+		t.Fatal("Expected targets", expected, "but got", targets)
+	}
+	for i := range expected {
+		if targets[i] != expected[i] {
+			t.Fatal("Expected targets", expected, "but got", targets)
+		}
+	}
+
+	targets = Search(testRuns(), "dns.f")
+	if len(targets) != 1 || targets[0] != "kuberhealthy/dns.failed" {
+		t.Fatal("Expected the search text to filter targets but got", targets)
+	}
+}
+
+// TestQuery validates that time series and tables only hold the finished runs within the requested range
+func TestQuery(t *testing.T) {
+	req := QueryRequest{
+		Range: Range{From: now.Add(-time.Hour), To: now},
+		Targets: []Target{
+			{Target: "kuberhealthy/deployment.duration_seconds", Type: "timeserie"},
+			{Target: "kuberhealthy/deployment.failed", Type: "timeserie"},
+			{Target: "kuberhealthy/deployment.failed", Type: TableType},
+		},
+	}
+	results := Query(testRuns(), req)
+	if len(results) != 3 {
+		t.Fatal("Expected a result for each target but got", results)
+	}
+
+	durations := results[0].(TimeSeries)
+	if len(durations.Datapoints) != 2 || durations.Datapoints[0][0] != 30 || durations.Datapoints[1][0] != 20 {
+		t.Fatal("Expected the durations of the runs within the range in order but got", durations.Datapoints)
+	}
+	if durations.Datapoints[1][1] != float64(now.Add(-time.Minute).Unix()*1000) {
+		t.Fatal("Expected data points to be timestamped in milliseconds but got", durations.Datapoints[1][1])
+	}
+	failures := results[1].(TimeSeries)
+	if len(failures.Datapoints) != 2 || failures.Datapoints[0][0] != 0 || failures.Datapoints[1][0] != 1 {
+		t.Fatal("Expected the failed run to be charted as 1 but got", failures.Datapoints)
+	}
+
+	runs := results[2].(Table)
+	if len(runs.Rows) != 2 || runs.Rows[1][2] != "3" || runs.Rows[1][5] != "deployment did not roll out" {
+		t.Fatal("Expected a row for each run within the range but got", runs.Rows)
+	}
+
+	// only the most recent points are kept when there are too many
+	req.MaxDataPoints = 1
+	durations = Query(testRuns(), req)[0].(TimeSeries)
+	if len(durations.Datapoints) != 1 || durations.Datapoints[0][0] != 20 {
+		t.Fatal("Expected only the most recent data point but got", durations.Datapoints)
+	}
+}
+
+// TestAnnotations validates that failed runs are annotated
+func TestAnnotations(t *testing.T) {
+	req := AnnotationRequest{
+		Range:      Range{From: now.Add(-time.Hour * 3), To: now},
+		Annotation: AnnotationQuery{Name: "failures", Query: "kuberhealthy/"},
+	}
+	annotations := Annotations(testRuns(), req)
+	if len(annotations) != 1 {
+		t.Fatal("Expected only the failed run to be annotated but got", annotations)
+	}
+	if annotations[0].Title != "kuberhealthy/deployment Failed" || annotations[0].Text != "deployment did not roll out" || annotations[0].Annotation.Name != "failures" {
+		t.Fatal("Unexpected annotation:", annotations[0])
+	}
+
+	req.Annotation.Query = "kuberhealthy/dns"
+	if annotations = Annotations(testRuns(), req); len(annotations) != 0 {
+		t.Fatal("Expected the query to filter annotations by check but got", annotations)
+	}
+}
@@ -0,0 +1,99 @@
+package health
+
+import "time"
+
+// AvailabilityWindows are the rolling windows that the availability of each check is computed over
+var AvailabilityWindows = []struct {
+	Name     string
+	Duration time.Duration
+}{
+	{"24h", time.Hour * 24},
+	{"7d", time.Hour * 24 * 7},
+	{"30d", time.Hour * 24 * 30},
+}
+
+// runHistoryRetention is how long run counts are kept, which is the longest availability window
+const runHistoryRetention = time.Hour * 24 * 30
+
+// runCountsDetailedFor is how long run counts are kept in hourly buckets before they are merged into daily
+// buckets.  This keeps the history of a check small enough to be stored with its state.
+const runCountsDetailedFor = time.Hour * 24
+
+// RunCounts is the number of successful and failed runs of a check that started within a bucket of time
+type RunCounts struct {
+	Start     time.Time // the start of the bucket
+	Succeeded int
+	Failed    int
+}
+
+// Availability is the share of successful runs of a check over a rolling window, and how much of the
+// check's error budget is left in that window
+type Availability struct {
+	Window               string  // the length of the window, such as 24h or 7d
+	Runs                 int     // the number of runs in the window
+	Failed               int     // the number of failed runs in the window
+	Percent              float64 // the percentage of runs in the window that succeeded
+	ErrorBudgetRemaining float64 // the percentage of allowed failures that have not been used up.  Negative when the objective is missed.
+}
+
+// RecordRun adds the result of a run to the history of the check.  Run counts older than the longest
+// availability window are dropped, and those older than a day are merged into daily buckets.
+func (d *CheckDetails) RecordRun(now time.Time, ok bool) {
+	hour := now.UTC().Truncate(time.Hour)
+	last := len(d.RunHistory) - 1
+	if last < 0 || !d.RunHistory[last].Start.Equal(hour) {
+		d.RunHistory = append(d.RunHistory, RunCounts{Start: hour})
+		last++
+	}
+	if ok {
+		d.RunHistory[last].Succeeded++
+	} else {
+		d.RunHistory[last].Failed++
+	}
+
+	history := make([]RunCounts, 0, len(d.RunHistory))
+	for _, c := range d.RunHistory {
+		if c.Start.Before(now.Add(-runHistoryRetention)) {
+			continue
+		}
+		if c.Start.Before(now.Add(-runCountsDetailedFor)) {
+			c.Start = c.Start.UTC().Truncate(time.Hour * 24)
+		}
+		if len(history) > 0 && history[len(history)-1].Start.Equal(c.Start) {
+			history[len(history)-1].Succeeded += c.Succeeded
+			history[len(history)-1].Failed += c.Failed
+			continue
+		}
+		history = append(history, c)
+	}
+	d.RunHistory = history
+}
+
+// ComputeAvailability fills in the availability of the check over each availability window from its run
+// history.  The error budget is measured against the SLO target of the check, which is a percentage of runs
+// that are expected to succeed.
+func (d *CheckDetails) ComputeAvailability(now time.Time) {
+	d.Availability = make([]Availability, 0, len(AvailabilityWindows))
+	for _, w := range AvailabilityWindows {
+		a := Availability{Window: w.Name, Percent: 100, ErrorBudgetRemaining: 100}
+		for _, c := range d.RunHistory {
+			if c.Start.Before(now.Add(-w.Duration)) {
+				continue
+			}
+			a.Runs += c.Succeeded + c.Failed
+			a.Failed += c.Failed
+		}
+		if a.Runs > 0 {
+			a.Percent = float64(a.Runs-a.Failed) / float64(a.Runs) * 100
+		}
+		if d.SLOTarget > 0 {
+			allowedFailures := float64(a.Runs) * (100 - d.SLOTarget) / 100
+			if allowedFailures > 0 {
+				a.ErrorBudgetRemaining = (1 - float64(a.Failed)/allowedFailures) * 100
+			} else if a.Failed > 0 {
+				a.ErrorBudgetRemaining = 0
+			}
+		}
+		d.Availability = append(d.Availability, a)
+	}
+}
@@ -0,0 +1,71 @@
+package health
+
+import (
+	"testing"
+	"time"
+)
+
+// TestRecordRun validates that runs are counted in hourly buckets that are merged into daily buckets once
+// they are a day old and dropped after the longest availability window
+func TestRecordRun(t *testing.T) {
+	d := NewCheckDetails()
+	start := time.Date(2020, 4, 1, 0, 30, 0, 0, time.UTC)
+	d.RecordRun(start, true)
+	d.RecordRun(start.Add(time.Minute), false)
+	d.RecordRun(start.Add(time.Hour), true)
+	if len(d.RunHistory) != 2 {
+		t.Fatal("Expected runs in two different hours to be counted in two buckets but got", d.RunHistory)
+	}
+	if d.RunHistory[0].Succeeded != 1 || d.RunHistory[0].Failed != 1 {
+		t.Fatal("Expected the first hour to count one success and one failure but got", d.RunHistory[0])
+	}
+
+	// two days later the first day is merged into a single bucket
+	d.RecordRun(start.Add(time.Hour*48), true)
+	if len(d.RunHistory) != 2 {
+		t.Fatal("Expected the runs of the first day to be merged but got", d.RunHistory)
+	}
+	if !d.RunHistory[0].Start.Equal(time.Date(2020, 4, 1, 0, 0, 0, 0, time.UTC)) || d.RunHistory[0].Succeeded != 2 || d.RunHistory[0].Failed != 1 {
+		t.Fatal("Expected a daily bucket with every run of the first day but got", d.RunHistory[0])
+	}
+
+	// runs older than the longest window are dropped
+	d.RecordRun(start.Add(time.Hour*24*40), true)
+	if len(d.RunHistory) != 1 {
+		t.Fatal("Expected runs older than thirty days to be dropped but got", d.RunHistory)
+	}
+}
+
+// TestComputeAvailability validates the availability and error budget of each window
+func TestComputeAvailability(t *testing.T) {
+	now := time.Date(2020, 4, 30, 12, 0, 0, 0, time.UTC)
+	d := NewCheckDetails()
+	d.SLOTarget = 90
+	d.RunHistory = []RunCounts{
+		{Start: now.Add(-time.Hour * 24 * 20), Succeeded: 10, Failed: 10}, // only in the 30d window
+		{Start: now.Add(-time.Hour * 24 * 3), Succeeded: 10, Failed: 0},   // in the 7d and 30d windows
+		{Start: now.Add(-time.Hour), Succeeded: 19, Failed: 1},            // in every window
+	}
+	d.ComputeAvailability(now)
+	if len(d.Availability) != len(AvailabilityWindows) {
+		t.Fatal("Expected availability for every window but got", d.Availability)
+	}
+
+	day, week, month := d.Availability[0], d.Availability[1], d.Availability[2]
+	if day.Window != "24h" || day.Runs != 20 || day.Percent != 95 || day.ErrorBudgetRemaining != 50 {
+		t.Fatal("Unexpected availability over the last day:", day)
+	}
+	if week.Runs != 30 || week.Failed != 1 {
+		t.Fatal("Unexpected availability over the last week:", week)
+	}
+	if month.Runs != 50 || month.Percent != 78 || month.ErrorBudgetRemaining >= 0 {
+		t.Fatal("Expected the error budget of the last month to be overspent but got", month)
+	}
+
+	// checks without runs are fully available
+	d = NewCheckDetails()
+	d.ComputeAvailability(now)
+	if d.Availability[0].Percent != 100 || d.Availability[0].ErrorBudgetRemaining != 100 {
+		t.Fatal("Expected a check without runs to be fully available but got", d.Availability[0])
+	}
+}
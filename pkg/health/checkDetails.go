@@ -11,22 +11,234 @@
 
 package health
 
-import "time"
+import (
+	"encoding/json"
+	"time"
+)
 
 // CheckDetails contains details about a single check's current status
 type CheckDetails struct {
-	OK               bool
-	Errors           []string
-	RunDuration		 string
+	OK               bool     // the debounced health of the check
+	Errors           []string // the errors reported with the debounced health
+	LastRunOK        bool     // the raw result of the most recent run
+	LastRunErrors    []string // the errors of the most recent run
+	RunDuration      string
 	Namespace        string
-	LastRun          time.Time // the time the check last was last run
-	AuthoritativePod string    // the pod that last ran the check
-	CurrentUUID      string    `json:"uuid"` // the UUID that is authorized to report statuses into the kuberhealthy endpoint
+	LastRun          time.Time         // the time the check last was last run
+	LastReport       time.Time         // the time a checker pod last reported a result
+	StaleAt          time.Time         // the time after which the check is shown as failed if it has not completed another run
+	Stale            bool              `json:",omitempty"` // true when the check has stopped completing runs and its results are out of date
+	UnknownAt        time.Time         `json:",omitempty"` // the time after which the last result has outlived the result TTL of the check
+	Unknown          bool              `json:",omitempty"` // true when no result has arrived within the result TTL of the check, so its health is unknown
+	AuthoritativePod string            // the pod that last ran the check
+	CurrentUUID      string            `json:"uuid"`       // the UUID that is authorized to report statuses into the kuberhealthy endpoint
+	RunningUUIDs     []string          `json:",omitempty"` // the UUIDs of the runs of a check whose runs can overlap that have not reported in yet, which are also authorized to report
+	RunPods          map[string]string `json:",omitempty"` // the namespace and name of the checker pod created for each run that is authorized to report, by run UUID
+	Progress         *Progress         `json:",omitempty"` // the latest progress update sent by the currently running checker pod
+	Assertions       []Assertion       `json:",omitempty"` // named sub-check results from the last report
+	Artifacts        []Artifact        `json:",omitempty"` // files uploaded by the checker pod of the current run as evidence
+	SLOTarget        float64           `json:",omitempty"` // the percentage of runs that are expected to succeed, used to compute error budgets
+	RunHistory       []RunCounts       `json:",omitempty"` // the number of successful and failed runs over the longest availability window
+	Availability     []Availability    `json:",omitempty"` // the availability of the check over each availability window, computed from its run history
+	Severity         string            `json:",omitempty"` // how important the check is, which weights it when rolling up the overall status
+	Labels           map[string]string `json:",omitempty"` // the labels of the khcheck resource, which status page views select checks by
+	Warnings         []string          `json:",omitempty"` // problems with the configuration of the check that do not keep it from running, such as a timeout longer than its run interval
+	Silence          *Silence          `json:",omitempty"` // set while the check is silenced, which keeps its errors out of the overall status
+	Architectures    ArchResults       `json:",omitempty"` // the result of the latest run on each node architecture, for checks that choose their architectures
+	Skipped          string            `json:",omitempty"` // why the latest run was skipped, such as the cluster being under resource pressure
+	Measurements     Measurements      `json:",omitempty"` // the numeric measurements, such as latencies or counts, reported with the last run
+	Baselines        Baselines         `json:",omitempty"` // the recent values of each measurement that regression rules compare new values to
+	ErrorHistory     []ErrorRecord     `json:",omitempty"` // the distinct errors of recent runs with how often and when they were seen
+	ErrorCategory    string            `json:",omitempty"` // what caused the errors of the last run: infrastructure, check, or configuration
+	Pod              *PodDetails       `json:",omitempty"` // where and how the checker pod of the most recent run that started one ran
+}
+
+// ArchResults holds the result of the latest run of a check on nodes of each CPU architecture
+type ArchResults map[string]ArchitectureResult
+
+// ArchitectureResult is the result of the latest run of a check on nodes of one CPU architecture
+type ArchitectureResult struct {
+	OK      bool      // the raw result of the run
+	Errors  []string  `json:",omitempty"` // the errors of the run
+	LastRun time.Time // when the run finished
+}
+
+// Silence acknowledges a failing check until it expires.  Silenced checks are still shown with their errors
+// but do not affect the overall status or send notifications.
+type Silence struct {
+	Name    string    // the name of the khsilence resource
+	Reason  string    // why the check was silenced
+	Author  string    // who silenced the check
+	Expires time.Time // when the silence stops applying
+}
+
+// Progress is an intermediate update sent by a checker pod that has not finished its run yet
+type Progress struct {
+	Percent int       // how far along the run is, from 0 to 100
+	Message string    // a human readable description of what the check is doing
+	Updated time.Time // when the progress update was received
+}
+
+// Assertion is the result of a single named sub-check within a check run
+type Assertion struct {
+	Name  string
+	OK    bool
+	Error string `json:",omitempty"` // why the assertion failed
+}
+
+// Artifact is a file uploaded by a checker pod, such as a JSON report, packet capture, or screenshot, that
+// was stored for review alongside the result of its run
+type Artifact struct {
+	Name      string    // the name of the file, which is also its key in the config map
+	Size      int       // the size of the file in bytes
+	RunID     string    // the UUID of the run that uploaded the file
+	ConfigMap string    // the namespace/name of the config map the file is stored in
+	Uploaded  time.Time // when the file was uploaded
+}
+
+// PodDetails describes where a checker pod was scheduled, which image it actually ran, and how long it took to
+// start and run, so that failures that only happen on some nodes or with some image versions can be told apart
+type PodDetails struct {
+	Name         string
+	Node         string `json:",omitempty"` // the node the pod was scheduled to
+	Image        string `json:",omitempty"` // the image of the main container as written in the pod spec
+	ImageDigest  string `json:",omitempty"` // the digest of the image the main container actually ran
+	StartLatency string `json:",omitempty"` // how long the main container took to start after the pod was created
+	RunDuration  string `json:",omitempty"` // how long the main container ran before it exited
+}
+
+// RecordArchitecture records the result of the last run as the result of the node architecture it ran on.
+// Results of architectures the check no longer runs on are dropped.
+func (d *CheckDetails) RecordArchitecture(arch string, architectures []string, now time.Time) {
+	results := make(ArchResults)
+	for _, a := range architectures {
+		if r, ok := d.Architectures[a]; ok {
+			results[a] = r
+		}
+	}
+	results[arch] = ArchitectureResult{OK: d.LastRunOK, Errors: d.LastRunErrors, LastRun: now}
+	d.Architectures = results
+}
+
+// MarkStale marks the check as failed when it has not completed a run by its StaleAt time.  This happens
+// when a checker pod never starts or the check stopped being run, and keeps the last result from being
+// shown as current.  Returns true if the check is stale.
+func (d *CheckDetails) MarkStale(now time.Time) bool {
+	if d.StaleAt.IsZero() || now.Before(d.StaleAt) {
+		return false
+	}
+	d.OK = false
+	d.Stale = true
+	errors := make([]string, 0, len(d.Errors)+1)
+	errors = append(errors, d.Errors...)
+	d.Errors = append(errors, "Check has not completed a run since "+d.LastRun.Format(time.RFC3339)+" and its results are stale")
+	return true
+}
+
+// MarkUnknown marks the health of the check as unknown when no result has arrived by its UnknownAt time.  Unlike a
+// stale check, an unknown check is not failed: it is neither OK nor has errors, and the result it last reported
+// is kept in LastRunOK and LastRunErrors.  Returns true if the health of the check is unknown.
+func (d *CheckDetails) MarkUnknown(now time.Time) bool {
+	if d.UnknownAt.IsZero() || now.Before(d.UnknownAt) {
+		return false
+	}
+	d.OK = false
+	d.Errors = []string{}
+	d.Unknown = true
+	return true
+}
+
+// StartRun makes the supplied run UUID the current run of the check.  When runs can overlap, the UUIDs of up to
+// maxOverlapping earlier runs that have not reported in yet stay authorized to report.
+func (d *CheckDetails) StartRun(uuid string, maxOverlapping int) {
+	d.CurrentUUID = uuid
+	if maxOverlapping <= 0 {
+		d.RunningUUIDs = nil
+		return
+	}
+	running := append(d.RunningUUIDs, uuid)
+	if len(running) > maxOverlapping+1 {
+		running = running[len(running)-maxOverlapping-1:]
+	}
+	d.RunningUUIDs = running
+}
+
+// FinishRun removes a run UUID from the runs that have not reported in yet.  The current run stays current.
+func (d *CheckDetails) FinishRun(uuid string) {
+	var running []string
+	for _, u := range d.RunningUUIDs {
+		if u != uuid {
+			running = append(running, u)
+		}
+	}
+	d.RunningUUIDs = running
+}
+
+// AuthorizesRun returns true if the supplied run UUID is allowed to report the status of the check, which is
+// the current run and any overlapping run that has not reported in yet
+func (d CheckDetails) AuthorizesRun(uuid string) bool {
+	if d.CurrentUUID == uuid {
+		return true
+	}
+	return d.Running(uuid)
+}
+
+// Running returns true if the supplied run UUID belongs to an overlapping run that has not reported in yet
+func (d CheckDetails) Running(uuid string) bool {
+	for _, u := range d.RunningUUIDs {
+		if u == uuid {
+			return true
+		}
+	}
+	return false
+}
+
+// RecordRunPod records the namespace/name of the checker pod created for a run, which is the only pod allowed
+// to report for it.  Pods recorded for runs that are no longer authorized to report are dropped.
+func (d *CheckDetails) RecordRunPod(uuid string, pod string) {
+	runPods := map[string]string{uuid: pod}
+	for u, p := range d.RunPods {
+		if u != uuid && d.AuthorizesRun(u) {
+			runPods[u] = p
+		}
+	}
+	d.RunPods = runPods
+}
+
+// ForgetRunPod removes the checker pod recorded for a run, such as when the pod could not be created
+func (d *CheckDetails) ForgetRunPod(uuid string) {
+	delete(d.RunPods, uuid)
+}
+
+// RunPod returns the namespace/name of the checker pod recorded for a run, or a blank string if the run is not
+// authorized to report or no pod was recorded for it
+func (d CheckDetails) RunPod(uuid string) string {
+	if !d.AuthorizesRun(uuid) {
+		return ""
+	}
+	return d.RunPods[uuid]
 }
 
 // NewCheckDetails creates a new CheckDetails struct
 func NewCheckDetails() CheckDetails {
 	return CheckDetails{
-		Errors: []string{},
+		Errors:        []string{},
+		LastRunErrors: []string{},
+	}
+}
+
+// DeepCopy returns a copy of the details that shares no slices, maps, or pointers with them, such as to keep the
+// state that was read from a state store while the details are changed.  The copy is made through the JSON form
+// the details are stored in.
+func (d CheckDetails) DeepCopy() CheckDetails {
+	var c CheckDetails
+	b, err := json.Marshal(d)
+	if err != nil {
+		return d
+	}
+	err = json.Unmarshal(b, &c)
+	if err != nil {
+		return d
 	}
+	return c
 }
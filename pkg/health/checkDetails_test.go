@@ -0,0 +1,136 @@
+package health
+
+import (
+	"testing"
+	"time"
+)
+
+// TestMarkStale validates that checks are only marked failed once their stale time has passed
+func TestMarkStale(t *testing.T) {
+	now := time.Now()
+
+	d := NewCheckDetails()
+	d.OK = true
+	if d.MarkStale(now) || !d.OK {
+		t.Fatal("Expected a check without a stale time to never be stale")
+	}
+
+	d.StaleAt = now.Add(time.Minute)
+	if d.MarkStale(now) || !d.OK {
+		t.Fatal("Expected a check to not be stale before its stale time")
+	}
+
+	errors := make([]string, 1, 2)
+	errors[0] = "previous error"
+	d.Errors = errors
+	if !d.MarkStale(now.Add(time.Minute * 2)) {
+		t.Fatal("Expected a check to be stale after its stale time")
+	}
+	if d.OK || !d.Stale || len(d.Errors) != 2 {
+		t.Fatal("Expected a stale check to be failed with a stale error but got", d)
+	}
+	if len(errors[:2][1]) != 0 {
+		t.Fatal("Expected the errors of a stale check to be copied rather than modified in place")
+	}
+}
+
+// TestMarkUnknown validates that the health of a check becomes unknown, rather than failed, once its result TTL
+// has passed
+func TestMarkUnknown(t *testing.T) {
+	now := time.Now()
+
+	d := NewCheckDetails()
+	d.LastRunOK = false
+	d.LastRunErrors = []string{"last error"}
+	d.Errors = d.LastRunErrors
+	if d.MarkUnknown(now) || d.Unknown {
+		t.Fatal("Expected a check without a result TTL to never be unknown")
+	}
+
+	d.UnknownAt = now.Add(time.Minute)
+	if d.MarkUnknown(now) || d.Unknown {
+		t.Fatal("Expected a check to not be unknown before its result expires")
+	}
+
+	if !d.MarkUnknown(now.Add(time.Minute * 2)) {
+		t.Fatal("Expected a check to be unknown after its result expires")
+	}
+	if d.OK || !d.Unknown || len(d.Errors) != 0 {
+		t.Fatal("Expected an unknown check to be neither OK nor failing but got", d)
+	}
+	if len(d.LastRunErrors) != 1 {
+		t.Fatal("Expected the last result of an unknown check to be kept but got", d.LastRunErrors)
+	}
+}
+
+// TestRecordArchitecture validates that the result of a run is recorded for its architecture and that results
+// of architectures the check no longer runs on are dropped
+func TestRecordArchitecture(t *testing.T) {
+	now := time.Now()
+	d := NewCheckDetails()
+	d.Architectures = map[string]ArchitectureResult{"amd64": {OK: true}, "s390x": {OK: true}}
+	d.LastRunOK = false
+	d.LastRunErrors = []string{"failed on arm64"}
+	d.RecordArchitecture("arm64", []string{"amd64", "arm64"}, now)
+
+	if len(d.Architectures) != 2 || !d.Architectures["amd64"].OK {
+		t.Fatal("Expected the amd64 result to be kept and the s390x result to be dropped but got", d.Architectures)
+	}
+	if r := d.Architectures["arm64"]; r.OK || len(r.Errors) != 1 || !r.LastRun.Equal(now) {
+		t.Fatal("Expected the failed run to be recorded for arm64 but got", r)
+	}
+}
+
+// TestOverlappingRuns validates that overlapping runs stay authorized to report until they report in or too many
+// newer runs have started
+func TestOverlappingRuns(t *testing.T) {
+	d := NewCheckDetails()
+	d.StartRun("1", 2)
+	d.StartRun("2", 2)
+	d.StartRun("3", 2)
+	if d.CurrentUUID != "3" || !d.AuthorizesRun("1") || !d.AuthorizesRun("2") {
+		t.Fatal("Expected the two earlier runs to stay authorized but got", d.CurrentUUID, d.RunningUUIDs)
+	}
+
+	d.StartRun("4", 2)
+	if d.AuthorizesRun("1") || !d.AuthorizesRun("2") {
+		t.Fatal("Expected only the two most recent earlier runs to stay authorized but got", d.RunningUUIDs)
+	}
+
+	d.FinishRun("2")
+	if d.AuthorizesRun("2") || d.CurrentUUID != "4" || !d.Running("3") {
+		t.Fatal("Expected a run that reported in to no longer be authorized but got", d.CurrentUUID, d.RunningUUIDs)
+	}
+
+	d.StartRun("5", 0)
+	if len(d.RunningUUIDs) != 0 || d.AuthorizesRun("4") {
+		t.Fatal("Expected only the current run to be authorized when runs can not overlap but got", d.RunningUUIDs)
+	}
+}
+
+// TestRecordRunPod validates that the checker pod recorded for a run is only returned while the run is authorized
+// to report, and that the pods of runs that are no longer authorized or were forgotten are dropped
+func TestRecordRunPod(t *testing.T) {
+	d := NewCheckDetails()
+	d.StartRun("1", 1)
+	d.RecordRunPod("1", "kuberhealthy/check-1")
+	d.StartRun("2", 1)
+	d.RecordRunPod("2", "kuberhealthy/check-2")
+	if d.RunPod("1") != "kuberhealthy/check-1" || d.RunPod("2") != "kuberhealthy/check-2" {
+		t.Fatal("Expected the pods of both authorized runs to be recorded but got", d.RunPods)
+	}
+
+	d.StartRun("3", 1)
+	d.RecordRunPod("3", "kuberhealthy/check-3")
+	if len(d.RunPod("1")) != 0 || len(d.RunPods) != 2 {
+		t.Fatal("Expected the pod of a run that is no longer authorized to be dropped but got", d.RunPods)
+	}
+	if len(d.RunPod("4")) != 0 {
+		t.Fatal("Expected no pod for a run that was never started but got", d.RunPod("4"))
+	}
+
+	d.ForgetRunPod("3")
+	if len(d.RunPod("3")) != 0 || d.RunPod("2") != "kuberhealthy/check-2" {
+		t.Fatal("Expected only the forgotten pod to be removed but got", d.RunPods)
+	}
+}
@@ -0,0 +1,39 @@
+package health
+
+// Debouncer tracks the results of consecutive check runs so that the health of a check only changes
+// after several runs in a row agree.  This keeps a single transient failure from flipping the cluster status.
+type Debouncer struct {
+	FailureThreshold int      // consecutive failed runs before a healthy check is reported unhealthy
+	SuccessThreshold int      // consecutive successful runs before an unhealthy check is reported healthy
+	unhealthy        bool     // the debounced health of the check
+	streak           int      // consecutive runs that disagree with the debounced health
+	errors           []string // the errors of the most recent failed run
+}
+
+// Record adds the result of a run and returns the debounced health of the check along with the errors
+// that should be reported for it.  Checks start out healthy.  Thresholds below one are treated as one.
+func (d *Debouncer) Record(ok bool, errors []string) (bool, []string) {
+	if !ok {
+		d.errors = errors
+	}
+
+	if ok != d.unhealthy {
+		// the run agrees with the current health
+		d.streak = 0
+	} else {
+		d.streak++
+		threshold := d.FailureThreshold
+		if d.unhealthy {
+			threshold = d.SuccessThreshold
+		}
+		if d.streak >= threshold {
+			d.unhealthy = !ok
+			d.streak = 0
+		}
+	}
+
+	if d.unhealthy {
+		return false, d.errors
+	}
+	return true, []string{}
+}
@@ -0,0 +1,51 @@
+package health
+
+import "testing"
+
+// TestDebouncerRecord validates that health only changes after the configured number of runs agree
+func TestDebouncerRecord(t *testing.T) {
+	d := Debouncer{FailureThreshold: 3, SuccessThreshold: 2}
+	runs := []struct {
+		ok       bool
+		expected bool
+	}{
+		{false, true},
+		{true, true}, // a success resets the failure streak
+		{false, true},
+		{false, true},
+		{false, false},
+		{true, false},
+		{false, false},
+		{true, false},
+		{true, true},
+	}
+	for i, r := range runs {
+		errors := []string{}
+		if !r.ok {
+			errors = []string{"run failed"}
+		}
+		ok, reported := d.Record(r.ok, errors)
+		if ok != r.expected {
+			t.Fatal("Expected health", r.expected, "after run", i, "but got", ok)
+		}
+		if ok && len(reported) != 0 {
+			t.Fatal("Expected no errors to be reported for a healthy check but got", reported)
+		}
+		if !ok && len(reported) == 0 {
+			t.Fatal("Expected errors to be reported for an unhealthy check after run", i)
+		}
+	}
+}
+
+// TestDebouncerDefaults validates that a debouncer without thresholds reports every result as is
+func TestDebouncerDefaults(t *testing.T) {
+	d := Debouncer{}
+	ok, errors := d.Record(false, []string{"run failed"})
+	if ok || len(errors) != 1 {
+		t.Fatal("Expected the first failure to be reported but got", ok, errors)
+	}
+	ok, _ = d.Record(true, []string{})
+	if !ok {
+		t.Fatal("Expected the first success to be reported")
+	}
+}
@@ -0,0 +1,81 @@
+// Copyright 2018 Comcast Cable Communications Management, LLC
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//     http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package health
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// the categories of the errors of a run, so that they can be routed to the people who can fix them
+const (
+	ErrorCategoryInfrastructure = "infrastructure" // Kuberhealthy could not run the check, such as when its checker pod could not be scheduled
+	ErrorCategoryCheck          = "check"          // the check ran and reported a failure
+	ErrorCategoryConfiguration  = "configuration"  // the khcheck is configured wrong, such as a bad pod spec or a missing secret
+)
+
+// MaxErrorHistory is the number of distinct errors that are kept in the error history of a check.  The errors
+// that were seen least recently are dropped first.
+var MaxErrorHistory = 10
+
+// ErrorRecord is a distinct error message reported by the runs of a check, with how many runs reported it and
+// when it was first and last seen
+type ErrorRecord struct {
+	Message   string
+	Count     int
+	FirstSeen time.Time
+	LastSeen  time.Time
+}
+
+// String returns the message of the error with how often it was seen, such as "timed out (×12 since 10:03)"
+func (r ErrorRecord) String() string {
+	if r.Count <= 1 {
+		return r.Message
+	}
+	return fmt.Sprintf("%s (×%d since %s)", r.Message, r.Count, r.FirstSeen.UTC().Format("15:04"))
+}
+
+// RecordErrors merges the errors of a run into the error history of the check.  Errors that were seen before
+// are counted again instead of being added twice, and the history is trimmed to the most recently seen
+// errors so that it stays small enough to be stored with the state of the check.
+func (d *CheckDetails) RecordErrors(now time.Time, errs []string) {
+	seen := make(map[string]bool, len(errs))
+	for _, e := range errs {
+		e = strings.TrimSpace(e)
+		if len(e) == 0 || seen[e] {
+			continue
+		}
+		seen[e] = true
+
+		found := false
+		for i := range d.ErrorHistory {
+			if d.ErrorHistory[i].Message == e {
+				d.ErrorHistory[i].Count++
+				d.ErrorHistory[i].LastSeen = now
+				found = true
+				break
+			}
+		}
+		if !found {
+			d.ErrorHistory = append(d.ErrorHistory, ErrorRecord{Message: e, Count: 1, FirstSeen: now, LastSeen: now})
+		}
+	}
+
+	sort.SliceStable(d.ErrorHistory, func(i, j int) bool {
+		return d.ErrorHistory[i].LastSeen.After(d.ErrorHistory[j].LastSeen)
+	})
+	if len(d.ErrorHistory) > MaxErrorHistory {
+		d.ErrorHistory = d.ErrorHistory[:MaxErrorHistory]
+	}
+}
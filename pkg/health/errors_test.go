@@ -0,0 +1,42 @@
+package health
+
+import (
+	"strconv"
+	"testing"
+	"time"
+)
+
+// TestRecordErrors validates that repeated errors are counted instead of added again, and that the history keeps
+// only the most recently seen errors
+func TestRecordErrors(t *testing.T) {
+	d := NewCheckDetails()
+	start := time.Date(2020, 4, 1, 10, 3, 0, 0, time.UTC)
+	d.RecordErrors(start, []string{"timed out", "timed out", " "})
+	d.RecordErrors(start.Add(time.Minute), []string{"connection refused"})
+	d.RecordErrors(start.Add(time.Minute*2), []string{"timed out"})
+	if len(d.ErrorHistory) != 2 {
+		t.Fatal("Expected two distinct errors but got", d.ErrorHistory)
+	}
+	r := d.ErrorHistory[0]
+	if r.Message != "timed out" || r.Count != 2 || !r.FirstSeen.Equal(start) || !r.LastSeen.Equal(start.Add(time.Minute*2)) {
+		t.Fatal("Expected the most recent error to be seen twice since the first run but got", r)
+	}
+	if r.String() != "timed out (×2 since 10:03)" {
+		t.Fatal("Expected the error to show how often it was seen but got", r.String())
+	}
+	if d.ErrorHistory[1].String() != "connection refused" {
+		t.Fatal("Expected an error seen once to show only its message but got", d.ErrorHistory[1].String())
+	}
+
+	for i := 0; i < MaxErrorHistory; i++ {
+		d.RecordErrors(start.Add(time.Hour+time.Duration(i)*time.Minute), []string{"error " + strconv.Itoa(i)})
+	}
+	if len(d.ErrorHistory) != MaxErrorHistory {
+		t.Fatal("Expected the error history to be trimmed but got", len(d.ErrorHistory))
+	}
+	for _, r := range d.ErrorHistory {
+		if r.Message == "timed out" || r.Message == "connection refused" {
+			t.Fatal("Expected the least recently seen errors to be dropped but got", d.ErrorHistory)
+		}
+	}
+}
@@ -0,0 +1,151 @@
+// Copyright 2018 Comcast Cable Communications Management, LLC
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//     http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package health
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// The formats the status page can be written in
+const (
+	FormatJSON   = "json"
+	FormatText   = "text"
+	FormatNagios = "nagios"
+)
+
+// The exit codes of Nagios and Icinga plugins, which the nagios format reports the overall status with
+const (
+	NagiosOK       = 0
+	NagiosWarning  = 1
+	NagiosCritical = 2
+)
+
+// NagiosExitCodeHeader is the response header that holds the Nagios exit code of the status in the nagios format
+const NagiosExitCodeHeader = "X-Nagios-Exit-Code"
+
+// nagiosStates are the service states of the Nagios exit codes
+var nagiosStates = []string{"OK", "WARNING", "CRITICAL"}
+
+// NegotiateFormat returns the format a status page request asks for.  The format query parameter takes
+// precedence over the Accept header, and JSON is returned when neither asks for a known format.
+func NegotiateFormat(r *http.Request) (string, error) {
+	if format := r.URL.Query().Get("format"); len(format) > 0 {
+		switch format {
+		case FormatJSON, FormatText, FormatNagios:
+			return format, nil
+		}
+		return "", errors.New("unknown status format " + format + ".  Expected json, text, or nagios.")
+	}
+
+	// the first media type that is known wins, so browsers asking for html and */* get JSON
+	for _, mediaType := range strings.Split(r.Header.Get("Accept"), ",") {
+		switch strings.TrimSpace(strings.Split(mediaType, ";")[0]) {
+		case "application/json":
+			return FormatJSON, nil
+		case "text/plain":
+			return FormatText, nil
+		}
+	}
+	return FormatJSON, nil
+}
+
+// WriteHTTPStatusResponseFormat writes the state to an http response writer in the supplied format.  JSON is
+// always written with a 200 so that existing callers are unaffected, while the text and nagios formats
+// respond with a 503 when the overall status is failing so that tools which only look at the status code,
+// such as curl --fail, see the failure.
+func (h *State) WriteHTTPStatusResponseFormat(w http.ResponseWriter, format string) error {
+	var body string
+	switch format {
+	case FormatJSON:
+		w.Header().Set("Content-Type", "application/json")
+		return h.WriteHTTPStatusResponse(w)
+	case FormatText:
+		body = h.Text()
+	case FormatNagios:
+		var code int
+		code, body = h.Nagios()
+		w.Header().Set(NagiosExitCodeHeader, strconv.Itoa(code))
+	default:
+		return errors.New("unknown status format " + format)
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	if !h.OK {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	_, err := w.Write([]byte(body))
+	return err
+}
+
+// Text returns the overall status as compact plaintext for smoke tests.  The first line is OK or FAILING and
+// each error of the overall status follows on its own line.
+func (h *State) Text() string {
+	if h.OK {
+		return "OK\n"
+	}
+	var b strings.Builder
+	b.WriteString("FAILING\n")
+	for _, e := range h.Errors {
+		b.WriteString(e + "\n")
+	}
+	return b.String()
+}
+
+// Nagios returns the overall status as the exit code and output of a Nagios or Icinga plugin.  The status is
+// CRITICAL when the overall status is failing, and WARNING when checks are failing without failing the
+// overall status, such as silenced checks or checks below the failure threshold.  The first line of the
+// output holds performance data on the number of checks, and each failing check follows on its own line.
+func (h *State) Nagios() (int, string) {
+	keys := make([]string, 0, len(h.CheckDetails))
+	for key := range h.CheckDetails {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var failing []string
+	var silenced int
+	for _, key := range keys {
+		d := h.CheckDetails[key]
+		if d.OK {
+			continue
+		}
+		line := key + ": " + strings.Join(d.Errors, "; ")
+		if d.Silence != nil {
+			silenced++
+			line += " (silenced)"
+		}
+		failing = append(failing, line)
+	}
+
+	code := NagiosOK
+	summary := fmt.Sprintf("%d checks OK", len(keys))
+	switch {
+	case !h.OK:
+		code = NagiosCritical
+		summary = fmt.Sprintf("%d of %d checks failing", len(failing), len(keys))
+	case len(failing) > 0:
+		code = NagiosWarning
+		summary = fmt.Sprintf("%d of %d checks failing without failing the overall status", len(failing), len(keys))
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "KUBERHEALTHY %s - %s | checks=%d failing=%d silenced=%d\n", nagiosStates[code], summary, len(keys), len(failing), silenced)
+	for _, line := range failing {
+		b.WriteString(line + "\n")
+	}
+	return code, b.String()
+}
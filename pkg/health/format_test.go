@@ -0,0 +1,87 @@
+package health
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestNegotiateFormat validates that the format parameter takes precedence over the Accept header
+func TestNegotiateFormat(t *testing.T) {
+	tests := []struct {
+		url      string
+		accept   string
+		expected string
+	}{
+		{url: "/", expected: FormatJSON},
+		{url: "/", accept: "text/html,application/xhtml+xml,*/*;q=0.8", expected: FormatJSON},
+		{url: "/", accept: "text/plain", expected: FormatText},
+		{url: "/", accept: "text/html, text/plain;q=0.9", expected: FormatText},
+		{url: "/?format=nagios", accept: "application/json", expected: FormatNagios},
+	}
+	for _, test := range tests {
+		r := httptest.NewRequest(http.MethodGet, test.url, nil)
+		r.Header.Set("Accept", test.accept)
+		format, err := NegotiateFormat(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if format != test.expected {
+			t.Fatal("Expected format", test.expected, "for", test.url, test.accept, "but got", format)
+		}
+	}
+
+	_, err := NegotiateFormat(httptest.NewRequest(http.MethodGet, "/?format=xml", nil))
+	if err == nil {
+		t.Fatal("Expected an error for an unknown format")
+	}
+}
+
+// TestNagios validates the exit codes and output of the nagios format
+func TestNagios(t *testing.T) {
+	s := NewState()
+	s.CheckDetails["kuberhealthy/deployment"] = CheckDetails{OK: true}
+	s.CheckDetails["kuberhealthy/dns"] = CheckDetails{OK: true}
+	code, output := s.Nagios()
+	if code != NagiosOK || output != "KUBERHEALTHY OK - 2 checks OK | checks=2 failing=0 silenced=0\n" {
+		t.Fatal("Expected an OK status but got", code, output)
+	}
+
+	// failing checks that do not fail the overall status are a warning
+	s.CheckDetails["kuberhealthy/dns"] = CheckDetails{Errors: []string{"timed out"}, Silence: &Silence{Expires: time.Now().Add(time.Hour)}}
+	code, output = s.Nagios()
+	if code != NagiosWarning || !strings.HasSuffix(output, "silenced=1\nkuberhealthy/dns: timed out (silenced)\n") {
+		t.Fatal("Expected a warning status but got", code, output)
+	}
+
+	s.OK = false
+	s.Errors = []string{"timed out"}
+	code, output = s.Nagios()
+	if code != NagiosCritical || !strings.HasPrefix(output, "KUBERHEALTHY CRITICAL - 1 of 2 checks failing |") {
+		t.Fatal("Expected a critical status but got", code, output)
+	}
+
+	w := httptest.NewRecorder()
+	err := s.WriteHTTPStatusResponseFormat(w, FormatNagios)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if w.Code != http.StatusServiceUnavailable || w.Header().Get(NagiosExitCodeHeader) != "2" {
+		t.Fatal("Expected a 503 with the critical exit code but got", w.Code, w.Header())
+	}
+}
+
+// TestText validates the plaintext format
+func TestText(t *testing.T) {
+	s := NewState()
+	if s.Text() != "OK\n" {
+		t.Fatal("Expected OK but got", s.Text())
+	}
+	s.OK = false
+	s.Errors = []string{"Check kuberhealthy/dns failed: timed out"}
+	if s.Text() != "FAILING\nCheck kuberhealthy/dns failed: timed out\n" {
+		t.Fatal("Expected the failing status with its errors but got", s.Text())
+	}
+}
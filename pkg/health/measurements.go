@@ -0,0 +1,46 @@
+package health
+
+// Measurements are numeric values reported by a checker pod with the result of its run, such as the latency
+// of a request or the number of objects it found, keyed by name
+type Measurements map[string]float64
+
+// Baselines holds the values of each measurement over the most recent runs of a check, oldest first
+type Baselines map[string][]float64
+
+// Mean returns the average value of a measurement over the most recent runs.  False is returned when fewer
+// runs than that reported the measurement.
+func (b Baselines) Mean(name string, runs int) (float64, bool) {
+	values := b[name]
+	if runs <= 0 || len(values) < runs {
+		return 0, false
+	}
+	values = values[len(values)-runs:]
+	var sum float64
+	for _, v := range values {
+		sum += v
+	}
+	return sum / float64(len(values)), true
+}
+
+// RecordBaselines adds the measurements of the last run to the baselines of the check.  Windows holds the
+// number of recent values kept for each measurement.  Baselines of measurements that are not in windows are
+// dropped, and measurements that were not reported leave their baseline unchanged.
+func (d *CheckDetails) RecordBaselines(windows map[string]int) {
+	baselines := make(Baselines)
+	for name, window := range windows {
+		values := append([]float64{}, d.Baselines[name]...)
+		if v, ok := d.Measurements[name]; ok {
+			values = append(values, v)
+		}
+		if len(values) > window {
+			values = values[len(values)-window:]
+		}
+		if len(values) > 0 {
+			baselines[name] = values
+		}
+	}
+	if len(baselines) == 0 {
+		baselines = nil
+	}
+	d.Baselines = baselines
+}
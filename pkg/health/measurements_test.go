@@ -0,0 +1,30 @@
+package health
+
+import (
+	"testing"
+)
+
+// TestRecordBaselines validates that baselines keep the most recent values of each measurement with a window
+func TestRecordBaselines(t *testing.T) {
+	d := NewCheckDetails()
+	d.Baselines = Baselines{"latency": {1, 2, 3}, "dropped": {1}}
+	d.Measurements = Measurements{"latency": 4, "count": 7, "unwatched": 1}
+	d.RecordBaselines(map[string]int{"latency": 3, "count": 5, "missing": 5})
+
+	if len(d.Baselines) != 2 {
+		t.Fatal("Expected only measurements with a window and a value to have baselines but got", d.Baselines)
+	}
+	latency := d.Baselines["latency"]
+	if len(latency) != 3 || latency[0] != 2 || latency[2] != 4 {
+		t.Fatal("Expected the oldest latency to be dropped from its baseline but got", latency)
+	}
+	if mean, ok := d.Baselines.Mean("latency", 3); !ok || mean != 3 {
+		t.Fatal("Expected a mean latency of 3 but got", mean)
+	}
+	if mean, ok := d.Baselines.Mean("latency", 2); !ok || mean != 3.5 {
+		t.Fatal("Expected a mean latency of 3.5 over the last 2 runs but got", mean)
+	}
+	if _, ok := d.Baselines.Mean("count", 2); ok {
+		t.Fatal("Expected no mean for a measurement without enough values")
+	}
+}
@@ -0,0 +1,93 @@
+package health
+
+import (
+	"sort"
+	"strings"
+)
+
+// DefaultSeverityWeight is the weight of failing checks whose severity has no configured weight
+const DefaultSeverityWeight = 1.0
+
+// RollupPolicy decides the overall OK status of a cluster from the status of its checks.  Each failing check
+// adds the weight of its severity, and the cluster is unhealthy once the total weight of failing checks
+// reaches the failure threshold.  The default policy marks the cluster unhealthy when any check fails.
+type RollupPolicy struct {
+	FailureThreshold  float64            // the total weight of failing checks at which the cluster is unhealthy
+	SeverityWeights   map[string]float64 // the weight of failing checks by severity
+	ExcludeNamespaces []string           // namespaces whose checks never affect the overall status
+}
+
+// DefaultRollupPolicy returns a policy where any failing check makes the cluster unhealthy
+func DefaultRollupPolicy() RollupPolicy {
+	return RollupPolicy{FailureThreshold: 1}
+}
+
+// Weight returns the weight a check adds when it fails
+func (p RollupPolicy) Weight(d CheckDetails) float64 {
+	if w, ok := p.SeverityWeights[d.Severity]; ok {
+		return w
+	}
+	return DefaultSeverityWeight
+}
+
+// Excluded returns true if a check never affects the overall status, either because its namespace is
+// excluded or because it is silenced
+func (p RollupPolicy) Excluded(d CheckDetails) bool {
+	if d.Silence != nil {
+		return true
+	}
+	for _, ns := range p.ExcludeNamespaces {
+		if ns == d.Namespace {
+			return true
+		}
+	}
+	return false
+}
+
+// Apply sets the overall OK status and errors of a state from its check details.  The errors of every check
+// that is not excluded are listed, even when their total weight is below the failure threshold.  Checks whose
+// health is unknown are listed apart from the errors and do not count as failing.
+func (p RollupPolicy) Apply(s *State) {
+	s.OK = true
+	s.Errors = []string{}
+	s.Unknown = nil
+
+	keys := make([]string, 0, len(s.CheckDetails))
+	for key := range s.CheckDetails {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var failingWeight float64
+	for _, key := range keys {
+		d := s.CheckDetails[key]
+		if p.Excluded(d) {
+			continue
+		}
+		if d.Unknown {
+			s.Unknown = append(s.Unknown, key)
+			continue
+		}
+
+		// skip blank errors
+		failing := false
+		for _, e := range d.Errors {
+			if len(strings.TrimSpace(e)) == 0 {
+				continue
+			}
+			s.AddError(e)
+			failing = true
+		}
+		if failing {
+			failingWeight += p.Weight(d)
+		}
+	}
+
+	threshold := p.FailureThreshold
+	if threshold <= 0 {
+		threshold = DefaultRollupPolicy().FailureThreshold
+	}
+	if failingWeight >= threshold {
+		s.OK = false
+	}
+}
@@ -0,0 +1,81 @@
+package health
+
+import (
+	"testing"
+	"time"
+)
+
+// rollupTestState returns a state with a failing critical check and two failing warning checks, one of which
+// is in an excluded namespace
+func rollupTestState() State {
+	s := NewState()
+	s.CheckDetails["kuberhealthy/dns"] = CheckDetails{OK: true, Namespace: "kuberhealthy", Errors: []string{}}
+	s.CheckDetails["kuberhealthy/deployment"] = CheckDetails{OK: false, Namespace: "kuberhealthy", Severity: "critical", Errors: []string{"deployment did not roll out"}}
+	s.CheckDetails["kuberhealthy/pod-restarts"] = CheckDetails{OK: false, Namespace: "kuberhealthy", Severity: "warning", Errors: []string{"pod restarted"}}
+	s.CheckDetails["sandbox/ssl"] = CheckDetails{OK: false, Namespace: "sandbox", Severity: "warning", Errors: []string{"certificate expires soon"}}
+	return s
+}
+
+// TestRollupDefault validates that any failing check makes the cluster unhealthy by default
+func TestRollupDefault(t *testing.T) {
+	s := rollupTestState()
+	DefaultRollupPolicy().Apply(&s)
+	if s.OK || len(s.Errors) != 3 {
+		t.Fatal("Expected the cluster to be unhealthy with every error listed but got", s.OK, s.Errors)
+	}
+	if s.Errors[0] != "deployment did not roll out" {
+		t.Fatal("Expected errors to be ordered by check but got", s.Errors)
+	}
+}
+
+// TestRollupPolicy validates that failing checks are weighted by severity against the failure threshold
+func TestRollupPolicy(t *testing.T) {
+	p := RollupPolicy{
+		FailureThreshold:  2,
+		SeverityWeights:   map[string]float64{"critical": 2, "warning": 0.5},
+		ExcludeNamespaces: []string{"sandbox"},
+	}
+
+	// the critical check alone reaches the threshold
+	s := rollupTestState()
+	p.Apply(&s)
+	if s.OK || len(s.Errors) != 2 {
+		t.Fatal("Expected the critical check to make the cluster unhealthy without the excluded error but got", s.OK, s.Errors)
+	}
+
+	// warnings alone do not
+	s = rollupTestState()
+	s.CheckDetails["kuberhealthy/deployment"] = CheckDetails{OK: true, Namespace: "kuberhealthy", Severity: "critical", Errors: []string{}}
+	p.Apply(&s)
+	if !s.OK || len(s.Errors) != 1 {
+		t.Fatal("Expected a single warning to leave the cluster healthy with its error listed but got", s.OK, s.Errors)
+	}
+
+	// silenced checks are excluded
+	s = rollupTestState()
+	d := s.CheckDetails["kuberhealthy/deployment"]
+	d.Silence = &Silence{Reason: "INC-1", Author: "oncall"}
+	s.CheckDetails["kuberhealthy/deployment"] = d
+	p.Apply(&s)
+	if !s.OK || len(s.Errors) != 1 {
+		t.Fatal("Expected the silenced check to be excluded but got", s.OK, s.Errors)
+	}
+}
+
+// TestRollupUnknown validates that checks whose health is unknown are listed apart from the errors and do not
+// count as failing
+func TestRollupUnknown(t *testing.T) {
+	s := rollupTestState()
+	d := s.CheckDetails["kuberhealthy/deployment"]
+	d.UnknownAt = time.Now()
+	d.MarkUnknown(time.Now())
+	s.CheckDetails["kuberhealthy/deployment"] = d
+
+	DefaultRollupPolicy().Apply(&s)
+	if len(s.Errors) != 2 {
+		t.Fatal("Expected the errors of the unknown check to be left out but got", s.Errors)
+	}
+	if len(s.Unknown) != 1 || s.Unknown[0] != "kuberhealthy/deployment" {
+		t.Fatal("Expected the unknown check to be listed but got", s.Unknown)
+	}
+}
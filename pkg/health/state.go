@@ -24,21 +24,36 @@ type State struct {
 	OK            bool
 	Errors        []string
 	CheckDetails  map[string]CheckDetails // map of check names to last run timestamp
+	Unknown       []string                `json:",omitempty"` // the checks whose health is unknown because their last result outlived their result TTL
 	CurrentMaster string
 }
 
-// AddError adds new errors to State
+// AddError adds new errors to State.  Errors that are already in the State are not added again.
 func (h *State) AddError(s ...string) {
 	for _, str := range s {
-		if len(s) == 0 {
+		if len(str) == 0 {
 			log.Warningln("AddError was called but the error was blank so it was skipped.")
 			continue
 		}
+		if h.hasError(str) {
+			log.Debugln("Skipping duplicate error:", str)
+			continue
+		}
 		log.Debugln("Appending error:", str)
 		h.Errors = append(h.Errors, str)
 	}
 }
 
+// hasError returns true if the State already has an error
+func (h *State) hasError(str string) bool {
+	for _, e := range h.Errors {
+		if e == str {
+			return true
+		}
+	}
+	return false
+}
+
 // WriteHTTPStatusResponse writes a response to an http response writer
 func (h *State) WriteHTTPStatusResponse(w http.ResponseWriter) error {
 
@@ -63,6 +78,19 @@ func (h *State) WriteHTTPStatusResponse(w http.ResponseWriter) error {
 	return err
 }
 
+// Filter returns a copy of the state holding only the checks that keep returns true for.  The overall OK
+// status and errors of the copy are left for a roll-up policy to compute.
+func (h State) Filter(keep func(CheckDetails) bool) State {
+	filtered := h
+	filtered.CheckDetails = make(map[string]CheckDetails)
+	for key, d := range h.CheckDetails {
+		if keep(d) {
+			filtered.CheckDetails[key] = d
+		}
+	}
+	return filtered
+}
+
 // NewState creates a new health check result response
 func NewState() State {
 	s := State{}
@@ -0,0 +1,34 @@
+package health
+
+import (
+	"testing"
+)
+
+// TestFilter validates that filtering a state keeps only the selected checks and leaves the original alone
+func TestFilter(t *testing.T) {
+	s := NewState()
+	s.CheckDetails["kuberhealthy/deployment"] = CheckDetails{Namespace: "kuberhealthy", Labels: map[string]string{"team": "payments"}}
+	s.CheckDetails["kuberhealthy/dns"] = CheckDetails{Namespace: "kuberhealthy", Labels: map[string]string{"team": "platform"}}
+
+	filtered := s.Filter(func(d CheckDetails) bool {
+		return d.Labels["team"] == "payments"
+	})
+	if len(filtered.CheckDetails) != 1 {
+		t.Fatal("Expected only the payments check to be kept but got", filtered.CheckDetails)
+	}
+	if _, ok := filtered.CheckDetails["kuberhealthy/deployment"]; !ok {
+		t.Fatal("Expected the payments check to be kept but got", filtered.CheckDetails)
+	}
+	if len(s.CheckDetails) != 2 {
+		t.Fatal("Expected the original state to keep every check but got", s.CheckDetails)
+	}
+}
+
+// TestAddError validates that blank and duplicate errors are not added to a state
+func TestAddError(t *testing.T) {
+	s := NewState()
+	s.AddError("dns failed", "", "dns failed", "deployment failed")
+	if len(s.Errors) != 2 || s.Errors[0] != "dns failed" || s.Errors[1] != "deployment failed" {
+		t.Fatal("Expected two distinct errors but got", s.Errors)
+	}
+}
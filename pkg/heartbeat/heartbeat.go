@@ -0,0 +1,119 @@
+// Package heartbeat tracks whether long-running goroutines are still making progress.  Each goroutine
+// beats with the time by which it will beat again, and is considered wedged once that time has passed
+// without another beat.  Goroutines can also record the phase they are in, so that an operator can see
+// what a wedged goroutine was doing.
+package heartbeat
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// Monitor holds the heartbeat deadlines of a set of named goroutines
+type Monitor struct {
+	mu      sync.Mutex
+	entries map[string]*entry
+}
+
+// entry is the most recent heartbeat and phase of a single goroutine
+type entry struct {
+	lastBeat   time.Time
+	deadline   time.Time
+	phase      string
+	phaseSince time.Time
+}
+
+// Status is the most recent heartbeat and phase of a single goroutine
+type Status struct {
+	Name       string
+	Phase      string    // what the goroutine is doing, such as idle
+	PhaseSince time.Time // when the goroutine entered its phase
+	LastBeat   time.Time // when the goroutine last beat
+	Deadline   time.Time // when the goroutine is expected to beat again
+	Missed     bool      // the goroutine did not beat again by its deadline
+}
+
+// New creates a Monitor without any goroutines
+func New() *Monitor {
+	return &Monitor{
+		entries: make(map[string]*entry),
+	}
+}
+
+// get returns the entry of the named goroutine, creating it if it does not exist
+func (m *Monitor) get(name string) *entry {
+	e, ok := m.entries[name]
+	if !ok {
+		e = &entry{}
+		m.entries[name] = e
+	}
+	return e
+}
+
+// Beat records that the named goroutine is alive and will beat again before next
+func (m *Monitor) Beat(name string, next time.Time) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	e := m.get(name)
+	e.lastBeat = time.Now()
+	e.deadline = next
+}
+
+// SetPhase records the phase that the named goroutine is in.  Setting the phase it is already in does not
+// reset when it entered the phase.
+func (m *Monitor) SetPhase(name string, phase string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	e := m.get(name)
+	if e.phase == phase {
+		return
+	}
+	e.phase = phase
+	e.phaseSince = time.Now()
+}
+
+// Remove stops tracking the named goroutine.  Goroutines should be removed when they exit cleanly.
+func (m *Monitor) Remove(name string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.entries, name)
+}
+
+// Missed returns the names of the goroutines that have not beat again by their deadline, in sorted order
+func (m *Monitor) Missed(now time.Time) []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var missed []string
+	for name, e := range m.entries {
+		if e.missed(now) {
+			missed = append(missed, name)
+		}
+	}
+	sort.Strings(missed)
+	return missed
+}
+
+// Statuses returns the heartbeat and phase of every goroutine, sorted by name
+func (m *Monitor) Statuses(now time.Time) []Status {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	statuses := make([]Status, 0, len(m.entries))
+	for name, e := range m.entries {
+		statuses = append(statuses, Status{
+			Name:       name,
+			Phase:      e.phase,
+			PhaseSince: e.phaseSince,
+			LastBeat:   e.lastBeat,
+			Deadline:   e.deadline,
+			Missed:     e.missed(now),
+		})
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Name < statuses[j].Name })
+	return statuses
+}
+
+// missed returns true if the goroutine has beat and not beat again by its deadline
+func (e *entry) missed(now time.Time) bool {
+	return !e.deadline.IsZero() && now.After(e.deadline)
+}
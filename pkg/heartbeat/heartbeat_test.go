@@ -0,0 +1,59 @@
+package heartbeat
+
+import (
+	"testing"
+	"time"
+)
+
+// TestMissed validates that only goroutines past their deadline are reported as missed
+func TestMissed(t *testing.T) {
+	now := time.Now()
+	m := New()
+	if len(m.Missed(now)) != 0 {
+		t.Fatal("Expected an empty monitor to have no missed heartbeats")
+	}
+
+	m.Beat("kuberhealthy/deployment", now.Add(-time.Second))
+	m.Beat("kuberhealthy/dns", now.Add(time.Minute))
+	m.Beat("kuberhealthy/daemonset", now.Add(-time.Minute))
+	missed := m.Missed(now)
+	if len(missed) != 2 || missed[0] != "kuberhealthy/daemonset" || missed[1] != "kuberhealthy/deployment" {
+		t.Fatal("Expected the daemonset and deployment heartbeats to be missed but got", missed)
+	}
+
+	// a new beat and removal both clear a missed heartbeat
+	m.Beat("kuberhealthy/deployment", now.Add(time.Minute))
+	m.Remove("kuberhealthy/daemonset")
+	missed = m.Missed(now)
+	if len(missed) != 0 {
+		t.Fatal("Expected no missed heartbeats but got", missed)
+	}
+}
+
+// TestStatuses validates that the phase and heartbeat of each goroutine are reported
+func TestStatuses(t *testing.T) {
+	now := time.Now()
+	m := New()
+	m.SetPhase("kuberhealthy/dns", "idle")
+	m.Beat("kuberhealthy/deployment", now.Add(-time.Second))
+	m.SetPhase("kuberhealthy/deployment", "running")
+
+	statuses := m.Statuses(now)
+	if len(statuses) != 2 || statuses[0].Name != "kuberhealthy/deployment" || statuses[1].Name != "kuberhealthy/dns" {
+		t.Fatal("Expected the statuses of both goroutines sorted by name but got", statuses)
+	}
+	if statuses[0].Phase != "running" || !statuses[0].Missed || statuses[0].LastBeat.IsZero() {
+		t.Fatalf("Expected the deployment goroutine to be running and missed but got %+v", statuses[0])
+	}
+	if statuses[1].Phase != "idle" || statuses[1].Missed {
+		t.Fatalf("Expected a goroutine that never beat to be idle and not missed but got %+v", statuses[1])
+	}
+
+	// setting the same phase again keeps when the phase was entered
+	since := statuses[1].PhaseSince
+	time.Sleep(time.Millisecond)
+	m.SetPhase("kuberhealthy/dns", "idle")
+	if !m.Statuses(now)[1].PhaseSince.Equal(since) {
+		t.Fatal("Expected setting the same phase to keep when it was entered")
+	}
+}
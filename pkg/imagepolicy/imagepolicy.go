@@ -0,0 +1,86 @@
+// Package imagepolicy restricts the images that checker pods can run to an
+// allowlist of registries and repositories, so that the owners of khchecks in
+// a shared cluster can not run arbitrary images with the permissions of
+// Kuberhealthy's checker pods.
+package imagepolicy
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// RegexPrefix marks an allowlist entry as a regular expression instead of a glob
+const RegexPrefix = "regex:"
+
+// Policy is an allowlist of the images checker pods can run.  A policy without any patterns allows every image.
+type Policy struct {
+	patterns []*regexp.Regexp
+	entries  []string
+}
+
+// ParsePolicy builds a policy from a comma separated list of image patterns.  Each pattern is a glob, where *
+// matches any characters including slashes, such as quay.io/comcast/*, or a regular expression prefixed with
+// regex:, such as regex:^registry\.example\.com/(checks|tools)/.  Patterns match the whole image reference.
+func ParsePolicy(s string) (Policy, error) {
+	p := Policy{}
+	for _, entry := range strings.Split(s, ",") {
+		entry = strings.TrimSpace(entry)
+		if len(entry) == 0 {
+			continue
+		}
+
+		var expr string
+		if strings.HasPrefix(entry, RegexPrefix) {
+			expr = strings.TrimPrefix(entry, RegexPrefix)
+		} else {
+			expr = "^" + strings.Replace(regexp.QuoteMeta(entry), `\*`, ".*", -1) + "$"
+		}
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return p, fmt.Errorf("invalid image allowlist pattern %s: %w", entry, err)
+		}
+		p.patterns = append(p.patterns, re)
+		p.entries = append(p.entries, entry)
+	}
+	return p, nil
+}
+
+// Enabled returns true if the policy restricts images
+func (p Policy) Enabled() bool {
+	return len(p.patterns) > 0
+}
+
+// String returns the patterns of the policy as they were configured
+func (p Policy) String() string {
+	return strings.Join(p.entries, ",")
+}
+
+// Allows returns true if the policy allows the supplied image.  The image is matched as it is written and as
+// its fully qualified name, so that docker.io/library/* also matches short names like busybox:1.31.
+func (p Policy) Allows(image string) bool {
+	if !p.Enabled() {
+		return true
+	}
+	qualified := Qualify(image)
+	for _, re := range p.patterns {
+		if re.MatchString(image) || re.MatchString(qualified) {
+			return true
+		}
+	}
+	return false
+}
+
+// Qualify returns the fully qualified name of an image, adding the docker.io registry and library repository
+// that the container runtime assumes for short names
+func Qualify(image string) string {
+	parts := strings.SplitN(image, "/", 2)
+	if len(parts) == 1 {
+		return "docker.io/library/" + image
+	}
+	registry := parts[0]
+	if !strings.ContainsAny(registry, ".:") && registry != "localhost" {
+		return "docker.io/" + image
+	}
+	return image
+}
@@ -0,0 +1,51 @@
+package imagepolicy
+
+import "testing"
+
+// TestAllows validates that images are matched against globs and regular expressions
+func TestAllows(t *testing.T) {
+	p, err := ParsePolicy("quay.io/comcast/*, docker.io/library/busybox:*,regex:^registry\\.example\\.com/(checks|tools)/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, image := range []string{"quay.io/comcast/khcheck:1.0.0", "busybox:1.31", "docker.io/library/busybox:1.31", "registry.example.com/checks/http:2"} {
+		if !p.Allows(image) {
+			t.Fatal("Expected image", image, "to be allowed")
+		}
+	}
+	for _, image := range []string{"quay.io/other/khcheck:1.0.0", "nginx:1.17", "evil.io/quay.io/comcast/khcheck", "registry.example.com/other/http:2"} {
+		if p.Allows(image) {
+			t.Fatal("Expected image", image, "to be rejected")
+		}
+	}
+}
+
+// TestParsePolicy validates that blank policies allow every image and that invalid patterns are rejected
+func TestParsePolicy(t *testing.T) {
+	p, err := ParsePolicy(" , ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Enabled() || !p.Allows("anything:latest") {
+		t.Fatal("Expected a blank policy to allow every image")
+	}
+	_, err = ParsePolicy("regex:(")
+	if err == nil {
+		t.Fatal("Expected an error for an invalid regular expression")
+	}
+}
+
+// TestQualify validates that short image names are expanded the way the container runtime expands them
+func TestQualify(t *testing.T) {
+	for image, expected := range map[string]string{
+		"busybox":                 "docker.io/library/busybox",
+		"kuberhealthy/khcheck:1":  "docker.io/kuberhealthy/khcheck:1",
+		"quay.io/comcast/khcheck": "quay.io/comcast/khcheck",
+		"localhost/check":         "localhost/check",
+		"localhost:5000/check":    "localhost:5000/check",
+	} {
+		if Qualify(image) != expected {
+			t.Fatal("Expected", image, "to be qualified as", expected, "but got", Qualify(image))
+		}
+	}
+}
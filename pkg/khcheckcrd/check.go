@@ -4,6 +4,8 @@ import (
 	"time"
 
 	apiv1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
 )
 
 // CheckConfig represents a configuration for a kuberhealthy external
@@ -11,11 +13,118 @@ import (
 // the whitelisted UUID that is currently allowed to report-in to
 // the status reporting endpoint.
 type CheckConfig struct {
-	RunInterval      string            `json:"runInterval"`      // the interval at which the check runs
-	Timeout          string            `json:"timeout"`          // the maximum time the pod is allowed to run before a failure is assumed
-	PodSpec          apiv1.PodSpec     `json:"podSpec"`          // a spec for the external checker
-	ExtraAnnotations map[string]string `json:"extraAnnotations"` // a map of extra annotations that will be applied to the pod
-	ExtraLabels      map[string]string `json:"extraLabels"`      // a map of extra labels that will be applied to the pod
+	RunInterval           string                `json:"runInterval"`                     // the interval at which the check runs
+	Timeout               string                `json:"timeout"`                         // the maximum time the pod is allowed to run before a failure is assumed
+	PodSpec               apiv1.PodSpec         `json:"podSpec"`                         // a spec for the external checker
+	ExtraAnnotations      map[string]string     `json:"extraAnnotations"`                // a map of extra annotations that will be applied to the pod
+	ExtraLabels           map[string]string     `json:"extraLabels"`                     // a map of extra labels that will be applied to the pod
+	ServiceAccount        *ServiceAccountConfig `json:"serviceAccount,omitempty"`        // requests a dedicated service account for the checker pod
+	DisableSecurityPolicy bool                  `json:"disableSecurityPolicy,omitempty"` // opts this check out of the security policy enforced on checker pods
+	ExpandTemplates       bool                  `json:"expandTemplates,omitempty"`       // expands Go template expressions in the pod spec when each checker pod is created
+	Secrets               []ResourceRef         `json:"secrets,omitempty"`               // secrets in the check's namespace made available to the checker pod
+	ConfigMaps            []ResourceRef         `json:"configMaps,omitempty"`            // config maps in the check's namespace made available to the checker pod
+	FailureThreshold      int                   `json:"failureThreshold,omitempty"`      // consecutive failed runs before the check is reported unhealthy
+	SuccessThreshold      int                   `json:"successThreshold,omitempty"`      // consecutive successful runs before an unhealthy check is reported healthy
+	Mode                  string                `json:"mode,omitempty"`                  // how the checker pod is run, either run or daemon.  Defaults to run.
+	EphemeralNamespace    *EphemeralNamespace   `json:"ephemeralNamespace,omitempty"`    // runs each checker pod in its own namespace that is deleted after the run
+	NetworkPolicy         *NetworkPolicyConfig  `json:"networkPolicy,omitempty"`         // limits the egress traffic of the checker pod
+	SLOTarget             float64               `json:"sloTarget,omitempty"`             // the percentage of runs expected to succeed, used to compute error budgets
+	NotificationChannels  []string              `json:"notificationChannels,omitempty"`  // the webhooks notified when the health of the check changes
+	Severity              string                `json:"severity,omitempty"`              // how important the check is, which weights it when rolling up the overall status
+	Template              *TemplateRef          `json:"template,omitempty"`              // creates the check from a khchecktemplate instead of its own podSpec
+	Setup                 *Hook                 `json:"setup,omitempty"`                 // runs before the checker pod's containers on every run
+	Teardown              *Hook                 `json:"teardown,omitempty"`              // runs after the checker pod of every run is done, even when the run failed or timed out
+	TimeoutBudget         *TimeoutBudget        `json:"timeoutBudget,omitempty"`         // limits how long each phase of a run can take within the timeout
+	OS                    string                `json:"os,omitempty"`                    // the operating system of the nodes the checker pod runs on, either linux or windows
+	Architectures         []string              `json:"architectures,omitempty"`         // the CPU architectures of the nodes the checker pod runs on, such as amd64 or arm64, taking turns between runs
+	Regressions           []RegressionRule      `json:"regressions,omitempty"`           // rules that fail a run when a measurement it reported crosses a threshold or regresses from its baseline
+	MaxOverlappingRuns    int                   `json:"maxOverlappingRuns,omitempty"`    // how many earlier runs can still be in progress when a new run starts.  Defaults to 0, which runs the check one run at a time.
+	ResultTTL             string                `json:"resultTTL,omitempty"`             // how long a result is trusted before the health of the check is shown as unknown, if no new result arrives
+}
+
+// TimeoutBudget limits how long each phase of a run can take, so that the phase that is slow on a cluster can
+// be given more time without inflating the timeout of the whole run.  Phases without a budget are only limited
+// by the timeout of the run, which still applies to the run as a whole.
+type TimeoutBudget struct {
+	Schedule  string `json:"schedule,omitempty"`  // how long the checker pod can take to be scheduled onto a node
+	ImagePull string `json:"imagePull,omitempty"` // how long the checker pod can take to pull its images and start once it is scheduled
+	Run       string `json:"run,omitempty"`       // how long the checker pod can run before it reports in
+	Report    string `json:"report,omitempty"`    // how long the checker pod can take to exit once it has reported in
+	Cleanup   string `json:"cleanup,omitempty"`   // how long the pods of earlier runs can take to clean up before the checker pod is created
+}
+
+// Hook runs a command or containers before or after the checker pod of every run, such as to create and
+// destroy the fixtures a check relies on.  The command runs in the image of the checker pod's first container,
+// before any of the containers.  Containers run one after another in the order they are listed.
+type Hook struct {
+	Command    []string          `json:"command,omitempty"`    // a command run in the image of the checker pod's first container
+	Containers []apiv1.Container `json:"containers,omitempty"` // containers run one after another
+	Timeout    string            `json:"timeout,omitempty"`    // how long a teardown can take.  Setup counts toward the timeout of the run.
+}
+
+// RegressionRule fails a run of a check when a measurement reported by its checker pod crosses a threshold or
+// regresses from its baseline, which is the average of the measurement over the check's recent runs.  Increases
+// and decreases are a percentage of the baseline and are only checked once the baseline holds BaselineRuns
+// values.  Rules are ignored for runs that did not report their measurement.
+type RegressionRule struct {
+	Measurement        string   `json:"measurement"`                  // the name of the measurement reported by the checker pod
+	Max                *float64 `json:"max,omitempty"`                // fails the run when the measurement is above this value
+	Min                *float64 `json:"min,omitempty"`                // fails the run when the measurement is below this value
+	MaxIncreasePercent float64  `json:"maxIncreasePercent,omitempty"` // fails the run when the measurement is more than this percent above its baseline
+	MaxDecreasePercent float64  `json:"maxDecreasePercent,omitempty"` // fails the run when the measurement is more than this percent below its baseline
+	BaselineRuns       int      `json:"baselineRuns,omitempty"`       // the number of recent runs averaged into the baseline.  Defaults to DefaultBaselineRuns.
+}
+
+// DefaultBaselineRuns is the number of recent runs averaged into the baseline of a regression rule that does not
+// set its own
+const DefaultBaselineRuns = 10
+
+// TemplateRef references the khchecktemplate that a check is created from, along with the values of the
+// template's parameters.  The rest of the check's spec overrides the defaults of the template.
+type TemplateRef struct {
+	Name       string            `json:"name"`                 // the name of the khchecktemplate
+	Parameters map[string]string `json:"parameters,omitempty"` // the values of the template's parameters
+}
+
+// the modes a check can run in.  In run mode, a checker pod is created for each run and reports once before
+// exiting.  In daemon mode, one long-running checker pod is kept alive and reports on its own schedule.
+const ModeRun = "run"
+const ModeDaemon = "daemon"
+
+// the operating systems of the nodes a checker pod can be scheduled to.  Checks that do not set one are
+// scheduled like any other pod, which may place them on a node of either operating system.
+const OSLinux = "linux"
+const OSWindows = "windows"
+
+// ResourceRef references a Secret or ConfigMap in the check's namespace.  The resource is mounted into
+// every container of the checker pod when MountPath is set, and its keys are injected as environment
+// variables when Env is true.  At least one of the two must be used.
+type ResourceRef struct {
+	Name      string `json:"name"`                // the name of the Secret or ConfigMap
+	MountPath string `json:"mountPath,omitempty"` // the path the resource is mounted at
+	Env       bool   `json:"env,omitempty"`       // injects every key of the resource as an environment variable
+	EnvPrefix string `json:"envPrefix,omitempty"` // a prefix added to the names of injected environment variables
+}
+
+// ServiceAccountConfig requests that Kuberhealthy create a service account for a check.  The service
+// account is bound to a role in the check's namespace that grants the listed rules.
+type ServiceAccountConfig struct {
+	Rules []rbacv1.PolicyRule `json:"rules"` // the RBAC rules granted to the check's service account
+}
+
+// EphemeralNamespace requests that every run of a check happen in a dedicated namespace that Kuberhealthy
+// creates before the run and deletes after it, isolating checks that create or destroy resources.  The
+// namespace denies all ingress traffic unless AllowIngress is set and is limited by ResourceQuota when set.
+type EphemeralNamespace struct {
+	ResourceQuota apiv1.ResourceList `json:"resourceQuota,omitempty"` // the hard limits of the resource quota applied to the namespace
+	AllowIngress  bool               `json:"allowIngress,omitempty"`  // skips the network policy that denies ingress traffic to the namespace
+}
+
+// NetworkPolicyConfig requests that Kuberhealthy restrict the egress traffic of the checker pod with a
+// network policy that exists for the duration of each run.  The pod may always reach DNS and the Kuberhealthy
+// pods it reports to, along with any destinations allowed by Egress.
+type NetworkPolicyConfig struct {
+	Egress []networkingv1.NetworkPolicyEgressRule `json:"egress,omitempty"` // the destinations the checker pod is allowed to reach
 }
 
 // DefaultTimeout is the default timeout for external checks
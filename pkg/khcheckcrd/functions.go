@@ -27,6 +27,11 @@ type KuberhealthyCheckClient struct {
 	ns         string
 }
 
+// RestClient returns the rest client for easy listWatcher use
+func (c *KuberhealthyCheckClient) RestClient() rest.Interface {
+	return c.restClient
+}
+
 // Create creates a new resource for this CRD
 func (c *KuberhealthyCheckClient) Create(check *KuberhealthyCheck, resource string, namespace string) (*KuberhealthyCheck, error) {
 	result := KuberhealthyCheck{}
@@ -0,0 +1,67 @@
+// Copyright 2018 Comcast Cable Communications Management, LLC
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//     http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package khchecktemplatecrd
+
+import (
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest"
+)
+
+// KuberhealthyCheckTemplateClient holds client data for talking to Kubernetes
+// about the khchecktemplate custom resource.  Templates are cluster scoped.
+type KuberhealthyCheckTemplateClient struct {
+	restClient rest.Interface
+	ns         string
+}
+
+// Get fetches a resource of this CRD
+func (c *KuberhealthyCheckTemplateClient) Get(opts metav1.GetOptions, resource string, name string) (*KuberhealthyCheckTemplate, error) {
+	result := KuberhealthyCheckTemplate{}
+	err := c.restClient.
+		Get().
+		Resource(resource).
+		Name(name).
+		VersionedParams(&opts, scheme.ParameterCodec).
+		Do().
+		Into(&result)
+	return &result, err
+}
+
+// List lists resources for this CRD
+func (c *KuberhealthyCheckTemplateClient) List(opts metav1.ListOptions, resource string) (*KuberhealthyCheckTemplateList, error) {
+	result := KuberhealthyCheckTemplateList{}
+	err := c.restClient.
+		Get().
+		Resource(resource).
+		VersionedParams(&opts, scheme.ParameterCodec).
+		Do().
+		Into(&result)
+	return &result, err
+}
+
+// Watch returns a watch.Interface that watches resources of this CRD
+func (c *KuberhealthyCheckTemplateClient) Watch(opts metav1.ListOptions, resource string) (watch.Interface, error) {
+	var timeout time.Duration
+	if opts.TimeoutSeconds != nil {
+		timeout = time.Duration(*opts.TimeoutSeconds) * time.Second
+	}
+	opts.Watch = true
+	return c.restClient.Get().
+		Resource(resource).
+		VersionedParams(&opts, scheme.ParameterCodec).
+		Timeout(timeout).
+		Watch()
+}
@@ -0,0 +1,275 @@
+// Copyright 2018 Comcast Cable Communications Management, LLC
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//     http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package khchecktemplatecrd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+	apiv1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	"github.com/Comcast/kuberhealthy/v2/pkg/khcheckcrd"
+)
+
+// KuberhealthyCheckTemplate represents the data in the CRD for a reusable
+// check definition that khchecks are created from
+type KuberhealthyCheckTemplate struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+	Spec              TemplateSpec `json:"spec"`
+}
+
+// TemplateSpec is the check that khchecks created from the template run, along
+// with the parameters those khchecks supply values for
+type TemplateSpec struct {
+	Description string                 `json:"description,omitempty"` // what the checks created from the template verify
+	Parameters  []Parameter            `json:"parameters,omitempty"`  // the values supplied by khchecks created from the template
+	Check       khcheckcrd.CheckConfig `json:"check"`                 // the spec of the checks created from the template, including its default interval and timeout
+}
+
+// Parameter is a value supplied by the khchecks created from a template.  Every
+// container of the checker pod receives the value as an environment variable
+// of the same name, so it can also be used in commands and arguments as $(NAME).
+type Parameter struct {
+	Name        string `json:"name"`                  // the name of the parameter and of its environment variable
+	Description string `json:"description,omitempty"` // what the parameter configures
+	Required    bool   `json:"required,omitempty"`    // khchecks must supply a value for the parameter
+	Default     string `json:"default,omitempty"`     // the value used when a khcheck does not supply one
+}
+
+// String satisfies the stringer interface for cleaner output when printing
+func (h KuberhealthyCheckTemplate) String() string {
+	b, err := json.MarshalIndent(&h, "", "\t")
+	if err != nil {
+		logrus.Errorln("Failed to marshal KuberhealthyCheckTemplate in a nice format:", err)
+	}
+	return string(b)
+}
+
+// DeepCopyInto copies all properties of this object into another object of the
+// same type that is provided as a pointer.
+func (h KuberhealthyCheckTemplate) DeepCopyInto(out *KuberhealthyCheckTemplate) {
+	out.TypeMeta = h.TypeMeta
+	out.ObjectMeta = h.ObjectMeta
+	out.Spec = h.Spec
+}
+
+// DeepCopyObject returns a generically typed copy of an object
+func (h KuberhealthyCheckTemplate) DeepCopyObject() runtime.Object {
+	out := KuberhealthyCheckTemplate{}
+	h.DeepCopyInto(&out)
+	return &out
+}
+
+// NewKuberhealthyCheckTemplate creates a KuberhealthyCheckTemplate struct which
+// represents the data inside a KuberhealthyCheckTemplate resource
+func NewKuberhealthyCheckTemplate(name string, spec TemplateSpec) KuberhealthyCheckTemplate {
+	template := KuberhealthyCheckTemplate{}
+	template.ObjectMeta.Name = name
+	template.Spec = spec
+	return template
+}
+
+// Expand returns a khcheck created from the template it references.  The spec of
+// the template is used as the check's spec, with the settings of the khcheck
+// applied over it and the values of the template's parameters set as environment
+// variables on every container of the checker pod.
+func Expand(check khcheckcrd.KuberhealthyCheck, template KuberhealthyCheckTemplate) (khcheckcrd.KuberhealthyCheck, error) {
+	ref := check.Spec.Template
+	if ref == nil || ref.Name != template.Name {
+		return check, fmt.Errorf("khcheck %s does not reference khchecktemplate %s", check.Name, template.Name)
+	}
+	if len(check.Spec.PodSpec.Containers) > 0 || len(check.Spec.PodSpec.InitContainers) > 0 {
+		return check, errors.New("khchecks created from a template can not set their own podSpec")
+	}
+	env, err := template.parameterEnv(ref.Parameters)
+	if err != nil {
+		return check, err
+	}
+
+	// copy the template's spec so that expanding never changes the template
+	var spec khcheckcrd.CheckConfig
+	b, err := json.Marshal(template.Spec.Check)
+	if err != nil {
+		return check, fmt.Errorf("error copying khchecktemplate %s: %w", template.Name, err)
+	}
+	err = json.Unmarshal(b, &spec)
+	if err != nil {
+		return check, fmt.Errorf("error copying khchecktemplate %s: %w", template.Name, err)
+	}
+
+	// the settings of the khcheck override the defaults of the template
+	overrides := check.Spec
+	if len(overrides.RunInterval) > 0 {
+		spec.RunInterval = overrides.RunInterval
+	}
+	if len(overrides.Timeout) > 0 {
+		spec.Timeout = overrides.Timeout
+	}
+	spec.ExtraAnnotations = mergeMaps(spec.ExtraAnnotations, overrides.ExtraAnnotations)
+	spec.ExtraLabels = mergeMaps(spec.ExtraLabels, overrides.ExtraLabels)
+	if overrides.ServiceAccount != nil {
+		spec.ServiceAccount = overrides.ServiceAccount
+	}
+	spec.DisableSecurityPolicy = spec.DisableSecurityPolicy || overrides.DisableSecurityPolicy
+	spec.Secrets = append(spec.Secrets, overrides.Secrets...)
+	spec.ConfigMaps = append(spec.ConfigMaps, overrides.ConfigMaps...)
+	if overrides.FailureThreshold > 0 {
+		spec.FailureThreshold = overrides.FailureThreshold
+	}
+	if overrides.SuccessThreshold > 0 {
+		spec.SuccessThreshold = overrides.SuccessThreshold
+	}
+	if len(overrides.Mode) > 0 {
+		spec.Mode = overrides.Mode
+	}
+	if overrides.EphemeralNamespace != nil {
+		spec.EphemeralNamespace = overrides.EphemeralNamespace
+	}
+	if overrides.NetworkPolicy != nil {
+		spec.NetworkPolicy = overrides.NetworkPolicy
+	}
+	if overrides.Setup != nil {
+		spec.Setup = overrides.Setup
+	}
+	if overrides.Teardown != nil {
+		spec.Teardown = overrides.Teardown
+	}
+	if overrides.TimeoutBudget != nil {
+		spec.TimeoutBudget = overrides.TimeoutBudget
+	}
+	if len(overrides.OS) > 0 {
+		spec.OS = overrides.OS
+	}
+	if len(overrides.Architectures) > 0 {
+		spec.Architectures = overrides.Architectures
+	}
+	if len(overrides.Regressions) > 0 {
+		spec.Regressions = overrides.Regressions
+	}
+	if overrides.SLOTarget > 0 {
+		spec.SLOTarget = overrides.SLOTarget
+	}
+	if len(overrides.NotificationChannels) > 0 {
+		spec.NotificationChannels = overrides.NotificationChannels
+	}
+	if len(overrides.Severity) > 0 {
+		spec.Severity = overrides.Severity
+	}
+	spec.Template = ref
+
+	for i := range spec.PodSpec.InitContainers {
+		setEnv(&spec.PodSpec.InitContainers[i], env)
+	}
+	for i := range spec.PodSpec.Containers {
+		setEnv(&spec.PodSpec.Containers[i], env)
+	}
+	spec.Setup = hookWithEnv(spec.Setup, env)
+	spec.Teardown = hookWithEnv(spec.Teardown, env)
+
+	expanded := check
+	expanded.Spec = spec
+	return expanded, nil
+}
+
+// hookWithEnv returns a copy of a setup or teardown hook with the environment variables set on its containers.
+// The hook is copied because it may belong to the check that is being expanded.
+func hookWithEnv(hook *khcheckcrd.Hook, env []apiv1.EnvVar) *khcheckcrd.Hook {
+	if hook == nil {
+		return nil
+	}
+	copied := *hook
+	copied.Containers = make([]apiv1.Container, len(hook.Containers))
+	for i := range hook.Containers {
+		copied.Containers[i] = *hook.Containers[i].DeepCopy()
+		setEnv(&copied.Containers[i], env)
+	}
+	return &copied
+}
+
+// parameterEnv returns the environment variables of the supplied parameter values, sorted by name.  Values for
+// parameters the template does not have and missing values for required parameters are errors.
+func (h KuberhealthyCheckTemplate) parameterEnv(values map[string]string) ([]apiv1.EnvVar, error) {
+	known := make(map[string]bool)
+	var env []apiv1.EnvVar
+	var missing []string
+	for _, p := range h.Spec.Parameters {
+		if len(p.Name) == 0 {
+			return nil, fmt.Errorf("khchecktemplate %s has a parameter without a name", h.Name)
+		}
+		if known[p.Name] {
+			return nil, fmt.Errorf("khchecktemplate %s has more than one parameter named %s", h.Name, p.Name)
+		}
+		known[p.Name] = true
+
+		value, ok := values[p.Name]
+		if !ok {
+			if p.Required {
+				missing = append(missing, p.Name)
+				continue
+			}
+			value = p.Default
+		}
+		env = append(env, apiv1.EnvVar{Name: p.Name, Value: value})
+	}
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("khchecktemplate %s requires parameters %s", h.Name, strings.Join(missing, ", "))
+	}
+
+	var unknown []string
+	for name := range values {
+		if !known[name] {
+			unknown = append(unknown, name)
+		}
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return nil, fmt.Errorf("khchecktemplate %s has no parameters named %s", h.Name, strings.Join(unknown, ", "))
+	}
+
+	sort.Slice(env, func(i, j int) bool { return env[i].Name < env[j].Name })
+	return env, nil
+}
+
+// setEnv sets environment variables on a container, replacing any of the same name
+func setEnv(c *apiv1.Container, env []apiv1.EnvVar) {
+	for _, e := range env {
+		replaced := false
+		for i := range c.Env {
+			if c.Env[i].Name == e.Name {
+				c.Env[i] = e
+				replaced = true
+			}
+		}
+		if !replaced {
+			c.Env = append(c.Env, e)
+		}
+	}
+}
+
+// mergeMaps returns the keys of both maps, with the values of overrides taking precedence
+func mergeMaps(defaults map[string]string, overrides map[string]string) map[string]string {
+	merged := make(map[string]string, len(defaults)+len(overrides))
+	for k, v := range defaults {
+		merged[k] = v
+	}
+	for k, v := range overrides {
+		merged[k] = v
+	}
+	return merged
+}
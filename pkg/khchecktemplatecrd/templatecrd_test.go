@@ -0,0 +1,131 @@
+package khchecktemplatecrd
+
+import (
+	"strings"
+	"testing"
+
+	apiv1 "k8s.io/api/core/v1"
+
+	"github.com/Comcast/kuberhealthy/v2/pkg/khcheckcrd"
+)
+
+// testTemplate returns a template of an HTTP check with a required URL and an optional expected status code
+func testTemplate() KuberhealthyCheckTemplate {
+	return NewKuberhealthyCheckTemplate("http", TemplateSpec{
+		Parameters: []Parameter{
+			{Name: "CHECK_URL", Required: true},
+			{Name: "EXPECTED_STATUS", Default: "200"},
+		},
+		Check: khcheckcrd.CheckConfig{
+			RunInterval: "5m",
+			Timeout:     "2m",
+			ExtraLabels: map[string]string{"owner": "platform", "check": "http"},
+			PodSpec: apiv1.PodSpec{
+				Containers: []apiv1.Container{{
+					Name:  "main",
+					Image: "kuberhealthy/http-check:v1",
+					Env:   []apiv1.EnvVar{{Name: "EXPECTED_STATUS", Value: "000"}, {Name: "COUNT", Value: "3"}},
+				}},
+			},
+		},
+	})
+}
+
+// TestExpand validates that khchecks take their spec from their template, override its defaults, and supply
+// its parameters as environment variables
+func TestExpand(t *testing.T) {
+	template := testTemplate()
+	check := khcheckcrd.NewKuberhealthyCheck("payments-api", "payments", khcheckcrd.CheckConfig{
+		Timeout:     "30s",
+		ExtraLabels: map[string]string{"owner": "payments"},
+		Template:    &khcheckcrd.TemplateRef{Name: "http", Parameters: map[string]string{"CHECK_URL": "http://payments"}},
+	})
+
+	expanded, err := Expand(check, template)
+	if err != nil {
+		t.Fatal(err)
+	}
+	spec := expanded.Spec
+	if expanded.Name != "payments-api" || expanded.Namespace != "payments" || spec.Template == nil {
+		t.Fatal("Expected the expanded check to keep its name, namespace, and template reference but got", expanded)
+	}
+	if spec.RunInterval != "5m" || spec.Timeout != "30s" {
+		t.Fatal("Expected the template's interval and the check's timeout but got", spec.RunInterval, spec.Timeout)
+	}
+	if spec.ExtraLabels["owner"] != "payments" || spec.ExtraLabels["check"] != "http" {
+		t.Fatal("Expected the check's labels to be merged over the template's but got", spec.ExtraLabels)
+	}
+	env := spec.PodSpec.Containers[0].Env
+	expected := []apiv1.EnvVar{{Name: "EXPECTED_STATUS", Value: "200"}, {Name: "COUNT", Value: "3"}, {Name: "CHECK_URL", Value: "http://payments"}}
+	if len(env) != len(expected) {
+		t.Fatal("Expected the parameters to be set as environment variables but got", env)
+	}
+	for i := range expected {
+		if env[i] != expected[i] {
+			t.Fatal("Expected the parameters to be set as environment variables but got", env)
+		}
+	}
+
+	// expanding must not change the template
+	if template.Spec.Check.PodSpec.Containers[0].Env[0].Value != "000" || len(template.Spec.Check.ExtraLabels) != 2 {
+		t.Fatal("Expected the template to be left unchanged but got", template.Spec.Check)
+	}
+}
+
+// TestExpandHooks validates that the setup and teardown hooks of khchecks and their templates are given the
+// template's parameters without changing the khcheck
+func TestExpandHooks(t *testing.T) {
+	template := testTemplate()
+	template.Spec.Check.Teardown = &khcheckcrd.Hook{Containers: []apiv1.Container{{Name: "clean", Image: "busybox"}}}
+	setup := &khcheckcrd.Hook{Containers: []apiv1.Container{{Name: "create", Image: "busybox"}}}
+	check := khcheckcrd.NewKuberhealthyCheck("payments-api", "payments", khcheckcrd.CheckConfig{
+		Setup:    setup,
+		Template: &khcheckcrd.TemplateRef{Name: "http", Parameters: map[string]string{"CHECK_URL": "http://payments"}},
+	})
+
+	expanded, err := Expand(check, template)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, hook := range []*khcheckcrd.Hook{expanded.Spec.Setup, expanded.Spec.Teardown} {
+		if hook == nil || len(hook.Containers) != 1 {
+			t.Fatal("Expected the expanded check to have both hooks but got", expanded.Spec.Setup, expanded.Spec.Teardown)
+		}
+		var found bool
+		for _, e := range hook.Containers[0].Env {
+			found = found || (e.Name == "CHECK_URL" && e.Value == "http://payments")
+		}
+		if !found {
+			t.Fatal("Expected the parameters to be set on the hook containers but got", hook.Containers[0].Env)
+		}
+	}
+	if len(setup.Containers[0].Env) != 0 {
+		t.Fatal("Expected the check's own setup hook to be left unchanged but got", setup.Containers[0].Env)
+	}
+}
+
+// TestExpandInvalid validates that khchecks with missing or unknown parameters or their own pod spec are rejected
+func TestExpandInvalid(t *testing.T) {
+	template := testTemplate()
+	tests := map[string]khcheckcrd.CheckConfig{
+		"requires parameters CHECK_URL": {
+			Template: &khcheckcrd.TemplateRef{Name: "http"},
+		},
+		"has no parameters named TARGET": {
+			Template: &khcheckcrd.TemplateRef{Name: "http", Parameters: map[string]string{"CHECK_URL": "http://payments", "TARGET": "payments"}},
+		},
+		"can not set their own podSpec": {
+			Template: &khcheckcrd.TemplateRef{Name: "http", Parameters: map[string]string{"CHECK_URL": "http://payments"}},
+			PodSpec:  apiv1.PodSpec{Containers: []apiv1.Container{{Name: "main", Image: "busybox"}}},
+		},
+		"does not reference khchecktemplate http": {
+			Template: &khcheckcrd.TemplateRef{Name: "dns"},
+		},
+	}
+	for message, spec := range tests {
+		_, err := Expand(khcheckcrd.NewKuberhealthyCheck("payments-api", "payments", spec), template)
+		if err == nil || !strings.Contains(err.Error(), message) {
+			t.Fatal("Expected an error containing", message, "but got", err)
+		}
+	}
+}
@@ -0,0 +1,177 @@
+// Copyright 2018 Comcast Cable Communications Management, LLC
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//     http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package khconfig loads the operator-wide settings of Kuberhealthy from a YAML file, usually a mounted
+// ConfigMap, and watches the file so that changed settings are picked up without restarting Kuberhealthy.
+package khconfig
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"time"
+
+	"github.com/ghodss/yaml"
+	log "github.com/sirupsen/logrus"
+	apiv1 "k8s.io/api/core/v1"
+
+	"github.com/Comcast/kuberhealthy/v2/pkg/notify"
+)
+
+// Config is the operator-wide configuration of Kuberhealthy.  Settings left out of the file keep the value set
+// by their flag or environment variable.
+type Config struct {
+	ListenAddress        string         `json:"listenAddress,omitempty"`        // only read at startup
+	LogLevel             string         `json:"logLevel,omitempty"`             // such as debug or info
+	DefaultRunInterval   *Duration      `json:"defaultRunInterval,omitempty"`   // for khchecks with an unparsable runInterval
+	DefaultTimeout       *Duration      `json:"defaultTimeout,omitempty"`       // for khchecks without a timeout
+	Notifications        *notify.Config `json:"notifications,omitempty"`        // the chat webhooks notified of changes to the health of checks
+	NotificationThrottle *Duration      `json:"notificationThrottle,omitempty"` // the least time between two notifications about the same check
+	NotificationRenotify *Duration      `json:"notificationRenotify,omitempty"` // how often checks that remain failing are notified again
+	PodDefaults          *PodDefaults   `json:"podDefaults,omitempty"`          // applied to every checker pod
+}
+
+// PodDefaults are the settings applied to every checker pod.  Settings in a khcheck take precedence.
+type PodDefaults struct {
+	Labels            map[string]string  `json:"labels,omitempty"`
+	Annotations       map[string]string  `json:"annotations,omitempty"`
+	NodeSelector      map[string]string  `json:"nodeSelector,omitempty"`
+	Tolerations       []apiv1.Toleration `json:"tolerations,omitempty"`
+	PriorityClassName string             `json:"priorityClassName,omitempty"`
+	DeleteGracePeriod *Duration          `json:"deleteGracePeriod,omitempty"`
+	ForceDeleteAfter  *Duration          `json:"forceDeleteAfter,omitempty"`
+}
+
+// Duration is a time.Duration written in the config file as a string, such as 5m
+type Duration struct {
+	time.Duration
+}
+
+// UnmarshalJSON parses a duration string
+func (d *Duration) UnmarshalJSON(b []byte) error {
+	var s string
+	err := json.Unmarshal(b, &s)
+	if err != nil {
+		return errors.New("durations must be strings such as 5m")
+	}
+	d.Duration, err = time.ParseDuration(s)
+	return err
+}
+
+// MarshalJSON writes a duration as a string
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.Duration.String())
+}
+
+// Load reads and validates a config file
+func Load(path string) (Config, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return Config{}, fmt.Errorf("error reading config file: %w", err)
+	}
+	return parse(b)
+}
+
+// parse unmarshals and validates the contents of a config file
+func parse(b []byte) (Config, error) {
+	var config Config
+	err := yaml.Unmarshal(b, &config)
+	if err != nil {
+		return config, fmt.Errorf("error parsing config file: %w", err)
+	}
+	if len(config.LogLevel) > 0 {
+		_, err = log.ParseLevel(config.LogLevel)
+		if err != nil {
+			return config, fmt.Errorf("error parsing logLevel in config file: %w", err)
+		}
+	}
+	if config.DefaultRunInterval != nil && config.DefaultRunInterval.Duration <= 0 {
+		return config, errors.New("defaultRunInterval in config file must be greater than zero")
+	}
+	if config.DefaultTimeout != nil && config.DefaultTimeout.Duration <= 0 {
+		return config, errors.New("defaultTimeout in config file must be greater than zero")
+	}
+	return config, nil
+}
+
+// Watcher reloads a config file whenever its contents change.  The file is polled rather than watched for
+// events, because the files of a mounted ConfigMap are replaced by swapping a symlink to their directory.
+type Watcher struct {
+	Path string
+
+	contents []byte
+}
+
+// NewWatcher loads a config file and creates a watcher that reloads it when it changes
+func NewWatcher(path string) (*Watcher, Config, error) {
+	w := &Watcher{
+		Path: path,
+	}
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return w, Config{}, fmt.Errorf("error reading config file: %w", err)
+	}
+	config, err := parse(b)
+	if err != nil {
+		return w, config, err
+	}
+	w.contents = b
+	return w, config, nil
+}
+
+// Reload reads the config file and returns its config and true if its contents changed since they were last
+// loaded.  A file that fails to load is tried again on the next reload.
+func (w *Watcher) Reload() (Config, bool, error) {
+	b, err := ioutil.ReadFile(w.Path)
+	if err != nil {
+		return Config{}, false, fmt.Errorf("error reading config file: %w", err)
+	}
+	if bytes.Equal(b, w.contents) {
+		return Config{}, false, nil
+	}
+	config, err := parse(b)
+	if err != nil {
+		return config, false, err
+	}
+	w.contents = b
+	return config, true, nil
+}
+
+// Watch reloads the config file every interval and sends its config to the changed channel whenever its
+// contents change, until the context is canceled
+func (w *Watcher) Watch(ctx context.Context, interval time.Duration, changed chan<- Config) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			config, reloaded, err := w.Reload()
+			if err != nil {
+				log.Errorln("config: failed to reload config file", w.Path+":", err)
+				continue
+			}
+			if !reloaded {
+				continue
+			}
+			log.Infoln("config: reloaded config file", w.Path)
+			select {
+			case changed <- config:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,105 @@
+package khconfig
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// TestLoad validates that settings are parsed from a config file and invalid settings are rejected
+func TestLoad(t *testing.T) {
+	dir, err := ioutil.TempDir("", "khconfig")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "config.yaml")
+
+	err = ioutil.WriteFile(path, []byte(`
+logLevel: debug
+defaultTimeout: 2m
+notifications:
+  webhooks:
+  - name: team
+    type: generic
+    url: http://example.com/hook
+podDefaults:
+  labels:
+    team: platform
+  tolerations:
+  - key: dedicated
+    value: checks
+    effect: NoSchedule
+  deleteGracePeriod: 5s
+`), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.LogLevel != "debug" || c.DefaultTimeout.Duration != time.Minute*2 || c.DefaultRunInterval != nil {
+		t.Fatal("Expected the log level and default timeout to be set but got", c)
+	}
+	if c.Notifications == nil || len(c.Notifications.Webhooks) != 1 || c.Notifications.Webhooks[0].Name != "team" {
+		t.Fatal("Expected one webhook but got", c.Notifications)
+	}
+	p := c.PodDefaults
+	if p == nil || p.Labels["team"] != "platform" || len(p.Tolerations) != 1 || p.Tolerations[0].Key != "dedicated" || p.DeleteGracePeriod.Duration != time.Second*5 || p.ForceDeleteAfter != nil {
+		t.Fatal("Expected the pod defaults to be set but got", p)
+	}
+
+	for _, invalid := range []string{"logLevel: loud", "defaultTimeout: soon", "defaultRunInterval: 0s", "defaultTimeout: 300"} {
+		_, err = parse([]byte(invalid))
+		if err == nil {
+			t.Fatal("Expected an error parsing", invalid)
+		}
+	}
+}
+
+// TestReload validates that the config is only reloaded when the contents of the file change
+func TestReload(t *testing.T) {
+	dir, err := ioutil.TempDir("", "khconfig")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "config.yaml")
+	err = ioutil.WriteFile(path, []byte("logLevel: info\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w, c, err := NewWatcher(path)
+	if err != nil || c.LogLevel != "info" {
+		t.Fatal("Expected the config to load but got", c, err)
+	}
+	_, reloaded, err := w.Reload()
+	if err != nil || reloaded {
+		t.Fatal("Expected an unchanged file not to be reloaded but got", reloaded, err)
+	}
+
+	// invalid contents are reported and keep being retried until they are fixed
+	err = ioutil.WriteFile(path, []byte("logLevel: loud\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		_, reloaded, err = w.Reload()
+		if err == nil || reloaded {
+			t.Fatal("Expected an invalid file to fail to reload but got", reloaded, err)
+		}
+	}
+
+	err = ioutil.WriteFile(path, []byte("logLevel: debug\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, reloaded, err = w.Reload()
+	if err != nil || !reloaded || c.LogLevel != "debug" {
+		t.Fatal("Expected the changed file to be reloaded but got", c, reloaded, err)
+	}
+}
@@ -0,0 +1,62 @@
+// Copyright 2018 Comcast Cable Communications Management, LLC
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//     http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package khsilencecrd
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest"
+)
+
+// KuberhealthySilenceClient holds client data for talking to Kubernetes about
+// the khsilence custom resource
+type KuberhealthySilenceClient struct {
+	restClient rest.Interface
+	ns         string
+}
+
+// Create creates a new resource for this CRD
+func (c *KuberhealthySilenceClient) Create(silence *KuberhealthySilence, resource string, namespace string) (*KuberhealthySilence, error) {
+	result := KuberhealthySilence{}
+	err := c.restClient.
+		Post().
+		Namespace(namespace).
+		Resource(resource).
+		Body(silence).
+		Do().
+		Into(&result)
+	return &result, err
+}
+
+// Delete deletes a resource for this CRD
+func (c *KuberhealthySilenceClient) Delete(resource string, name string, namespace string) error {
+	return c.restClient.
+		Delete().
+		Namespace(namespace).
+		Resource(resource).
+		Name(name).
+		Do().
+		Error()
+}
+
+// List lists resources for this CRD
+func (c *KuberhealthySilenceClient) List(opts metav1.ListOptions, resource string, namespace string) (*KuberhealthySilenceList, error) {
+	result := KuberhealthySilenceList{}
+	err := c.restClient.
+		Get().
+		Namespace(namespace).
+		Resource(resource).
+		VersionedParams(&opts, scheme.ParameterCodec).
+		Do().
+		Into(&result)
+	return &result, err
+}
@@ -0,0 +1,103 @@
+// Copyright 2018 Comcast Cable Communications Management, LLC
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//     http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package khsilencecrd
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	"github.com/Comcast/kuberhealthy/v2/pkg/health"
+)
+
+// KuberhealthySilence represents the data in the CRD for silencing a
+// failing check until it expires
+type KuberhealthySilence struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+	Spec              SilenceSpec `json:"spec"`
+}
+
+// SilenceSpec is the check a silence applies to, along with who silenced it,
+// why, and until when.  Silences apply to the check of the same name in the
+// namespace of the silence.
+type SilenceSpec struct {
+	Check   string    `json:"check"`   // the name of the silenced check
+	Reason  string    `json:"reason"`  // why the check was silenced, such as a link to the incident
+	Author  string    `json:"author"`  // who silenced the check
+	Expires time.Time `json:"expires"` // when the silence stops applying
+}
+
+// String satisfies the stringer interface for cleaner output when printing
+func (h KuberhealthySilence) String() string {
+	b, err := json.MarshalIndent(&h, "", "\t")
+	if err != nil {
+		logrus.Errorln("Failed to marshal KuberhealthySilence in a nice format:", err)
+	}
+	return string(b)
+}
+
+// DeepCopyInto copies all properties of this object into another object of the
+// same type that is provided as a pointer.
+func (h KuberhealthySilence) DeepCopyInto(out *KuberhealthySilence) {
+	out.TypeMeta = h.TypeMeta
+	out.ObjectMeta = h.ObjectMeta
+	out.Spec = h.Spec
+}
+
+// DeepCopyObject returns a generically typed copy of an object
+func (h KuberhealthySilence) DeepCopyObject() runtime.Object {
+	out := KuberhealthySilence{}
+	h.DeepCopyInto(&out)
+	return &out
+}
+
+// NewKuberhealthySilence creates a KuberhealthySilence struct which represents
+// the data inside a KuberhealthySilence resource
+func NewKuberhealthySilence(name string, namespace string, spec SilenceSpec) KuberhealthySilence {
+	silence := KuberhealthySilence{}
+	silence.ObjectMeta.Name = name
+	silence.ObjectMeta.Namespace = namespace
+	silence.Spec = spec
+	return silence
+}
+
+// Active returns true if the silence still applies at the supplied time
+func (h KuberhealthySilence) Active(now time.Time) bool {
+	return now.Before(h.Spec.Expires)
+}
+
+// ActiveSilences returns the silences that apply at the supplied time, keyed
+// by the namespace/name of the check they silence.  When a check has several
+// silences, the one that expires last is returned.
+func ActiveSilences(silences []KuberhealthySilence, now time.Time) map[string]health.Silence {
+	active := make(map[string]health.Silence)
+	for _, s := range silences {
+		if !s.Active(now) {
+			continue
+		}
+		key := s.Namespace + "/" + s.Spec.Check
+		if existing, ok := active[key]; ok && existing.Expires.After(s.Spec.Expires) {
+			continue
+		}
+		active[key] = health.Silence{
+			Name:    s.Name,
+			Reason:  s.Spec.Reason,
+			Author:  s.Spec.Author,
+			Expires: s.Spec.Expires,
+		}
+	}
+	return active
+}
@@ -0,0 +1,31 @@
+package khsilencecrd
+
+import (
+	"testing"
+	"time"
+)
+
+// TestActiveSilences validates that only unexpired silences apply and that the longest silence of a check wins
+func TestActiveSilences(t *testing.T) {
+	now := time.Date(2020, 4, 2, 18, 0, 0, 0, time.UTC)
+	silences := []KuberhealthySilence{
+		NewKuberhealthySilence("deployment-1", "kuberhealthy", SilenceSpec{Check: "deployment", Reason: "INC-1", Author: "oncall", Expires: now.Add(time.Hour)}),
+		NewKuberhealthySilence("deployment-2", "kuberhealthy", SilenceSpec{Check: "deployment", Reason: "INC-2", Author: "oncall", Expires: now.Add(time.Hour * 2)}),
+		NewKuberhealthySilence("dns-1", "kuberhealthy", SilenceSpec{Check: "dns", Reason: "INC-0", Author: "oncall", Expires: now.Add(-time.Minute)}),
+		NewKuberhealthySilence("dns-2", "other", SilenceSpec{Check: "dns", Reason: "maintenance", Author: "platform", Expires: now.Add(time.Minute)}),
+	}
+
+	active := ActiveSilences(silences, now)
+	if len(active) != 2 {
+		t.Fatal("Expected two checks to be silenced but got", active)
+	}
+	if active["kuberhealthy/deployment"].Name != "deployment-2" || active["kuberhealthy/deployment"].Reason != "INC-2" {
+		t.Fatal("Expected the silence that expires last to apply but got", active["kuberhealthy/deployment"])
+	}
+	if _, ok := active["kuberhealthy/dns"]; ok {
+		t.Fatal("Expected the expired silence not to apply")
+	}
+	if active["other/dns"].Author != "platform" {
+		t.Fatal("Expected silences to apply to the check in their own namespace but got", active)
+	}
+}
@@ -0,0 +1,158 @@
+// Package khtls loads the TLS certificates used by the Kuberhealthy report-in
+// listener and reloads them whenever the files on disk change, such as when a
+// mounted Secret is rotated.
+package khtls
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Reloader serves the most recently loaded certificate, key, and client CA bundle
+type Reloader struct {
+	CertFile string // the PEM encoded server certificate
+	KeyFile  string // the PEM encoded server key
+	CAFile   string // the PEM encoded CA bundle used to verify client certificates.  mTLS is disabled when blank.
+
+	mu      sync.RWMutex
+	cert    *tls.Certificate
+	caPool  *x509.CertPool
+	certPEM []byte
+	keyPEM  []byte
+	caPEM   []byte
+}
+
+// NewReloader creates a reloader and loads the certificates from disk for the first time
+func NewReloader(certFile string, keyFile string, caFile string) (*Reloader, error) {
+	r := &Reloader{
+		CertFile: certFile,
+		KeyFile:  keyFile,
+		CAFile:   caFile,
+	}
+	_, err := r.Reload()
+	return r, err
+}
+
+// Reload reads the certificate files from disk and swaps them in if they changed.  Returns true
+// if new certificates were loaded.
+func (r *Reloader) Reload() (bool, error) {
+	certPEM, err := ioutil.ReadFile(r.CertFile)
+	if err != nil {
+		return false, fmt.Errorf("error reading tls certificate: %w", err)
+	}
+	keyPEM, err := ioutil.ReadFile(r.KeyFile)
+	if err != nil {
+		return false, fmt.Errorf("error reading tls key: %w", err)
+	}
+	var caPEM []byte
+	if len(r.CAFile) > 0 {
+		caPEM, err = ioutil.ReadFile(r.CAFile)
+		if err != nil {
+			return false, fmt.Errorf("error reading tls client CA bundle: %w", err)
+		}
+	}
+
+	// skip parsing if nothing changed since the last load
+	r.mu.RLock()
+	unchanged := r.cert != nil && bytes.Equal(certPEM, r.certPEM) && bytes.Equal(keyPEM, r.keyPEM) && bytes.Equal(caPEM, r.caPEM)
+	r.mu.RUnlock()
+	if unchanged {
+		return false, nil
+	}
+
+	cert, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		return false, fmt.Errorf("error parsing tls key pair: %w", err)
+	}
+	var caPool *x509.CertPool
+	if len(caPEM) > 0 {
+		caPool = x509.NewCertPool()
+		if !caPool.AppendCertsFromPEM(caPEM) {
+			return false, errors.New("no certificates found in tls client CA bundle")
+		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.cert = &cert
+	r.caPool = caPool
+	r.certPEM = certPEM
+	r.keyPEM = keyPEM
+	r.caPEM = caPEM
+	return true, nil
+}
+
+// Watch reloads the certificates on the supplied interval until the context is canceled.  Errors
+// are logged and the previously loaded certificates stay in use.
+func (r *Reloader) Watch(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			reloaded, err := r.Reload()
+			if err != nil {
+				log.Errorln("tls: failed to reload certificates:", err)
+				continue
+			}
+			if reloaded {
+				log.Infoln("tls: reloaded certificates from", r.CertFile)
+			}
+		}
+	}
+}
+
+// MutualTLS returns true if client certificates are verified against a CA bundle
+func (r *Reloader) MutualTLS() bool {
+	return len(r.CAFile) > 0
+}
+
+// CABundle returns the PEM encoded client CA bundle that was last loaded
+func (r *Reloader) CABundle() []byte {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.caPEM
+}
+
+// ClientCertificate returns the most recently loaded certificate so that connections to other servers
+// that verify client certificates present it.  It satisfies tls.Config.GetClientCertificate.
+func (r *Reloader) ClientCertificate(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.cert, nil
+}
+
+// ServerConfig returns a TLS config that always serves the most recently loaded certificates.  When
+// requireClientCert is false, client certificates are verified if presented but not required.
+func (r *Reloader) ServerConfig(requireClientCert bool) *tls.Config {
+	return &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			r.mu.RLock()
+			defer r.mu.RUnlock()
+			c := &tls.Config{
+				MinVersion:   tls.VersionTLS12,
+				Certificates: []tls.Certificate{*r.cert},
+			}
+			if r.caPool != nil {
+				c.ClientCAs = r.caPool
+				c.ClientAuth = tls.VerifyClientCertIfGiven
+				if requireClientCert {
+					c.ClientAuth = tls.RequireAndVerifyClientCert
+				}
+			}
+			return c, nil
+		},
+	}
+}
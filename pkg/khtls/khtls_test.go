@@ -0,0 +1,126 @@
+package khtls
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// newCert creates a certificate signed by parent, or a self-signed CA if parent is nil
+func newCert(t *testing.T, name string, parent *x509.Certificate, parentKey *ecdsa.PrivateKey) (*x509.Certificate, *ecdsa.PrivateKey, []byte, []byte) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Minute),
+		NotAfter:     time.Now().Add(time.Hour),
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	if parent == nil {
+		template.IsCA = true
+		template.KeyUsage = x509.KeyUsageCertSign
+		template.BasicConstraintsValid = true
+		parent, parentKey = template, key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, parentKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+	keyDER, _ := x509.MarshalECPrivateKey(key)
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+	return cert, key, certPEM, keyPEM
+}
+
+func TestMutualTLSAndReload(t *testing.T) {
+	dir, err := ioutil.TempDir("", "khtls")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	ca, caKey, caPEM, _ := newCert(t, "ca", nil, nil)
+	_, _, serverPEM, serverKeyPEM := newCert(t, "server", ca, caKey)
+	_, _, clientPEM, clientKeyPEM := newCert(t, "client", ca, caKey)
+
+	certFile := filepath.Join(dir, "tls.crt")
+	keyFile := filepath.Join(dir, "tls.key")
+	caFile := filepath.Join(dir, "ca.crt")
+	ioutil.WriteFile(certFile, serverPEM, 0600)
+	ioutil.WriteFile(keyFile, serverKeyPEM, 0600)
+	ioutil.WriteFile(caFile, caPEM, 0600)
+
+	r, err := NewReloader(certFile, keyFile, caFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !r.MutualTLS() {
+		t.Fatal("expected mutual tls to be enabled")
+	}
+
+	listener, err := tls.Listen("tcp", "127.0.0.1:0", r.ServerConfig(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			conn.(*tls.Conn).Handshake()
+			conn.Close()
+		}
+	}()
+
+	roots := x509.NewCertPool()
+	roots.AppendCertsFromPEM(caPEM)
+	clientCert, _ := tls.X509KeyPair(clientPEM, clientKeyPEM)
+
+	// a client with a certificate signed by the CA is accepted
+	conn, err := tls.Dial("tcp", listener.Addr().String(), &tls.Config{RootCAs: roots, Certificates: []tls.Certificate{clientCert}})
+	if err != nil {
+		t.Fatal("expected handshake with client certificate to succeed:", err)
+	}
+	conn.Close()
+
+	// a client without a certificate is refused
+	conn, err = tls.Dial("tcp", listener.Addr().String(), &tls.Config{RootCAs: roots})
+	if err == nil {
+		_, err = conn.Read(make([]byte, 1))
+		conn.Close()
+	}
+	if err == nil {
+		t.Fatal("expected handshake without client certificate to fail")
+	}
+
+	// unchanged files are not reloaded, rotated files are
+	reloaded, err := r.Reload()
+	if err != nil || reloaded {
+		t.Fatal("expected no reload for unchanged files", err)
+	}
+	_, _, newServerPEM, newServerKeyPEM := newCert(t, "server", ca, caKey)
+	ioutil.WriteFile(certFile, newServerPEM, 0600)
+	ioutil.WriteFile(keyFile, newServerKeyPEM, 0600)
+	reloaded, err = r.Reload()
+	if err != nil || !reloaded {
+		t.Fatal("expected rotated certificate to be reloaded", err)
+	}
+}
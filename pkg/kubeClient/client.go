@@ -15,11 +15,29 @@ import (
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/clientcmd"
+	"k8s.io/client-go/util/flowcontrol"
 )
 
 // Create returns a kubernetes api clientset that enables communication with
 // the kubernetes API via the internal service.
 func Create(kubeConfigFile string) (*kubernetes.Clientset, error) {
+	return CreateWithRateLimiter(kubeConfigFile, nil)
+}
+
+// CreateWithRateLimiter returns a kubernetes api clientset that makes its requests through the supplied rate
+// limiter instead of the default client-go QPS and burst.  A nil rate limiter uses the client-go defaults.
+func CreateWithRateLimiter(kubeConfigFile string, rateLimiter flowcontrol.RateLimiter) (*kubernetes.Clientset, error) {
+	kubeconfig, err := Config(kubeConfigFile)
+	if err != nil {
+		return nil, err
+	}
+	kubeconfig.RateLimiter = rateLimiter
+	return kubernetes.NewForConfig(kubeconfig)
+}
+
+// Config returns the config used to reach the kubernetes api, for clients that are not built from a clientset
+// such as those that exec into pods
+func Config(kubeConfigFile string) (*rest.Config, error) {
 	kubeconfig, err := rest.InClusterConfig()
 	if err != nil {
 		// If not in cluster, use kube config file
@@ -28,5 +46,5 @@ func Create(kubeConfigFile string) (*kubernetes.Clientset, error) {
 			return nil, err
 		}
 	}
-	return kubernetes.NewForConfig(kubeconfig)
+	return kubeconfig, nil
 }
@@ -50,17 +50,15 @@ func getEnvVar(v string) (string, error) {
 	return envVar, err
 }
 
-// CalculateMaster determines which kuberhealthy pod should assume the master role
-func CalculateMaster(client *kubernetes.Clientset) (string, error) {
-
-	log.Debugln("Calculating current master...")
+// RunningPods returns the names of the running kuberhealthy pods in alphabetical order
+func RunningPods(client kubernetes.Interface) ([]string, error) {
 
 	// get a list of all kuberhealthy pods
 	pods, err := client.CoreV1().Pods(namespace).List(metav1.ListOptions{
 		LabelSelector: "app=kuberhealthy", FieldSelector: "status.phase=Running",
 	})
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
 	// create a slice of all kuberhealthy pod names for use in sort
@@ -70,12 +68,24 @@ func CalculateMaster(client *kubernetes.Clientset) (string, error) {
 	}
 
 	if len(podlist) < 1 {
-		return "", errors.New("Failed to retrieve list of Kuberhealthy pods")
+		return nil, errors.New("Failed to retrieve list of Kuberhealthy pods")
+	}
+	sort.Strings(podlist)
+	return podlist, nil
+}
+
+// CalculateMaster determines which kuberhealthy pod should assume the master role
+func CalculateMaster(client kubernetes.Interface) (string, error) {
+
+	log.Debugln("Calculating current master...")
+
+	podlist, err := RunningPods(client)
+	if err != nil {
+		return "", err
 	}
 
 	// choose master by grabbing the first in alphabetical order based on
 	// the pod name
-	sort.Strings(podlist)
 	master := podlist[0]
 
 	log.Debugln("Calculated master as", master)
@@ -83,7 +93,7 @@ func CalculateMaster(client *kubernetes.Clientset) (string, error) {
 }
 
 // IAmMaster determines if the executing pod is the cluster master or not
-func IAmMaster(client *kubernetes.Clientset) (bool, error) {
+func IAmMaster(client kubernetes.Interface) (bool, error) {
 
 	// if we are in debug enable master always, then just return true
 	if enableForceMaster {
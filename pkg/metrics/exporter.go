@@ -14,14 +14,32 @@ package metrics // import "github.com/Comcast/kuberhealthy/v2/pkg/metrics"
 import (
 	"fmt"
 	"net/http"
+	"strconv"
 	"time"
 
 	log "github.com/sirupsen/logrus"
 
 	"github.com/Comcast/kuberhealthy/v2/pkg/health"
+	"github.com/Comcast/kuberhealthy/v2/pkg/ratelimit"
+	"github.com/Comcast/kuberhealthy/v2/pkg/scheduler"
+	"github.com/Comcast/kuberhealthy/v2/pkg/usage"
 )
 
-//GenerateMetrics takes the state and returns it in the Prometheus format
+// ClusterState is the most recent state of a single cluster in a federation of Kuberhealthy instances
+type ClusterState struct {
+	Cluster   string
+	Reachable bool
+	State     health.State
+}
+
+// CheckSchedule is the scheduling state of a single check
+type CheckSchedule struct {
+	Check     string
+	Namespace string
+	Stats     scheduler.Stats
+}
+
+// GenerateMetrics takes the state and returns it in the Prometheus format
 func GenerateMetrics(state health.State) string {
 	metricsOutput := ""
 	healthStatus := "0"
@@ -36,22 +54,56 @@ func GenerateMetrics(state health.State) string {
 	metricsOutput += fmt.Sprintf("kuberhealthy_cluster_state %s\n", healthStatus)
 	metricsOutput += "# HELP kuberhealthy_check Shows the status of a Kuberhealthy check\n"
 	metricsOutput += "# TYPE kuberhealthy_check gauge\n"
+	metricsOutput += "# HELP kuberhealthy_check_unknown Shows if the health of a Kuberhealthy check is unknown because its last result outlived its result TTL\n"
+	metricsOutput += "# TYPE kuberhealthy_check_unknown gauge\n"
 	metricsOutput += "# HELP kuberhealthy_check_duration_seconds Shows the check run duration of a Kuberhealthy check\n"
 	metricsOutput += "# TYPE kuberhealthy_check_duration_seconds gauge\n"
+	metricsOutput += "# HELP kuberhealthy_check_availability_percent Shows the percentage of successful runs of a Kuberhealthy check over a rolling window\n"
+	metricsOutput += "# TYPE kuberhealthy_check_availability_percent gauge\n"
+	metricsOutput += "# HELP kuberhealthy_check_error_budget_remaining_percent Shows the percentage of the error budget of a Kuberhealthy check left over a rolling window\n"
+	metricsOutput += "# TYPE kuberhealthy_check_error_budget_remaining_percent gauge\n"
+	metricsOutput += "# HELP kuberhealthy_check_architecture Shows the status of the latest run of a Kuberhealthy check on nodes of each CPU architecture\n"
+	metricsOutput += "# TYPE kuberhealthy_check_architecture gauge\n"
+	metricsOutput += "# HELP kuberhealthy_check_measurement Shows the numeric measurements reported with the last run of a Kuberhealthy check\n"
+	metricsOutput += "# TYPE kuberhealthy_check_measurement gauge\n"
 	checkMetricState := map[string]string{}
 	for c, d := range state.CheckDetails {
-		checkStatus := "0"
-		if d.OK {
-			checkStatus = "1"
+		// checks whose health is unknown are neither passing nor failing, so they have no status
+		unknownName := fmt.Sprintf("kuberhealthy_check_unknown{check=\"%s\",namespace=\"%s\"}", c, d.Namespace)
+		checkMetricState[unknownName] = strconv.Itoa(boolToInt(d.Unknown))
+		if !d.Unknown {
+			checkStatus := "0"
+			if d.OK {
+				checkStatus = "1"
+			}
+			metricName := fmt.Sprintf("kuberhealthy_check{check=\"%s\",namespace=\"%s\",status=\"%s\"}", c, d.Namespace, checkStatus)
+			checkMetricState[metricName] = checkStatus
 		}
-		metricName := fmt.Sprintf("kuberhealthy_check{check=\"%s\",namespace=\"%s\",status=\"%s\"}", c, d.Namespace, checkStatus)
 		metricDurationName := fmt.Sprintf("kuberhealthy_check_duration_seconds{check=\"%s\",namespace=\"%s\"}", c, d.Namespace)
-		checkMetricState[metricName] = checkStatus
 		runDuration, err := time.ParseDuration(d.RunDuration)
 		if err != nil {
 			log.Errorln("Error parsing run duration", err)
 		}
 		checkMetricState[metricDurationName] = fmt.Sprintf("%f", runDuration.Seconds())
+		for _, a := range d.Availability {
+			availabilityName := fmt.Sprintf("kuberhealthy_check_availability_percent{check=\"%s\",namespace=\"%s\",window=\"%s\"}", c, d.Namespace, a.Window)
+			errorBudgetName := fmt.Sprintf("kuberhealthy_check_error_budget_remaining_percent{check=\"%s\",namespace=\"%s\",window=\"%s\"}", c, d.Namespace, a.Window)
+			checkMetricState[availabilityName] = fmt.Sprintf("%f", a.Percent)
+			checkMetricState[errorBudgetName] = fmt.Sprintf("%f", a.ErrorBudgetRemaining)
+		}
+		for arch, r := range d.Architectures {
+			archStatus := "0"
+			if r.OK {
+				archStatus = "1"
+			}
+			architectureName := fmt.Sprintf("kuberhealthy_check_architecture{check=\"%s\",namespace=\"%s\",architecture=\"%s\",status=\"%s\"}", c, d.Namespace, arch, archStatus)
+			checkMetricState[architectureName] = archStatus
+		}
+		for name, v := range d.Measurements {
+			// measurement names are chosen by checker pods, so they are quoted to escape them as label values
+			measurementName := fmt.Sprintf("kuberhealthy_check_measurement{check=\"%s\",namespace=\"%s\",measurement=%q}", c, d.Namespace, name)
+			checkMetricState[measurementName] = fmt.Sprintf("%f", v)
+		}
 	}
 	for m, v := range checkMetricState {
 		metricsOutput += fmt.Sprintf("%s %s\n", m, v)
@@ -59,7 +111,109 @@ func GenerateMetrics(state health.State) string {
 	return metricsOutput
 }
 
-//ErrorStateMetrics is a Prometheus metric meant to show Kuberhealthy has error
+// GenerateScheduleMetrics returns the scheduling statistics of checks in the Prometheus format
+func GenerateScheduleMetrics(schedules []CheckSchedule) string {
+	metricsOutput := ""
+	metricsOutput += "# HELP kuberhealthy_check_schedule_drift_seconds Shows how late the most recent scheduled run of a Kuberhealthy check started\n"
+	metricsOutput += "# TYPE kuberhealthy_check_schedule_drift_seconds gauge\n"
+	for _, s := range schedules {
+		metricsOutput += fmt.Sprintf("kuberhealthy_check_schedule_drift_seconds{check=\"%s\",namespace=\"%s\"} %f\n", s.Check, s.Namespace, s.Stats.Drift.Seconds())
+	}
+	metricsOutput += "# HELP kuberhealthy_check_skipped_runs_total Shows the number of scheduled runs of a Kuberhealthy check skipped because a previous run overran them\n"
+	metricsOutput += "# TYPE kuberhealthy_check_skipped_runs_total counter\n"
+	for _, s := range schedules {
+		metricsOutput += fmt.Sprintf("kuberhealthy_check_skipped_runs_total{check=\"%s\",namespace=\"%s\"} %d\n", s.Check, s.Namespace, s.Stats.SkippedRuns)
+	}
+	metricsOutput += "# HELP kuberhealthy_check_run_interval_seconds Shows the current run interval of a Kuberhealthy check, including any failure backoff\n"
+	metricsOutput += "# TYPE kuberhealthy_check_run_interval_seconds gauge\n"
+	for _, s := range schedules {
+		metricsOutput += fmt.Sprintf("kuberhealthy_check_run_interval_seconds{check=\"%s\",namespace=\"%s\"} %f\n", s.Check, s.Namespace, s.Stats.Interval.Seconds())
+	}
+	return metricsOutput
+}
+
+// GenerateRateLimitMetrics returns how many calls to the Kubernetes API were made and throttled by each rate
+// limiter in the Prometheus format
+func GenerateRateLimitMetrics(stats []ratelimit.Stats) string {
+	metricsOutput := ""
+	metricsOutput += "# HELP kuberhealthy_rate_limited_calls_total Shows the number of calls made through a Kuberhealthy rate limiter\n"
+	metricsOutput += "# TYPE kuberhealthy_rate_limited_calls_total counter\n"
+	for _, s := range stats {
+		metricsOutput += fmt.Sprintf("kuberhealthy_rate_limited_calls_total{limiter=\"%s\",operation=\"%s\"} %d\n", s.Limiter, s.Operation, s.Calls)
+	}
+	metricsOutput += "# HELP kuberhealthy_throttled_calls_total Shows the number of calls that waited for a Kuberhealthy rate limiter\n"
+	metricsOutput += "# TYPE kuberhealthy_throttled_calls_total counter\n"
+	for _, s := range stats {
+		metricsOutput += fmt.Sprintf("kuberhealthy_throttled_calls_total{limiter=\"%s\",operation=\"%s\"} %d\n", s.Limiter, s.Operation, s.Throttled)
+	}
+	metricsOutput += "# HELP kuberhealthy_throttled_seconds_total Shows the total time calls waited for a Kuberhealthy rate limiter\n"
+	metricsOutput += "# TYPE kuberhealthy_throttled_seconds_total counter\n"
+	for _, s := range stats {
+		metricsOutput += fmt.Sprintf("kuberhealthy_throttled_seconds_total{limiter=\"%s\",operation=\"%s\"} %f\n", s.Limiter, s.Operation, s.WaitSeconds)
+	}
+	return metricsOutput
+}
+
+// GenerateUsageMetrics returns the resources reserved by the checker pods of each check in the Prometheus format
+func GenerateUsageMetrics(checks []usage.CheckUsage) string {
+	metricsOutput := ""
+	metricsOutput += "# HELP kuberhealthy_check_pod_runs_total Shows the number of runs of a Kuberhealthy check that created a checker pod\n"
+	metricsOutput += "# TYPE kuberhealthy_check_pod_runs_total counter\n"
+	for _, u := range checks {
+		metricsOutput += fmt.Sprintf("kuberhealthy_check_pod_runs_total{check=\"%s\",namespace=\"%s\"} %d\n", u.Name, u.Namespace, u.Runs)
+	}
+	metricsOutput += "# HELP kuberhealthy_check_pod_seconds_total Shows how long the checker pods of a Kuberhealthy check ran\n"
+	metricsOutput += "# TYPE kuberhealthy_check_pod_seconds_total counter\n"
+	for _, u := range checks {
+		metricsOutput += fmt.Sprintf("kuberhealthy_check_pod_seconds_total{check=\"%s\",namespace=\"%s\"} %f\n", u.Name, u.Namespace, u.PodSeconds)
+	}
+	metricsOutput += "# HELP kuberhealthy_check_cpu_request_core_seconds_total Shows the CPU cores requested by the checker pods of a Kuberhealthy check multiplied by how long they ran\n"
+	metricsOutput += "# TYPE kuberhealthy_check_cpu_request_core_seconds_total counter\n"
+	for _, u := range checks {
+		metricsOutput += fmt.Sprintf("kuberhealthy_check_cpu_request_core_seconds_total{check=\"%s\",namespace=\"%s\"} %f\n", u.Name, u.Namespace, u.CPUCoreSeconds)
+	}
+	metricsOutput += "# HELP kuberhealthy_check_memory_request_byte_seconds_total Shows the memory bytes requested by the checker pods of a Kuberhealthy check multiplied by how long they ran\n"
+	metricsOutput += "# TYPE kuberhealthy_check_memory_request_byte_seconds_total counter\n"
+	for _, u := range checks {
+		metricsOutput += fmt.Sprintf("kuberhealthy_check_memory_request_byte_seconds_total{check=\"%s\",namespace=\"%s\"} %f\n", u.Name, u.Namespace, u.MemoryByteSeconds)
+	}
+	return metricsOutput
+}
+
+// GenerateFederationMetrics returns the state of every cluster in a federation and their checks in the
+// Prometheus format, labelled with the cluster they came from
+func GenerateFederationMetrics(clusters []ClusterState) string {
+	metricsOutput := ""
+	metricsOutput += "# HELP kuberhealthy_federated_cluster_reachable Shows if the Kuberhealthy instance of a federated cluster could be reached\n"
+	metricsOutput += "# TYPE kuberhealthy_federated_cluster_reachable gauge\n"
+	for _, c := range clusters {
+		metricsOutput += fmt.Sprintf("kuberhealthy_federated_cluster_reachable{cluster=\"%s\"} %d\n", c.Cluster, boolToInt(c.Reachable))
+	}
+	metricsOutput += "# HELP kuberhealthy_federated_cluster_state Shows the status of a federated cluster\n"
+	metricsOutput += "# TYPE kuberhealthy_federated_cluster_state gauge\n"
+	for _, c := range clusters {
+		metricsOutput += fmt.Sprintf("kuberhealthy_federated_cluster_state{cluster=\"%s\"} %d\n", c.Cluster, boolToInt(c.Reachable && c.State.OK))
+	}
+	metricsOutput += "# HELP kuberhealthy_federated_check Shows the status of a Kuberhealthy check in a federated cluster\n"
+	metricsOutput += "# TYPE kuberhealthy_federated_check gauge\n"
+	for _, c := range clusters {
+		for name, d := range c.State.CheckDetails {
+			checkStatus := boolToInt(d.OK)
+			metricsOutput += fmt.Sprintf("kuberhealthy_federated_check{cluster=\"%s\",check=\"%s\",namespace=\"%s\",status=\"%d\"} %d\n", c.Cluster, name, d.Namespace, checkStatus, checkStatus)
+		}
+	}
+	return metricsOutput
+}
+
+// boolToInt returns 1 for true and 0 for false
+func boolToInt(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// ErrorStateMetrics is a Prometheus metric meant to show Kuberhealthy has error
 func ErrorStateMetrics(state health.State) string {
 	errorOutput := ""
 	errorOutput += "# HELP kuberhealthy_running Shows if kuberhealthy is running error free\n"
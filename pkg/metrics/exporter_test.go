@@ -15,8 +15,12 @@ import (
 	"net/http/httptest"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/Comcast/kuberhealthy/v2/pkg/health"
+	"github.com/Comcast/kuberhealthy/v2/pkg/ratelimit"
+	"github.com/Comcast/kuberhealthy/v2/pkg/scheduler"
+	"github.com/Comcast/kuberhealthy/v2/pkg/usage"
 )
 
 func parseMetrics(metricOutput string) map[string]string {
@@ -157,3 +161,158 @@ func TestWriteMetricError(t *testing.T) {
 		t.Fatal("Error Metric does not match actual error metric function")
 	}
 }
+
+func TestGenerateScheduleMetrics(t *testing.T) {
+	result := GenerateScheduleMetrics([]CheckSchedule{{
+		Check:     "deployment",
+		Namespace: "kuberhealthy",
+		Stats:     scheduler.Stats{Drift: time.Millisecond * 1500, SkippedRuns: 2, Interval: time.Minute},
+	}})
+	metrics := parseMetrics(result)
+	if metrics[`kuberhealthy_check_schedule_drift_seconds{check="deployment",namespace="kuberhealthy"}`] != "1.500000" {
+		t.Fatal("Unexpected drift metric in output:", result)
+	}
+	if metrics[`kuberhealthy_check_skipped_runs_total{check="deployment",namespace="kuberhealthy"}`] != "2" {
+		t.Fatal("Unexpected skipped runs metric in output:", result)
+	}
+	if metrics[`kuberhealthy_check_run_interval_seconds{check="deployment",namespace="kuberhealthy"}`] != "60.000000" {
+		t.Fatal("Unexpected run interval metric in output:", result)
+	}
+}
+
+// TestGenerateRateLimitMetrics validates that calls and throttled calls are labelled by limiter and operation
+func TestGenerateRateLimitMetrics(t *testing.T) {
+	result := GenerateRateLimitMetrics([]ratelimit.Stats{{
+		Limiter:     "checker_pods",
+		Operation:   "create",
+		Calls:       10,
+		Throttled:   3,
+		WaitSeconds: 1.5,
+	}})
+	metrics := parseMetrics(result)
+	if metrics[`kuberhealthy_rate_limited_calls_total{limiter="checker_pods",operation="create"}`] != "10" {
+		t.Fatal("Unexpected calls metric in output:", result)
+	}
+	if metrics[`kuberhealthy_throttled_calls_total{limiter="checker_pods",operation="create"}`] != "3" {
+		t.Fatal("Unexpected throttled calls metric in output:", result)
+	}
+	if metrics[`kuberhealthy_throttled_seconds_total{limiter="checker_pods",operation="create"}`] != "1.500000" {
+		t.Fatal("Unexpected throttled seconds metric in output:", result)
+	}
+}
+
+// TestGenerateUsageMetrics validates that the resources reserved by checker pods are labelled by check
+func TestGenerateUsageMetrics(t *testing.T) {
+	result := GenerateUsageMetrics([]usage.CheckUsage{{
+		Name:              "dns",
+		Namespace:         "kuberhealthy",
+		Runs:              3,
+		PodSeconds:        30,
+		CPUCoreSeconds:    1.5,
+		MemoryByteSeconds: 2048,
+	}})
+	metrics := parseMetrics(result)
+	if metrics[`kuberhealthy_check_pod_runs_total{check="dns",namespace="kuberhealthy"}`] != "3" {
+		t.Fatal("Unexpected runs metric in output:", result)
+	}
+	if metrics[`kuberhealthy_check_pod_seconds_total{check="dns",namespace="kuberhealthy"}`] != "30.000000" {
+		t.Fatal("Unexpected pod seconds metric in output:", result)
+	}
+	if metrics[`kuberhealthy_check_cpu_request_core_seconds_total{check="dns",namespace="kuberhealthy"}`] != "1.500000" {
+		t.Fatal("Unexpected CPU metric in output:", result)
+	}
+	if metrics[`kuberhealthy_check_memory_request_byte_seconds_total{check="dns",namespace="kuberhealthy"}`] != "2048.000000" {
+		t.Fatal("Unexpected memory metric in output:", result)
+	}
+}
+
+// TestGenerateAvailabilityMetrics validates that the availability and remaining error budget of checks are
+// labelled by window
+func TestGenerateAvailabilityMetrics(t *testing.T) {
+	details := health.NewCheckDetails()
+	details.Namespace = "kuberhealthy"
+	details.RunDuration = "1s"
+	details.Availability = []health.Availability{{Window: "24h", Runs: 4, Failed: 1, Percent: 75, ErrorBudgetRemaining: -50}}
+	result := GenerateMetrics(health.State{CheckDetails: map[string]health.CheckDetails{"deployment": details}})
+	metrics := parseMetrics(result)
+	if metrics[`kuberhealthy_check_availability_percent{check="deployment",namespace="kuberhealthy",window="24h"}`] != "75.000000" {
+		t.Fatal("Unexpected availability metric in output:", result)
+	}
+	if metrics[`kuberhealthy_check_error_budget_remaining_percent{check="deployment",namespace="kuberhealthy",window="24h"}`] != "-50.000000" {
+		t.Fatal("Unexpected error budget metric in output:", result)
+	}
+}
+
+// TestGenerateUnknownMetrics validates that checks whose health is unknown are exported as unknown rather than
+// with a status
+func TestGenerateUnknownMetrics(t *testing.T) {
+	unknown := health.NewCheckDetails()
+	unknown.Namespace = "kuberhealthy"
+	unknown.RunDuration = "1s"
+	unknown.Unknown = true
+	known := unknown
+	known.Unknown = false
+	known.OK = true
+	result := GenerateMetrics(health.State{CheckDetails: map[string]health.CheckDetails{"deployment": unknown, "dns": known}})
+	metrics := parseMetrics(result)
+	if metrics[`kuberhealthy_check_unknown{check="deployment",namespace="kuberhealthy"}`] != "1" {
+		t.Fatal("Expected the deployment check to be unknown in output:", result)
+	}
+	if strings.Contains(result, `kuberhealthy_check{check="deployment"`) {
+		t.Fatal("Expected the unknown check to have no status in output:", result)
+	}
+	if metrics[`kuberhealthy_check_unknown{check="dns",namespace="kuberhealthy"}`] != "0" || metrics[`kuberhealthy_check{check="dns",namespace="kuberhealthy",status="1"}`] != "1" {
+		t.Fatal("Expected the dns check to be known and OK in output:", result)
+	}
+}
+
+// TestGenerateArchitectureMetrics validates that the results of checks on each node architecture are exported
+func TestGenerateArchitectureMetrics(t *testing.T) {
+	details := health.NewCheckDetails()
+	details.Namespace = "kuberhealthy"
+	details.RunDuration = "1s"
+	details.Architectures = health.ArchResults{"amd64": {OK: true}, "arm64": {OK: false}}
+	result := GenerateMetrics(health.State{CheckDetails: map[string]health.CheckDetails{"deployment": details}})
+	metrics := parseMetrics(result)
+	if metrics[`kuberhealthy_check_architecture{check="deployment",namespace="kuberhealthy",architecture="amd64",status="1"}`] != "1" {
+		t.Fatal("Unexpected amd64 architecture metric in output:", result)
+	}
+	if metrics[`kuberhealthy_check_architecture{check="deployment",namespace="kuberhealthy",architecture="arm64",status="0"}`] != "0" {
+		t.Fatal("Unexpected arm64 architecture metric in output:", result)
+	}
+}
+
+func TestGenerateMeasurementMetrics(t *testing.T) {
+	details := health.NewCheckDetails()
+	details.Namespace = "kuberhealthy"
+	details.RunDuration = "1s"
+	details.Measurements = health.Measurements{"latency_ms": 12.5}
+	result := GenerateMetrics(health.State{CheckDetails: map[string]health.CheckDetails{"dns": details}})
+	metrics := parseMetrics(result)
+	if metrics[`kuberhealthy_check_measurement{check="dns",namespace="kuberhealthy",measurement="latency_ms"}`] != "12.500000" {
+		t.Fatal("Unexpected measurement metric in output:", result)
+	}
+}
+
+// TestGenerateFederationMetrics validates that federated cluster and check metrics are labelled by cluster
+func TestGenerateFederationMetrics(t *testing.T) {
+	east := health.NewState()
+	east.CheckDetails["kuberhealthy/deployment"] = health.CheckDetails{OK: true, Namespace: "kuberhealthy"}
+	result := GenerateFederationMetrics([]ClusterState{
+		{Cluster: "east", Reachable: true, State: east},
+		{Cluster: "west", Reachable: false, State: health.NewState()},
+	})
+	metrics := parseMetrics(result)
+	if metrics[`kuberhealthy_federated_cluster_state{cluster="east"}`] != "1" {
+		t.Fatal("Unexpected cluster state metric for east in output:", result)
+	}
+	if metrics[`kuberhealthy_federated_cluster_reachable{cluster="west"}`] != "0" {
+		t.Fatal("Unexpected reachable metric for west in output:", result)
+	}
+	if metrics[`kuberhealthy_federated_cluster_state{cluster="west"}`] != "0" {
+		t.Fatal("Expected an unreachable cluster to be shown as down in output:", result)
+	}
+	if metrics[`kuberhealthy_federated_check{cluster="east",check="kuberhealthy/deployment",namespace="kuberhealthy",status="1"}`] != "1" {
+		t.Fatal("Unexpected check metric in output:", result)
+	}
+}
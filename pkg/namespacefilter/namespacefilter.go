@@ -0,0 +1,127 @@
+// Package namespacefilter decides which namespaces Kuberhealthy watches for khcheck resources, so that a
+// Kuberhealthy instance in a shared cluster only runs the checks of the tenants it serves.
+package namespacefilter
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// Filter selects namespaces by name and by their labels.  A namespace is watched when it matches the allow
+// list, or the allow list is empty, it does not match the deny list, and its labels match the selector.  Allow
+// and deny patterns are namespace names that can contain * wildcards.
+type Filter struct {
+	Allow    []string
+	Deny     []string
+	Selector labels.Selector // nil selects every namespace
+}
+
+// Parse creates a Filter from comma separated allow and deny patterns and a namespace label selector
+func Parse(allow string, deny string, selector string) (Filter, error) {
+	f := Filter{
+		Allow: splitPatterns(allow),
+		Deny:  splitPatterns(deny),
+	}
+	for _, pattern := range append(f.Allow, f.Deny...) {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return Filter{}, fmt.Errorf("invalid namespace pattern %s: %w", pattern, err)
+		}
+	}
+	if len(strings.TrimSpace(selector)) > 0 {
+		s, err := labels.Parse(selector)
+		if err != nil {
+			return Filter{}, fmt.Errorf("invalid namespace selector %s: %w", selector, err)
+		}
+		f.Selector = s
+	}
+	return f, nil
+}
+
+// splitPatterns splits a comma separated list of patterns, skipping blank entries
+func splitPatterns(s string) []string {
+	var patterns []string
+	for _, p := range strings.Split(s, ",") {
+		p = strings.TrimSpace(p)
+		if len(p) > 0 {
+			patterns = append(patterns, p)
+		}
+	}
+	return patterns
+}
+
+// Enabled returns true if the filter leaves out any namespace
+func (f Filter) Enabled() bool {
+	return len(f.Allow) > 0 || len(f.Deny) > 0 || f.Selector != nil
+}
+
+// NeedsLabels returns true if namespaces can only be matched with their labels
+func (f Filter) NeedsLabels() bool {
+	return f.Selector != nil
+}
+
+// Namespaces returns the namespaces the filter allows when they can be watched one by one, which is when the
+// allow list names every namespace without wildcards.  Returns nil when namespaces must be watched cluster
+// wide and filtered as they are seen.
+func (f Filter) Namespaces() []string {
+	if len(f.Allow) == 0 {
+		return nil
+	}
+	var namespaces []string
+	for _, p := range f.Allow {
+		if strings.Contains(p, "*") {
+			return nil
+		}
+		if matchesAny(f.Deny, p) {
+			continue
+		}
+		namespaces = append(namespaces, p)
+	}
+	return namespaces
+}
+
+// MatchesName returns true if a namespace passes the allow and deny lists
+func (f Filter) MatchesName(namespace string) bool {
+	if len(f.Allow) > 0 && !matchesAny(f.Allow, namespace) {
+		return false
+	}
+	return !matchesAny(f.Deny, namespace)
+}
+
+// Matches returns true if a namespace with the supplied labels is watched
+func (f Filter) Matches(namespace string, namespaceLabels map[string]string) bool {
+	if !f.MatchesName(namespace) {
+		return false
+	}
+	return f.Selector == nil || f.Selector.Matches(labels.Set(namespaceLabels))
+}
+
+// String describes the filter for logging
+func (f Filter) String() string {
+	if !f.Enabled() {
+		return "all namespaces"
+	}
+	var parts []string
+	if len(f.Allow) > 0 {
+		parts = append(parts, "allow "+strings.Join(f.Allow, ","))
+	}
+	if len(f.Deny) > 0 {
+		parts = append(parts, "deny "+strings.Join(f.Deny, ","))
+	}
+	if f.Selector != nil {
+		parts = append(parts, "selector "+f.Selector.String())
+	}
+	return strings.Join(parts, ", ")
+}
+
+// matchesAny returns true if the namespace matches any of the patterns
+func matchesAny(patterns []string, namespace string) bool {
+	for _, p := range patterns {
+		if ok, _ := path.Match(p, namespace); ok {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,73 @@
+package namespacefilter
+
+import (
+	"reflect"
+	"testing"
+)
+
+// TestParse validates that patterns and selectors are parsed and that invalid ones are rejected
+func TestParse(t *testing.T) {
+	f, err := Parse("team-*, kuberhealthy,", "team-sandbox", "tier=prod")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(f.Allow, []string{"team-*", "kuberhealthy"}) || !reflect.DeepEqual(f.Deny, []string{"team-sandbox"}) || f.Selector == nil {
+		t.Fatal("Expected the allow and deny lists and selector to be parsed but got", f)
+	}
+	if !f.Enabled() || !f.NeedsLabels() {
+		t.Fatal("Expected the filter to be enabled and to need namespace labels")
+	}
+
+	f, err = Parse("", "", "")
+	if err != nil || f.Enabled() {
+		t.Fatal("Expected an empty filter to watch every namespace but got", f, err)
+	}
+
+	if _, err := Parse("team-[", "", ""); err == nil {
+		t.Fatal("Expected an invalid pattern to be rejected")
+	}
+	if _, err := Parse("", "", "tier in (prod"); err == nil {
+		t.Fatal("Expected an invalid selector to be rejected")
+	}
+}
+
+// TestMatches validates that namespaces must pass the allow list, deny list, and selector to be watched
+func TestMatches(t *testing.T) {
+	f, err := Parse("team-*,kuberhealthy", "team-sandbox", "tier=prod")
+	if err != nil {
+		t.Fatal(err)
+	}
+	prod := map[string]string{"tier": "prod"}
+	tests := []struct {
+		namespace string
+		labels    map[string]string
+		expected  bool
+	}{
+		{"team-payments", prod, true},
+		{"kuberhealthy", prod, true},
+		{"team-sandbox", prod, false},
+		{"default", prod, false},
+		{"team-payments", map[string]string{"tier": "dev"}, false},
+	}
+	for _, test := range tests {
+		if f.Matches(test.namespace, test.labels) != test.expected {
+			t.Fatal("Expected namespace", test.namespace, "with labels", test.labels, "to match:", test.expected)
+		}
+	}
+}
+
+// TestNamespaces validates that namespaces are only watched one by one when the allow list names all of them
+func TestNamespaces(t *testing.T) {
+	f, _ := Parse("payments,kuberhealthy,sandbox", "sandbox", "")
+	if !reflect.DeepEqual(f.Namespaces(), []string{"payments", "kuberhealthy"}) {
+		t.Fatal("Expected the allowed namespaces without the denied one but got", f.Namespaces())
+	}
+	f, _ = Parse("team-*", "", "")
+	if f.Namespaces() != nil {
+		t.Fatal("Expected wildcard allow lists to be watched cluster wide but got", f.Namespaces())
+	}
+	f, _ = Parse("", "sandbox", "")
+	if f.Namespaces() != nil {
+		t.Fatal("Expected deny lists to be watched cluster wide but got", f.Namespaces())
+	}
+}
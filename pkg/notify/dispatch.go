@@ -0,0 +1,197 @@
+package notify
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/Comcast/kuberhealthy/v2/pkg/health"
+)
+
+// DefaultThrottle is the least time between two notifications about the same check when none is configured
+var DefaultThrottle = time.Minute * 5
+
+// DefaultRenotify is how often a check that remains failing is notified again when no interval is configured
+var DefaultRenotify = time.Hour * 4
+
+// dispatchInterval is how often throttled notifications and renotifications are checked for
+var dispatchInterval = time.Second * 30
+
+// Sender posts notifications about checks to the channels they route to.  The Notifier is a Sender.
+type Sender interface {
+	Notify(ctx context.Context, e Event, channels []string) error
+}
+
+// Dispatcher sits in front of a Sender and decides which notifications are sent.  Notifications identical to
+// the last one sent for a check are dropped, at most one notification is sent for each check per throttle
+// window, and checks that remain failing are notified again every renotify interval.  Notifications that
+// arrive within the throttle window are held and the latest of them is sent once the window ends.
+type Dispatcher struct {
+	Throttle time.Duration // the least time between two notifications about the same check
+	Renotify time.Duration // how often checks that remain failing are notified again.  Zero disables renotifying.
+
+	sender  Sender
+	mu      sync.Mutex
+	records map[string]*record
+}
+
+// record is what the dispatcher knows about the notifications of a check
+type record struct {
+	sent     Event     // the last notification sent
+	sentKey  string    // identifies the content of the last notification sent
+	sentAt   time.Time // when the last notification was attempted
+	channels []string  // the channels the check routes its notifications to
+	pending  *Event    // the latest notification held back by the throttle, if any
+}
+
+// NewDispatcher creates a dispatcher that sends notifications with the supplied sender
+func NewDispatcher(sender Sender) *Dispatcher {
+	return &Dispatcher{
+		Throttle: DefaultThrottle,
+		Renotify: DefaultRenotify,
+		sender:   sender,
+		records:  make(map[string]*record),
+	}
+}
+
+// Reconfigure replaces the sender, throttle, and renotify interval of the dispatcher.  What the dispatcher
+// knows about the notifications already sent and held for each check is kept.
+func (d *Dispatcher) Reconfigure(sender Sender, throttle time.Duration, renotify time.Duration) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.sender = sender
+	d.Throttle = throttle
+	d.Renotify = renotify
+}
+
+// Dispatch sends a notification about a check unless it is identical to the last one sent, or holds it until
+// the check's throttle window ends
+func (d *Dispatcher) Dispatch(ctx context.Context, e Event, channels []string) error {
+	return d.dispatch(ctx, e, channels, time.Now())
+}
+
+// Run sends held notifications once their throttle window ends and renotifies the checks that remain failing
+// in the status returned by currentState, until the context is canceled
+func (d *Dispatcher) Run(ctx context.Context, currentState func() health.State) {
+	ticker := time.NewTicker(dispatchInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			log.Infoln("notify: shutting down dispatcher from context abort")
+			return
+		case <-ticker.C:
+		}
+		d.flush(ctx, currentState(), time.Now())
+	}
+}
+
+// dispatch decides whether a notification is sent, dropped, or held at the supplied time
+func (d *Dispatcher) dispatch(ctx context.Context, e Event, channels []string, now time.Time) error {
+	key := e.Namespace + "/" + e.Check
+	d.mu.Lock()
+	r, ok := d.records[key]
+	if !ok {
+		r = &record{}
+		d.records[key] = r
+	}
+	r.channels = channels
+
+	// notifications identical to the one sent last are dropped, along with any held notification, since the
+	// check is back to what was last sent
+	if eventKey(e) == r.sentKey {
+		r.pending = nil
+		d.mu.Unlock()
+		return nil
+	}
+	if now.Sub(r.sentAt) < d.Throttle {
+		r.pending = &e
+		d.mu.Unlock()
+		return nil
+	}
+	d.mu.Unlock()
+	return d.send(ctx, key, r, e, now)
+}
+
+// flush sends held notifications whose throttle window has ended and renotifies checks that remain failing.
+// Silenced checks are skipped until their silence is lifted.  Checks that are no longer in the status are
+// forgotten.
+func (d *Dispatcher) flush(ctx context.Context, state health.State, now time.Time) {
+	type notification struct {
+		key string
+		r   *record
+		e   Event
+	}
+	var due []notification
+
+	d.mu.Lock()
+	for key, r := range d.records {
+		details, ok := state.CheckDetails[key]
+		if !ok {
+			delete(d.records, key)
+			continue
+		}
+		if now.Sub(r.sentAt) < d.Throttle || details.Silence != nil {
+			continue
+		}
+		if r.pending != nil {
+			due = append(due, notification{key: key, r: r, e: *r.pending})
+			continue
+		}
+		if d.Renotify > 0 && len(r.sentKey) > 0 && !r.sent.OK && !details.OK && now.Sub(r.sentAt) >= d.Renotify {
+			e := r.sent
+			e.Errors = details.Errors
+			e.LastError = ""
+			e.Category = details.ErrorCategory
+			e.RunID = details.CurrentUUID
+			e.Time = now
+			e.Renotify = true
+			due = append(due, notification{key: key, r: r, e: e})
+		}
+	}
+	d.mu.Unlock()
+
+	for _, n := range due {
+		err := d.send(ctx, n.key, n.r, n.e, now)
+		if err != nil {
+			log.Errorln("notify: failed to send notification for check", n.key+":", err)
+		}
+	}
+}
+
+// send records a notification as sent and sends it.  If sending fails, the notification is held so that it
+// is attempted again once the throttle window ends.
+func (d *Dispatcher) send(ctx context.Context, key string, r *record, e Event, now time.Time) error {
+	d.mu.Lock()
+	previous, previousKey := r.sent, r.sentKey
+	r.sent = e
+	r.sentKey = eventKey(e)
+	r.sentAt = now
+	r.pending = nil
+	channels := r.channels
+	sender := d.sender
+	d.mu.Unlock()
+
+	err := sender.Notify(ctx, e, channels)
+	if err != nil {
+		d.mu.Lock()
+		if r.sentKey == eventKey(e) {
+			r.sent, r.sentKey = previous, previousKey
+		}
+		if r.pending == nil {
+			r.pending = &e
+		}
+		d.mu.Unlock()
+		log.Debugln("notify: holding notification for check", key, "after it failed to send")
+	}
+	return err
+}
+
+// eventKey identifies the content of a notification so that identical notifications can be dropped
+func eventKey(e Event) string {
+	return strconv.FormatBool(e.OK) + "\n" + strings.Join(e.Errors, "\n")
+}
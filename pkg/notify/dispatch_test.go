@@ -0,0 +1,175 @@
+package notify
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Comcast/kuberhealthy/v2/pkg/health"
+)
+
+// fakeSender records the notifications it is asked to send
+type fakeSender struct {
+	sent []Event
+	err  error
+}
+
+// Notify records the notification, or returns the sender's error
+func (s *fakeSender) Notify(ctx context.Context, e Event, channels []string) error {
+	if s.err != nil {
+		return s.err
+	}
+	s.sent = append(s.sent, e)
+	return nil
+}
+
+// TestDispatch validates that identical notifications are dropped and notifications within the throttle
+// window are held until it ends
+func TestDispatch(t *testing.T) {
+	sender := &fakeSender{}
+	d := NewDispatcher(sender)
+	d.Throttle = time.Minute * 5
+	now := time.Date(2020, 4, 2, 18, 0, 0, 0, time.UTC)
+	ctx := context.Background()
+
+	failing := Event{Check: "deployment", Namespace: "kuberhealthy", Errors: []string{"timed out"}}
+	recovered := Event{Check: "deployment", Namespace: "kuberhealthy", OK: true}
+	state := health.NewState()
+	state.CheckDetails["kuberhealthy/deployment"] = health.CheckDetails{OK: true, Namespace: "kuberhealthy"}
+
+	if err := d.dispatch(ctx, failing, nil, now); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.dispatch(ctx, failing, nil, now.Add(time.Minute*10)); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.dispatch(ctx, Event{Check: "deployment", Namespace: "kuberhealthy", Errors: []string{"pod evicted"}}, nil, now.Add(time.Minute*11)); err != nil {
+		t.Fatal(err)
+	}
+	if len(sender.sent) != 2 || sender.sent[1].Errors[0] != "pod evicted" {
+		t.Fatal("Expected identical failures to be sent once and different failures to be sent but got", sender.sent)
+	}
+
+	// a recovery within the throttle window is held until the window ends
+	if err := d.dispatch(ctx, recovered, nil, now.Add(time.Minute*12)); err != nil {
+		t.Fatal(err)
+	}
+	if len(sender.sent) != 2 {
+		t.Fatal("Expected the recovery to be held but got", sender.sent)
+	}
+	d.flush(ctx, state, now.Add(time.Minute*15))
+	if len(sender.sent) != 2 {
+		t.Fatal("Expected the recovery to be held until the throttle window ends but got", sender.sent)
+	}
+	d.flush(ctx, state, now.Add(time.Minute*16))
+	if len(sender.sent) != 3 || !sender.sent[2].OK {
+		t.Fatal("Expected the held recovery to be sent once the throttle window ended but got", sender.sent)
+	}
+
+	// a check that fails and recovers within the window only notifies what changed since the last notification
+	if err := d.dispatch(ctx, failing, nil, now.Add(time.Minute*17)); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.dispatch(ctx, recovered, nil, now.Add(time.Minute*18)); err != nil {
+		t.Fatal(err)
+	}
+	d.flush(ctx, state, now.Add(time.Minute*30))
+	if len(sender.sent) != 3 {
+		t.Fatal("Expected a flap within the throttle window to be dropped but got", sender.sent)
+	}
+
+	// notifications that fail to send are attempted again
+	sender.err = errors.New("webhook unavailable")
+	if d.dispatch(ctx, failing, nil, now.Add(time.Minute*40)) == nil {
+		t.Fatal("Expected the error of the sender to be returned")
+	}
+	sender.err = nil
+	d.flush(ctx, state, now.Add(time.Minute*46))
+	if len(sender.sent) != 4 || sender.sent[3].OK {
+		t.Fatal("Expected the failed notification to be sent again but got", sender.sent)
+	}
+}
+
+// TestRenotify validates that checks that remain failing are notified again on the renotify interval
+func TestRenotify(t *testing.T) {
+	sender := &fakeSender{}
+	d := NewDispatcher(sender)
+	d.Renotify = time.Hour * 4
+	now := time.Date(2020, 4, 2, 18, 0, 0, 0, time.UTC)
+	ctx := context.Background()
+
+	state := health.NewState()
+	state.CheckDetails["kuberhealthy/deployment"] = health.CheckDetails{OK: false, Namespace: "kuberhealthy", Errors: []string{"timed out"}, CurrentUUID: "5678"}
+	err := d.dispatch(ctx, Event{Check: "deployment", Namespace: "kuberhealthy", Errors: []string{"timed out"}, RunID: "1234"}, []string{"platform"}, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	d.flush(ctx, state, now.Add(time.Hour*3))
+	if len(sender.sent) != 1 {
+		t.Fatal("Expected no renotification before the interval but got", sender.sent)
+	}
+	d.flush(ctx, state, now.Add(time.Hour*4))
+	if len(sender.sent) != 2 || !sender.sent[1].Renotify || sender.sent[1].RunID != "5678" {
+		t.Fatal("Expected the failing check to be renotified with its latest run but got", sender.sent)
+	}
+
+	// checks that are removed are forgotten
+	delete(state.CheckDetails, "kuberhealthy/deployment")
+	d.flush(ctx, state, now.Add(time.Hour*8))
+	if len(sender.sent) != 2 || len(d.records) != 0 {
+		t.Fatal("Expected removed checks to be forgotten but got", sender.sent)
+	}
+}
+
+// TestRenotifySilenced validates that silenced checks are not renotified
+func TestRenotifySilenced(t *testing.T) {
+	sender := &fakeSender{}
+	d := NewDispatcher(sender)
+	now := time.Date(2020, 4, 2, 18, 0, 0, 0, time.UTC)
+	ctx := context.Background()
+
+	err := d.dispatch(ctx, Event{Check: "deployment", Namespace: "kuberhealthy", Errors: []string{"timed out"}}, nil, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	state := health.NewState()
+	state.CheckDetails["kuberhealthy/deployment"] = health.CheckDetails{
+		OK:        false,
+		Namespace: "kuberhealthy",
+		Errors:    []string{"timed out"},
+		Silence:   &health.Silence{Reason: "INC-1", Author: "oncall", Expires: now.Add(time.Hour * 24)},
+	}
+	d.flush(ctx, state, now.Add(d.Renotify))
+	if len(sender.sent) != 1 {
+		t.Fatal("Expected the silenced check not to be renotified but got", sender.sent)
+	}
+}
+
+// TestReconfigure validates that a reconfigured dispatcher sends with its new sender and remembers what was sent
+func TestReconfigure(t *testing.T) {
+	before := &fakeSender{}
+	after := &fakeSender{}
+	d := NewDispatcher(before)
+	now := time.Date(2020, 4, 2, 18, 0, 0, 0, time.UTC)
+	ctx := context.Background()
+	failing := Event{Check: "deployment", Namespace: "kuberhealthy", Errors: []string{"timed out"}}
+
+	if err := d.dispatch(ctx, failing, nil, now); err != nil {
+		t.Fatal(err)
+	}
+	d.Reconfigure(after, time.Minute, 0)
+	if err := d.dispatch(ctx, failing, nil, now.Add(time.Minute*2)); err != nil {
+		t.Fatal(err)
+	}
+	if len(after.sent) != 0 {
+		t.Fatal("Expected the notification already sent not to be sent again but got", after.sent)
+	}
+	if err := d.dispatch(ctx, Event{Check: "deployment", Namespace: "kuberhealthy", OK: true}, nil, now.Add(time.Minute*2)); err != nil {
+		t.Fatal(err)
+	}
+	if len(before.sent) != 1 || len(after.sent) != 1 || d.Renotify != 0 {
+		t.Fatal("Expected the recovery to be sent with the new sender after the new throttle but got", before.sent, after.sent)
+	}
+}
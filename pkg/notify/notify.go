@@ -0,0 +1,291 @@
+// Package notify posts a message to chat webhooks, such as Microsoft Teams channels, whenever the health of a
+// check changes.  Checks can route their messages to specific webhooks, and the text of each message is
+// rendered from a template.
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/ghodss/yaml"
+
+	"github.com/Comcast/kuberhealthy/v2/pkg/proxy"
+)
+
+// the kinds of webhooks messages can be posted to.  Teams webhooks receive a message card.  Generic webhooks
+// receive the event as JSON, or the rendered template when one is configured.
+const (
+	TypeTeams   = "teams"
+	TypeGeneric = "generic"
+)
+
+// DefaultTemplate is the text of messages when a webhook does not configure its own template
+const DefaultTemplate = `{{if .OK}}Check {{.Namespace}}/{{.Check}} has recovered.{{else}}Check {{.Namespace}}/{{.Check}} is {{if .Renotify}}still {{end}}failing: {{.LastError}}{{end}}{{if .RunID}}  Run {{.RunID}}.{{end}}`
+
+// Config is the list of webhooks that messages are posted to, as loaded from a YAML or JSON file
+type Config struct {
+	StatusPageURL string    `json:"statusPageURL,omitempty"` // the URL of the status page that messages link to
+	Webhooks      []Webhook `json:"webhooks"`
+}
+
+// Webhook is a chat webhook that messages are posted to
+type Webhook struct {
+	Name        string `json:"name"`                  // the name checks use to route their messages to this webhook
+	Type        string `json:"type"`                  // teams or generic
+	URL         string `json:"url,omitempty"`         // the URL messages are posted to
+	URLFile     string `json:"urlFile,omitempty"`     // a file holding the URL, read on each message.  Webhook URLs usually embed a secret.
+	Default     bool   `json:"default,omitempty"`     // receives the messages of checks that do not route their messages
+	Template    string `json:"template,omitempty"`    // a Go template for the text of Teams messages or the body of generic messages
+	ContentType string `json:"contentType,omitempty"` // the content type of generic messages.  Defaults to application/json.
+	Proxy       string `json:"proxy,omitempty"`       // the proxy messages are sent through, or direct.  Defaults to the proxy environment variables.
+	NoProxy     string `json:"noProxy,omitempty"`     // comma separated hosts reached without the proxy, added to NO_PROXY
+}
+
+// Event is a change to the health of a check.  Templates are rendered with it.
+type Event struct {
+	Check     string    `json:"check"`
+	Namespace string    `json:"namespace"`
+	OK        bool      `json:"ok"`
+	Errors    []string  `json:"errors"`
+	LastError string    `json:"lastError,omitempty"` // the first error of the check, if it is failing
+	Category  string    `json:"category,omitempty"`  // what caused the errors: infrastructure, check, or configuration
+	RunID     string    `json:"runID,omitempty"`     // the UUID of the run that changed the health of the check
+	StatusURL string    `json:"statusURL,omitempty"` // the status page of the check's namespace
+	Time      time.Time `json:"time"`
+	Renotify  bool      `json:"renotify,omitempty"` // set when the check is still failing since it was last notified
+}
+
+// teamsCard is a Microsoft Teams message card
+type teamsCard struct {
+	Type            string        `json:"@type"`
+	Context         string        `json:"@context"`
+	ThemeColor      string        `json:"themeColor"`
+	Summary         string        `json:"summary"`
+	Title           string        `json:"title"`
+	Text            string        `json:"text"`
+	PotentialAction []teamsAction `json:"potentialAction,omitempty"`
+}
+
+// teamsAction is a button on a Teams message card
+type teamsAction struct {
+	Type    string        `json:"@type"`
+	Name    string        `json:"name"`
+	Targets []teamsTarget `json:"targets"`
+}
+
+// teamsTarget is the link opened by a Teams message card button
+type teamsTarget struct {
+	OS  string `json:"os"`
+	URI string `json:"uri"`
+}
+
+// the colors of Teams message cards for failing and recovered checks
+const teamsFailingColor = "D32F2F"
+const teamsRecoveredColor = "388E3C"
+
+// Notifier posts messages about checks to their webhooks
+type Notifier struct {
+	statusPageURL string
+	webhooks      []Webhook
+	templates     map[string]*template.Template
+	clients       map[string]*http.Client // the client of each webhook, which sends messages through its proxy
+}
+
+// LoadConfig reads a notification config file
+func LoadConfig(path string) (Config, error) {
+	var config Config
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return config, fmt.Errorf("error reading notification config: %w", err)
+	}
+	err = yaml.Unmarshal(b, &config)
+	if err != nil {
+		return config, fmt.Errorf("error parsing notification config: %w", err)
+	}
+	return config, nil
+}
+
+// New creates a notifier for the webhooks of the supplied config
+func New(config Config) (*Notifier, error) {
+	n := &Notifier{
+		statusPageURL: strings.TrimSuffix(config.StatusPageURL, "/"),
+		webhooks:      config.Webhooks,
+		templates:     make(map[string]*template.Template),
+		clients:       make(map[string]*http.Client),
+	}
+	for _, w := range config.Webhooks {
+		if len(w.Name) == 0 {
+			return nil, errors.New("webhooks require a name")
+		}
+		if _, exists := n.templates[w.Name]; exists {
+			return nil, fmt.Errorf("more than one webhook is named %s", w.Name)
+		}
+		if w.Type != TypeTeams && w.Type != TypeGeneric {
+			return nil, fmt.Errorf("webhook %s has unknown type %q.  Must be %s or %s", w.Name, w.Type, TypeTeams, TypeGeneric)
+		}
+		if len(w.URL) == 0 && len(w.URLFile) == 0 {
+			return nil, fmt.Errorf("webhook %s requires a url or urlFile", w.Name)
+		}
+		text := w.Template
+		if len(text) == 0 && w.Type == TypeTeams {
+			text = DefaultTemplate
+		}
+		t, err := template.New(w.Name).Parse(text)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing template of webhook %s: %w", w.Name, err)
+		}
+		n.templates[w.Name] = t
+		transport, err := proxy.Transport(w.Proxy, w.NoProxy)
+		if err != nil {
+			return nil, fmt.Errorf("error configuring proxy of webhook %s: %w", w.Name, err)
+		}
+		n.clients[w.Name] = &http.Client{Timeout: time.Second * 10, Transport: transport}
+	}
+	return n, nil
+}
+
+// StatusURL returns the link to the status page of the supplied namespace, or an empty string when no
+// status page URL is configured
+func (n *Notifier) StatusURL(namespace string) string {
+	if len(n.statusPageURL) == 0 {
+		return ""
+	}
+	return n.statusPageURL + "/?namespace=" + url.QueryEscape(namespace)
+}
+
+// Notify posts a message about the supplied event to the webhooks named by channels, or to the default
+// webhooks when no channels are named.  Every webhook is attempted and the errors of those that fail are
+// returned together.
+func (n *Notifier) Notify(ctx context.Context, e Event, channels []string) error {
+	if len(e.StatusURL) == 0 {
+		e.StatusURL = n.StatusURL(e.Namespace)
+	}
+	if !e.OK && len(e.LastError) == 0 && len(e.Errors) > 0 {
+		e.LastError = e.Errors[0]
+	}
+
+	var errs []string
+	for _, w := range n.route(channels) {
+		err := n.post(ctx, w, e)
+		if err != nil {
+			errs = append(errs, "webhook "+w.Name+": "+err.Error())
+		}
+	}
+	for _, c := range channels {
+		if _, ok := n.templates[c]; !ok {
+			errs = append(errs, "no webhook is named "+c)
+		}
+	}
+	if len(errs) > 0 {
+		return errors.New(strings.Join(errs, ", "))
+	}
+	return nil
+}
+
+// route returns the webhooks named by channels, or the default webhooks when no channels are named
+func (n *Notifier) route(channels []string) []Webhook {
+	var webhooks []Webhook
+	for _, w := range n.webhooks {
+		if len(channels) == 0 && w.Default {
+			webhooks = append(webhooks, w)
+			continue
+		}
+		for _, c := range channels {
+			if c == w.Name {
+				webhooks = append(webhooks, w)
+				break
+			}
+		}
+	}
+	return webhooks
+}
+
+// body renders the message posted to a webhook for an event and returns it with its content type
+func (n *Notifier) body(w Webhook, e Event) ([]byte, string, error) {
+	var rendered bytes.Buffer
+	err := n.templates[w.Name].Execute(&rendered, e)
+	if err != nil {
+		return nil, "", fmt.Errorf("error rendering template: %w", err)
+	}
+
+	if w.Type == TypeGeneric {
+		if len(w.Template) == 0 {
+			b, err := json.Marshal(e)
+			return b, "application/json", err
+		}
+		contentType := w.ContentType
+		if len(contentType) == 0 {
+			contentType = "application/json"
+		}
+		return rendered.Bytes(), contentType, nil
+	}
+
+	card := teamsCard{
+		Type:       "MessageCard",
+		Context:    "https://schema.org/extensions",
+		ThemeColor: teamsFailingColor,
+		Summary:    "Kuberhealthy check " + e.Namespace + "/" + e.Check + " is failing",
+		Title:      "Kuberhealthy check " + e.Namespace + "/" + e.Check + " is failing",
+		Text:       rendered.String(),
+	}
+	if e.Renotify {
+		card.Summary = "Kuberhealthy check " + e.Namespace + "/" + e.Check + " is still failing"
+		card.Title = card.Summary
+	}
+	if e.OK {
+		card.ThemeColor = teamsRecoveredColor
+		card.Summary = "Kuberhealthy check " + e.Namespace + "/" + e.Check + " has recovered"
+		card.Title = card.Summary
+	}
+	if len(e.StatusURL) > 0 {
+		card.PotentialAction = []teamsAction{{
+			Type:    "OpenUri",
+			Name:    "View status",
+			Targets: []teamsTarget{{OS: "default", URI: e.StatusURL}},
+		}}
+	}
+	b, err := json.Marshal(card)
+	return b, "application/json", err
+}
+
+// post sends the message for an event to a webhook
+func (n *Notifier) post(ctx context.Context, w Webhook, e Event) error {
+	body, contentType, err := n.body(w, e)
+	if err != nil {
+		return err
+	}
+
+	target := w.URL
+	if len(w.URLFile) > 0 {
+		b, err := ioutil.ReadFile(w.URLFile)
+		if err != nil {
+			return fmt.Errorf("error reading url file: %w", err)
+		}
+		target = strings.TrimSpace(string(b))
+	}
+
+	req, err := http.NewRequest(http.MethodPost, target, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", contentType)
+	resp, err := n.clients[w.Name].Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("unexpected status code %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,160 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// received is a message posted to a test webhook
+type received struct {
+	path        string
+	contentType string
+	body        []byte
+}
+
+// newWebhookServer starts a server that records the messages posted to it
+func newWebhookServer(t *testing.T) (*httptest.Server, *[]received) {
+	var messages []received
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			t.Error(err)
+		}
+		messages = append(messages, received{path: r.URL.Path, contentType: r.Header.Get("Content-Type"), body: b})
+	}))
+	return server, &messages
+}
+
+// TestNotifyRouting validates that events go to the webhooks a check routes to, or the default webhooks
+func TestNotifyRouting(t *testing.T) {
+	server, messages := newWebhookServer(t)
+	defer server.Close()
+
+	n, err := New(Config{
+		StatusPageURL: "http://kuberhealthy.example.com/",
+		Webhooks: []Webhook{
+			{Name: "platform", Type: TypeTeams, URL: server.URL + "/platform", Default: true},
+			{Name: "dns-team", Type: TypeGeneric, URL: server.URL + "/dns-team"},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	failing := Event{Check: "deployment", Namespace: "kuberhealthy", Errors: []string{"deployment did not roll out"}, RunID: "1234"}
+	err = n.Notify(context.Background(), failing, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(*messages) != 1 || (*messages)[0].path != "/platform" {
+		t.Fatal("Expected the event to go to the default webhook but got", *messages)
+	}
+	var card teamsCard
+	err = json.Unmarshal((*messages)[0].body, &card)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if card.Type != "MessageCard" || card.ThemeColor != teamsFailingColor || card.Text != "Check kuberhealthy/deployment is failing: deployment did not roll out  Run 1234." {
+		t.Fatal("Unexpected Teams card:", string((*messages)[0].body))
+	}
+	if len(card.PotentialAction) != 1 || card.PotentialAction[0].Targets[0].URI != "http://kuberhealthy.example.com/?namespace=kuberhealthy" {
+		t.Fatal("Expected the card to link to the status page but got", card.PotentialAction)
+	}
+
+	// checks that route their events only notify those webhooks
+	err = n.Notify(context.Background(), Event{Check: "dns", Namespace: "kuberhealthy", OK: true}, []string{"dns-team"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(*messages) != 2 || (*messages)[1].path != "/dns-team" {
+		t.Fatal("Expected the event to go to the routed webhook but got", *messages)
+	}
+	var e Event
+	err = json.Unmarshal((*messages)[1].body, &e)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e.Check != "dns" || !e.OK || e.StatusURL != "http://kuberhealthy.example.com/?namespace=kuberhealthy" {
+		t.Fatal("Expected the event as JSON on the generic webhook but got", string((*messages)[1].body))
+	}
+
+	// unknown channels are reported
+	err = n.Notify(context.Background(), failing, []string{"missing"})
+	if err == nil || !strings.Contains(err.Error(), "missing") {
+		t.Fatal("Expected an error for an unknown channel but got", err)
+	}
+}
+
+// TestNotifyTemplate validates that generic webhooks post their rendered template
+func TestNotifyTemplate(t *testing.T) {
+	server, messages := newWebhookServer(t)
+	defer server.Close()
+
+	n, err := New(Config{Webhooks: []Webhook{{
+		Name:        "chat",
+		Type:        TypeGeneric,
+		URL:         server.URL,
+		Default:     true,
+		Template:    `{{.Namespace}}/{{.Check}} {{if .OK}}ok{{else}}failed: {{.LastError}}{{end}}`,
+		ContentType: "text/plain",
+	}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = n.Notify(context.Background(), Event{Check: "deployment", Namespace: "kuberhealthy", Errors: []string{"timed out", "pod evicted"}}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(*messages) != 1 || string((*messages)[0].body) != "kuberhealthy/deployment failed: timed out" || (*messages)[0].contentType != "text/plain" {
+		t.Fatal("Expected the rendered template to be posted but got", *messages)
+	}
+}
+
+// TestNewValidation validates that invalid webhooks are refused
+func TestNewValidation(t *testing.T) {
+	invalid := []Webhook{
+		{Type: TypeTeams, URL: "http://example.com"},
+		{Name: "a", Type: "slack", URL: "http://example.com"},
+		{Name: "a", Type: TypeTeams},
+		{Name: "a", Type: TypeTeams, URL: "http://example.com", Template: "{{.Check"},
+		{Name: "a", Type: TypeTeams, URL: "http://example.com", Proxy: "proxy.example.com:3128"},
+	}
+	for _, w := range invalid {
+		_, err := New(Config{Webhooks: []Webhook{w}})
+		if err == nil {
+			t.Fatal("Expected webhook to be refused:", w)
+		}
+	}
+	_, err := New(Config{Webhooks: []Webhook{{Name: "a", Type: TypeTeams, URL: "http://a"}, {Name: "a", Type: TypeGeneric, URL: "http://b"}}})
+	if err == nil {
+		t.Fatal("Expected webhooks with the same name to be refused")
+	}
+}
+
+// TestNotifyProxy validates that messages are sent through the proxy of their webhook
+func TestNotifyProxy(t *testing.T) {
+	var hosts []string
+	proxyServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hosts = append(hosts, r.Host)
+	}))
+	defer proxyServer.Close()
+
+	n, err := New(Config{Webhooks: []Webhook{
+		{Name: "proxied", Type: TypeGeneric, URL: "http://hooks.example.com/webhook", Proxy: proxyServer.URL},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = n.Notify(context.Background(), Event{Check: "dns", Namespace: "kuberhealthy", OK: true}, []string{"proxied"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(hosts) != 1 || hosts[0] != "hooks.example.com" {
+		t.Fatal("Expected the message to hooks.example.com to be sent through the proxy but got", hosts)
+	}
+}
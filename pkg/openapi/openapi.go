@@ -0,0 +1,272 @@
+// Package openapi builds OpenAPI 3 documents that describe an HTTP API.  Schemas are generated from the Go
+// types of request and response bodies, so the document stays in step with the code that serves the API.
+package openapi
+
+import (
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Version is the version of the OpenAPI specification that documents are written in
+const Version = "3.0.3"
+
+// Document is an OpenAPI document
+type Document struct {
+	OpenAPI    string                `json:"openapi"`
+	Info       Info                  `json:"info"`
+	Paths      map[string]PathItem   `json:"paths"`
+	Components Components            `json:"components"`
+	Security   []map[string][]string `json:"security,omitempty"`
+
+	names map[reflect.Type]string // the component names of the struct types with schemas
+}
+
+// Info describes the API of a document
+type Info struct {
+	Title       string `json:"title"`
+	Description string `json:"description,omitempty"`
+	Version     string `json:"version"`
+}
+
+// PathItem holds the operations of a path, keyed by their lower case HTTP method
+type PathItem map[string]Operation
+
+// Operation is a single API endpoint
+type Operation struct {
+	OperationID string              `json:"operationId"`
+	Summary     string              `json:"summary"`
+	Parameters  []Parameter         `json:"parameters,omitempty"`
+	RequestBody *RequestBody        `json:"requestBody,omitempty"`
+	Responses   map[string]Response `json:"responses"`
+}
+
+// Parameter is a path or query parameter of an operation
+type Parameter struct {
+	Name        string  `json:"name"`
+	In          string  `json:"in"`
+	Description string  `json:"description,omitempty"`
+	Required    bool    `json:"required,omitempty"`
+	Schema      *Schema `json:"schema"`
+}
+
+// RequestBody is the body of a request to an operation
+type RequestBody struct {
+	Required bool                 `json:"required"`
+	Content  map[string]MediaType `json:"content"`
+}
+
+// Response is a response of an operation, keyed by status code in the operation
+type Response struct {
+	Description string               `json:"description"`
+	Content     map[string]MediaType `json:"content,omitempty"`
+}
+
+// MediaType is the schema of a body with a given content type
+type MediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+// Components holds the schemas referenced by operations along with the security schemes of the API
+type Components struct {
+	Schemas         map[string]*Schema        `json:"schemas"`
+	SecuritySchemes map[string]SecurityScheme `json:"securitySchemes,omitempty"`
+}
+
+// SecurityScheme is a way callers authenticate to the API
+type SecurityScheme struct {
+	Type   string `json:"type"`
+	Scheme string `json:"scheme,omitempty"`
+}
+
+// Schema describes the shape of a JSON value
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+}
+
+// Endpoint is an operation to add to a document.  Request and Response are values of the Go types of the
+// request and response bodies, or nil when there is no body.
+type Endpoint struct {
+	Method      string
+	Path        string // the path of the endpoint, with path parameters in braces such as /runs/{id}
+	OperationID string
+	Summary     string
+	Query       []Parameter // the query parameters of the endpoint
+	Request     interface{}
+	Status      int // the status code of a successful response
+	Response    interface{}
+}
+
+// NewDocument creates a document for the API with the supplied title and version
+func NewDocument(title string, version string, description string) *Document {
+	return &Document{
+		OpenAPI: Version,
+		Info:    Info{Title: title, Version: version, Description: description},
+		Paths:   make(map[string]PathItem),
+		Components: Components{
+			Schemas: make(map[string]*Schema),
+		},
+		names: make(map[reflect.Type]string),
+	}
+}
+
+// RequireBearerAuth documents that every operation requires a bearer token
+func (d *Document) RequireBearerAuth() {
+	d.Components.SecuritySchemes = map[string]SecurityScheme{"bearerAuth": {Type: "http", Scheme: "bearer"}}
+	d.Security = []map[string][]string{{"bearerAuth": {}}}
+}
+
+// Add adds an endpoint to the document.  Path parameters are taken from the braces in its path, and the
+// schemas of its bodies are generated from their types.
+func (d *Document) Add(e Endpoint) {
+	op := Operation{
+		OperationID: e.OperationID,
+		Summary:     e.Summary,
+		Responses:   make(map[string]Response),
+	}
+	for _, segment := range strings.Split(e.Path, "/") {
+		if strings.HasPrefix(segment, "{") && strings.HasSuffix(segment, "}") {
+			op.Parameters = append(op.Parameters, Parameter{
+				Name:     strings.Trim(segment, "{}"),
+				In:       "path",
+				Required: true,
+				Schema:   &Schema{Type: "string"},
+			})
+		}
+	}
+	op.Parameters = append(op.Parameters, e.Query...)
+
+	if e.Request != nil {
+		op.RequestBody = &RequestBody{
+			Required: true,
+			Content:  map[string]MediaType{"application/json": {Schema: d.SchemaOf(e.Request)}},
+		}
+	}
+	success := Response{Description: "Success"}
+	if e.Response != nil {
+		success.Content = map[string]MediaType{"application/json": {Schema: d.SchemaOf(e.Response)}}
+	}
+	op.Responses[statusCode(e.Status)] = success
+	op.Responses["default"] = Response{Description: "Error"}
+
+	item, ok := d.Paths[e.Path]
+	if !ok {
+		item = make(PathItem)
+		d.Paths[e.Path] = item
+	}
+	item[strings.ToLower(e.Method)] = op
+}
+
+// SchemaOf returns the schema of a value's type.  Struct types are added to the components of the document
+// and referenced by name.
+func (d *Document) SchemaOf(v interface{}) *Schema {
+	return d.schema(reflect.TypeOf(v))
+}
+
+// schema returns the schema of a type
+func (d *Document) schema(t reflect.Type) *Schema {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	switch t {
+	case reflect.TypeOf(time.Time{}):
+		return &Schema{Type: "string", Format: "date-time"}
+	case reflect.TypeOf(time.Duration(0)):
+		return &Schema{Type: "integer", Format: "int64"}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return &Schema{Type: "integer", Format: "int32"}
+	case reflect.Int64, reflect.Uint64:
+		return &Schema{Type: "integer", Format: "int64"}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number", Format: "double"}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &Schema{Type: "string", Format: "byte"}
+		}
+		return &Schema{Type: "array", Items: d.schema(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: d.schema(t.Elem())}
+	case reflect.Struct:
+		return d.structSchema(t)
+	}
+
+	// interfaces and anything else can hold any value
+	return &Schema{}
+}
+
+// structSchema adds the schema of a struct type to the components of the document and returns a reference
+// to it.  Anonymous structs are returned inline.
+func (d *Document) structSchema(t reflect.Type) *Schema {
+	if len(t.Name()) == 0 {
+		return d.objectSchema(t)
+	}
+	name, ok := d.names[t]
+	if !ok {
+		name = t.Name()
+		if _, taken := d.Components.Schemas[name]; taken {
+			name = strings.Title(t.PkgPath()[strings.LastIndex(t.PkgPath(), "/")+1:]) + name
+		}
+		d.names[t] = name
+		d.Components.Schemas[name] = &Schema{} // placeholder so recursive types terminate
+		d.Components.Schemas[name] = d.objectSchema(t)
+	}
+	return &Schema{Ref: "#/components/schemas/" + name}
+}
+
+// objectSchema returns the schema of a struct type with a property for each field that is marshaled to JSON
+func (d *Document) objectSchema(t reflect.Type) *Schema {
+	s := &Schema{Type: "object", Properties: make(map[string]*Schema)}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name := strings.Split(tag, ",")[0]
+
+		// the fields of embedded structs are marshaled as if they were fields of the outer struct
+		if f.Anonymous && len(name) == 0 {
+			ft := f.Type
+			for ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				for k, v := range d.objectSchema(ft).Properties {
+					s.Properties[k] = v
+				}
+				continue
+			}
+		}
+		if len(f.PkgPath) > 0 {
+			continue // unexported
+		}
+		if len(name) == 0 {
+			name = f.Name
+		}
+		s.Properties[name] = d.schema(f.Type)
+	}
+	return s
+}
+
+// statusCode formats an HTTP status code as a response key, defaulting to 200
+func statusCode(code int) string {
+	if code == 0 {
+		code = http.StatusOK
+	}
+	return strconv.Itoa(code)
+}
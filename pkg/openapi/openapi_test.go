@@ -0,0 +1,78 @@
+package openapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// testRun is a response body with nested, embedded, and ignored fields
+type testRun struct {
+	testMeta
+	ID       string            `json:"id"`
+	Errors   []string          `json:"errors"`
+	Started  *time.Time        `json:"started,omitempty"`
+	Labels   map[string]string `json:"labels,omitempty"`
+	Next     *testRun          `json:"next,omitempty"`
+	Internal string            `json:"-"`
+	Count    int
+	private  bool
+}
+
+// testMeta is embedded in testRun
+type testMeta struct {
+	Name string `json:"name"`
+}
+
+// TestAdd validates that endpoints are documented with their parameters and generated schemas
+func TestAdd(t *testing.T) {
+	d := NewDocument("Test API", "v1", "")
+	d.RequireBearerAuth()
+	d.Add(Endpoint{
+		Method:      http.MethodGet,
+		Path:        "/runs/{id}",
+		OperationID: "getRun",
+		Summary:     "Get a run",
+		Query:       []Parameter{{Name: "verbose", In: "query", Schema: &Schema{Type: "boolean"}}},
+		Response:    testRun{},
+	})
+	d.Add(Endpoint{Method: http.MethodDelete, Path: "/runs/{id}", OperationID: "deleteRun", Summary: "Delete a run", Status: http.StatusNoContent})
+
+	op, ok := d.Paths["/runs/{id}"]["get"]
+	if !ok {
+		t.Fatal("Expected the get operation to be documented but got", d.Paths)
+	}
+	if len(op.Parameters) != 2 || op.Parameters[0].Name != "id" || op.Parameters[0].In != "path" || !op.Parameters[0].Required {
+		t.Fatal("Expected the path parameter and then the query parameter but got", op.Parameters)
+	}
+	if op.Responses["200"].Content["application/json"].Schema.Ref != "#/components/schemas/testRun" {
+		t.Fatal("Expected the response to reference its schema but got", op.Responses)
+	}
+	if _, ok := d.Paths["/runs/{id}"]["delete"].Responses["204"]; !ok {
+		t.Fatal("Expected the delete operation to respond with 204 but got", d.Paths["/runs/{id}"]["delete"].Responses)
+	}
+
+	s := d.Components.Schemas["testRun"]
+	expected := map[string]string{"name": "string", "id": "string", "errors": "array", "started": "string", "labels": "object", "Count": "integer"}
+	for name, typ := range expected {
+		if s.Properties[name] == nil || s.Properties[name].Type != typ {
+			t.Fatal("Expected property", name, "of type", typ, "but got", s.Properties[name])
+		}
+	}
+	if len(s.Properties) != len(expected)+1 {
+		t.Fatal("Expected ignored and unexported fields to be left out but got", s.Properties)
+	}
+	if s.Properties["next"].Ref != "#/components/schemas/testRun" {
+		t.Fatal("Expected the recursive field to reference its own schema but got", s.Properties["next"])
+	}
+	if s.Properties["started"].Format != "date-time" {
+		t.Fatal("Expected times to be formatted as date-time but got", s.Properties["started"])
+	}
+
+	// the document must marshal to JSON
+	_, err := json.Marshal(d)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,143 @@
+// Package podsecurity hardens the pod specs of checker pods by enforcing a
+// security context on them.  Checks that need privileges can opt out with the
+// disableSecurityPolicy field of their khcheck.
+package podsecurity
+
+import (
+	"fmt"
+	"strings"
+
+	apiv1 "k8s.io/api/core/v1"
+)
+
+// SeccompPodAnnotation is the annotation used to apply a seccomp profile to every container in a pod
+const SeccompPodAnnotation = "seccomp.security.alpha.kubernetes.io/pod"
+
+// DefaultSeccompProfile is the seccomp profile applied when none is configured
+const DefaultSeccompProfile = "runtime/default"
+
+// DefaultRunAsUser is the user ID checker pods run as when they must run as non-root and do
+// not specify a user themselves
+const DefaultRunAsUser int64 = 999
+
+// names of the settings that can be listed in a policy string
+const (
+	RunAsNonRootSetting           = "runAsNonRoot"
+	DropCapabilitiesSetting       = "dropCapabilities"
+	ReadOnlyRootFilesystemSetting = "readOnlyRootFilesystem"
+	SeccompSetting                = "seccomp"
+)
+
+// DefaultPolicyString enables every setting
+var DefaultPolicyString = strings.Join([]string{RunAsNonRootSetting, DropCapabilitiesSetting, ReadOnlyRootFilesystemSetting, SeccompSetting}, ",")
+
+// Policy describes the security settings enforced on checker pods
+type Policy struct {
+	RunAsNonRoot           bool   // pods must run as a non-root user
+	DropCapabilities       bool   // all linux capabilities are dropped and privilege escalation is disallowed
+	ReadOnlyRootFilesystem bool   // container root filesystems are mounted read-only
+	SeccompProfile         string // the seccomp profile applied to the pod.  Not applied when blank.
+}
+
+// ParsePolicy builds a policy from a comma separated list of setting names.  A blank string
+// or "none" results in a policy that enforces nothing.
+func ParsePolicy(s string, seccompProfile string) (Policy, error) {
+	p := Policy{}
+	if len(strings.TrimSpace(s)) == 0 || s == "none" {
+		return p, nil
+	}
+
+	for _, setting := range strings.Split(s, ",") {
+		switch strings.TrimSpace(setting) {
+		case RunAsNonRootSetting:
+			p.RunAsNonRoot = true
+		case DropCapabilitiesSetting:
+			p.DropCapabilities = true
+		case ReadOnlyRootFilesystemSetting:
+			p.ReadOnlyRootFilesystem = true
+		case SeccompSetting:
+			p.SeccompProfile = seccompProfile
+			if len(p.SeccompProfile) == 0 {
+				p.SeccompProfile = DefaultSeccompProfile
+			}
+		default:
+			return p, fmt.Errorf("unknown security policy setting: %s", setting)
+		}
+	}
+	return p, nil
+}
+
+// Enabled returns true if the policy enforces any setting
+func (p Policy) Enabled() bool {
+	return p.RunAsNonRoot || p.DropCapabilities || p.ReadOnlyRootFilesystem || len(p.SeccompProfile) > 0
+}
+
+// Apply returns a copy of the supplied pod spec with the policy enforced on the pod and all of
+// its containers.  The supplied spec is not modified.
+func (p Policy) Apply(spec apiv1.PodSpec) apiv1.PodSpec {
+	hardened := spec.DeepCopy()
+
+	if p.RunAsNonRoot {
+		if hardened.SecurityContext == nil {
+			hardened.SecurityContext = &apiv1.PodSecurityContext{}
+		}
+		runAsNonRoot := true
+		hardened.SecurityContext.RunAsNonRoot = &runAsNonRoot
+
+		// images that don't declare a user would otherwise be refused by the kubelet
+		if hardened.SecurityContext.RunAsUser == nil || *hardened.SecurityContext.RunAsUser == 0 {
+			runAsUser := DefaultRunAsUser
+			hardened.SecurityContext.RunAsUser = &runAsUser
+		}
+	}
+
+	for i := range hardened.InitContainers {
+		p.applyToContainer(&hardened.InitContainers[i])
+	}
+	for i := range hardened.Containers {
+		p.applyToContainer(&hardened.Containers[i])
+	}
+
+	return *hardened
+}
+
+// applyToContainer enforces the container level settings of the policy
+func (p Policy) applyToContainer(c *apiv1.Container) {
+	if c.SecurityContext == nil {
+		c.SecurityContext = &apiv1.SecurityContext{}
+	}
+	sc := c.SecurityContext
+
+	// a root user set on the container overrides the pod level setting
+	if p.RunAsNonRoot {
+		runAsNonRoot := true
+		sc.RunAsNonRoot = &runAsNonRoot
+		if sc.RunAsUser != nil && *sc.RunAsUser == 0 {
+			sc.RunAsUser = nil
+		}
+	}
+
+	if p.DropCapabilities {
+		privileged := false
+		allowPrivilegeEscalation := false
+		sc.Privileged = &privileged
+		sc.AllowPrivilegeEscalation = &allowPrivilegeEscalation
+		sc.Capabilities = &apiv1.Capabilities{
+			Drop: []apiv1.Capability{"ALL"},
+		}
+	}
+
+	if p.ReadOnlyRootFilesystem {
+		readOnlyRootFilesystem := true
+		sc.ReadOnlyRootFilesystem = &readOnlyRootFilesystem
+	}
+}
+
+// Annotations returns the pod annotations required to enforce the policy
+func (p Policy) Annotations() map[string]string {
+	annotations := make(map[string]string)
+	if len(p.SeccompProfile) > 0 {
+		annotations[SeccompPodAnnotation] = p.SeccompProfile
+	}
+	return annotations
+}
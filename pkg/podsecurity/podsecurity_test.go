@@ -0,0 +1,71 @@
+package podsecurity
+
+import (
+	"testing"
+
+	apiv1 "k8s.io/api/core/v1"
+)
+
+func TestParsePolicy(t *testing.T) {
+	p, err := ParsePolicy(DefaultPolicyString, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !p.RunAsNonRoot || !p.DropCapabilities || !p.ReadOnlyRootFilesystem || p.SeccompProfile != DefaultSeccompProfile {
+		t.Fatalf("expected every setting to be enabled, got %+v", p)
+	}
+
+	p, err = ParsePolicy("none", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Enabled() {
+		t.Fatalf("expected an empty policy, got %+v", p)
+	}
+
+	_, err = ParsePolicy("runAsNonRoot,bogus", "")
+	if err == nil {
+		t.Fatal("expected an error for an unknown setting")
+	}
+}
+
+func TestApply(t *testing.T) {
+	root := int64(0)
+	spec := apiv1.PodSpec{
+		Containers: []apiv1.Container{
+			{
+				Name: "main",
+				SecurityContext: &apiv1.SecurityContext{
+					RunAsUser: &root,
+					Capabilities: &apiv1.Capabilities{
+						Add: []apiv1.Capability{"NET_ADMIN"},
+					},
+				},
+			},
+		},
+	}
+
+	p, _ := ParsePolicy(DefaultPolicyString, "")
+	hardened := p.Apply(spec)
+
+	if spec.Containers[0].SecurityContext.RunAsUser == nil {
+		t.Fatal("expected the original spec to be left alone")
+	}
+	if !*hardened.SecurityContext.RunAsNonRoot || *hardened.SecurityContext.RunAsUser != DefaultRunAsUser {
+		t.Fatal("expected pod to run as a non-root user")
+	}
+
+	sc := hardened.Containers[0].SecurityContext
+	if sc.RunAsUser != nil {
+		t.Fatal("expected the container root user to be removed")
+	}
+	if *sc.Privileged || *sc.AllowPrivilegeEscalation || !*sc.ReadOnlyRootFilesystem {
+		t.Fatalf("expected container to be hardened, got %+v", sc)
+	}
+	if len(sc.Capabilities.Add) != 0 || len(sc.Capabilities.Drop) != 1 || sc.Capabilities.Drop[0] != "ALL" {
+		t.Fatalf("expected all capabilities to be dropped, got %+v", sc.Capabilities)
+	}
+	if p.Annotations()[SeccompPodAnnotation] != DefaultSeccompProfile {
+		t.Fatal("expected the seccomp annotation to be set")
+	}
+}
@@ -0,0 +1,144 @@
+// Package podtemplate expands Go template expressions in khcheck pod specs so that one khcheck manifest
+// can be reused across namespaces and environments.
+package podtemplate
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"text/template"
+
+	apiv1 "k8s.io/api/core/v1"
+)
+
+// Values are the fields available to templates in a pod spec, such as {{ .Namespace }}
+type Values struct {
+	Namespace    string // the namespace the checker pod runs in
+	CheckName    string // the name of the khcheck
+	RunID        string // the UUID of the current run
+	PodName      string // the name of the checker pod
+	ReportingURL string // the URL the checker pod reports to
+}
+
+// FieldRefEnvPrefix is the prefix of the environment variables added to containers for fieldRef helpers
+const FieldRefEnvPrefix = "KH_FIELD_"
+
+// invalidEnvChars matches characters that can not be used in an environment variable name
+var invalidEnvChars = regexp.MustCompile(`[^A-Z0-9_]`)
+
+// fieldRefEnvName returns the environment variable name used to expose a downward API field
+func fieldRefEnvName(fieldPath string) string {
+	return FieldRefEnvPrefix + invalidEnvChars.ReplaceAllString(strings.ToUpper(fieldPath), "_")
+}
+
+// Expand returns a copy of the pod spec with all template expressions in its string fields expanded.
+// Strings without template expressions are left alone.
+//
+// The fieldRef helper, such as {{ fieldRef "spec.nodeName" }}, exposes a downward API field.  It expands to
+// a $(VAR) reference and adds VAR to every container with its value set from the field.  Kubernetes only
+// expands these references in container commands, args, and environment variable values.
+func Expand(spec apiv1.PodSpec, values Values) (apiv1.PodSpec, error) {
+	var expanded apiv1.PodSpec
+
+	fieldRefs := make(map[string]string) // environment variable name to field path
+	funcs := template.FuncMap{
+		"fieldRef": func(fieldPath string) string {
+			name := fieldRefEnvName(fieldPath)
+			fieldRefs[name] = fieldPath
+			return "$(" + name + ")"
+		},
+	}
+
+	// the spec is walked in its generic JSON form so that every string field is covered
+	b, err := json.Marshal(spec)
+	if err != nil {
+		return expanded, err
+	}
+	var generic interface{}
+	err = json.Unmarshal(b, &generic)
+	if err != nil {
+		return expanded, err
+	}
+	generic, err = expandValue(generic, values, funcs)
+	if err != nil {
+		return expanded, err
+	}
+	b, err = json.Marshal(generic)
+	if err != nil {
+		return expanded, err
+	}
+	err = json.Unmarshal(b, &expanded)
+	if err != nil {
+		return expanded, err
+	}
+
+	// add the environment variables that fieldRef helpers refer to.  They are sorted so that the
+	// rendered spec is the same on every run.
+	var names []string
+	for name := range fieldRefs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		env := apiv1.EnvVar{
+			Name: name,
+			ValueFrom: &apiv1.EnvVarSource{
+				FieldRef: &apiv1.ObjectFieldSelector{FieldPath: fieldRefs[name]},
+			},
+		}
+		for i := range expanded.InitContainers {
+			expanded.InitContainers[i].Env = append([]apiv1.EnvVar{env}, expanded.InitContainers[i].Env...)
+		}
+		for i := range expanded.Containers {
+			expanded.Containers[i].Env = append([]apiv1.EnvVar{env}, expanded.Containers[i].Env...)
+		}
+	}
+
+	return expanded, nil
+}
+
+// expandValue expands templates in all strings found in a decoded JSON value
+func expandValue(v interface{}, values Values, funcs template.FuncMap) (interface{}, error) {
+	switch typed := v.(type) {
+	case string:
+		return expandString(typed, values, funcs)
+	case []interface{}:
+		for i := range typed {
+			expanded, err := expandValue(typed[i], values, funcs)
+			if err != nil {
+				return nil, err
+			}
+			typed[i] = expanded
+		}
+	case map[string]interface{}:
+		for k := range typed {
+			expanded, err := expandValue(typed[k], values, funcs)
+			if err != nil {
+				return nil, err
+			}
+			typed[k] = expanded
+		}
+	}
+	return v, nil
+}
+
+// expandString executes a single string as a template
+func expandString(s string, values Values, funcs template.FuncMap) (string, error) {
+	if !strings.Contains(s, "{{") {
+		return s, nil
+	}
+
+	t, err := template.New("").Funcs(funcs).Option("missingkey=error").Parse(s)
+	if err != nil {
+		return "", fmt.Errorf("error parsing template %q: %w", s, err)
+	}
+	var out bytes.Buffer
+	err = t.Execute(&out, values)
+	if err != nil {
+		return "", fmt.Errorf("error expanding template %q: %w", s, err)
+	}
+	return out.String(), nil
+}
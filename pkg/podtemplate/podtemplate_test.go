@@ -0,0 +1,93 @@
+package podtemplate
+
+import (
+	"testing"
+
+	apiv1 "k8s.io/api/core/v1"
+)
+
+var testValues = Values{
+	Namespace:    "team-a",
+	CheckName:    "deployment",
+	RunID:        "1234",
+	PodName:      "deployment-1585850000",
+	ReportingURL: "http://kuberhealthy.kuberhealthy.svc.cluster.local/externalCheckStatus",
+}
+
+// TestExpand validates that templates are expanded in every string field of a pod spec
+func TestExpand(t *testing.T) {
+	spec := apiv1.PodSpec{
+		ServiceAccountName: "{{ .CheckName }}-sa",
+		Containers: []apiv1.Container{{
+			Name:    "main",
+			Image:   "registry.example.com/{{ .Namespace }}/check:latest",
+			Command: []string{"/check", "--run={{ .RunID }}"},
+			Env: []apiv1.EnvVar{
+				{Name: "TARGET", Value: "svc.{{ .Namespace }}.svc.cluster.local"},
+				{Name: "PLAIN", Value: "no templates here"},
+			},
+		}},
+		NodeSelector: map[string]string{"pool": "{{ .CheckName }}"},
+	}
+
+	expanded, err := Expand(spec, testValues)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := expanded.Containers[0]
+	checks := [][2]string{
+		{expanded.ServiceAccountName, "deployment-sa"},
+		{c.Image, "registry.example.com/team-a/check:latest"},
+		{c.Command[1], "--run=1234"},
+		{c.Env[0].Value, "svc.team-a.svc.cluster.local"},
+		{c.Env[1].Value, "no templates here"},
+		{expanded.NodeSelector["pool"], "deployment"},
+	}
+	for _, check := range checks {
+		if check[0] != check[1] {
+			t.Fatal("Expected", check[1], "but got", check[0])
+		}
+	}
+
+	// the original spec is left alone
+	if spec.Containers[0].Image != "registry.example.com/{{ .Namespace }}/check:latest" {
+		t.Fatal("Expand modified the original spec")
+	}
+}
+
+// TestExpandFieldRef validates that the fieldRef helper exposes downward API fields to every container
+func TestExpandFieldRef(t *testing.T) {
+	spec := apiv1.PodSpec{
+		InitContainers: []apiv1.Container{{Name: "init"}},
+		Containers: []apiv1.Container{{
+			Name: "main",
+			Args: []string{"--node={{ fieldRef \"spec.nodeName\" }}"},
+		}},
+	}
+
+	expanded, err := Expand(spec, testValues)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if expanded.Containers[0].Args[0] != "--node=$(KH_FIELD_SPEC_NODENAME)" {
+		t.Fatal("Unexpected expanded args:", expanded.Containers[0].Args[0])
+	}
+	for _, c := range append(expanded.InitContainers, expanded.Containers...) {
+		if len(c.Env) != 1 || c.Env[0].Name != "KH_FIELD_SPEC_NODENAME" || c.Env[0].ValueFrom.FieldRef.FieldPath != "spec.nodeName" {
+			t.Fatal("Container", c.Name, "was not given the fieldRef environment variable:", c.Env)
+		}
+	}
+}
+
+// TestExpandErrors validates that invalid templates are rejected
+func TestExpandErrors(t *testing.T) {
+	for _, s := range []string{"{{ .Missing }}", "{{ .Namespace ", "{{ unknownFunc }}"} {
+		spec := apiv1.PodSpec{Containers: []apiv1.Container{{Name: "main", Image: s}}}
+		_, err := Expand(spec, testValues)
+		if err == nil {
+			t.Fatal("Expected template", s, "to fail")
+		}
+	}
+}
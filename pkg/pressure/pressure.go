@@ -0,0 +1,120 @@
+// Copyright 2018 Comcast Cable Communications Management, LLC
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//     http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package pressure decides whether a cluster is under resource pressure, so that checks that are not important
+// enough to add to that pressure can hold off on creating their pods.  A cluster is under pressure when too many
+// pods are pending or any node reports memory, disk, or PID pressure.
+package pressure
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	apiv1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// DefaultCacheTTL is how long the pressure of the cluster is reused before it is looked up again
+const DefaultCacheTTL = time.Second * 30
+
+// nodePressureConditions are the node conditions that put a cluster under pressure when they are true
+var nodePressureConditions = []apiv1.NodeConditionType{
+	apiv1.NodeMemoryPressure,
+	apiv1.NodeDiskPressure,
+	apiv1.NodePIDPressure,
+}
+
+// Status is the pressure of the cluster at a point in time
+type Status struct {
+	UnderPressure bool     // true when the cluster is under resource pressure
+	Reasons       []string // why the cluster is under pressure
+}
+
+// Guard looks up the pressure of the cluster.  Lookups are cached so that many checks asking at once share
+// a single lookup.
+type Guard struct {
+	Client         kubernetes.Interface
+	MaxPendingPods int           // the number of pending pods above which the cluster is under pressure.  Zero ignores pending pods.
+	CacheTTL       time.Duration // how long a lookup is reused
+
+	mu      sync.Mutex
+	last    Status
+	expires time.Time
+}
+
+// New creates a guard that considers the cluster under pressure once more than maxPendingPods pods are pending
+func New(client kubernetes.Interface, maxPendingPods int) *Guard {
+	return &Guard{
+		Client:         client,
+		MaxPendingPods: maxPendingPods,
+		CacheTTL:       DefaultCacheTTL,
+	}
+}
+
+// Check returns the current pressure of the cluster
+func (g *Guard) Check() (Status, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if time.Now().Before(g.expires) {
+		return g.last, nil
+	}
+
+	s, err := g.lookup()
+	if err != nil {
+		return Status{}, err
+	}
+	g.last = s
+	g.expires = time.Now().Add(g.CacheTTL)
+	return s, nil
+}
+
+// lookup counts the pending pods of the cluster and looks for nodes under pressure
+func (g *Guard) lookup() (Status, error) {
+	var s Status
+
+	if g.MaxPendingPods > 0 {
+		pods, err := g.Client.CoreV1().Pods(metav1.NamespaceAll).List(metav1.ListOptions{
+			FieldSelector: "status.phase=" + string(apiv1.PodPending),
+		})
+		if err != nil {
+			return s, fmt.Errorf("error listing pending pods: %w", err)
+		}
+		if len(pods.Items) > g.MaxPendingPods {
+			s.Reasons = append(s.Reasons, fmt.Sprintf("%d pods are pending", len(pods.Items)))
+		}
+	}
+
+	nodes, err := g.Client.CoreV1().Nodes().List(metav1.ListOptions{})
+	if err != nil {
+		return s, fmt.Errorf("error listing nodes: %w", err)
+	}
+	var nodeReasons []string
+	for _, n := range nodes.Items {
+		for _, c := range n.Status.Conditions {
+			if c.Status != apiv1.ConditionTrue {
+				continue
+			}
+			for _, t := range nodePressureConditions {
+				if c.Type == t {
+					nodeReasons = append(nodeReasons, "node "+n.Name+" has "+string(t))
+				}
+			}
+		}
+	}
+	sort.Strings(nodeReasons)
+	s.Reasons = append(s.Reasons, nodeReasons...)
+
+	s.UnderPressure = len(s.Reasons) > 0
+	return s, nil
+}
@@ -0,0 +1,87 @@
+package pressure
+
+import (
+	"testing"
+
+	apiv1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+// TestCheck validates that a cluster is under pressure when nodes report pressure, and that lookups are cached
+func TestCheck(t *testing.T) {
+	healthy := &apiv1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: "healthy"},
+		Status: apiv1.NodeStatus{Conditions: []apiv1.NodeCondition{
+			{Type: apiv1.NodeMemoryPressure, Status: apiv1.ConditionFalse},
+			{Type: apiv1.NodeReady, Status: apiv1.ConditionTrue},
+		}},
+	}
+	client := fake.NewSimpleClientset(healthy)
+	g := New(client, 10)
+
+	s, err := g.Check()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.UnderPressure {
+		t.Fatal("Expected a cluster of healthy nodes to not be under pressure but got", s.Reasons)
+	}
+
+	pressured := &apiv1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: "pressured"},
+		Status: apiv1.NodeStatus{Conditions: []apiv1.NodeCondition{
+			{Type: apiv1.NodeDiskPressure, Status: apiv1.ConditionTrue},
+		}},
+	}
+	_, err = client.CoreV1().Nodes().Create(pressured)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the cached lookup is reused until it expires
+	s, _ = g.Check()
+	if s.UnderPressure {
+		t.Fatal("Expected the cached lookup to be reused")
+	}
+
+	g.CacheTTL = 0
+	g.expires = g.expires.Add(-DefaultCacheTTL)
+	s, err = g.Check()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !s.UnderPressure || len(s.Reasons) != 1 || s.Reasons[0] != "node pressured has DiskPressure" {
+		t.Fatal("Expected the cluster to be under disk pressure but got", s)
+	}
+}
+
+// TestCheckPendingPods validates that a cluster is under pressure once too many pods are pending
+func TestCheckPendingPods(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	for _, name := range []string{"a", "b", "c"} {
+		_, err := client.CoreV1().Pods("default").Create(&apiv1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: name},
+			Status:     apiv1.PodStatus{Phase: apiv1.PodPending},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	s, err := New(client, 2).Check()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !s.UnderPressure || len(s.Reasons) != 1 || s.Reasons[0] != "3 pods are pending" {
+		t.Fatal("Expected the cluster to be under pressure from pending pods but got", s)
+	}
+
+	s, err = New(client, 0).Check()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.UnderPressure {
+		t.Fatal("Expected pending pods to be ignored but got", s.Reasons)
+	}
+}
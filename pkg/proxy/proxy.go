@@ -0,0 +1,80 @@
+// Package proxy configures the proxy that outbound HTTP requests are sent through, such as reports from checker
+// pods and notifications sent to chat webhooks and Alertmanager.  Many enterprise clusters only reach external
+// endpoints through a proxy, while endpoints inside the cluster have to be reached directly.  By default the
+// HTTP_PROXY, HTTPS_PROXY, and NO_PROXY environment variables are used, and each destination can override
+// them with a proxy of its own.
+package proxy
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"golang.org/x/net/http/httpproxy"
+)
+
+// Direct is the proxy setting that sends requests straight to their destination, ignoring the proxy
+// environment variables
+const Direct = "direct"
+
+// Func returns the proxy function of a destination.  A blank proxy URL uses the proxy environment variables,
+// Direct uses no proxy, and any other value is the URL of the proxy that both HTTP and HTTPS requests are sent
+// through.  noProxy is a comma separated list of hosts, domains, and CIDRs that are reached directly, in the
+// format of NO_PROXY, and is added to the NO_PROXY environment variable.
+func Func(proxyURL string, noProxy string) (func(*http.Request) (*url.URL, error), error) {
+	if proxyURL == Direct {
+		return nil, nil
+	}
+
+	config := httpproxy.FromEnvironment()
+	if len(proxyURL) > 0 {
+		err := Validate(proxyURL)
+		if err != nil {
+			return nil, err
+		}
+		config.HTTPProxy = proxyURL
+		config.HTTPSProxy = proxyURL
+	}
+	if len(noProxy) > 0 {
+		config.NoProxy = strings.Trim(config.NoProxy+","+noProxy, ",")
+	}
+
+	proxyFunc := config.ProxyFunc()
+	return func(req *http.Request) (*url.URL, error) {
+		return proxyFunc(req.URL)
+	}, nil
+}
+
+// Validate returns an error if a proxy setting is not blank, Direct, or the URL of an HTTP, HTTPS, or SOCKS5
+// proxy
+func Validate(proxyURL string) error {
+	if len(proxyURL) == 0 || proxyURL == Direct {
+		return nil
+	}
+	u, err := url.Parse(proxyURL)
+	if err != nil {
+		return fmt.Errorf("invalid proxy url %s: %w", proxyURL, err)
+	}
+	switch u.Scheme {
+	case "http", "https", "socks5":
+	default:
+		return fmt.Errorf("invalid proxy url %s: scheme must be http, https, or socks5", proxyURL)
+	}
+	if len(u.Host) == 0 {
+		return fmt.Errorf("invalid proxy url %s: a host is required", proxyURL)
+	}
+	return nil
+}
+
+// Transport returns a copy of the default HTTP transport that sends requests through the proxy of a
+// destination
+func Transport(proxyURL string, noProxy string) (*http.Transport, error) {
+	proxyFunc, err := Func(proxyURL, noProxy)
+	if err != nil {
+		return nil, err
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = proxyFunc
+	return transport, nil
+}
@@ -0,0 +1,154 @@
+// Copyright 2018 Comcast Cable Communications Management, LLC
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//     http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package ratelimit limits how fast calls are made to the Kubernetes API with a token bucket and counts the
+// calls that had to wait for it, so that throttling can be watched in metrics instead of showing up as API
+// priority and fairness rejections.
+package ratelimit
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+	"k8s.io/client-go/util/flowcontrol"
+)
+
+// Stats are the counters of a single operation made through a limiter
+type Stats struct {
+	Limiter     string        // the name of the limiter
+	Operation   string        // the kind of call, such as create or watch
+	Calls       uint64        // how many calls were made
+	Throttled   uint64        // how many calls had to wait for the limiter
+	WaitSeconds float64       // the total time calls waited for the limiter
+	wait        time.Duration // the total wait, summed before converting to seconds
+}
+
+// Limiter allows calls at a steady rate with bursts.  A nil Limiter allows every call immediately.
+type Limiter struct {
+	Name    string
+	limiter *rate.Limiter
+	qps     float32
+
+	mu    sync.Mutex
+	stats map[string]*Stats
+}
+
+// New creates a limiter that allows qps calls a second with bursts of up to burst calls.  A qps of zero or
+// less allows every call immediately, though the calls are still counted.
+func New(name string, qps float32, burst int) *Limiter {
+	limit := rate.Limit(qps)
+	if qps <= 0 {
+		limit = rate.Inf
+	}
+	if burst < 1 {
+		burst = 1
+	}
+	return &Limiter{
+		Name:    name,
+		limiter: rate.NewLimiter(limit, burst),
+		qps:     qps,
+		stats:   make(map[string]*Stats),
+	}
+}
+
+// Wait blocks until the limiter allows an operation or the context is canceled
+func (l *Limiter) Wait(ctx context.Context, operation string) error {
+	if l == nil {
+		return nil
+	}
+
+	r := l.limiter.Reserve()
+	delay := r.Delay()
+	l.record(operation, delay)
+	if delay == 0 {
+		return nil
+	}
+
+	t := time.NewTimer(delay)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		r.Cancel()
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
+
+// record counts a call and how long it waits
+func (l *Limiter) record(operation string, delay time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	s, ok := l.stats[operation]
+	if !ok {
+		s = &Stats{Limiter: l.Name, Operation: operation}
+		l.stats[operation] = s
+	}
+	s.Calls++
+	if delay > 0 {
+		s.Throttled++
+		s.wait += delay
+	}
+}
+
+// Stats returns the counters of every operation made through the limiter, sorted by operation
+func (l *Limiter) Stats() []Stats {
+	if l == nil {
+		return nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	stats := make([]Stats, 0, len(l.stats))
+	for _, s := range l.stats {
+		stat := *s
+		stat.WaitSeconds = s.wait.Seconds()
+		stats = append(stats, stat)
+	}
+	sort.Slice(stats, func(i, j int) bool { return stats[i].Operation < stats[j].Operation })
+	return stats
+}
+
+// ClientRateLimiter returns the limiter as a client-go rate limiter that counts every request under the
+// supplied operation.  It replaces the QPS and Burst of a client's rest config.
+func (l *Limiter) ClientRateLimiter(operation string) flowcontrol.RateLimiter {
+	return &clientRateLimiter{l: l, operation: operation}
+}
+
+// clientRateLimiter adapts a Limiter to the rate limiter interface of client-go
+type clientRateLimiter struct {
+	l         *Limiter
+	operation string
+}
+
+// TryAccept takes a token if one is available without waiting
+func (c *clientRateLimiter) TryAccept() bool {
+	if !c.l.limiter.Allow() {
+		return false
+	}
+	c.l.record(c.operation, 0)
+	return true
+}
+
+// Accept waits for a token
+func (c *clientRateLimiter) Accept() {
+	c.l.Wait(context.Background(), c.operation)
+}
+
+// Stop does nothing because the limiter holds no resources
+func (c *clientRateLimiter) Stop() {}
+
+// QPS returns the calls a second that the limiter allows
+func (c *clientRateLimiter) QPS() float32 {
+	return c.l.qps
+}
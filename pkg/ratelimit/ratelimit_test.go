@@ -0,0 +1,58 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// TestWait validates that calls past the burst wait for the limiter and are counted as throttled
+func TestWait(t *testing.T) {
+	l := New("pods", 100, 2)
+	start := time.Now()
+	for i := 0; i < 4; i++ {
+		err := l.Wait(context.Background(), "create")
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if time.Since(start) < time.Millisecond*15 {
+		t.Fatal("Expected calls past the burst to wait but they took", time.Since(start))
+	}
+
+	stats := l.Stats()
+	if len(stats) != 1 || stats[0].Calls != 4 || stats[0].Throttled != 2 || stats[0].WaitSeconds <= 0 {
+		t.Fatalf("Expected 4 calls with 2 throttled but got %+v", stats)
+	}
+	if stats[0].Limiter != "pods" || stats[0].Operation != "create" {
+		t.Fatalf("Expected stats labelled with the limiter and operation but got %+v", stats[0])
+	}
+}
+
+// TestWaitCanceled validates that a canceled context stops a throttled call from waiting
+func TestWaitCanceled(t *testing.T) {
+	l := New("pods", 0.001, 1)
+	l.Wait(context.Background(), "delete")
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*10)
+	defer cancel()
+	if l.Wait(ctx, "delete") == nil {
+		t.Fatal("Expected a canceled wait to return an error")
+	}
+}
+
+// TestUnlimited validates that nil and unlimited limiters never wait
+func TestUnlimited(t *testing.T) {
+	var nilLimiter *Limiter
+	if nilLimiter.Wait(context.Background(), "watch") != nil || nilLimiter.Stats() != nil {
+		t.Fatal("Expected a nil limiter to allow every call")
+	}
+
+	l := New("client", 0, 0)
+	for i := 0; i < 100; i++ {
+		l.ClientRateLimiter("request").Accept()
+	}
+	stats := l.Stats()
+	if len(stats) != 1 || stats[0].Calls != 100 || stats[0].Throttled != 0 {
+		t.Fatalf("Expected 100 unthrottled calls but got %+v", stats)
+	}
+}
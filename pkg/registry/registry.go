@@ -0,0 +1,328 @@
+// Package registry resolves the tags of container images to the digests they
+// currently point to by asking their registry with the Docker Registry HTTP
+// API, so that checker pods can be run by digest and a change of image can be
+// told apart from a change in the cluster.
+package registry
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// CacheTTL is how long a resolved digest is reused before the registry is asked again.  It keeps the checks
+// that share an image from each asking the registry when the checks are reloaded.
+var CacheTTL = time.Minute
+
+// the docker hub names that image references and docker configs use
+const dockerHub = "docker.io"
+const dockerHubAPI = "registry-1.docker.io"
+const dockerHubConfigKey = "https://index.docker.io/v1/"
+
+// manifestMediaTypes are the manifest formats accepted from registries, including lists of manifests for
+// several platforms, whose digest is the one the container runtime resolves a tag to
+var manifestMediaTypes = []string{
+	"application/vnd.docker.distribution.manifest.list.v2+json",
+	"application/vnd.oci.image.index.v1+json",
+	"application/vnd.docker.distribution.manifest.v2+json",
+	"application/vnd.oci.image.manifest.v1+json",
+}
+
+// challengeParamPattern matches the key="value" parameters of a WWW-Authenticate challenge
+var challengeParamPattern = regexp.MustCompile(`(\w+)="([^"]*)"`)
+
+// Reference is a parsed image reference
+type Reference struct {
+	Name       string // the image as it was written, without its tag or digest
+	Registry   string // the registry host, such as quay.io or docker.io
+	Repository string // the repository in the registry, such as comcast/khcheck or library/busybox
+	Tag        string // the tag, which is latest when the image has neither a tag nor a digest
+	Digest     string // the digest, if the image is already pinned to one
+}
+
+// ParseReference splits an image into its registry, repository, tag, and digest the way the container runtime
+// does, adding the docker.io registry and library repository to short names
+func ParseReference(image string) (Reference, error) {
+	if len(image) == 0 {
+		return Reference{}, errors.New("image is blank")
+	}
+	ref := Reference{}
+	name := image
+	if i := strings.Index(name, "@"); i >= 0 {
+		ref.Digest = name[i+1:]
+		name = name[:i]
+	}
+	if i := strings.LastIndex(name, ":"); i > strings.LastIndex(name, "/") {
+		ref.Tag = name[i+1:]
+		name = name[:i]
+	}
+	if len(ref.Tag) == 0 && len(ref.Digest) == 0 {
+		ref.Tag = "latest"
+	}
+	ref.Name = name
+
+	parts := strings.SplitN(name, "/", 2)
+	if len(parts) == 2 && (strings.ContainsAny(parts[0], ".:") || parts[0] == "localhost") {
+		ref.Registry = parts[0]
+		ref.Repository = parts[1]
+	} else {
+		ref.Registry = dockerHub
+		ref.Repository = name
+		if len(parts) == 1 {
+			ref.Repository = "library/" + name
+		}
+	}
+	if len(ref.Repository) == 0 {
+		return ref, fmt.Errorf("image %s has no repository", image)
+	}
+	return ref, nil
+}
+
+// Credential is a user name and password used to log in to a registry
+type Credential struct {
+	Username string
+	Password string
+}
+
+// Credentials holds the credential of each registry host
+type Credentials map[string]Credential
+
+// ParseDockerConfig reads the credentials of a .dockerconfigjson or .dockercfg image pull secret
+func ParseDockerConfig(b []byte) (Credentials, error) {
+	type auth struct {
+		Username string `json:"username"`
+		Password string `json:"password"`
+		Auth     string `json:"auth"`
+	}
+	var config struct {
+		Auths map[string]auth `json:"auths"`
+	}
+	err := json.Unmarshal(b, &config)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing docker config: %w", err)
+	}
+	if config.Auths == nil {
+		// the older .dockercfg format is the map of auths itself
+		err = json.Unmarshal(b, &config.Auths)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing docker config: %w", err)
+		}
+	}
+
+	creds := make(Credentials)
+	for host, a := range config.Auths {
+		if len(a.Auth) > 0 {
+			decoded, err := base64.StdEncoding.DecodeString(a.Auth)
+			if err != nil {
+				return nil, fmt.Errorf("error decoding docker config auth of %s: %w", host, err)
+			}
+			userPass := strings.SplitN(string(decoded), ":", 2)
+			if len(userPass) == 2 {
+				a.Username, a.Password = userPass[0], userPass[1]
+			}
+		}
+		creds[configHost(host)] = Credential{Username: a.Username, Password: a.Password}
+	}
+	return creds, nil
+}
+
+// configHost returns the registry host of a docker config key, which can be a URL
+func configHost(key string) string {
+	if key == dockerHubConfigKey {
+		return dockerHub
+	}
+	if u, err := url.Parse(key); err == nil && len(u.Host) > 0 {
+		return u.Host
+	}
+	return strings.TrimSuffix(key, "/")
+}
+
+// Resolver resolves image tags to digests and caches what it resolved for CacheTTL
+type Resolver struct {
+	Client *http.Client
+	mu     sync.Mutex
+	cache  map[string]cachedDigest
+}
+
+// cachedDigest is a resolved digest and when it has to be resolved again
+type cachedDigest struct {
+	digest  string
+	expires time.Time
+}
+
+// NewResolver creates a resolver that asks registries with the supplied HTTP client
+func NewResolver(client *http.Client) *Resolver {
+	return &Resolver{
+		Client: client,
+		cache:  make(map[string]cachedDigest),
+	}
+}
+
+// Resolve returns the image pinned to the digest its tag currently points to, such as
+// quay.io/comcast/khcheck@sha256:0123.  Images that already name a digest are returned as they are.  The
+// credentials are used for registries that require a login.
+func (r *Resolver) Resolve(image string, creds Credentials) (string, error) {
+	ref, err := ParseReference(image)
+	if err != nil {
+		return "", err
+	}
+	if len(ref.Digest) > 0 {
+		return image, nil
+	}
+
+	r.mu.Lock()
+	cached, ok := r.cache[image]
+	r.mu.Unlock()
+	if ok && time.Now().Before(cached.expires) {
+		return ref.Name + "@" + cached.digest, nil
+	}
+
+	digest, err := r.manifestDigest(ref, creds[ref.Registry])
+	if err != nil {
+		return "", fmt.Errorf("error resolving digest of image %s: %w", image, err)
+	}
+	r.mu.Lock()
+	r.cache[image] = cachedDigest{digest: digest, expires: time.Now().Add(CacheTTL)}
+	r.mu.Unlock()
+	return ref.Name + "@" + digest, nil
+}
+
+// manifestDigest fetches the digest of the manifest a tag points to, logging in to the registry when it
+// challenges the request
+func (r *Resolver) manifestDigest(ref Reference, cred Credential) (string, error) {
+	host := ref.Registry
+	if host == dockerHub {
+		host = dockerHubAPI
+	}
+	manifestURL := "https://" + host + "/v2/" + ref.Repository + "/manifests/" + ref.Tag
+
+	// the manifest is asked for without logging in first, since most public registries still answer with the
+	// login they expect
+	resp, err := r.manifestRequest(http.MethodHead, manifestURL, "")
+	if err != nil {
+		return "", err
+	}
+	resp.Body.Close()
+	if resp.StatusCode == http.StatusUnauthorized {
+		authorization, err := r.authorize(resp.Header.Get("WWW-Authenticate"), ref, cred)
+		if err != nil {
+			return "", err
+		}
+		resp, err = r.manifestRequest(http.MethodHead, manifestURL, authorization)
+		if err != nil {
+			return "", err
+		}
+		resp.Body.Close()
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("registry %s answered %s for %s:%s", ref.Registry, resp.Status, ref.Repository, ref.Tag)
+	}
+	if digest := resp.Header.Get("Docker-Content-Digest"); len(digest) > 0 {
+		return digest, nil
+	}
+
+	// registries that do not send the digest with the manifest headers have the manifest hashed instead
+	resp, err = r.manifestRequest(http.MethodGet, manifestURL, resp.Request.Header.Get("Authorization"))
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("registry %s answered %s for %s:%s", ref.Registry, resp.Status, ref.Repository, ref.Tag)
+	}
+	hash := sha256.New()
+	_, err = io.Copy(hash, resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("error reading manifest of %s:%s: %w", ref.Repository, ref.Tag, err)
+	}
+	return "sha256:" + hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// manifestRequest requests a manifest in any of the accepted formats
+func (r *Resolver) manifestRequest(method string, manifestURL string, authorization string) (*http.Response, error) {
+	req, err := http.NewRequest(method, manifestURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", strings.Join(manifestMediaTypes, ", "))
+	if len(authorization) > 0 {
+		req.Header.Set("Authorization", authorization)
+	}
+	return r.Client.Do(req)
+}
+
+// authorize answers the login challenge of a registry with the Authorization header of the next request.
+// Basic challenges are answered with the credential itself, and bearer challenges with a token fetched from
+// the realm of the challenge.
+func (r *Resolver) authorize(challenge string, ref Reference, cred Credential) (string, error) {
+	scheme := strings.ToLower(strings.SplitN(challenge, " ", 2)[0])
+	params := make(map[string]string)
+	for _, m := range challengeParamPattern.FindAllStringSubmatch(challenge, -1) {
+		params[strings.ToLower(m[1])] = m[2]
+	}
+
+	switch scheme {
+	case "basic":
+		if len(cred.Username) == 0 {
+			return "", fmt.Errorf("registry %s requires a login, but no image pull secret has credentials for it", ref.Registry)
+		}
+		return "Basic " + base64.StdEncoding.EncodeToString([]byte(cred.Username+":"+cred.Password)), nil
+	case "bearer":
+		realm := params["realm"]
+		if len(realm) == 0 {
+			return "", fmt.Errorf("registry %s sent a bearer challenge without a realm", ref.Registry)
+		}
+		query := url.Values{}
+		if len(params["service"]) > 0 {
+			query.Set("service", params["service"])
+		}
+		scope := params["scope"]
+		if len(scope) == 0 {
+			scope = "repository:" + ref.Repository + ":pull"
+		}
+		query.Set("scope", scope)
+		req, err := http.NewRequest(http.MethodGet, realm+"?"+query.Encode(), nil)
+		if err != nil {
+			return "", err
+		}
+		if len(cred.Username) > 0 {
+			req.SetBasicAuth(cred.Username, cred.Password)
+		}
+		resp, err := r.Client.Do(req)
+		if err != nil {
+			return "", fmt.Errorf("error fetching registry token from %s: %w", realm, err)
+		}
+		defer resp.Body.Close()
+		b, err := ioutil.ReadAll(resp.Body)
+		if err != nil {
+			return "", fmt.Errorf("error reading registry token from %s: %w", realm, err)
+		}
+		if resp.StatusCode != http.StatusOK {
+			return "", fmt.Errorf("registry token server %s answered %s", realm, resp.Status)
+		}
+		var token struct {
+			Token       string `json:"token"`
+			AccessToken string `json:"access_token"`
+		}
+		err = json.Unmarshal(b, &token)
+		if err != nil {
+			return "", fmt.Errorf("error parsing registry token from %s: %w", realm, err)
+		}
+		if len(token.Token) == 0 {
+			token.Token = token.AccessToken
+		}
+		return "Bearer " + token.Token, nil
+	}
+	return "", fmt.Errorf("registry %s requires an unsupported login scheme: %s", ref.Registry, challenge)
+}
@@ -0,0 +1,106 @@
+package registry
+
+import (
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// TestParseReference validates that images are split into their registry, repository, tag, and digest
+func TestParseReference(t *testing.T) {
+	for image, expected := range map[string]Reference{
+		"busybox":                              {Name: "busybox", Registry: "docker.io", Repository: "library/busybox", Tag: "latest"},
+		"kuberhealthy/khcheck:1.0":             {Name: "kuberhealthy/khcheck", Registry: "docker.io", Repository: "kuberhealthy/khcheck", Tag: "1.0"},
+		"localhost:5000/check:v2":              {Name: "localhost:5000/check", Registry: "localhost:5000", Repository: "check", Tag: "v2"},
+		"quay.io/comcast/khcheck@sha256:0123":  {Name: "quay.io/comcast/khcheck", Registry: "quay.io", Repository: "comcast/khcheck", Digest: "sha256:0123"},
+		"quay.io/comcast/khcheck:1@sha256:abc": {Name: "quay.io/comcast/khcheck", Registry: "quay.io", Repository: "comcast/khcheck", Tag: "1", Digest: "sha256:abc"},
+	} {
+		ref, err := ParseReference(image)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ref != expected {
+			t.Fatal("Expected image", image, "to be parsed as", expected, "but got", ref)
+		}
+	}
+}
+
+// TestParseDockerConfig validates that credentials are read from image pull secrets
+func TestParseDockerConfig(t *testing.T) {
+	auth := base64.StdEncoding.EncodeToString([]byte("robot:secret"))
+	creds, err := ParseDockerConfig([]byte(`{"auths":{"https://index.docker.io/v1/":{"auth":"` + auth + `"},"quay.io":{"username":"user","password":"pass"}}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if creds["docker.io"].Username != "robot" || creds["docker.io"].Password != "secret" {
+		t.Fatal("Expected the docker hub credential from its auth but got", creds["docker.io"])
+	}
+	if creds["quay.io"].Username != "user" {
+		t.Fatal("Expected the quay.io credential but got", creds["quay.io"])
+	}
+}
+
+// TestResolve validates that tags are resolved to digests through a bearer token login and that the result
+// is cached
+func TestResolve(t *testing.T) {
+	var manifestRequests int
+	var server *httptest.Server
+	server = httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/token":
+			user, pass, _ := r.BasicAuth()
+			if user != "robot" || pass != "secret" || r.URL.Query().Get("scope") != "repository:checks/http:pull" {
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
+			w.Write([]byte(`{"token":"abc"}`))
+		case "/v2/checks/http/manifests/1.0":
+			manifestRequests++
+			if r.Header.Get("Authorization") != "Bearer abc" {
+				w.Header().Set("WWW-Authenticate", `Bearer realm="`+server.URL+`/token",service="registry",scope="repository:checks/http:pull"`)
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			if !strings.Contains(r.Header.Get("Accept"), "manifest.list.v2+json") {
+				w.WriteHeader(http.StatusNotAcceptable)
+				return
+			}
+			w.Header().Set("Docker-Content-Digest", "sha256:0123")
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	host := strings.TrimPrefix(server.URL, "https://")
+	image := host + "/checks/http:1.0"
+	resolver := NewResolver(server.Client())
+	creds := Credentials{host: {Username: "robot", Password: "secret"}}
+	pinned, err := resolver.Resolve(image, creds)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pinned != host+"/checks/http@sha256:0123" {
+		t.Fatal("Expected the image to be pinned to its digest but got", pinned)
+	}
+
+	requests := manifestRequests
+	_, err = resolver.Resolve(image, creds)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if manifestRequests != requests {
+		t.Fatal("Expected the digest to be cached")
+	}
+
+	_, err = resolver.Resolve(host+"/checks/missing:1.0", creds)
+	if err == nil {
+		t.Fatal("Expected an error for an image the registry does not have")
+	}
+	same, err := resolver.Resolve("quay.io/comcast/khcheck@sha256:abc", nil)
+	if err != nil || same != "quay.io/comcast/khcheck@sha256:abc" {
+		t.Fatal("Expected an image with a digest to be left alone but got", same, err)
+	}
+}
@@ -0,0 +1,189 @@
+// Copyright 2018 Comcast Cable Communications Management, LLC
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//     http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package responsecache holds rendered HTTP responses for a short time so that many clients polling the same
+// endpoint share the work of rendering it.  Cached responses are served with an ETag so that clients can
+// revalidate them without transferring the body again, and are gzipped for clients that accept it.
+package responsecache
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// minGzipSize is the smallest body that is gzipped.  Smaller bodies are not worth compressing.
+const minGzipSize = 1024
+
+// Cache holds rendered responses by key until their time to live passes
+type Cache struct {
+	TTL time.Duration // how long a rendered response is served before it is rendered again.  Zero disables caching.
+
+	mu      sync.Mutex
+	entries map[string]*Response
+}
+
+// Response is a rendered response along with its ETag and gzipped body
+type Response struct {
+	header  http.Header
+	code    int
+	body    bytes.Buffer
+	gzipped []byte
+	etag    string
+	expires time.Time
+	ready   chan struct{} // closed once the response has been rendered
+}
+
+// New creates a cache that holds responses for the supplied time to live
+func New(ttl time.Duration) *Cache {
+	return &Cache{
+		TTL:     ttl,
+		entries: make(map[string]*Response),
+	}
+}
+
+// Get returns the cached response for a key, or renders it with render if it is not cached or has expired.
+// Concurrent callers asking for the same expired key wait for a single render.
+func (c *Cache) Get(key string, render func(w http.ResponseWriter)) *Response {
+	now := time.Now()
+	if c.TTL <= 0 {
+		r := newResponse(now)
+		r.render(render)
+		return r
+	}
+
+	c.mu.Lock()
+	r, ok := c.entries[key]
+	if ok && now.Before(r.expires) {
+		c.mu.Unlock()
+		<-r.ready
+		return r
+	}
+
+	// forget expired responses so that the cache does not grow with every distinct key ever requested
+	for k, e := range c.entries {
+		if !now.Before(e.expires) {
+			delete(c.entries, k)
+		}
+	}
+	r = newResponse(now.Add(c.TTL))
+	c.entries[key] = r
+	c.mu.Unlock()
+
+	r.render(render)
+	return r
+}
+
+// newResponse creates a response that has not been rendered yet
+func newResponse(expires time.Time) *Response {
+	return &Response{
+		header:  make(http.Header),
+		code:    http.StatusOK,
+		expires: expires,
+		ready:   make(chan struct{}),
+	}
+}
+
+// render renders the response and computes its ETag and gzipped body
+func (r *Response) render(render func(w http.ResponseWriter)) {
+	defer close(r.ready)
+	render(&recorder{r: r})
+
+	sum := sha256.Sum256(r.body.Bytes())
+	r.etag = `"` + hex.EncodeToString(sum[:16]) + `"`
+	if r.body.Len() >= minGzipSize {
+		var b bytes.Buffer
+		gz := gzip.NewWriter(&b)
+		_, err := gz.Write(r.body.Bytes())
+		if err == nil && gz.Close() == nil {
+			r.gzipped = b.Bytes()
+		}
+	}
+}
+
+// ServeHTTP writes the response to a client.  Successful responses whose ETag matches the If-None-Match
+// header of the request are answered with a 304 and no body.
+func (r *Response) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	for k, v := range r.header {
+		w.Header()[k] = v
+	}
+	w.Header().Set("ETag", r.etag)
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Vary", "Accept, Accept-Encoding")
+
+	if r.code == http.StatusOK && etagMatches(req.Header.Get("If-None-Match"), r.etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	body := r.body.Bytes()
+	if r.gzipped != nil && acceptsGzip(req) {
+		w.Header().Set("Content-Encoding", "gzip")
+		body = r.gzipped
+	}
+	w.WriteHeader(r.code)
+	w.Write(body)
+}
+
+// etagMatches returns true if an If-None-Match header holds the supplied ETag
+func etagMatches(ifNoneMatch string, etag string) bool {
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == etag || candidate == "*" {
+			return true
+		}
+	}
+	return false
+}
+
+// acceptsGzip returns true if the client accepts gzip encoded responses
+func acceptsGzip(req *http.Request) bool {
+	for _, encoding := range strings.Split(req.Header.Get("Accept-Encoding"), ",") {
+		parts := strings.Split(encoding, ";")
+		if strings.TrimSpace(parts[0]) != "gzip" {
+			continue
+		}
+		// gzip;q=0 means the client refuses gzip
+		return len(parts) == 1 || strings.TrimSpace(parts[1]) != "q=0"
+	}
+	return false
+}
+
+// recorder renders a response into a Response
+type recorder struct {
+	r           *Response
+	wroteHeader bool
+}
+
+// Header returns the headers of the response
+func (rec *recorder) Header() http.Header {
+	return rec.r.header
+}
+
+// WriteHeader records the status code of the response
+func (rec *recorder) WriteHeader(code int) {
+	if rec.wroteHeader {
+		return
+	}
+	rec.wroteHeader = true
+	rec.r.code = code
+}
+
+// Write records the body of the response
+func (rec *recorder) Write(b []byte) (int, error) {
+	rec.wroteHeader = true
+	return rec.r.body.Write(b)
+}
@@ -0,0 +1,117 @@
+package responsecache
+
+import (
+	"compress/gzip"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// TestGet validates that responses are rendered once per time to live and shared by concurrent callers
+func TestGet(t *testing.T) {
+	c := New(time.Hour)
+	var renders int
+	var mu sync.Mutex
+	render := func(w http.ResponseWriter) {
+		mu.Lock()
+		renders++
+		mu.Unlock()
+		time.Sleep(time.Millisecond * 10)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"OK":true}`))
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			c.Get("status", render)
+		}()
+	}
+	wg.Wait()
+	if renders != 1 {
+		t.Fatal("Expected concurrent callers to share one render but got", renders)
+	}
+	c.Get("other", render)
+	if renders != 2 {
+		t.Fatal("Expected a different key to be rendered separately but got", renders)
+	}
+
+	// expired responses are rendered again
+	c.entries["status"].expires = time.Now().Add(-time.Second)
+	c.Get("status", render)
+	if renders != 3 {
+		t.Fatal("Expected the expired response to be rendered again but got", renders)
+	}
+
+	// caching is disabled without a time to live
+	c = New(0)
+	c.Get("status", render)
+	c.Get("status", render)
+	if renders != 5 || len(c.entries) != 0 {
+		t.Fatal("Expected every call to render without a time to live but got", renders)
+	}
+}
+
+// TestServeHTTP validates ETag revalidation and gzip encoding
+func TestServeHTTP(t *testing.T) {
+	body := `{"OK":true,"Errors":["` + strings.Repeat("a", minGzipSize) + `"]}`
+	r := New(time.Hour).Get("status", func(w http.ResponseWriter) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(body))
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	etag := w.Header().Get("ETag")
+	if w.Code != http.StatusOK || w.Body.String() != body || len(etag) == 0 || w.Header().Get("Content-Type") != "application/json" {
+		t.Fatal("Expected the body with an ETag but got", w.Code, w.Header())
+	}
+
+	req.Header.Set("If-None-Match", etag)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusNotModified || w.Body.Len() != 0 {
+		t.Fatal("Expected a 304 for a matching ETag but got", w.Code)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Accept-Encoding", "deflate, gzip")
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Header().Get("Content-Encoding") != "gzip" {
+		t.Fatal("Expected a gzipped body but got", w.Header())
+	}
+	gz, err := gzip.NewReader(w.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := ioutil.ReadAll(gz)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != body {
+		t.Fatal("Expected the gzipped body to decompress to the body but got", string(b))
+	}
+}
+
+// TestServeHTTPError validates that error responses keep their status code and are never answered with a 304
+func TestServeHTTPError(t *testing.T) {
+	r := New(time.Hour).Get("status", func(w http.ResponseWriter) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		w.Write([]byte("FAILING\n"))
+	})
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("If-None-Match", "*")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusServiceUnavailable || w.Body.String() != "FAILING\n" {
+		t.Fatal("Expected the 503 to be served but got", w.Code, w.Body.String())
+	}
+}
@@ -0,0 +1,103 @@
+// Package rolepolicy restricts the RBAC rules that khchecks can request for
+// the service accounts Kuberhealthy creates for them to an allowlist, so that
+// the owners of khchecks in a shared cluster can not grant their checker pods
+// arbitrary permissions.
+package rolepolicy
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	rbacv1 "k8s.io/api/rbac/v1"
+)
+
+// Policy is an allowlist of the verbs checks can be granted on resources.  A policy without any entries allows
+// no rules at all.
+type Policy struct {
+	entries []entry
+	strings []string
+}
+
+// entry allows the verbs that match verb on the resources that match resource in the API groups that match group
+type entry struct {
+	verb     *regexp.Regexp
+	resource *regexp.Regexp
+	group    *regexp.Regexp
+}
+
+// ParsePolicy builds a policy from a comma separated list of entries in the format verb:resource.group, such as
+// get:pods, list:deployments.apps, or *:configmaps.  Resources without a group are in the core API group.  A *
+// in any part matches any characters, including the * that a rule uses to request every verb or resource.
+func ParsePolicy(s string) (Policy, error) {
+	p := Policy{}
+	for _, e := range strings.Split(s, ",") {
+		e = strings.TrimSpace(e)
+		if len(e) == 0 {
+			continue
+		}
+
+		parts := strings.SplitN(e, ":", 2)
+		if len(parts) != 2 || len(parts[0]) == 0 || len(parts[1]) == 0 {
+			return p, fmt.Errorf("invalid role allowlist entry %s: expected verb:resource.group", e)
+		}
+		resource, group := parts[1], ""
+		if i := strings.Index(resource, "."); i >= 0 {
+			resource, group = resource[:i], resource[i+1:]
+		}
+		p.entries = append(p.entries, entry{
+			verb:     globToRegexp(parts[0]),
+			resource: globToRegexp(resource),
+			group:    globToRegexp(group),
+		})
+		p.strings = append(p.strings, e)
+	}
+	return p, nil
+}
+
+// globToRegexp returns a regular expression that matches the whole string against a glob whose * matches any
+// characters
+func globToRegexp(glob string) *regexp.Regexp {
+	return regexp.MustCompile("^" + strings.Replace(regexp.QuoteMeta(glob), `\*`, ".*", -1) + "$")
+}
+
+// String returns the entries of the policy as they were configured
+func (p Policy) String() string {
+	return strings.Join(p.strings, ",")
+}
+
+// allows returns true if an entry of the policy allows the verb on the resource in the API group
+func (p Policy) allows(verb, resource, group string) bool {
+	for _, e := range p.entries {
+		if e.verb.MatchString(verb) && e.resource.MatchString(resource) && e.group.MatchString(group) {
+			return true
+		}
+	}
+	return false
+}
+
+// Validate returns an error for the first permission granted by the rules that the policy does not allow.  Every
+// combination of the API groups, resources, and verbs of a rule must be allowed.  Rules for non resource URLs are
+// never allowed, since they can not be granted by a role.
+func (p Policy) Validate(rules []rbacv1.PolicyRule) error {
+	for _, rule := range rules {
+		if len(rule.NonResourceURLs) > 0 {
+			return fmt.Errorf("rules for non resource urls %s are not allowed", strings.Join(rule.NonResourceURLs, ","))
+		}
+		for _, group := range rule.APIGroups {
+			for _, resource := range rule.Resources {
+				for _, verb := range rule.Verbs {
+					if p.allows(verb, resource, group) {
+						continue
+					}
+					name := resource
+					if len(group) > 0 {
+						name += "." + group
+					}
+					return fmt.Errorf("%s:%s is not allowed by the check role allowlist %q", verb, name, p)
+				}
+			}
+		}
+	}
+	return nil
+}
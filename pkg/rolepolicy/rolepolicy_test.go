@@ -0,0 +1,65 @@
+package rolepolicy
+
+import (
+	"testing"
+
+	rbacv1 "k8s.io/api/rbac/v1"
+)
+
+// TestParsePolicy validates that malformed allowlist entries are rejected
+func TestParsePolicy(t *testing.T) {
+	_, err := ParsePolicy("get:pods, list:deployments.apps,*:configmaps")
+	if err != nil {
+		t.Fatal("Expected a valid allowlist to parse but got", err)
+	}
+	for _, s := range []string{"get", "get:", ":pods"} {
+		_, err = ParsePolicy(s)
+		if err == nil {
+			t.Fatal("Expected an error for the allowlist entry", s)
+		}
+	}
+}
+
+// TestValidate validates that rules are only allowed when every permission they grant is in the allowlist
+func TestValidate(t *testing.T) {
+	p, err := ParsePolicy("get:pods,list:pods,get:pods/log,get:deployments.apps,*:configmaps")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var tests = []struct {
+		rule    rbacv1.PolicyRule
+		allowed bool
+	}{
+		{rbacv1.PolicyRule{APIGroups: []string{""}, Resources: []string{"pods", "pods/log"}, Verbs: []string{"get"}}, true},
+		{rbacv1.PolicyRule{APIGroups: []string{""}, Resources: []string{"pods"}, Verbs: []string{"get", "list"}}, true},
+		{rbacv1.PolicyRule{APIGroups: []string{""}, Resources: []string{"configmaps"}, Verbs: []string{"*"}}, true},
+		{rbacv1.PolicyRule{APIGroups: []string{"apps"}, Resources: []string{"deployments"}, Verbs: []string{"get"}}, true},
+		{rbacv1.PolicyRule{APIGroups: []string{""}, Resources: []string{"pods"}, Verbs: []string{"delete"}}, false},
+		{rbacv1.PolicyRule{APIGroups: []string{""}, Resources: []string{"pods"}, Verbs: []string{"*"}}, false},
+		{rbacv1.PolicyRule{APIGroups: []string{""}, Resources: []string{"*"}, Verbs: []string{"get"}}, false},
+		{rbacv1.PolicyRule{APIGroups: []string{"*"}, Resources: []string{"deployments"}, Verbs: []string{"get"}}, false},
+		{rbacv1.PolicyRule{APIGroups: []string{"rbac.authorization.k8s.io"}, Resources: []string{"roles"}, Verbs: []string{"create"}}, false},
+		{rbacv1.PolicyRule{NonResourceURLs: []string{"/metrics"}, Verbs: []string{"get"}}, false},
+	}
+	for _, test := range tests {
+		err := p.Validate([]rbacv1.PolicyRule{test.rule})
+		if test.allowed && err != nil {
+			t.Fatal("Expected rule", test.rule, "to be allowed but got", err)
+		}
+		if !test.allowed && err == nil {
+			t.Fatal("Expected rule", test.rule, "to be rejected")
+		}
+	}
+
+	empty, err := ParsePolicy("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := empty.Validate(nil); err != nil {
+		t.Fatal("Expected a service account without rules to be allowed but got", err)
+	}
+	if err := empty.Validate([]rbacv1.PolicyRule{tests[0].rule}); err == nil {
+		t.Fatal("Expected an empty allowlist to reject every rule")
+	}
+}
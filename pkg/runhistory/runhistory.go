@@ -0,0 +1,172 @@
+// Package runhistory keeps a bounded, in-memory record of recent check runs keyed by their run UUID
+// so that callers can poll for the result of a specific run.
+package runhistory
+
+import (
+	"sync"
+	"time"
+)
+
+// Phase is the lifecycle phase of a check run
+type Phase string
+
+// The phases a check run moves through.  Pending runs were triggered but have not started yet.
+const (
+	PhasePending   Phase = "Pending"
+	PhaseRunning   Phase = "Running"
+	PhaseSucceeded Phase = "Succeeded"
+	PhaseFailed    Phase = "Failed"
+	PhaseTimedOut  Phase = "TimedOut"
+	PhaseSkipped   Phase = "Skipped"
+)
+
+// Done returns true if the phase is final
+func (p Phase) Done() bool {
+	return p != PhasePending && p != PhaseRunning
+}
+
+// Run is the record of a single check run
+type Run struct {
+	ID          string     `json:"id"`
+	Name        string     `json:"name"`
+	Namespace   string     `json:"namespace"`
+	Phase       Phase      `json:"phase"`
+	Errors      []string   `json:"errors"`
+	Triggered   bool       `json:"triggered"` // the run was triggered through the API rather than by its interval
+	Started     *time.Time `json:"started,omitempty"`
+	Finished    *time.Time `json:"finished,omitempty"`
+	Duration    string     `json:"duration,omitempty"`
+	Image       string     `json:"image,omitempty"`       // the image of the main container of the checker pod, as written in its pod spec
+	ImageDigest string     `json:"imageDigest,omitempty"` // the digest of the image the main container actually ran
+}
+
+// History holds the most recent check runs.  Once more than the maximum number of runs are recorded,
+// the oldest runs are forgotten.
+type History struct {
+	mu    sync.RWMutex
+	runs  map[string]*Run
+	order []string // run IDs from oldest to newest
+	max   int
+}
+
+// New creates a History that remembers up to max runs
+func New(max int) *History {
+	return &History{
+		runs: make(map[string]*Run),
+		max:  max,
+	}
+}
+
+// add records a new run and forgets the oldest runs if there are too many.  The caller must hold the lock.
+func (h *History) add(r *Run) {
+	if _, exists := h.runs[r.ID]; !exists {
+		h.order = append(h.order, r.ID)
+	}
+	h.runs[r.ID] = r
+	for len(h.order) > h.max {
+		delete(h.runs, h.order[0])
+		h.order = h.order[1:]
+	}
+}
+
+// Queue records a triggered run that has not started yet
+func (h *History) Queue(id string, namespace string, name string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.add(&Run{
+		ID:        id,
+		Name:      name,
+		Namespace: namespace,
+		Phase:     PhasePending,
+		Errors:    []string{},
+		Triggered: true,
+	})
+}
+
+// Start marks a run as running.  Runs that were not queued are recorded as they start.
+func (h *History) Start(id string, namespace string, name string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	now := time.Now()
+	r, ok := h.runs[id]
+	if !ok {
+		r = &Run{
+			ID:        id,
+			Name:      name,
+			Namespace: namespace,
+			Errors:    []string{},
+		}
+		h.add(r)
+	}
+	r.Phase = PhaseRunning
+	r.Started = &now
+}
+
+// Finish records the outcome of a run
+func (h *History) Finish(id string, phase Phase, errors []string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	r, ok := h.runs[id]
+	if !ok {
+		return
+	}
+	now := time.Now()
+	r.Phase = phase
+	r.Finished = &now
+	if errors != nil {
+		r.Errors = errors
+	}
+	if r.Started != nil {
+		r.Duration = now.Sub(*r.Started).String()
+	}
+}
+
+// SetImage records the image the checker pod of a run ran and its digest
+func (h *History) SetImage(id string, image string, digest string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	r, ok := h.runs[id]
+	if !ok {
+		return
+	}
+	r.Image = image
+	r.ImageDigest = digest
+}
+
+// Remove forgets the run with the supplied ID
+func (h *History) Remove(id string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if _, ok := h.runs[id]; !ok {
+		return
+	}
+	delete(h.runs, id)
+	for i, existing := range h.order {
+		if existing == id {
+			h.order = append(h.order[:i], h.order[i+1:]...)
+			break
+		}
+	}
+}
+
+// Get returns a copy of the run with the supplied ID
+func (h *History) Get(id string) (Run, bool) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	r, ok := h.runs[id]
+	if !ok {
+		return Run{}, false
+	}
+	return *r, true
+}
+
+// List returns a copy of every run that is remembered, from oldest to newest
+func (h *History) List() []Run {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	runs := make([]Run, 0, len(h.order))
+	for _, id := range h.order {
+		runs = append(runs, *h.runs[id])
+	}
+	return runs
+}
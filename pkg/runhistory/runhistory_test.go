@@ -0,0 +1,114 @@
+package runhistory
+
+import (
+	"testing"
+)
+
+// TestRunLifecycle validates that a triggered run moves through its phases
+func TestRunLifecycle(t *testing.T) {
+	h := New(10)
+
+	h.Queue("a", "kuberhealthy", "deployment")
+	r, ok := h.Get("a")
+	if !ok {
+		t.Fatal("Expected queued run to be found")
+	}
+	if r.Phase != PhasePending || !r.Triggered || r.Started != nil {
+		t.Fatalf("Unexpected queued run: %+v", r)
+	}
+
+	h.Start("a", "kuberhealthy", "deployment")
+	r, _ = h.Get("a")
+	if r.Phase != PhaseRunning || r.Started == nil {
+		t.Fatalf("Unexpected running run: %+v", r)
+	}
+
+	h.Finish("a", PhaseFailed, []string{"broken"})
+	r, _ = h.Get("a")
+	if r.Phase != PhaseFailed || r.Finished == nil || len(r.Duration) == 0 {
+		t.Fatalf("Unexpected finished run: %+v", r)
+	}
+	if len(r.Errors) != 1 || r.Errors[0] != "broken" {
+		t.Fatal("Expected run errors to be recorded but got:", r.Errors)
+	}
+	if !r.Phase.Done() {
+		t.Fatal("Expected failed phase to be done")
+	}
+
+	h.SetImage("a", "quay.io/comcast/deployment-check@sha256:0123", "sha256:0123")
+	r, _ = h.Get("a")
+	if r.Image != "quay.io/comcast/deployment-check@sha256:0123" || r.ImageDigest != "sha256:0123" {
+		t.Fatalf("Expected the image of the run to be recorded: %+v", r)
+	}
+}
+
+// TestUntriggeredRun validates that runs which were never queued are recorded when they start
+func TestUntriggeredRun(t *testing.T) {
+	h := New(10)
+	h.Start("b", "kuberhealthy", "dns")
+	r, ok := h.Get("b")
+	if !ok {
+		t.Fatal("Expected started run to be found")
+	}
+	if r.Triggered || r.Phase != PhaseRunning {
+		t.Fatalf("Unexpected started run: %+v", r)
+	}
+
+	// finishing an unknown run does nothing
+	h.Finish("unknown", PhaseSucceeded, nil)
+	_, ok = h.Get("unknown")
+	if ok {
+		t.Fatal("Expected unknown run to not be recorded")
+	}
+}
+
+// TestEviction validates that only the most recent runs are kept
+func TestEviction(t *testing.T) {
+	h := New(2)
+	h.Start("1", "ns", "check")
+	h.Start("2", "ns", "check")
+	h.Start("3", "ns", "check")
+
+	if _, ok := h.Get("1"); ok {
+		t.Fatal("Expected the oldest run to be forgotten")
+	}
+	for _, id := range []string{"2", "3"} {
+		if _, ok := h.Get(id); !ok {
+			t.Fatal("Expected run", id, "to be kept")
+		}
+	}
+}
+
+// TestRemove validates that removed runs are forgotten
+func TestRemove(t *testing.T) {
+	h := New(2)
+	h.Queue("1", "ns", "check")
+	h.Remove("1")
+	if _, ok := h.Get("1"); ok {
+		t.Fatal("Expected removed run to be forgotten")
+	}
+
+	// removed runs no longer count against the maximum
+	h.Start("2", "ns", "check")
+	h.Start("3", "ns", "check")
+	if _, ok := h.Get("2"); !ok {
+		t.Fatal("Expected run 2 to be kept")
+	}
+}
+
+// TestList validates that runs are listed from oldest to newest as copies
+func TestList(t *testing.T) {
+	h := New(2)
+	h.Start("1", "ns", "check")
+	h.Start("2", "ns", "check")
+	h.Start("3", "ns", "check")
+
+	runs := h.List()
+	if len(runs) != 2 || runs[0].ID != "2" || runs[1].ID != "3" {
+		t.Fatalf("Expected the two newest runs in order but got %+v", runs)
+	}
+	runs[0].Phase = PhaseFailed
+	if r, _ := h.Get("2"); r.Phase != PhaseRunning {
+		t.Fatal("Expected listed runs to be copies")
+	}
+}
@@ -0,0 +1,25 @@
+package scheduler
+
+import "time"
+
+// Backoff lengthens the interval of a check that keeps failing so that a broken dependency is not hammered
+type Backoff struct {
+	Threshold   int           // the number of consecutive failures before backing off.  Zero disables backoff.
+	MaxInterval time.Duration // the longest interval a check is backed off to
+}
+
+// Interval returns the interval a check should run on after the supplied number of consecutive failures.
+// The interval doubles with each failure from the threshold onward, up to the maximum interval.
+func (b Backoff) Interval(interval time.Duration, failures int) time.Duration {
+	if b.Threshold <= 0 || failures < b.Threshold || b.MaxInterval <= interval {
+		return interval
+	}
+	backedOff := interval
+	for i := b.Threshold; i <= failures && backedOff < b.MaxInterval; i++ {
+		backedOff *= 2
+	}
+	if backedOff > b.MaxInterval {
+		backedOff = b.MaxInterval
+	}
+	return backedOff
+}
@@ -0,0 +1,30 @@
+package scheduler
+
+import (
+	"testing"
+	"time"
+)
+
+// TestBackoffInterval validates that intervals double after the threshold and stop at the maximum
+func TestBackoffInterval(t *testing.T) {
+	b := Backoff{Threshold: 3, MaxInterval: time.Minute * 10}
+	expected := map[int]time.Duration{
+		0: time.Minute,
+		2: time.Minute,
+		3: time.Minute * 2,
+		4: time.Minute * 4,
+		5: time.Minute * 8,
+		6: time.Minute * 10,
+		9: time.Minute * 10,
+	}
+	for failures, interval := range expected {
+		if got := b.Interval(time.Minute, failures); got != interval {
+			t.Fatal("Expected interval", interval, "after", failures, "failures but got", got)
+		}
+	}
+
+	// backoff is disabled without a threshold
+	if got := (Backoff{MaxInterval: time.Hour}).Interval(time.Minute, 10); got != time.Minute {
+		t.Fatal("Expected backoff to be disabled but got", got)
+	}
+}
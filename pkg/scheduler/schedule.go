@@ -0,0 +1,96 @@
+package scheduler
+
+import (
+	"sync"
+	"time"
+)
+
+// Stats are the scheduling statistics of a single check
+type Stats struct {
+	Drift       time.Duration // how late the most recent scheduled run started
+	SkippedRuns int           // the number of scheduled runs that were skipped because a run overran them
+	Interval    time.Duration // the current interval between runs, including any backoff
+}
+
+// Schedule keeps a check running on a fixed wall-clock grid of intervals, so that the time taken by each
+// run does not push back every run after it.
+type Schedule struct {
+	mu           sync.Mutex
+	interval     time.Duration
+	skipOverruns bool
+	last         time.Time // the most recent scheduled run
+	next         time.Time // the next scheduled run that has not started yet
+	stats        Stats
+}
+
+// NewSchedule creates a Schedule for a check that ran for the first time at start.  When skipOverruns is
+// true, scheduled runs that pass while a run is still in progress are skipped.  Otherwise, a single late
+// run is started as soon as the run in progress finishes.
+func NewSchedule(start time.Time, interval time.Duration, skipOverruns bool) *Schedule {
+	return &Schedule{
+		interval:     interval,
+		skipOverruns: skipOverruns,
+		last:         start,
+		next:         start.Add(interval),
+		stats:        Stats{Interval: interval},
+	}
+}
+
+// Next returns the time of the next scheduled run as of now.  The returned time is in the past when a
+// late run should start immediately.
+func (s *Schedule) Next(now time.Time) time.Time {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.next.Before(now) || s.interval <= 0 {
+		return s.next
+	}
+
+	// the previous run overran one or more scheduled runs
+	missed := int(now.Sub(s.next) / s.interval)
+	if s.skipOverruns {
+		missed++
+	}
+	s.next = s.next.Add(time.Duration(missed) * s.interval)
+	s.stats.SkippedRuns += missed
+	return s.next
+}
+
+// Started records that the run scheduled at scheduled actually started at the supplied time
+func (s *Schedule) Started(scheduled time.Time, at time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.stats.Drift = at.Sub(scheduled)
+	s.last = scheduled
+	s.next = scheduled.Add(s.interval)
+}
+
+// SetInterval changes the interval between runs.  The next run is rescheduled one new interval after the
+// most recent scheduled run.
+func (s *Schedule) SetInterval(interval time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if interval == s.interval {
+		return
+	}
+	s.interval = interval
+	s.stats.Interval = interval
+	s.next = s.last.Add(interval)
+}
+
+// Skip gives up the next scheduled run as of now
+func (s *Schedule) Skip(now time.Time) {
+	next := s.Next(now)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.last = next
+	s.next = next.Add(s.interval)
+	s.stats.SkippedRuns++
+}
+
+// Stats returns the current scheduling statistics
+func (s *Schedule) Stats() Stats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.stats
+}
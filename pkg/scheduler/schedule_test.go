@@ -0,0 +1,106 @@
+package scheduler
+
+import (
+	"testing"
+	"time"
+)
+
+var scheduleStart = time.Date(2020, 4, 1, 12, 0, 0, 0, time.UTC)
+
+// TestScheduleNoDrift validates that run durations do not push back the following runs
+func TestScheduleNoDrift(t *testing.T) {
+	s := NewSchedule(scheduleStart, time.Minute, false)
+
+	// a run that takes 20 seconds does not move the next run
+	next := s.Next(scheduleStart.Add(time.Second * 20))
+	if !next.Equal(scheduleStart.Add(time.Minute)) {
+		t.Fatal("Expected next run at one minute but got", next)
+	}
+
+	// the run starts a little late, which is recorded as drift but does not move the grid
+	s.Started(next, next.Add(time.Millisecond*50))
+	if s.Stats().Drift != time.Millisecond*50 {
+		t.Fatal("Expected drift to be recorded but got", s.Stats().Drift)
+	}
+	next = s.Next(scheduleStart.Add(time.Minute + time.Second*30))
+	if !next.Equal(scheduleStart.Add(time.Minute * 2)) {
+		t.Fatal("Expected next run at two minutes but got", next)
+	}
+
+	// asking again without starting a run, such as after a triggered run, keeps the same slot
+	if !s.Next(scheduleStart.Add(time.Minute + time.Second*40)).Equal(next) {
+		t.Fatal("Expected the scheduled run to be kept")
+	}
+}
+
+// TestScheduleOverrunLateRun validates that an overrun results in one late run when overruns are not skipped
+func TestScheduleOverrunLateRun(t *testing.T) {
+	s := NewSchedule(scheduleStart, time.Minute, false)
+
+	// the first run takes two and a half minutes, overrunning the runs at one and two minutes
+	now := scheduleStart.Add(time.Minute*2 + time.Second*30)
+	next := s.Next(now)
+	if !next.Equal(scheduleStart.Add(time.Minute * 2)) {
+		t.Fatal("Expected a late run for the two minute slot but got", next)
+	}
+	if s.Stats().SkippedRuns != 1 {
+		t.Fatal("Expected one skipped run but got", s.Stats().SkippedRuns)
+	}
+	s.Started(next, now)
+	if s.Stats().Drift != time.Second*30 {
+		t.Fatal("Expected drift of 30 seconds but got", s.Stats().Drift)
+	}
+
+	// the schedule is back on its grid afterwards
+	next = s.Next(now.Add(time.Second))
+	if !next.Equal(scheduleStart.Add(time.Minute * 3)) {
+		t.Fatal("Expected next run at three minutes but got", next)
+	}
+}
+
+// TestScheduleOverrunSkip validates that overrun runs are skipped when configured
+func TestScheduleOverrunSkip(t *testing.T) {
+	s := NewSchedule(scheduleStart, time.Minute, true)
+
+	next := s.Next(scheduleStart.Add(time.Minute*2 + time.Second*30))
+	if !next.Equal(scheduleStart.Add(time.Minute * 3)) {
+		t.Fatal("Expected next run at three minutes but got", next)
+	}
+	if s.Stats().SkippedRuns != 2 {
+		t.Fatal("Expected two skipped runs but got", s.Stats().SkippedRuns)
+	}
+}
+
+// TestScheduleSkip validates that a skipped run moves the schedule on by one interval
+func TestScheduleSkip(t *testing.T) {
+	s := NewSchedule(scheduleStart, time.Minute, false)
+	s.Skip(scheduleStart.Add(time.Second * 10))
+	next := s.Next(scheduleStart.Add(time.Second * 10))
+	if !next.Equal(scheduleStart.Add(time.Minute * 2)) {
+		t.Fatal("Expected next run at two minutes but got", next)
+	}
+	if s.Stats().SkippedRuns != 1 {
+		t.Fatal("Expected one skipped run but got", s.Stats().SkippedRuns)
+	}
+}
+
+// TestScheduleSetInterval validates that changing the interval reschedules from the most recent run
+func TestScheduleSetInterval(t *testing.T) {
+	s := NewSchedule(scheduleStart, time.Minute, false)
+	s.SetInterval(time.Minute * 4)
+	next := s.Next(scheduleStart.Add(time.Second * 10))
+	if !next.Equal(scheduleStart.Add(time.Minute * 4)) {
+		t.Fatal("Expected next run at four minutes but got", next)
+	}
+	if s.Stats().Interval != time.Minute*4 {
+		t.Fatal("Expected the interval to be reported but got", s.Stats().Interval)
+	}
+
+	// returning to the normal interval after the backed off run resumes the normal cadence
+	s.Started(next, next)
+	s.SetInterval(time.Minute)
+	next = s.Next(scheduleStart.Add(time.Minute*4 + time.Second*10))
+	if !next.Equal(scheduleStart.Add(time.Minute * 5)) {
+		t.Fatal("Expected next run at five minutes but got", next)
+	}
+}
@@ -0,0 +1,38 @@
+// Package scheduler calculates when checks run so that checks created together do not all run at once.
+package scheduler
+
+import (
+	"hash/fnv"
+	"math/rand"
+	"time"
+)
+
+// SpreadOffset returns a deterministic offset within the interval for the supplied check key.  The same
+// key always gets the same offset, so a check keeps its place in the interval window across restarts
+// and master changes.
+func SpreadOffset(key string, interval time.Duration) time.Duration {
+	if interval <= 0 {
+		return 0
+	}
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(key))
+	return time.Duration(h.Sum64() % uint64(interval))
+}
+
+// Jitter returns a random duration between zero and max
+func Jitter(max time.Duration) time.Duration {
+	if max <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int63n(int64(max)))
+}
+
+// StartDelay returns how long a check should wait before its first run.  When spread is true, the check
+// is offset deterministically within its interval.  Up to jitter of random delay is added on top.
+func StartDelay(key string, interval time.Duration, spread bool, jitter time.Duration) time.Duration {
+	var delay time.Duration
+	if spread {
+		delay = SpreadOffset(key, interval)
+	}
+	return delay + Jitter(jitter)
+}
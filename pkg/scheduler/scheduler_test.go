@@ -0,0 +1,51 @@
+package scheduler
+
+import (
+	"testing"
+	"time"
+)
+
+// TestSpreadOffset validates that offsets are deterministic, within the interval, and differ between checks
+func TestSpreadOffset(t *testing.T) {
+	interval := time.Minute * 10
+
+	a := SpreadOffset("kuberhealthy/deployment", interval)
+	if a != SpreadOffset("kuberhealthy/deployment", interval) {
+		t.Fatal("Expected the same check to always get the same offset")
+	}
+	if a < 0 || a >= interval {
+		t.Fatal("Offset", a, "is outside of the interval", interval)
+	}
+
+	// offsets of a group of checks should not all land in the same place
+	seen := make(map[time.Duration]bool)
+	for _, key := range []string{"kuberhealthy/daemonset", "kuberhealthy/dns-status-internal", "kuberhealthy/pod-restarts", "kuberhealthy/pod-status"} {
+		seen[SpreadOffset(key, interval)] = true
+	}
+	if len(seen) < 2 {
+		t.Fatal("Expected checks to be spread across the interval")
+	}
+
+	if SpreadOffset("kuberhealthy/deployment", 0) != 0 {
+		t.Fatal("Expected no offset for a zero interval")
+	}
+}
+
+// TestStartDelay validates that the start delay is made of the spread offset and jitter
+func TestStartDelay(t *testing.T) {
+	interval := time.Minute
+	key := "kuberhealthy/deployment"
+
+	if StartDelay(key, interval, false, 0) != 0 {
+		t.Fatal("Expected no delay without spread or jitter")
+	}
+	if StartDelay(key, interval, true, 0) != SpreadOffset(key, interval) {
+		t.Fatal("Expected spread delay to equal the spread offset")
+	}
+	for i := 0; i < 100; i++ {
+		d := StartDelay(key, interval, false, time.Second)
+		if d < 0 || d >= time.Second {
+			t.Fatal("Jitter", d, "is outside of the allowed range")
+		}
+	}
+}
@@ -0,0 +1,94 @@
+// Copyright 2018 Comcast Cable Communications Management, LLC
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//     http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package sharding splits keys, such as checks, across a set of members, such as Kuberhealthy pods, with
+// consistent hashing.  When a member joins or leaves, only the keys it gains or loses move, so the rest of
+// the members keep running what they were already running.
+package sharding
+
+import (
+	"hash/crc32"
+	"sort"
+	"strconv"
+)
+
+// DefaultReplicas is how many points each member has on the ring.  More points spread keys more evenly.
+const DefaultReplicas = 128
+
+// Ring assigns keys to members with consistent hashing.  Each member is hashed onto the ring many times and
+// a key belongs to the member at the first point at or after the key's hash.
+type Ring struct {
+	members []string
+	points  []uint32          // the sorted points on the ring
+	owners  map[uint32]string // the member at each point
+}
+
+// NewRing creates a ring of the supplied members, each with the supplied number of points.  Every instance
+// that creates a ring of the same members assigns keys the same way.
+func NewRing(members []string, replicas int) *Ring {
+	r := &Ring{
+		owners: make(map[uint32]string, len(members)*replicas),
+	}
+	r.members = append(r.members, members...)
+	sort.Strings(r.members)
+	for _, member := range r.members {
+		for i := 0; i < replicas; i++ {
+			point := hash(member + "#" + strconv.Itoa(i))
+			// on a collision the member that sorts first keeps the point so that every ring agrees
+			if _, taken := r.owners[point]; taken {
+				continue
+			}
+			r.owners[point] = member
+			r.points = append(r.points, point)
+		}
+	}
+	sort.Slice(r.points, func(i, j int) bool { return r.points[i] < r.points[j] })
+	return r
+}
+
+// Owner returns the member that a key belongs to, or a blank string when the ring has no members
+func (r *Ring) Owner(key string) string {
+	if len(r.points) == 0 {
+		return ""
+	}
+	h := hash(key)
+	i := sort.Search(len(r.points), func(i int) bool { return r.points[i] >= h })
+	if i == len(r.points) {
+		i = 0
+	}
+	return r.owners[r.points[i]]
+}
+
+// Members returns the sorted members of the ring
+func (r *Ring) Members() []string {
+	return append([]string{}, r.members...)
+}
+
+// Equal returns true if two rings have the same members
+func (r *Ring) Equal(other *Ring) bool {
+	if r == nil || other == nil {
+		return r == other
+	}
+	if len(r.members) != len(other.members) {
+		return false
+	}
+	for i := range r.members {
+		if r.members[i] != other.members[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// hash returns the position of a string on the ring
+func hash(s string) uint32 {
+	return crc32.ChecksumIEEE([]byte(s))
+}
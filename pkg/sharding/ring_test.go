@@ -0,0 +1,51 @@
+package sharding
+
+import (
+	"strconv"
+	"testing"
+)
+
+// TestOwner validates that keys are spread across members and assigned the same way by every ring
+func TestOwner(t *testing.T) {
+	r := NewRing([]string{"kuberhealthy-c", "kuberhealthy-a", "kuberhealthy-b"}, DefaultReplicas)
+	same := NewRing([]string{"kuberhealthy-a", "kuberhealthy-b", "kuberhealthy-c"}, DefaultReplicas)
+
+	counts := make(map[string]int)
+	for i := 0; i < 3000; i++ {
+		key := "namespace/check-" + strconv.Itoa(i)
+		owner := r.Owner(key)
+		if owner != same.Owner(key) {
+			t.Fatal("Expected rings of the same members to agree on the owner of", key)
+		}
+		counts[owner]++
+	}
+	for member, count := range counts {
+		if count < 600 || count > 1400 {
+			t.Fatal("Expected keys to be spread evenly but", member, "owns", count, "of 3000:", counts)
+		}
+	}
+	if len(counts) != 3 {
+		t.Fatal("Expected every member to own keys but got", counts)
+	}
+
+	if NewRing(nil, DefaultReplicas).Owner("namespace/check") != "" {
+		t.Fatal("Expected an empty ring to have no owners")
+	}
+}
+
+// TestRebalance validates that only the keys of a member that leaves move to other members
+func TestRebalance(t *testing.T) {
+	before := NewRing([]string{"kuberhealthy-a", "kuberhealthy-b", "kuberhealthy-c"}, DefaultReplicas)
+	after := NewRing([]string{"kuberhealthy-a", "kuberhealthy-b"}, DefaultReplicas)
+	if before.Equal(after) || !before.Equal(NewRing(before.Members(), DefaultReplicas)) {
+		t.Fatal("Expected rings to be equal only when they have the same members")
+	}
+
+	for i := 0; i < 1000; i++ {
+		key := "namespace/check-" + strconv.Itoa(i)
+		owner := before.Owner(key)
+		if owner != "kuberhealthy-c" && after.Owner(key) != owner {
+			t.Fatal("Expected", key, "to stay with", owner, "but it moved to", after.Owner(key))
+		}
+	}
+}
@@ -0,0 +1,281 @@
+package statestore
+
+import (
+	"context"
+	"reflect"
+	"sort"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/Comcast/kuberhealthy/v2/pkg/health"
+	"github.com/Comcast/kuberhealthy/v2/pkg/ratelimit"
+)
+
+// Coalescer is a StateStore that batches writes to another store.  Updates are held in memory and flushed on an
+// interval, so that a check written several times between flushes is only written once, and flushes are rate
+// limited so that many checks finishing together do not flood the API server.  Reads see pending writes right
+// away, but only in this Kuberhealthy pod.  Updates that change which runs are authorized to report are never
+// held, since other Kuberhealthy pods check reports against them, and neither are writes that replace a state.
+type Coalescer struct {
+	Store    StateStore         // the store that writes are flushed to
+	Interval time.Duration      // how often pending writes are flushed
+	Limiter  *ratelimit.Limiter // limits how fast pending writes are flushed.  Nil flushes as fast as possible.
+
+	mu      sync.Mutex
+	pending map[string]pendingState
+	version uint64
+	flushMu sync.Mutex // keeps deletes from racing with a flush that would recreate the deleted state
+}
+
+// pendingState is an update that has not been flushed yet.  The details of the check state are the state as
+// it is seen in this pod, and base is the state the first of the pending changes was made to, so that only the
+// changed fields are written when it is flushed.  The version tells a flush whether the state was written again
+// while it was being flushed.
+type pendingState struct {
+	CheckState
+	base    health.CheckDetails
+	version uint64
+}
+
+// NewCoalescer creates a Coalescer that flushes writes to store every interval through limiter
+func NewCoalescer(store StateStore, interval time.Duration, limiter *ratelimit.Limiter) *Coalescer {
+	return &Coalescer{
+		Store:    store,
+		Interval: interval,
+		Limiter:  limiter,
+		pending:  make(map[string]pendingState),
+	}
+}
+
+// Get returns the pending state of a check, or the state in the store if there is no pending write
+func (c *Coalescer) Get(checkName string, namespace string) (health.CheckDetails, error) {
+	c.mu.Lock()
+	p, ok := c.pending[namespace+"/"+checkName]
+	c.mu.Unlock()
+	if ok {
+		return p.Details, nil
+	}
+	return c.Store.Get(checkName, namespace)
+}
+
+// Set replaces the state of a check in the store right away and drops any update of the check that is still
+// pending
+func (c *Coalescer) Set(checkName string, namespace string, details health.CheckDetails) error {
+	c.flushMu.Lock()
+	defer c.flushMu.Unlock()
+	err := c.Store.Set(checkName, namespace, details)
+	if err != nil {
+		return err
+	}
+	c.mu.Lock()
+	delete(c.pending, namespace+"/"+checkName)
+	c.mu.Unlock()
+	return nil
+}
+
+// Update holds the changes made to the state of a check until the next flush, along with any changes that are
+// still pending.  Changes to the runs that are authorized to report are written to the store right away.
+func (c *Coalescer) Update(checkName string, namespace string, base health.CheckDetails, details health.CheckDetails) error {
+	key := namespace + "/" + checkName
+	if changesRunAuthorization(base, details) {
+		return c.writeThrough(checkName, namespace, base, details)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.version++
+	p, ok := c.pending[key]
+	if !ok {
+		p = pendingState{
+			CheckState: CheckState{Name: checkName, Namespace: namespace, Details: details},
+			base:       base,
+		}
+	} else {
+		p.Details = Merge(base, details, p.Details)
+	}
+	p.version = c.version
+	c.pending[key] = p
+	return nil
+}
+
+// changesRunAuthorization returns true if the changes made to a state change the runs that are authorized to
+// report.  Reports can reach any Kuberhealthy pod, which only sees the runs that have been written to the store.
+func changesRunAuthorization(base health.CheckDetails, details health.CheckDetails) bool {
+	if base.CurrentUUID != details.CurrentUUID {
+		return true
+	}
+	if len(base.RunPods) > 0 || len(details.RunPods) > 0 {
+		if !reflect.DeepEqual(base.RunPods, details.RunPods) {
+			return true
+		}
+	}
+	if len(base.RunningUUIDs) == 0 && len(details.RunningUUIDs) == 0 {
+		return false
+	}
+	return !reflect.DeepEqual(base.RunningUUIDs, details.RunningUUIDs)
+}
+
+// writeThrough writes changes to the state of a check to the store right away, along with any changes to the
+// check that are still pending
+func (c *Coalescer) writeThrough(checkName string, namespace string, base health.CheckDetails, details health.CheckDetails) error {
+	c.flushMu.Lock()
+	defer c.flushMu.Unlock()
+
+	key := namespace + "/" + checkName
+	c.mu.Lock()
+	p, pending := c.pending[key]
+	c.mu.Unlock()
+	if pending {
+		details = Merge(base, details, p.Details)
+		base = p.base
+	}
+
+	err := c.Store.Update(checkName, namespace, base, details)
+	if err != nil {
+		return err
+	}
+
+	// changes made while the state was written stay pending for the next flush
+	if pending {
+		c.mu.Lock()
+		c.written(key, p.version, details)
+		c.mu.Unlock()
+	}
+	return nil
+}
+
+// List returns the state of every check in a namespace, or every namespace if blank, with pending writes
+// applied over the states in the store
+func (c *Coalescer) List(namespace string) ([]CheckState, error) {
+	states, err := c.Store.List(namespace)
+	if err != nil {
+		return nil, err
+	}
+	var filtered []CheckState
+	for _, s := range c.Overlay(states) {
+		if len(namespace) > 0 && s.Namespace != namespace {
+			continue
+		}
+		filtered = append(filtered, s)
+	}
+	return filtered, nil
+}
+
+// Overlay applies the pending writes over states read from the store, such as from a cache of the store,
+// so that they show the latest state of every check
+func (c *Coalescer) Overlay(states []CheckState) []CheckState {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.pending) == 0 {
+		return states
+	}
+	seen := make(map[string]bool, len(states))
+	overlaid := make([]CheckState, 0, len(states)+len(c.pending))
+	for _, s := range states {
+		key := s.Namespace + "/" + s.Name
+		seen[key] = true
+		if p, ok := c.pending[key]; ok {
+			s = p.CheckState
+		}
+		overlaid = append(overlaid, s)
+	}
+	for key, p := range c.pending {
+		if !seen[key] {
+			overlaid = append(overlaid, p.CheckState)
+		}
+	}
+	return overlaid
+}
+
+// Delete drops any pending write of a check and removes its state from the store
+func (c *Coalescer) Delete(checkName string, namespace string) error {
+	c.flushMu.Lock()
+	defer c.flushMu.Unlock()
+	c.mu.Lock()
+	delete(c.pending, namespace+"/"+checkName)
+	c.mu.Unlock()
+	return c.Store.Delete(checkName, namespace)
+}
+
+// Pending returns the number of writes that have not been flushed yet
+func (c *Coalescer) Pending() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.pending)
+}
+
+// Run flushes pending writes every interval until the context is canceled.  Writes still pending when the
+// context is canceled are left for a final Flush.
+func (c *Coalescer) Run(ctx context.Context) {
+	ticker := time.NewTicker(c.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			err := c.Flush(ctx)
+			if err != nil && ctx.Err() == nil {
+				log.Errorln("statestore: failed to flush check state:", err)
+			}
+		}
+	}
+}
+
+// Flush writes every pending state to the store, in order of namespace and name.  States that fail to be
+// written stay pending and are retried on the next flush.  Returns the last error encountered.
+func (c *Coalescer) Flush(ctx context.Context) error {
+	c.flushMu.Lock()
+	defer c.flushMu.Unlock()
+
+	c.mu.Lock()
+	batch := make([]pendingState, 0, len(c.pending))
+	for _, p := range c.pending {
+		batch = append(batch, p)
+	}
+	c.mu.Unlock()
+	sort.Slice(batch, func(i, j int) bool {
+		if batch[i].Namespace != batch[j].Namespace {
+			return batch[i].Namespace < batch[j].Namespace
+		}
+		return batch[i].Name < batch[j].Name
+	})
+
+	var lastErr error
+	for _, p := range batch {
+		err := c.Limiter.Wait(ctx, "update")
+		if err != nil {
+			return err
+		}
+		err = c.Store.Update(p.Name, p.Namespace, p.base, p.Details)
+		if err != nil {
+			log.Errorln("statestore: failed to write state of check", p.Name, "in namespace", p.Namespace+":", err)
+			lastErr = err
+			continue
+		}
+
+		// states written again during the flush stay pending for the next one
+		c.mu.Lock()
+		c.written(p.Namespace+"/"+p.Name, p.version, p.Details)
+		c.mu.Unlock()
+	}
+	return lastErr
+}
+
+// written drops the pending update of a check once the version of it was written to the store as details.  An
+// update that was changed again in the meantime stays pending, with only the changes made since the write left
+// to flush.  Must be called with the lock held.
+func (c *Coalescer) written(key string, version uint64, details health.CheckDetails) {
+	current, ok := c.pending[key]
+	if !ok {
+		return
+	}
+	if current.version == version {
+		delete(c.pending, key)
+		return
+	}
+	current.base = details
+	c.pending[key] = current
+}
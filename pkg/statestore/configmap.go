@@ -0,0 +1,148 @@
+package statestore
+
+import (
+	"encoding/json"
+	"fmt"
+
+	apiv1 "k8s.io/api/core/v1"
+	k8sErrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/util/retry"
+
+	"github.com/Comcast/kuberhealthy/v2/pkg/health"
+)
+
+// CheckStateLabel is applied to every ConfigMap holding check state.  Its value is the name of the check.
+const CheckStateLabel = "kuberhealthy-check-state"
+
+// configMapDataKey is the ConfigMap data key the JSON encoded check state is stored under
+const configMapDataKey = "state"
+
+// ConfigMapStore keeps check state in ConfigMaps in each check's namespace.  This allows Kuberhealthy
+// to run where custom resources can not be installed.
+type ConfigMapStore struct {
+	Client kubernetes.Interface
+}
+
+// NewConfigMapStore creates a ConfigMapStore that uses the supplied kubernetes client
+func NewConfigMapStore(client kubernetes.Interface) *ConfigMapStore {
+	return &ConfigMapStore{Client: client}
+}
+
+// configMapName returns the name of the ConfigMap that holds the state for a check
+func configMapName(checkName string) string {
+	return "khstate-" + checkName
+}
+
+// Get returns the state of a check
+func (c *ConfigMapStore) Get(checkName string, namespace string) (health.CheckDetails, error) {
+	cm, err := c.Client.CoreV1().ConfigMaps(namespace).Get(configMapName(checkName), metav1.GetOptions{})
+	if k8sErrors.IsNotFound(err) {
+		return health.CheckDetails{}, ErrNotFound
+	}
+	if err != nil {
+		return health.CheckDetails{}, err
+	}
+	return decodeConfigMap(cm)
+}
+
+// Set creates or replaces the state of a check
+func (c *ConfigMapStore) Set(checkName string, namespace string, details health.CheckDetails) error {
+	return c.write(checkName, namespace, details, func(health.CheckDetails) health.CheckDetails {
+		return details
+	})
+}
+
+// Update writes the fields of details that differ from base over the latest state of a check.  Updates that
+// conflict with another writer apply the changed fields again to the state that writer stored.
+func (c *ConfigMapStore) Update(checkName string, namespace string, base health.CheckDetails, details health.CheckDetails) error {
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		return c.write(checkName, namespace, details, func(latest health.CheckDetails) health.CheckDetails {
+			return Merge(base, details, latest)
+		})
+	})
+}
+
+// write creates the ConfigMap of a check with details if it does not exist, or replaces the state it holds with
+// the state that change returns for it.  Another writer creating the ConfigMap first is returned as a conflict.
+func (c *ConfigMapStore) write(checkName string, namespace string, details health.CheckDetails, change func(latest health.CheckDetails) health.CheckDetails) error {
+	cmClient := c.Client.CoreV1().ConfigMaps(namespace)
+	cm, err := cmClient.Get(configMapName(checkName), metav1.GetOptions{})
+	if k8sErrors.IsNotFound(err) {
+		b, err := json.Marshal(details)
+		if err != nil {
+			return fmt.Errorf("error marshaling check state for %s: %w", checkName, err)
+		}
+		_, err = cmClient.Create(&apiv1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      configMapName(checkName),
+				Namespace: namespace,
+				Labels: map[string]string{
+					CheckStateLabel: checkName,
+				},
+			},
+			Data: map[string]string{
+				configMapDataKey: string(b),
+			},
+		})
+		if k8sErrors.IsAlreadyExists(err) {
+			return k8sErrors.NewConflict(apiv1.Resource("configmaps"), configMapName(checkName), err)
+		}
+		return err
+	}
+	if err != nil {
+		return err
+	}
+
+	latest, err := decodeConfigMap(cm)
+	if err != nil {
+		return err
+	}
+	b, err := json.Marshal(change(latest))
+	if err != nil {
+		return fmt.Errorf("error marshaling check state for %s: %w", checkName, err)
+	}
+	if cm.Data == nil {
+		cm.Data = make(map[string]string)
+	}
+	cm.Data[configMapDataKey] = string(b)
+	_, err = cmClient.Update(cm)
+	return err
+}
+
+// List returns the state of every check in a namespace, or every namespace if blank
+func (c *ConfigMapStore) List(namespace string) ([]CheckState, error) {
+	cms, err := c.Client.CoreV1().ConfigMaps(namespace).List(metav1.ListOptions{LabelSelector: CheckStateLabel})
+	if err != nil {
+		return nil, err
+	}
+	var states []CheckState
+	for i := range cms.Items {
+		details, err := decodeConfigMap(&cms.Items[i])
+		if err != nil {
+			return nil, err
+		}
+		states = append(states, CheckState{
+			Name:      cms.Items[i].Labels[CheckStateLabel],
+			Namespace: cms.Items[i].Namespace,
+			Details:   details,
+		})
+	}
+	return states, nil
+}
+
+// Delete removes the state of a check
+func (c *ConfigMapStore) Delete(checkName string, namespace string) error {
+	return c.Client.CoreV1().ConfigMaps(namespace).Delete(configMapName(checkName), &metav1.DeleteOptions{})
+}
+
+// decodeConfigMap unmarshals the check state held in a ConfigMap
+func decodeConfigMap(cm *apiv1.ConfigMap) (health.CheckDetails, error) {
+	details := health.CheckDetails{}
+	err := json.Unmarshal([]byte(cm.Data[configMapDataKey]), &details)
+	if err != nil {
+		return details, fmt.Errorf("error unmarshaling check state from configmap %s in %s: %w", cm.Name, cm.Namespace, err)
+	}
+	return details, nil
+}
@@ -0,0 +1,108 @@
+package statestore
+
+import (
+	"fmt"
+
+	k8sErrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/util/retry"
+
+	"github.com/Comcast/kuberhealthy/v2/pkg/health"
+	"github.com/Comcast/kuberhealthy/v2/pkg/khstatecrd"
+)
+
+// stateCRDResource is the resource name of the khstate custom resource
+const stateCRDResource = "khstates"
+
+// CRDStore keeps check state in khstate custom resources
+type CRDStore struct {
+	Client *khstatecrd.KuberhealthyStateClient
+}
+
+// NewCRDStore creates a CRDStore that uses the supplied khstate client
+func NewCRDStore(client *khstatecrd.KuberhealthyStateClient) *CRDStore {
+	return &CRDStore{Client: client}
+}
+
+// Get returns the state of a check
+func (c *CRDStore) Get(checkName string, namespace string) (health.CheckDetails, error) {
+	khState, err := c.Client.Get(metav1.GetOptions{}, stateCRDResource, checkName, namespace)
+	if k8sErrors.IsNotFound(err) {
+		return health.CheckDetails{}, ErrNotFound
+	}
+	if err != nil {
+		return health.CheckDetails{}, err
+	}
+	return khState.Spec, nil
+}
+
+// Set creates or replaces the state of a check
+func (c *CRDStore) Set(checkName string, namespace string, details health.CheckDetails) error {
+	khState := khstatecrd.NewKuberhealthyState(checkName, details)
+	khState.SetNamespace(namespace)
+
+	// we must fetch the existing state to use the current resource version found within
+	existingState, err := c.Client.Get(metav1.GetOptions{}, stateCRDResource, checkName, namespace)
+	if k8sErrors.IsNotFound(err) {
+		_, err = c.Client.Create(&khState, stateCRDResource, namespace)
+		return err
+	}
+	if err != nil {
+		return fmt.Errorf("error retrieving khstate %s in %s: %w", checkName, namespace, err)
+	}
+
+	khState.SetResourceVersion(existingState.GetResourceVersion())
+	_, err = c.Client.Update(&khState, stateCRDResource, checkName, namespace)
+	return err
+}
+
+// Update writes the fields of details that differ from base over the latest khstate of a check.  Updates that
+// conflict with another writer apply the changed fields again to the khstate that writer stored.
+func (c *CRDStore) Update(checkName string, namespace string, base health.CheckDetails, details health.CheckDetails) error {
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		existingState, err := c.Client.Get(metav1.GetOptions{}, stateCRDResource, checkName, namespace)
+		if k8sErrors.IsNotFound(err) {
+			khState := khstatecrd.NewKuberhealthyState(checkName, details)
+			khState.SetNamespace(namespace)
+			_, err = c.Client.Create(&khState, stateCRDResource, namespace)
+			if k8sErrors.IsAlreadyExists(err) {
+				// another writer created the khstate first, so the changes are applied over theirs
+				return k8sErrors.NewConflict(schema.GroupResource{Resource: stateCRDResource}, checkName, err)
+			}
+			return err
+		}
+		if err != nil {
+			return fmt.Errorf("error retrieving khstate %s in %s: %w", checkName, namespace, err)
+		}
+
+		khState := khstatecrd.NewKuberhealthyState(checkName, Merge(base, details, existingState.Spec))
+		khState.SetNamespace(namespace)
+		khState.SetResourceVersion(existingState.GetResourceVersion())
+		_, err = c.Client.Update(&khState, stateCRDResource, checkName, namespace)
+		return err
+	})
+}
+
+// List returns the state of every check in a namespace, or every namespace if blank
+func (c *CRDStore) List(namespace string) ([]CheckState, error) {
+	khStates, err := c.Client.List(metav1.ListOptions{}, stateCRDResource, namespace)
+	if err != nil {
+		return nil, err
+	}
+	var states []CheckState
+	for _, khState := range khStates.Items {
+		states = append(states, CheckState{
+			Name:      khState.GetName(),
+			Namespace: khState.GetNamespace(),
+			Details:   khState.Spec,
+		})
+	}
+	return states, nil
+}
+
+// Delete removes the state of a check
+func (c *CRDStore) Delete(checkName string, namespace string) error {
+	_, err := c.Client.Delete(nil, stateCRDResource, checkName, namespace)
+	return err
+}
@@ -0,0 +1,82 @@
+package statestore
+
+import (
+	"sync"
+
+	"github.com/Comcast/kuberhealthy/v2/pkg/health"
+)
+
+// MemoryStore keeps check state in memory.  State is lost when Kuberhealthy restarts and is not
+// shared between Kuberhealthy instances.
+type MemoryStore struct {
+	mu     sync.RWMutex
+	states map[string]CheckState
+}
+
+// NewMemoryStore creates an empty MemoryStore
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		states: make(map[string]CheckState),
+	}
+}
+
+// Get returns the state of a check
+func (m *MemoryStore) Get(checkName string, namespace string) (health.CheckDetails, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	s, ok := m.states[namespace+"/"+checkName]
+	if !ok {
+		return health.CheckDetails{}, ErrNotFound
+	}
+	return s.Details, nil
+}
+
+// Set creates or replaces the state of a check
+func (m *MemoryStore) Set(checkName string, namespace string, details health.CheckDetails) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.states[namespace+"/"+checkName] = CheckState{
+		Name:      checkName,
+		Namespace: namespace,
+		Details:   details,
+	}
+	return nil
+}
+
+// Update writes the fields of details that differ from base over the latest state of a check
+func (m *MemoryStore) Update(checkName string, namespace string, base health.CheckDetails, details health.CheckDetails) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	key := namespace + "/" + checkName
+	if s, ok := m.states[key]; ok {
+		details = Merge(base, details, s.Details)
+	}
+	m.states[key] = CheckState{
+		Name:      checkName,
+		Namespace: namespace,
+		Details:   details,
+	}
+	return nil
+}
+
+// List returns the state of every check in a namespace, or every namespace if blank
+func (m *MemoryStore) List(namespace string) ([]CheckState, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	var states []CheckState
+	for _, s := range m.states {
+		if len(namespace) > 0 && s.Namespace != namespace {
+			continue
+		}
+		states = append(states, s)
+	}
+	return states, nil
+}
+
+// Delete removes the state of a check
+func (m *MemoryStore) Delete(checkName string, namespace string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.states, namespace+"/"+checkName)
+	return nil
+}
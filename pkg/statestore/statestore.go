@@ -0,0 +1,113 @@
+// Package statestore persists the state of Kuberhealthy checks.  The checker
+// and the web server share a StateStore so that reports written by one are
+// always seen by the other.  State can be kept in khstate custom resources,
+// in ConfigMaps, or in memory for testing and single-instance deployments.
+package statestore
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+
+	"github.com/Comcast/kuberhealthy/v2/pkg/health"
+)
+
+// ErrNotFound is returned by Get when no state has been stored for a check
+var ErrNotFound = errors.New("check state not found")
+
+// CheckState is the stored state of a single check
+type CheckState struct {
+	Name      string
+	Namespace string
+	Details   health.CheckDetails
+}
+
+// StateStore gets and sets the state of checks
+type StateStore interface {
+	// Get returns the state of a check, or ErrNotFound if none has been stored
+	Get(checkName string, namespace string) (health.CheckDetails, error)
+	// Set creates or replaces the state of a check.  Changes made by other writers are lost, so state that
+	// other writers may also change is written with Update.
+	Set(checkName string, namespace string, details health.CheckDetails) error
+	// Update writes the fields of details that differ from base, the state that was read before it was changed
+	// into details, over the latest state of the check.  Fields that another writer changed since base was read
+	// are kept.  Writes that conflict with another writer are applied again to the state that writer stored.
+	// The state is created from details if none has been stored.
+	Update(checkName string, namespace string, base health.CheckDetails, details health.CheckDetails) error
+	// List returns the state of every check in a namespace, or every namespace if blank
+	List(namespace string) ([]CheckState, error)
+	// Delete removes the state of a check
+	Delete(checkName string, namespace string) error
+}
+
+// Merge returns latest with every field of details that differs from base written over it.  Base is the state
+// details was changed from, so the fields that are equal in both were not changed by the writer of details and
+// are left as latest has them.  Maps are merged by key, so that writers changing different keys keep each other's
+// changes.
+func Merge(base health.CheckDetails, details health.CheckDetails, latest health.CheckDetails) health.CheckDetails {
+	b := reflect.ValueOf(base)
+	d := reflect.ValueOf(details)
+	l := reflect.ValueOf(&latest).Elem()
+	for i := 0; i < d.NumField(); i++ {
+		if fieldEqual(b.Field(i), d.Field(i)) {
+			continue
+		}
+		if d.Field(i).Kind() == reflect.Map {
+			l.Field(i).Set(mergeMap(b.Field(i), d.Field(i), l.Field(i)))
+			continue
+		}
+		l.Field(i).Set(d.Field(i))
+	}
+	return latest
+}
+
+// mergeMap returns a copy of the latest map with the keys that were added, changed, or removed between the base
+// and details maps changed the same way
+func mergeMap(base reflect.Value, details reflect.Value, latest reflect.Value) reflect.Value {
+	merged := reflect.MakeMap(latest.Type())
+	for _, k := range latest.MapKeys() {
+		merged.SetMapIndex(k, latest.MapIndex(k))
+	}
+	for _, k := range details.MapKeys() {
+		b := base.MapIndex(k)
+		if !b.IsValid() || !fieldEqual(b, details.MapIndex(k)) {
+			merged.SetMapIndex(k, details.MapIndex(k))
+		}
+	}
+	for _, k := range base.MapKeys() {
+		if !details.MapIndex(k).IsValid() {
+			merged.SetMapIndex(k, reflect.Value{})
+		}
+	}
+	if merged.Len() == 0 && latest.IsNil() {
+		return latest
+	}
+	return merged
+}
+
+// fieldEqual returns true if two values of a field are the same once they are stored.  Empty values are all
+// the same, and other values are compared in the JSON form they are stored in, so that details copied from a
+// state that was read are not mistaken for changes.
+func fieldEqual(a reflect.Value, b reflect.Value) bool {
+	if isEmpty(a) && isEmpty(b) {
+		return true
+	}
+	aJSON, err := json.Marshal(a.Interface())
+	if err != nil {
+		return reflect.DeepEqual(a.Interface(), b.Interface())
+	}
+	bJSON, err := json.Marshal(b.Interface())
+	if err != nil {
+		return reflect.DeepEqual(a.Interface(), b.Interface())
+	}
+	return string(aJSON) == string(bJSON)
+}
+
+// isEmpty returns true for the zero value of a field and for empty slices and maps
+func isEmpty(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Slice, reflect.Map:
+		return v.Len() == 0
+	}
+	return v.IsZero()
+}
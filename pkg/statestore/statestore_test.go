@@ -0,0 +1,301 @@
+package statestore
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sort"
+	"strconv"
+	"testing"
+	"time"
+
+	apiv1 "k8s.io/api/core/v1"
+	k8sErrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+
+	"github.com/Comcast/kuberhealthy/v2/pkg/health"
+)
+
+// testStore runs the same set of operations against any StateStore implementation
+func testStore(t *testing.T, store StateStore) {
+	_, err := store.Get("dns", "kuberhealthy")
+	if err != ErrNotFound {
+		t.Fatal("expected ErrNotFound for a missing check, got", err)
+	}
+
+	details := health.NewCheckDetails()
+	details.OK = false
+	details.Errors = []string{"no answer"}
+	err = store.Set("dns", "kuberhealthy", details)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// a second set should replace the first
+	details.CurrentUUID = "abc"
+	err = store.Set("dns", "kuberhealthy", details)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = store.Set("deployment", "other", health.NewCheckDetails())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := store.Get("dns", "kuberhealthy")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.OK || got.CurrentUUID != "abc" || len(got.Errors) != 1 {
+		t.Fatalf("unexpected state returned: %+v", got)
+	}
+
+	// an update only writes the fields changed from the state it was made to
+	base := got.DeepCopy()
+	got.RunDuration = "5s"
+	err = store.Update("dns", "kuberhealthy", base, got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err = store.Get("dns", "kuberhealthy")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.RunDuration != "5s" || got.CurrentUUID != "abc" || len(got.Errors) != 1 {
+		t.Fatalf("unexpected state returned after update: %+v", got)
+	}
+
+	states, err := store.List("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Slice(states, func(i, j int) bool { return states[i].Name < states[j].Name })
+	if len(states) != 2 || states[0].Name != "deployment" || states[0].Namespace != "other" {
+		t.Fatalf("unexpected states listed: %+v", states)
+	}
+	states, err = store.List("kuberhealthy")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(states) != 1 || states[0].Name != "dns" {
+		t.Fatalf("unexpected states listed for namespace: %+v", states)
+	}
+
+	err = store.Delete("dns", "kuberhealthy")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = store.Get("dns", "kuberhealthy")
+	if err != ErrNotFound {
+		t.Fatal("expected ErrNotFound after delete, got", err)
+	}
+}
+
+func TestMemoryStore(t *testing.T) {
+	testStore(t, NewMemoryStore())
+}
+
+func TestConfigMapStore(t *testing.T) {
+	testStore(t, NewConfigMapStore(fake.NewSimpleClientset()))
+}
+
+func TestCoalescer(t *testing.T) {
+	store := NewCoalescer(NewMemoryStore(), time.Second, nil)
+	testStore(t, store)
+	err := store.Flush(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	testStore(t, store)
+}
+
+// countingStore counts the writes made to a MemoryStore and fails them while failing is set
+type countingStore struct {
+	*MemoryStore
+	writes  int
+	failing bool
+}
+
+// Update counts a write and passes it to the MemoryStore unless writes are failing
+func (c *countingStore) Update(checkName string, namespace string, base health.CheckDetails, details health.CheckDetails) error {
+	c.writes++
+	if c.failing {
+		return errors.New("the API server is unavailable")
+	}
+	return c.MemoryStore.Update(checkName, namespace, base, details)
+}
+
+// TestCoalescerFlush validates that writes between flushes are coalesced into one, that pending writes are seen
+// by reads and overlays before they are flushed, and that failed writes are retried on the next flush
+func TestCoalescerFlush(t *testing.T) {
+	underlying := &countingStore{MemoryStore: NewMemoryStore()}
+	store := NewCoalescer(underlying, time.Second, nil)
+	for i := 0; i < 5; i++ {
+		base, err := store.Get("dns", "kuberhealthy")
+		if err != nil && err != ErrNotFound {
+			t.Fatal(err)
+		}
+		details := base.DeepCopy()
+		details.RunDuration = strconv.Itoa(i) + "s"
+		err = store.Update("dns", "kuberhealthy", base, details)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	got, err := store.Get("dns", "kuberhealthy")
+	if err != nil || got.RunDuration != "4s" {
+		t.Fatal("Expected the latest pending write to be read before it is flushed but got", got.RunDuration, err)
+	}
+	if states := store.Overlay(nil); len(states) != 1 || states[0].Details.RunDuration != "4s" {
+		t.Fatal("Expected the pending write to be overlaid on a cache without it but got", states)
+	}
+
+	underlying.failing = true
+	if store.Flush(context.Background()) == nil || store.Pending() != 1 {
+		t.Fatal("Expected a failed write to stay pending")
+	}
+	underlying.failing = false
+	err = store.Flush(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if underlying.writes != 2 || store.Pending() != 0 {
+		t.Fatal("Expected five writes to be flushed as one failed and one successful write but got", underlying.writes, "writes with", store.Pending(), "pending")
+	}
+	stored, err := underlying.Get("dns", "kuberhealthy")
+	if err != nil || stored.RunDuration != "4s" {
+		t.Fatal("Expected the latest write to be flushed but got", stored.RunDuration, err)
+	}
+}
+
+// TestCoalescerRunAuthorization validates that updates of the runs that are authorized to report are written to
+// the store right away, along with the changes to the check that were still pending
+func TestCoalescerRunAuthorization(t *testing.T) {
+	underlying := &countingStore{MemoryStore: NewMemoryStore()}
+	store := NewCoalescer(underlying, time.Second, nil)
+	err := store.Set("dns", "kuberhealthy", health.NewCheckDetails())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	base, _ := store.Get("dns", "kuberhealthy")
+	details := base.DeepCopy()
+	details.RunDuration = "5s"
+	err = store.Update("dns", "kuberhealthy", base, details)
+	if err != nil || store.Pending() != 1 || underlying.writes != 0 {
+		t.Fatal("Expected an update of the run duration to be held but got", store.Pending(), "pending and", underlying.writes, "writes", err)
+	}
+
+	base, _ = store.Get("dns", "kuberhealthy")
+	details = base.DeepCopy()
+	details.StartRun("run-2", 0)
+	err = store.Update("dns", "kuberhealthy", base, details)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if store.Pending() != 0 || underlying.writes != 1 {
+		t.Fatal("Expected the new run to be written right away but got", store.Pending(), "pending and", underlying.writes, "writes")
+	}
+	stored, err := underlying.Get("dns", "kuberhealthy")
+	if err != nil || stored.CurrentUUID != "run-2" || stored.RunDuration != "5s" {
+		t.Fatal("Expected the new run and the pending run duration to be stored but got", stored.CurrentUUID, stored.RunDuration, err)
+	}
+}
+
+// TestMerge validates that only the fields changed from the base state are written over the latest state
+func TestMerge(t *testing.T) {
+	base := health.NewCheckDetails()
+	base.CurrentUUID = "run-1"
+	base.RunHistory = []health.RunCounts{{Succeeded: 1}}
+
+	// another writer started a new run
+	latest := base.DeepCopy()
+	latest.CurrentUUID = "run-2"
+
+	// this writer recorded a failed run of the first
+	details := base.DeepCopy()
+	details.LastRunOK = false
+	details.LastRunErrors = []string{"timed out"}
+	details.RecordRun(time.Now(), false)
+
+	merged := Merge(base, details, latest)
+	if merged.CurrentUUID != "run-2" {
+		t.Fatal("Expected the run started by the other writer to be kept but got", merged.CurrentUUID)
+	}
+	if len(merged.LastRunErrors) != 1 || len(merged.RunHistory) != len(details.RunHistory) {
+		t.Fatal("Expected the changed fields to be written but got", merged.LastRunErrors, merged.RunHistory)
+	}
+	if len(base.RunHistory) != 1 || base.RunHistory[0].Failed != 0 {
+		t.Fatal("Expected the base state to be unchanged by changes to its copy but got", base.RunHistory)
+	}
+
+	// writers that record the checker pods of different runs keep each other's pods
+	base.RunningUUIDs = []string{"run-1", "run-2"}
+	latest = base.DeepCopy()
+	latest.RecordRunPod("run-2", "kuberhealthy/dns-2")
+	details = base.DeepCopy()
+	details.RecordRunPod("run-1", "kuberhealthy/dns-1")
+	merged = Merge(base, details, latest)
+	if merged.RunPods["run-1"] != "kuberhealthy/dns-1" || merged.RunPods["run-2"] != "kuberhealthy/dns-2" {
+		t.Fatal("Expected the checker pods of both runs to be kept but got", merged.RunPods)
+	}
+}
+
+// TestConfigMapStoreConflict validates that an update that conflicts with another writer is applied again to the
+// state the other writer stored, keeping the fields it changed
+func TestConfigMapStoreConflict(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	store := NewConfigMapStore(client)
+	err := store.Set("dns", "kuberhealthy", health.NewCheckDetails())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	base, err := store.Get("dns", "kuberhealthy")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// another writer records a run duration before the first update lands, which the next read returns
+	conflicts := 0
+	var other *apiv1.ConfigMap
+	client.PrependReactor("update", "configmaps", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		if conflicts > 0 {
+			return false, nil, nil
+		}
+		conflicts++
+		otherDetails := base.DeepCopy()
+		otherDetails.RunDuration = "5s"
+		b, err := json.Marshal(otherDetails)
+		if err != nil {
+			t.Fatal(err)
+		}
+		other = action.(k8stesting.UpdateAction).GetObject().(*apiv1.ConfigMap).DeepCopy()
+		other.Data[configMapDataKey] = string(b)
+		return true, nil, k8sErrors.NewConflict(schema.GroupResource{Resource: "configmaps"}, "dns", errors.New("the object has been modified"))
+	})
+	client.PrependReactor("get", "configmaps", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		if other == nil {
+			return false, nil, nil
+		}
+		cm := other
+		other = nil
+		return true, cm, nil
+	})
+	details := base.DeepCopy()
+	details.CurrentUUID = "abc"
+	err = store.Update("dns", "kuberhealthy", base, details)
+	if err != nil {
+		t.Fatal("Expected the conflicting update to be retried but got", err)
+	}
+	got, err := store.Get("dns", "kuberhealthy")
+	if err != nil || got.CurrentUUID != "abc" || conflicts != 1 {
+		t.Fatal("Expected the retried update to be stored after one conflict but got", got.CurrentUUID, err, conflicts)
+	}
+	if got.RunDuration != "5s" {
+		t.Fatal("Expected the run duration written by the other writer to be kept but got", got.RunDuration)
+	}
+}
@@ -0,0 +1,225 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// maxQueuedSpans is the number of finished spans held for export before new spans are dropped
+const maxQueuedSpans = 2048
+
+// Tracer starts spans and exports them to an OTLP/HTTP endpoint in batches
+type Tracer struct {
+	ServiceName string       // the service.name resource attribute of exported spans
+	Endpoint    string       // the base URL of the OTLP/HTTP receiver, such as http://otel-collector:4318
+	HTTPClient  *http.Client // the client used to export spans
+
+	mu      sync.Mutex
+	queue   []*Span
+	dropped int
+}
+
+// NewTracer creates a tracer that exports to the supplied OTLP/HTTP endpoint
+func NewTracer(serviceName string, endpoint string) *Tracer {
+	return &Tracer{
+		ServiceName: serviceName,
+		Endpoint:    strings.TrimSuffix(endpoint, "/"),
+		HTTPClient:  &http.Client{Timeout: time.Second * 10},
+	}
+}
+
+// Start begins a span.  A new trace is started when the parent is not valid.  A nil tracer starts
+// spans that are not recorded.
+func (t *Tracer) Start(name string, parent SpanContext) *Span {
+	s := &Span{
+		Name:       name,
+		Start:      time.Now(),
+		Attributes: make(map[string]string),
+		tracer:     t,
+	}
+	if parent.IsValid() {
+		s.Context.TraceID = parent.TraceID
+		s.Parent = parent.SpanID
+	} else {
+		newID(s.Context.TraceID[:])
+	}
+	newID(s.Context.SpanID[:])
+	return s
+}
+
+// enqueue holds a finished span until the next export
+func (t *Tracer) enqueue(s *Span) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if len(t.queue) >= maxQueuedSpans {
+		t.dropped++
+		return
+	}
+	t.queue = append(t.queue, s)
+}
+
+// Run exports finished spans on the supplied interval until the context is canceled.  Remaining spans
+// are exported before returning.
+func (t *Tracer) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			err := t.Flush()
+			if err != nil {
+				log.Warningln("tracing: failed to export spans during shutdown:", err)
+			}
+			return
+		case <-ticker.C:
+			err := t.Flush()
+			if err != nil {
+				log.Warningln("tracing: failed to export spans:", err)
+			}
+		}
+	}
+}
+
+// Flush exports all finished spans now
+func (t *Tracer) Flush() error {
+	t.mu.Lock()
+	spans := t.queue
+	dropped := t.dropped
+	t.queue = nil
+	t.dropped = 0
+	t.mu.Unlock()
+
+	if dropped > 0 {
+		log.Warningln("tracing: dropped", dropped, "spans because the export queue was full")
+	}
+	if len(spans) == 0 {
+		return nil
+	}
+
+	body, err := json.Marshal(t.otlpRequest(spans))
+	if err != nil {
+		return fmt.Errorf("error marshaling spans: %w", err)
+	}
+	resp, err := t.HTTPClient.Post(t.Endpoint+"/v1/traces", "application/json", bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("error exporting %d spans: %w", len(spans), err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("unexpected status code %d exporting %d spans", resp.StatusCode, len(spans))
+	}
+	return nil
+}
+
+// the OTLP/HTTP JSON encoding of an export request.  Trace and span IDs are hex encoded and timestamps
+// are decimal strings, as required by the OTLP JSON mapping.
+type otlpExportRequest struct {
+	ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+}
+
+type otlpResourceSpans struct {
+	Resource   otlpResource     `json:"resource"`
+	ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+}
+
+type otlpResource struct {
+	Attributes []otlpAttribute `json:"attributes"`
+}
+
+type otlpScopeSpans struct {
+	Scope otlpScope  `json:"scope"`
+	Spans []otlpSpan `json:"spans"`
+}
+
+type otlpScope struct {
+	Name string `json:"name"`
+}
+
+type otlpSpan struct {
+	TraceID           string          `json:"traceId"`
+	SpanID            string          `json:"spanId"`
+	ParentSpanID      string          `json:"parentSpanId,omitempty"`
+	Name              string          `json:"name"`
+	Kind              int             `json:"kind"`
+	StartTimeUnixNano string          `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string          `json:"endTimeUnixNano"`
+	Attributes        []otlpAttribute `json:"attributes,omitempty"`
+	Status            otlpStatus      `json:"status"`
+}
+
+type otlpAttribute struct {
+	Key   string             `json:"key"`
+	Value otlpAttributeValue `json:"value"`
+}
+
+type otlpAttributeValue struct {
+	StringValue string `json:"stringValue"`
+}
+
+type otlpStatus struct {
+	Code    int    `json:"code"`
+	Message string `json:"message,omitempty"`
+}
+
+// OTLP span kind and status codes
+const otlpSpanKindInternal = 1
+const otlpStatusCodeOK = 1
+const otlpStatusCodeError = 2
+
+// otlpRequest converts finished spans into an OTLP export request
+func (t *Tracer) otlpRequest(spans []*Span) otlpExportRequest {
+	scopeSpans := otlpScopeSpans{Scope: otlpScope{Name: t.ServiceName}}
+	for _, s := range spans {
+		s.mu.Lock()
+		span := otlpSpan{
+			TraceID:           hex.EncodeToString(s.Context.TraceID[:]),
+			SpanID:            hex.EncodeToString(s.Context.SpanID[:]),
+			Name:              s.Name,
+			Kind:              otlpSpanKindInternal,
+			StartTimeUnixNano: strconv.FormatInt(s.Start.UnixNano(), 10),
+			EndTimeUnixNano:   strconv.FormatInt(s.End.UnixNano(), 10),
+			Attributes:        attributes(s.Attributes),
+			Status:            otlpStatus{Code: otlpStatusCodeOK},
+		}
+		if s.Parent != [8]byte{} {
+			span.ParentSpanID = hex.EncodeToString(s.Parent[:])
+		}
+		if len(s.Error) > 0 {
+			span.Status = otlpStatus{Code: otlpStatusCodeError, Message: s.Error}
+		}
+		s.mu.Unlock()
+		scopeSpans.Spans = append(scopeSpans.Spans, span)
+	}
+
+	return otlpExportRequest{
+		ResourceSpans: []otlpResourceSpans{{
+			Resource:   otlpResource{Attributes: attributes(map[string]string{"service.name": t.ServiceName})},
+			ScopeSpans: []otlpScopeSpans{scopeSpans},
+		}},
+	}
+}
+
+// attributes converts a map of string attributes into OTLP attributes in a stable order
+func attributes(m map[string]string) []otlpAttribute {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var attrs []otlpAttribute
+	for _, k := range keys {
+		attrs = append(attrs, otlpAttribute{Key: k, Value: otlpAttributeValue{StringValue: m[k]}})
+	}
+	return attrs
+}
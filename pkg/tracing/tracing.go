@@ -0,0 +1,146 @@
+// Package tracing records spans across a check run and exports them to an OpenTelemetry collector over
+// OTLP/HTTP.  Trace context is propagated to checker pods in the W3C traceparent format so that spans
+// created by a check join the trace of the run that started it.
+package tracing
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// TraceparentEnv is the environment variable that holds the W3C traceparent of the current check run
+// in checker pods
+const TraceparentEnv = "TRACEPARENT"
+
+// TraceparentHeader is the HTTP header and gRPC metadata key that carries a W3C traceparent
+const TraceparentHeader = "traceparent"
+
+// DefaultTracer records spans started with the package level Start function.  Spans are not recorded
+// when this is nil.
+var DefaultTracer *Tracer
+
+// SpanContext identifies a span within a trace
+type SpanContext struct {
+	TraceID [16]byte
+	SpanID  [8]byte
+}
+
+// IsValid returns true if the span context has a trace and span ID
+func (sc SpanContext) IsValid() bool {
+	return sc.TraceID != [16]byte{} && sc.SpanID != [8]byte{}
+}
+
+// Traceparent formats the span context as a sampled W3C traceparent
+func (sc SpanContext) Traceparent() string {
+	return "00-" + hex.EncodeToString(sc.TraceID[:]) + "-" + hex.EncodeToString(sc.SpanID[:]) + "-01"
+}
+
+// ParseTraceparent parses a W3C traceparent
+func ParseTraceparent(traceparent string) (SpanContext, error) {
+	var sc SpanContext
+	parts := strings.Split(strings.TrimSpace(traceparent), "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || parts[0] == "ff" {
+		return sc, fmt.Errorf("invalid traceparent %q", traceparent)
+	}
+	traceID, err := hex.DecodeString(parts[1])
+	if err != nil || len(traceID) != len(sc.TraceID) {
+		return sc, fmt.Errorf("invalid trace id in traceparent %q", traceparent)
+	}
+	spanID, err := hex.DecodeString(parts[2])
+	if err != nil || len(spanID) != len(sc.SpanID) {
+		return sc, fmt.Errorf("invalid span id in traceparent %q", traceparent)
+	}
+	copy(sc.TraceID[:], traceID)
+	copy(sc.SpanID[:], spanID)
+	if !sc.IsValid() {
+		return sc, errors.New("traceparent has a blank trace or span id")
+	}
+	return sc, nil
+}
+
+// Span is a single timed operation within a trace
+type Span struct {
+	Name       string
+	Context    SpanContext
+	Parent     [8]byte // the span ID of the parent span.  Blank for root spans.
+	Start      time.Time
+	End        time.Time
+	Attributes map[string]string
+	Error      string // why the operation failed, if it did
+
+	tracer *Tracer
+	mu     sync.Mutex
+	ended  bool
+}
+
+// Start begins a span on the default tracer.  A new trace is started when the parent is not valid.
+func Start(name string, parent SpanContext) *Span {
+	return DefaultTracer.Start(name, parent)
+}
+
+// SpanContext returns the context of the span.  A nil span has a blank context.
+func (s *Span) SpanContext() SpanContext {
+	if s == nil {
+		return SpanContext{}
+	}
+	return s.Context
+}
+
+// Recording returns true if the span will be exported when it ends
+func (s *Span) Recording() bool {
+	return s != nil && s.tracer != nil
+}
+
+// SetAttribute sets a string attribute on the span
+func (s *Span) SetAttribute(key string, value string) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.Attributes[key] = value
+}
+
+// SetError marks the span as failed if the error is not nil
+func (s *Span) SetError(err error) {
+	if s == nil || err == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.Error = err.Error()
+}
+
+// Finish ends the span and queues it for export.  Spans can be finished more than once, which lets
+// callers defer Finish as a fallback for early returns.
+func (s *Span) Finish() {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	if s.ended {
+		s.mu.Unlock()
+		return
+	}
+	s.ended = true
+	s.End = time.Now()
+	s.mu.Unlock()
+
+	if s.tracer != nil {
+		s.tracer.enqueue(s)
+	}
+}
+
+// newID fills the supplied slice with random bytes
+func newID(b []byte) {
+	_, err := rand.Read(b)
+	if err != nil {
+		// crypto/rand does not fail on supported platforms, but fall back to the clock rather than a blank ID
+		copy(b, []byte(fmt.Sprintf("%016x", time.Now().UnixNano())))
+	}
+}
@@ -0,0 +1,80 @@
+package tracing
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// TestTraceparent validates that span contexts survive a round trip through a traceparent
+func TestTraceparent(t *testing.T) {
+	span := (*Tracer)(nil).Start("run", SpanContext{})
+	traceparent := span.SpanContext().Traceparent()
+	parsed, err := ParseTraceparent(traceparent)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parsed != span.SpanContext() {
+		t.Fatal("Expected", span.SpanContext(), "but got", parsed)
+	}
+
+	for _, invalid := range []string{"", "00-abc-def-01", "ff-" + traceparent[3:], "00-00000000000000000000000000000000-0000000000000000-01"} {
+		_, err = ParseTraceparent(invalid)
+		if err == nil {
+			t.Fatal("Expected an error parsing traceparent", invalid)
+		}
+	}
+}
+
+// TestTracerFlush validates that finished spans are exported as OTLP JSON with their parents
+func TestTracerFlush(t *testing.T) {
+	var received otlpExportRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/traces" {
+			t.Error("Unexpected export path", r.URL.Path)
+		}
+		b, _ := ioutil.ReadAll(r.Body)
+		err := json.Unmarshal(b, &received)
+		if err != nil {
+			t.Error(err)
+		}
+	}))
+	defer server.Close()
+
+	tracer := NewTracer("kuberhealthy", server.URL+"/")
+	root := tracer.Start("run", SpanContext{})
+	child := tracer.Start("create pod", root.SpanContext())
+	child.SetError(errors.New("pod create failed"))
+	child.Finish()
+	child.Finish() // finishing twice does not export twice
+	root.SetAttribute("check", "deployment")
+	root.Finish()
+
+	err := tracer.Flush()
+	if err != nil {
+		t.Fatal(err)
+	}
+	spans := received.ResourceSpans[0].ScopeSpans[0].Spans
+	if len(spans) != 2 {
+		t.Fatal("Expected two exported spans but got", len(spans))
+	}
+	if spans[0].TraceID != spans[1].TraceID || spans[0].ParentSpanID != spans[1].SpanID {
+		t.Fatal("Expected the child span to belong to the root span but got", spans)
+	}
+	if spans[0].Status.Code != otlpStatusCodeError || spans[0].Status.Message != "pod create failed" {
+		t.Fatal("Expected the child span to be marked as failed but got", spans[0].Status)
+	}
+	if len(spans[1].Attributes) != 1 || spans[1].Attributes[0].Value.StringValue != "deployment" {
+		t.Fatal("Unexpected root span attributes:", spans[1].Attributes)
+	}
+
+	// nothing is exported when there are no new spans
+	received = otlpExportRequest{}
+	err = tracer.Flush()
+	if err != nil || len(received.ResourceSpans) != 0 {
+		t.Fatal("Expected no export without new spans but got", received, err)
+	}
+}
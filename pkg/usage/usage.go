@@ -0,0 +1,129 @@
+// Package usage accounts for the cluster resources that checker pods reserve, so that the cost of synthetic
+// monitoring can be attributed to the checks that incur it.  Usage is measured in request-seconds: the CPU and
+// memory a checker pod requests multiplied by how long its run took.
+package usage
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	apiv1 "k8s.io/api/core/v1"
+)
+
+// Requests are the CPU and memory a pod requests from the scheduler
+type Requests struct {
+	CPU    float64 // CPU cores
+	Memory float64 // bytes
+}
+
+// PodRequests returns the resources the scheduler reserves for a pod with the supplied spec.  Like the
+// scheduler, this is the larger of the sum of the requests of its containers and the largest request of an
+// init container.
+func PodRequests(spec apiv1.PodSpec) Requests {
+	var requests Requests
+	for _, c := range spec.Containers {
+		requests.CPU += float64(c.Resources.Requests.Cpu().MilliValue()) / 1000
+		requests.Memory += float64(c.Resources.Requests.Memory().Value())
+	}
+	for _, c := range spec.InitContainers {
+		cpu := float64(c.Resources.Requests.Cpu().MilliValue()) / 1000
+		if cpu > requests.CPU {
+			requests.CPU = cpu
+		}
+		memory := float64(c.Resources.Requests.Memory().Value())
+		if memory > requests.Memory {
+			requests.Memory = memory
+		}
+	}
+	return requests
+}
+
+// CheckUsage is the resources the checker pods of a check have reserved since Kuberhealthy started
+type CheckUsage struct {
+	Name              string  `json:"name"`
+	Namespace         string  `json:"namespace"`
+	Runs              int     `json:"runs"`              // the number of runs that created a checker pod
+	PodSeconds        float64 `json:"podSeconds"`        // how long checker pods ran in total
+	CPUCoreSeconds    float64 `json:"cpuCoreSeconds"`    // the requested CPU cores multiplied by how long they were requested
+	MemoryByteSeconds float64 `json:"memoryByteSeconds"` // the requested memory bytes multiplied by how long they were requested
+}
+
+// Tracker adds up the usage of the checker pods of every check
+type Tracker struct {
+	mu     sync.RWMutex
+	checks map[string]*CheckUsage
+}
+
+// New creates a Tracker without any usage
+func New() *Tracker {
+	return &Tracker{
+		checks: make(map[string]*CheckUsage),
+	}
+}
+
+// Record adds a run of a check whose checker pod reserved the supplied requests for the supplied duration
+func (t *Tracker) Record(namespace string, name string, requests Requests, duration time.Duration) {
+	if duration < 0 {
+		duration = 0
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	key := namespace + "/" + name
+	u, ok := t.checks[key]
+	if !ok {
+		u = &CheckUsage{Name: name, Namespace: namespace}
+		t.checks[key] = u
+	}
+	seconds := duration.Seconds()
+	u.Runs++
+	u.PodSeconds += seconds
+	u.CPUCoreSeconds += requests.CPU * seconds
+	u.MemoryByteSeconds += requests.Memory * seconds
+}
+
+// List returns a copy of the usage of every check that has recorded a run, sorted by namespace and name
+func (t *Tracker) List() []CheckUsage {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	list := make([]CheckUsage, 0, len(t.checks))
+	for _, u := range t.checks {
+		list = append(list, *u)
+	}
+	Sort(list)
+	return list
+}
+
+// Merge adds up the usage of checks that were recorded more than once, such as by several Kuberhealthy pods
+// that each ran the check for a while, and returns the combined usage sorted by namespace and name
+func Merge(list []CheckUsage) []CheckUsage {
+	merged := make(map[string]*CheckUsage)
+	for _, u := range list {
+		key := u.Namespace + "/" + u.Name
+		m, ok := merged[key]
+		if !ok {
+			m = &CheckUsage{Name: u.Name, Namespace: u.Namespace}
+			merged[key] = m
+		}
+		m.Runs += u.Runs
+		m.PodSeconds += u.PodSeconds
+		m.CPUCoreSeconds += u.CPUCoreSeconds
+		m.MemoryByteSeconds += u.MemoryByteSeconds
+	}
+	result := make([]CheckUsage, 0, len(merged))
+	for _, m := range merged {
+		result = append(result, *m)
+	}
+	Sort(result)
+	return result
+}
+
+// Sort sorts usage by namespace and name
+func Sort(list []CheckUsage) {
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Namespace != list[j].Namespace {
+			return list[i].Namespace < list[j].Namespace
+		}
+		return list[i].Name < list[j].Name
+	})
+}
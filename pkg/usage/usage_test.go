@@ -0,0 +1,67 @@
+package usage
+
+import (
+	"testing"
+	"time"
+
+	apiv1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// requestsOf returns resource requirements that request the supplied CPU and memory
+func requestsOf(cpu string, memory string) apiv1.ResourceRequirements {
+	return apiv1.ResourceRequirements{
+		Requests: apiv1.ResourceList{
+			apiv1.ResourceCPU:    resource.MustParse(cpu),
+			apiv1.ResourceMemory: resource.MustParse(memory),
+		},
+	}
+}
+
+// TestPodRequests validates that container requests are added up and that a larger init container request wins
+func TestPodRequests(t *testing.T) {
+	spec := apiv1.PodSpec{
+		InitContainers: []apiv1.Container{{Name: "init", Resources: requestsOf("500m", "1Mi")}},
+		Containers: []apiv1.Container{
+			{Name: "main", Resources: requestsOf("100m", "10Mi")},
+			{Name: "sidecar", Resources: requestsOf("50m", "5Mi")},
+			{Name: "unrequested"},
+		},
+	}
+	requests := PodRequests(spec)
+	if requests.CPU != 0.5 {
+		t.Fatal("Expected the init container CPU request of 0.5 cores but got", requests.CPU)
+	}
+	if requests.Memory != 15*1024*1024 {
+		t.Fatal("Expected the container memory requests to add up to 15Mi but got", requests.Memory)
+	}
+}
+
+// TestTracker validates that runs are added up per check and that usage from several trackers can be merged
+func TestTracker(t *testing.T) {
+	tracker := New()
+	requests := Requests{CPU: 0.1, Memory: 1000}
+	tracker.Record("kuberhealthy", "dns", requests, time.Second*10)
+	tracker.Record("kuberhealthy", "dns", requests, time.Second*20)
+	tracker.Record("default", "http", requests, time.Second)
+
+	list := tracker.List()
+	if len(list) != 2 {
+		t.Fatal("Expected usage of 2 checks but got", len(list))
+	}
+	if list[0].Name != "http" {
+		t.Fatal("Expected usage to be sorted by namespace but got", list[0].Namespace+"/"+list[0].Name, "first")
+	}
+	dns := list[1]
+	if dns.Runs != 2 || dns.PodSeconds != 30 {
+		t.Fatal("Expected 2 runs and 30 pod seconds but got", dns.Runs, "runs and", dns.PodSeconds, "pod seconds")
+	}
+	if dns.MemoryByteSeconds != 30000 {
+		t.Fatal("Expected 30000 memory byte seconds but got", dns.MemoryByteSeconds)
+	}
+
+	merged := Merge(append(list, CheckUsage{Name: "dns", Namespace: "kuberhealthy", Runs: 1, PodSeconds: 5}))
+	if len(merged) != 2 || merged[1].Runs != 3 || merged[1].PodSeconds != 35 {
+		t.Fatal("Expected merged usage to add up the runs of dns but got", merged)
+	}
+}